@@ -335,6 +335,15 @@ func processDownlinkMessages(ctx context.Context, log *logger.Logger, rmqClient
 		return
 	}
 
+	// Replies to device requests and status reports go back down the same way
+	for _, action := range []string{dji.ActionDeviceRequestReply, dji.ActionStatusReply} {
+		replyPattern := rabbitmq.BuildBindingPattern(dji.VendorDJI, "", action)
+		if err := rmqClient.BindQueue(queueName, replyPattern, rmqCfg.ExchangeName); err != nil {
+			log.WithError(err).Error("Failed to bind reply queue")
+			return
+		}
+	}
+
 	// Get channel and consume messages
 	channel := rmqClient.Channel()
 	if channel == nil {
@@ -6,20 +6,31 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/utmos/utmos/internal/account"
 	"github.com/utmos/utmos/internal/api"
 	"github.com/utmos/utmos/internal/api/handler"
+	"github.com/utmos/utmos/internal/api/middleware"
 	"github.com/utmos/utmos/internal/downlink/dispatcher"
+	"github.com/utmos/utmos/internal/organization"
+	"github.com/utmos/utmos/internal/scheduler"
 	"github.com/utmos/utmos/internal/shared/config"
 	"github.com/utmos/utmos/internal/shared/database"
 	"github.com/utmos/utmos/internal/shared/server"
-	"github.com/utmos/utmos/pkg/logger"
+	uplinkrouter "github.com/utmos/utmos/internal/uplink/router"
+	"github.com/utmos/utmos/internal/ws"
 	djidownlink "github.com/utmos/utmos/pkg/adapter/dji/downlink"
+	"github.com/utmos/utmos/pkg/adapter/dji/wpml"
+	"github.com/utmos/utmos/pkg/logger"
 	"github.com/utmos/utmos/pkg/metrics"
 	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
 	"github.com/utmos/utmos/pkg/rabbitmq"
+	"github.com/utmos/utmos/pkg/terrain"
 	"github.com/utmos/utmos/pkg/tracer"
 )
 
@@ -44,6 +55,30 @@ import (
 
 const serviceName = "iot-api"
 
+// mediaEventQueue receives uplink events for the media catalog.
+const mediaEventQueue = "iot.api.media"
+
+// topologyStatusQueue receives device status messages for the device topology.
+const topologyStatusQueue = "iot.api.topology"
+
+// organizationRequestQueue receives dock organization binding requests.
+const organizationRequestQueue = "iot.api.organization"
+
+// flightAreaRequestQueue receives dock flight_areas_get requests.
+const flightAreaRequestQueue = "iot.api.flightarea.request"
+
+// flightAreaEventQueue receives uplink events for flight area sync progress.
+const flightAreaEventQueue = "iot.api.flightarea.event"
+
+// unlockLicenseReplyQueue receives dock replies to unlock licence commands.
+const unlockLicenseReplyQueue = "iot.api.unlocklicense"
+
+// conditionPropertyQueue receives dock OSD for the scheduler's weather checks.
+const conditionPropertyQueue = "iot.api.conditions.property"
+
+// conditionEventQueue receives dock HMS events for the scheduler's checks.
+const conditionEventQueue = "iot.api.conditions.event"
+
 func main() {
 	// Load configuration
 	cfg, err := config.LoadFromEnv("dev")
@@ -98,15 +133,46 @@ func main() {
 	dispatcherRegistry.Register(dispatcher.NewAdapterDispatcher(djiDispatcher))
 	dispatchHandler := dispatcher.NewDispatchHandler(dispatcherRegistry, log.WithService(serviceName))
 
-	// Get API keys from environment
-	apiKeys := getAPIKeys()
+	// Initialize object store for wayline files
+	objectStore, urlSigner, objectCredentials, err := getObjectStore()
+	if err != nil {
+		log.WithService(serviceName).Warnf("failed to initialize object store: %v", err)
+	}
+
+	// Get API keys and account settings from environment
+	apiKeys, apiKeyWorkspaces := getAPIKeys()
+	accountConfig := getAccountConfig()
+
+	// Load terrain models for above ground level and EGM96 waylines
+	terrainModel, err := getTerrainModel()
+	if err != nil {
+		log.WithService(serviceName).Warnf("failed to load terrain models: %v", err)
+	}
+	minTerrainClearance, _ := strconv.ParseFloat(os.Getenv("TERRAIN_MIN_CLEARANCE"), 64)
+
+	// Load the no-fly zones and geofences waylines are checked against
+	geofences, err := getGeofences()
+	if err != nil {
+		log.WithService(serviceName).Warnf("failed to load geofences: %v", err)
+	}
 
 	// Create router configuration
 	routerConfig := &api.Config{
-		APIKeys:     apiKeys,
-		EnableAuth:  len(apiKeys) > 0,
-		EnableTrace: true,
-		ServiceName: serviceName,
+		APIKeys:             apiKeys,
+		APIKeyWorkspaces:    apiKeyWorkspaces,
+		Accounts:            accountConfig,
+		EnableAuth:          len(apiKeys) > 0 || accountConfig != nil,
+		EnableTrace:         true,
+		ServiceName:         serviceName,
+		EnableScheduler:     os.Getenv("SCHEDULER_ENABLED") != "false",
+		ObjectStore:         objectStore,
+		ObjectURLSigner:     urlSigner,
+		ObjectCredentials:   objectCredentials,
+		PilotTokens:         middleware.ParsePilotTokens(os.Getenv("PILOT_TOKENS")),
+		WorkspaceNotifier:   ws.NewNotifier(publisher, serviceName),
+		Terrain:             terrainModel,
+		MinTerrainClearance: minTerrainClearance,
+		Geofences:           geofences,
 		TelemetryConfig: &handler.TelemetryConfig{
 			URL:    cfg.Database.InfluxDB.URL,
 			Token:  cfg.Database.InfluxDB.Token,
@@ -124,6 +190,84 @@ func main() {
 		log.WithService(serviceName),
 	)
 
+	// Bootstrap the first administrator
+	if accountService := apiRouter.AccountService(); accountService != nil {
+		if username, password := os.Getenv("ADMIN_USERNAME"), os.Getenv("ADMIN_PASSWORD"); username != "" && password != "" {
+			if err := accountService.EnsureUser(context.Background(), username, password, account.RoleAdmin); err != nil {
+				log.WithService(serviceName).Warnf("failed to create admin user: %v", err)
+			}
+		}
+	}
+
+	// Catalog media reported by dock file_upload_callback events
+	subscriber := rabbitmq.NewSubscriber(rmqClient)
+	if mediaService := apiRouter.MediaService(); mediaService != nil && rmqClient.IsConnected() {
+		if err := rmqClient.SetupQueueWithBinding(mediaEventQueue, uplinkrouter.RoutingKeyAPIEvent); err != nil {
+			log.WithService(serviceName).Warnf("failed to setup media event queue: %v", err)
+		} else if err := subscriber.Subscribe(mediaEventQueue, mediaService.HandleUplinkEvent); err != nil {
+			log.WithService(serviceName).Warnf("failed to subscribe to media events: %v", err)
+		}
+	}
+
+	// Persist gateway topology reported by device status messages
+	if topologyService := apiRouter.TopologyService(); topologyService != nil && rmqClient.IsConnected() {
+		topologyService.SetReplyPublisher(publisher)
+		if err := rmqClient.SetupQueueWithBinding(topologyStatusQueue, uplinkrouter.RoutingKeyAPIStatus); err != nil {
+			log.WithService(serviceName).Warnf("failed to setup topology status queue: %v", err)
+		} else if err := subscriber.Subscribe(topologyStatusQueue, topologyService.HandleUplinkStatus); err != nil {
+			log.WithService(serviceName).Warnf("failed to subscribe to device status: %v", err)
+		}
+	}
+
+	// Answer dock organization binding requests
+	if rmqClient.IsConnected() {
+		organizationService := organization.NewService(db, publisher, log.WithService(serviceName))
+		if err := rmqClient.SetupQueueWithBinding(organizationRequestQueue, organization.RoutingKeyRequest); err != nil {
+			log.WithService(serviceName).Warnf("failed to setup organization request queue: %v", err)
+		} else if err := subscriber.Subscribe(organizationRequestQueue, organizationService.HandleDeviceRequest); err != nil {
+			log.WithService(serviceName).Warnf("failed to subscribe to organization requests: %v", err)
+		}
+	}
+
+	// Serve flight area files to docks and track their synchronization
+	if flightAreaService := apiRouter.FlightAreaService(); flightAreaService != nil && rmqClient.IsConnected() {
+		flightAreaService.SetReplyPublisher(publisher)
+		if err := rmqClient.SetupQueueWithBinding(flightAreaRequestQueue, organization.RoutingKeyRequest); err != nil {
+			log.WithService(serviceName).Warnf("failed to setup flight area request queue: %v", err)
+		} else if err := subscriber.Subscribe(flightAreaRequestQueue, flightAreaService.HandleDeviceRequest); err != nil {
+			log.WithService(serviceName).Warnf("failed to subscribe to flight area requests: %v", err)
+		}
+		if err := rmqClient.SetupQueueWithBinding(flightAreaEventQueue, uplinkrouter.RoutingKeyAPIEvent); err != nil {
+			log.WithService(serviceName).Warnf("failed to setup flight area event queue: %v", err)
+		} else if err := subscriber.Subscribe(flightAreaEventQueue, flightAreaService.HandleUplinkEvent); err != nil {
+			log.WithService(serviceName).Warnf("failed to subscribe to flight area events: %v", err)
+		}
+	}
+
+	// Record dock replies to unlock licence commands
+	if unlockLicenseService := apiRouter.UnlockLicenseService(); unlockLicenseService != nil && rmqClient.IsConnected() {
+		if err := rmqClient.SetupQueueWithBinding(unlockLicenseReplyQueue, uplinkrouter.RoutingKeyAPIService); err != nil {
+			log.WithService(serviceName).Warnf("failed to setup unlock licence reply queue: %v", err)
+		} else if err := subscriber.Subscribe(unlockLicenseReplyQueue, unlockLicenseService.HandleServiceReply); err != nil {
+			log.WithService(serviceName).Warnf("failed to subscribe to unlock licence replies: %v", err)
+		}
+	}
+
+	// Record the dock weather and HMS state scheduled flights are checked against
+	if rmqClient.IsConnected() {
+		conditionRecorder := scheduler.NewConditionRecorder(db, nil, log.WithService(serviceName))
+		if err := rmqClient.SetupQueueWithBinding(conditionPropertyQueue, uplinkrouter.RoutingKeyAPIProperty); err != nil {
+			log.WithService(serviceName).Warnf("failed to setup condition property queue: %v", err)
+		} else if err := subscriber.Subscribe(conditionPropertyQueue, conditionRecorder.HandleUplinkProperty); err != nil {
+			log.WithService(serviceName).Warnf("failed to subscribe to dock properties: %v", err)
+		}
+		if err := rmqClient.SetupQueueWithBinding(conditionEventQueue, uplinkrouter.RoutingKeyAPIEvent); err != nil {
+			log.WithService(serviceName).Warnf("failed to setup condition event queue: %v", err)
+		} else if err := subscriber.Subscribe(conditionEventQueue, conditionRecorder.HandleUplinkEvent); err != nil {
+			log.WithService(serviceName).Warnf("failed to subscribe to dock events: %v", err)
+		}
+	}
+
 	// Create HTTP server
 	srv := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
@@ -143,6 +287,11 @@ func main() {
 		apiRouter.Close()
 		return nil
 	})
+	shutdown.Register(func(_ context.Context) error {
+		log.WithService(serviceName).Info("Stopping RabbitMQ subscriber")
+		subscriber.UnsubscribeAll()
+		return nil
+	})
 	shutdown.Register(func(_ context.Context) error {
 		log.WithService(serviceName).Info("Closing RabbitMQ connection")
 		return rmqClient.Close()
@@ -174,20 +323,140 @@ func main() {
 	log.WithService(serviceName).Info("Service stopped")
 }
 
-// getAPIKeys returns API keys from environment
-func getAPIKeys() []string {
+// getAPIKeys returns API keys from environment. Entries of the form
+// key=workspace_id confine the key to that workspace.
+func getAPIKeys() ([]string, map[string]string) {
 	keysStr := os.Getenv("API_KEYS")
 	if keysStr == "" {
-		return nil
+		return nil, nil
 	}
 
 	keys := strings.Split(keysStr, ",")
 	result := make([]string, 0, len(keys))
-	for _, key := range keys {
-		key = strings.TrimSpace(key)
-		if key != "" {
-			result = append(result, key)
+	workspaces := make(map[string]string)
+	for _, entry := range keys {
+		key, workspaceID, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		if key == "" {
+			continue
+		}
+		result = append(result, key)
+		if workspaceID != "" {
+			workspaces[key] = workspaceID
+		}
+	}
+	return result, workspaces
+}
+
+// getAccountConfig returns the account settings from environment, or nil
+// when AUTH_TOKEN_SECRET is not set
+func getAccountConfig() *account.Config {
+	secret := os.Getenv("AUTH_TOKEN_SECRET")
+	if secret == "" {
+		return nil
+	}
+	cfg := account.DefaultConfig()
+	cfg.Secret = []byte(secret)
+	if ttl, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL")); err == nil && ttl > 0 {
+		cfg.AccessTokenTTL = ttl
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL")); err == nil && ttl > 0 {
+		cfg.RefreshTokenTTL = ttl
+	}
+	return cfg
+}
+
+// getTerrainModel loads the elevation models in TERRAIN_DEM_DIR and the
+// EGM96 geoid grid in TERRAIN_GEOID_FILE, or returns nil when neither is set
+func getTerrainModel() (*wpml.TerrainModel, error) {
+	demDir, geoidFile := os.Getenv("TERRAIN_DEM_DIR"), os.Getenv("TERRAIN_GEOID_FILE")
+	if demDir == "" && geoidFile == "" {
+		return nil, nil
+	}
+	model := &wpml.TerrainModel{}
+	if demDir != "" {
+		dem, err := terrain.OpenDirectory(demDir)
+		if err != nil {
+			return nil, err
+		}
+		model.DEM = dem
+	}
+	if geoidFile != "" {
+		geoid, err := terrain.LoadEGM96Grid(geoidFile)
+		if err != nil {
+			return nil, err
 		}
+		model.Geoid = geoid
+	}
+	return model, nil
+}
+
+// getObjectStore returns the S3 or MinIO bucket OBJECT_STORE_ENDPOINT and
+// OBJECT_STORE_BUCKET, with STS AssumeRole credentials for Pilot 2 uploads.
+// Without a bucket, objects are kept in OBJECT_STORE_DIR and Pilot 2 gets no
+// upload credentials, since it could not upload there.
+func getObjectStore() (objectstore.Store, *objectstore.URLSigner, objectstore.CredentialProvider, error) {
+	endpoint, bucket := os.Getenv("OBJECT_STORE_ENDPOINT"), os.Getenv("OBJECT_STORE_BUCKET")
+	if endpoint == "" || bucket == "" {
+		localStore, err := objectstore.NewLocalStore(getEnv("OBJECT_STORE_DIR", "./data/objects"))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		var urlSigner *objectstore.URLSigner
+		if baseURL, secret := os.Getenv("OBJECT_URL_BASE"), os.Getenv("OBJECT_URL_SECRET"); baseURL != "" && secret != "" {
+			urlSigner = objectstore.NewURLSigner(baseURL, []byte(secret))
+			localStore.SetURLSigner(urlSigner)
+		}
+		return localStore, urlSigner, nil, nil
+	}
+
+	provider := getEnv("OBJECT_STORE_PROVIDER", "minio")
+	region := os.Getenv("OBJECT_STORE_REGION")
+	accessKey, secretKey := os.Getenv("OBJECT_STORE_ACCESS_KEY"), os.Getenv("OBJECT_STORE_SECRET_KEY")
+	s3Store, err := objectstore.NewS3Store(objectstore.S3Config{
+		Endpoint:        endpoint,
+		Bucket:          bucket,
+		Region:          region,
+		AccessKeyID:     accessKey,
+		AccessKeySecret: secretKey,
+		PathStyle:       provider != "aws",
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	credentials, err := objectstore.NewAssumeRoleCredentials(objectstore.AssumeRoleConfig{
+		Provider:        provider,
+		Endpoint:        endpoint,
+		Bucket:          bucket,
+		Region:          region,
+		STSEndpoint:     getEnv("OBJECT_STORE_STS_ENDPOINT", endpoint),
+		AccessKeyID:     accessKey,
+		AccessKeySecret: secretKey,
+		RoleARN:         os.Getenv("OBJECT_STORE_ROLE_ARN"),
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return s3Store, nil, credentials, nil
+}
+
+// getGeofences loads the no-fly zones and geofences in the GeoJSON file
+// GEOFENCE_FILE, or returns nil when it is not set
+func getGeofences() ([]wpml.Geofence, error) {
+	path := os.Getenv("GEOFENCE_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	return wpml.ParseGeofences(data)
+}
+
+// getEnv returns the environment variable value or a default
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
 	}
-	return result
+	return defaultValue
 }
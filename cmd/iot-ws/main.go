@@ -5,11 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 
+	"github.com/utmos/utmos/internal/account"
+	"github.com/utmos/utmos/internal/api/middleware"
 	"github.com/utmos/utmos/internal/shared/config"
+	"github.com/utmos/utmos/internal/shared/database"
 	"github.com/utmos/utmos/internal/shared/server"
 	"github.com/utmos/utmos/pkg/logger"
 	"github.com/utmos/utmos/internal/ws"
@@ -49,11 +55,16 @@ func main() {
 		log.WithService(serviceName).Warnf("failed to connect to RabbitMQ: %v", err)
 	}
 
-	// Setup Exchange
+	// Setup Exchange and this replica's queue; every replica receives each
+	// message for WebSocket clients and pushes it to its own clients
+	instanceID := getInstanceID()
 	if rmqClient.IsConnected() {
 		if err := rmqClient.SetupExchange(); err != nil {
 			log.WithService(serviceName).Warnf("failed to setup exchange: %v", err)
 		}
+		if err := rmqClient.SetupExclusiveQueueWithBinding(ws.QueueName(instanceID), ws.RoutingKeyPattern); err != nil {
+			log.WithService(serviceName).Warnf("failed to setup queue: %v", err)
+		}
 	}
 
 	// Initialize RabbitMQ subscriber
@@ -70,15 +81,49 @@ func main() {
 		},
 		ClientConfig: hub.DefaultClientConfig(),
 		PusherConfig: &push.Config{
-			WorkerCount: 4,
-			QueueSize:   10000,
+			WorkerCount:   4,
+			QueueSize:     10000,
+			HistorySize:   push.DefaultHistorySize,
+			HistoryTopics: push.DefaultHistoryTopics,
 		},
-		AllowedOrigins: []string{"*"},
+		AllowedOrigins:      getAllowedOrigins(),
+		AuthRecheckInterval: ws.DefaultAuthRecheckInterval,
+		InstanceID:          instanceID,
+		StatsInterval:       ws.DefaultStatsInterval,
 	}
 
 	// Create WebSocket service
 	wsSvc := ws.NewService(wsConfig, metricsCollector, log.WithService(serviceName))
 	wsSvc.SetSubscriber(subscriber)
+	wsSvc.SetPublisher(rabbitmq.NewPublisher(rmqClient))
+
+	// Device telemetry is pushed to the device's workspace when the device
+	// registry is reachable
+	accountConfig := getAccountConfig()
+	if db, err := database.NewPostgresDB(&cfg.Database.Postgres); err != nil {
+		if accountConfig != nil {
+			log.WithService(serviceName).Fatalf("failed to connect to database for authentication: %v", err)
+		}
+		log.WithService(serviceName).Warnf("failed to connect to database, device OSD is not pushed to workspaces: %v", err)
+	} else {
+		wsSvc.SetWorkspaceResolver(ws.NewDeviceWorkspaces(db, ws.DefaultWorkspaceCacheTTL))
+
+		// Clients authenticate with the access tokens and API keys issued
+		// by iot-api when a token secret is configured; DJI Pilot 2 may
+		// also connect with the static PILOT_TOKENS iot-api accepts
+		if accountConfig != nil {
+			accountService, err := account.NewService(db, accountConfig, log.WithService(serviceName))
+			if err != nil {
+				log.WithService(serviceName).Fatalf("failed to create account service: %v", err)
+			}
+			wsSvc.SetAuthenticator(middleware.Authenticators{
+				middleware.ParsePilotTokens(os.Getenv("PILOT_TOKENS")),
+				accountService,
+			})
+		} else {
+			log.WithService(serviceName).Warn("AUTH_TOKEN_SECRET is not set, WebSocket clients are not authenticated")
+		}
+	}
 
 	// Start WebSocket service
 	if err := wsSvc.Start(context.Background()); err != nil {
@@ -113,6 +158,9 @@ func main() {
 		stats := wsSvc.GetStats()
 		c.JSON(http.StatusOK, stats)
 	})
+	router.GET("/stats/cluster", func(c *gin.Context) {
+		c.JSON(http.StatusOK, wsSvc.GetClusterStats())
+	})
 
 	// WebSocket endpoint
 	router.GET("/ws", func(c *gin.Context) {
@@ -168,3 +216,46 @@ func main() {
 	}
 	log.WithService(serviceName).Info("Service stopped")
 }
+
+// getInstanceID returns WS_INSTANCE_ID, or the host name with a random
+// suffix so that a restarted replica never reuses a queue still held by its
+// previous connection
+func getInstanceID() string {
+	if id := os.Getenv("WS_INSTANCE_ID"); id != "" {
+		return id
+	}
+	suffix := uuid.New().String()[:8]
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname + "-" + suffix
+	}
+	return suffix
+}
+
+// getAllowedOrigins parses WS_ALLOWED_ORIGINS, a comma separated list of
+// origins allowed to open WebSocket connections. Every origin is allowed
+// when it is not set.
+func getAllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		return []string{"*"}
+	}
+	return origins
+}
+
+// getAccountConfig returns the account settings from environment, or nil
+// when AUTH_TOKEN_SECRET is not set. It must match iot-api's settings for
+// tokens issued by iot-api to be accepted.
+func getAccountConfig() *account.Config {
+	secret := os.Getenv("AUTH_TOKEN_SECRET")
+	if secret == "" {
+		return nil
+	}
+	cfg := account.DefaultConfig()
+	cfg.Secret = []byte(secret)
+	return cfg
+}
@@ -0,0 +1,84 @@
+// Package account manages iot-api users and API keys: password sign-in,
+// JWT access and refresh tokens, and the roles that decide what a caller
+// may do.
+package account
+
+import "time"
+
+// Role is the role of a user or API key.
+type Role string
+
+// Roles, from least to most privileged.
+const (
+	// RoleViewer may read resources.
+	RoleViewer Role = "viewer"
+	// RoleOperator may also change resources and call device services.
+	RoleOperator Role = "operator"
+	// RolePilot may also fly: DRC, flight tasks and other flight control
+	// services.
+	RolePilot Role = "pilot"
+	// RoleAdmin may also manage workspaces, users and API keys.
+	RoleAdmin Role = "admin"
+)
+
+// Permission is an action a role may be allowed to take.
+type Permission string
+
+// Permissions checked by the API routes.
+const (
+	PermissionRead          Permission = "read"
+	PermissionWrite         Permission = "write"
+	PermissionServiceCall   Permission = "service_call"
+	PermissionFlightControl Permission = "flight_control"
+	PermissionAdmin         Permission = "admin"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:   {PermissionRead},
+	RoleOperator: {PermissionRead, PermissionWrite, PermissionServiceCall},
+	RolePilot:    {PermissionRead, PermissionWrite, PermissionServiceCall, PermissionFlightControl},
+	RoleAdmin:    {PermissionRead, PermissionWrite, PermissionServiceCall, PermissionFlightControl, PermissionAdmin},
+}
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role grants the permission.
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions returns the permissions the role grants.
+func (r Role) Permissions() []Permission {
+	return append([]Permission(nil), rolePermissions[r]...)
+}
+
+// Identity is an authenticated caller: a user signed in with a token or a
+// managed API key.
+type Identity struct {
+	// ExpiresAt is when the credential stops being valid; nil if never
+	ExpiresAt *time.Time
+	// Subject identifies the credential owner, e.g. "user:1" or "api_key:2"
+	Subject string
+	// Name is the username or API key name
+	Name string
+	Role Role
+	// WorkspaceID confines the caller to a workspace; empty for callers with
+	// access to every workspace
+	WorkspaceID string
+	// UserID is the signed-in user, zero for API keys
+	UserID uint
+}
+
+// Can reports whether the caller's role grants the permission.
+func (i *Identity) Can(permission Permission) bool {
+	return i.Role.Can(permission)
+}
@@ -0,0 +1,477 @@
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// APIKeyPrefix starts every managed API key.
+const APIKeyPrefix = "utm_"
+
+// MinSecretLength is the minimum length of the token signing secret.
+const MinSecretLength = 32
+
+var (
+	// ErrInvalidCredentials is returned for unknown users, wrong passwords,
+	// disabled users and revoked or expired API keys.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrUserNotFound is returned when the user does not exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists is returned when creating a user with a username in use.
+	ErrUserExists = errors.New("user already exists")
+	// ErrInvalidRole is returned for unknown roles.
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrAPIKeyNotFound is returned when the API key does not exist.
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// dummyHash is compared against when signing in as an unknown user, so the
+// response time does not reveal which usernames exist.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("utmos-dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// Config holds token settings.
+type Config struct {
+	// Secret signs access and refresh tokens; at least MinSecretLength bytes
+	Secret []byte
+	// AccessTokenTTL is the lifetime of access tokens
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is the lifetime of refresh tokens
+	RefreshTokenTTL time.Duration
+}
+
+// DefaultConfig returns the default token settings, without a secret.
+func DefaultConfig() *Config {
+	return &Config{
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 7 * 24 * time.Hour,
+	}
+}
+
+// TokenPair is issued on sign-in and refresh.
+type TokenPair struct {
+	AccessTokenExpiresAt  time.Time
+	RefreshTokenExpiresAt time.Time
+	AccessToken           string
+	RefreshToken          string
+}
+
+// UserUpdate lists the user fields to change; nil fields are kept.
+type UserUpdate struct {
+	Password    *string
+	Role        *Role
+	WorkspaceID *string
+	Disabled    *bool
+}
+
+// Service manages users, API keys and tokens.
+type Service struct {
+	db     *gorm.DB
+	config *Config
+	logger *logrus.Entry
+	now    func() time.Time
+}
+
+// NewService creates a new account service. It fails when the secret is
+// shorter than MinSecretLength.
+func NewService(db *gorm.DB, config *Config, logger *logrus.Entry) (*Service, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if len(config.Secret) < MinSecretLength {
+		return nil, fmt.Errorf("token secret must be at least %d bytes", MinSecretLength)
+	}
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Service{
+		db:     db,
+		config: config,
+		logger: logger.WithField("component", "account"),
+		now:    time.Now,
+	}, nil
+}
+
+// CreateUser creates a user. A non-empty workspaceID confines the user to
+// that workspace.
+func (s *Service) CreateUser(ctx context.Context, username, password string, role Role, workspaceID string) (*models.User, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check user: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrUserExists
+	}
+
+	user := &models.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         string(role),
+		WorkspaceID:  workspaceID,
+	}
+	if err := s.db.WithContext(ctx).Create(user).Error; err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"username": username,
+		"role":     role,
+	}).Info("User created")
+	return user, nil
+}
+
+// EnsureUser creates the user unless one with the username exists. It
+// bootstraps the first administrator.
+func (s *Service) EnsureUser(ctx context.Context, username, password string, role Role) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check user: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := s.CreateUser(ctx, username, password, role, "")
+	return err
+}
+
+// GetUser returns a user.
+func (s *Service) GetUser(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).First(&user, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+// ListUsers returns users ordered by username. A non-empty workspaceID
+// restricts the result to users confined to that workspace.
+func (s *Service) ListUsers(ctx context.Context, workspaceID string) ([]models.User, error) {
+	query := s.db.WithContext(ctx)
+	if workspaceID != "" {
+		query = query.Where("workspace_id = ?", workspaceID)
+	}
+	var users []models.User
+	if err := query.Order("username ASC").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+// UpdateUser changes a user. Any change revokes the tokens issued to the
+// user, so new privileges or a new password take effect at once.
+func (s *Service) UpdateUser(ctx context.Context, id uint, update UserUpdate) (*models.User, error) {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if update.Password != nil {
+		hash, err := hashPassword(*update.Password)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = hash
+	}
+	if update.Role != nil {
+		if !update.Role.Valid() {
+			return nil, ErrInvalidRole
+		}
+		user.Role = string(*update.Role)
+	}
+	if update.WorkspaceID != nil {
+		user.WorkspaceID = *update.WorkspaceID
+	}
+	if update.Disabled != nil {
+		user.Disabled = *update.Disabled
+	}
+	user.TokenVersion++
+
+	if err := s.db.WithContext(ctx).Save(user).Error; err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	return user, nil
+}
+
+// DeleteUser deletes a user. Its tokens stop working with it.
+func (s *Service) DeleteUser(ctx context.Context, id uint) error {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Delete(user).Error; err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	s.logger.WithField("username", user.Username).Info("User deleted")
+	return nil
+}
+
+// Login checks a username and password and issues a token pair.
+func (s *Service) Login(ctx context.Context, username, password string) (*TokenPair, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil || user.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+	return s.issue(&user)
+}
+
+// Refresh exchanges a refresh token for a new token pair.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := ParseToken(s.config.Secret, refreshToken, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != TokenTypeRefresh {
+		return nil, ErrInvalidToken
+	}
+	user, err := s.tokenUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(user)
+}
+
+// RevokeTokens revokes every token issued to a user, signing it out
+// everywhere.
+func (s *Service) RevokeTokens(ctx context.Context, userID uint) error {
+	err := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+	return nil
+}
+
+// Authenticate resolves an access token or a managed API key to the
+// caller's identity. Revoked, expired and unknown credentials yield
+// ErrInvalidCredentials, ErrInvalidToken or ErrTokenExpired.
+func (s *Service) Authenticate(ctx context.Context, credential string) (*Identity, error) {
+	if looksLikeToken(credential) {
+		return s.authenticateToken(ctx, credential)
+	}
+	return s.authenticateAPIKey(ctx, credential)
+}
+
+// CreateAPIKey creates an API key and returns it in plain text; only its
+// hash is stored, so it cannot be shown again. A nil expiresAt never
+// expires.
+func (s *Service) CreateAPIKey(ctx context.Context, name string, role Role, workspaceID string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	if !role.Valid() {
+		return "", nil, ErrInvalidRole
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	plain := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := &models.APIKey{
+		ExpiresAt:   expiresAt,
+		Name:        name,
+		KeyHash:     hashAPIKey(plain),
+		Prefix:      plain[:len(APIKeyPrefix)+6],
+		Role:        string(role),
+		WorkspaceID: workspaceID,
+	}
+	if err := s.db.WithContext(ctx).Create(key).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"api_key_id": key.ID,
+		"name":       name,
+		"role":       role,
+	}).Info("API key created")
+	return plain, key, nil
+}
+
+// GetAPIKey returns an API key.
+func (s *Service) GetAPIKey(ctx context.Context, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	err := s.db.WithContext(ctx).First(&key, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns API keys, newest first. A non-empty workspaceID
+// restricts the result to keys confined to that workspace.
+func (s *Service) ListAPIKeys(ctx context.Context, workspaceID string) ([]models.APIKey, error) {
+	query := s.db.WithContext(ctx)
+	if workspaceID != "" {
+		query = query.Where("workspace_id = ?", workspaceID)
+	}
+	var keys []models.APIKey
+	if err := query.Order("id DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key. Revoking a revoked key is a no-op.
+func (s *Service) RevokeAPIKey(ctx context.Context, id uint) error {
+	key, err := s.GetAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	now := s.now()
+	key.RevokedAt = &now
+	if err := s.db.WithContext(ctx).Save(key).Error; err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	s.logger.WithField("api_key_id", id).Info("API key revoked")
+	return nil
+}
+
+func (s *Service) authenticateToken(ctx context.Context, token string) (*Identity, error) {
+	claims, err := ParseToken(s.config.Secret, token, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != TokenTypeAccess {
+		return nil, ErrInvalidToken
+	}
+	user, err := s.tokenUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	return &Identity{
+		ExpiresAt:   &expiresAt,
+		Subject:     claims.Subject,
+		Name:        user.Username,
+		Role:        Role(user.Role),
+		WorkspaceID: user.WorkspaceID,
+		UserID:      user.ID,
+	}, nil
+}
+
+// tokenUser returns the user a token was issued to, unless the user has
+// been disabled or deleted or the token revoked since.
+func (s *Service) tokenUser(ctx context.Context, claims *Claims) (*models.User, error) {
+	user, err := s.GetUser(ctx, claims.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled || user.TokenVersion != claims.Version {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+func (s *Service) authenticateAPIKey(ctx context.Context, plain string) (*Identity, error) {
+	var key models.APIKey
+	err := s.db.WithContext(ctx).Where("key_hash = ?", hashAPIKey(plain)).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	now := s.now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)) {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Last use is informational; a failed update does not fail the request
+	if err := s.db.WithContext(ctx).Model(&key).UpdateColumn("last_used_at", now).Error; err != nil {
+		s.logger.WithError(err).WithField("api_key_id", key.ID).Warn("Failed to record API key use")
+	}
+	return &Identity{
+		ExpiresAt:   key.ExpiresAt,
+		Subject:     "api_key:" + strconv.FormatUint(uint64(key.ID), 10),
+		Name:        key.Name,
+		Role:        Role(key.Role),
+		WorkspaceID: key.WorkspaceID,
+	}, nil
+}
+
+// issue signs a new access and refresh token for the user.
+func (s *Service) issue(user *models.User) (*TokenPair, error) {
+	now := s.now()
+	pair := &TokenPair{
+		AccessTokenExpiresAt:  now.Add(s.config.AccessTokenTTL),
+		RefreshTokenExpiresAt: now.Add(s.config.RefreshTokenTTL),
+	}
+	claims := &Claims{
+		Subject:     "user:" + strconv.FormatUint(uint64(user.ID), 10),
+		Name:        user.Username,
+		Role:        Role(user.Role),
+		WorkspaceID: user.WorkspaceID,
+		Type:        TokenTypeAccess,
+		ID:          uuid.New().String(),
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   pair.AccessTokenExpiresAt.Unix(),
+		UserID:      user.ID,
+		Version:     user.TokenVersion,
+	}
+	var err error
+	if pair.AccessToken, err = SignToken(s.config.Secret, claims); err != nil {
+		return nil, err
+	}
+
+	claims.Type = TokenTypeRefresh
+	claims.ID = uuid.New().String()
+	claims.ExpiresAt = pair.RefreshTokenExpiresAt.Unix()
+	if pair.RefreshToken, err = SignToken(s.config.Secret, claims); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func hashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,175 @@
+package account
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/models"
+)
+
+func setupService(t *testing.T) *Service {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.APIKey{}))
+
+	cfg := DefaultConfig()
+	cfg.Secret = []byte(strings.Repeat("k", MinSecretLength))
+	svc, err := NewService(db, cfg, nil)
+	require.NoError(t, err)
+	return svc
+}
+
+func TestNewService_RequiresSecret(t *testing.T) {
+	_, err := NewService(nil, &Config{Secret: []byte("short")}, nil)
+	assert.Error(t, err)
+	_, err = NewService(nil, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestService_LoginAndAuthenticate(t *testing.T) {
+	svc := setupService(t)
+	ctx := context.Background()
+
+	user, err := svc.CreateUser(ctx, "alice", "correct horse", RolePilot, "ws1")
+	require.NoError(t, err)
+	assert.NotEqual(t, "correct horse", user.PasswordHash)
+
+	_, err = svc.CreateUser(ctx, "alice", "another password", RoleViewer, "")
+	assert.ErrorIs(t, err, ErrUserExists)
+	_, err = svc.CreateUser(ctx, "bob", "another password", Role("root"), "")
+	assert.ErrorIs(t, err, ErrInvalidRole)
+
+	_, err = svc.Login(ctx, "alice", "wrong")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = svc.Login(ctx, "nobody", "correct horse")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	pair, err := svc.Login(ctx, "alice", "correct horse")
+	require.NoError(t, err)
+
+	identity, err := svc.Authenticate(ctx, pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", identity.Name)
+	assert.Equal(t, RolePilot, identity.Role)
+	assert.Equal(t, "ws1", identity.WorkspaceID)
+	assert.Equal(t, user.ID, identity.UserID)
+	require.NotNil(t, identity.ExpiresAt)
+
+	_, err = svc.Authenticate(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidToken, "refresh tokens are not access tokens")
+
+	t.Run("expired access token", func(t *testing.T) {
+		svc.now = func() time.Time { return time.Now().Add(time.Hour) }
+		defer func() { svc.now = time.Now }()
+		_, err := svc.Authenticate(ctx, pair.AccessToken)
+		assert.ErrorIs(t, err, ErrTokenExpired)
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		refreshed, err := svc.Refresh(ctx, pair.RefreshToken)
+		require.NoError(t, err)
+		_, err = svc.Authenticate(ctx, refreshed.AccessToken)
+		assert.NoError(t, err)
+
+		_, err = svc.Refresh(ctx, pair.AccessToken)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("role change revokes tokens", func(t *testing.T) {
+		role := RoleViewer
+		_, err := svc.UpdateUser(ctx, user.ID, UserUpdate{Role: &role})
+		require.NoError(t, err)
+
+		_, err = svc.Authenticate(ctx, pair.AccessToken)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		_, err = svc.Refresh(ctx, pair.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+		pair, err = svc.Login(ctx, "alice", "correct horse")
+		require.NoError(t, err)
+		identity, err := svc.Authenticate(ctx, pair.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, RoleViewer, identity.Role)
+	})
+
+	t.Run("logout revokes tokens", func(t *testing.T) {
+		require.NoError(t, svc.RevokeTokens(ctx, user.ID))
+		_, err := svc.Authenticate(ctx, pair.AccessToken)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("disabled users cannot sign in", func(t *testing.T) {
+		disabled := true
+		_, err := svc.UpdateUser(ctx, user.ID, UserUpdate{Disabled: &disabled})
+		require.NoError(t, err)
+		_, err = svc.Login(ctx, "alice", "correct horse")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+}
+
+func TestService_EnsureUser(t *testing.T) {
+	svc := setupService(t)
+	ctx := context.Background()
+
+	require.NoError(t, svc.EnsureUser(ctx, "admin", "first password", RoleAdmin))
+	require.NoError(t, svc.EnsureUser(ctx, "admin", "second password", RoleAdmin))
+
+	users, err := svc.ListUsers(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	_, err = svc.Login(ctx, "admin", "first password")
+	assert.NoError(t, err, "an existing user is left untouched")
+}
+
+func TestService_APIKeys(t *testing.T) {
+	svc := setupService(t)
+	ctx := context.Background()
+
+	plain, key, err := svc.CreateAPIKey(ctx, "ci", RoleOperator, "ws1", nil)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(plain, APIKeyPrefix))
+	assert.True(t, strings.HasPrefix(plain, key.Prefix))
+	assert.NotContains(t, key.KeyHash, plain)
+
+	identity, err := svc.Authenticate(ctx, plain)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", identity.Name)
+	assert.Equal(t, RoleOperator, identity.Role)
+	assert.Equal(t, "ws1", identity.WorkspaceID)
+	assert.Zero(t, identity.UserID)
+
+	stored, err := svc.GetAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, stored.LastUsedAt)
+
+	_, err = svc.Authenticate(ctx, plain+"x")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	require.NoError(t, svc.RevokeAPIKey(ctx, key.ID))
+	_, err = svc.Authenticate(ctx, plain)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	expiresAt := time.Now().Add(time.Minute)
+	expiring, _, err := svc.CreateAPIKey(ctx, "temp", RoleViewer, "", &expiresAt)
+	require.NoError(t, err)
+	_, err = svc.Authenticate(ctx, expiring)
+	require.NoError(t, err)
+	svc.now = func() time.Time { return expiresAt }
+	_, err = svc.Authenticate(ctx, expiring)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	keys, err := svc.ListAPIKeys(ctx, "ws1")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "ci", keys[0].Name)
+
+	_, err = svc.GetAPIKey(ctx, 999)
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+}
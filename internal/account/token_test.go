@@ -0,0 +1,71 @@
+package account
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndParseToken(t *testing.T) {
+	secret := []byte(strings.Repeat("s", MinSecretLength))
+	now := time.Unix(1700000000, 0)
+	claims := &Claims{
+		Subject:     "user:1",
+		Name:        "alice",
+		Role:        RolePilot,
+		WorkspaceID: "ws1",
+		Type:        TokenTypeAccess,
+		ID:          "jti",
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   now.Add(time.Minute).Unix(),
+		UserID:      1,
+		Version:     3,
+	}
+
+	token, err := SignToken(secret, claims)
+	require.NoError(t, err)
+	assert.True(t, looksLikeToken(token))
+
+	parsed, err := ParseToken(secret, token, now)
+	require.NoError(t, err)
+	assert.Equal(t, claims, parsed)
+
+	t.Run("expired", func(t *testing.T) {
+		_, err := ParseToken(secret, token, now.Add(time.Minute))
+		assert.ErrorIs(t, err, ErrTokenExpired)
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		_, err := ParseToken([]byte(strings.Repeat("x", MinSecretLength)), token, now)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+		forged, err := SignToken([]byte("attacker"), &Claims{Role: RoleAdmin, ExpiresAt: now.Add(time.Hour).Unix()})
+		require.NoError(t, err)
+		parts[1] = strings.Split(forged, ".")[1]
+		_, err = ParseToken(secret, strings.Join(parts, "."), now)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := ParseToken(secret, "not-a-token", now)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestRolePermissions(t *testing.T) {
+	assert.True(t, RoleViewer.Can(PermissionRead))
+	assert.False(t, RoleViewer.Can(PermissionWrite))
+	assert.True(t, RoleOperator.Can(PermissionServiceCall))
+	assert.False(t, RoleOperator.Can(PermissionFlightControl))
+	assert.True(t, RolePilot.Can(PermissionFlightControl))
+	assert.False(t, RolePilot.Can(PermissionAdmin))
+	assert.True(t, RoleAdmin.Can(PermissionAdmin))
+	assert.False(t, Role("root").Valid())
+	assert.False(t, Role("root").Can(PermissionRead))
+}
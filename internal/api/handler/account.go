@@ -0,0 +1,392 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/account"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// Account handles user and API key management. Administrators confined to
+// a workspace only see and manage the users and keys of that workspace.
+type Account struct {
+	service *account.Service
+	logger  *logrus.Entry
+}
+
+// NewAccount creates a new account handler
+func NewAccount(service *account.Service, logger *logrus.Entry) *Account {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Account{
+		service: service,
+		logger:  logger.WithField("handler", "account"),
+	}
+}
+
+// CreateUserRequest represents the request body for creating a user
+type CreateUserRequest struct {
+	Username    string `json:"username" binding:"required,max=100"`
+	Password    string `json:"password" binding:"required,min=8,max=72"`
+	Role        string `json:"role" binding:"required"`
+	WorkspaceID string `json:"workspace_id,omitempty"`
+}
+
+// UpdateUserRequest represents the request body for updating a user
+type UpdateUserRequest struct {
+	Password    *string `json:"password,omitempty" binding:"omitempty,min=8,max=72"`
+	Role        *string `json:"role,omitempty"`
+	WorkspaceID *string `json:"workspace_id,omitempty"`
+	Disabled    *bool   `json:"disabled,omitempty"`
+}
+
+// UserResponse represents the response for a user
+type UserResponse struct {
+	ID          uint   `json:"id"`
+	Username    string `json:"username"`
+	Role        string `json:"role"`
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	Disabled    bool   `json:"disabled"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// ListUsersResponse represents the response for listing users
+type ListUsersResponse struct {
+	Users []UserResponse `json:"users"`
+}
+
+// CreateAPIKeyRequest represents the request body for creating an API key
+type CreateAPIKeyRequest struct {
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Name        string     `json:"name" binding:"required,max=200"`
+	Role        string     `json:"role" binding:"required"`
+	WorkspaceID string     `json:"workspace_id,omitempty"`
+}
+
+// APIKeyResponse represents the response for an API key
+type APIKeyResponse struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	Prefix      string  `json:"prefix"`
+	Role        string  `json:"role"`
+	WorkspaceID string  `json:"workspace_id,omitempty"`
+	ExpiresAt   *string `json:"expires_at,omitempty"`
+	LastUsedAt  *string `json:"last_used_at,omitempty"`
+	RevokedAt   *string `json:"revoked_at,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+// CreateAPIKeyResponse represents a new API key. Key is only returned once.
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// ListAPIKeysResponse represents the response for listing API keys
+type ListAPIKeysResponse struct {
+	APIKeys []APIKeyResponse `json:"api_keys"`
+}
+
+func toUserResponse(u *models.User) UserResponse {
+	return UserResponse{
+		ID:          u.ID,
+		Username:    u.Username,
+		Role:        u.Role,
+		WorkspaceID: u.WorkspaceID,
+		Disabled:    u.Disabled,
+		CreatedAt:   u.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   u.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func toAPIKeyResponse(k *models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:          k.ID,
+		Name:        k.Name,
+		Prefix:      k.Prefix,
+		Role:        k.Role,
+		WorkspaceID: k.WorkspaceID,
+		ExpiresAt:   formatOptionalTime(k.ExpiresAt),
+		LastUsedAt:  formatOptionalTime(k.LastUsedAt),
+		RevokedAt:   formatOptionalTime(k.RevokedAt),
+		CreatedAt:   k.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// CreateUser creates a user
+// @Summary Create a user
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Param user body CreateUserRequest true "User information"
+// @Success 201 {object} UserResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/users [post]
+func (h *Account) CreateUser(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	var req CreateUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	workspaceID, ok := requireWorkspace(c, req.WorkspaceID)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.CreateUser(c.Request.Context(), req.Username, req.Password, account.Role(req.Role), workspaceID)
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to create user")
+		return
+	}
+	c.JSON(http.StatusCreated, toUserResponse(user))
+}
+
+// ListUsers lists the users visible to the caller
+// @Summary List users
+// @Tags accounts
+// @Produce json
+// @Success 200 {object} ListUsersResponse
+// @Router /api/v1/users [get]
+func (h *Account) ListUsers(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	workspaceID, _ := workspaceScope(c)
+	users, err := h.service.ListUsers(c.Request.Context(), workspaceID)
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to list users", "Failed to list users")
+		return
+	}
+
+	resp := ListUsersResponse{Users: make([]UserResponse, len(users))}
+	for i := range users {
+		resp.Users[i] = toUserResponse(&users[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetUser retrieves a user
+// @Summary Get a user
+// @Tags accounts
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} UserResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/users/{id} [get]
+func (h *Account) GetUser(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+// UpdateUser updates a user and revokes its tokens
+// @Summary Update a user
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body UpdateUserRequest true "Fields to update"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/users/{id} [put]
+func (h *Account) UpdateUser(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+	var req UpdateUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	update := account.UserUpdate{Password: req.Password, Disabled: req.Disabled}
+	if req.Role != nil {
+		role := account.Role(*req.Role)
+		update.Role = &role
+	}
+	if req.WorkspaceID != nil {
+		workspaceID, ok := requireWorkspace(c, *req.WorkspaceID)
+		if !ok {
+			return
+		}
+		update.WorkspaceID = &workspaceID
+	}
+
+	user, err := h.service.UpdateUser(c.Request.Context(), user.ID, update)
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to update user")
+		return
+	}
+	c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+// DeleteUser deletes a user
+// @Summary Delete a user
+// @Tags accounts
+// @Param id path int true "User ID"
+// @Success 204
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/users/{id} [delete]
+func (h *Account) DeleteUser(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteUser(c.Request.Context(), user.ID); err != nil {
+		h.respondServiceError(c, err, "Failed to delete user")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateAPIKey creates an API key
+// @Summary Create an API key
+// @Description The key is only returned in this response
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Param key body CreateAPIKeyRequest true "API key information"
+// @Success 201 {object} CreateAPIKeyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/api-keys [post]
+func (h *Account) CreateAPIKey(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	var req CreateAPIKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		respondBadRequest(c, "INVALID_EXPIRY", "expires_at must be in the future")
+		return
+	}
+	workspaceID, ok := requireWorkspace(c, req.WorkspaceID)
+	if !ok {
+		return
+	}
+
+	plain, key, err := h.service.CreateAPIKey(c.Request.Context(), req.Name, account.Role(req.Role), workspaceID, req.ExpiresAt)
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to create API key")
+		return
+	}
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKeyResponse: toAPIKeyResponse(key), Key: plain})
+}
+
+// ListAPIKeys lists the API keys visible to the caller
+// @Summary List API keys
+// @Tags accounts
+// @Produce json
+// @Success 200 {object} ListAPIKeysResponse
+// @Router /api/v1/api-keys [get]
+func (h *Account) ListAPIKeys(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	workspaceID, _ := workspaceScope(c)
+	keys, err := h.service.ListAPIKeys(c.Request.Context(), workspaceID)
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to list API keys", "Failed to list API keys")
+		return
+	}
+
+	resp := ListAPIKeysResponse{APIKeys: make([]APIKeyResponse, len(keys))}
+	for i := range keys {
+		resp.APIKeys[i] = toAPIKeyResponse(&keys[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RevokeAPIKey revokes an API key
+// @Summary Revoke an API key
+// @Tags accounts
+// @Param id path int true "API key ID"
+// @Success 204
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/api-keys/{id} [delete]
+func (h *Account) RevokeAPIKey(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	id, ok := parseUintID(c, "id")
+	if !ok {
+		return
+	}
+	key, err := h.service.GetAPIKey(c.Request.Context(), uint(id))
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to get API key")
+		return
+	}
+	if !inCallerWorkspace(c, key.WorkspaceID) {
+		respondNotFound(c, "API_KEY_NOT_FOUND", "API key not found")
+		return
+	}
+
+	if err := h.service.RevokeAPIKey(c.Request.Context(), key.ID); err != nil {
+		h.respondServiceError(c, err, "Failed to revoke API key")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// requireUser returns the :id user when the service is available and the
+// user is visible to the caller.
+func (h *Account) requireUser(c *gin.Context) (*models.User, bool) {
+	if !h.requireService(c) {
+		return nil, false
+	}
+	id, ok := parseUintID(c, "id")
+	if !ok {
+		return nil, false
+	}
+	user, err := h.service.GetUser(c.Request.Context(), uint(id))
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to get user")
+		return nil, false
+	}
+	if !inCallerWorkspace(c, user.WorkspaceID) {
+		respondNotFound(c, "USER_NOT_FOUND", "User not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// requireService writes a 503 response when accounts are not configured.
+func (h *Account) requireService(c *gin.Context) bool {
+	if h.service == nil {
+		respondServiceUnavailable(c, "Account service not available")
+		return false
+	}
+	return true
+}
+
+func (h *Account) respondServiceError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, account.ErrUserNotFound):
+		respondNotFound(c, "USER_NOT_FOUND", "User not found")
+	case errors.Is(err, account.ErrUserExists):
+		respondError(c, http.StatusConflict, "USER_EXISTS", "User with this username already exists")
+	case errors.Is(err, account.ErrInvalidRole):
+		respondBadRequest(c, "INVALID_ROLE", "Role must be one of viewer, operator, pilot, admin")
+	case errors.Is(err, account.ErrAPIKeyNotFound):
+		respondNotFound(c, "API_KEY_NOT_FOUND", "API key not found")
+	default:
+		respondInternalError(c, h.logger, err, msg, msg)
+	}
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/account"
+	"github.com/utmos/utmos/internal/api/middleware"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+func setupAccountRouter(t *testing.T) (*gin.Engine, *account.Service) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.APIKey{}))
+	cfg := account.DefaultConfig()
+	cfg.Secret = []byte(strings.Repeat("s", account.MinSecretLength))
+	svc, err := account.NewService(db, cfg, nil)
+	require.NoError(t, err)
+
+	h := NewAccount(svc, nil)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if workspaceID := c.GetHeader(scopeHeader); workspaceID != "" {
+			middleware.SetWorkspaceScope(c, workspaceID)
+		}
+		c.Next()
+	})
+	router.POST("/api/v1/users", h.CreateUser)
+	router.GET("/api/v1/users", h.ListUsers)
+	router.GET("/api/v1/users/:id", h.GetUser)
+	router.PUT("/api/v1/users/:id", h.UpdateUser)
+	router.DELETE("/api/v1/users/:id", h.DeleteUser)
+	router.POST("/api/v1/api-keys", h.CreateAPIKey)
+	router.GET("/api/v1/api-keys", h.ListAPIKeys)
+	router.DELETE("/api/v1/api-keys/:id", h.RevokeAPIKey)
+	return router, svc
+}
+
+func TestAccount_Users(t *testing.T) {
+	router, svc := setupAccountRouter(t)
+	other, err := svc.CreateUser(context.Background(), "other", "password-other", account.RoleViewer, "ws2")
+	require.NoError(t, err)
+
+	w := doWorkspaceRequest(t, router, http.MethodPost, "/api/v1/users", "ws1", CreateUserRequest{
+		Username: "pilot", Password: "password-pilot", Role: "pilot",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created UserResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "ws1", created.WorkspaceID, "scoped admins create users in their workspace")
+
+	w = doWorkspaceRequest(t, router, http.MethodPost, "/api/v1/users", "ws1", CreateUserRequest{
+		Username: "intruder", Password: "password-intruder", Role: "admin", WorkspaceID: "ws2",
+	})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doWorkspaceRequest(t, router, http.MethodPost, "/api/v1/users", "", CreateUserRequest{
+		Username: "root", Password: "password-root", Role: "root",
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doWorkspaceRequest(t, router, http.MethodPost, "/api/v1/users", "", CreateUserRequest{
+		Username: "pilot", Password: "password-pilot", Role: "pilot",
+	})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/users", "ws1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListUsersResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Users, 1)
+	assert.Equal(t, "pilot", list.Users[0].Username)
+
+	otherPath := "/api/v1/users/" + strconv.FormatUint(uint64(other.ID), 10)
+	assert.Equal(t, http.StatusNotFound, doWorkspaceRequest(t, router, http.MethodGet, otherPath, "ws1", nil).Code)
+	assert.Equal(t, http.StatusNotFound, doWorkspaceRequest(t, router, http.MethodDelete, otherPath, "ws1", nil).Code)
+
+	disabled := true
+	w = doWorkspaceRequest(t, router, http.MethodPut, otherPath, "", UpdateUserRequest{Disabled: &disabled})
+	require.Equal(t, http.StatusOK, w.Code)
+	var updated UserResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.True(t, updated.Disabled)
+
+	assert.Equal(t, http.StatusNoContent, doWorkspaceRequest(t, router, http.MethodDelete, otherPath, "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, doWorkspaceRequest(t, router, http.MethodGet, otherPath, "", nil).Code)
+}
+
+func TestAccount_APIKeys(t *testing.T) {
+	router, _ := setupAccountRouter(t)
+
+	w := doWorkspaceRequest(t, router, http.MethodPost, "/api/v1/api-keys", "ws1", CreateAPIKeyRequest{
+		Name: "ci", Role: "operator",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created CreateAPIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.True(t, strings.HasPrefix(created.Key, account.APIKeyPrefix))
+	assert.Equal(t, "ws1", created.WorkspaceID)
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/api-keys", "ws2", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListAPIKeysResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Empty(t, list.APIKeys)
+	assert.NotContains(t, w.Body.String(), created.Key)
+
+	path := "/api/v1/api-keys/" + strconv.FormatUint(uint64(created.ID), 10)
+	assert.Equal(t, http.StatusNotFound, doWorkspaceRequest(t, router, http.MethodDelete, path, "ws2", nil).Code)
+	assert.Equal(t, http.StatusNoContent, doWorkspaceRequest(t, router, http.MethodDelete, path, "ws1", nil).Code)
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/api-keys", "ws1", nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.APIKeys, 1)
+	assert.NotNil(t, list.APIKeys[0].RevokedAt)
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/account"
+	"github.com/utmos/utmos/internal/api/middleware"
+)
+
+// Auth handles sign-in and token requests
+type Auth struct {
+	service *account.Service
+	logger  *logrus.Entry
+}
+
+// NewAuth creates a new auth handler
+func NewAuth(service *account.Service, logger *logrus.Entry) *Auth {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Auth{
+		service: service,
+		logger:  logger.WithField("handler", "auth"),
+	}
+}
+
+// LoginRequest represents the request body for signing in
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// RefreshRequest represents the request body for refreshing tokens
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenResponse represents an issued token pair
+type TokenResponse struct {
+	AccessToken           string `json:"access_token"`
+	RefreshToken          string `json:"refresh_token"`
+	TokenType             string `json:"token_type"`
+	ExpiresIn             int64  `json:"expires_in"`
+	RefreshTokenExpiresIn int64  `json:"refresh_token_expires_in"`
+}
+
+// IdentityResponse represents the authenticated caller
+type IdentityResponse struct {
+	Subject     string   `json:"subject"`
+	Name        string   `json:"name"`
+	Role        string   `json:"role"`
+	WorkspaceID string   `json:"workspace_id,omitempty"`
+	Permissions []string `json:"permissions"`
+	ExpiresAt   *string  `json:"expires_at,omitempty"`
+}
+
+func toTokenResponse(pair *account.TokenPair) TokenResponse {
+	now := time.Now()
+	return TokenResponse{
+		AccessToken:           pair.AccessToken,
+		RefreshToken:          pair.RefreshToken,
+		TokenType:             "Bearer",
+		ExpiresIn:             int64(pair.AccessTokenExpiresAt.Sub(now).Seconds()),
+		RefreshTokenExpiresIn: int64(pair.RefreshTokenExpiresAt.Sub(now).Seconds()),
+	}
+}
+
+// Login signs a user in
+// @Summary Sign in
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body LoginRequest true "Username and password"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/auth/login [post]
+func (h *Auth) Login(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	var req LoginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	pair, err := h.service.Login(c.Request.Context(), req.Username, req.Password)
+	if errors.Is(err, account.ErrInvalidCredentials) {
+		h.logger.WithField("username", req.Username).Warn("Failed sign-in")
+		respondError(c, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid username or password")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to sign in", "Failed to sign in")
+		return
+	}
+	c.JSON(http.StatusOK, toTokenResponse(pair))
+}
+
+// Refresh exchanges a refresh token for a new token pair
+// @Summary Refresh tokens
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body RefreshRequest true "Refresh token"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/auth/refresh [post]
+func (h *Auth) Refresh(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	var req RefreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	pair, err := h.service.Refresh(c.Request.Context(), req.RefreshToken)
+	switch {
+	case errors.Is(err, account.ErrInvalidToken), errors.Is(err, account.ErrTokenExpired), errors.Is(err, account.ErrInvalidCredentials):
+		respondError(c, http.StatusUnauthorized, "INVALID_REFRESH_TOKEN", "Refresh token is invalid or expired")
+	case err != nil:
+		respondInternalError(c, h.logger, err, "Failed to refresh tokens", "Failed to refresh tokens")
+	default:
+		c.JSON(http.StatusOK, toTokenResponse(pair))
+	}
+}
+
+// Logout revokes every token issued to the signed-in user
+// @Summary Sign out everywhere
+// @Tags auth
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Router /api/v1/auth/logout [post]
+func (h *Auth) Logout(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	identity, ok := middleware.GetIdentity(c)
+	if !ok || identity.UserID == 0 {
+		respondBadRequest(c, "NOT_SIGNED_IN", "Only signed-in users can sign out")
+		return
+	}
+
+	if err := h.service.RevokeTokens(c.Request.Context(), identity.UserID); err != nil {
+		respondInternalError(c, h.logger, err, "Failed to sign out", "Failed to sign out")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Me returns the authenticated caller
+// @Summary Get the authenticated caller
+// @Tags auth
+// @Produce json
+// @Success 200 {object} IdentityResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/auth/me [get]
+func (h *Auth) Me(c *gin.Context) {
+	identity, ok := middleware.GetIdentity(c)
+	if !ok {
+		respondNotFound(c, "NOT_AUTHENTICATED", "Authentication is disabled")
+		return
+	}
+
+	resp := IdentityResponse{
+		Subject:     identity.Subject,
+		Name:        identity.Name,
+		Role:        string(identity.Role),
+		WorkspaceID: identity.WorkspaceID,
+		Permissions: make([]string, 0),
+	}
+	for _, p := range identity.Role.Permissions() {
+		resp.Permissions = append(resp.Permissions, string(p))
+	}
+	if identity.ExpiresAt != nil {
+		t := identity.ExpiresAt.Format(time.RFC3339)
+		resp.ExpiresAt = &t
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// requireService writes a 503 response when accounts are not configured.
+func (h *Auth) requireService(c *gin.Context) bool {
+	if h.service == nil {
+		respondServiceUnavailable(c, "Account service not available")
+		return false
+	}
+	return true
+}
@@ -34,6 +34,7 @@ type CreateDeviceRequest struct {
 	DeviceName   string  `json:"device_name" binding:"required"`
 	DeviceType   string  `json:"device_type" binding:"required"`
 	Vendor       string  `json:"vendor"`
+	WorkspaceID  string  `json:"workspace_id,omitempty"`
 	GatewaySN    *string `json:"gateway_sn,omitempty"`
 	ThingModelID *uint   `json:"thing_model_id,omitempty"`
 }
@@ -44,6 +45,7 @@ type UpdateDeviceRequest struct {
 	DeviceType   *string              `json:"device_type,omitempty"`
 	Vendor       *string              `json:"vendor,omitempty"`
 	Status       *models.DeviceStatus `json:"status,omitempty"`
+	WorkspaceID  *string              `json:"workspace_id,omitempty"`
 	GatewaySN    *string              `json:"gateway_sn,omitempty"`
 	ThingModelID *uint                `json:"thing_model_id,omitempty"`
 }
@@ -56,6 +58,7 @@ type DeviceResponse struct {
 	DeviceType     string              `json:"device_type"`
 	Vendor         string              `json:"vendor"`
 	Status         models.DeviceStatus `json:"status"`
+	WorkspaceID    string              `json:"workspace_id,omitempty"`
 	GatewaySN      *string             `json:"gateway_sn,omitempty"`
 	ThingModelID   *uint               `json:"thing_model_id,omitempty"`
 	LastOnlineTime *string             `json:"last_online_time,omitempty"`
@@ -81,6 +84,7 @@ func toDeviceResponse(device *models.Device) DeviceResponse {
 		DeviceType:   device.DeviceType,
 		Vendor:       device.Vendor,
 		Status:       device.Status,
+		WorkspaceID:  device.WorkspaceID,
 		GatewaySN:    device.GatewaySN,
 		ThingModelID: device.ThingModelID,
 		CreatedAt:    device.CreatedAt.Format("2006-01-02T15:04:05Z"),
@@ -110,12 +114,17 @@ func (h *Device) Create(c *gin.Context) {
 	if !bindJSON(c, &req) {
 		return
 	}
+	workspaceID, ok := requireWorkspace(c, req.WorkspaceID)
+	if !ok {
+		return
+	}
 
 	device := &models.Device{
 		DeviceSN:     req.DeviceSN,
 		DeviceName:   req.DeviceName,
 		DeviceType:   req.DeviceType,
 		Vendor:       req.Vendor,
+		WorkspaceID:  workspaceID,
 		GatewaySN:    req.GatewaySN,
 		ThingModelID: req.ThingModelID,
 		Status:       models.DeviceStatusUnknown,
@@ -155,7 +164,7 @@ func (h *Device) Get(c *gin.Context) {
 	}
 
 	var device models.Device
-	if handleDBLookupError(c, h.logger, h.db.First(&device, id).Error,
+	if handleDBLookupError(c, h.logger, scopeWorkspace(c, h.db).First(&device, id).Error,
 		"DEVICE_NOT_FOUND", "Device not found",
 		"Failed to get device", "Failed to get device") {
 		return
@@ -181,7 +190,7 @@ func (h *Device) GetBySN(c *gin.Context) {
 	}
 
 	var device models.Device
-	if handleDBLookupError(c, h.logger, h.db.Where("device_sn = ?", sn).First(&device).Error,
+	if handleDBLookupError(c, h.logger, scopeWorkspace(c, h.db).Where("device_sn = ?", sn).First(&device).Error,
 		"DEVICE_NOT_FOUND", "Device not found",
 		"Failed to get device", "Failed to get device") {
 		return
@@ -200,13 +209,14 @@ func (h *Device) GetBySN(c *gin.Context) {
 // @Param vendor query string false "Filter by vendor"
 // @Param status query string false "Filter by status"
 // @Param device_type query string false "Filter by device type"
+// @Param workspace_id query string false "Filter by workspace"
 // @Success 200 {object} ListDevicesResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/devices [get]
 func (h *Device) List(c *gin.Context) {
 	page, pageSize, offset := parsePagination(c, 20, 100)
 
-	query := h.db.Model(&models.Device{})
+	query := scopeWorkspace(c, h.db.Model(&models.Device{}))
 
 	// Apply filters
 	if vendor := c.Query("vendor"); vendor != "" {
@@ -218,6 +228,9 @@ func (h *Device) List(c *gin.Context) {
 	if deviceType := c.Query("device_type"); deviceType != "" {
 		query = query.Where("device_type = ?", deviceType)
 	}
+	if workspaceID := c.Query("workspace_id"); workspaceID != "" {
+		query = query.Where("workspace_id = ?", workspaceID)
+	}
 
 	// Count total
 	var total int64
@@ -273,7 +286,7 @@ func (h *Device) Update(c *gin.Context) {
 	}
 
 	var device models.Device
-	if handleDBLookupError(c, h.logger, h.db.First(&device, id).Error,
+	if handleDBLookupError(c, h.logger, scopeWorkspace(c, h.db).First(&device, id).Error,
 		"DEVICE_NOT_FOUND", "Device not found",
 		"Failed to get device", "Failed to update device") {
 		return
@@ -292,6 +305,13 @@ func (h *Device) Update(c *gin.Context) {
 	if req.Status != nil {
 		device.Status = *req.Status
 	}
+	if req.WorkspaceID != nil {
+		workspaceID, ok := requireWorkspace(c, *req.WorkspaceID)
+		if !ok {
+			return
+		}
+		device.WorkspaceID = workspaceID
+	}
 	if req.GatewaySN != nil {
 		device.GatewaySN = req.GatewaySN
 	}
@@ -326,7 +346,7 @@ func (h *Device) Delete(c *gin.Context) {
 		return
 	}
 
-	result := h.db.Delete(&models.Device{}, id)
+	result := scopeWorkspace(c, h.db).Delete(&models.Device{}, id)
 	if result.Error != nil {
 		respondInternalError(c, h.logger, result.Error, "Failed to delete device", "Failed to delete device")
 		return
@@ -214,7 +214,7 @@ func TestDevice_List(t *testing.T) {
 
 	// Create multiple devices
 	devices := []models.Device{
-		{DeviceSN: "DEVICE001", DeviceName: "Device 1", DeviceType: "drone", Vendor: "dji", Status: models.DeviceStatusOnline},
+		{DeviceSN: "DEVICE001", DeviceName: "Device 1", DeviceType: "drone", Vendor: "dji", Status: models.DeviceStatusOnline, WorkspaceID: "ws1"},
 		{DeviceSN: "DEVICE002", DeviceName: "Device 2", DeviceType: "drone", Vendor: "dji", Status: models.DeviceStatusOffline},
 		{DeviceSN: "DEVICE003", DeviceName: "Device 3", DeviceType: "sensor", Vendor: "generic", Status: models.DeviceStatusOnline},
 	}
@@ -262,6 +262,20 @@ func TestDevice_List(t *testing.T) {
 		assert.Equal(t, int64(2), resp.Total)
 	})
 
+	t.Run("filter by workspace", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v1/devices?workspace_id=ws1", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp ListDevicesResponse
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		require.NoError(t, err)
+		require.Len(t, resp.Devices, 1)
+		assert.Equal(t, "ws1", resp.Devices[0].WorkspaceID)
+	})
+
 	t.Run("pagination", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest("GET", "/api/v1/devices?page=1&page_size=2", nil)
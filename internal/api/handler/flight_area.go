@@ -0,0 +1,344 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/flightarea"
+	"github.com/utmos/utmos/pkg/adapter/dji/wpml"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// FlightArea handles the custom flight areas (no-fly zones and geofences)
+// of a workspace. Changes are pushed to the workspace's docks by the flight
+// area service.
+type FlightArea struct {
+	service *flightarea.Service
+	logger  *logrus.Entry
+}
+
+// NewFlightArea creates a new flight area handler
+func NewFlightArea(service *flightarea.Service, logger *logrus.Entry) *FlightArea {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &FlightArea{
+		service: service,
+		logger:  logger.WithField("handler", "flight_area"),
+	}
+}
+
+// CreateFlightAreaRequest represents the request body for creating a flight
+// area. Circles are Point geometries with a radius in meters.
+type CreateFlightAreaRequest struct {
+	Name     string              `json:"name" binding:"required"`
+	Type     string              `json:"type" binding:"required,oneof=nfz dfence"`
+	Geometry flightarea.Geometry `json:"geometry"`
+	Radius   float64             `json:"radius,omitempty"`
+	Enable   *bool               `json:"enable,omitempty"`
+}
+
+// UpdateFlightAreaRequest represents the request body for updating a flight area
+type UpdateFlightAreaRequest struct {
+	Name     *string              `json:"name,omitempty"`
+	Type     *string              `json:"type,omitempty" binding:"omitempty,oneof=nfz dfence"`
+	Geometry *flightarea.Geometry `json:"geometry,omitempty"`
+	Radius   *float64             `json:"radius,omitempty"`
+	Enable   *bool                `json:"enable,omitempty"`
+}
+
+// FlightAreaResponse represents a flight area
+type FlightAreaResponse struct {
+	ID          string          `json:"id"`
+	WorkspaceID string          `json:"workspace_id"`
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Geometry    json.RawMessage `json:"geometry"`
+	Radius      float64         `json:"radius,omitempty"`
+	Enable      bool            `json:"enable"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+}
+
+// ListFlightAreasResponse represents the flight areas of a workspace
+type ListFlightAreasResponse struct {
+	Areas []FlightAreaResponse `json:"areas"`
+}
+
+// FlightAreaFileResponse represents the flight area file served to docks
+type FlightAreaFileResponse struct {
+	Name      string `json:"name"`
+	Checksum  string `json:"checksum"`
+	Size      int64  `json:"size"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// FlightAreaSyncResponse represents the flight area file of a workspace and
+// the synchronization status of its docks
+type FlightAreaSyncResponse struct {
+	File  *FlightAreaFileResponse `json:"file,omitempty"`
+	Docks []flightarea.DockSync   `json:"docks"`
+}
+
+func toFlightAreaResponse(a *models.FlightArea) FlightAreaResponse {
+	return FlightAreaResponse{
+		ID:          a.ID,
+		WorkspaceID: a.WorkspaceID,
+		Name:        a.Name,
+		Type:        a.Type,
+		Geometry:    json.RawMessage(a.Geometry),
+		Radius:      a.Radius,
+		Enable:      a.Enable,
+		CreatedAt:   a.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   a.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// List lists the flight areas of a workspace
+// @Summary List flight areas
+// @Tags flight-areas
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Success 200 {object} ListFlightAreasResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/flight-areas [get]
+func (h *FlightArea) List(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+
+	areas, err := h.service.List(c.Request.Context(), workspaceID)
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to list flight areas", "Failed to list flight areas")
+		return
+	}
+	resp := ListFlightAreasResponse{Areas: make([]FlightAreaResponse, len(areas))}
+	for i := range areas {
+		resp.Areas[i] = toFlightAreaResponse(&areas[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Create creates a flight area and pushes it to the workspace's docks
+// @Summary Create a flight area
+// @Tags flight-areas
+// @Accept json
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Param area body CreateFlightAreaRequest true "Flight area"
+// @Success 201 {object} FlightAreaResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/flight-areas [post]
+func (h *FlightArea) Create(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+	var req CreateFlightAreaRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	area := &flightarea.Area{
+		Name:     req.Name,
+		Type:     wpml.GeofenceType(req.Type),
+		Geometry: req.Geometry,
+		Radius:   req.Radius,
+		Enable:   req.Enable == nil || *req.Enable,
+	}
+	created, err := h.service.Create(c.Request.Context(), workspaceID, area)
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to create flight area")
+		return
+	}
+	c.JSON(http.StatusCreated, toFlightAreaResponse(created))
+}
+
+// Get retrieves a flight area
+// @Summary Get a flight area
+// @Tags flight-areas
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Param area_id path string true "Flight area ID"
+// @Success 200 {object} FlightAreaResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/flight-areas/{area_id} [get]
+func (h *FlightArea) Get(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+
+	area, err := h.service.Get(c.Request.Context(), workspaceID, c.Param("area_id"))
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to get flight area")
+		return
+	}
+	c.JSON(http.StatusOK, toFlightAreaResponse(area))
+}
+
+// Update updates a flight area and pushes it to the workspace's docks
+// @Summary Update a flight area
+// @Tags flight-areas
+// @Accept json
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Param area_id path string true "Flight area ID"
+// @Param area body UpdateFlightAreaRequest true "Fields to update"
+// @Success 200 {object} FlightAreaResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/flight-areas/{area_id} [put]
+func (h *FlightArea) Update(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+	var req UpdateFlightAreaRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	update := &flightarea.AreaUpdate{
+		Name:     req.Name,
+		Geometry: req.Geometry,
+		Radius:   req.Radius,
+		Enable:   req.Enable,
+	}
+	if req.Type != nil {
+		areaType := wpml.GeofenceType(*req.Type)
+		update.Type = &areaType
+	}
+	area, err := h.service.Update(c.Request.Context(), workspaceID, c.Param("area_id"), update)
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to update flight area")
+		return
+	}
+	c.JSON(http.StatusOK, toFlightAreaResponse(area))
+}
+
+// Delete deletes a flight area and pushes the change to the workspace's docks
+// @Summary Delete a flight area
+// @Tags flight-areas
+// @Param id path string true "Workspace ID"
+// @Param area_id path string true "Flight area ID"
+// @Success 204
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/flight-areas/{area_id} [delete]
+func (h *FlightArea) Delete(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), workspaceID, c.Param("area_id")); err != nil {
+		h.respondServiceError(c, err, "Failed to delete flight area")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SyncStatus reports the flight area file of a workspace and the
+// synchronization status of its docks
+// @Summary Get flight area synchronization status
+// @Tags flight-areas
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Success 200 {object} FlightAreaSyncResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/flight-area-sync [get]
+func (h *FlightArea) SyncStatus(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.service.File(c.Request.Context(), workspaceID)
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to get flight area file", "Failed to get synchronization status")
+		return
+	}
+	docks, err := h.service.SyncStatus(c.Request.Context(), workspaceID)
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to get flight area sync status", "Failed to get synchronization status")
+		return
+	}
+	c.JSON(http.StatusOK, toFlightAreaSyncResponse(file, docks))
+}
+
+// Sync regenerates the flight area file of a workspace and tells its docks
+// to synchronize it again
+// @Summary Synchronize flight areas to docks
+// @Tags flight-areas
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Success 200 {object} FlightAreaSyncResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/flight-area-sync [post]
+func (h *FlightArea) Sync(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.service.Publish(c.Request.Context(), workspaceID)
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to publish flight areas", "Failed to publish flight areas")
+		return
+	}
+	docks, err := h.service.SyncStatus(c.Request.Context(), workspaceID)
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to get flight area sync status", "Failed to get synchronization status")
+		return
+	}
+	c.JSON(http.StatusOK, toFlightAreaSyncResponse(file, docks))
+}
+
+func toFlightAreaSyncResponse(file *models.FlightAreaFile, docks []flightarea.DockSync) FlightAreaSyncResponse {
+	resp := FlightAreaSyncResponse{Docks: docks}
+	if file != nil {
+		resp.File = &FlightAreaFileResponse{
+			Name:      file.Name,
+			Checksum:  file.Checksum,
+			Size:      file.Size,
+			UpdatedAt: file.UpdatedAt.Format(time.RFC3339),
+		}
+	}
+	return resp
+}
+
+// requireAccess returns the :id workspace when the service is available and
+// the caller may access it.
+func (h *FlightArea) requireAccess(c *gin.Context) (string, bool) {
+	if h.service == nil {
+		respondServiceUnavailable(c, "Flight area service not available")
+		return "", false
+	}
+	id, ok := requireStringParam(c, "id", "INVALID_ID", "Workspace ID is required")
+	if !ok {
+		return "", false
+	}
+	return requireWorkspace(c, id)
+}
+
+func (h *FlightArea) respondServiceError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, flightarea.ErrInvalidArea):
+		respondBadRequest(c, "INVALID_FLIGHT_AREA", err.Error())
+	case errors.Is(err, flightarea.ErrAreaNotFound):
+		respondNotFound(c, "FLIGHT_AREA_NOT_FOUND", "Flight area not found")
+	default:
+		respondInternalError(c, h.logger, err, msg, msg)
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/api/middleware"
+	"github.com/utmos/utmos/internal/flightarea"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+func setupFlightAreaRouter(t *testing.T) *gin.Engine {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Device{}, &models.FlightArea{}, &models.FlightAreaFile{}, &models.RetiredFlightAreaFile{}, &models.FlightAreaSync{}))
+	require.NoError(t, db.Create(&models.Device{DeviceSN: "DOCK1", DeviceName: "Dock", DeviceType: "dock", WorkspaceID: "ws1"}).Error)
+
+	store, err := objectstore.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	h := NewFlightArea(flightarea.NewService(db, store, nil, nil), nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if workspaceID := c.GetHeader(scopeHeader); workspaceID != "" {
+			middleware.SetWorkspaceScope(c, workspaceID)
+		}
+		c.Next()
+	})
+	router.GET("/api/v1/workspaces/:id/flight-areas", h.List)
+	router.POST("/api/v1/workspaces/:id/flight-areas", h.Create)
+	router.GET("/api/v1/workspaces/:id/flight-areas/:area_id", h.Get)
+	router.PUT("/api/v1/workspaces/:id/flight-areas/:area_id", h.Update)
+	router.DELETE("/api/v1/workspaces/:id/flight-areas/:area_id", h.Delete)
+	router.GET("/api/v1/workspaces/:id/flight-area-sync", h.SyncStatus)
+	router.POST("/api/v1/workspaces/:id/flight-area-sync", h.Sync)
+	return router
+}
+
+func TestFlightArea_Lifecycle(t *testing.T) {
+	router := setupFlightAreaRouter(t)
+
+	circle := CreateFlightAreaRequest{
+		Name:     "Tower",
+		Type:     "nfz",
+		Geometry: flightarea.Geometry{Type: flightarea.GeometryPoint, Coordinates: json.RawMessage(`[113.94,22.57]`)},
+		Radius:   50,
+	}
+	w := doWorkspaceRequest(t, router, http.MethodPost, "/api/v1/workspaces/ws1/flight-areas", "", circle)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created FlightAreaResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "ws1", created.WorkspaceID)
+	assert.True(t, created.Enable)
+	assert.InDelta(t, 50, created.Radius, 0)
+
+	areaPath := "/api/v1/workspaces/ws1/flight-areas/" + created.ID
+	disabled := false
+	w = doWorkspaceRequest(t, router, http.MethodPut, areaPath, "", UpdateFlightAreaRequest{Enable: &disabled})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated FlightAreaResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.False(t, updated.Enable)
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/workspaces/ws1/flight-area-sync", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status FlightAreaSyncResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.NotNil(t, status.File)
+	assert.Len(t, status.File.Checksum, 64)
+	require.Len(t, status.Docks, 1)
+	assert.Equal(t, "DOCK1", status.Docks[0].DeviceSN)
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/workspaces/ws1/flight-areas", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListFlightAreasResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Areas, 1)
+
+	w = doWorkspaceRequest(t, router, http.MethodDelete, areaPath, "", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = doWorkspaceRequest(t, router, http.MethodGet, areaPath, "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFlightArea_Errors(t *testing.T) {
+	router := setupFlightAreaRouter(t)
+
+	open := CreateFlightAreaRequest{
+		Name:     "Open ring",
+		Type:     "dfence",
+		Geometry: flightarea.Geometry{Type: flightarea.GeometryPolygon, Coordinates: json.RawMessage(`[[[0,0],[1,0],[1,1],[0,1]]]`)},
+	}
+	w := doWorkspaceRequest(t, router, http.MethodPost, "/api/v1/workspaces/ws1/flight-areas", "", open)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "INVALID_FLIGHT_AREA")
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/workspaces/ws1/flight-areas", "ws2", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	h := NewFlightArea(nil, nil)
+	unavailable := gin.New()
+	unavailable.GET("/api/v1/workspaces/:id/flight-areas", h.List)
+	w = doWorkspaceRequest(t, unavailable, http.MethodGet, "/api/v1/workspaces/ws1/flight-areas", "", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
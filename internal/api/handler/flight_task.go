@@ -0,0 +1,728 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/scheduler"
+	"github.com/utmos/utmos/internal/wayline"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// FlightTask handles scheduled flight task API requests
+type FlightTask struct {
+	db        *gorm.DB
+	scheduler *scheduler.Scheduler
+	logger    *logrus.Entry
+}
+
+// NewFlightTask creates a new flight task handler
+func NewFlightTask(db *gorm.DB, sched *scheduler.Scheduler, logger *logrus.Entry) *FlightTask {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	if sched == nil {
+		sched = scheduler.NewScheduler(nil, db, nil, nil, nil, logger)
+	}
+	return &FlightTask{
+		db:        db,
+		scheduler: sched,
+		logger:    logger.WithField("handler", "flight_task"),
+	}
+}
+
+// CreateFlightTaskRequest represents the request body for creating a flight task.
+// Either CronExpr (recurring) or ExecuteTime (one-shot) must be set. Tasks
+// flying a stored wayline set WaylineID, and WaylineRevision unless they fly
+// its latest revision; the fingerprint is then taken from that revision and
+// its archive is signed when each occurrence is dispatched. Other tasks set
+// WaylineURL.
+type CreateFlightTaskRequest struct {
+	Name               string     `json:"name" binding:"required"`
+	DockSN             string     `json:"dock_sn" binding:"required"`
+	Vendor             string     `json:"vendor"`
+	CronExpr           string     `json:"cron_expr,omitempty"`
+	ExecuteTime        *time.Time `json:"execute_time,omitempty"`
+	Timezone           string     `json:"timezone,omitempty"`
+	WaylineURL         string     `json:"wayline_url"`
+	WaylineFingerprint string     `json:"wayline_fingerprint"`
+	WaylineID          *uint      `json:"wayline_id,omitempty"`
+	WaylineRevision    *int       `json:"wayline_revision,omitempty"`
+	TaskType           *int       `json:"task_type,omitempty"`
+	OutOfControlAction int        `json:"out_of_control_action"`
+	RthAltitude        *int       `json:"rth_altitude,omitempty"`
+	BatteryCapacity    *int       `json:"battery_capacity,omitempty"`
+	DurationSeconds    int        `json:"duration_seconds"`
+	Enabled            *bool      `json:"enabled,omitempty"`
+}
+
+// UpdateFlightTaskRequest represents the request body for updating a flight task
+type UpdateFlightTaskRequest struct {
+	Name               *string    `json:"name,omitempty"`
+	CronExpr           *string    `json:"cron_expr,omitempty"`
+	ExecuteTime        *time.Time `json:"execute_time,omitempty"`
+	Timezone           *string    `json:"timezone,omitempty"`
+	WaylineURL         *string    `json:"wayline_url,omitempty"`
+	WaylineFingerprint *string    `json:"wayline_fingerprint,omitempty"`
+	WaylineID          *uint      `json:"wayline_id,omitempty"`
+	WaylineRevision    *int       `json:"wayline_revision,omitempty"`
+	TaskType           *int       `json:"task_type,omitempty"`
+	OutOfControlAction *int       `json:"out_of_control_action,omitempty"`
+	RthAltitude        *int       `json:"rth_altitude,omitempty"`
+	BatteryCapacity    *int       `json:"battery_capacity,omitempty"`
+	DurationSeconds    *int       `json:"duration_seconds,omitempty"`
+	Enabled            *bool      `json:"enabled,omitempty"`
+}
+
+// FlightTaskResponse represents the response for a flight task
+type FlightTaskResponse struct {
+	ID                 uint    `json:"id"`
+	Name               string  `json:"name"`
+	DockSN             string  `json:"dock_sn"`
+	WorkspaceID        string  `json:"workspace_id,omitempty"`
+	Vendor             string  `json:"vendor"`
+	CronExpr           string  `json:"cron_expr,omitempty"`
+	ExecuteTime        *string `json:"execute_time,omitempty"`
+	Timezone           string  `json:"timezone"`
+	WaylineURL         string  `json:"wayline_url"`
+	WaylineFingerprint string  `json:"wayline_fingerprint"`
+	WaylineID          *uint   `json:"wayline_id,omitempty"`
+	WaylineRevision    *int    `json:"wayline_revision,omitempty"`
+	TaskType           int     `json:"task_type"`
+	OutOfControlAction int     `json:"out_of_control_action"`
+	RthAltitude        *int    `json:"rth_altitude,omitempty"`
+	BatteryCapacity    *int    `json:"battery_capacity,omitempty"`
+	DurationSeconds    int     `json:"duration_seconds"`
+	Enabled            bool    `json:"enabled"`
+	NextRunAt          *string `json:"next_run_at,omitempty"`
+	LastRunAt          *string `json:"last_run_at,omitempty"`
+	CreatedAt          string  `json:"created_at"`
+	UpdatedAt          string  `json:"updated_at"`
+}
+
+// ListFlightTasksResponse represents the response for listing flight tasks
+type ListFlightTasksResponse struct {
+	Tasks      []FlightTaskResponse `json:"tasks"`
+	Total      int64                `json:"total"`
+	Page       int                  `json:"page"`
+	PageSize   int                  `json:"page_size"`
+	TotalPages int                  `json:"total_pages"`
+}
+
+// FlightTaskConflictResponse is returned when a task overlaps another task on the same dock
+type FlightTaskConflictResponse struct {
+	Code      string               `json:"code"`
+	Message   string               `json:"message"`
+	Conflicts []scheduler.Conflict `json:"conflicts"`
+}
+
+// FlightTaskRunResponse represents a single recorded occurrence
+type FlightTaskRunResponse struct {
+	ID                 uint   `json:"id"`
+	TaskID             uint   `json:"task_id"`
+	DockSN             string `json:"dock_sn"`
+	FlightID           string `json:"flight_id,omitempty"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	WaylineRevision    *int   `json:"wayline_revision,omitempty"`
+	WaylineFingerprint string `json:"wayline_fingerprint,omitempty"`
+	ScheduledAt        string `json:"scheduled_at"`
+	CreatedAt          string `json:"created_at"`
+}
+
+// BlackoutWindowRequest represents the request body for creating a blackout window
+type BlackoutWindowRequest struct {
+	DockSN  string    `json:"dock_sn,omitempty"`
+	StartAt time.Time `json:"start_at" binding:"required"`
+	EndAt   time.Time `json:"end_at" binding:"required"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// BlackoutWindowResponse represents a blackout window
+type BlackoutWindowResponse struct {
+	ID      uint   `json:"id"`
+	DockSN  string `json:"dock_sn,omitempty"`
+	StartAt string `json:"start_at"`
+	EndAt   string `json:"end_at"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.UTC().Format(time.RFC3339)
+	return &s
+}
+
+// toFlightTaskResponse converts a flight task model to response
+func toFlightTaskResponse(task *models.FlightTask) FlightTaskResponse {
+	return FlightTaskResponse{
+		ID:                 task.ID,
+		Name:               task.Name,
+		DockSN:             task.DockSN,
+		WorkspaceID:        task.WorkspaceID,
+		Vendor:             task.Vendor,
+		CronExpr:           task.CronExpr,
+		ExecuteTime:        formatOptionalTime(task.ExecuteTime),
+		Timezone:           task.Timezone,
+		WaylineURL:         task.WaylineURL,
+		WaylineFingerprint: task.WaylineFingerprint,
+		WaylineID:          task.WaylineID,
+		WaylineRevision:    task.WaylineRevision,
+		TaskType:           int(task.TaskType),
+		OutOfControlAction: task.OutOfControlAction,
+		RthAltitude:        task.RthAltitude,
+		BatteryCapacity:    task.BatteryCapacity,
+		DurationSeconds:    task.DurationSeconds,
+		Enabled:            task.Enabled,
+		NextRunAt:          formatOptionalTime(task.NextRunAt),
+		LastRunAt:          formatOptionalTime(task.LastRunAt),
+		CreatedAt:          task.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:          task.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func toFlightTaskRunResponse(run *models.FlightTaskRun) FlightTaskRunResponse {
+	return FlightTaskRunResponse{
+		ID:                 run.ID,
+		TaskID:             run.TaskID,
+		DockSN:             run.DockSN,
+		FlightID:           run.FlightID,
+		Status:             string(run.Status),
+		Reason:             run.Reason,
+		WaylineRevision:    run.WaylineRevision,
+		WaylineFingerprint: run.WaylineFingerprint,
+		ScheduledAt:        run.ScheduledAt.UTC().Format(time.RFC3339),
+		CreatedAt:          run.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func toBlackoutWindowResponse(w *models.BlackoutWindow) BlackoutWindowResponse {
+	return BlackoutWindowResponse{
+		ID:      w.ID,
+		DockSN:  w.DockSN,
+		StartAt: w.StartAt.UTC().Format(time.RFC3339),
+		EndAt:   w.EndAt.UTC().Format(time.RFC3339),
+		Reason:  w.Reason,
+	}
+}
+
+// prepareSchedule validates the task type and schedule and computes NextRunAt.
+// Returns true on success. On failure it writes an error response and returns false.
+func (h *FlightTask) prepareSchedule(c *gin.Context, task *models.FlightTask) bool {
+	if task.TaskType != models.FlightTaskTypeTimed && task.TaskType != models.FlightTaskTypeConditional {
+		respondBadRequest(c, "INVALID_TASK_TYPE", "Scheduled tasks must be timed (1) or conditional (2)")
+		return false
+	}
+	if task.CronExpr != "" && task.ExecuteTime != nil {
+		respondBadRequest(c, "INVALID_SCHEDULE", "Specify either cron_expr or execute_time, not both")
+		return false
+	}
+	if task.BatteryCapacity != nil && (*task.BatteryCapacity < 0 || *task.BatteryCapacity > 100) {
+		respondBadRequest(c, "INVALID_BATTERY_CAPACITY", "battery_capacity must be between 0 and 100")
+		return false
+	}
+
+	next, err := scheduler.NextRun(task, h.scheduler.Now())
+	if err != nil {
+		respondBadRequest(c, "INVALID_SCHEDULE", err.Error())
+		return false
+	}
+	if next == nil && task.Enabled {
+		respondBadRequest(c, "INVALID_SCHEDULE", "Schedule has no future occurrence")
+		return false
+	}
+	task.NextRunAt = next
+	if !task.Enabled {
+		task.NextRunAt = nil
+	}
+
+	if !task.Enabled {
+		return true
+	}
+	conflicts, err := h.scheduler.FindConflicts(c.Request.Context(), task)
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to check schedule conflicts", "Failed to check schedule conflicts")
+		return false
+	}
+	if len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, FlightTaskConflictResponse{
+			Code:      "SCHEDULE_CONFLICT",
+			Message:   "Task overlaps another task on the same dock",
+			Conflicts: conflicts,
+		})
+		return false
+	}
+	return true
+}
+
+// resolveWaylineRevision pins a task flying a stored wayline to a revision,
+// its latest when none is given, and takes the wayline fingerprint from it.
+// A fingerprint given in the request must match the revision's. The
+// scheduler signs the revision's archive at dispatch, so no WaylineURL is
+// kept for such tasks; other tasks must have one.
+// Returns true on success. On failure it writes an error response and returns false.
+func (h *FlightTask) resolveWaylineRevision(c *gin.Context, task *models.FlightTask, fingerprint string) bool {
+	if task.WaylineID == nil {
+		if task.WaylineURL == "" {
+			respondBadRequest(c, "WAYLINE_REQUIRED", "wayline_url or wayline_id is required")
+			return false
+		}
+		task.WaylineRevision = nil
+		return true
+	}
+
+	var w models.Wayline
+	if handleDBLookupError(c, h.logger, scopeWorkspace(c, h.db).First(&w, *task.WaylineID).Error,
+		"WAYLINE_NOT_FOUND", "Wayline not found",
+		"Failed to get wayline", "Failed to resolve wayline") {
+		return false
+	}
+	number := w.Revision
+	if task.WaylineRevision != nil {
+		number = *task.WaylineRevision
+	}
+	revision, err := wayline.FindRevision(c.Request.Context(), h.db, &w, number)
+	if errors.Is(err, wayline.ErrRevisionNotFound) {
+		respondBadRequest(c, "REVISION_NOT_FOUND", "Wayline revision not found")
+		return false
+	}
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to get wayline revision", "Failed to resolve wayline")
+		return false
+	}
+	if fingerprint != "" && fingerprint != revision.Fingerprint {
+		respondBadRequest(c, "FINGERPRINT_MISMATCH", "wayline_fingerprint does not match the wayline revision")
+		return false
+	}
+
+	task.WaylineRevision = &revision.Revision
+	task.WaylineFingerprint = revision.Fingerprint
+	task.WaylineURL = ""
+	return true
+}
+
+// Create creates a new scheduled flight task
+// @Summary Create a flight task
+// @Description Schedule a one-shot (execute_time) or recurring (cron_expr) wayline mission on a dock
+// @Tags flight-tasks
+// @Accept json
+// @Produce json
+// @Param task body CreateFlightTaskRequest true "Flight task"
+// @Success 201 {object} FlightTaskResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} FlightTaskConflictResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/flight-tasks [post]
+func (h *FlightTask) Create(c *gin.Context) {
+	var req CreateFlightTaskRequest
+	if !bindJSON(c, &req) || !requireDeviceScope(c, h.db, h.logger, req.DockSN) {
+		return
+	}
+
+	task := &models.FlightTask{
+		Name:               req.Name,
+		DockSN:             req.DockSN,
+		Vendor:             req.Vendor,
+		CronExpr:           req.CronExpr,
+		ExecuteTime:        req.ExecuteTime,
+		Timezone:           req.Timezone,
+		WaylineURL:         req.WaylineURL,
+		WaylineFingerprint: req.WaylineFingerprint,
+		WaylineID:          req.WaylineID,
+		WaylineRevision:    req.WaylineRevision,
+		TaskType:           models.FlightTaskTypeTimed,
+		OutOfControlAction: req.OutOfControlAction,
+		RthAltitude:        req.RthAltitude,
+		BatteryCapacity:    req.BatteryCapacity,
+		DurationSeconds:    req.DurationSeconds,
+		Enabled:            true,
+	}
+	if task.Vendor == "" {
+		task.Vendor = "dji"
+	}
+	if task.Timezone == "" {
+		task.Timezone = "UTC"
+	}
+	if req.TaskType != nil {
+		task.TaskType = models.FlightTaskType(*req.TaskType)
+	}
+	if req.Enabled != nil {
+		task.Enabled = *req.Enabled
+	}
+
+	if !h.resolveWaylineRevision(c, task, req.WaylineFingerprint) || !h.prepareSchedule(c, task) {
+		return
+	}
+
+	// Occurrences are dispatched on behalf of the dock's workspace at
+	// creation, so they are rejected once the dock moves to another
+	workspaceID, ok := workspaceScope(c)
+	if !ok {
+		var err error
+		if workspaceID, err = h.dockWorkspace(task.DockSN); err != nil {
+			respondInternalError(c, h.logger, err, "Failed to get dock workspace", "Failed to create flight task")
+			return
+		}
+	}
+	task.WorkspaceID = workspaceID
+
+	// Select keeps an explicit enabled=false from being replaced by the column default.
+	if err := h.db.Select("*").Omit("ID").Create(task).Error; err != nil {
+		respondInternalError(c, h.logger, err, "Failed to create flight task", "Failed to create flight task")
+		return
+	}
+
+	logWithTrace(h.logger, c.Request.Context()).WithFields(logrus.Fields{
+		"task_id": task.ID,
+		"dock_sn": task.DockSN,
+	}).Info("Flight task created")
+	c.JSON(http.StatusCreated, toFlightTaskResponse(task))
+}
+
+// dockWorkspace returns the workspace of a dock, or "" for docks outside any
+// workspace or not registered yet.
+func (h *FlightTask) dockWorkspace(dockSN string) (string, error) {
+	var workspaceIDs []string
+	if err := h.db.Model(&models.Device{}).Where("device_sn = ?", dockSN).Limit(1).Pluck("workspace_id", &workspaceIDs).Error; err != nil {
+		return "", err
+	}
+	if len(workspaceIDs) == 0 {
+		return "", nil
+	}
+	return workspaceIDs[0], nil
+}
+
+// Get retrieves a flight task by ID
+// @Summary Get a flight task
+// @Tags flight-tasks
+// @Produce json
+// @Param id path int true "Flight task ID"
+// @Success 200 {object} FlightTaskResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/flight-tasks/{id} [get]
+func (h *FlightTask) Get(c *gin.Context) {
+	id, ok := parseUintID(c, "id")
+	if !ok {
+		return
+	}
+
+	var task models.FlightTask
+	if handleDBLookupError(c, h.logger, scopeDevices(c, h.db, h.db, "dock_sn").First(&task, id).Error,
+		"FLIGHT_TASK_NOT_FOUND", "Flight task not found",
+		"Failed to get flight task", "Failed to get flight task") {
+		return
+	}
+
+	c.JSON(http.StatusOK, toFlightTaskResponse(&task))
+}
+
+// List lists flight tasks with pagination
+// @Summary List flight tasks
+// @Tags flight-tasks
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param dock_sn query string false "Filter by dock serial number"
+// @Success 200 {object} ListFlightTasksResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/flight-tasks [get]
+func (h *FlightTask) List(c *gin.Context) {
+	page, pageSize, offset := parsePagination(c, 20, 100)
+
+	query := scopeDevices(c, h.db, h.db.Model(&models.FlightTask{}), "dock_sn")
+	if dockSN := c.Query("dock_sn"); dockSN != "" {
+		query = query.Where("dock_sn = ?", dockSN)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondInternalError(c, h.logger, err, "Failed to count flight tasks", "Failed to list flight tasks")
+		return
+	}
+
+	var tasks []models.FlightTask
+	if err := query.Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&tasks).Error; err != nil {
+		respondInternalError(c, h.logger, err, "Failed to list flight tasks", "Failed to list flight tasks")
+		return
+	}
+
+	resp := make([]FlightTaskResponse, len(tasks))
+	for i := range tasks {
+		resp[i] = toFlightTaskResponse(&tasks[i])
+	}
+
+	c.JSON(http.StatusOK, ListFlightTasksResponse{
+		Tasks:      resp,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages(total, pageSize),
+	})
+}
+
+// Update updates a flight task and recomputes its next occurrence
+// @Summary Update a flight task
+// @Tags flight-tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Flight task ID"
+// @Param task body UpdateFlightTaskRequest true "Fields to update"
+// @Success 200 {object} FlightTaskResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} FlightTaskConflictResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/flight-tasks/{id} [put]
+func (h *FlightTask) Update(c *gin.Context) {
+	id, ok := parseUintID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req UpdateFlightTaskRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	var task models.FlightTask
+	if handleDBLookupError(c, h.logger, scopeDevices(c, h.db, h.db, "dock_sn").First(&task, id).Error,
+		"FLIGHT_TASK_NOT_FOUND", "Flight task not found",
+		"Failed to get flight task", "Failed to update flight task") {
+		return
+	}
+
+	if req.Name != nil {
+		task.Name = *req.Name
+	}
+	if req.CronExpr != nil {
+		task.CronExpr = *req.CronExpr
+		if task.CronExpr != "" {
+			task.ExecuteTime = nil
+		}
+	}
+	if req.ExecuteTime != nil {
+		task.ExecuteTime = req.ExecuteTime
+		task.CronExpr = ""
+	}
+	if req.Timezone != nil {
+		task.Timezone = *req.Timezone
+	}
+	if req.WaylineURL != nil {
+		task.WaylineURL = *req.WaylineURL
+	}
+	if req.WaylineFingerprint != nil {
+		task.WaylineFingerprint = *req.WaylineFingerprint
+	}
+	if req.WaylineID != nil || req.WaylineRevision != nil {
+		if req.WaylineID != nil {
+			task.WaylineID = req.WaylineID
+		}
+		task.WaylineRevision = req.WaylineRevision
+	}
+	if req.TaskType != nil {
+		task.TaskType = models.FlightTaskType(*req.TaskType)
+	}
+	if req.OutOfControlAction != nil {
+		task.OutOfControlAction = *req.OutOfControlAction
+	}
+	if req.RthAltitude != nil {
+		task.RthAltitude = req.RthAltitude
+	}
+	if req.BatteryCapacity != nil {
+		task.BatteryCapacity = req.BatteryCapacity
+	}
+	if req.DurationSeconds != nil {
+		task.DurationSeconds = *req.DurationSeconds
+	}
+	if req.Enabled != nil {
+		task.Enabled = *req.Enabled
+	}
+
+	if req.WaylineURL != nil || req.WaylineID != nil || req.WaylineRevision != nil || req.WaylineFingerprint != nil {
+		fingerprint := ""
+		if req.WaylineFingerprint != nil {
+			fingerprint = *req.WaylineFingerprint
+		}
+		if !h.resolveWaylineRevision(c, &task, fingerprint) {
+			return
+		}
+	}
+	if !h.prepareSchedule(c, &task) {
+		return
+	}
+
+	if err := h.db.Save(&task).Error; err != nil {
+		respondInternalError(c, h.logger, err, "Failed to update flight task", "Failed to update flight task")
+		return
+	}
+
+	logWithTrace(h.logger, c.Request.Context()).WithField("task_id", id).Info("Flight task updated")
+	c.JSON(http.StatusOK, toFlightTaskResponse(&task))
+}
+
+// Delete deletes a flight task
+// @Summary Delete a flight task
+// @Tags flight-tasks
+// @Param id path int true "Flight task ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/flight-tasks/{id} [delete]
+func (h *FlightTask) Delete(c *gin.Context) {
+	id, ok := parseUintID(c, "id")
+	if !ok {
+		return
+	}
+
+	result := scopeDevices(c, h.db, h.db, "dock_sn").Delete(&models.FlightTask{}, id)
+	if result.Error != nil {
+		respondInternalError(c, h.logger, result.Error, "Failed to delete flight task", "Failed to delete flight task")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondNotFound(c, "FLIGHT_TASK_NOT_FOUND", "Flight task not found")
+		return
+	}
+
+	logWithTrace(h.logger, c.Request.Context()).WithField("task_id", id).Info("Flight task deleted")
+	c.Status(http.StatusNoContent)
+}
+
+// ListRuns lists the recorded occurrences of a flight task
+// @Summary List flight task runs
+// @Description List dispatched, skipped and failed occurrences, newest first
+// @Tags flight-tasks
+// @Produce json
+// @Param id path int true "Flight task ID"
+// @Param limit query int false "Maximum number of runs" default(50)
+// @Success 200 {array} FlightTaskRunResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/flight-tasks/{id}/runs [get]
+func (h *FlightTask) ListRuns(c *gin.Context) {
+	id, ok := parseUintID(c, "id")
+	if !ok {
+		return
+	}
+	limit := parseLimit(c, 50, 500)
+
+	var runs []models.FlightTaskRun
+	query := scopeDevices(c, h.db, h.db.Where("task_id = ?", id), "dock_sn")
+	if err := query.Order("scheduled_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+		respondInternalError(c, h.logger, err, "Failed to list flight task runs", "Failed to list flight task runs")
+		return
+	}
+
+	resp := make([]FlightTaskRunResponse, len(runs))
+	for i := range runs {
+		resp[i] = toFlightTaskRunResponse(&runs[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateBlackout creates a blackout window
+// @Summary Create a blackout window
+// @Description No scheduled task starts inside a blackout window; an empty dock_sn applies to all docks
+// @Tags flight-tasks
+// @Accept json
+// @Produce json
+// @Param window body BlackoutWindowRequest true "Blackout window"
+// @Success 201 {object} BlackoutWindowResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/blackout-windows [post]
+func (h *FlightTask) CreateBlackout(c *gin.Context) {
+	var req BlackoutWindowRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !req.EndAt.After(req.StartAt) {
+		respondBadRequest(c, "INVALID_WINDOW", "end_at must be after start_at")
+		return
+	}
+	// Windows covering every dock are reserved to callers without a workspace
+	if _, scoped := workspaceScope(c); scoped && req.DockSN == "" {
+		respondWorkspaceForbidden(c)
+		return
+	}
+	if !requireDeviceScope(c, h.db, h.logger, req.DockSN) {
+		return
+	}
+
+	window := &models.BlackoutWindow{
+		DockSN:  req.DockSN,
+		StartAt: req.StartAt.UTC(),
+		EndAt:   req.EndAt.UTC(),
+		Reason:  req.Reason,
+	}
+	if err := h.db.Create(window).Error; err != nil {
+		respondInternalError(c, h.logger, err, "Failed to create blackout window", "Failed to create blackout window")
+		return
+	}
+
+	c.JSON(http.StatusCreated, toBlackoutWindowResponse(window))
+}
+
+// ListBlackouts lists blackout windows that have not yet ended
+// @Summary List blackout windows
+// @Tags flight-tasks
+// @Produce json
+// @Param dock_sn query string false "Filter by dock serial number"
+// @Success 200 {array} BlackoutWindowResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/blackout-windows [get]
+func (h *FlightTask) ListBlackouts(c *gin.Context) {
+	query := h.db.Where("end_at > ?", h.scheduler.Now())
+	if workspaceID, ok := workspaceScope(c); ok {
+		devices := h.db.Model(&models.Device{}).Select("device_sn").Where("workspace_id = ?", workspaceID)
+		query = query.Where("dock_sn = '' OR dock_sn IN (?)", devices)
+	}
+	if dockSN := c.Query("dock_sn"); dockSN != "" {
+		query = query.Where("dock_sn = '' OR dock_sn = ?", dockSN)
+	}
+
+	var windows []models.BlackoutWindow
+	if err := query.Order("start_at ASC").Find(&windows).Error; err != nil {
+		respondInternalError(c, h.logger, err, "Failed to list blackout windows", "Failed to list blackout windows")
+		return
+	}
+
+	resp := make([]BlackoutWindowResponse, len(windows))
+	for i := range windows {
+		resp[i] = toBlackoutWindowResponse(&windows[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteBlackout deletes a blackout window
+// @Summary Delete a blackout window
+// @Tags flight-tasks
+// @Param id path int true "Blackout window ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/blackout-windows/{id} [delete]
+func (h *FlightTask) DeleteBlackout(c *gin.Context) {
+	id, ok := parseUintID(c, "id")
+	if !ok {
+		return
+	}
+
+	result := scopeDevices(c, h.db, h.db, "dock_sn").Delete(&models.BlackoutWindow{}, id)
+	if result.Error != nil {
+		respondInternalError(c, h.logger, result.Error, "Failed to delete blackout window", "Failed to delete blackout window")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondNotFound(c, "BLACKOUT_NOT_FOUND", "Blackout window not found")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
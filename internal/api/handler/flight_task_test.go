@@ -0,0 +1,263 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/models"
+)
+
+func setupFlightTaskTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	err = db.AutoMigrate(&models.FlightTask{}, &models.FlightTaskRun{}, &models.BlackoutWindow{},
+		&models.Wayline{}, &models.WaylineRevision{}, &models.Device{})
+	require.NoError(t, err)
+
+	return db
+}
+
+func setupFlightTaskRouter(handler *FlightTask) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	router.POST("/api/v1/flight-tasks", handler.Create)
+	router.GET("/api/v1/flight-tasks", handler.List)
+	router.GET("/api/v1/flight-tasks/:id", handler.Get)
+	router.PUT("/api/v1/flight-tasks/:id", handler.Update)
+	router.DELETE("/api/v1/flight-tasks/:id", handler.Delete)
+	router.GET("/api/v1/flight-tasks/:id/runs", handler.ListRuns)
+	router.POST("/api/v1/blackout-windows", handler.CreateBlackout)
+	router.GET("/api/v1/blackout-windows", handler.ListBlackouts)
+	router.DELETE("/api/v1/blackout-windows/:id", handler.DeleteBlackout)
+
+	return router
+}
+
+func doJSON(t *testing.T, router *gin.Engine, method, path string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestFlightTask_Create(t *testing.T) {
+	db := setupFlightTaskTestDB(t)
+	router := setupFlightTaskRouter(NewFlightTask(db, nil, nil))
+
+	t.Run("recurring task", func(t *testing.T) {
+		w := doJSON(t, router, http.MethodPost, "/api/v1/flight-tasks", CreateFlightTaskRequest{
+			Name:       "morning patrol",
+			DockSN:     "DOCK001",
+			CronExpr:   "0 9 * * *",
+			WaylineURL: "https://example.com/patrol.kmz",
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var resp FlightTaskResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "dji", resp.Vendor)
+		assert.Equal(t, "UTC", resp.Timezone)
+		assert.Equal(t, int(models.FlightTaskTypeTimed), resp.TaskType)
+		assert.True(t, resp.Enabled)
+		assert.NotNil(t, resp.NextRunAt)
+		assert.Empty(t, resp.WorkspaceID, "the dock is not registered")
+	})
+
+	t.Run("dock workspace", func(t *testing.T) {
+		require.NoError(t, db.Create(&models.Device{DeviceSN: "DOCK9", DeviceName: "Dock 9", DeviceType: "dock", WorkspaceID: "ws1"}).Error)
+		w := doJSON(t, router, http.MethodPost, "/api/v1/flight-tasks", CreateFlightTaskRequest{
+			Name:       "evening patrol",
+			DockSN:     "DOCK9",
+			CronExpr:   "0 19 * * *",
+			WaylineURL: "https://example.com/patrol.kmz",
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var resp FlightTaskResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "ws1", resp.WorkspaceID)
+	})
+
+	t.Run("conflicting one-shot task", func(t *testing.T) {
+		at := time.Now().UTC().Add(48 * time.Hour).Truncate(24 * time.Hour).Add(9*time.Hour + 10*time.Minute)
+		w := doJSON(t, router, http.MethodPost, "/api/v1/flight-tasks", CreateFlightTaskRequest{
+			Name:        "survey",
+			DockSN:      "DOCK001",
+			ExecuteTime: &at,
+			WaylineURL:  "https://example.com/survey.kmz",
+		})
+		require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+
+		var resp FlightTaskConflictResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "SCHEDULE_CONFLICT", resp.Code)
+		assert.Len(t, resp.Conflicts, 1)
+	})
+
+	t.Run("other dock does not conflict", func(t *testing.T) {
+		at := time.Now().UTC().Add(48 * time.Hour).Truncate(24 * time.Hour).Add(9*time.Hour + 10*time.Minute)
+		w := doJSON(t, router, http.MethodPost, "/api/v1/flight-tasks", CreateFlightTaskRequest{
+			Name:        "survey",
+			DockSN:      "DOCK002",
+			ExecuteTime: &at,
+			WaylineURL:  "https://example.com/survey.kmz",
+		})
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	})
+
+	t.Run("invalid schedules", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		overfull := 101
+		cases := []CreateFlightTaskRequest{
+			{Name: "bad cron", DockSN: "DOCK003", CronExpr: "61 * * * *", WaylineURL: "u"},
+			{Name: "no schedule", DockSN: "DOCK003", WaylineURL: "u"},
+			{Name: "past", DockSN: "DOCK003", ExecuteTime: &past, WaylineURL: "u"},
+			{Name: "bad tz", DockSN: "DOCK003", CronExpr: "@daily", Timezone: "Mars/Olympus", WaylineURL: "u"},
+			{Name: "battery", DockSN: "DOCK003", CronExpr: "@daily", WaylineURL: "u", BatteryCapacity: &overfull},
+		}
+		for _, req := range cases {
+			w := doJSON(t, router, http.MethodPost, "/api/v1/flight-tasks", req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, req.Name)
+		}
+	})
+}
+
+func TestFlightTask_UpdateAndDelete(t *testing.T) {
+	db := setupFlightTaskTestDB(t)
+	router := setupFlightTaskRouter(NewFlightTask(db, nil, nil))
+
+	w := doJSON(t, router, http.MethodPost, "/api/v1/flight-tasks", CreateFlightTaskRequest{
+		Name:       "patrol",
+		DockSN:     "DOCK001",
+		CronExpr:   "0 9 * * *",
+		WaylineURL: "https://example.com/patrol.kmz",
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created FlightTaskResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	path := fmt.Sprintf("/api/v1/flight-tasks/%d", created.ID)
+
+	disabled := false
+	w = doJSON(t, router, http.MethodPut, path, UpdateFlightTaskRequest{Enabled: &disabled})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated FlightTaskResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.False(t, updated.Enabled)
+	assert.Nil(t, updated.NextRunAt)
+
+	w = doJSON(t, router, http.MethodGet, path+"/runs", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doJSON(t, router, http.MethodDelete, path, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = doJSON(t, router, http.MethodGet, path, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFlightTask_WaylineRevision(t *testing.T) {
+	db := setupFlightTaskTestDB(t)
+	router := setupFlightTaskRouter(NewFlightTask(db, nil, nil))
+
+	stored := &models.Wayline{Name: "patrol", ObjectKey: "waylines/b.kmz", Fingerprint: "fp2", Revision: 2}
+	require.NoError(t, db.Create(stored).Error)
+	require.NoError(t, db.Create(&[]models.WaylineRevision{
+		{WaylineID: stored.ID, Revision: 1, ObjectKey: "waylines/a.kmz", Fingerprint: "fp1"},
+		{WaylineID: stored.ID, Revision: 2, ObjectKey: "waylines/b.kmz", Fingerprint: "fp2"},
+	}).Error)
+
+	request := func(name string, revision *int, fingerprint string) CreateFlightTaskRequest {
+		return CreateFlightTaskRequest{
+			Name:               name,
+			DockSN:             "DOCK001",
+			CronExpr:           "0 9 * * *",
+			WaylineID:          &stored.ID,
+			WaylineRevision:    revision,
+			WaylineFingerprint: fingerprint,
+			Enabled:            new(bool),
+		}
+	}
+
+	// The latest revision is pinned by default
+	w := doJSON(t, router, http.MethodPost, "/api/v1/flight-tasks", request("latest", nil, ""))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created FlightTaskResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.NotNil(t, created.WaylineRevision)
+	assert.Equal(t, 2, *created.WaylineRevision)
+	assert.Equal(t, "fp2", created.WaylineFingerprint)
+	assert.Empty(t, created.WaylineURL, "the revision's archive is signed at dispatch")
+
+	first := 1
+	w = doJSON(t, router, http.MethodPut, fmt.Sprintf("/api/v1/flight-tasks/%d", created.ID), UpdateFlightTaskRequest{WaylineRevision: &first})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated FlightTaskResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, 1, *updated.WaylineRevision)
+	assert.Equal(t, "fp1", updated.WaylineFingerprint)
+
+	missing := 3
+	w = doJSON(t, router, http.MethodPost, "/api/v1/flight-tasks", request("missing", &missing, ""))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "REVISION_NOT_FOUND")
+	w = doJSON(t, router, http.MethodPost, "/api/v1/flight-tasks", request("mismatch", &first, "fp2"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "FINGERPRINT_MISMATCH")
+
+	neither := request("neither", nil, "")
+	neither.WaylineID = nil
+	w = doJSON(t, router, http.MethodPost, "/api/v1/flight-tasks", neither)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "WAYLINE_REQUIRED")
+}
+
+func TestFlightTask_BlackoutWindows(t *testing.T) {
+	db := setupFlightTaskTestDB(t)
+	router := setupFlightTaskRouter(NewFlightTask(db, nil, nil))
+
+	start := time.Now().UTC().Add(time.Hour)
+	w := doJSON(t, router, http.MethodPost, "/api/v1/blackout-windows", BlackoutWindowRequest{
+		StartAt: start,
+		EndAt:   start.Add(-time.Minute),
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doJSON(t, router, http.MethodPost, "/api/v1/blackout-windows", BlackoutWindowRequest{
+		DockSN:  "DOCK001",
+		StartAt: start,
+		EndAt:   start.Add(2 * time.Hour),
+		Reason:  "maintenance",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created BlackoutWindowResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	w = doJSON(t, router, http.MethodGet, "/api/v1/blackout-windows", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "maintenance")
+
+	path := fmt.Sprintf("/api/v1/blackout-windows/%d", created.ID)
+	w = doJSON(t, router, http.MethodDelete, path, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = doJSON(t, router, http.MethodDelete, path, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// Media handles media catalog API requests
+type Media struct {
+	db     *gorm.DB
+	logger *logrus.Entry
+}
+
+// NewMedia creates a new media catalog handler
+func NewMedia(db *gorm.DB, logger *logrus.Entry) *Media {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Media{
+		db:     db,
+		logger: logger.WithField("handler", "media"),
+	}
+}
+
+// MediaFileResponse represents a catalogued media file
+type MediaFileResponse struct {
+	ID               uint    `json:"id"`
+	WorkspaceID      string  `json:"workspace_id"`
+	Source           string  `json:"source"`
+	DeviceSN         string  `json:"device_sn,omitempty"`
+	Username         string  `json:"username,omitempty"`
+	Name             string  `json:"name"`
+	Path             string  `json:"path,omitempty"`
+	ObjectKey        string  `json:"object_key"`
+	Fingerprint      string  `json:"fingerprint,omitempty"`
+	FlightID         string  `json:"flight_id,omitempty"`
+	DroneModelKey    string  `json:"drone_model_key,omitempty"`
+	PayloadModelKey  string  `json:"payload_model_key,omitempty"`
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	AbsoluteAltitude float64 `json:"absolute_altitude"`
+	RelativeAltitude float64 `json:"relative_altitude"`
+	GimbalYawDegree  float64 `json:"gimbal_yaw_degree"`
+	IsOriginal       bool    `json:"is_original"`
+	ShootTime        string  `json:"shoot_time,omitempty"`
+	CreatedAt        string  `json:"created_at"`
+}
+
+// ListMediaFilesResponse represents a paginated list of media files
+type ListMediaFilesResponse struct {
+	Files      []MediaFileResponse `json:"files"`
+	Total      int64               `json:"total"`
+	Page       int                 `json:"page"`
+	PageSize   int                 `json:"page_size"`
+	TotalPages int                 `json:"total_pages"`
+}
+
+func toMediaFileResponse(m *models.MediaFile) MediaFileResponse {
+	resp := MediaFileResponse{
+		ID:               m.ID,
+		WorkspaceID:      m.WorkspaceID,
+		Source:           m.Source,
+		DeviceSN:         m.DeviceSN,
+		Username:         m.Username,
+		Name:             m.Name,
+		Path:             m.Path,
+		ObjectKey:        m.ObjectKey,
+		Fingerprint:      m.Fingerprint,
+		FlightID:         m.FlightID,
+		DroneModelKey:    m.DroneModelKey,
+		PayloadModelKey:  m.PayloadModelKey,
+		Latitude:         m.Latitude,
+		Longitude:        m.Longitude,
+		AbsoluteAltitude: m.AbsoluteAltitude,
+		RelativeAltitude: m.RelativeAltitude,
+		GimbalYawDegree:  m.GimbalYawDegree,
+		IsOriginal:       m.IsOriginal,
+		CreatedAt:        m.CreatedAt.Format(time.RFC3339),
+	}
+	if m.ShootTime != nil {
+		resp.ShootTime = m.ShootTime.Format(time.RFC3339)
+	}
+	return resp
+}
+
+// Get returns a catalogued media file
+// @Summary Get a media file
+// @Tags media
+// @Produce json
+// @Param id path int true "Media file ID"
+// @Success 200 {object} MediaFileResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/media/{id} [get]
+func (h *Media) Get(c *gin.Context) {
+	id, ok := parseUintID(c, "id")
+	if !ok {
+		return
+	}
+
+	var m models.MediaFile
+	if handleDBLookupError(c, h.logger, scopeWorkspace(c, h.db).First(&m, id).Error,
+		"MEDIA_NOT_FOUND", "Media file not found",
+		"Failed to get media file", "Failed to get media file") {
+		return
+	}
+	c.JSON(http.StatusOK, toMediaFileResponse(&m))
+}
+
+// List searches dock and Pilot 2 media together
+// @Summary List media files
+// @Tags media
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param workspace_id query string false "Filter by workspace"
+// @Param source query string false "Filter by source (dock, pilot)"
+// @Param device_sn query string false "Filter by capturing device"
+// @Param flight_id query string false "Filter by flight"
+// @Param name query string false "Filter by name (substring)"
+// @Success 200 {object} ListMediaFilesResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/media [get]
+func (h *Media) List(c *gin.Context) {
+	page, pageSize, offset := parsePagination(c, 20, 100)
+
+	query := scopeWorkspace(c, h.db.Model(&models.MediaFile{}))
+	for _, column := range []string{"workspace_id", "source", "device_sn", "flight_id"} {
+		if value, ok := c.GetQuery(column); ok {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondInternalError(c, h.logger, err, "Failed to count media files", "Failed to list media files")
+		return
+	}
+
+	var files []models.MediaFile
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&files).Error; err != nil {
+		respondInternalError(c, h.logger, err, "Failed to list media files", "Failed to list media files")
+		return
+	}
+
+	resp := ListMediaFilesResponse{
+		Files:      make([]MediaFileResponse, len(files)),
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages(total, pageSize),
+	}
+	for i := range files {
+		resp.Files[i] = toMediaFileResponse(&files[i])
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
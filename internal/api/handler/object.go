@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+// Object serves objects through URLs signed by an objectstore.URLSigner, for
+// object stores that cannot presign URLs themselves
+type Object struct {
+	store  objectstore.Store
+	signer *objectstore.URLSigner
+	logger *logrus.Entry
+}
+
+// NewObject creates a new signed object download handler
+func NewObject(store objectstore.Store, signer *objectstore.URLSigner, logger *logrus.Entry) *Object {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Object{
+		store:  store,
+		signer: signer,
+		logger: logger.WithField("handler", "object"),
+	}
+}
+
+// Download streams an object if the URL signature is valid and unexpired
+// @Summary Download a signed object
+// @Tags objects
+// @Param key path string true "Object key"
+// @Param expires query int true "Expiry (unix seconds)"
+// @Param signature query string true "URL signature"
+// @Success 200 {file} binary
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /objects/{key} [get]
+func (h *Object) Download(c *gin.Context) {
+	if h.store == nil || h.signer == nil {
+		respondServiceUnavailable(c, "Object downloads not available")
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if !h.signer.Verify(key, c.Query("expires"), c.Query("signature"), time.Now()) {
+		respondError(c, http.StatusForbidden, "INVALID_SIGNATURE", "Invalid or expired download URL")
+		return
+	}
+
+	rc, info, err := h.store.Get(c.Request.Context(), key)
+	if errors.Is(err, objectstore.ErrNotFound) || errors.Is(err, objectstore.ErrInvalidKey) {
+		respondNotFound(c, "OBJECT_NOT_FOUND", "Object not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to open object", "Failed to download object")
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, rc, nil)
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/utmos/utmos/internal/api/middleware"
+)
+
+// PilotResponse is the response envelope of the DJI Pilot 2 HTTPS APIs.
+// Code is 0 on success.
+type PilotResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+}
+
+// PilotPagination describes a page of a Pilot list response.
+type PilotPagination struct {
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+	Total    int64 `json:"total"`
+}
+
+// PilotPage is the data of a paginated Pilot list response.
+type PilotPage struct {
+	List       any             `json:"list"`
+	Pagination PilotPagination `json:"pagination"`
+}
+
+// pilotOK writes a successful Pilot response.
+func pilotOK(c *gin.Context, data any) {
+	c.JSON(http.StatusOK, PilotResponse{Code: 0, Message: "success", Data: data})
+}
+
+// pilotError writes a failed Pilot response; the HTTP status doubles as the code.
+func pilotError(c *gin.Context, status int, message string) {
+	c.JSON(status, PilotResponse{Code: status, Message: message})
+}
+
+// requirePilotIdentity returns the identity resolved by the Pilot auth middleware.
+func requirePilotIdentity(c *gin.Context) (*middleware.PilotIdentity, bool) {
+	identity, ok := middleware.GetPilotIdentity(c)
+	if !ok {
+		pilotError(c, http.StatusUnauthorized, "x-auth-token is required")
+		return nil, false
+	}
+	return identity, true
+}
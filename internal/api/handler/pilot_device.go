@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/topology"
+)
+
+// PilotDevice implements the DJI Pilot 2 device management HTTPS APIs.
+// Online, offline and topology changes are pushed to the workspace's
+// WebSocket clients by the topology service.
+type PilotDevice struct {
+	service *topology.Service
+	logger  *logrus.Entry
+}
+
+// NewPilotDevice creates a new Pilot device handler
+func NewPilotDevice(service *topology.Service, logger *logrus.Entry) *PilotDevice {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &PilotDevice{
+		service: service,
+		logger:  logger.WithField("handler", "pilot_device"),
+	}
+}
+
+// PilotTopologyListResponse is the device topology list of a workspace
+type PilotTopologyListResponse struct {
+	List []topology.Topology `json:"list"`
+}
+
+// Topologies returns the devices of the workspace grouped by gateway
+// @Summary Obtain device topology list (Pilot 2)
+// @Tags pilot
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Success 200 {object} PilotResponse
+// @Router /manage/api/v1/workspaces/{workspace_id}/devices/topologies [get]
+func (h *PilotDevice) Topologies(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok {
+		return
+	}
+	if h.service == nil {
+		pilotError(c, http.StatusServiceUnavailable, "device topology not available")
+		return
+	}
+
+	list, err := h.service.Topologies(c.Request.Context(), identity.WorkspaceID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list device topology")
+		pilotError(c, http.StatusInternalServerError, "failed to list device topology")
+		return
+	}
+	pilotOK(c, PilotTopologyListResponse{List: list})
+}
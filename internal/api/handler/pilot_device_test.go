@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/api/middleware"
+	"github.com/utmos/utmos/internal/topology"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+func TestPilotDevice_Topologies(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Device{}))
+	require.NoError(t, db.Create(&models.Device{
+		DeviceSN: "DOCK1", DeviceName: "Dock 1", DeviceType: "dock", Vendor: "dji", WorkspaceID: "ws1",
+	}).Error)
+
+	svc := topology.NewService(db, nil, nil)
+	require.NoError(t, svc.Update(context.Background(), &topology.Status{
+		GatewaySN:       "DOCK1",
+		GatewayModelKey: "3-1-0",
+		SubDevices:      []topology.SubDevice{{DeviceSN: "UAV1", DeviceModelKey: "0-67-0", Online: true}},
+		Online:          true,
+	}))
+
+	tokens := middleware.StaticPilotTokens{
+		"token-ws1": {WorkspaceID: "ws1", Username: "alice"},
+		"token-ws2": {WorkspaceID: "ws2", Username: "bob"},
+	}
+	h := NewPilotDevice(svc, nil)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/manage/api/v1/workspaces/:workspace_id/devices/topologies", middleware.PilotAuth(tokens, nil), h.Topologies)
+	f := &pilotWaylineFixture{router: router}
+
+	w := f.do(t, http.MethodGet, "/manage/api/v1/workspaces/ws1/devices/topologies", "token-ws1", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp PilotTopologyListResponse
+	decodePilot(t, w, &resp)
+	require.Len(t, resp.List, 1)
+	assert.Equal(t, "DOCK1", resp.List[0].Parents[0].SN)
+	assert.Equal(t, "3-1-0", resp.List[0].Parents[0].DeviceModel.Key)
+	require.Len(t, resp.List[0].Hosts, 1)
+	assert.Equal(t, "UAV1", resp.List[0].Hosts[0].SN)
+	assert.True(t, resp.List[0].Hosts[0].OnlineStatus)
+	assert.Equal(t, topology.DeviceModel{Key: "0-67-0", Domain: "0", Type: "67", SubType: "0"}, resp.List[0].Hosts[0].DeviceModel)
+	assert.Equal(t, topology.AircraftIconURL, resp.List[0].Hosts[0].IconURLs.NormalIconURL)
+	assert.Contains(t, w.Body.String(), `"icon_urls":{`)
+
+	w = f.do(t, http.MethodGet, "/manage/api/v1/workspaces/ws2/devices/topologies", "token-ws2", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodePilot(t, w, &resp)
+	assert.Empty(t, resp.List)
+
+	w = f.do(t, http.MethodGet, "/manage/api/v1/workspaces/ws1/devices/topologies", "token-ws2", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = f.do(t, http.MethodGet, "/manage/api/v1/workspaces/ws1/devices/topologies", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
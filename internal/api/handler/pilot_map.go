@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/mapelement"
+)
+
+// PilotMap implements the DJI Pilot 2 map element HTTPS APIs. Changes are
+// pushed to the workspace's WebSocket clients by the map element service.
+type PilotMap struct {
+	service *mapelement.Service
+	logger  *logrus.Entry
+}
+
+// NewPilotMap creates a new Pilot map element handler
+func NewPilotMap(service *mapelement.Service, logger *logrus.Entry) *PilotMap {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &PilotMap{
+		service: service,
+		logger:  logger.WithField("handler", "pilot_map"),
+	}
+}
+
+// PilotMapElementResource is the typed GeoJSON content of a map element
+type PilotMapElementResource struct {
+	Type    int                `json:"type"`
+	Content mapelement.Content `json:"content"`
+}
+
+// PilotCreateMapElementRequest creates a map element
+type PilotCreateMapElementRequest struct {
+	ID       string                  `json:"id"`
+	Name     string                  `json:"name" binding:"required"`
+	Resource PilotMapElementResource `json:"resource"`
+}
+
+// PilotUpdateMapElementRequest updates a map element
+type PilotUpdateMapElementRequest struct {
+	Name    string              `json:"name"`
+	Content *mapelement.Content `json:"content"`
+}
+
+func (h *PilotMap) requireService(c *gin.Context) bool {
+	if h.service == nil {
+		pilotError(c, http.StatusServiceUnavailable, "map elements not available")
+		return false
+	}
+	return true
+}
+
+// respondMapElementError maps map element service errors to Pilot responses
+func (h *PilotMap) respondMapElementError(c *gin.Context, err error, logMsg string) {
+	switch {
+	case errors.Is(err, mapelement.ErrInvalidElement):
+		pilotError(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, mapelement.ErrGroupNotFound):
+		pilotError(c, http.StatusNotFound, "element group not found")
+	case errors.Is(err, mapelement.ErrElementNotFound):
+		pilotError(c, http.StatusNotFound, "element not found")
+	case errors.Is(err, mapelement.ErrDuplicateElement):
+		pilotError(c, http.StatusConflict, "element id already exists")
+	case errors.Is(err, mapelement.ErrGroupLocked):
+		pilotError(c, http.StatusForbidden, "element group is locked")
+	default:
+		h.logger.WithError(err).Error(logMsg)
+		pilotError(c, http.StatusInternalServerError, "failed to update map elements")
+	}
+}
+
+// ListGroups returns the workspace's element groups with their elements
+// @Summary Obtain map elements (Pilot 2)
+// @Tags pilot
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param group_id query string false "Element group ID"
+// @Success 200 {object} PilotResponse
+// @Router /map/api/v1/workspaces/{workspace_id}/element-groups [get]
+func (h *PilotMap) ListGroups(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	groups, err := h.service.Groups(c.Request.Context(), identity.WorkspaceID, c.Query("group_id"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list map elements")
+		pilotError(c, http.StatusInternalServerError, "failed to list map elements")
+		return
+	}
+	pilotOK(c, groups)
+}
+
+// CreateElement adds a map element to a group
+// @Summary Create map element (Pilot 2)
+// @Tags pilot
+// @Accept json
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param group_id path string true "Element group ID"
+// @Param body body PilotCreateMapElementRequest true "Element"
+// @Success 200 {object} PilotResponse
+// @Failure 400 {object} PilotResponse
+// @Router /map/api/v1/workspaces/{workspace_id}/element-groups/{group_id}/elements [post]
+func (h *PilotMap) CreateElement(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	var req PilotCreateMapElementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pilotError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	element, err := h.service.Create(c.Request.Context(), identity.WorkspaceID, identity.Username,
+		c.Param("group_id"), req.ID, req.Name, req.Resource.Content)
+	if err != nil {
+		h.respondMapElementError(c, err, "Failed to create map element")
+		return
+	}
+	pilotOK(c, gin.H{"id": element.ID})
+}
+
+// UpdateElement changes a map element's name or content
+// @Summary Update map element (Pilot 2)
+// @Tags pilot
+// @Accept json
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param element_id path string true "Element ID"
+// @Param body body PilotUpdateMapElementRequest true "Changes"
+// @Success 200 {object} PilotResponse
+// @Failure 404 {object} PilotResponse
+// @Router /map/api/v1/workspaces/{workspace_id}/elements/{element_id} [put]
+func (h *PilotMap) UpdateElement(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	var req PilotUpdateMapElementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pilotError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := h.service.Update(c.Request.Context(), identity.WorkspaceID, identity.Username,
+		c.Param("element_id"), req.Name, req.Content); err != nil {
+		h.respondMapElementError(c, err, "Failed to update map element")
+		return
+	}
+	pilotOK(c, nil)
+}
+
+// DeleteElement removes a map element
+// @Summary Delete map element (Pilot 2)
+// @Tags pilot
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param element_id path string true "Element ID"
+// @Success 200 {object} PilotResponse
+// @Failure 404 {object} PilotResponse
+// @Router /map/api/v1/workspaces/{workspace_id}/elements/{element_id} [delete]
+func (h *PilotMap) DeleteElement(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), identity.WorkspaceID, c.Param("element_id")); err != nil {
+		h.respondMapElementError(c, err, "Failed to delete map element")
+		return
+	}
+	pilotOK(c, nil)
+}
+
+// ClearGroup removes every element of a group
+// @Summary Delete all elements of a group (Pilot 2)
+// @Tags pilot
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param group_id path string true "Element group ID"
+// @Success 200 {object} PilotResponse
+// @Failure 404 {object} PilotResponse
+// @Router /map/api/v1/workspaces/{workspace_id}/element-groups/{group_id}/elements [delete]
+func (h *PilotMap) ClearGroup(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	if err := h.service.ClearGroup(c.Request.Context(), identity.WorkspaceID, c.Param("group_id")); err != nil {
+		h.respondMapElementError(c, err, "Failed to clear map element group")
+		return
+	}
+	pilotOK(c, nil)
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/api/middleware"
+	"github.com/utmos/utmos/internal/mapelement"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+func setupPilotMap(t *testing.T) *pilotWaylineFixture {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.MapElementGroup{}, &models.MapElement{}))
+
+	h := NewPilotMap(mapelement.NewService(db, nil, nil), nil)
+	tokens := middleware.StaticPilotTokens{
+		"token-ws1": {WorkspaceID: "ws1", Username: "alice"},
+		"token-ws2": {WorkspaceID: "ws2", Username: "bob"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	group := router.Group("/map/api/v1/workspaces/:workspace_id", middleware.PilotAuth(tokens, nil))
+	group.GET("/element-groups", h.ListGroups)
+	group.POST("/element-groups/:group_id/elements", h.CreateElement)
+	group.DELETE("/element-groups/:group_id/elements", h.ClearGroup)
+	group.PUT("/elements/:element_id", h.UpdateElement)
+	group.DELETE("/elements/:element_id", h.DeleteElement)
+
+	return &pilotWaylineFixture{router: router}
+}
+
+func TestPilotMap_ElementCRUD(t *testing.T) {
+	f := setupPilotMap(t)
+	base := "/map/api/v1/workspaces/ws1"
+
+	w := f.do(t, http.MethodGet, base+"/element-groups", "token-ws1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var groups []mapelement.Group
+	decodePilot(t, w, &groups)
+	require.Len(t, groups, 2)
+	groupID := groups[0].ID
+
+	point := mapelement.Content{
+		Type:       "Feature",
+		Properties: mapelement.Properties{Color: "#FF0000"},
+		Geometry:   mapelement.Geometry{Type: mapelement.GeometryPoint, Coordinates: json.RawMessage(`[113.95, 22.54]`)},
+	}
+	w = f.do(t, http.MethodPost, base+"/element-groups/"+groupID+"/elements", "token-ws1",
+		PilotCreateMapElementRequest{ID: "e1", Name: "marker", Resource: PilotMapElementResource{Content: point}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var created struct {
+		ID string `json:"id"`
+	}
+	decodePilot(t, w, &created)
+	assert.Equal(t, "e1", created.ID)
+
+	bad := point
+	bad.Geometry.Coordinates = json.RawMessage(`[200, 22.54]`)
+	w = f.do(t, http.MethodPost, base+"/element-groups/"+groupID+"/elements", "token-ws1",
+		PilotCreateMapElementRequest{Name: "bad", Resource: PilotMapElementResource{Content: bad}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = f.do(t, http.MethodPut, base+"/elements/e1", "token-ws1", PilotUpdateMapElementRequest{Name: "renamed"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = f.do(t, http.MethodPut, "/map/api/v1/workspaces/ws2/elements/e1", "token-ws2", PilotUpdateMapElementRequest{Name: "x"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = f.do(t, http.MethodGet, base+"/element-groups?group_id="+groupID, "token-ws1", nil)
+	decodePilot(t, w, &groups)
+	require.Len(t, groups, 1)
+	require.Len(t, groups[0].Elements, 1)
+	assert.Equal(t, "renamed", groups[0].Elements[0].Name)
+	assert.Equal(t, "alice", groups[0].Elements[0].Resource.UserName)
+
+	w = f.do(t, http.MethodDelete, base+"/elements/e1", "token-ws1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = f.do(t, http.MethodDelete, base+"/elements/e1", "token-ws1", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = f.do(t, http.MethodDelete, base+"/element-groups/"+groupID+"/elements", "token-ws1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/media"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/file"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+// PilotMedia implements the DJI Pilot 2 media management HTTPS APIs. Uploads
+// are recorded in the same catalog as dock media.
+type PilotMedia struct {
+	service *media.Service
+	store   objectstore.Store
+	logger  *logrus.Entry
+}
+
+// NewPilotMedia creates a new Pilot media handler. store must be the bucket
+// Pilot 2 uploads to with the temporary credentials; it may be nil, in which
+// case uploaded objects are catalogued without checking they exist.
+func NewPilotMedia(service *media.Service, store objectstore.Store, logger *logrus.Entry) *PilotMedia {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &PilotMedia{
+		service: service,
+		store:   store,
+		logger:  logger.WithField("handler", "pilot_media"),
+	}
+}
+
+// PilotFastUploadRequest asks whether a file with the fingerprint is already stored
+type PilotFastUploadRequest struct {
+	Fingerprint string   `json:"fingerprint" binding:"required"`
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	Ext         file.Ext `json:"ext"`
+}
+
+// PilotTinyFingerprintsRequest lists tiny fingerprints of files Pilot 2 may upload
+type PilotTinyFingerprintsRequest struct {
+	TinyFingerprints []string `json:"tiny_fingerprints"`
+}
+
+// PilotTinyFingerprintsResponse lists the tiny fingerprints already stored
+type PilotTinyFingerprintsResponse struct {
+	TinyFingerprints []string `json:"tiny_fingerprints"`
+}
+
+// PilotMediaUploadRequest is reported by Pilot 2 after uploading a media file
+// to the object store with temporary credentials
+type PilotMediaUploadRequest struct {
+	Name        string             `json:"name" binding:"required"`
+	ObjectKey   string             `json:"object_key" binding:"required"`
+	Path        string             `json:"path"`
+	Fingerprint string             `json:"fingerprint"`
+	Ext         file.Ext           `json:"ext"`
+	Metadata    file.MediaMetadata `json:"metadata"`
+}
+
+// PilotGroupUploadRequest is reported by Pilot 2 after uploading a file group
+type PilotGroupUploadRequest struct {
+	FileGroupID       string `json:"file_group_id"`
+	FlightID          string `json:"flight_id"`
+	FileCount         int    `json:"file_count"`
+	FileUploadedCount int    `json:"file_uploaded_count"`
+}
+
+func (h *PilotMedia) requireService(c *gin.Context) bool {
+	if h.service == nil {
+		pilotError(c, http.StatusServiceUnavailable, "media catalog not available")
+		return false
+	}
+	return true
+}
+
+// FastUpload succeeds when a file with the same fingerprint is already
+// catalogued, so Pilot 2 can skip uploading it
+// @Summary Media fast upload (Pilot 2)
+// @Tags pilot
+// @Accept json
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param body body PilotFastUploadRequest true "File fingerprint"
+// @Success 200 {object} PilotResponse
+// @Failure 404 {object} PilotResponse
+// @Router /media/api/v1/workspaces/{workspace_id}/fast-upload [post]
+func (h *PilotMedia) FastUpload(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	var req PilotFastUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pilotError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err := h.service.FindByFingerprint(c.Request.Context(), identity.WorkspaceID, req.Fingerprint)
+	if errors.Is(err, media.ErrNotFound) {
+		pilotError(c, http.StatusNotFound, "file not stored, upload required")
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to look up media fingerprint")
+		pilotError(c, http.StatusInternalServerError, "failed to look up fingerprint")
+		return
+	}
+	pilotOK(c, nil)
+}
+
+// TinyFingerprints returns which of the given tiny fingerprints are already stored
+// @Summary Obtain existing file tiny fingerprints (Pilot 2)
+// @Tags pilot
+// @Accept json
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param body body PilotTinyFingerprintsRequest true "Tiny fingerprints"
+// @Success 200 {object} PilotResponse
+// @Router /media/api/v1/workspaces/{workspace_id}/files/tiny-fingerprints [post]
+func (h *PilotMedia) TinyFingerprints(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	var req PilotTinyFingerprintsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pilotError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existing, err := h.service.ExistingTinyFingerprints(c.Request.Context(), identity.WorkspaceID, req.TinyFingerprints)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to look up tiny fingerprints")
+		pilotError(c, http.StatusInternalServerError, "failed to look up tiny fingerprints")
+		return
+	}
+	pilotOK(c, PilotTinyFingerprintsResponse{TinyFingerprints: existing})
+}
+
+// UploadCallback catalogues a media file Pilot 2 uploaded to the object store
+// @Summary Report media upload result (Pilot 2)
+// @Tags pilot
+// @Accept json
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param body body PilotMediaUploadRequest true "Upload result"
+// @Success 200 {object} PilotResponse
+// @Failure 400 {object} PilotResponse
+// @Router /media/api/v1/workspaces/{workspace_id}/upload-callback [post]
+func (h *PilotMedia) UploadCallback(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	var req PilotMediaUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pilotError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	objectKey, err := objectstore.CleanKey(req.ObjectKey)
+	if err != nil || !strings.HasPrefix(objectKey, pilotObjectPrefix(identity.WorkspaceID)) {
+		pilotError(c, http.StatusBadRequest, "object_key is outside the workspace upload prefix")
+		return
+	}
+	if h.store != nil {
+		if _, err := h.store.Stat(c.Request.Context(), objectKey); err != nil {
+			if errors.Is(err, objectstore.ErrNotFound) {
+				pilotError(c, http.StatusBadRequest, "uploaded object not found")
+				return
+			}
+			h.logger.WithError(err).Error("Failed to stat uploaded media")
+			pilotError(c, http.StatusInternalServerError, "failed to check uploaded object")
+			return
+		}
+	}
+
+	info := file.Info{
+		ObjectKey: objectKey,
+		Path:      req.Path,
+		Name:      req.Name,
+		Ext:       req.Ext,
+		Metadata:  req.Metadata,
+	}
+	if req.Fingerprint != "" {
+		info.Ext.Fingerprint = req.Fingerprint
+	}
+	uploader := media.Uploader{
+		WorkspaceID: identity.WorkspaceID,
+		Source:      models.MediaSourcePilot,
+		Username:    identity.Username,
+	}
+
+	m, err := h.service.Record(c.Request.Context(), media.NewMediaFile(uploader, &info))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to catalogue uploaded media")
+		pilotError(c, http.StatusInternalServerError, "failed to record media file")
+		return
+	}
+	pilotOK(c, m.ObjectKey)
+}
+
+// GroupUploadCallback acknowledges the end of a file group upload
+// @Summary Report media group upload result (Pilot 2)
+// @Tags pilot
+// @Accept json
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param body body PilotGroupUploadRequest true "Group upload result"
+// @Success 200 {object} PilotResponse
+// @Router /media/api/v1/workspaces/{workspace_id}/group-upload-callback [post]
+func (h *PilotMedia) GroupUploadCallback(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	var req PilotGroupUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pilotError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err := h.service.CompleteGroup(c.Request.Context(), identity.WorkspaceID, media.GroupUpload{
+		FileGroupID:       req.FileGroupID,
+		FlightID:          req.FlightID,
+		FileCount:         req.FileCount,
+		FileUploadedCount: req.FileUploadedCount,
+	})
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to record group upload")
+		pilotError(c, http.StatusInternalServerError, "failed to record group upload")
+		return
+	}
+	pilotOK(c, nil)
+}
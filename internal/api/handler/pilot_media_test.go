@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/api/middleware"
+	"github.com/utmos/utmos/internal/media"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/file"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+// memoryBucket is an in-memory S3 bucket served over HTTP, which Pilot 2
+// uploads to directly.
+type memoryBucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (b *memoryBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		b.objects[r.URL.Path] = data
+	case http.MethodHead, http.MethodGet:
+		data, ok := b.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	}
+}
+
+type pilotMediaFixture struct {
+	router  *gin.Engine
+	service *media.Service
+	// bucketURL is the URL of the bucket the store and Pilot 2 use
+	bucketURL string
+}
+
+func setupPilotMedia(t *testing.T) *pilotMediaFixture {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.MediaFile{}, &models.Device{}))
+	require.NoError(t, db.Create(&models.Device{DeviceSN: "dock-sn", DeviceName: "dock", DeviceType: "dock", WorkspaceID: "ws1"}).Error)
+
+	bucket := httptest.NewServer(&memoryBucket{objects: map[string][]byte{}})
+	t.Cleanup(bucket.Close)
+	store, err := objectstore.NewS3Store(objectstore.S3Config{
+		Endpoint: bucket.URL, Bucket: "utmos", AccessKeyID: "ak", AccessKeySecret: "sk", PathStyle: true,
+	})
+	require.NoError(t, err)
+
+	svc := media.NewService(db, nil)
+	h := NewPilotMedia(svc, store, nil)
+	catalog := NewMedia(db, nil)
+
+	tokens := middleware.StaticPilotTokens{
+		"token-ws1": {WorkspaceID: "ws1", Username: "alice"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	group := router.Group("/media/api/v1/workspaces/:workspace_id", middleware.PilotAuth(tokens, nil))
+	group.POST("/fast-upload", h.FastUpload)
+	group.POST("/files/tiny-fingerprints", h.TinyFingerprints)
+	group.POST("/upload-callback", h.UploadCallback)
+	group.POST("/group-upload-callback", h.GroupUploadCallback)
+	router.GET("/api/v1/media", catalog.List)
+	router.GET("/api/v1/media/:id", catalog.Get)
+
+	return &pilotMediaFixture{router: router, service: svc, bucketURL: bucket.URL + "/utmos/"}
+}
+
+func (f *pilotMediaFixture) do(t *testing.T, method, path string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.PilotAuthHeader, "token-ws1")
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestPilotMedia_UploadCallbackAndFingerprints(t *testing.T) {
+	f := setupPilotMedia(t)
+	key := "workspaces/ws1/DJI_0001.JPG"
+
+	upload := PilotMediaUploadRequest{
+		Name:        "DJI_0001.JPG",
+		ObjectKey:   key,
+		Path:        "survey",
+		Fingerprint: "fp-1",
+		Ext:         file.Ext{DroneModelKey: "0-67-0", IsOriginal: true, SN: "drone-sn", TinnyFingerprint: "tiny-1"},
+		Metadata:    file.MediaMetadata{ShootPosition: file.ShootPosition{Lat: 22.5, Lng: 113.9}},
+	}
+
+	w := f.do(t, http.MethodPost, "/media/api/v1/workspaces/ws1/upload-callback", upload)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "object must exist in the store")
+
+	// Pilot 2 uploads straight to the bucket with its temporary credentials
+	req, err := http.NewRequest(http.MethodPut, f.bucketURL+key, bytes.NewReader([]byte("jpeg")))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	w = f.do(t, http.MethodPost, "/media/api/v1/workspaces/ws1/upload-callback", upload)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var objectKey string
+	decodePilot(t, w, &objectKey)
+	assert.Equal(t, key, objectKey)
+
+	upload.ObjectKey = "workspaces/ws2/DJI_0001.JPG"
+	w = f.do(t, http.MethodPost, "/media/api/v1/workspaces/ws1/upload-callback", upload)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = f.do(t, http.MethodPost, "/media/api/v1/workspaces/ws1/fast-upload", PilotFastUploadRequest{Fingerprint: "fp-1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = f.do(t, http.MethodPost, "/media/api/v1/workspaces/ws1/fast-upload", PilotFastUploadRequest{Fingerprint: "fp-2"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = f.do(t, http.MethodPost, "/media/api/v1/workspaces/ws1/files/tiny-fingerprints",
+		PilotTinyFingerprintsRequest{TinyFingerprints: []string{"tiny-1", "tiny-2"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	var tiny PilotTinyFingerprintsResponse
+	decodePilot(t, w, &tiny)
+	assert.Equal(t, []string{"tiny-1"}, tiny.TinyFingerprints)
+
+	w = f.do(t, http.MethodPost, "/media/api/v1/workspaces/ws1/group-upload-callback",
+		PilotGroupUploadRequest{FileGroupID: "g1", FileCount: 1, FileUploadedCount: 1})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestMedia_ListsDockAndPilotTogether(t *testing.T) {
+	f := setupPilotMedia(t)
+	ctx := context.Background()
+
+	_, err := f.service.RecordDockUpload(ctx, "dock-sn", &file.UploadCallbackData{
+		File: file.Info{ObjectKey: "media/f1/a.jpg", Name: "a.jpg", Ext: file.Ext{FlightID: "f1"}},
+	})
+	require.NoError(t, err)
+	_, err = f.service.Record(ctx, &models.MediaFile{WorkspaceID: "ws1", Source: models.MediaSourcePilot, Name: "b.jpg", ObjectKey: "workspaces/ws1/b.jpg"})
+	require.NoError(t, err)
+
+	w := f.do(t, http.MethodGet, "/api/v1/media", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListMediaFilesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, int64(2), list.Total)
+
+	w = f.do(t, http.MethodGet, "/api/v1/media?source=dock", nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Files, 1)
+	assert.Equal(t, "dock-sn", list.Files[0].DeviceSN)
+
+	w = f.do(t, http.MethodGet, "/api/v1/media/999", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+// pilotObjectPrefix is the object key prefix Pilot 2 uploads of a workspace
+// are confined to. Wayline and media uploads share one set of temporary
+// credentials, so they share the prefix too.
+func pilotObjectPrefix(workspaceID string) string {
+	return "workspaces/" + workspaceID + "/"
+}
+
+// PilotStorage issues object storage credentials to DJI Pilot 2
+type PilotStorage struct {
+	credentials objectstore.CredentialProvider
+	logger      *logrus.Entry
+}
+
+// NewPilotStorage creates a new Pilot storage handler. credentials may be nil,
+// in which case the temporary credential endpoint reports it is unavailable.
+func NewPilotStorage(credentials objectstore.CredentialProvider, logger *logrus.Entry) *PilotStorage {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &PilotStorage{
+		credentials: credentials,
+		logger:      logger.WithField("handler", "pilot_storage"),
+	}
+}
+
+// TemporaryCredential returns object storage credentials for direct uploads
+// of wayline and media files
+// @Summary Obtain temporary credential (Pilot 2)
+// @Tags pilot
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Success 200 {object} PilotResponse
+// @Failure 503 {object} PilotResponse
+// @Router /storage/api/v1/workspaces/{workspace_id}/sts [post]
+func (h *PilotStorage) TemporaryCredential(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok {
+		return
+	}
+	if h.credentials == nil {
+		pilotError(c, http.StatusServiceUnavailable, "temporary credentials not configured")
+		return
+	}
+
+	creds, err := h.credentials.TemporaryCredentials(c.Request.Context(), pilotObjectPrefix(identity.WorkspaceID))
+	if errors.Is(err, objectstore.ErrCredentialsUnavailable) {
+		pilotError(c, http.StatusServiceUnavailable, "temporary credentials not configured")
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to issue temporary credentials")
+		pilotError(c, http.StatusInternalServerError, "failed to issue temporary credentials")
+		return
+	}
+
+	pilotOK(c, gin.H{
+		"bucket":            creds.Bucket,
+		"endpoint":          creds.Endpoint,
+		"provider":          creds.Provider,
+		"region":            creds.Region,
+		"object_key_prefix": creds.ObjectKeyPrefix,
+		"credentials": gin.H{
+			"access_key_id":     creds.AccessKeyID,
+			"access_key_secret": creds.AccessKeySecret,
+			"security_token":    creds.SecurityToken,
+			"expire":            creds.Expire,
+		},
+	})
+}
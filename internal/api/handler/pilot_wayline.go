@@ -0,0 +1,362 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/wayline"
+	"github.com/utmos/utmos/pkg/adapter/dji/wpml"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+// pilotDownloadURLTTL is how long wayline download addresses stay valid.
+const pilotDownloadURLTTL = 15 * time.Minute
+
+// pilotTemplateTypes maps WPML template types to the integers Pilot 2 uses.
+var pilotTemplateTypes = map[wpml.TemplateType]int{
+	wpml.TemplateTypeWaypoint:     0,
+	wpml.TemplateTypeMapping2D:    1,
+	wpml.TemplateTypeMapping3D:    2,
+	wpml.TemplateTypeMappingStrip: 4,
+}
+
+// pilotListOrders whitelists the order_by values accepted from Pilot 2.
+var pilotListOrders = map[string]string{
+	"update_time desc": "updated_at DESC",
+	"update_time asc":  "updated_at ASC",
+	"create_time desc": "created_at DESC",
+	"create_time asc":  "created_at ASC",
+	"name desc":        "name DESC",
+	"name asc":         "name ASC",
+}
+
+// PilotWayline implements the DJI Pilot 2 wayline management HTTPS APIs
+type PilotWayline struct {
+	db      *gorm.DB
+	service *wayline.Service
+	logger  *logrus.Entry
+}
+
+// NewPilotWayline creates a new Pilot wayline handler
+func NewPilotWayline(db *gorm.DB, service *wayline.Service, logger *logrus.Entry) *PilotWayline {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &PilotWayline{
+		db:      db,
+		service: service,
+		logger:  logger.WithField("handler", "pilot_wayline"),
+	}
+}
+
+// PilotWaylineFile is a wayline entry in the Pilot 2 wayline list
+type PilotWaylineFile struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	DroneModelKey    string   `json:"drone_model_key"`
+	PayloadModelKeys []string `json:"payload_model_keys"`
+	TemplateTypes    []int    `json:"template_types"`
+	Sign             string   `json:"sign"`
+	Favorited        bool     `json:"favorited"`
+	UserName         string   `json:"user_name"`
+	CreateTime       int64    `json:"create_time"`
+	UpdateTime       int64    `json:"update_time"`
+}
+
+// PilotUploadCallbackRequest is reported by Pilot 2 after uploading a wayline
+// to the object store with temporary credentials
+type PilotUploadCallbackRequest struct {
+	Name      string `json:"name" binding:"required"`
+	ObjectKey string `json:"object_key" binding:"required"`
+}
+
+func toPilotWaylineFile(w *models.Wayline) PilotWaylineFile {
+	file := PilotWaylineFile{
+		ID:               strconv.FormatUint(uint64(w.ID), 10),
+		Name:             w.Name,
+		DroneModelKey:    fmt.Sprintf("0-%d-%d", w.DroneModel, w.DroneSubModel),
+		PayloadModelKeys: []string{fmt.Sprintf("1-%d-0", w.PayloadModel)},
+		TemplateTypes:    []int{},
+		Sign:             w.Fingerprint,
+		Favorited:        w.Favorited,
+		UserName:         w.Username,
+		CreateTime:       w.CreatedAt.UnixMilli(),
+		UpdateTime:       w.UpdatedAt.UnixMilli(),
+	}
+	if t, ok := pilotTemplateTypes[wpml.TemplateType(w.TemplateType)]; ok {
+		file.TemplateTypes = append(file.TemplateTypes, t)
+	}
+	return file
+}
+
+func (h *PilotWayline) requireService(c *gin.Context) bool {
+	if h.service == nil {
+		pilotError(c, http.StatusServiceUnavailable, "wayline storage not available")
+		return false
+	}
+	return true
+}
+
+// List returns the workspace's wayline files
+// @Summary Obtain wayline file list (Pilot 2)
+// @Tags pilot
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Param order_by query string false "e.g. update_time desc"
+// @Param favorited query bool false "Only favourites"
+// @Param template_type query []int false "Template types" collectionFormat(multi)
+// @Param key query string false "Name search"
+// @Success 200 {object} PilotResponse
+// @Router /wayline/api/v1/workspaces/{workspace_id}/waylines [get]
+func (h *PilotWayline) List(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok {
+		return
+	}
+	page, pageSize, offset := parsePagination(c, 10, 100)
+
+	query := h.db.Model(&models.Wayline{}).Where("workspace_id = ?", identity.WorkspaceID)
+	if favorited, err := strconv.ParseBool(c.Query("favorited")); err == nil {
+		query = query.Where("favorited = ?", favorited)
+	}
+	if key := c.Query("key"); key != "" {
+		query = query.Where("name LIKE ?", "%"+key+"%")
+	}
+	if types := c.QueryArray("template_type"); len(types) > 0 {
+		var names []string
+		for _, raw := range types {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				continue
+			}
+			for templateType, value := range pilotTemplateTypes {
+				if value == n {
+					names = append(names, string(templateType))
+				}
+			}
+		}
+		query = query.Where("template_type IN ?", names)
+	}
+
+	order := "updated_at DESC"
+	if o, ok := pilotListOrders[strings.ToLower(strings.TrimSpace(c.Query("order_by")))]; ok {
+		order = o
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		h.logger.WithError(err).Error("Failed to count waylines")
+		pilotError(c, http.StatusInternalServerError, "failed to list waylines")
+		return
+	}
+
+	var waylines []models.Wayline
+	if err := query.Order(order).Offset(offset).Limit(pageSize).Find(&waylines).Error; err != nil {
+		h.logger.WithError(err).Error("Failed to list waylines")
+		pilotError(c, http.StatusInternalServerError, "failed to list waylines")
+		return
+	}
+
+	files := make([]PilotWaylineFile, len(waylines))
+	for i := range waylines {
+		files[i] = toPilotWaylineFile(&waylines[i])
+	}
+	pilotOK(c, PilotPage{
+		List:       files,
+		Pagination: PilotPagination{Page: page, PageSize: pageSize, Total: total},
+	})
+}
+
+// DownloadURL redirects to a time-limited download address for a wayline file
+// @Summary Obtain wayline file download address (Pilot 2)
+// @Tags pilot
+// @Param workspace_id path string true "Workspace ID"
+// @Param wayline_id path string true "Wayline ID"
+// @Success 302
+// @Failure 404 {object} PilotResponse
+// @Router /wayline/api/v1/workspaces/{workspace_id}/waylines/{wayline_id}/url [get]
+func (h *PilotWayline) DownloadURL(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("wayline_id"), 10, 64)
+	if err != nil {
+		pilotError(c, http.StatusBadRequest, "invalid wayline_id")
+		return
+	}
+
+	var w models.Wayline
+	err = h.db.Where("workspace_id = ?", identity.WorkspaceID).First(&w, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pilotError(c, http.StatusNotFound, "wayline not found")
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get wayline")
+		pilotError(c, http.StatusInternalServerError, "failed to get wayline")
+		return
+	}
+
+	presigner, ok := h.service.Store().(objectstore.Presigner)
+	if !ok {
+		pilotError(c, http.StatusServiceUnavailable, "object store cannot issue download addresses")
+		return
+	}
+	url, err := presigner.PresignGet(c.Request.Context(), w.ObjectKey, pilotDownloadURLTTL)
+	if errors.Is(err, objectstore.ErrNotFound) {
+		pilotError(c, http.StatusNotFound, "wayline file not found")
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to presign wayline download")
+		pilotError(c, http.StatusInternalServerError, "failed to get download address")
+		return
+	}
+
+	c.Redirect(http.StatusFound, url)
+}
+
+// DuplicateNames reports which names already exist in the workspace
+// @Summary Obtain duplicated wayline names (Pilot 2)
+// @Tags pilot
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param name query []string true "Names" collectionFormat(multi)
+// @Success 200 {object} PilotResponse
+// @Router /wayline/api/v1/workspaces/{workspace_id}/waylines/duplicate-names [get]
+func (h *PilotWayline) DuplicateNames(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	names := c.QueryArray("name")
+	if len(names) == 0 {
+		pilotError(c, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	duplicates, err := h.service.DuplicateNames(c.Request.Context(), identity.WorkspaceID, names)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to check wayline names")
+		pilotError(c, http.StatusInternalServerError, "failed to check names")
+		return
+	}
+	pilotOK(c, duplicates)
+}
+
+// UploadCallback records a wayline file Pilot 2 uploaded to the object store
+// @Summary Report wayline file upload result (Pilot 2)
+// @Tags pilot
+// @Accept json
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param body body PilotUploadCallbackRequest true "Upload result"
+// @Success 200 {object} PilotResponse
+// @Failure 400 {object} PilotResponse
+// @Router /wayline/api/v1/workspaces/{workspace_id}/upload-callback [post]
+func (h *PilotWayline) UploadCallback(c *gin.Context) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	var req PilotUploadCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pilotError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	objectKey, err := objectstore.CleanKey(req.ObjectKey)
+	if err != nil || !strings.HasPrefix(objectKey, pilotObjectPrefix(identity.WorkspaceID)) {
+		pilotError(c, http.StatusBadRequest, "object_key is outside the workspace upload prefix")
+		return
+	}
+
+	name := strings.TrimSuffix(req.Name, ".kmz")
+	owner := wayline.Owner{WorkspaceID: identity.WorkspaceID, Username: identity.Username}
+	w, err := h.service.RegisterUploaded(c.Request.Context(), owner, name, objectKey)
+
+	var validationErr *wayline.ValidationError
+	switch {
+	case err == nil:
+		pilotOK(c, toPilotWaylineFile(w))
+	case errors.Is(err, objectstore.ErrNotFound):
+		pilotError(c, http.StatusBadRequest, "uploaded object not found")
+	case errors.Is(err, wayline.ErrDuplicateName):
+		pilotError(c, http.StatusConflict, "wayline name already exists")
+	case errors.Is(err, wayline.ErrInvalidKMZ):
+		pilotError(c, http.StatusBadRequest, err.Error())
+	case errors.As(err, &validationErr):
+		pilotError(c, http.StatusBadRequest, validationErr.Error())
+	default:
+		h.logger.WithError(err).Error("Failed to register uploaded wayline")
+		pilotError(c, http.StatusInternalServerError, "failed to register wayline")
+	}
+}
+
+// Favorite adds waylines to the favourites
+// @Summary Batch favorite waylines (Pilot 2)
+// @Tags pilot
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param id query []string true "Wayline IDs" collectionFormat(multi)
+// @Success 200 {object} PilotResponse
+// @Router /wayline/api/v1/workspaces/{workspace_id}/favorites [post]
+func (h *PilotWayline) Favorite(c *gin.Context) {
+	h.setFavorite(c, true)
+}
+
+// Unfavorite removes waylines from the favourites
+// @Summary Batch unfavorite waylines (Pilot 2)
+// @Tags pilot
+// @Produce json
+// @Param workspace_id path string true "Workspace ID"
+// @Param id query []string true "Wayline IDs" collectionFormat(multi)
+// @Success 200 {object} PilotResponse
+// @Router /wayline/api/v1/workspaces/{workspace_id}/favorites [delete]
+func (h *PilotWayline) Unfavorite(c *gin.Context) {
+	h.setFavorite(c, false)
+}
+
+func (h *PilotWayline) setFavorite(c *gin.Context, favorite bool) {
+	identity, ok := requirePilotIdentity(c)
+	if !ok || !h.requireService(c) {
+		return
+	}
+
+	raw := c.QueryArray("id")
+	if len(raw) == 0 {
+		pilotError(c, http.StatusBadRequest, "id is required")
+		return
+	}
+	ids := make([]uint, 0, len(raw))
+	for _, r := range raw {
+		id, err := strconv.ParseUint(r, 10, 64)
+		if err != nil {
+			pilotError(c, http.StatusBadRequest, "invalid id: "+r)
+			return
+		}
+		ids = append(ids, uint(id))
+	}
+
+	if err := h.service.SetFavorite(c.Request.Context(), identity.WorkspaceID, ids, favorite); err != nil {
+		h.logger.WithError(err).Error("Failed to update favourites")
+		pilotError(c, http.StatusInternalServerError, "failed to update favourites")
+		return
+	}
+	pilotOK(c, nil)
+}
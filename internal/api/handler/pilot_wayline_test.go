@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/api/middleware"
+	"github.com/utmos/utmos/internal/wayline"
+	"github.com/utmos/utmos/pkg/adapter/dji/wpml"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+// stubCredentials hands out fixed credentials for the requested prefix.
+type stubCredentials objectstore.Credentials
+
+func (s stubCredentials) TemporaryCredentials(_ context.Context, prefix string) (*objectstore.Credentials, error) {
+	creds := objectstore.Credentials(s)
+	creds.ObjectKeyPrefix = prefix
+	creds.Expire = 3600
+	return &creds, nil
+}
+
+type pilotWaylineFixture struct {
+	router  *gin.Engine
+	service *wayline.Service
+	store   *objectstore.LocalStore
+}
+
+func setupPilotWayline(t *testing.T) *pilotWaylineFixture {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Wayline{}, &models.WaylineRevision{}))
+
+	store, err := objectstore.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	signer := objectstore.NewURLSigner("http://localhost/objects", []byte("secret"))
+	store.SetURLSigner(signer)
+
+	svc, err := wayline.NewService(db, store, nil)
+	require.NoError(t, err)
+
+	creds := stubCredentials{Provider: "minio", Endpoint: "http://minio:9000", Bucket: "utmos", AccessKeyID: "ak", AccessKeySecret: "sk"}
+	h := NewPilotWayline(db, svc, nil)
+	storage := NewPilotStorage(creds, nil)
+	objects := NewObject(store, signer, nil)
+
+	tokens := middleware.StaticPilotTokens{
+		"token-ws1": {WorkspaceID: "ws1", Username: "alice"},
+		"token-ws2": {WorkspaceID: "ws2", Username: "bob"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	auth := middleware.PilotAuth(tokens, nil)
+	wl := router.Group("/wayline/api/v1/workspaces/:workspace_id", auth)
+	wl.GET("/waylines", h.List)
+	wl.GET("/waylines/duplicate-names", h.DuplicateNames)
+	wl.GET("/waylines/:wayline_id/url", h.DownloadURL)
+	wl.POST("/upload-callback", h.UploadCallback)
+	wl.POST("/favorites", h.Favorite)
+	wl.DELETE("/favorites", h.Unfavorite)
+	router.POST("/storage/api/v1/workspaces/:workspace_id/sts", auth, storage.TemporaryCredential)
+	router.GET("/objects/*key", objects.Download)
+
+	return &pilotWaylineFixture{router: router, service: svc, store: store}
+}
+
+func (f *pilotWaylineFixture) do(t *testing.T, method, path, token string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.PilotAuthHeader, token)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func decodePilot(t *testing.T, w *httptest.ResponseRecorder, data any) PilotResponse {
+	var resp PilotResponse
+	if data != nil {
+		resp.Data = data
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func (f *pilotWaylineFixture) create(t *testing.T, workspaceID, name string) *models.Wayline {
+	w, err := f.service.CreateFromWaylines(context.Background(), wayline.Owner{WorkspaceID: workspaceID, Username: "alice"}, sampleWaylines(name))
+	require.NoError(t, err)
+	return w
+}
+
+func TestPilotWayline_ListIsWorkspaceScoped(t *testing.T) {
+	f := setupPilotWayline(t)
+	f.create(t, "ws1", "north")
+	f.create(t, "ws1", "south")
+	f.create(t, "ws2", "other")
+
+	w := f.do(t, http.MethodGet, "/wayline/api/v1/workspaces/ws1/waylines?order_by=name%20asc", "token-ws1", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var page struct {
+		List       []PilotWaylineFile `json:"list"`
+		Pagination PilotPagination    `json:"pagination"`
+	}
+	resp := decodePilot(t, w, &page)
+	assert.Equal(t, 0, resp.Code)
+	assert.Equal(t, int64(2), page.Pagination.Total)
+	require.Len(t, page.List, 2)
+	assert.Equal(t, "north", page.List[0].Name)
+	assert.Equal(t, fmt.Sprintf("0-%d-0", wpml.DroneM3DSeries), page.List[0].DroneModelKey)
+	assert.Equal(t, []int{0}, page.List[0].TemplateTypes)
+	assert.Equal(t, "alice", page.List[0].UserName)
+	assert.Len(t, page.List[0].Sign, 32)
+
+	w = f.do(t, http.MethodGet, "/wayline/api/v1/workspaces/ws1/waylines", "token-ws2", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestPilotWayline_DuplicateNamesAndFavorites(t *testing.T) {
+	f := setupPilotWayline(t)
+	a := f.create(t, "ws1", "a")
+	b := f.create(t, "ws1", "b")
+	other := f.create(t, "ws2", "c")
+
+	w := f.do(t, http.MethodGet, "/wayline/api/v1/workspaces/ws1/waylines/duplicate-names?name=a&name=c", "token-ws1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var dups []string
+	decodePilot(t, w, &dups)
+	assert.Equal(t, []string{"a"}, dups)
+
+	path := fmt.Sprintf("/wayline/api/v1/workspaces/ws1/favorites?id=%d&id=%d&id=%d", a.ID, b.ID, other.ID)
+	w = f.do(t, http.MethodPost, path, "token-ws1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = f.do(t, http.MethodGet, "/wayline/api/v1/workspaces/ws1/waylines?favorited=true", "token-ws1", nil)
+	var page struct {
+		Pagination PilotPagination `json:"pagination"`
+	}
+	decodePilot(t, w, &page)
+	assert.Equal(t, int64(2), page.Pagination.Total)
+
+	w = f.do(t, http.MethodGet, "/wayline/api/v1/workspaces/ws2/waylines?favorited=true", "token-ws2", nil)
+	decodePilot(t, w, &page)
+	assert.Equal(t, int64(0), page.Pagination.Total, "other workspace untouched")
+
+	w = f.do(t, http.MethodDelete, fmt.Sprintf("/wayline/api/v1/workspaces/ws1/favorites?id=%d", a.ID), "token-ws1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = f.do(t, http.MethodGet, "/wayline/api/v1/workspaces/ws1/waylines?favorited=true", "token-ws1", nil)
+	decodePilot(t, w, &page)
+	assert.Equal(t, int64(1), page.Pagination.Total)
+}
+
+func TestPilotWayline_DownloadURL(t *testing.T) {
+	f := setupPilotWayline(t)
+	wl := f.create(t, "ws1", "route")
+
+	w := f.do(t, http.MethodGet, fmt.Sprintf("/wayline/api/v1/workspaces/ws1/waylines/%d/url", wl.ID), "token-ws1", nil)
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+
+	w = f.do(t, http.MethodGet, location.RequestURI(), "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	_, err = wpml.ParseKMZBuffer(w.Body.Bytes())
+	require.NoError(t, err)
+
+	tampered := location.Path + "?expires=" + location.Query().Get("expires") + "&signature=00"
+	w = f.do(t, http.MethodGet, tampered, "", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Waylines of other workspaces are not visible.
+	w = f.do(t, http.MethodGet, fmt.Sprintf("/wayline/api/v1/workspaces/ws2/waylines/%d/url", wl.ID), "token-ws2", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPilotWayline_TemporaryCredentialAndUploadCallback(t *testing.T) {
+	f := setupPilotWayline(t)
+
+	w := f.do(t, http.MethodPost, "/storage/api/v1/workspaces/ws1/sts", "token-ws1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var sts struct {
+		Bucket          string `json:"bucket"`
+		ObjectKeyPrefix string `json:"object_key_prefix"`
+		Credentials     struct {
+			AccessKeyID string `json:"access_key_id"`
+			Expire      int64  `json:"expire"`
+		} `json:"credentials"`
+	}
+	decodePilot(t, w, &sts)
+	assert.Equal(t, "utmos", sts.Bucket)
+	assert.Equal(t, "workspaces/ws1/", sts.ObjectKeyPrefix)
+	assert.Equal(t, "ak", sts.Credentials.AccessKeyID)
+
+	// Simulate Pilot 2 uploading directly to the bucket.
+	buffer, err := wpml.CreateKmzBufferFromWaylines(sampleWaylines("x"))
+	require.NoError(t, err)
+	key := sts.ObjectKeyPrefix + "pilot-route.kmz"
+	require.NoError(t, f.store.Put(context.Background(), key, bytes.NewReader(buffer.Bytes()), int64(buffer.Len()), wayline.ContentTypeKMZ))
+
+	w = f.do(t, http.MethodPost, "/wayline/api/v1/workspaces/ws1/upload-callback", "token-ws1",
+		PilotUploadCallbackRequest{Name: "pilot-route.kmz", ObjectKey: key})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var file PilotWaylineFile
+	decodePilot(t, w, &file)
+	assert.Equal(t, "pilot-route", file.Name)
+	assert.Equal(t, "alice", file.UserName)
+
+	w = f.do(t, http.MethodPost, "/wayline/api/v1/workspaces/ws1/upload-callback", "token-ws1",
+		PilotUploadCallbackRequest{Name: "pilot-route.kmz", ObjectKey: key})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = f.do(t, http.MethodPost, "/wayline/api/v1/workspaces/ws1/upload-callback", "token-ws1",
+		PilotUploadCallbackRequest{Name: "escape", ObjectKey: "workspaces/ws2/pilot-route.kmz"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = f.do(t, http.MethodPost, "/wayline/api/v1/workspaces/ws1/upload-callback", "token-ws1",
+		PilotUploadCallbackRequest{Name: "missing", ObjectKey: "workspaces/ws1/missing.kmz"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
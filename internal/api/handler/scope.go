@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/api/middleware"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// workspaceScope returns the workspace the caller is confined to. ok is
+// false for callers with access to every workspace.
+func workspaceScope(c *gin.Context) (string, bool) {
+	return middleware.GetWorkspaceScope(c)
+}
+
+// respondWorkspaceForbidden writes a 403 response for resources outside the
+// caller's workspace.
+func respondWorkspaceForbidden(c *gin.Context) {
+	respondError(c, http.StatusForbidden, "WORKSPACE_FORBIDDEN", "Resource is not in the caller's workspace")
+}
+
+// scopeWorkspace restricts query to rows of the caller's workspace.
+func scopeWorkspace(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if workspaceID, ok := workspaceScope(c); ok {
+		return query.Where("workspace_id = ?", workspaceID)
+	}
+	return query
+}
+
+// scopeDevices restricts query to rows whose column holds the serial number
+// of a device in the caller's workspace.
+func scopeDevices(c *gin.Context, db *gorm.DB, query *gorm.DB, column string) *gorm.DB {
+	workspaceID, ok := workspaceScope(c)
+	if !ok {
+		return query
+	}
+	devices := db.Model(&models.Device{}).Select("device_sn").Where("workspace_id = ?", workspaceID)
+	return query.Where(column+" IN (?)", devices)
+}
+
+// requireWorkspace checks that a workspace requested by the caller is
+// accessible and returns the workspace to use: the caller's own when it is
+// confined to one and none was requested. On failure it writes a 403
+// response and returns false.
+func requireWorkspace(c *gin.Context, requested string) (string, bool) {
+	workspaceID, ok := workspaceScope(c)
+	if !ok {
+		return requested, true
+	}
+	if requested != "" && requested != workspaceID {
+		respondWorkspaceForbidden(c)
+		return "", false
+	}
+	return workspaceID, true
+}
+
+// inCallerWorkspace reports whether a resource of the workspace is visible
+// to the caller. Resources outside the caller's workspace are reported as
+// not found rather than forbidden, so their existence is not revealed.
+func inCallerWorkspace(c *gin.Context, workspaceID string) bool {
+	scope, ok := workspaceScope(c)
+	return !ok || scope == workspaceID
+}
+
+// requireDeviceScope checks that the device belongs to the caller's
+// workspace. On failure it writes a 403 response and returns false.
+func requireDeviceScope(c *gin.Context, db *gorm.DB, logger *logrus.Entry, deviceSN string) bool {
+	workspaceID, ok := workspaceScope(c)
+	if !ok {
+		return true
+	}
+
+	var count int64
+	err := db.Model(&models.Device{}).
+		Where("device_sn = ? AND workspace_id = ?", deviceSN, workspaceID).
+		Count(&count).Error
+	if err != nil {
+		respondInternalError(c, logger, err, "Failed to check device workspace", "Failed to check device workspace")
+		return false
+	}
+	if count == 0 {
+		respondWorkspaceForbidden(c)
+		return false
+	}
+	return true
+}
+
+// DeviceScope rejects requests whose :device_sn parameter names a device
+// outside the caller's workspace. It guards handlers that have no database
+// of their own, such as telemetry queries.
+func DeviceScope(db *gorm.DB, logger *logrus.Entry) gin.HandlerFunc {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return func(c *gin.Context) {
+		if deviceSN := c.Param("device_sn"); deviceSN != "" && !requireDeviceScope(c, db, logger, deviceSN) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
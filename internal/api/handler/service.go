@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
@@ -9,10 +10,33 @@ import (
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 
+	"github.com/utmos/utmos/internal/account"
+	"github.com/utmos/utmos/internal/api/middleware"
 	"github.com/utmos/utmos/internal/downlink/dispatcher"
 	"github.com/utmos/utmos/internal/downlink/model"
+	djirouter "github.com/utmos/utmos/pkg/adapter/dji/router"
 )
 
+// flightControlMethods are the service methods that fly an aircraft. Only
+// callers allowed to fly may call them.
+var flightControlMethods = map[string]bool{
+	djirouter.MethodDRCModeEnter:       true,
+	djirouter.MethodDRCModeExit:        true,
+	djirouter.MethodDroneControl:       true,
+	djirouter.MethodDroneEmergencyStop: true,
+	djirouter.MethodFlighttaskCreate:   true,
+	djirouter.MethodFlighttaskPrepare:  true,
+	djirouter.MethodFlighttaskExecute:  true,
+	djirouter.MethodFlighttaskPause:    true,
+	djirouter.MethodFlighttaskRecovery: true,
+	djirouter.MethodFlighttaskUndo:     true,
+	djirouter.MethodReturnHome:         true,
+	djirouter.MethodReturnHomeCancel:   true,
+	"takeoff_to_point":                 true,
+	"fly_to_point":                     true,
+	"fly_to_point_stop":                true,
+}
+
 // Service handles service call API requests
 type Service struct {
 	db         *gorm.DB
@@ -151,6 +175,7 @@ func (h *Service) requireRepository(c *gin.Context) bool {
 // @Param call body ServiceCallRequest true "Service call request"
 // @Success 202 {object} ServiceCallResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/services/call [post]
 func (h *Service) Call(c *gin.Context) {
@@ -158,14 +183,26 @@ func (h *Service) Call(c *gin.Context) {
 	if !bindJSON(c, &req) {
 		return
 	}
+	if flightControlMethods[req.Method] && !middleware.HasPermission(c, account.PermissionFlightControl) {
+		middleware.AbortForbidden(c, account.PermissionFlightControl)
+		return
+	}
+	if h.db != nil && !requireDeviceScope(c, h.db, h.logger, req.DeviceSN) {
+		return
+	}
 
-	// Create dispatcher service call
+	// Create dispatcher service call, on behalf of the caller's workspace
 	dispatcherCall := toDispatcherServiceCall(&req)
+	dispatcherCall.WorkspaceID, _ = workspaceScope(c)
 
 	// Dispatch the call
 	if h.dispatcher != nil {
 		ctx := c.Request.Context()
 		result, err := h.dispatcher.Handle(ctx, dispatcherCall)
+		if errors.Is(err, dispatcher.ErrWorkspaceForbidden) {
+			respondWorkspaceForbidden(c)
+			return
+		}
 		if err != nil {
 			logWithTrace(h.logger, c.Request.Context()).WithError(err).WithFields(logrus.Fields{
 				"device_sn": req.DeviceSN,
@@ -268,6 +305,9 @@ func (h *Service) Get(c *gin.Context) {
 		"Failed to get service call", "Failed to get service call") {
 		return
 	}
+	if !requireDeviceScope(c, h.db, h.logger, call.DeviceSN) {
+		return
+	}
 
 	c.JSON(http.StatusOK, toServiceCallResponse(call))
 }
@@ -288,7 +328,7 @@ func (h *Service) ListByDevice(c *gin.Context) {
 		return
 	}
 
-	if !h.requireRepository(c) {
+	if !h.requireRepository(c) || !requireDeviceScope(c, h.db, h.logger, deviceSN) {
 		return
 	}
 
@@ -339,6 +379,9 @@ func (h *Service) Cancel(c *gin.Context) {
 		"Failed to get service call", "Failed to cancel service call") {
 		return
 	}
+	if !requireDeviceScope(c, h.db, h.logger, call.DeviceSN) {
+		return
+	}
 
 	// Check if call can be cancelled
 	if call.IsCompleted() {
@@ -0,0 +1,448 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/api/middleware"
+	"github.com/utmos/utmos/internal/unlocklicense"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// UnlockLicense handles FlySafe unlock licences of a workspace: licence
+// files, pushing them to docks, the licences docks report and the audit
+// trail of changes.
+type UnlockLicense struct {
+	service *unlocklicense.Service
+	logger  *logrus.Entry
+}
+
+// NewUnlockLicense creates a new unlock licence handler
+func NewUnlockLicense(service *unlocklicense.Service, logger *logrus.Entry) *UnlockLicense {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &UnlockLicense{
+		service: service,
+		logger:  logger.WithField("handler", "unlock_license"),
+	}
+}
+
+// UnlockLicenseFileResponse represents an uploaded licence file
+type UnlockLicenseFileResponse struct {
+	ID          string `json:"id"`
+	WorkspaceID string `json:"workspace_id"`
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+	Size        int64  `json:"size"`
+	UploadedBy  string `json:"uploaded_by,omitempty"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ListUnlockLicenseFilesResponse represents the licence files of a workspace
+type ListUnlockLicenseFilesResponse struct {
+	Files []UnlockLicenseFileResponse `json:"files"`
+}
+
+// PushUnlockLicenseRequest represents the request body for pushing a
+// licence file to docks
+type PushUnlockLicenseRequest struct {
+	DeviceSNs []string `json:"device_sns" binding:"required,min=1,dive,required"`
+}
+
+// SwitchUnlockLicenseRequest represents the request body for enabling or
+// disabling a licence
+type SwitchUnlockLicenseRequest struct {
+	Enable *bool `json:"enable" binding:"required"`
+}
+
+// RefreshUnlockLicensesRequest represents the request body for asking a dock
+// to report its licences; device_model_domain is 0 for the aircraft and 3
+// for the dock
+type RefreshUnlockLicensesRequest struct {
+	DeviceModelDomain int `json:"device_model_domain"`
+}
+
+// DeviceUnlockLicensesResponse represents the licences a dock last reported
+// for a device model domain
+type DeviceUnlockLicensesResponse struct {
+	DeviceSN          string          `json:"device_sn"`
+	DeviceModelDomain int             `json:"device_model_domain"`
+	Consistence       bool            `json:"consistence"`
+	Licenses          json.RawMessage `json:"licenses"`
+	UpdatedAt         string          `json:"updated_at"`
+}
+
+// ListDeviceUnlockLicensesResponse represents the licences of a dock
+type ListDeviceUnlockLicensesResponse struct {
+	Domains []DeviceUnlockLicensesResponse `json:"domains"`
+}
+
+// UnlockLicenseAuditResponse represents an audited licence change
+type UnlockLicenseAuditResponse struct {
+	ID          uint    `json:"id"`
+	Action      string  `json:"action"`
+	Status      string  `json:"status"`
+	DeviceSN    string  `json:"device_sn,omitempty"`
+	FileID      string  `json:"file_id,omitempty"`
+	LicenseID   int     `json:"license_id,omitempty"`
+	ActorID     string  `json:"actor_id,omitempty"`
+	ActorName   string  `json:"actor_name,omitempty"`
+	Result      int     `json:"result"`
+	Error       string  `json:"error,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+	CompletedAt *string `json:"completed_at,omitempty"`
+}
+
+// ListUnlockLicenseAuditsResponse represents the audit trail of a workspace
+type ListUnlockLicenseAuditsResponse struct {
+	Audits []UnlockLicenseAuditResponse `json:"audits"`
+}
+
+func toUnlockLicenseFileResponse(f *models.UnlockLicenseFile) UnlockLicenseFileResponse {
+	return UnlockLicenseFileResponse{
+		ID:          f.ID,
+		WorkspaceID: f.WorkspaceID,
+		Name:        f.Name,
+		Fingerprint: f.Fingerprint,
+		Size:        f.Size,
+		UploadedBy:  f.UploadedBy,
+		CreatedAt:   f.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func toUnlockLicenseAuditResponse(a *models.UnlockLicenseAudit) UnlockLicenseAuditResponse {
+	resp := UnlockLicenseAuditResponse{
+		ID:        a.ID,
+		Action:    a.Action,
+		Status:    a.Status,
+		DeviceSN:  a.DeviceSN,
+		FileID:    a.FileID,
+		LicenseID: a.LicenseID,
+		ActorID:   a.ActorID,
+		ActorName: a.ActorName,
+		Result:    a.Result,
+		Error:     a.Error,
+		CreatedAt: a.CreatedAt.Format(time.RFC3339),
+	}
+	if a.CompletedAt != nil {
+		t := a.CompletedAt.Format(time.RFC3339)
+		resp.CompletedAt = &t
+	}
+	return resp
+}
+
+func toUnlockLicenseAuditsResponse(audits []models.UnlockLicenseAudit) ListUnlockLicenseAuditsResponse {
+	resp := ListUnlockLicenseAuditsResponse{Audits: make([]UnlockLicenseAuditResponse, len(audits))}
+	for i := range audits {
+		resp.Audits[i] = toUnlockLicenseAuditResponse(&audits[i])
+	}
+	return resp
+}
+
+// ListFiles lists the licence files of a workspace
+// @Summary List unlock licence files
+// @Tags unlock-licenses
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Success 200 {object} ListUnlockLicenseFilesResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/unlock-licenses/files [get]
+func (h *UnlockLicense) ListFiles(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+
+	files, err := h.service.ListFiles(c.Request.Context(), workspaceID)
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to list licence files", "Failed to list licence files")
+		return
+	}
+	resp := ListUnlockLicenseFilesResponse{Files: make([]UnlockLicenseFileResponse, len(files))}
+	for i := range files {
+		resp.Files[i] = toUnlockLicenseFileResponse(&files[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// UploadFile uploads an offline licence file
+// @Summary Upload an unlock licence file
+// @Tags unlock-licenses
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Param file formData file true "Licence file"
+// @Success 201 {object} UnlockLicenseFileResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/unlock-licenses/files [post]
+func (h *UnlockLicense) UploadFile(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		respondBadRequest(c, "INVALID_REQUEST", "file is required")
+		return
+	}
+	if fileHeader.Size > unlocklicense.MaxFileSize {
+		respondError(c, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", "Licence file is too large")
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		respondBadRequest(c, "INVALID_REQUEST", "failed to open uploaded file")
+		return
+	}
+	defer func() { _ = file.Close() }()
+	data, err := io.ReadAll(io.LimitReader(file, unlocklicense.MaxFileSize))
+	if err != nil {
+		respondBadRequest(c, "INVALID_REQUEST", "failed to read uploaded file")
+		return
+	}
+
+	uploaded, err := h.service.Upload(c.Request.Context(), workspaceID, callerActor(c), fileHeader.Filename, data)
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to upload licence file")
+		return
+	}
+	c.JSON(http.StatusCreated, toUnlockLicenseFileResponse(uploaded))
+}
+
+// DeleteFile deletes a licence file
+// @Summary Delete an unlock licence file
+// @Tags unlock-licenses
+// @Param id path string true "Workspace ID"
+// @Param file_id path string true "Licence file ID"
+// @Success 204
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/unlock-licenses/files/{file_id} [delete]
+func (h *UnlockLicense) DeleteFile(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteFile(c.Request.Context(), workspaceID, callerActor(c), c.Param("file_id")); err != nil {
+		h.respondServiceError(c, err, "Failed to delete licence file")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// PushFile sends a licence file to docks of the workspace
+// @Summary Push an unlock licence file to docks
+// @Description Docks reply asynchronously; the returned audit entries stay pending until they do.
+// @Tags unlock-licenses
+// @Accept json
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Param file_id path string true "Licence file ID"
+// @Param push body PushUnlockLicenseRequest true "Target docks"
+// @Success 202 {object} ListUnlockLicenseAuditsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/unlock-licenses/files/{file_id}/push [post]
+func (h *UnlockLicense) PushFile(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+	var req PushUnlockLicenseRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	audits, err := h.service.Push(c.Request.Context(), workspaceID, callerActor(c), c.Param("file_id"), req.DeviceSNs)
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to push licence file")
+		return
+	}
+	c.JSON(http.StatusAccepted, toUnlockLicenseAuditsResponse(audits))
+}
+
+// ListDeviceLicenses lists the licences a dock last reported
+// @Summary List the unlock licences of a dock
+// @Tags unlock-licenses
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Param device_sn path string true "Dock serial number"
+// @Success 200 {object} ListDeviceUnlockLicensesResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/devices/{device_sn}/unlock-licenses [get]
+func (h *UnlockLicense) ListDeviceLicenses(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+
+	licenses, err := h.service.Licenses(c.Request.Context(), workspaceID, c.Param("device_sn"))
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to list device licences")
+		return
+	}
+	resp := ListDeviceUnlockLicensesResponse{Domains: make([]DeviceUnlockLicensesResponse, len(licenses))}
+	for i := range licenses {
+		resp.Domains[i] = DeviceUnlockLicensesResponse{
+			DeviceSN:          licenses[i].DeviceSN,
+			DeviceModelDomain: licenses[i].DeviceModelDomain,
+			Consistence:       licenses[i].Consistence,
+			Licenses:          json.RawMessage(licenses[i].Licenses),
+			UpdatedAt:         licenses[i].UpdatedAt.Format(time.RFC3339),
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RefreshDeviceLicenses asks a dock to report its licences
+// @Summary Refresh the unlock licences of a dock
+// @Description The dock replies asynchronously; list its licences again once it has.
+// @Tags unlock-licenses
+// @Accept json
+// @Param id path string true "Workspace ID"
+// @Param device_sn path string true "Dock serial number"
+// @Param refresh body RefreshUnlockLicensesRequest true "Device model domain"
+// @Success 202
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/devices/{device_sn}/unlock-licenses/refresh [post]
+func (h *UnlockLicense) RefreshDeviceLicenses(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+	var req RefreshUnlockLicensesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.service.Refresh(c.Request.Context(), workspaceID, c.Param("device_sn"), req.DeviceModelDomain); err != nil {
+		h.respondServiceError(c, err, "Failed to refresh device licences")
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// SwitchDeviceLicense enables or disables a licence on a dock
+// @Summary Enable or disable an unlock licence
+// @Description The dock replies asynchronously; the returned audit entry stays pending until it does.
+// @Tags unlock-licenses
+// @Accept json
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Param device_sn path string true "Dock serial number"
+// @Param license_id path int true "Licence ID"
+// @Param switch body SwitchUnlockLicenseRequest true "Licence state"
+// @Success 202 {object} UnlockLicenseAuditResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/devices/{device_sn}/unlock-licenses/{license_id} [put]
+func (h *UnlockLicense) SwitchDeviceLicense(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+	licenseID, err := strconv.Atoi(c.Param("license_id"))
+	if err != nil {
+		respondBadRequest(c, "INVALID_ID", "Invalid licence ID")
+		return
+	}
+	var req SwitchUnlockLicenseRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	audit, err := h.service.Switch(c.Request.Context(), workspaceID, callerActor(c), c.Param("device_sn"), licenseID, *req.Enable)
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to switch device licence")
+		return
+	}
+	c.JSON(http.StatusAccepted, toUnlockLicenseAuditResponse(audit))
+}
+
+// ListAudits lists the audit trail of licence changes in a workspace
+// @Summary List unlock licence changes
+// @Tags unlock-licenses
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Param device_sn query string false "Filter by dock serial number"
+// @Param limit query int false "Maximum number of entries" default(100)
+// @Success 200 {object} ListUnlockLicenseAuditsResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/unlock-licenses/audits [get]
+func (h *UnlockLicense) ListAudits(c *gin.Context) {
+	workspaceID, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+
+	audits, err := h.service.Audits(c.Request.Context(), workspaceID, unlocklicense.AuditFilter{
+		DeviceSN: c.Query("device_sn"),
+		Limit:    parseLimit(c, 100, 1000),
+	})
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to list licence audits", "Failed to list licence audits")
+		return
+	}
+	c.JSON(http.StatusOK, toUnlockLicenseAuditsResponse(audits))
+}
+
+// callerActor identifies the caller for the audit trail.
+func callerActor(c *gin.Context) unlocklicense.Actor {
+	identity, ok := middleware.GetIdentity(c)
+	if !ok {
+		return unlocklicense.Actor{}
+	}
+	return unlocklicense.Actor{ID: identity.Subject, Name: identity.Name}
+}
+
+// requireAccess returns the :id workspace when the service is available and
+// the caller may access it.
+func (h *UnlockLicense) requireAccess(c *gin.Context) (string, bool) {
+	if h.service == nil {
+		respondServiceUnavailable(c, "Unlock licence service not available")
+		return "", false
+	}
+	id, ok := requireStringParam(c, "id", "INVALID_ID", "Workspace ID is required")
+	if !ok {
+		return "", false
+	}
+	return requireWorkspace(c, id)
+}
+
+func (h *UnlockLicense) respondServiceError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, unlocklicense.ErrInvalidFile):
+		respondBadRequest(c, "INVALID_LICENSE_FILE", err.Error())
+	case errors.Is(err, unlocklicense.ErrInvalidDomain):
+		respondBadRequest(c, "INVALID_DEVICE_MODEL_DOMAIN", err.Error())
+	case errors.Is(err, unlocklicense.ErrNotDock):
+		respondBadRequest(c, "NOT_A_DOCK", err.Error())
+	case errors.Is(err, unlocklicense.ErrFileNotFound):
+		respondNotFound(c, "LICENSE_FILE_NOT_FOUND", "Licence file not found")
+	case errors.Is(err, unlocklicense.ErrDeviceNotFound):
+		respondNotFound(c, "DEVICE_NOT_FOUND", err.Error())
+	case errors.Is(err, unlocklicense.ErrDispatchUnavailable):
+		respondServiceUnavailable(c, "Device commands not available")
+	default:
+		respondInternalError(c, h.logger, err, msg, msg)
+	}
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/account"
+	"github.com/utmos/utmos/internal/api/middleware"
+	"github.com/utmos/utmos/internal/downlink/dispatcher"
+	"github.com/utmos/utmos/internal/unlocklicense"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+type acceptingDispatcher struct {
+	calls []*dispatcher.ServiceCall
+}
+
+func (d *acceptingDispatcher) Handle(_ context.Context, call *dispatcher.ServiceCall) (*dispatcher.DispatchResult, error) {
+	d.calls = append(d.calls, call)
+	return &dispatcher.DispatchResult{Success: true}, nil
+}
+
+func setupUnlockLicenseRouter(t *testing.T) (*gin.Engine, *acceptingDispatcher) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Device{}, &models.UnlockLicenseFile{}, &models.DeviceUnlockLicenses{}, &models.UnlockLicenseAudit{}))
+	require.NoError(t, db.Create(&models.Device{DeviceSN: "DOCK1", DeviceName: "Dock", DeviceType: "dock", WorkspaceID: "ws1"}).Error)
+
+	store, err := objectstore.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	store.SetURLSigner(objectstore.NewURLSigner("https://iot.example.com/objects", []byte("secret")))
+	disp := &acceptingDispatcher{}
+	h := NewUnlockLicense(unlocklicense.NewService(db, store, disp, nil), nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetIdentity(c, &account.Identity{Subject: "user:1", Name: "alice", Role: account.RoleAdmin, WorkspaceID: c.GetHeader(scopeHeader)})
+		c.Next()
+	})
+	router.GET("/api/v1/workspaces/:id/unlock-licenses/files", h.ListFiles)
+	router.POST("/api/v1/workspaces/:id/unlock-licenses/files", h.UploadFile)
+	router.DELETE("/api/v1/workspaces/:id/unlock-licenses/files/:file_id", h.DeleteFile)
+	router.POST("/api/v1/workspaces/:id/unlock-licenses/files/:file_id/push", h.PushFile)
+	router.GET("/api/v1/workspaces/:id/unlock-licenses/audits", h.ListAudits)
+	router.GET("/api/v1/workspaces/:id/devices/:device_sn/unlock-licenses", h.ListDeviceLicenses)
+	router.POST("/api/v1/workspaces/:id/devices/:device_sn/unlock-licenses/refresh", h.RefreshDeviceLicenses)
+	router.PUT("/api/v1/workspaces/:id/devices/:device_sn/unlock-licenses/:license_id", h.SwitchDeviceLicense)
+	return router, disp
+}
+
+func TestUnlockLicense_UploadPushAndSwitch(t *testing.T) {
+	router, disp := setupUnlockLicenseRouter(t)
+
+	w := postWaylineFile(t, router, "/api/v1/workspaces/ws1/unlock-licenses/files", "site.lic", []byte("licence"), nil)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var file UnlockLicenseFileResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &file))
+	assert.Equal(t, "alice", file.UploadedBy)
+	assert.Len(t, file.Fingerprint, 32)
+
+	filePath := "/api/v1/workspaces/ws1/unlock-licenses/files/" + file.ID
+	w = doWorkspaceRequest(t, router, http.MethodPost, filePath+"/push", "", PushUnlockLicenseRequest{DeviceSNs: []string{"DOCK9"}})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doWorkspaceRequest(t, router, http.MethodPost, filePath+"/push", "", PushUnlockLicenseRequest{DeviceSNs: []string{"DOCK1"}})
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var pushed ListUnlockLicenseAuditsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pushed))
+	require.Len(t, pushed.Audits, 1)
+	assert.Equal(t, unlocklicense.StatusPending, pushed.Audits[0].Status)
+
+	enable := true
+	devicePath := "/api/v1/workspaces/ws1/devices/DOCK1/unlock-licenses"
+	w = doWorkspaceRequest(t, router, http.MethodPut, devicePath+"/42", "", SwitchUnlockLicenseRequest{Enable: &enable})
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	w = doWorkspaceRequest(t, router, http.MethodPut, devicePath+"/42", "", map[string]any{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doWorkspaceRequest(t, router, http.MethodPost, devicePath+"/refresh", "", RefreshUnlockLicensesRequest{DeviceModelDomain: 2})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doWorkspaceRequest(t, router, http.MethodPost, devicePath+"/refresh", "", RefreshUnlockLicensesRequest{DeviceModelDomain: 3})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Len(t, disp.calls, 3)
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, devicePath, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"domains":[]}`, w.Body.String())
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/workspaces/ws1/unlock-licenses/audits?device_sn=DOCK1", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var audits ListUnlockLicenseAuditsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &audits))
+	require.Len(t, audits.Audits, 2)
+	assert.Equal(t, unlocklicense.ActionEnable, audits.Audits[0].Action)
+	assert.Equal(t, "user:1", audits.Audits[0].ActorID)
+	assert.Equal(t, 42, audits.Audits[0].LicenseID)
+
+	w = doWorkspaceRequest(t, router, http.MethodDelete, filePath, "", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestUnlockLicense_WorkspaceScope(t *testing.T) {
+	router, _ := setupUnlockLicenseRouter(t)
+
+	w := doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/workspaces/ws1/unlock-licenses/files", "ws2", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/workspaces/ws2/devices/DOCK1/unlock-licenses", "ws2", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
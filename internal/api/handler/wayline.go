@@ -0,0 +1,820 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/wayline"
+	"github.com/utmos/utmos/pkg/adapter/dji/wpml"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+// maxWaylineUploadSize limits the size of uploaded KMZ files.
+const maxWaylineUploadSize = 50 << 20
+
+// Wayline handles wayline file API requests
+type Wayline struct {
+	db      *gorm.DB
+	service *wayline.Service
+	logger  *logrus.Entry
+}
+
+// NewWayline creates a new wayline handler
+func NewWayline(db *gorm.DB, service *wayline.Service, logger *logrus.Entry) *Wayline {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Wayline{
+		db:      db,
+		service: service,
+		logger:  logger.WithField("handler", "wayline"),
+	}
+}
+
+// WaylineResponse represents a stored wayline file
+type WaylineResponse struct {
+	ID              uint          `json:"id"`
+	Name            string        `json:"name"`
+	Description     string        `json:"description,omitempty"`
+	TemplateType    string        `json:"template_type"`
+	Fingerprint     string        `json:"fingerprint"`
+	Size            int64         `json:"size"`
+	Distance        float64       `json:"distance"`
+	Duration        float64       `json:"duration"`
+	DroneModel      int           `json:"drone_model"`
+	DroneSubModel   int           `json:"drone_sub_model"`
+	PayloadModel    int           `json:"payload_model"`
+	PayloadPosition int           `json:"payload_position"`
+	WaypointCount   int           `json:"waypoint_count"`
+	PhotoCount      int           `json:"photo_count"`
+	Favorited       bool          `json:"favorited"`
+	Revision        int           `json:"revision"`
+	Kmz             *wpml.KmzInfo `json:"kmz,omitempty"`
+	CreatedAt       string        `json:"created_at"`
+	UpdatedAt       string        `json:"updated_at"`
+}
+
+// ListWaylinesResponse represents a paginated list of waylines
+type ListWaylinesResponse struct {
+	Waylines   []WaylineResponse `json:"waylines"`
+	Total      int64             `json:"total"`
+	Page       int               `json:"page"`
+	PageSize   int               `json:"page_size"`
+	TotalPages int               `json:"total_pages"`
+}
+
+// WaylineValidationErrorResponse is returned when a wayline fails WPML validation
+type WaylineValidationErrorResponse struct {
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Details []string `json:"details"`
+}
+
+// DuplicateNamesResponse lists names that are already taken
+type DuplicateNamesResponse struct {
+	Duplicates []string `json:"duplicates"`
+}
+
+// WaylineRevisionResponse represents a stored revision of a wayline file
+type WaylineRevisionResponse struct {
+	Revision    int    `json:"revision"`
+	Fingerprint string `json:"fingerprint"`
+	Size        int64  `json:"size"`
+	Username    string `json:"username,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ListWaylineRevisionsResponse lists the revisions of a wayline, oldest first
+type ListWaylineRevisionsResponse struct {
+	Revisions []WaylineRevisionResponse `json:"revisions"`
+}
+
+// WaylineDiffResponse is the difference between two revisions of a wayline
+type WaylineDiffResponse struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	*wpml.MissionDiff
+}
+
+func toWaylineResponse(w *models.Wayline) WaylineResponse {
+	resp := WaylineResponse{
+		ID:              w.ID,
+		Name:            w.Name,
+		Description:     w.Description,
+		TemplateType:    w.TemplateType,
+		Fingerprint:     w.Fingerprint,
+		Size:            w.Size,
+		Distance:        w.Distance,
+		Duration:        w.Duration,
+		DroneModel:      w.DroneModel,
+		DroneSubModel:   w.DroneSubModel,
+		PayloadModel:    w.PayloadModel,
+		PayloadPosition: w.PayloadPosition,
+		WaypointCount:   w.WaypointCount,
+		PhotoCount:      w.PhotoCount,
+		Favorited:       w.Favorited,
+		Revision:        w.Revision,
+		CreatedAt:       w.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:       w.UpdatedAt.Format(time.RFC3339),
+	}
+	if len(w.Metadata) > 0 {
+		var info wpml.KmzInfo
+		if err := json.Unmarshal(w.Metadata, &info); err == nil {
+			resp.Kmz = &info
+		}
+	}
+	return resp
+}
+
+// respondWaylineError maps wayline service errors to HTTP responses.
+func (h *Wayline) respondWaylineError(c *gin.Context, err error, logMsg string) {
+	var validationErr *wayline.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, WaylineValidationErrorResponse{
+			Code:    "INVALID_WAYLINE",
+			Message: "Wayline failed validation",
+			Details: validationErr.Details,
+		})
+	case errors.Is(err, wayline.ErrInvalidKMZ):
+		respondBadRequest(c, "INVALID_KMZ", err.Error())
+	case errors.Is(err, wayline.ErrInvalidRoute):
+		respondBadRequest(c, "INVALID_ROUTE", err.Error())
+	case errors.Is(err, wayline.ErrDuplicateName):
+		respondError(c, http.StatusConflict, "DUPLICATE_NAME", "Wayline name already exists")
+	case errors.Is(err, wayline.ErrRevisionNotFound):
+		respondNotFound(c, "REVISION_NOT_FOUND", "Wayline revision not found")
+	case errors.Is(err, wayline.ErrRevisionUnchanged):
+		respondError(c, http.StatusConflict, "REVISION_UNCHANGED", "Wayline file is identical to the latest revision")
+	case errors.Is(err, wayline.ErrRevisionConflict):
+		respondError(c, http.StatusConflict, "REVISION_CONFLICT", "Wayline was revised concurrently")
+	case errors.Is(err, wayline.ErrWaylineInUse):
+		respondError(c, http.StatusConflict, "WAYLINE_IN_USE", "Wayline is referenced by flight tasks")
+	case errors.Is(err, objectstore.ErrNotFound):
+		respondNotFound(c, "WAYLINE_FILE_NOT_FOUND", "Wayline file not found")
+	default:
+		respondInternalError(c, h.logger, err, logMsg, logMsg)
+	}
+}
+
+// requireService checks that wayline storage is configured.
+// Returns true if available. On failure it writes a 503 response and returns false.
+func (h *Wayline) requireService(c *gin.Context) bool {
+	if h.service == nil {
+		respondServiceUnavailable(c, "Wayline storage not available")
+		return false
+	}
+	return true
+}
+
+// loadWayline looks up the wayline referenced by the :id parameter.
+func (h *Wayline) loadWayline(c *gin.Context) (*models.Wayline, bool) {
+	id, ok := parseUintID(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	var w models.Wayline
+	if handleDBLookupError(c, h.logger, scopeWorkspace(c, h.db).First(&w, id).Error,
+		"WAYLINE_NOT_FOUND", "Wayline not found",
+		"Failed to get wayline", "Failed to get wayline") {
+		return nil, false
+	}
+	return &w, true
+}
+
+// Upload stores an uploaded KMZ wayline file
+// @Summary Upload a wayline KMZ
+// @Description Upload a KMZ archive; it is validated against the drone and payload declared in its mission config
+// @Tags waylines
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "KMZ file"
+// @Param name formData string false "Wayline name (defaults to the file name)"
+// @Param description formData string false "Description"
+// @Success 201 {object} WaylineResponse
+// @Failure 400 {object} WaylineValidationErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/waylines/upload [post]
+func (h *Wayline) Upload(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+
+	data, filename, ok := readWaylineFile(c)
+	if !ok {
+		return
+	}
+
+	name := c.PostForm("name")
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+
+	workspaceID, _ := workspaceScope(c)
+	w, err := h.service.ImportKMZ(c.Request.Context(), wayline.Owner{WorkspaceID: workspaceID}, name, c.PostForm("description"), data)
+	if err != nil {
+		h.respondWaylineError(c, err, "Failed to upload wayline")
+		return
+	}
+
+	c.JSON(http.StatusCreated, toWaylineResponse(w))
+}
+
+// readWaylineFile reads the uploaded "file" form field and returns its
+// contents and file name. On failure it writes an error response and returns false.
+func readWaylineFile(c *gin.Context) ([]byte, string, bool) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		respondBadRequest(c, "INVALID_REQUEST", "file is required")
+		return nil, "", false
+	}
+	if fileHeader.Size > maxWaylineUploadSize {
+		respondError(c, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", "Wayline file is too large")
+		return nil, "", false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		respondBadRequest(c, "INVALID_REQUEST", "failed to open uploaded file")
+		return nil, "", false
+	}
+	defer func() { _ = file.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxWaylineUploadSize))
+	if err != nil {
+		respondBadRequest(c, "INVALID_REQUEST", "failed to read uploaded file")
+		return nil, "", false
+	}
+	return data, fileHeader.Filename, true
+}
+
+// Create builds a wayline KMZ from the Waylines JSON schema
+// @Summary Create a wayline from JSON
+// @Tags waylines
+// @Accept json
+// @Produce json
+// @Param wayline body wpml.Waylines true "Waylines definition"
+// @Success 201 {object} WaylineResponse
+// @Failure 400 {object} WaylineValidationErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/waylines [post]
+func (h *Wayline) Create(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+
+	var req wpml.Waylines
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	workspaceID, _ := workspaceScope(c)
+	w, err := h.service.CreateFromWaylines(c.Request.Context(), wayline.Owner{WorkspaceID: workspaceID}, &req)
+	if err != nil {
+		h.respondWaylineError(c, err, "Failed to create wayline")
+		return
+	}
+
+	c.JSON(http.StatusCreated, toWaylineResponse(w))
+}
+
+// Get retrieves wayline metadata by ID
+// @Summary Get a wayline
+// @Tags waylines
+// @Produce json
+// @Param id path int true "Wayline ID"
+// @Success 200 {object} WaylineResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/waylines/{id} [get]
+func (h *Wayline) Get(c *gin.Context) {
+	w, ok := h.loadWayline(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toWaylineResponse(w))
+}
+
+// List lists waylines with pagination
+// @Summary List waylines
+// @Tags waylines
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param workspace_id query string false "Filter by workspace"
+// @Param name query string false "Filter by name (substring)"
+// @Param favorited query bool false "Only favourites"
+// @Param drone_model query int false "Filter by drone model"
+// @Param payload_model query int false "Filter by payload model"
+// @Success 200 {object} ListWaylinesResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/waylines [get]
+func (h *Wayline) List(c *gin.Context) {
+	page, pageSize, offset := parsePagination(c, 20, 100)
+
+	query := scopeWorkspace(c, h.db.Model(&models.Wayline{}))
+	if workspaceID, ok := c.GetQuery("workspace_id"); ok {
+		query = query.Where("workspace_id = ?", workspaceID)
+	}
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+	if favorited, err := strconv.ParseBool(c.Query("favorited")); err == nil {
+		query = query.Where("favorited = ?", favorited)
+	}
+	if drone, err := strconv.Atoi(c.Query("drone_model")); err == nil {
+		query = query.Where("drone_model = ?", drone)
+	}
+	if payload, err := strconv.Atoi(c.Query("payload_model")); err == nil {
+		query = query.Where("payload_model = ?", payload)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondInternalError(c, h.logger, err, "Failed to count waylines", "Failed to list waylines")
+		return
+	}
+
+	var waylines []models.Wayline
+	if err := query.Order("updated_at DESC").Offset(offset).Limit(pageSize).Find(&waylines).Error; err != nil {
+		respondInternalError(c, h.logger, err, "Failed to list waylines", "Failed to list waylines")
+		return
+	}
+
+	resp := ListWaylinesResponse{
+		Waylines:   make([]WaylineResponse, len(waylines)),
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages(total, pageSize),
+	}
+	for i := range waylines {
+		resp.Waylines[i] = toWaylineResponse(&waylines[i])
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Download streams the stored KMZ file
+// @Summary Download a wayline KMZ
+// @Tags waylines
+// @Produce application/vnd.google-earth.kmz
+// @Param id path int true "Wayline ID"
+// @Success 200 {file} binary
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/waylines/{id}/download [get]
+func (h *Wayline) Download(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	w, ok := h.loadWayline(c)
+	if !ok {
+		return
+	}
+
+	rc, info, err := h.service.Open(c.Request.Context(), w)
+	if errors.Is(err, objectstore.ErrNotFound) {
+		respondNotFound(c, "WAYLINE_FILE_NOT_FOUND", "Wayline file not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to open wayline file", "Failed to download wayline")
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", w.Name+".kmz"))
+	c.DataFromReader(http.StatusOK, info.Size, wayline.ContentTypeKMZ, rc, nil)
+}
+
+// Estimate estimates the flight of a stored wayline
+// @Summary Estimate a wayline
+// @Description Estimate the route length, flight time and photo count of a wayline and check it against the drone's battery
+// @Tags waylines
+// @Produce json
+// @Param id path int true "Wayline ID"
+// @Success 200 {object} wpml.Estimate
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/waylines/{id}/estimate [get]
+func (h *Wayline) Estimate(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	w, ok := h.loadWayline(c)
+	if !ok {
+		return
+	}
+
+	estimate, err := h.service.Estimate(c.Request.Context(), w)
+	if errors.Is(err, objectstore.ErrNotFound) {
+		respondNotFound(c, "WAYLINE_FILE_NOT_FOUND", "Wayline file not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to estimate wayline", "Failed to estimate wayline")
+		return
+	}
+	c.JSON(http.StatusOK, estimate)
+}
+
+// routeContentTypes maps the route formats waylines are exported to onto their MIME types.
+var routeContentTypes = map[wpml.RouteFormat]string{
+	wpml.RouteFormatKML:     "application/vnd.google-earth.kml+xml",
+	wpml.RouteFormatGeoJSON: "application/geo+json",
+	wpml.RouteFormatGPX:     "application/gpx+xml",
+}
+
+// routeFormatExtensions maps route file extensions onto their formats.
+var routeFormatExtensions = map[string]wpml.RouteFormat{
+	".kml":     wpml.RouteFormatKML,
+	".geojson": wpml.RouteFormatGeoJSON,
+	".json":    wpml.RouteFormatGeoJSON,
+	".gpx":     wpml.RouteFormatGPX,
+	".csv":     wpml.RouteFormatLitchiCSV,
+}
+
+// Import creates a wayline from a route file
+// @Summary Import a route file
+// @Description Create a waypoint wayline from a KML, GeoJSON, GPX or Litchi CSV route; the format defaults to the file extension
+// @Tags waylines
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Route file"
+// @Param format formData string false "Route format: kml, geojson, gpx or litchi"
+// @Param name formData string false "Wayline name (defaults to the route name)"
+// @Param drone_model formData int true "Drone model"
+// @Param payload_model formData int true "Payload model"
+// @Param height formData number false "Waypoint height of routes without heights"
+// @Param speed formData number false "Flight speed of waypoints without a speed"
+// @Param height_type formData string false "Height mode overriding the route's"
+// @Param take_photo formData bool false "Take a photo at every waypoint without actions"
+// @Success 201 {object} WaylineResponse
+// @Failure 400 {object} WaylineValidationErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/waylines/import [post]
+func (h *Wayline) Import(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+
+	data, filename, ok := readWaylineFile(c)
+	if !ok {
+		return
+	}
+	format := wpml.RouteFormat(c.PostForm("format"))
+	if format == "" {
+		format = routeFormatExtensions[strings.ToLower(filepath.Ext(filename))]
+	}
+	opts, ok := importOptions(c)
+	if !ok {
+		return
+	}
+	if format != wpml.RouteFormatLitchiCSV && routeContentTypes[format] == "" {
+		respondBadRequest(c, "INVALID_REQUEST", "format must be kml, geojson, gpx or litchi")
+		return
+	}
+
+	workspaceID, _ := workspaceScope(c)
+	w, err := h.service.ImportRoute(c.Request.Context(), wayline.Owner{WorkspaceID: workspaceID}, format, data, opts)
+	if err != nil {
+		h.respondWaylineError(c, err, "Failed to import wayline")
+		return
+	}
+
+	c.JSON(http.StatusCreated, toWaylineResponse(w))
+}
+
+// importOptions reads the import options of a route file upload.
+// On failure it writes a 400 response and returns false.
+func importOptions(c *gin.Context) (*wpml.ImportOptions, bool) {
+	opts := &wpml.ImportOptions{
+		Name:       c.PostForm("name"),
+		HeightType: wpml.HeightMode(c.PostForm("height_type")),
+	}
+	numbers := []struct {
+		field string
+		value *float64
+	}{{"height", &opts.Height}, {"speed", &opts.Speed}}
+	for _, n := range numbers {
+		raw := c.PostForm(n.field)
+		if raw == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			respondBadRequest(c, "INVALID_REQUEST", n.field+" must be a number")
+			return nil, false
+		}
+		*n.value = parsed
+	}
+
+	drone, droneErr := strconv.Atoi(c.PostForm("drone_model"))
+	payload, payloadErr := strconv.Atoi(c.PostForm("payload_model"))
+	if droneErr != nil || payloadErr != nil {
+		respondBadRequest(c, "INVALID_REQUEST", "drone_model and payload_model are required")
+		return nil, false
+	}
+	opts.DroneModel, opts.PayloadModel = wpml.DroneModel(drone), wpml.PayloadModel(payload)
+	opts.TakePhoto, _ = strconv.ParseBool(c.PostForm("take_photo"))
+	return opts, true
+}
+
+// Export writes a stored wayline as a route file
+// @Summary Export a wayline as a route file
+// @Description Export the waylines of a stored wayline as KML, GeoJSON or GPX for review in GIS tools
+// @Tags waylines
+// @Produce application/geo+json
+// @Param id path int true "Wayline ID"
+// @Param format query string false "Route format: kml, geojson (default) or gpx"
+// @Success 200 {file} binary
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/waylines/{id}/export [get]
+func (h *Wayline) Export(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	format := wpml.RouteFormat(c.DefaultQuery("format", string(wpml.RouteFormatGeoJSON)))
+	contentType, ok := routeContentTypes[format]
+	if !ok {
+		respondBadRequest(c, "INVALID_REQUEST", "format must be kml, geojson or gpx")
+		return
+	}
+	w, ok := h.loadWayline(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.service.ExportRoute(c.Request.Context(), w, format)
+	if errors.Is(err, objectstore.ErrNotFound) {
+		respondNotFound(c, "WAYLINE_FILE_NOT_FOUND", "Wayline file not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to export wayline", "Failed to export wayline")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", w.Name+"."+string(format)))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// AddRevision stores an edited KMZ file as the next revision of a wayline
+// @Summary Revise a wayline
+// @Description Upload an edited KMZ archive as the wayline's next revision; earlier revisions stay downloadable
+// @Tags waylines
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Wayline ID"
+// @Param file formData file true "KMZ file"
+// @Param comment formData string false "What changed"
+// @Success 201 {object} WaylineResponse
+// @Failure 400 {object} WaylineValidationErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/waylines/{id}/revisions [post]
+func (h *Wayline) AddRevision(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	w, ok := h.loadWayline(c)
+	if !ok {
+		return
+	}
+	data, _, ok := readWaylineFile(c)
+	if !ok {
+		return
+	}
+
+	workspaceID, _ := workspaceScope(c)
+	revised, err := h.service.AddRevision(c.Request.Context(), w, wayline.Owner{WorkspaceID: workspaceID}, data, c.PostForm("comment"))
+	if err != nil {
+		h.respondWaylineError(c, err, "Failed to revise wayline")
+		return
+	}
+	c.JSON(http.StatusCreated, toWaylineResponse(revised))
+}
+
+// Revisions lists the revisions of a wayline
+// @Summary List wayline revisions
+// @Tags waylines
+// @Produce json
+// @Param id path int true "Wayline ID"
+// @Success 200 {object} ListWaylineRevisionsResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/waylines/{id}/revisions [get]
+func (h *Wayline) Revisions(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	w, ok := h.loadWayline(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.service.Revisions(c.Request.Context(), w)
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to list wayline revisions", "Failed to list wayline revisions")
+		return
+	}
+	resp := ListWaylineRevisionsResponse{Revisions: make([]WaylineRevisionResponse, len(revisions))}
+	for i, r := range revisions {
+		resp.Revisions[i] = WaylineRevisionResponse{
+			Revision:    r.Revision,
+			Fingerprint: r.Fingerprint,
+			Size:        r.Size,
+			Username:    r.Username,
+			Comment:     r.Comment,
+			CreatedAt:   r.CreatedAt.Format(time.RFC3339),
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DownloadRevision downloads the KMZ file of a wayline revision
+// @Summary Download a wayline revision
+// @Tags waylines
+// @Produce application/vnd.google-earth.kmz
+// @Param id path int true "Wayline ID"
+// @Param revision path int true "Revision number"
+// @Success 200 {file} binary
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/waylines/{id}/revisions/{revision}/download [get]
+func (h *Wayline) DownloadRevision(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	w, ok := h.loadWayline(c)
+	if !ok {
+		return
+	}
+	number, ok := parseUintID(c, "revision")
+	if !ok {
+		return
+	}
+
+	revision, err := h.service.Revision(c.Request.Context(), w, int(number))
+	if err != nil {
+		h.respondWaylineError(c, err, "Failed to get wayline revision")
+		return
+	}
+	rc, info, err := h.service.OpenRevision(c.Request.Context(), revision)
+	if err != nil {
+		h.respondWaylineError(c, err, "Failed to open wayline file")
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-r%d.kmz", w.Name, revision.Revision)))
+	c.DataFromReader(http.StatusOK, info.Size, wayline.ContentTypeKMZ, rc, nil)
+}
+
+// Diff compares two revisions of a wayline
+// @Summary Diff wayline revisions
+// @Description List the added, removed and moved waypoints and the changed heights, speeds, action groups and mission settings between two revisions
+// @Tags waylines
+// @Produce json
+// @Param id path int true "Wayline ID"
+// @Param from query int false "Older revision (defaults to the one before to)"
+// @Param to query int false "Newer revision (defaults to the latest)"
+// @Success 200 {object} WaylineDiffResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/waylines/{id}/diff [get]
+func (h *Wayline) Diff(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	w, ok := h.loadWayline(c)
+	if !ok {
+		return
+	}
+
+	to, err := strconv.Atoi(c.DefaultQuery("to", strconv.Itoa(w.Revision)))
+	if err != nil {
+		respondBadRequest(c, "INVALID_REQUEST", "to must be a revision number")
+		return
+	}
+	from, err := strconv.Atoi(c.DefaultQuery("from", strconv.Itoa(to-1)))
+	if err != nil {
+		respondBadRequest(c, "INVALID_REQUEST", "from must be a revision number")
+		return
+	}
+
+	diff, err := h.service.Diff(c.Request.Context(), w, from, to)
+	if err != nil {
+		h.respondWaylineError(c, err, "Failed to diff wayline revisions")
+		return
+	}
+	c.JSON(http.StatusOK, WaylineDiffResponse{From: from, To: to, MissionDiff: diff})
+}
+
+// DuplicateNames reports which of the given names are already in use
+// @Summary Check wayline names
+// @Tags waylines
+// @Produce json
+// @Param name query []string true "Names to check" collectionFormat(multi)
+// @Success 200 {object} DuplicateNamesResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /api/v1/waylines/duplicate-names [get]
+func (h *Wayline) DuplicateNames(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	names := c.QueryArray("name")
+	if len(names) == 0 {
+		respondBadRequest(c, "INVALID_REQUEST", "at least one name is required")
+		return
+	}
+
+	workspaceID, _ := workspaceScope(c)
+	duplicates, err := h.service.DuplicateNames(c.Request.Context(), workspaceID, names)
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to check wayline names", "Failed to check wayline names")
+		return
+	}
+	c.JSON(http.StatusOK, DuplicateNamesResponse{Duplicates: duplicates})
+}
+
+// Favorite marks a wayline as favourite
+// @Summary Favourite a wayline
+// @Tags waylines
+// @Param id path int true "Wayline ID"
+// @Success 204
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/waylines/{id}/favorite [post]
+func (h *Wayline) Favorite(c *gin.Context) {
+	h.setFavorite(c, true)
+}
+
+// Unfavorite removes a wayline from favourites
+// @Summary Unfavourite a wayline
+// @Tags waylines
+// @Param id path int true "Wayline ID"
+// @Success 204
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/waylines/{id}/favorite [delete]
+func (h *Wayline) Unfavorite(c *gin.Context) {
+	h.setFavorite(c, false)
+}
+
+func (h *Wayline) setFavorite(c *gin.Context, favorite bool) {
+	if !h.requireService(c) {
+		return
+	}
+	w, ok := h.loadWayline(c)
+	if !ok {
+		return
+	}
+	if err := h.service.SetFavorite(c.Request.Context(), w.WorkspaceID, []uint{w.ID}, favorite); err != nil {
+		respondInternalError(c, h.logger, err, "Failed to update favourite", "Failed to update favourite")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Delete deletes a wayline and its stored file
+// @Summary Delete a wayline
+// @Tags waylines
+// @Param id path int true "Wayline ID"
+// @Success 204
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/waylines/{id} [delete]
+func (h *Wayline) Delete(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+	w, ok := h.loadWayline(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c.Request.Context(), w); err != nil {
+		h.respondWaylineError(c, err, "Failed to delete wayline")
+		return
+	}
+
+	logWithTrace(h.logger, c.Request.Context()).WithField("wayline_id", w.ID).Info("Wayline deleted")
+	c.Status(http.StatusNoContent)
+}
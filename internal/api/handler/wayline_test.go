@@ -0,0 +1,354 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/wayline"
+	"github.com/utmos/utmos/pkg/adapter/dji/wpml"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+func setupWaylineHandler(t *testing.T) (*Wayline, *gin.Engine) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Wayline{}, &models.WaylineRevision{}, &models.FlightTask{}))
+
+	store, err := objectstore.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	svc, err := wayline.NewService(db, store, nil)
+	require.NoError(t, err)
+
+	h := NewWayline(db, svc, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/waylines", h.Create)
+	router.POST("/api/v1/waylines/upload", h.Upload)
+	router.POST("/api/v1/waylines/import", h.Import)
+	router.GET("/api/v1/waylines", h.List)
+	router.GET("/api/v1/waylines/duplicate-names", h.DuplicateNames)
+	router.GET("/api/v1/waylines/:id", h.Get)
+	router.GET("/api/v1/waylines/:id/download", h.Download)
+	router.GET("/api/v1/waylines/:id/estimate", h.Estimate)
+	router.GET("/api/v1/waylines/:id/export", h.Export)
+	router.POST("/api/v1/waylines/:id/revisions", h.AddRevision)
+	router.GET("/api/v1/waylines/:id/revisions", h.Revisions)
+	router.GET("/api/v1/waylines/:id/revisions/:revision/download", h.DownloadRevision)
+	router.GET("/api/v1/waylines/:id/diff", h.Diff)
+	router.POST("/api/v1/waylines/:id/favorite", h.Favorite)
+	router.DELETE("/api/v1/waylines/:id/favorite", h.Unfavorite)
+	router.DELETE("/api/v1/waylines/:id", h.Delete)
+
+	return h, router
+}
+
+func sampleWaylines(name string) *wpml.Waylines {
+	return &wpml.Waylines{
+		Name:                    name,
+		DroneModel:              wpml.DroneM3DSeries,
+		PayloadModel:            wpml.PayloadMatrice3DCamera,
+		TemplateType:            wpml.TemplateTypeWaypoint,
+		GlobalHeight:            80,
+		GlobalSpeed:             10,
+		TakeOffSecurityHeight:   50,
+		GlobalRTHHeight:         100,
+		GlobalTransitionalSpeed: 10,
+		Waypoints: []wpml.WaylinesWaypoint{
+			{Latitude: 22.5431, Longitude: 113.9501, Height: 80, Speed: 10, TriggerType: wpml.TriggerTypeReachPoint},
+			{Latitude: 22.5440, Longitude: 113.9510, Height: 80, Speed: 10, TriggerType: wpml.TriggerTypeReachPoint},
+		},
+	}
+}
+
+func uploadKMZ(t *testing.T, router *gin.Engine, filename string, data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/waylines/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestWayline_CreateAndDownload(t *testing.T) {
+	_, router := setupWaylineHandler(t)
+
+	w := doJSON(t, router, http.MethodPost, "/api/v1/waylines", sampleWaylines("inspection"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var created WaylineResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "inspection", created.Name)
+	require.NotNil(t, created.Kmz)
+	assert.Len(t, created.Kmz.Files, 2)
+
+	w = doJSON(t, router, http.MethodGet, fmt.Sprintf("/api/v1/waylines/%d/download", created.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, wayline.ContentTypeKMZ, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "inspection.kmz")
+
+	mission, err := wpml.ParseKMZBuffer(w.Body.Bytes())
+	require.NoError(t, err)
+	assert.Len(t, mission.Waylines.Document.Folders[0].Placemarks, 2)
+
+	w = doJSON(t, router, http.MethodPost, "/api/v1/waylines", sampleWaylines("inspection"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestWayline_Estimate(t *testing.T) {
+	_, router := setupWaylineHandler(t)
+
+	w := doJSON(t, router, http.MethodPost, "/api/v1/waylines", sampleWaylines("estimated"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created WaylineResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Positive(t, created.Distance)
+	assert.Positive(t, created.Duration)
+
+	w = doJSON(t, router, http.MethodGet, fmt.Sprintf("/api/v1/waylines/%d/estimate", created.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var estimate wpml.Estimate
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &estimate))
+	assert.InDelta(t, created.Distance, estimate.Distance, 1e-9)
+	assert.Greater(t, estimate.FlightTime, estimate.Duration)
+	require.NotNil(t, estimate.Battery)
+	assert.True(t, estimate.Battery.Feasible)
+
+	w = doJSON(t, router, http.MethodGet, "/api/v1/waylines/999/estimate", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestWayline_CreateInvalid(t *testing.T) {
+	_, router := setupWaylineHandler(t)
+
+	invalid := sampleWaylines("bad")
+	invalid.Waypoints = nil
+	w := doJSON(t, router, http.MethodPost, "/api/v1/waylines", invalid)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp WaylineValidationErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "INVALID_WAYLINE", resp.Code)
+	assert.NotEmpty(t, resp.Details)
+}
+
+func TestWayline_Upload(t *testing.T) {
+	_, router := setupWaylineHandler(t)
+
+	buffer, err := wpml.CreateKmzBufferFromWaylines(sampleWaylines("ignored"))
+	require.NoError(t, err)
+
+	w := uploadKMZ(t, router, "survey-north.kmz", buffer.Bytes())
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created WaylineResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "survey-north", created.Name)
+	assert.Equal(t, int64(buffer.Len()), created.Size)
+
+	w = uploadKMZ(t, router, "broken.kmz", []byte("not a kmz"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "INVALID_KMZ")
+}
+
+func importRoute(t *testing.T, router *gin.Engine, filename string, data []byte, fields map[string]string) *httptest.ResponseRecorder {
+	return postWaylineFile(t, router, "/api/v1/waylines/import", filename, data, fields)
+}
+
+func postWaylineFile(t *testing.T, router *gin.Engine, path, filename string, data []byte, fields map[string]string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	for name, value := range fields {
+		require.NoError(t, writer.WriteField(name, value))
+	}
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestWayline_ExportAndImport(t *testing.T) {
+	_, router := setupWaylineHandler(t)
+
+	w := doJSON(t, router, http.MethodPost, "/api/v1/waylines", sampleWaylines("survey"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created WaylineResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	w = doJSON(t, router, http.MethodGet, fmt.Sprintf("/api/v1/waylines/%d/export", created.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/geo+json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "survey.geojson")
+	exported := w.Body.Bytes()
+
+	w = doJSON(t, router, http.MethodGet, fmt.Sprintf("/api/v1/waylines/%d/export?format=kml", created.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "<LineString>")
+	w = doJSON(t, router, http.MethodGet, fmt.Sprintf("/api/v1/waylines/%d/export?format=litchi", created.ID), nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	fields := map[string]string{
+		"name":          "survey copy",
+		"drone_model":   strconv.Itoa(int(wpml.DroneM3DSeries)),
+		"payload_model": strconv.Itoa(int(wpml.PayloadMatrice3DCamera)),
+	}
+	w = importRoute(t, router, "survey.geojson", exported, fields)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var imported WaylineResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &imported))
+	assert.Equal(t, "survey copy", imported.Name)
+	assert.Equal(t, 2, imported.WaypointCount)
+	assert.InDelta(t, created.Distance, imported.Distance, 1e-6)
+
+	w = importRoute(t, router, "survey.geojson", []byte("{"), map[string]string{"drone_model": "77", "payload_model": "68"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "INVALID_ROUTE")
+	w = importRoute(t, router, "survey.shp", exported, fields)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = importRoute(t, router, "survey.geojson", exported, map[string]string{"height": "high"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWayline_RevisionsAndDiff(t *testing.T) {
+	_, router := setupWaylineHandler(t)
+
+	w := doJSON(t, router, http.MethodPost, "/api/v1/waylines", sampleWaylines("patrol"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created WaylineResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, 1, created.Revision)
+	base := fmt.Sprintf("/api/v1/waylines/%d", created.ID)
+
+	edited := sampleWaylines("patrol")
+	edited.Waypoints = append(edited.Waypoints, wpml.WaylinesWaypoint{
+		Latitude: 22.5450, Longitude: 113.9520, Height: 80, Speed: 10, TriggerType: wpml.TriggerTypeReachPoint,
+	})
+	buffer, err := wpml.CreateKmzBufferFromWaylines(edited)
+	require.NoError(t, err)
+	w = postWaylineFile(t, router, base+"/revisions", "patrol.kmz", buffer.Bytes(), map[string]string{"comment": "extend"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var revised WaylineResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &revised))
+	assert.Equal(t, 2, revised.Revision)
+	assert.Equal(t, 3, revised.WaypointCount)
+
+	w = postWaylineFile(t, router, base+"/revisions", "patrol.kmz", buffer.Bytes(), nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "REVISION_UNCHANGED")
+
+	w = doJSON(t, router, http.MethodGet, base+"/revisions", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var revisions ListWaylineRevisionsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &revisions))
+	require.Len(t, revisions.Revisions, 2)
+	assert.Equal(t, created.Fingerprint, revisions.Revisions[0].Fingerprint)
+	assert.Equal(t, "extend", revisions.Revisions[1].Comment)
+
+	w = doJSON(t, router, http.MethodGet, base+"/revisions/1/download", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "patrol-r1.kmz")
+	mission, err := wpml.ParseKMZBuffer(w.Body.Bytes())
+	require.NoError(t, err)
+	assert.Len(t, mission.Waylines.Document.Folders[0].Placemarks, 2)
+
+	w = doJSON(t, router, http.MethodGet, base+"/diff", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var diff WaylineDiffResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &diff))
+	assert.Equal(t, 1, diff.From)
+	assert.Equal(t, 2, diff.To)
+	require.Len(t, diff.Waylines, 1)
+	require.Len(t, diff.Waylines[0].Waypoints, 1)
+	assert.Equal(t, wpml.ChangeAdded, diff.Waylines[0].Waypoints[0].Change)
+
+	w = doJSON(t, router, http.MethodGet, base+"/diff?from=1&to=5", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "REVISION_NOT_FOUND")
+	w = doJSON(t, router, http.MethodGet, base+"/diff?from=first", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWayline_ListFavoritesAndDuplicates(t *testing.T) {
+	h, router := setupWaylineHandler(t)
+
+	var ids []uint
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		w := doJSON(t, router, http.MethodPost, "/api/v1/waylines", sampleWaylines(name))
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var created WaylineResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		ids = append(ids, created.ID)
+	}
+
+	w := doJSON(t, router, http.MethodPost, fmt.Sprintf("/api/v1/waylines/%d/favorite", ids[1]), nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = doJSON(t, router, http.MethodGet, "/api/v1/waylines?favorited=true", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListWaylinesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Waylines, 1)
+	assert.Equal(t, "beta", list.Waylines[0].Name)
+
+	w = doJSON(t, router, http.MethodDelete, fmt.Sprintf("/api/v1/waylines/%d/favorite", ids[1]), nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = doJSON(t, router, http.MethodGet, "/api/v1/waylines?name=amm", nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, int64(1), list.Total)
+
+	w = doJSON(t, router, http.MethodGet, "/api/v1/waylines/duplicate-names?name=alpha&name=delta", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var dups DuplicateNamesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &dups))
+	assert.Equal(t, []string{"alpha"}, dups.Duplicates)
+
+	// A wayline flown by a task is kept
+	task := models.FlightTask{Name: "patrol", DockSN: "DOCK001", WaylineID: &ids[0]}
+	require.NoError(t, h.db.Create(&task).Error)
+	w = doJSON(t, router, http.MethodDelete, fmt.Sprintf("/api/v1/waylines/%d", ids[0]), nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "WAYLINE_IN_USE")
+	require.NoError(t, h.db.Delete(&task).Error)
+
+	w = doJSON(t, router, http.MethodDelete, fmt.Sprintf("/api/v1/waylines/%d", ids[0]), nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = doJSON(t, router, http.MethodGet, fmt.Sprintf("/api/v1/waylines/%d", ids[0]), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestWayline_NoStorage(t *testing.T) {
+	h := NewWayline(nil, nil, nil)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/waylines", h.Create)
+
+	w := doJSON(t, router, http.MethodPost, "/api/v1/waylines", sampleWaylines("x"))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,339 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/workspace"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// Workspace handles workspace-related API requests. Callers confined to a
+// workspace may only read and rename their own; creating and deleting
+// workspaces and moving devices between them is reserved for unscoped
+// callers.
+type Workspace struct {
+	service *workspace.Service
+	logger  *logrus.Entry
+}
+
+// NewWorkspace creates a new workspace handler
+func NewWorkspace(service *workspace.Service, logger *logrus.Entry) *Workspace {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Workspace{
+		service: service,
+		logger:  logger.WithField("handler", "workspace"),
+	}
+}
+
+// CreateWorkspaceRequest represents the request body for creating a workspace
+type CreateWorkspaceRequest struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description,omitempty"`
+}
+
+// UpdateWorkspaceRequest represents the request body for updating a workspace
+type UpdateWorkspaceRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	BindCode    *string `json:"bind_code,omitempty"`
+}
+
+// WorkspaceResponse represents the response for a workspace
+type WorkspaceResponse struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	BindCode    string `json:"bind_code,omitempty"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// ListWorkspacesResponse represents the response for listing workspaces
+type ListWorkspacesResponse struct {
+	Workspaces []WorkspaceResponse `json:"workspaces"`
+}
+
+// ListWorkspaceDevicesResponse represents the devices of a workspace
+type ListWorkspaceDevicesResponse struct {
+	Devices []DeviceResponse `json:"devices"`
+}
+
+func toWorkspaceResponse(w *models.Workspace) WorkspaceResponse {
+	return WorkspaceResponse{
+		ID:          w.ID,
+		Name:        w.Name,
+		Description: w.Description,
+		BindCode:    w.BindCode,
+		CreatedAt:   w.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:   w.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// Create creates a new workspace
+// @Summary Create a workspace
+// @Tags workspaces
+// @Accept json
+// @Produce json
+// @Param workspace body CreateWorkspaceRequest true "Workspace information"
+// @Success 201 {object} WorkspaceResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/workspaces [post]
+func (h *Workspace) Create(c *gin.Context) {
+	if !h.requireUnscoped(c) || !h.requireService(c) {
+		return
+	}
+	var req CreateWorkspaceRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	w, err := h.service.Create(c.Request.Context(), req.ID, req.Name, req.Description)
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to create workspace")
+		return
+	}
+	c.JSON(http.StatusCreated, toWorkspaceResponse(w))
+}
+
+// List lists the workspaces visible to the caller
+// @Summary List workspaces
+// @Tags workspaces
+// @Produce json
+// @Success 200 {object} ListWorkspacesResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/workspaces [get]
+func (h *Workspace) List(c *gin.Context) {
+	if !h.requireService(c) {
+		return
+	}
+
+	var ids []string
+	if workspaceID, ok := workspaceScope(c); ok {
+		ids = []string{workspaceID}
+	}
+	workspaces, err := h.service.List(c.Request.Context(), ids...)
+	if err != nil {
+		respondInternalError(c, h.logger, err, "Failed to list workspaces", "Failed to list workspaces")
+		return
+	}
+
+	resp := ListWorkspacesResponse{Workspaces: make([]WorkspaceResponse, len(workspaces))}
+	for i := range workspaces {
+		resp.Workspaces[i] = toWorkspaceResponse(&workspaces[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Get retrieves a workspace
+// @Summary Get a workspace
+// @Tags workspaces
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Success 200 {object} WorkspaceResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id} [get]
+func (h *Workspace) Get(c *gin.Context) {
+	id, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+
+	w, err := h.service.Get(c.Request.Context(), id)
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to get workspace")
+		return
+	}
+	c.JSON(http.StatusOK, toWorkspaceResponse(w))
+}
+
+// Update updates a workspace
+// @Summary Update a workspace
+// @Tags workspaces
+// @Accept json
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Param workspace body UpdateWorkspaceRequest true "Fields to update"
+// @Success 200 {object} WorkspaceResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id} [put]
+func (h *Workspace) Update(c *gin.Context) {
+	id, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+	var req UpdateWorkspaceRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	w, err := h.service.Update(c.Request.Context(), id, req.Name, req.Description, req.BindCode)
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to update workspace")
+		return
+	}
+	c.JSON(http.StatusOK, toWorkspaceResponse(w))
+}
+
+// Delete deletes a workspace without devices
+// @Summary Delete a workspace
+// @Tags workspaces
+// @Param id path string true "Workspace ID"
+// @Success 204
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id} [delete]
+func (h *Workspace) Delete(c *gin.Context) {
+	if !h.requireUnscoped(c) {
+		return
+	}
+	id, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), id); err != nil {
+		h.respondServiceError(c, err, "Failed to delete workspace")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDevices lists the devices of a workspace
+// @Summary List workspace devices
+// @Tags workspaces
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Success 200 {object} ListWorkspaceDevicesResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/devices [get]
+func (h *Workspace) ListDevices(c *gin.Context) {
+	id, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+
+	devices, err := h.service.Devices(c.Request.Context(), id)
+	if err != nil {
+		h.respondServiceError(c, err, "Failed to list workspace devices")
+		return
+	}
+
+	resp := ListWorkspaceDevicesResponse{Devices: make([]DeviceResponse, len(devices))}
+	for i := range devices {
+		resp.Devices[i] = toDeviceResponse(&devices[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// AddDevice moves a device and its sub-devices into a workspace
+// @Summary Add a device to a workspace
+// @Tags workspaces
+// @Param id path string true "Workspace ID"
+// @Param device_sn path string true "Device serial number"
+// @Success 204
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/devices/{device_sn} [put]
+func (h *Workspace) AddDevice(c *gin.Context) {
+	h.changeMembership(c, func(ctx context.Context, id, deviceSN string) error {
+		return h.service.AddDevice(ctx, id, deviceSN)
+	})
+}
+
+// RemoveDevice takes a device and its sub-devices out of a workspace
+// @Summary Remove a device from a workspace
+// @Tags workspaces
+// @Param id path string true "Workspace ID"
+// @Param device_sn path string true "Device serial number"
+// @Success 204
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/workspaces/{id}/devices/{device_sn} [delete]
+func (h *Workspace) RemoveDevice(c *gin.Context) {
+	h.changeMembership(c, func(ctx context.Context, id, deviceSN string) error {
+		return h.service.RemoveDevice(ctx, id, deviceSN)
+	})
+}
+
+func (h *Workspace) changeMembership(c *gin.Context, change func(ctx context.Context, id, deviceSN string) error) {
+	if !h.requireUnscoped(c) {
+		return
+	}
+	id, ok := h.requireAccess(c)
+	if !ok {
+		return
+	}
+	deviceSN, ok := requireStringParam(c, "device_sn", "INVALID_SN", "Device serial number is required")
+	if !ok {
+		return
+	}
+
+	if err := change(c.Request.Context(), id, deviceSN); err != nil {
+		h.respondServiceError(c, err, "Failed to change workspace membership")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// requireAccess returns the :id workspace when the service is available and
+// the caller may access it.
+func (h *Workspace) requireAccess(c *gin.Context) (string, bool) {
+	if !h.requireService(c) {
+		return "", false
+	}
+	id, ok := requireStringParam(c, "id", "INVALID_ID", "Workspace ID is required")
+	if !ok {
+		return "", false
+	}
+	return requireWorkspace(c, id)
+}
+
+// requireService writes a 503 response when the service is not configured.
+func (h *Workspace) requireService(c *gin.Context) bool {
+	if h.service == nil {
+		respondServiceUnavailable(c, "Workspace service not available")
+		return false
+	}
+	return true
+}
+
+// requireUnscoped rejects callers confined to a workspace.
+func (h *Workspace) requireUnscoped(c *gin.Context) bool {
+	if _, ok := workspaceScope(c); ok {
+		respondWorkspaceForbidden(c)
+		return false
+	}
+	return true
+}
+
+func (h *Workspace) respondServiceError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, workspace.ErrNotFound):
+		respondNotFound(c, "WORKSPACE_NOT_FOUND", "Workspace not found")
+	case errors.Is(err, workspace.ErrExists):
+		respondError(c, http.StatusConflict, "WORKSPACE_EXISTS", "Workspace with this ID already exists")
+	case errors.Is(err, workspace.ErrNotEmpty):
+		respondError(c, http.StatusConflict, "WORKSPACE_NOT_EMPTY", "Workspace still has devices")
+	case errors.Is(err, workspace.ErrDeviceNotFound):
+		respondNotFound(c, "DEVICE_NOT_FOUND", "Device not found")
+	case errors.Is(err, workspace.ErrDeviceNotMember):
+		respondNotFound(c, "DEVICE_NOT_MEMBER", "Device is not in the workspace")
+	default:
+		respondInternalError(c, h.logger, err, msg, msg)
+	}
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/api/middleware"
+	"github.com/utmos/utmos/internal/workspace"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// scopeHeader confines test requests to the workspace named in the header,
+// standing in for workspace-bound API keys.
+const scopeHeader = "X-Test-Workspace"
+
+func setupWorkspaceRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Workspace{}, &models.Device{}))
+
+	h := NewWorkspace(workspace.NewService(db, nil), nil)
+	devices := NewDevice(db, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if workspaceID := c.GetHeader(scopeHeader); workspaceID != "" {
+			middleware.SetWorkspaceScope(c, workspaceID)
+		}
+		c.Next()
+	})
+	router.POST("/api/v1/workspaces", h.Create)
+	router.GET("/api/v1/workspaces", h.List)
+	router.GET("/api/v1/workspaces/:id", h.Get)
+	router.PUT("/api/v1/workspaces/:id", h.Update)
+	router.DELETE("/api/v1/workspaces/:id", h.Delete)
+	router.GET("/api/v1/workspaces/:id/devices", h.ListDevices)
+	router.PUT("/api/v1/workspaces/:id/devices/:device_sn", h.AddDevice)
+	router.DELETE("/api/v1/workspaces/:id/devices/:device_sn", h.RemoveDevice)
+	router.GET("/api/v1/devices", devices.List)
+	router.GET("/api/v1/telemetry/:device_sn", DeviceScope(db, nil), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router, db
+}
+
+func doWorkspaceRequest(t *testing.T, router *gin.Engine, method, path, scope string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if scope != "" {
+		req.Header.Set(scopeHeader, scope)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestWorkspace_Lifecycle(t *testing.T) {
+	router, db := setupWorkspaceRouter(t)
+	require.NoError(t, db.Create(&models.Device{DeviceSN: "DOCK1", DeviceName: "Dock", DeviceType: "dock"}).Error)
+
+	w := doWorkspaceRequest(t, router, http.MethodPost, "/api/v1/workspaces", "", CreateWorkspaceRequest{ID: "ws1", Name: "Site A"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = doWorkspaceRequest(t, router, http.MethodPost, "/api/v1/workspaces", "", CreateWorkspaceRequest{ID: "ws1", Name: "Site A"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = doWorkspaceRequest(t, router, http.MethodPut, "/api/v1/workspaces/ws1/devices/DOCK1", "", nil)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/workspaces/ws1/devices", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var devices ListWorkspaceDevicesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &devices))
+	require.Len(t, devices.Devices, 1)
+	assert.Equal(t, "DOCK1", devices.Devices[0].DeviceSN)
+
+	w = doWorkspaceRequest(t, router, http.MethodDelete, "/api/v1/workspaces/ws1", "", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = doWorkspaceRequest(t, router, http.MethodDelete, "/api/v1/workspaces/ws1/devices/DOCK1", "", nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = doWorkspaceRequest(t, router, http.MethodDelete, "/api/v1/workspaces/ws1", "", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/workspaces/ws1", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestWorkspace_Scoped(t *testing.T) {
+	router, db := setupWorkspaceRouter(t)
+	require.NoError(t, db.Create(&[]models.Workspace{{ID: "ws1", Name: "A"}, {ID: "ws2", Name: "B"}}).Error)
+	require.NoError(t, db.Create(&[]models.Device{
+		{DeviceSN: "DOCK1", DeviceName: "Dock", DeviceType: "dock", WorkspaceID: "ws1"},
+		{DeviceSN: "DOCK2", DeviceName: "Dock", DeviceType: "dock", WorkspaceID: "ws2"},
+	}).Error)
+
+	w := doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/workspaces", "ws1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListWorkspacesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Workspaces, 1)
+	assert.Equal(t, "ws1", list.Workspaces[0].ID)
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/workspaces/ws2", "ws1", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	name := "Renamed"
+	w = doWorkspaceRequest(t, router, http.MethodPut, "/api/v1/workspaces/ws1", "ws1", UpdateWorkspaceRequest{Name: &name})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodPost, "/api/v1/workspaces"},
+		{http.MethodDelete, "/api/v1/workspaces/ws1"},
+		{http.MethodPut, "/api/v1/workspaces/ws1/devices/DOCK2"},
+	} {
+		w = doWorkspaceRequest(t, router, req.method, req.path, "ws1", CreateWorkspaceRequest{Name: "X"})
+		assert.Equal(t, http.StatusForbidden, w.Code, req.path)
+	}
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/devices", "ws1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var deviceList ListDevicesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deviceList))
+	require.Len(t, deviceList.Devices, 1)
+	assert.Equal(t, "DOCK1", deviceList.Devices[0].DeviceSN)
+
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/telemetry/DOCK1", "ws1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doWorkspaceRequest(t, router, http.MethodGet, "/api/v1/telemetry/DOCK2", "ws1", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -2,17 +2,26 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/account"
 )
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
 	// APIKeys is a list of valid API keys
 	APIKeys []string
+	// KeyWorkspaces confines API keys to a workspace; keys without an
+	// entry have access to every workspace
+	KeyWorkspaces map[string]string
+	// Authenticator resolves credentials other than APIKeys, such as JWT
+	// access tokens and managed API keys; APIKeys act as administrators
+	Authenticator Authenticator
 	// HeaderName is the header name for API key
 	HeaderName string
 	// SkipPaths are paths that don't require authentication
@@ -89,23 +98,74 @@ func (m *AuthMiddleware) Handler() gin.HandlerFunc {
 
 		// Validate API key
 		if !m.apiKeys[apiKey] {
-			m.logger.WithFields(logrus.Fields{
-				"path":    path,
-				"api_key": maskAPIKey(apiKey),
-			}).Warn("Invalid API key")
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"code":    "UNAUTHORIZED",
-				"message": "Invalid API key",
-			})
+			m.authenticate(c, apiKey)
 			return
 		}
 
 		// Set API key in context for later use
 		c.Set("api_key", apiKey)
+		SetIdentity(c, &account.Identity{
+			Subject:     "static_key",
+			Name:        maskAPIKey(apiKey),
+			Role:        account.RoleAdmin,
+			WorkspaceID: m.config.KeyWorkspaces[apiKey],
+		})
 		c.Next()
 	}
 }
 
+// authenticate resolves a credential that is not a static API key with the
+// configured Authenticator.
+func (m *AuthMiddleware) authenticate(c *gin.Context, credential string) {
+	path := c.Request.URL.Path
+	if m.config.Authenticator == nil {
+		m.logger.WithFields(logrus.Fields{
+			"path":    path,
+			"api_key": maskAPIKey(credential),
+		}).Warn("Invalid API key")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"code":    "UNAUTHORIZED",
+			"message": "Invalid API key",
+		})
+		return
+	}
+
+	identity, err := m.config.Authenticator.Authenticate(c.Request.Context(), credential)
+	if err != nil {
+		m.reject(c, credential, err)
+		return
+	}
+
+	SetIdentity(c, identity)
+	c.Next()
+}
+
+// reject aborts a request whose credential the Authenticator refused.
+func (m *AuthMiddleware) reject(c *gin.Context, credential string, err error) {
+	switch {
+	case errors.Is(err, account.ErrTokenExpired):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"code":    "TOKEN_EXPIRED",
+			"message": "Access token has expired",
+		})
+	case errors.Is(err, account.ErrInvalidCredentials), errors.Is(err, account.ErrInvalidToken):
+		m.logger.WithFields(logrus.Fields{
+			"path":       c.Request.URL.Path,
+			"credential": maskAPIKey(credential),
+		}).Warn("Invalid credentials")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"code":    "UNAUTHORIZED",
+			"message": "Invalid credentials",
+		})
+	default:
+		m.logger.WithError(err).WithField("path", c.Request.URL.Path).Error("Failed to authenticate")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"code":    "INTERNAL_ERROR",
+			"message": "Failed to authenticate",
+		})
+	}
+}
+
 // AddAPIKey adds an API key to the allowed list
 func (m *AuthMiddleware) AddAPIKey(key string) {
 	m.apiKeys[key] = true
@@ -93,6 +93,40 @@ func TestAuthMiddleware_ValidBearerToken(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestAuthMiddleware_WorkspaceScope(t *testing.T) {
+	config := &AuthConfig{
+		APIKeys:       []string{"admin-key", "tenant-key"},
+		KeyWorkspaces: map[string]string{"tenant-key": "ws1"},
+		HeaderName:    "X-API-Key",
+	}
+	middleware := NewAuthMiddleware(config, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.Handler())
+	router.GET("/api/v1/test", func(c *gin.Context) {
+		workspaceID, scoped := GetWorkspaceScope(c)
+		c.JSON(http.StatusOK, gin.H{"workspace_id": workspaceID, "scoped": scoped})
+	})
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"admin-key", `{"scoped":false,"workspace_id":""}`},
+		{"tenant-key", `{"scoped":true,"workspace_id":"ws1"}`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v1/test", nil)
+		r.Header.Set("X-API-Key", tt.key)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, tt.want, w.Body.String())
+	}
+}
+
 func TestAuthMiddleware_MissingAPIKey(t *testing.T) {
 	config := &AuthConfig{
 		APIKeys:    []string{"valid-key"},
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/utmos/utmos/internal/account"
+)
+
+// identityKey is the gin context key holding the caller's account.Identity.
+const identityKey = "identity"
+
+// Authenticator resolves bearer credentials, such as JWT access tokens and
+// managed API keys, to identities.
+type Authenticator interface {
+	Authenticate(ctx context.Context, credential string) (*account.Identity, error)
+}
+
+// Authenticators tries each Authenticator in turn and returns the first
+// identity. A credential every Authenticator rejects is reported as
+// account.ErrInvalidCredentials; other errors, such as an expired token, are
+// returned at once.
+type Authenticators []Authenticator
+
+// Authenticate implements Authenticator.
+func (a Authenticators) Authenticate(ctx context.Context, credential string) (*account.Identity, error) {
+	for _, authenticator := range a {
+		identity, err := authenticator.Authenticate(ctx, credential)
+		if err == nil {
+			return identity, nil
+		}
+		if !isInvalidCredential(err) {
+			return nil, err
+		}
+	}
+	return nil, account.ErrInvalidCredentials
+}
+
+// isInvalidCredential reports whether err rejects an unknown credential.
+func isInvalidCredential(err error) bool {
+	return errors.Is(err, account.ErrInvalidCredentials) ||
+		errors.Is(err, account.ErrInvalidToken) ||
+		errors.Is(err, ErrInvalidPilotToken)
+}
+
+// SetIdentity records the authenticated caller and confines the request to
+// the caller's workspace, if any.
+func SetIdentity(c *gin.Context, identity *account.Identity) {
+	c.Set(identityKey, identity)
+	if identity.WorkspaceID != "" {
+		SetWorkspaceScope(c, identity.WorkspaceID)
+	}
+}
+
+// GetIdentity returns the identity set by the auth middleware.
+func GetIdentity(c *gin.Context) (*account.Identity, bool) {
+	v, ok := c.Get(identityKey)
+	if !ok {
+		return nil, false
+	}
+	identity, ok := v.(*account.Identity)
+	return identity, ok
+}
+
+// HasPermission reports whether the caller may take an action. Requests
+// without an identity are allowed: they only reach handlers when
+// authentication is disabled.
+func HasPermission(c *gin.Context, permission account.Permission) bool {
+	identity, ok := GetIdentity(c)
+	return !ok || identity.Can(permission)
+}
+
+// RequirePermission rejects callers whose role lacks the permission.
+func RequirePermission(permission account.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, permission) {
+			AbortForbidden(c, permission)
+			return
+		}
+		c.Next()
+	}
+}
+
+// AbortForbidden writes a 403 response for a caller lacking a permission.
+func AbortForbidden(c *gin.Context, permission account.Permission) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"code":    "FORBIDDEN",
+		"message": "Permission required: " + string(permission),
+	})
+}
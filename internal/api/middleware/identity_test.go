@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/utmos/utmos/internal/account"
+)
+
+type fakeAuthenticator map[string]*account.Identity
+
+func (f fakeAuthenticator) Authenticate(_ context.Context, credential string) (*account.Identity, error) {
+	switch credential {
+	case "expired":
+		return nil, account.ErrTokenExpired
+	case "broken":
+		return nil, errors.New("database is down")
+	}
+	identity, ok := f[credential]
+	if !ok {
+		return nil, account.ErrInvalidCredentials
+	}
+	return identity, nil
+}
+
+func TestAuthMiddleware_Authenticator(t *testing.T) {
+	m := NewAuthMiddleware(&AuthConfig{
+		APIKeys:    []string{"static-key"},
+		HeaderName: "X-API-Key",
+		Authenticator: fakeAuthenticator{
+			"viewer-token": {Name: "vera", Role: account.RoleViewer, WorkspaceID: "ws1"},
+			"pilot-token":  {Name: "paul", Role: account.RolePilot},
+		},
+	}, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(m.Handler())
+	router.GET("/read", func(c *gin.Context) {
+		identity, _ := GetIdentity(c)
+		scope, _ := GetWorkspaceScope(c)
+		c.JSON(http.StatusOK, gin.H{"name": identity.Name, "workspace": scope})
+	})
+	router.POST("/fly", RequirePermission(account.PermissionFlightControl), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do(http.MethodGet, "/read", "viewer-token")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"name":"vera","workspace":"ws1"}`, w.Body.String())
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/fly", "viewer-token").Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodPost, "/fly", "pilot-token").Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodPost, "/fly", "static-key").Code, "static keys act as administrators")
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/read", "unknown").Code)
+	w = do(http.MethodGet, "/read", "expired")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "TOKEN_EXPIRED")
+	assert.Equal(t, http.StatusInternalServerError, do(http.MethodGet, "/read", "broken").Code)
+}
+
+func TestRequirePermission_WithoutAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/fly", RequirePermission(account.PermissionFlightControl), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/fly", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code, "requests are not checked when auth is disabled")
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/account"
+)
+
+// PilotAuthHeader is the header DJI Pilot 2 sends its session token in.
+const PilotAuthHeader = "x-auth-token"
+
+// pilotIdentityKey is the gin context key holding the resolved PilotIdentity.
+const pilotIdentityKey = "pilot_identity"
+
+// ErrInvalidPilotToken is returned for unknown Pilot tokens.
+var ErrInvalidPilotToken = errors.New("invalid pilot token")
+
+// PilotIdentity is the caller of a Pilot 2 route, confined to the workspace
+// of the route.
+type PilotIdentity struct {
+	WorkspaceID string
+	Username    string
+	// Role is the role of a static token; RolePilot when empty
+	Role account.Role
+}
+
+// StaticPilotTokens authenticates tokens from a fixed token -> identity map.
+// Every token must name a workspace.
+type StaticPilotTokens map[string]PilotIdentity
+
+// ParsePilotTokens parses token=workspace_id[:username[:role]] entries
+// separated by commas, as in the PILOT_TOKENS environment variable. Tokens
+// act as pilots unless a role is given; entries without a workspace or with
+// an unknown role are skipped.
+func ParsePilotTokens(spec string) StaticPilotTokens {
+	tokens := StaticPilotTokens{}
+	for _, entry := range strings.Split(spec, ",") {
+		token, identity, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || token == "" {
+			continue
+		}
+		workspaceID, user, _ := strings.Cut(identity, ":")
+		username, role, _ := strings.Cut(user, ":")
+		if workspaceID == "" || (role != "" && !account.Role(role).Valid()) {
+			continue
+		}
+		tokens[token] = PilotIdentity{WorkspaceID: workspaceID, Username: username, Role: account.Role(role)}
+	}
+	return tokens
+}
+
+// Authenticate implements Authenticator.
+func (s StaticPilotTokens) Authenticate(_ context.Context, token string) (*account.Identity, error) {
+	pilot, ok := s[token]
+	if !ok || pilot.WorkspaceID == "" {
+		return nil, ErrInvalidPilotToken
+	}
+	role := pilot.Role
+	if role == "" {
+		role = account.RolePilot
+	}
+	return &account.Identity{
+		Subject:     "pilot_token",
+		Name:        pilot.Username,
+		Role:        role,
+		WorkspaceID: pilot.WorkspaceID,
+	}, nil
+}
+
+// PilotAuth authenticates Pilot 2 requests by x-auth-token and rejects
+// requests whose :workspace_id path parameter differs from the caller's
+// workspace. The caller's identity is recorded like the API auth middleware
+// does, so role permissions apply. Errors use the Pilot response envelope.
+func PilotAuth(authenticator Authenticator, logger *logrus.Entry) gin.HandlerFunc {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	logger = logger.WithField("middleware", "pilot_auth")
+
+	return func(c *gin.Context) {
+		token := c.GetHeader(PilotAuthHeader)
+		if token == "" || authenticator == nil {
+			abortPilot(c, http.StatusUnauthorized, "x-auth-token is required")
+			return
+		}
+
+		identity, err := authenticator.Authenticate(c.Request.Context(), token)
+		switch {
+		case errors.Is(err, account.ErrTokenExpired):
+			abortPilot(c, http.StatusUnauthorized, "x-auth-token has expired")
+			return
+		case isInvalidCredential(err):
+			logger.WithField("path", c.Request.URL.Path).Warn("Invalid pilot token")
+			abortPilot(c, http.StatusUnauthorized, "invalid x-auth-token")
+			return
+		case err != nil:
+			logger.WithError(err).WithField("path", c.Request.URL.Path).Error("Failed to authenticate pilot token")
+			abortPilot(c, http.StatusInternalServerError, "failed to authenticate")
+			return
+		}
+
+		workspaceID := c.Param("workspace_id")
+		if identity.WorkspaceID != "" && workspaceID != "" && workspaceID != identity.WorkspaceID {
+			logger.WithFields(logrus.Fields{
+				"path":         c.Request.URL.Path,
+				"workspace_id": workspaceID,
+			}).Warn("Pilot token used outside its workspace")
+			abortPilot(c, http.StatusForbidden, "workspace not accessible with this token")
+			return
+		}
+		if workspaceID == "" {
+			workspaceID = identity.WorkspaceID
+		}
+
+		SetIdentity(c, identity)
+		c.Set(pilotIdentityKey, &PilotIdentity{
+			WorkspaceID: workspaceID,
+			Username:    identity.Name,
+			Role:        identity.Role,
+		})
+		c.Next()
+	}
+}
+
+// RequirePilotPermission rejects Pilot callers whose role lacks the
+// permission, with the Pilot response envelope.
+func RequirePilotPermission(permission account.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, permission) {
+			abortPilot(c, http.StatusForbidden, "permission required: "+string(permission))
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetPilotIdentity returns the identity set by PilotAuth.
+func GetPilotIdentity(c *gin.Context) (*PilotIdentity, bool) {
+	v, ok := c.Get(pilotIdentityKey)
+	if !ok {
+		return nil, false
+	}
+	identity, ok := v.(*PilotIdentity)
+	return identity, ok
+}
+
+func abortPilot(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"code":    status,
+		"message": message,
+		"data":    nil,
+	})
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/utmos/utmos/internal/account"
+)
+
+func TestPilotAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := StaticPilotTokens{
+		"tok":    {WorkspaceID: "ws1", Username: "pilot"},
+		"viewer": {WorkspaceID: "ws1", Username: "vera", Role: account.RoleViewer},
+	}
+	accounts := fakeAuthenticator{
+		"jwt-ws1":   {Name: "olga", Role: account.RoleOperator, WorkspaceID: "ws1"},
+		"jwt-admin": {Name: "root", Role: account.RoleAdmin},
+	}
+
+	router := gin.New()
+	group := router.Group("/workspaces/:workspace_id", PilotAuth(Authenticators{tokens, accounts}, nil))
+	group.GET("/x", func(c *gin.Context) {
+		identity, ok := GetPilotIdentity(c)
+		assert.True(t, ok)
+		c.String(http.StatusOK, identity.WorkspaceID+"/"+identity.Username)
+	})
+	group.POST("/x", RequirePilotPermission(account.PermissionWrite), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"valid token", http.MethodGet, "/workspaces/ws1/x", "tok", http.StatusOK, "ws1/pilot"},
+		{"missing token", http.MethodGet, "/workspaces/ws1/x", "", http.StatusUnauthorized, ""},
+		{"unknown token", http.MethodGet, "/workspaces/ws1/x", "nope", http.StatusUnauthorized, ""},
+		{"other workspace", http.MethodGet, "/workspaces/ws2/x", "tok", http.StatusForbidden, ""},
+		{"static token writes as pilot", http.MethodPost, "/workspaces/ws1/x", "tok", http.StatusOK, ""},
+		{"viewer token cannot write", http.MethodPost, "/workspaces/ws1/x", "viewer", http.StatusForbidden, ""},
+		{"account token", http.MethodPost, "/workspaces/ws1/x", "jwt-ws1", http.StatusOK, ""},
+		{"account token in other workspace", http.MethodGet, "/workspaces/ws2/x", "jwt-ws1", http.StatusForbidden, ""},
+		{"unconfined account", http.MethodGet, "/workspaces/ws2/x", "jwt-admin", http.StatusOK, "ws2/root"},
+		{"expired account token", http.MethodGet, "/workspaces/ws1/x", "expired", http.StatusUnauthorized, ""},
+		{"authenticator failure", http.MethodGet, "/workspaces/ws1/x", "broken", http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set(PilotAuthHeader, tt.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestParsePilotTokens(t *testing.T) {
+	tokens := ParsePilotTokens("a=ws1, b=ws2:bob:viewer,c=ws3:carl:root,d=,=ws4")
+	assert.Equal(t, StaticPilotTokens{
+		"a": {WorkspaceID: "ws1"},
+		"b": {WorkspaceID: "ws2", Username: "bob", Role: account.RoleViewer},
+	}, tokens)
+	assert.Empty(t, ParsePilotTokens(""))
+}
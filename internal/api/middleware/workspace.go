@@ -0,0 +1,19 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// workspaceScopeKey is the gin context key holding the workspace an
+// authenticated caller is confined to.
+const workspaceScopeKey = "workspace_scope"
+
+// SetWorkspaceScope confines the request to a workspace.
+func SetWorkspaceScope(c *gin.Context, workspaceID string) {
+	c.Set(workspaceScopeKey, workspaceID)
+}
+
+// GetWorkspaceScope returns the workspace the caller is confined to. ok is
+// false for callers with access to every workspace.
+func GetWorkspaceScope(c *gin.Context) (string, bool) {
+	workspaceID := c.GetString(workspaceScopeKey)
+	return workspaceID, workspaceID != ""
+}
@@ -2,6 +2,7 @@
 package api
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -10,10 +11,21 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"gorm.io/gorm"
 
+	"github.com/utmos/utmos/internal/account"
 	"github.com/utmos/utmos/internal/api/handler"
 	"github.com/utmos/utmos/internal/api/middleware"
 	"github.com/utmos/utmos/internal/downlink/dispatcher"
+	"github.com/utmos/utmos/internal/flightarea"
+	"github.com/utmos/utmos/internal/mapelement"
+	"github.com/utmos/utmos/internal/media"
+	"github.com/utmos/utmos/internal/scheduler"
+	"github.com/utmos/utmos/internal/topology"
+	"github.com/utmos/utmos/internal/unlocklicense"
+	"github.com/utmos/utmos/internal/wayline"
+	"github.com/utmos/utmos/internal/workspace"
+	"github.com/utmos/utmos/pkg/adapter/dji/wpml"
 	"github.com/utmos/utmos/pkg/metrics"
+	"github.com/utmos/utmos/pkg/objectstore"
 
 	// Import swagger docs
 	_ "github.com/utmos/utmos/docs/swagger"
@@ -23,6 +35,12 @@ import (
 type Config struct {
 	// APIKeys for authentication
 	APIKeys []string
+	// APIKeyWorkspaces confines API keys to a workspace; keys not listed
+	// may access every workspace
+	APIKeyWorkspaces map[string]string
+	// Accounts enables user accounts, JWT sign-in and managed API keys;
+	// only the static APIKeys are accepted when it is nil
+	Accounts *account.Config
 	// EnableAuth enables authentication middleware
 	EnableAuth bool
 	// EnableTrace enables tracing middleware
@@ -31,6 +49,33 @@ type Config struct {
 	ServiceName string
 	// TelemetryConfig for telemetry handler
 	TelemetryConfig *handler.TelemetryConfig
+	// EnableScheduler starts the flight task scheduler loop
+	EnableScheduler bool
+	// SchedulerConfig for the flight task scheduler
+	SchedulerConfig *scheduler.Config
+	// ObjectStore holds wayline files and other blobs; wayline routes
+	// respond 503 when it is nil
+	ObjectStore objectstore.Store
+	// ObjectCredentials issues temporary upload credentials to Pilot 2
+	ObjectCredentials objectstore.CredentialProvider
+	// ObjectURLSigner verifies signed URLs served under /objects
+	ObjectURLSigner *objectstore.URLSigner
+	// PilotTokens authenticates static DJI Pilot 2 x-auth-token values;
+	// Pilot routes also accept account access tokens and API keys
+	PilotTokens middleware.StaticPilotTokens
+	// WorkspaceNotifier pushes workspace events such as map element and
+	// device topology changes to WebSocket clients; changes are not pushed
+	// when it is nil
+	WorkspaceNotifier mapelement.Notifier
+	// Terrain resolves above ground level and EGM96 waypoint heights of
+	// created waylines; they are converted unchanged when it is nil
+	Terrain *wpml.TerrainModel
+	// MinTerrainClearance rejects waylines passing closer to the terrain,
+	// in meters; clearance is not checked when it is zero
+	MinTerrainClearance float64
+	// Geofences are the no-fly zones and geofences every leg of a wayline
+	// is checked against
+	Geofences []wpml.Geofence
 }
 
 // DefaultConfig returns default router configuration
@@ -51,6 +96,26 @@ type Router struct {
 	deviceHandler    *handler.Device
 	serviceHandler   *handler.Service
 	telemetryHandler *handler.Telemetry
+	flightTask       *handler.FlightTask
+	wayline          *handler.Wayline
+	pilotWayline     *handler.PilotWayline
+	pilotStorage     *handler.PilotStorage
+	media            *handler.Media
+	pilotMedia       *handler.PilotMedia
+	mediaService     *media.Service
+	pilotMap         *handler.PilotMap
+	pilotDevice      *handler.PilotDevice
+	topologyService  *topology.Service
+	workspace        *handler.Workspace
+	flightArea       *handler.FlightArea
+	flightAreas      *flightarea.Service
+	unlockLicense    *handler.UnlockLicense
+	unlockLicenses   *unlocklicense.Service
+	accountService   *account.Service
+	auth             *handler.Auth
+	account          *handler.Account
+	object           *handler.Object
+	scheduler        *scheduler.Scheduler
 }
 
 // NewRouter creates a new API router
@@ -98,6 +163,91 @@ func NewRouter(
 		telemetryHandler = handler.NewTelemetry(config.TelemetryConfig, logger)
 	}
 
+	var taskDispatcher scheduler.Dispatcher
+	if dispatchHandler != nil {
+		taskDispatcher = dispatchHandler
+	}
+	taskScheduler := scheduler.NewScheduler(config.SchedulerConfig, db, taskDispatcher, nil, nil, logger)
+	flightTaskHandler := handler.NewFlightTask(db, taskScheduler, logger)
+
+	var waylineService *wayline.Service
+	if config.ObjectStore != nil && db != nil {
+		svc, err := wayline.NewService(db, config.ObjectStore, logger)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to create wayline service")
+		} else {
+			waylineService = svc
+			if config.Terrain != nil {
+				waylineService.SetTerrain(config.Terrain, config.MinTerrainClearance)
+			}
+			if err := waylineService.SetGeofences(config.Geofences); err != nil {
+				logger.WithError(err).Warn("Failed to set wayline geofences")
+			}
+		}
+	}
+	if waylineService != nil {
+		taskScheduler.SetWaylineURLs(waylineService)
+	}
+	waylineHandler := handler.NewWayline(db, waylineService, logger)
+	pilotWaylineHandler := handler.NewPilotWayline(db, waylineService, logger)
+	pilotStorageHandler := handler.NewPilotStorage(config.ObjectCredentials, logger)
+
+	var mediaService *media.Service
+	if db != nil {
+		mediaService = media.NewService(db, logger)
+	}
+	mediaHandler := handler.NewMedia(db, logger)
+	pilotMediaHandler := handler.NewPilotMedia(mediaService, config.ObjectStore, logger)
+
+	var mapElementService *mapelement.Service
+	if db != nil {
+		mapElementService = mapelement.NewService(db, config.WorkspaceNotifier, logger)
+	}
+	pilotMapHandler := handler.NewPilotMap(mapElementService, logger)
+
+	var topologyService *topology.Service
+	if db != nil {
+		topologyService = topology.NewService(db, config.WorkspaceNotifier, logger)
+	}
+	pilotDeviceHandler := handler.NewPilotDevice(topologyService, logger)
+
+	var workspaceService *workspace.Service
+	if db != nil {
+		workspaceService = workspace.NewService(db, logger)
+		if dispatchHandler != nil {
+			dispatchHandler.SetWorkspaceAuthorizer(workspaceService)
+		}
+	}
+	workspaceHandler := handler.NewWorkspace(workspaceService, logger)
+
+	var flightAreaService *flightarea.Service
+	if config.ObjectStore != nil && db != nil {
+		flightAreaService = flightarea.NewService(db, config.ObjectStore, taskDispatcher, logger)
+		if waylineService != nil {
+			waylineService.SetWorkspaceGeofences(flightAreaService)
+		}
+	}
+	flightAreaHandler := handler.NewFlightArea(flightAreaService, logger)
+
+	var unlockLicenseService *unlocklicense.Service
+	if config.ObjectStore != nil && db != nil {
+		unlockLicenseService = unlocklicense.NewService(db, config.ObjectStore, taskDispatcher, logger)
+	}
+	unlockLicenseHandler := handler.NewUnlockLicense(unlockLicenseService, logger)
+
+	var accountService *account.Service
+	if db != nil && config.Accounts != nil {
+		svc, err := account.NewService(db, config.Accounts, logger)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to create account service")
+		} else {
+			accountService = svc
+		}
+	}
+	authHandler := handler.NewAuth(accountService, logger)
+	accountHandler := handler.NewAccount(accountService, logger)
+	objectHandler := handler.NewObject(config.ObjectStore, config.ObjectURLSigner, logger)
+
 	router := &Router{
 		engine:           engine,
 		config:           config,
@@ -106,12 +256,39 @@ func NewRouter(
 		deviceHandler:    deviceHandler,
 		serviceHandler:   serviceHandler,
 		telemetryHandler: telemetryHandler,
+		flightTask:       flightTaskHandler,
+		wayline:          waylineHandler,
+		pilotWayline:     pilotWaylineHandler,
+		pilotStorage:     pilotStorageHandler,
+		media:            mediaHandler,
+		pilotMedia:       pilotMediaHandler,
+		mediaService:     mediaService,
+		pilotMap:         pilotMapHandler,
+		pilotDevice:      pilotDeviceHandler,
+		topologyService:  topologyService,
+		workspace:        workspaceHandler,
+		flightArea:       flightAreaHandler,
+		flightAreas:      flightAreaService,
+		unlockLicense:    unlockLicenseHandler,
+		unlockLicenses:   unlockLicenseService,
+		accountService:   accountService,
+		auth:             authHandler,
+		account:          accountHandler,
+		object:           objectHandler,
+		scheduler:        taskScheduler,
 	}
 
 	// Setup routes
 	router.setupHealthRoutes()
 	router.setupMetricsRoute(metricsCollector)
 	router.setupAPIRoutes()
+	router.setupPilotRoutes()
+
+	if config.EnableScheduler && db != nil {
+		if err := taskScheduler.Start(context.Background()); err != nil {
+			logger.WithError(err).Warn("Failed to start flight task scheduler")
+		}
+	}
 
 	return router
 }
@@ -178,41 +355,155 @@ func (r *Router) setupAPIRoutes() {
 	api := r.engine.Group("/api/v1")
 
 	// Add auth middleware if enabled
-	if r.config.EnableAuth && len(r.config.APIKeys) > 0 {
-		authMiddleware := middleware.NewAuthMiddleware(&middleware.AuthConfig{
-			APIKeys:    r.config.APIKeys,
-			HeaderName: "X-API-Key",
-			SkipPaths:  []string{},
-		}, r.logger)
-		api.Use(authMiddleware.Handler())
+	if r.config.EnableAuth && (len(r.config.APIKeys) > 0 || r.accountService != nil) {
+		authConfig := &middleware.AuthConfig{
+			APIKeys:       r.config.APIKeys,
+			KeyWorkspaces: r.config.APIKeyWorkspaces,
+			HeaderName:    "X-API-Key",
+			SkipPaths:     []string{"/api/v1/auth/login", "/api/v1/auth/refresh"},
+		}
+		if r.accountService != nil {
+			authConfig.Authenticator = r.accountService
+		}
+		api.Use(middleware.NewAuthMiddleware(authConfig, r.logger).Handler())
+	}
+
+	// Role permissions; reading only needs to be authenticated
+	write := middleware.RequirePermission(account.PermissionWrite)
+	serviceCall := middleware.RequirePermission(account.PermissionServiceCall)
+	flightControl := middleware.RequirePermission(account.PermissionFlightControl)
+	admin := middleware.RequirePermission(account.PermissionAdmin)
+
+	// Sign-in and token routes
+	auth := api.Group("/auth")
+	{
+		auth.POST("/login", r.auth.Login)
+		auth.POST("/refresh", r.auth.Refresh)
+		auth.POST("/logout", r.auth.Logout)
+		auth.GET("/me", r.auth.Me)
+	}
+
+	// User and API key management routes
+	users := api.Group("/users", admin)
+	{
+		users.POST("", r.account.CreateUser)
+		users.GET("", r.account.ListUsers)
+		users.GET("/:id", r.account.GetUser)
+		users.PUT("/:id", r.account.UpdateUser)
+		users.DELETE("/:id", r.account.DeleteUser)
+	}
+
+	apiKeys := api.Group("/api-keys", admin)
+	{
+		apiKeys.POST("", r.account.CreateAPIKey)
+		apiKeys.GET("", r.account.ListAPIKeys)
+		apiKeys.DELETE("/:id", r.account.RevokeAPIKey)
+	}
+
+	// Workspace routes
+	workspaces := api.Group("/workspaces")
+	{
+		workspaces.POST("", admin, r.workspace.Create)
+		workspaces.GET("", r.workspace.List)
+		workspaces.GET("/:id", r.workspace.Get)
+		workspaces.PUT("/:id", admin, r.workspace.Update)
+		workspaces.DELETE("/:id", admin, r.workspace.Delete)
+		workspaces.GET("/:id/devices", r.workspace.ListDevices)
+		workspaces.PUT("/:id/devices/:device_sn", admin, r.workspace.AddDevice)
+		workspaces.DELETE("/:id/devices/:device_sn", admin, r.workspace.RemoveDevice)
+		workspaces.GET("/:id/flight-areas", r.flightArea.List)
+		workspaces.POST("/:id/flight-areas", write, r.flightArea.Create)
+		workspaces.GET("/:id/flight-areas/:area_id", r.flightArea.Get)
+		workspaces.PUT("/:id/flight-areas/:area_id", write, r.flightArea.Update)
+		workspaces.DELETE("/:id/flight-areas/:area_id", write, r.flightArea.Delete)
+		workspaces.GET("/:id/flight-area-sync", r.flightArea.SyncStatus)
+		workspaces.POST("/:id/flight-area-sync", write, r.flightArea.Sync)
+		workspaces.GET("/:id/unlock-licenses/files", r.unlockLicense.ListFiles)
+		workspaces.POST("/:id/unlock-licenses/files", write, r.unlockLicense.UploadFile)
+		workspaces.DELETE("/:id/unlock-licenses/files/:file_id", write, r.unlockLicense.DeleteFile)
+		workspaces.POST("/:id/unlock-licenses/files/:file_id/push", flightControl, r.unlockLicense.PushFile)
+		workspaces.GET("/:id/unlock-licenses/audits", r.unlockLicense.ListAudits)
+		workspaces.GET("/:id/devices/:device_sn/unlock-licenses", r.unlockLicense.ListDeviceLicenses)
+		workspaces.POST("/:id/devices/:device_sn/unlock-licenses/refresh", serviceCall, r.unlockLicense.RefreshDeviceLicenses)
+		workspaces.PUT("/:id/devices/:device_sn/unlock-licenses/:license_id", flightControl, r.unlockLicense.SwitchDeviceLicense)
 	}
 
 	// Device routes
 	devices := api.Group("/devices")
 	{
-		devices.POST("", r.deviceHandler.Create)
+		devices.POST("", write, r.deviceHandler.Create)
 		devices.GET("", r.deviceHandler.List)
 		devices.GET("/:id", r.deviceHandler.Get)
 		devices.GET("/sn/:sn", r.deviceHandler.GetBySN)
-		devices.PUT("/:id", r.deviceHandler.Update)
-		devices.DELETE("/:id", r.deviceHandler.Delete)
+		devices.PUT("/:id", write, r.deviceHandler.Update)
+		devices.DELETE("/:id", write, r.deviceHandler.Delete)
 	}
 
-	// Service call routes
+	// Service call routes; flight control methods are further restricted
+	// by the handler
 	services := api.Group("/services")
 	{
-		services.POST("/call", r.serviceHandler.Call)
+		services.POST("/call", serviceCall, r.serviceHandler.Call)
 		services.GET("/calls/:id", r.serviceHandler.Get)
 		services.GET("/calls/device/:device_sn", r.serviceHandler.ListByDevice)
-		services.POST("/calls/:id/cancel", r.serviceHandler.Cancel)
+		services.POST("/calls/:id/cancel", serviceCall, r.serviceHandler.Cancel)
 
 		// Note: Vendor-specific routes (e.g., /dji/takeoff) have been removed.
 		// Use the generic /call endpoint with vendor and method parameters instead.
 	}
 
+	// Flight task routes
+	flightTasks := api.Group("/flight-tasks")
+	{
+		flightTasks.POST("", flightControl, r.flightTask.Create)
+		flightTasks.GET("", r.flightTask.List)
+		flightTasks.GET("/:id", r.flightTask.Get)
+		flightTasks.PUT("/:id", flightControl, r.flightTask.Update)
+		flightTasks.DELETE("/:id", flightControl, r.flightTask.Delete)
+		flightTasks.GET("/:id/runs", r.flightTask.ListRuns)
+	}
+
+	blackouts := api.Group("/blackout-windows")
+	{
+		blackouts.POST("", write, r.flightTask.CreateBlackout)
+		blackouts.GET("", r.flightTask.ListBlackouts)
+		blackouts.DELETE("/:id", write, r.flightTask.DeleteBlackout)
+	}
+
+	// Wayline file routes
+	waylines := api.Group("/waylines")
+	{
+		waylines.POST("", write, r.wayline.Create)
+		waylines.POST("/upload", write, r.wayline.Upload)
+		waylines.POST("/import", write, r.wayline.Import)
+		waylines.GET("", r.wayline.List)
+		waylines.GET("/duplicate-names", r.wayline.DuplicateNames)
+		waylines.GET("/:id", r.wayline.Get)
+		waylines.GET("/:id/download", r.wayline.Download)
+		waylines.GET("/:id/estimate", r.wayline.Estimate)
+		waylines.GET("/:id/export", r.wayline.Export)
+		waylines.POST("/:id/revisions", write, r.wayline.AddRevision)
+		waylines.GET("/:id/revisions", r.wayline.Revisions)
+		waylines.GET("/:id/revisions/:revision/download", r.wayline.DownloadRevision)
+		waylines.GET("/:id/diff", r.wayline.Diff)
+		waylines.POST("/:id/favorite", write, r.wayline.Favorite)
+		waylines.DELETE("/:id/favorite", write, r.wayline.Unfavorite)
+		waylines.DELETE("/:id", write, r.wayline.Delete)
+	}
+
+	// Media catalog routes
+	mediaFiles := api.Group("/media")
+	{
+		mediaFiles.GET("", r.media.List)
+		mediaFiles.GET("/:id", r.media.Get)
+	}
+
 	// Telemetry routes
 	if r.telemetryHandler != nil {
 		telemetry := api.Group("/telemetry")
+		if r.db != nil {
+			telemetry.Use(handler.DeviceScope(r.db, r.logger))
+		}
 		{
 			telemetry.GET("/:device_sn", r.telemetryHandler.Query)
 			telemetry.GET("/:device_sn/latest", r.telemetryHandler.Latest)
@@ -221,6 +512,64 @@ func (r *Router) setupAPIRoutes() {
 	}
 }
 
+// setupPilotRoutes sets up the DJI Pilot 2 HTTPS API routes. Their paths and
+// response envelope follow the DJI Cloud API contract, so they live outside
+// /api/v1 and authenticate with x-auth-token instead of API keys. The token
+// is a static Pilot token or an account access token or API key, and role
+// permissions apply as on /api/v1.
+func (r *Router) setupPilotRoutes() {
+	var authenticators middleware.Authenticators
+	if len(r.config.PilotTokens) > 0 {
+		authenticators = append(authenticators, r.config.PilotTokens)
+	}
+	if r.accountService != nil {
+		authenticators = append(authenticators, r.accountService)
+	}
+	pilotAuth := middleware.PilotAuth(authenticators, r.logger)
+	write := middleware.RequirePilotPermission(account.PermissionWrite)
+
+	waylines := r.engine.Group("/wayline/api/v1/workspaces/:workspace_id", pilotAuth)
+	{
+		waylines.GET("/waylines", r.pilotWayline.List)
+		waylines.GET("/waylines/duplicate-names", r.pilotWayline.DuplicateNames)
+		waylines.GET("/waylines/:wayline_id/url", r.pilotWayline.DownloadURL)
+		waylines.POST("/upload-callback", write, r.pilotWayline.UploadCallback)
+		waylines.POST("/favorites", write, r.pilotWayline.Favorite)
+		waylines.DELETE("/favorites", write, r.pilotWayline.Unfavorite)
+	}
+
+	// Temporary credentials allow uploads
+	storage := r.engine.Group("/storage/api/v1/workspaces/:workspace_id", pilotAuth, write)
+	{
+		storage.POST("/sts", r.pilotStorage.TemporaryCredential)
+	}
+
+	mediaFiles := r.engine.Group("/media/api/v1/workspaces/:workspace_id", pilotAuth)
+	{
+		mediaFiles.POST("/fast-upload", write, r.pilotMedia.FastUpload)
+		mediaFiles.POST("/files/tiny-fingerprints", r.pilotMedia.TinyFingerprints)
+		mediaFiles.POST("/upload-callback", write, r.pilotMedia.UploadCallback)
+		mediaFiles.POST("/group-upload-callback", write, r.pilotMedia.GroupUploadCallback)
+	}
+
+	mapElements := r.engine.Group("/map/api/v1/workspaces/:workspace_id", pilotAuth)
+	{
+		mapElements.GET("/element-groups", r.pilotMap.ListGroups)
+		mapElements.POST("/element-groups/:group_id/elements", write, r.pilotMap.CreateElement)
+		mapElements.DELETE("/element-groups/:group_id/elements", write, r.pilotMap.ClearGroup)
+		mapElements.PUT("/elements/:element_id", write, r.pilotMap.UpdateElement)
+		mapElements.DELETE("/elements/:element_id", write, r.pilotMap.DeleteElement)
+	}
+
+	manage := r.engine.Group("/manage/api/v1/workspaces/:workspace_id", pilotAuth)
+	{
+		manage.GET("/devices/topologies", r.pilotDevice.Topologies)
+	}
+
+	// Signed object downloads carry their own authorization
+	r.engine.GET("/objects/*key", r.object.Download)
+}
+
 // Engine returns the underlying gin.Engine
 func (r *Router) Engine() *gin.Engine {
 	return r.engine
@@ -231,8 +580,43 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.engine.ServeHTTP(w, req)
 }
 
+// MediaService returns the media catalog service, or nil without a database.
+// Dock upload events are fed into it by the caller.
+func (r *Router) MediaService() *media.Service {
+	return r.mediaService
+}
+
+// TopologyService returns the device topology service, or nil without a
+// database. Device status messages are fed into it by the caller.
+func (r *Router) TopologyService() *topology.Service {
+	return r.topologyService
+}
+
+// FlightAreaService returns the flight area service, or nil without a
+// database or object store. Dock flight area requests and sync progress
+// events are fed into it by the caller.
+func (r *Router) FlightAreaService() *flightarea.Service {
+	return r.flightAreas
+}
+
+// UnlockLicenseService returns the unlock licence service, or nil without a
+// database or object store. Dock replies to licence commands are fed into
+// it by the caller.
+func (r *Router) UnlockLicenseService() *unlocklicense.Service {
+	return r.unlockLicenses
+}
+
+// AccountService returns the account service, or nil when accounts are not
+// configured. The caller bootstraps the first administrator with it.
+func (r *Router) AccountService() *account.Service {
+	return r.accountService
+}
+
 // Close closes any resources held by the router
 func (r *Router) Close() {
+	if r.scheduler != nil {
+		_ = r.scheduler.Stop()
+	}
 	if r.telemetryHandler != nil {
 		r.telemetryHandler.Close()
 	}
@@ -1,9 +1,13 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,6 +15,7 @@ import (
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
+	"github.com/utmos/utmos/internal/account"
 	"github.com/utmos/utmos/internal/api/handler"
 	"github.com/utmos/utmos/internal/downlink/model"
 	"github.com/utmos/utmos/pkg/models"
@@ -172,6 +177,112 @@ func TestRouter_WithAuth(t *testing.T) {
 	})
 }
 
+func TestRouter_WithAccounts(t *testing.T) {
+	db := setupRouterTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.APIKey{}))
+	accounts := account.DefaultConfig()
+	accounts.Secret = []byte(strings.Repeat("s", account.MinSecretLength))
+	router := NewRouter(&Config{EnableAuth: true, Accounts: accounts}, db, nil, nil, nil)
+	require.NotNil(t, router.AccountService())
+
+	ctx := context.Background()
+	for _, role := range []account.Role{account.RoleViewer, account.RoleOperator, account.RolePilot, account.RoleAdmin} {
+		_, err := router.AccountService().CreateUser(ctx, string(role), "password-"+string(role), role, "")
+		require.NoError(t, err)
+	}
+
+	do := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, &buf)
+		r.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, r)
+		return w
+	}
+	login := func(role account.Role) string {
+		w := do(http.MethodPost, "/api/v1/auth/login", "", handler.LoginRequest{
+			Username: string(role), Password: "password-" + string(role),
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp handler.TokenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.AccessToken
+	}
+
+	w := do(http.MethodPost, "/api/v1/auth/login", "", handler.LoginRequest{Username: "viewer", Password: "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/v1/devices", "", nil).Code)
+
+	viewer := login(account.RoleViewer)
+	operator := login(account.RoleOperator)
+	pilot := login(account.RolePilot)
+	admin := login(account.RoleAdmin)
+
+	t.Run("viewers read only", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/devices", viewer, nil).Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1/devices", viewer, map[string]any{}).Code)
+
+		w := do(http.MethodGet, "/api/v1/auth/me", viewer, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var me handler.IdentityResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &me))
+		assert.Equal(t, "viewer", me.Role)
+		assert.Equal(t, []string{"read"}, me.Permissions)
+	})
+
+	t.Run("only pilots fly", func(t *testing.T) {
+		execute := handler.ServiceCallRequest{DeviceSN: "DOCK1", Vendor: "dji", Method: "flighttask_execute"}
+		photo := handler.ServiceCallRequest{DeviceSN: "DOCK1", Vendor: "dji", Method: "camera_photo_take"}
+
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1/services/call", viewer, photo).Code)
+		assert.Equal(t, http.StatusAccepted, do(http.MethodPost, "/api/v1/services/call", operator, photo).Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1/services/call", operator, execute).Code)
+		assert.Equal(t, http.StatusAccepted, do(http.MethodPost, "/api/v1/services/call", pilot, execute).Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1/flight-tasks", operator, map[string]any{}).Code)
+	})
+
+	t.Run("admins manage API keys", func(t *testing.T) {
+		body := handler.CreateAPIKeyRequest{Name: "ci", Role: string(account.RoleViewer)}
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1/api-keys", pilot, body).Code)
+
+		w := do(http.MethodPost, "/api/v1/api-keys", admin, body)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var created handler.CreateAPIKeyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/devices", created.Key, nil).Code)
+
+		assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/v1/api-keys/"+strconv.FormatUint(uint64(created.ID), 10), admin, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/v1/devices", created.Key, nil).Code)
+	})
+
+	t.Run("Pilot routes apply account roles", func(t *testing.T) {
+		pilotDo := func(token string) int {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/storage/api/v1/workspaces/ws1/sts", nil)
+			if token != "" {
+				r.Header.Set("x-auth-token", token)
+			}
+			router.ServeHTTP(w, r)
+			return w.Code
+		}
+		assert.Equal(t, http.StatusUnauthorized, pilotDo(""))
+		assert.Equal(t, http.StatusForbidden, pilotDo(viewer))
+		// Authorized, but no credential provider is configured
+		assert.Equal(t, http.StatusServiceUnavailable, pilotDo(operator))
+	})
+
+	t.Run("logout revokes the token", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do(http.MethodPost, "/api/v1/auth/logout", viewer, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/v1/devices", viewer, nil).Code)
+	})
+}
+
 func TestRouter_WithTelemetry(t *testing.T) {
 	db := setupRouterTestDB(t)
 	config := &Config{
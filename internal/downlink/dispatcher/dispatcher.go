@@ -3,6 +3,7 @@ package dispatcher
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -47,6 +48,15 @@ const (
 	ServiceCallStatusRetrying ServiceCallStatus = "retrying"
 )
 
+// ErrWorkspaceForbidden is returned when a call made on behalf of a
+// workspace targets a device outside that workspace
+var ErrWorkspaceForbidden = errors.New("device is not in the caller's workspace")
+
+// WorkspaceAuthorizer checks device membership of workspaces
+type WorkspaceAuthorizer interface {
+	DeviceInWorkspace(ctx context.Context, workspaceID, deviceSN string) (bool, error)
+}
+
 // ServiceCallParams represents service call parameters as raw JSON.
 // Using json.RawMessage allows for type-safe handling while deferring parsing.
 type ServiceCallParams = json.RawMessage
@@ -55,6 +65,7 @@ type ServiceCallParams = json.RawMessage
 type ServiceCall struct {
 	ID          string            `json:"id"`
 	DeviceSN    string            `json:"device_sn"`
+	WorkspaceID string            `json:"workspace_id,omitempty"`
 	Vendor      string            `json:"vendor"`
 	Method      string            `json:"method"`
 	Params      ServiceCallParams `json:"params"`
@@ -131,6 +142,8 @@ func (d *AdapterDispatcher) Dispatch(ctx context.Context, call *ServiceCall) (*D
 		Vendor:   call.Vendor,
 		Method:   call.Method,
 		Params:   params,
+		TID:      call.TID,
+		BID:      call.BID,
 	}
 
 	result, err := d.adapter.Dispatch(ctx, adapterCall)
@@ -180,6 +193,7 @@ func (r *Registry) GetForCall(call *ServiceCall) (Dispatcher, bool) {
 type DispatchHandler struct {
 	registry     *Registry
 	logger       *logrus.Entry
+	authorizer   WorkspaceAuthorizer
 	onDispatched func(ctx context.Context, call *ServiceCall, result *DispatchResult) error
 }
 
@@ -199,6 +213,13 @@ func (h *DispatchHandler) SetOnDispatched(callback func(ctx context.Context, cal
 	h.onDispatched = callback
 }
 
+// SetWorkspaceAuthorizer sets the authorizer checking that calls made on
+// behalf of a workspace target one of its devices. Without one, such calls
+// are rejected.
+func (h *DispatchHandler) SetWorkspaceAuthorizer(authorizer WorkspaceAuthorizer) {
+	h.authorizer = authorizer
+}
+
 // Handle dispatches a service call
 func (h *DispatchHandler) Handle(ctx context.Context, call *ServiceCall) (*DispatchResult, error) {
 	if call == nil {
@@ -215,6 +236,12 @@ func (h *DispatchHandler) Handle(ctx context.Context, call *ServiceCall) (*Dispa
 	)
 	defer span.End()
 
+	if err := h.authorize(ctx, call); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
 	// Find appropriate dispatcher
 	dispatcher, found := h.registry.GetForCall(call)
 	if !found {
@@ -260,6 +287,31 @@ func (h *DispatchHandler) Handle(ctx context.Context, call *ServiceCall) (*Dispa
 	return result, nil
 }
 
+// authorize rejects calls made on behalf of a workspace whose device is not
+// in that workspace
+func (h *DispatchHandler) authorize(ctx context.Context, call *ServiceCall) error {
+	if call.WorkspaceID == "" {
+		return nil
+	}
+	if h.authorizer == nil {
+		return ErrWorkspaceForbidden
+	}
+
+	ok, err := h.authorizer.DeviceInWorkspace(ctx, call.WorkspaceID, call.DeviceSN)
+	if err != nil {
+		return fmt.Errorf("failed to authorize service call: %w", err)
+	}
+	if !ok {
+		h.logger.WithFields(logrus.Fields{
+			"device_sn":    call.DeviceSN,
+			"workspace_id": call.WorkspaceID,
+			"method":       call.Method,
+		}).Warn("Rejected service call outside the caller's workspace")
+		return ErrWorkspaceForbidden
+	}
+	return nil
+}
+
 // RegisterDispatcher registers a dispatcher
 func (h *DispatchHandler) RegisterDispatcher(dispatcher Dispatcher) {
 	h.registry.Register(dispatcher)
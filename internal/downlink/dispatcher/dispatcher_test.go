@@ -8,6 +8,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/utmos/utmos/pkg/adapter"
 )
 
 // mockDispatcher is a test dispatcher implementation
@@ -188,6 +190,36 @@ func TestDispatchHandler_Handle(t *testing.T) {
 	})
 }
 
+type staticMembership map[string]string
+
+func (m staticMembership) DeviceInWorkspace(_ context.Context, workspaceID, deviceSN string) (bool, error) {
+	return m[deviceSN] == workspaceID, nil
+}
+
+func TestDispatchHandler_WorkspaceAuthorization(t *testing.T) {
+	registry := NewRegistry(nil)
+	registry.Register(newMockDispatcher("dji"))
+	handler := NewDispatchHandler(registry, nil)
+
+	call := func(deviceSN, workspaceID string) *ServiceCall {
+		c := NewServiceCall(deviceSN, "dji", "takeoff", nil)
+		c.WorkspaceID = workspaceID
+		return c
+	}
+
+	_, err := handler.Handle(context.Background(), call("DOCK1", "ws1"))
+	assert.ErrorIs(t, err, ErrWorkspaceForbidden, "workspace calls are rejected without an authorizer")
+
+	handler.SetWorkspaceAuthorizer(staticMembership{"DOCK1": "ws1", "DOCK2": "ws2"})
+
+	_, err = handler.Handle(context.Background(), call("DOCK1", "ws1"))
+	assert.NoError(t, err)
+	_, err = handler.Handle(context.Background(), call("DOCK2", "ws1"))
+	assert.ErrorIs(t, err, ErrWorkspaceForbidden)
+	_, err = handler.Handle(context.Background(), call("DOCK2", ""))
+	assert.NoError(t, err, "calls without a workspace are not restricted")
+}
+
 func TestDispatchHandler_RegisterDispatcher(t *testing.T) {
 	registry := NewRegistry(nil)
 	handler := NewDispatchHandler(registry, nil)
@@ -296,3 +328,34 @@ func TestDispatchResult(t *testing.T) {
 	assert.Equal(t, "msg-001", result.MessageID)
 	assert.Nil(t, result.Error)
 }
+
+// recordingAdapter is an adapter.DownlinkDispatcher keeping the last call.
+type recordingAdapter struct {
+	call *adapter.ServiceCall
+}
+
+func (a *recordingAdapter) GetVendor() string { return "dji" }
+
+func (a *recordingAdapter) CanDispatch(vendor string) bool { return vendor == "dji" }
+
+func (a *recordingAdapter) Dispatch(_ context.Context, call *adapter.ServiceCall) (*adapter.DispatchResult, error) {
+	a.call = call
+	return &adapter.DispatchResult{CallID: call.ID, Success: true}, nil
+}
+
+func TestAdapterDispatcher_PassesMessageIDs(t *testing.T) {
+	recorder := &recordingAdapter{}
+	d := NewAdapterDispatcher(recorder)
+
+	call := NewServiceCall("DOCK1", "dji", "unlock_license_switch", json.RawMessage(`{"license_id":42}`))
+	call.ID = "call-001"
+	call.TID = "tid-001"
+	call.BID = "bid-001"
+	_, err := d.Dispatch(context.Background(), call)
+	require.NoError(t, err)
+
+	require.NotNil(t, recorder.call)
+	assert.Equal(t, "tid-001", recorder.call.TID)
+	assert.Equal(t, "bid-001", recorder.call.BID)
+	assert.Equal(t, map[string]any{"license_id": float64(42)}, recorder.call.Params)
+}
@@ -0,0 +1,202 @@
+package flightarea
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/utmos/utmos/pkg/adapter/dji/wpml"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// GeoJSON geometry types of flight areas.
+const (
+	GeometryPolygon = "Polygon"
+	GeometryPoint   = "Point"
+)
+
+// subTypeCircle is the DJI subType of circular flight areas.
+const subTypeCircle = "Circle"
+
+// Geometry is a GeoJSON geometry object: a Polygon, or a Point centering a
+// circular area.
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Area holds the editable fields of a flight area. Radius, in meters, is
+// required for Point geometries and ignored for polygons.
+type Area struct {
+	Name     string
+	Type     wpml.GeofenceType
+	Geometry Geometry
+	Radius   float64
+	Enable   bool
+}
+
+// validate checks the area and clears the radius of polygons.
+func (a *Area) validate() error {
+	if a.Name == "" || len(a.Name) > 100 {
+		return fmt.Errorf("%w: name must be 1 to 100 characters", ErrInvalidArea)
+	}
+	if a.Type != wpml.GeofenceTypeNoFly && a.Type != wpml.GeofenceTypeFence {
+		return fmt.Errorf("%w: type must be %s or %s", ErrInvalidArea, wpml.GeofenceTypeNoFly, wpml.GeofenceTypeFence)
+	}
+
+	switch a.Geometry.Type {
+	case GeometryPoint:
+		var position []float64
+		if err := json.Unmarshal(a.Geometry.Coordinates, &position); err != nil {
+			return fmt.Errorf("%w: Point coordinates must be a position", ErrInvalidArea)
+		}
+		if err := validatePosition(position); err != nil {
+			return err
+		}
+		if a.Radius <= 0 {
+			return fmt.Errorf("%w: circles need a positive radius", ErrInvalidArea)
+		}
+	case GeometryPolygon:
+		var rings [][][]float64
+		if err := json.Unmarshal(a.Geometry.Coordinates, &rings); err != nil {
+			return fmt.Errorf("%w: Polygon coordinates must be an array of linear rings", ErrInvalidArea)
+		}
+		if len(rings) != 1 {
+			return fmt.Errorf("%w: Polygon needs exactly one ring", ErrInvalidArea)
+		}
+		if err := validateRing(rings[0]); err != nil {
+			return err
+		}
+		a.Radius = 0
+	default:
+		return fmt.Errorf("%w: unsupported geometry type %q", ErrInvalidArea, a.Geometry.Type)
+	}
+	return nil
+}
+
+// validateRing checks a closed linear ring of at least three distinct positions.
+func validateRing(ring [][]float64) error {
+	if len(ring) < 4 {
+		return fmt.Errorf("%w: ring needs at least 4 positions", ErrInvalidArea)
+	}
+	for _, position := range ring {
+		if err := validatePosition(position); err != nil {
+			return err
+		}
+	}
+	first, last := ring[0], ring[len(ring)-1]
+	if first[0] != last[0] || first[1] != last[1] {
+		return fmt.Errorf("%w: ring is not closed", ErrInvalidArea)
+	}
+	return nil
+}
+
+// validatePosition checks a [longitude, latitude] position.
+func validatePosition(position []float64) error {
+	if len(position) != 2 {
+		return fmt.Errorf("%w: positions must be [longitude, latitude]", ErrInvalidArea)
+	}
+	if position[0] < -180 || position[0] > 180 || position[1] < -90 || position[1] > 90 {
+		return fmt.Errorf("%w: position %v is out of range", ErrInvalidArea, position)
+	}
+	return nil
+}
+
+// fileDocument is the DJI custom flight area file, a GeoJSON feature
+// collection with the area type in each feature's geofence_type.
+type fileDocument struct {
+	Type     string        `json:"type"`
+	Features []fileFeature `json:"features"`
+}
+
+type fileFeature struct {
+	ID           string         `json:"id"`
+	Type         string         `json:"type"`
+	GeofenceType string         `json:"geofence_type"`
+	Geometry     Geometry       `json:"geometry"`
+	Properties   fileProperties `json:"properties"`
+}
+
+type fileProperties struct {
+	SubType string  `json:"subType,omitempty"`
+	Radius  float64 `json:"radius,omitempty"`
+	Enable  bool    `json:"enable"`
+}
+
+// BuildFile builds the DJI custom flight area file of areas, ordered by
+// creation. Disabled areas are kept in the file with enable set to false.
+func BuildFile(areas []models.FlightArea) ([]byte, error) {
+	sorted := make([]models.FlightArea, len(areas))
+	copy(sorted, areas)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].CreatedAt.Equal(sorted[j].CreatedAt) {
+			return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	document := fileDocument{Type: "FeatureCollection", Features: make([]fileFeature, len(sorted))}
+	for i := range sorted {
+		area := &sorted[i]
+		feature := fileFeature{
+			ID:           area.ID,
+			Type:         "Feature",
+			GeofenceType: area.Type,
+			Properties:   fileProperties{Enable: area.Enable},
+		}
+		if err := json.Unmarshal(area.Geometry, &feature.Geometry); err != nil {
+			return nil, fmt.Errorf("failed to decode geometry of flight area %s: %w", area.ID, err)
+		}
+		if feature.Geometry.Type == GeometryPoint {
+			feature.Properties.SubType = subTypeCircle
+			feature.Properties.Radius = area.Radius
+		}
+		document.Features[i] = feature
+	}
+	return json.Marshal(document)
+}
+
+// Geofences converts the enabled areas to the geofences waylines are
+// validated against. Disabled areas are left out, as aircraft do not
+// enforce them.
+func Geofences(areas []models.FlightArea) ([]wpml.Geofence, error) {
+	geofences := make([]wpml.Geofence, 0, len(areas))
+	for i := range areas {
+		area := &areas[i]
+		if !area.Enable {
+			continue
+		}
+		var geometry Geometry
+		if err := json.Unmarshal(area.Geometry, &geometry); err != nil {
+			return nil, fmt.Errorf("failed to decode geometry of flight area %s: %w", area.ID, err)
+		}
+
+		g := wpml.Geofence{ID: area.ID, Name: area.Name, Type: wpml.GeofenceType(area.Type)}
+		switch geometry.Type {
+		case GeometryPoint:
+			var position []float64
+			if err := json.Unmarshal(geometry.Coordinates, &position); err != nil || len(position) != 2 {
+				return nil, fmt.Errorf("%w: flight area %s has no valid center", ErrInvalidArea, area.ID)
+			}
+			g.Center = &wpml.Coordinate{Longitude: position[0], Latitude: position[1]}
+			g.Radius = area.Radius
+		case GeometryPolygon:
+			var rings [][][]float64
+			if err := json.Unmarshal(geometry.Coordinates, &rings); err != nil || len(rings) == 0 {
+				return nil, fmt.Errorf("%w: flight area %s has no valid ring", ErrInvalidArea, area.ID)
+			}
+			// The closing position repeats the first
+			ring := rings[0]
+			for _, position := range ring[:max(len(ring)-1, 0)] {
+				if len(position) != 2 {
+					return nil, fmt.Errorf("%w: flight area %s has an invalid position", ErrInvalidArea, area.ID)
+				}
+				g.Polygon = append(g.Polygon, wpml.Coordinate{Longitude: position[0], Latitude: position[1]})
+			}
+		default:
+			return nil, fmt.Errorf("%w: flight area %s has unsupported geometry type %q", ErrInvalidArea, area.ID, geometry.Type)
+		}
+		geofences = append(geofences, g)
+	}
+	return geofences, nil
+}
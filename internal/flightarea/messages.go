@@ -0,0 +1,142 @@
+package flightarea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/organization"
+	dji "github.com/utmos/utmos/pkg/adapter/dji"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/config"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/safety"
+	djirouter "github.com/utmos/utmos/pkg/adapter/dji/router"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+// Result codes of request replies.
+const (
+	resultOK    = 0
+	resultError = 1
+)
+
+// Publisher publishes replies to the devices.
+type Publisher interface {
+	Publish(ctx context.Context, routingKey string, msg *rabbitmq.StandardMessage) error
+}
+
+// reply is the data of a requests_reply message.
+type reply struct {
+	Result int `json:"result"`
+	Output any `json:"output"`
+}
+
+// uplinkEventData is the payload of event messages routed by iot-uplink.
+type uplinkEventData struct {
+	Events []struct {
+		Name   string          `json:"name"`
+		Params json.RawMessage `json:"params"`
+	} `json:"events"`
+}
+
+// SetReplyPublisher sets the publisher used to answer flight_areas_get
+// requests. Requests are ignored while it is nil.
+func (s *Service) SetReplyPublisher(publisher Publisher) {
+	s.publisher = publisher
+}
+
+// HandleDeviceRequest answers flight_areas_get requests with the flight
+// area file of the dock's workspace. Other requests are ignored. It matches
+// the rabbitmq.MessageHandler signature so it can consume device requests
+// directly.
+func (s *Service) HandleDeviceRequest(ctx context.Context, msg *rabbitmq.StandardMessage) error {
+	if msg == nil || msg.ProtocolMeta == nil || msg.ProtocolMeta.Vendor != dji.VendorDJI ||
+		msg.ProtocolMeta.Method != djirouter.MethodFlightAreasGet || s.publisher == nil {
+		return nil
+	}
+
+	out, err := s.flightAreasGet(ctx, msg.DeviceSN)
+	if err != nil {
+		s.logger.WithError(err).WithField("device_sn", msg.DeviceSN).Warn("Failed to answer flight_areas_get")
+		out = reply{Result: resultError, Output: struct{}{}}
+	}
+
+	resp, err := rabbitmq.NewStandardMessageWithIDs(msg.TID, msg.BID, "iot-api", dji.ActionDeviceRequestReply, msg.DeviceSN, out)
+	if err != nil {
+		return fmt.Errorf("failed to create request reply: %w", err)
+	}
+	resp.ProtocolMeta = &rabbitmq.ProtocolMeta{Vendor: dji.VendorDJI, Method: djirouter.MethodFlightAreasGet}
+	if err := s.publisher.Publish(ctx, organization.RoutingKeyReply, resp); err != nil {
+		return fmt.Errorf("failed to publish request reply: %w", err)
+	}
+	return nil
+}
+
+// flightAreasGet lists the flight area file of a dock's workspace, which is
+// empty until the workspace's areas are first published.
+func (s *Service) flightAreasGet(ctx context.Context, deviceSN string) (reply, error) {
+	workspaceID, err := s.deviceWorkspace(ctx, deviceSN)
+	if err != nil {
+		return reply{}, err
+	}
+	file, err := s.File(ctx, workspaceID)
+	if err != nil {
+		return reply{}, err
+	}
+
+	output := config.FlightAreasGetOutputData{File: []config.FlightAreasFile{}}
+	if file != nil {
+		address, err := s.FileURL(ctx, file)
+		if err != nil {
+			return reply{}, err
+		}
+		output.File = append(output.File, config.FlightAreasFile{
+			Name:     file.Name,
+			URL:      address,
+			Checksum: file.Checksum,
+			Size:     int(file.Size),
+		})
+	}
+	return reply{Result: resultOK, Output: output}, nil
+}
+
+// HandleUplinkEvent records the synchronization progress docks report with
+// flight_areas_sync_progress events. Other events, and events of devices
+// outside any workspace, are ignored. It matches the rabbitmq.MessageHandler
+// signature so it can consume iot.api.event directly.
+func (s *Service) HandleUplinkEvent(ctx context.Context, msg *rabbitmq.StandardMessage) error {
+	if msg == nil || (msg.ProtocolMeta != nil && msg.ProtocolMeta.Vendor != "" && msg.ProtocolMeta.Vendor != dji.VendorDJI) {
+		return nil
+	}
+
+	var data uplinkEventData
+	if err := msg.GetData(&data); err != nil {
+		return fmt.Errorf("failed to decode event message: %w", err)
+	}
+
+	for _, event := range data.Events {
+		if event.Name != djirouter.MethodFlightAreasSyncProgress {
+			continue
+		}
+		var progress safety.FlightAreasSyncProgressData
+		if err := json.Unmarshal(event.Params, &progress); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", djirouter.MethodFlightAreasSyncProgress, err)
+		}
+		err := s.RecordSyncProgress(ctx, msg.DeviceSN, &progress)
+		if errors.Is(err, ErrDeviceNotBound) {
+			s.logger.WithField("device_sn", msg.DeviceSN).Debug("Ignoring flight area sync progress of unbound device")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		s.logger.WithFields(logrus.Fields{
+			"device_sn": msg.DeviceSN,
+			"status":    progress.Status,
+			"reason":    progress.Reason,
+		}).Debug("Flight area sync progress")
+	}
+	return nil
+}
@@ -0,0 +1,489 @@
+// Package flightarea manages the custom flight areas of a workspace: the
+// no-fly zones and geofences its docks enforce. Every change regenerates
+// the workspace's DJI flight area file in the object store and tells the
+// workspace's docks to fetch it; the docks' synchronization progress is
+// tracked per dock.
+package flightarea
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/utmos/utmos/internal/downlink/dispatcher"
+	"github.com/utmos/utmos/internal/topology"
+	dji "github.com/utmos/utmos/pkg/adapter/dji"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/safety"
+	djirouter "github.com/utmos/utmos/pkg/adapter/dji/router"
+	"github.com/utmos/utmos/pkg/adapter/dji/wpml"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+// fileURLTTL is how long docks may download a flight area file from the
+// address handed out in flight_areas_get replies.
+const fileURLTTL = time.Hour
+
+var (
+	// ErrInvalidArea is returned for malformed flight areas.
+	ErrInvalidArea = errors.New("invalid flight area")
+	// ErrAreaNotFound is returned when the flight area does not exist.
+	ErrAreaNotFound = errors.New("flight area not found")
+	// ErrDeviceNotBound is returned for devices outside any workspace.
+	ErrDeviceNotBound = errors.New("device is not bound to a workspace")
+)
+
+// Dispatcher sends service calls to devices.
+type Dispatcher interface {
+	Handle(ctx context.Context, call *dispatcher.ServiceCall) (*dispatcher.DispatchResult, error)
+}
+
+// AreaUpdate holds the flight area fields to change; nil fields are kept.
+type AreaUpdate struct {
+	Name     *string
+	Type     *wpml.GeofenceType
+	Geometry *Geometry
+	Radius   *float64
+	Enable   *bool
+}
+
+// DockSync is the flight area synchronization status of a dock. Status is
+// empty for docks that were never told to synchronize.
+type DockSync struct {
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	DeviceSN   string     `json:"device_sn"`
+	DeviceName string     `json:"device_name"`
+	Status     string     `json:"status"`
+	Reason     int        `json:"reason"`
+	// Current tells whether the dock synchronized the latest file
+	Current bool `json:"current"`
+}
+
+// Service manages flight areas.
+type Service struct {
+	db         *gorm.DB
+	store      objectstore.Store
+	dispatcher Dispatcher
+	publisher  Publisher
+	logger     *logrus.Entry
+}
+
+// NewService creates a new flight area service. dispatch may be nil, in
+// which case files are regenerated without telling the docks.
+func NewService(db *gorm.DB, store objectstore.Store, dispatch Dispatcher, logger *logrus.Entry) *Service {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Service{
+		db:         db,
+		store:      store,
+		dispatcher: dispatch,
+		logger:     logger.WithField("component", "flightarea"),
+	}
+}
+
+// List returns the flight areas of a workspace, oldest first.
+func (s *Service) List(ctx context.Context, workspaceID string) ([]models.FlightArea, error) {
+	var areas []models.FlightArea
+	err := s.db.WithContext(ctx).Where("workspace_id = ?", workspaceID).Order("created_at ASC, id ASC").Find(&areas).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flight areas: %w", err)
+	}
+	return areas, nil
+}
+
+// Get returns a flight area of a workspace.
+func (s *Service) Get(ctx context.Context, workspaceID, id string) (*models.FlightArea, error) {
+	var area models.FlightArea
+	err := s.db.WithContext(ctx).Where("workspace_id = ? AND id = ?", workspaceID, id).First(&area).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAreaNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flight area: %w", err)
+	}
+	return &area, nil
+}
+
+// Create adds a flight area to a workspace and publishes the workspace's areas.
+func (s *Service) Create(ctx context.Context, workspaceID string, area *Area) (*models.FlightArea, error) {
+	if err := area.validate(); err != nil {
+		return nil, err
+	}
+	m := &models.FlightArea{ID: uuid.New().String(), WorkspaceID: workspaceID}
+	if err := applyArea(m, area); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(m).Error; err != nil {
+		return nil, fmt.Errorf("failed to create flight area: %w", err)
+	}
+	s.changed(ctx, workspaceID)
+	return m, nil
+}
+
+// Update changes a flight area and publishes the workspace's areas.
+func (s *Service) Update(ctx context.Context, workspaceID, id string, update *AreaUpdate) (*models.FlightArea, error) {
+	m, err := s.Get(ctx, workspaceID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	area := Area{Name: m.Name, Type: wpml.GeofenceType(m.Type), Radius: m.Radius, Enable: m.Enable}
+	if err := json.Unmarshal(m.Geometry, &area.Geometry); err != nil {
+		return nil, fmt.Errorf("failed to decode flight area geometry: %w", err)
+	}
+	if update.Name != nil {
+		area.Name = *update.Name
+	}
+	if update.Type != nil {
+		area.Type = *update.Type
+	}
+	if update.Geometry != nil {
+		area.Geometry = *update.Geometry
+	}
+	if update.Radius != nil {
+		area.Radius = *update.Radius
+	}
+	if update.Enable != nil {
+		area.Enable = *update.Enable
+	}
+	if err := area.validate(); err != nil {
+		return nil, err
+	}
+	if err := applyArea(m, &area); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Save(m).Error; err != nil {
+		return nil, fmt.Errorf("failed to update flight area: %w", err)
+	}
+	s.changed(ctx, workspaceID)
+	return m, nil
+}
+
+// Delete removes a flight area and publishes the workspace's areas.
+func (s *Service) Delete(ctx context.Context, workspaceID, id string) error {
+	result := s.db.WithContext(ctx).Where("workspace_id = ? AND id = ?", workspaceID, id).Delete(&models.FlightArea{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete flight area: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAreaNotFound
+	}
+	s.changed(ctx, workspaceID)
+	return nil
+}
+
+// applyArea copies a validated area onto its stored form.
+func applyArea(m *models.FlightArea, area *Area) error {
+	geometry, err := json.Marshal(area.Geometry)
+	if err != nil {
+		return fmt.Errorf("failed to encode geometry: %w", err)
+	}
+	m.Name = area.Name
+	m.Type = string(area.Type)
+	m.Geometry = geometry
+	m.Radius = area.Radius
+	m.Enable = area.Enable
+	return nil
+}
+
+// Geofences returns the enabled flight areas of a workspace as geofences,
+// for validating the waylines stored in it.
+func (s *Service) Geofences(ctx context.Context, workspaceID string) ([]wpml.Geofence, error) {
+	var areas []models.FlightArea
+	err := s.db.WithContext(ctx).Where("workspace_id = ? AND enable = ?", workspaceID, true).Find(&areas).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flight areas: %w", err)
+	}
+	return Geofences(areas)
+}
+
+// changed publishes the workspace's areas after a change. The change is
+// kept when publishing fails; Publish can be retried.
+func (s *Service) changed(ctx context.Context, workspaceID string) {
+	if _, err := s.Publish(ctx, workspaceID); err != nil {
+		s.logger.WithError(err).WithField("workspace_id", workspaceID).Warn("Failed to publish flight areas")
+	}
+}
+
+// Publish regenerates the flight area file of a workspace, stores it and
+// sends flight_areas_update to every dock of the workspace. Docks that
+// cannot be reached are logged and left waiting to synchronize.
+func (s *Service) Publish(ctx context.Context, workspaceID string) (*models.FlightAreaFile, error) {
+	file, err := s.generate(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	docks, err := s.docks(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range docks {
+		s.notifyDock(ctx, &docks[i], file.Checksum)
+	}
+	return file, nil
+}
+
+// generate writes the flight area file of a workspace to the object store
+// and records it. The previous file is retired rather than removed, as
+// docks may still be downloading it from an address handed out earlier.
+func (s *Service) generate(ctx context.Context, workspaceID string) (*models.FlightAreaFile, error) {
+	areas, err := s.List(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := BuildFile(areas)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	file := &models.FlightAreaFile{
+		WorkspaceID: workspaceID,
+		Name:        "geofence_" + checksum[:16] + ".json",
+		ObjectKey:   fmt.Sprintf("flight-areas/%s/%s.json", workspaceID, checksum),
+		Checksum:    checksum,
+		Size:        int64(len(data)),
+	}
+	if err := s.store.Put(ctx, file.ObjectKey, bytes.NewReader(data), file.Size, "application/json"); err != nil {
+		return nil, fmt.Errorf("failed to store flight area file: %w", err)
+	}
+
+	previous, err := s.File(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(file).Error; err != nil {
+			return err
+		}
+		// The file may be the content of a retired one again
+		if err := tx.Delete(&models.RetiredFlightAreaFile{}, "object_key = ?", file.ObjectKey).Error; err != nil {
+			return err
+		}
+		if previous == nil || previous.ObjectKey == file.ObjectKey {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.RetiredFlightAreaFile{
+			ObjectKey:   previous.ObjectKey,
+			WorkspaceID: workspaceID,
+			RetiredAt:   time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record flight area file: %w", err)
+	}
+	s.purgeRetired(ctx, workspaceID)
+	return file, nil
+}
+
+// purgeRetired removes the retired flight area files of a workspace whose
+// download addresses have expired. Files that cannot be removed are kept
+// for the next attempt.
+func (s *Service) purgeRetired(ctx context.Context, workspaceID string) {
+	var retired []models.RetiredFlightAreaFile
+	if err := s.db.WithContext(ctx).
+		Where("workspace_id = ? AND retired_at <= ?", workspaceID, time.Now().Add(-fileURLTTL)).
+		Find(&retired).Error; err != nil {
+		s.logger.WithError(err).WithField("workspace_id", workspaceID).Warn("Failed to list retired flight area files")
+		return
+	}
+	for i := range retired {
+		logger := s.logger.WithField("object_key", retired[i].ObjectKey)
+		if err := s.store.Delete(ctx, retired[i].ObjectKey); err != nil {
+			logger.WithError(err).Warn("Failed to delete retired flight area file")
+			continue
+		}
+		if err := s.db.WithContext(ctx).Delete(&retired[i]).Error; err != nil {
+			logger.WithError(err).Warn("Failed to forget retired flight area file")
+		}
+	}
+}
+
+// File returns the flight area file last generated for a workspace, or nil
+// when its areas were never published.
+func (s *Service) File(ctx context.Context, workspaceID string) (*models.FlightAreaFile, error) {
+	var file models.FlightAreaFile
+	err := s.db.WithContext(ctx).Where("workspace_id = ?", workspaceID).First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flight area file: %w", err)
+	}
+	return &file, nil
+}
+
+// docks returns the docks of a workspace.
+func (s *Service) docks(ctx context.Context, workspaceID string) ([]models.Device, error) {
+	var docks []models.Device
+	err := s.db.WithContext(ctx).
+		Where("workspace_id = ? AND device_type = ?", workspaceID, topology.DeviceTypeDock).
+		Order("device_sn ASC").
+		Find(&docks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspace docks: %w", err)
+	}
+	return docks, nil
+}
+
+// notifyDock sends flight_areas_update to a dock and marks it waiting to
+// synchronize the file with the checksum.
+func (s *Service) notifyDock(ctx context.Context, dock *models.Device, checksum string) {
+	if s.dispatcher == nil {
+		return
+	}
+	logger := s.logger.WithFields(logrus.Fields{"device_sn": dock.DeviceSN, "workspace_id": dock.WorkspaceID})
+
+	vendor := dock.Vendor
+	if vendor == "" {
+		vendor = dji.VendorDJI
+	}
+	call := dispatcher.NewServiceCall(dock.DeviceSN, vendor, djirouter.MethodFlightAreasUpdate, nil)
+	call.WorkspaceID = dock.WorkspaceID
+	if _, err := s.dispatcher.Handle(ctx, call); err != nil {
+		logger.WithError(err).Warn("Failed to send flight_areas_update")
+	}
+
+	record := models.FlightAreaSync{
+		WorkspaceID: dock.WorkspaceID,
+		DeviceSN:    dock.DeviceSN,
+		Status:      safety.FlightAreasSyncWait,
+		Checksum:    checksum,
+	}
+	if err := s.saveSync(ctx, &record); err != nil {
+		logger.WithError(err).Warn("Failed to record flight area sync")
+	}
+}
+
+// RecordSyncProgress records the flight area synchronization progress a
+// dock reported.
+func (s *Service) RecordSyncProgress(ctx context.Context, deviceSN string, progress *safety.FlightAreasSyncProgressData) error {
+	var record models.FlightAreaSync
+	err := s.db.WithContext(ctx).Where("device_sn = ?", deviceSN).First(&record).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to get flight area sync: %w", err)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		record, err = s.newSync(ctx, deviceSN)
+		if err != nil {
+			return err
+		}
+	}
+	record.Status = progress.Status
+	record.Reason = progress.Reason
+	return s.saveSync(ctx, &record)
+}
+
+// newSync starts the sync record of a dock that was never told to
+// synchronize, against the current file of its workspace.
+func (s *Service) newSync(ctx context.Context, deviceSN string) (models.FlightAreaSync, error) {
+	workspaceID, err := s.deviceWorkspace(ctx, deviceSN)
+	if err != nil {
+		return models.FlightAreaSync{}, err
+	}
+	record := models.FlightAreaSync{WorkspaceID: workspaceID, DeviceSN: deviceSN}
+	file, err := s.File(ctx, workspaceID)
+	if err != nil {
+		return models.FlightAreaSync{}, err
+	}
+	if file != nil {
+		record.Checksum = file.Checksum
+	}
+	return record, nil
+}
+
+func (s *Service) saveSync(ctx context.Context, record *models.FlightAreaSync) error {
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "device_sn"}},
+		DoUpdates: clause.AssignmentColumns([]string{"workspace_id", "status", "checksum", "reason", "updated_at"}),
+	}).Create(record).Error
+	if err != nil {
+		return fmt.Errorf("failed to save flight area sync: %w", err)
+	}
+	return nil
+}
+
+// SyncStatus returns the flight area synchronization status of every dock
+// of a workspace.
+func (s *Service) SyncStatus(ctx context.Context, workspaceID string) ([]DockSync, error) {
+	docks, err := s.docks(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := s.File(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	sns := make([]string, len(docks))
+	for i := range docks {
+		sns[i] = docks[i].DeviceSN
+	}
+	var syncs []models.FlightAreaSync
+	if len(sns) > 0 {
+		if err := s.db.WithContext(ctx).Where("device_sn IN ?", sns).Find(&syncs).Error; err != nil {
+			return nil, fmt.Errorf("failed to list flight area syncs: %w", err)
+		}
+	}
+	bySN := make(map[string]*models.FlightAreaSync, len(syncs))
+	for i := range syncs {
+		bySN[syncs[i].DeviceSN] = &syncs[i]
+	}
+
+	result := make([]DockSync, len(docks))
+	for i := range docks {
+		result[i] = DockSync{DeviceSN: docks[i].DeviceSN, DeviceName: docks[i].DeviceName}
+		record, ok := bySN[docks[i].DeviceSN]
+		if !ok || record.WorkspaceID != workspaceID {
+			continue
+		}
+		updatedAt := record.UpdatedAt
+		result[i].UpdatedAt = &updatedAt
+		result[i].Status = record.Status
+		result[i].Reason = record.Reason
+		result[i].Current = file != nil && record.Status == safety.FlightAreasSyncSynchronized && record.Checksum == file.Checksum
+	}
+	return result, nil
+}
+
+// deviceWorkspace returns the workspace a device is bound to.
+func (s *Service) deviceWorkspace(ctx context.Context, deviceSN string) (string, error) {
+	var device models.Device
+	err := s.db.WithContext(ctx).Where("device_sn = ?", deviceSN).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrDeviceNotBound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get device: %w", err)
+	}
+	if device.WorkspaceID == "" {
+		return "", ErrDeviceNotBound
+	}
+	return device.WorkspaceID, nil
+}
+
+// FileURL returns a time-limited download address of a flight area file.
+func (s *Service) FileURL(ctx context.Context, file *models.FlightAreaFile) (string, error) {
+	presigner, ok := s.store.(objectstore.Presigner)
+	if !ok {
+		return "", errors.New("object store cannot issue download addresses")
+	}
+	address, err := presigner.PresignGet(ctx, file.ObjectKey, fileURLTTL)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign flight area file: %w", err)
+	}
+	return address, nil
+}
@@ -0,0 +1,338 @@
+package flightarea
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/downlink/dispatcher"
+	"github.com/utmos/utmos/internal/organization"
+	dji "github.com/utmos/utmos/pkg/adapter/dji"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/config"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/safety"
+	djirouter "github.com/utmos/utmos/pkg/adapter/dji/router"
+	"github.com/utmos/utmos/pkg/adapter/dji/wpml"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+type recordingDispatcher struct {
+	mu    sync.Mutex
+	calls []*dispatcher.ServiceCall
+}
+
+func (d *recordingDispatcher) Handle(_ context.Context, call *dispatcher.ServiceCall) (*dispatcher.DispatchResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, call)
+	return &dispatcher.DispatchResult{Success: true}, nil
+}
+
+func (d *recordingDispatcher) take() []*dispatcher.ServiceCall {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	calls := d.calls
+	d.calls = nil
+	return calls
+}
+
+type recordingPublisher struct {
+	mu   sync.Mutex
+	msgs []*rabbitmq.StandardMessage
+	keys []string
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, routingKey string, msg *rabbitmq.StandardMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = append(p.keys, routingKey)
+	p.msgs = append(p.msgs, msg)
+	return nil
+}
+
+func setupService(t *testing.T) (*Service, *recordingDispatcher, *objectstore.LocalStore) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Device{}, &models.FlightArea{}, &models.FlightAreaFile{}, &models.RetiredFlightAreaFile{}, &models.FlightAreaSync{}))
+	require.NoError(t, db.Create(&[]models.Device{
+		{DeviceSN: "DOCK1", DeviceName: "Dock 1", DeviceType: "dock", Vendor: "dji", WorkspaceID: "ws1"},
+		{DeviceSN: "DOCK2", DeviceName: "Dock 2", DeviceType: "dock", Vendor: "dji", WorkspaceID: "ws2"},
+		{DeviceSN: "DRONE1", DeviceName: "Drone 1", DeviceType: "aircraft", Vendor: "dji", WorkspaceID: "ws1"},
+		{DeviceSN: "DOCK3", DeviceName: "Dock 3", DeviceType: "dock", Vendor: "dji"},
+	}).Error)
+
+	store, err := objectstore.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	store.SetURLSigner(objectstore.NewURLSigner("https://iot.example.com/objects", []byte("secret")))
+	disp := &recordingDispatcher{}
+	return NewService(db, store, disp, nil), disp, store
+}
+
+func polygon() Geometry {
+	return Geometry{Type: GeometryPolygon, Coordinates: json.RawMessage(`[[[113.9,22.5],[113.91,22.5],[113.91,22.51],[113.9,22.5]]]`)}
+}
+
+func circle() Geometry {
+	return Geometry{Type: GeometryPoint, Coordinates: json.RawMessage(`[113.95, 22.55]`)}
+}
+
+func readObject(t *testing.T, store objectstore.Store, key string) []byte {
+	t.Helper()
+	r, _, err := store.Get(context.Background(), key)
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return data
+}
+
+func TestArea_Validate(t *testing.T) {
+	valid := Area{Name: "Airport", Type: wpml.GeofenceTypeNoFly, Geometry: polygon(), Radius: 50}
+	require.NoError(t, valid.validate())
+	assert.Zero(t, valid.Radius, "polygons have no radius")
+
+	tests := map[string]Area{
+		"no name":         {Type: wpml.GeofenceTypeNoFly, Geometry: polygon()},
+		"unknown type":    {Name: "a", Type: "zone", Geometry: polygon()},
+		"no radius":       {Name: "a", Type: wpml.GeofenceTypeFence, Geometry: circle()},
+		"line":            {Name: "a", Type: wpml.GeofenceTypeFence, Geometry: Geometry{Type: "LineString", Coordinates: json.RawMessage(`[[0,0],[1,1]]`)}},
+		"open ring":       {Name: "a", Type: wpml.GeofenceTypeFence, Geometry: Geometry{Type: GeometryPolygon, Coordinates: json.RawMessage(`[[[0,0],[1,0],[1,1],[0,1]]]`)}},
+		"out of range":    {Name: "a", Type: wpml.GeofenceTypeFence, Geometry: Geometry{Type: GeometryPoint, Coordinates: json.RawMessage(`[190,0]`)}, Radius: 10},
+		"holes":           {Name: "a", Type: wpml.GeofenceTypeFence, Geometry: Geometry{Type: GeometryPolygon, Coordinates: json.RawMessage(`[[[0,0],[1,0],[1,1],[0,0]],[[0,0],[1,0],[1,1],[0,0]]]`)}},
+		"bad coordinates": {Name: "a", Type: wpml.GeofenceTypeFence, Geometry: Geometry{Type: GeometryPoint, Coordinates: json.RawMessage(`"x"`)}, Radius: 10},
+	}
+	for name, area := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, area.validate(), ErrInvalidArea)
+		})
+	}
+}
+
+func TestGeofences(t *testing.T) {
+	areas := []models.FlightArea{
+		{ID: "fence", Name: "Site", Type: string(wpml.GeofenceTypeFence), Geometry: []byte(`{"type":"Polygon","coordinates":[[[113.9,22.5],[113.91,22.5],[113.91,22.51],[113.9,22.5]]]}`), Enable: true},
+		{ID: "nfz", Name: "Tower", Type: string(wpml.GeofenceTypeNoFly), Geometry: []byte(`{"type":"Point","coordinates":[113.95,22.55]}`), Radius: 30, Enable: true},
+		{ID: "off", Name: "Disabled", Type: string(wpml.GeofenceTypeNoFly), Geometry: []byte(`{"type":"Point","coordinates":[113.95,22.55]}`), Radius: 30},
+	}
+	geofences, err := Geofences(areas)
+	require.NoError(t, err)
+	assert.Equal(t, []wpml.Geofence{
+		{ID: "fence", Name: "Site", Type: wpml.GeofenceTypeFence, Polygon: []wpml.Coordinate{
+			{Longitude: 113.9, Latitude: 22.5}, {Longitude: 113.91, Latitude: 22.5}, {Longitude: 113.91, Latitude: 22.51},
+		}},
+		{ID: "nfz", Name: "Tower", Type: wpml.GeofenceTypeNoFly, Center: &wpml.Coordinate{Longitude: 113.95, Latitude: 22.55}, Radius: 30},
+	}, geofences)
+
+	_, err = Geofences([]models.FlightArea{{ID: "line", Geometry: []byte(`{"type":"LineString","coordinates":[[0,0],[1,1]]}`), Enable: true}})
+	assert.ErrorIs(t, err, ErrInvalidArea)
+}
+
+func TestService_CRUDPublishesToDocks(t *testing.T) {
+	svc, disp, store := setupService(t)
+	ctx := context.Background()
+
+	fence, err := svc.Create(ctx, "ws1", &Area{Name: "Site", Type: wpml.GeofenceTypeFence, Geometry: polygon(), Enable: true})
+	require.NoError(t, err)
+	nfz, err := svc.Create(ctx, "ws1", &Area{Name: "Tower", Type: wpml.GeofenceTypeNoFly, Geometry: circle(), Radius: 30})
+	require.NoError(t, err)
+	assert.False(t, nfz.Enable)
+
+	// Only the workspace's docks are told to update
+	calls := disp.take()
+	require.Len(t, calls, 2)
+	assert.Equal(t, "DOCK1", calls[0].DeviceSN)
+	assert.Equal(t, "ws1", calls[0].WorkspaceID)
+	assert.Equal(t, dji.VendorDJI, calls[0].Vendor)
+	assert.Equal(t, djirouter.MethodFlightAreasUpdate, calls[0].Method)
+
+	file, err := svc.File(ctx, "ws1")
+	require.NoError(t, err)
+	require.NotNil(t, file)
+	data := readObject(t, store, file.ObjectKey)
+	sum := sha256.Sum256(data)
+	assert.Equal(t, hex.EncodeToString(sum[:]), file.Checksum)
+	assert.Equal(t, int64(len(data)), file.Size)
+	assert.Equal(t, "geofence_"+file.Checksum[:16]+".json", file.Name)
+	assert.JSONEq(t, `{
+		"type": "FeatureCollection",
+		"features": [
+			{"id": "`+fence.ID+`", "type": "Feature", "geofence_type": "dfence",
+			 "geometry": {"type": "Polygon", "coordinates": [[[113.9,22.5],[113.91,22.5],[113.91,22.51],[113.9,22.5]]]},
+			 "properties": {"enable": true}},
+			{"id": "`+nfz.ID+`", "type": "Feature", "geofence_type": "nfz",
+			 "geometry": {"type": "Point", "coordinates": [113.95,22.55]},
+			 "properties": {"subType": "Circle", "radius": 30, "enable": false}}
+		]
+	}`, string(data))
+
+	syncs, err := svc.SyncStatus(ctx, "ws1")
+	require.NoError(t, err)
+	require.Len(t, syncs, 1)
+	assert.Equal(t, safety.FlightAreasSyncWait, syncs[0].Status)
+	assert.False(t, syncs[0].Current)
+
+	// Updates replace the file, keeping the previous one while addresses
+	// handed out for it are valid
+	enable := true
+	updated, err := svc.Update(ctx, "ws1", nfz.ID, &AreaUpdate{Enable: &enable})
+	require.NoError(t, err)
+	assert.True(t, updated.Enable)
+	assert.Equal(t, 30.0, updated.Radius)
+	newFile, err := svc.File(ctx, "ws1")
+	require.NoError(t, err)
+	assert.NotEqual(t, file.Checksum, newFile.Checksum)
+	_, err = store.Stat(ctx, file.ObjectKey)
+	require.NoError(t, err)
+	assert.Len(t, disp.take(), 1)
+
+	require.NoError(t, svc.db.Model(&models.RetiredFlightAreaFile{}).Where("object_key = ?", file.ObjectKey).
+		Update("retired_at", time.Now().Add(-fileURLTTL-time.Minute)).Error)
+	_, err = svc.Publish(ctx, "ws1")
+	require.NoError(t, err)
+	_, err = store.Stat(ctx, file.ObjectKey)
+	assert.ErrorIs(t, err, objectstore.ErrNotFound)
+	_, err = store.Stat(ctx, newFile.ObjectKey)
+	require.NoError(t, err)
+	disp.take()
+
+	geofences, err := svc.Geofences(ctx, "ws1")
+	require.NoError(t, err)
+	require.Len(t, geofences, 2)
+
+	radius := -1.0
+	_, err = svc.Update(ctx, "ws1", nfz.ID, &AreaUpdate{Radius: &radius})
+	assert.ErrorIs(t, err, ErrInvalidArea)
+	_, err = svc.Update(ctx, "ws2", nfz.ID, &AreaUpdate{Enable: &enable})
+	assert.ErrorIs(t, err, ErrAreaNotFound)
+
+	require.NoError(t, svc.Delete(ctx, "ws1", nfz.ID))
+	assert.ErrorIs(t, svc.Delete(ctx, "ws1", nfz.ID), ErrAreaNotFound)
+	areas, err := svc.List(ctx, "ws1")
+	require.NoError(t, err)
+	require.Len(t, areas, 1)
+	assert.Equal(t, fence.ID, areas[0].ID)
+}
+
+func flightAreasGet(t *testing.T, svc *Service, publisher *recordingPublisher, deviceSN string) (int, config.FlightAreasGetOutputData) {
+	t.Helper()
+	msg, err := rabbitmq.NewStandardMessageWithIDs("tid-1", "bid-1", "dji-adapter", dji.ActionDeviceRequest, deviceSN, map[string]any{})
+	require.NoError(t, err)
+	msg.ProtocolMeta = &rabbitmq.ProtocolMeta{Vendor: dji.VendorDJI, Method: djirouter.MethodFlightAreasGet}
+	require.NoError(t, svc.HandleDeviceRequest(context.Background(), msg))
+
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+	require.NotEmpty(t, publisher.msgs)
+	resp := publisher.msgs[len(publisher.msgs)-1]
+	assert.Equal(t, organization.RoutingKeyReply, publisher.keys[len(publisher.keys)-1])
+	assert.Equal(t, "tid-1", resp.TID)
+	assert.Equal(t, djirouter.MethodFlightAreasGet, resp.ProtocolMeta.Method)
+
+	var data struct {
+		Result int                             `json:"result"`
+		Output config.FlightAreasGetOutputData `json:"output"`
+	}
+	require.NoError(t, resp.GetData(&data))
+	return data.Result, data.Output
+}
+
+func TestService_HandleDeviceRequest(t *testing.T) {
+	svc, _, _ := setupService(t)
+	publisher := &recordingPublisher{}
+	svc.SetReplyPublisher(publisher)
+	ctx := context.Background()
+
+	// Workspaces without published areas have no file
+	result, output := flightAreasGet(t, svc, publisher, "DOCK1")
+	assert.Equal(t, resultOK, result)
+	assert.Empty(t, output.File)
+
+	_, err := svc.Create(ctx, "ws1", &Area{Name: "Site", Type: wpml.GeofenceTypeFence, Geometry: polygon(), Enable: true})
+	require.NoError(t, err)
+	file, err := svc.File(ctx, "ws1")
+	require.NoError(t, err)
+
+	result, output = flightAreasGet(t, svc, publisher, "DOCK1")
+	assert.Equal(t, resultOK, result)
+	require.Len(t, output.File, 1)
+	assert.Equal(t, file.Name, output.File[0].Name)
+	assert.Equal(t, file.Checksum, output.File[0].Checksum)
+	assert.Equal(t, int(file.Size), output.File[0].Size)
+	assert.Contains(t, output.File[0].URL, "https://iot.example.com/objects/"+file.ObjectKey)
+
+	result, _ = flightAreasGet(t, svc, publisher, "DOCK3")
+	assert.Equal(t, resultError, result)
+
+	// Other requests are left to other services
+	msg, err := rabbitmq.NewStandardMessageWithIDs("tid-2", "bid-2", "dji-adapter", dji.ActionDeviceRequest, "DOCK1", map[string]any{})
+	require.NoError(t, err)
+	msg.ProtocolMeta = &rabbitmq.ProtocolMeta{Vendor: dji.VendorDJI, Method: djirouter.MethodAirportBindStatus}
+	require.NoError(t, svc.HandleDeviceRequest(ctx, msg))
+	assert.Len(t, publisher.msgs, 3)
+}
+
+func syncEvent(t *testing.T, deviceSN, status string, reason int) *rabbitmq.StandardMessage {
+	t.Helper()
+	data := map[string]any{
+		"events": []map[string]any{
+			{"name": "hms", "params": map[string]any{}},
+			{"name": djirouter.MethodFlightAreasSyncProgress, "params": map[string]any{"status": status, "reason": reason}},
+		},
+	}
+	msg, err := rabbitmq.NewStandardMessage("iot-uplink", "event.processed", deviceSN, data)
+	require.NoError(t, err)
+	msg.ProtocolMeta = &rabbitmq.ProtocolMeta{Vendor: dji.VendorDJI}
+	return msg
+}
+
+func TestService_HandleUplinkEvent(t *testing.T) {
+	svc, _, _ := setupService(t)
+	ctx := context.Background()
+
+	// Docks may report progress before any push
+	require.NoError(t, svc.HandleUplinkEvent(ctx, syncEvent(t, "DOCK2", safety.FlightAreasSyncFail, 3)))
+	syncs, err := svc.SyncStatus(ctx, "ws2")
+	require.NoError(t, err)
+	require.Len(t, syncs, 1)
+	assert.Equal(t, safety.FlightAreasSyncFail, syncs[0].Status)
+	assert.Equal(t, 3, syncs[0].Reason)
+	assert.NotNil(t, syncs[0].UpdatedAt)
+
+	_, err = svc.Create(ctx, "ws1", &Area{Name: "Site", Type: wpml.GeofenceTypeFence, Geometry: polygon(), Enable: true})
+	require.NoError(t, err)
+	require.NoError(t, svc.HandleUplinkEvent(ctx, syncEvent(t, "DOCK1", safety.FlightAreasSyncSynchronized, 0)))
+	syncs, err = svc.SyncStatus(ctx, "ws1")
+	require.NoError(t, err)
+	require.Len(t, syncs, 1)
+	assert.Equal(t, "Dock 1", syncs[0].DeviceName)
+	assert.Equal(t, safety.FlightAreasSyncSynchronized, syncs[0].Status)
+	assert.True(t, syncs[0].Current)
+
+	// A later change leaves the dock behind until it synchronizes again
+	_, err = svc.Create(ctx, "ws1", &Area{Name: "Tower", Type: wpml.GeofenceTypeNoFly, Geometry: circle(), Radius: 30, Enable: true})
+	require.NoError(t, err)
+	syncs, err = svc.SyncStatus(ctx, "ws1")
+	require.NoError(t, err)
+	assert.Equal(t, safety.FlightAreasSyncWait, syncs[0].Status)
+	assert.False(t, syncs[0].Current)
+
+	// Unbound devices and other vendors are ignored
+	require.NoError(t, svc.HandleUplinkEvent(ctx, syncEvent(t, "DOCK3", safety.FlightAreasSyncSynchronized, 0)))
+	other := syncEvent(t, "DOCK1", safety.FlightAreasSyncFail, 1)
+	other.ProtocolMeta.Vendor = "tuya"
+	require.NoError(t, svc.HandleUplinkEvent(ctx, other))
+	syncs, err = svc.SyncStatus(ctx, "ws1")
+	require.NoError(t, err)
+	assert.Equal(t, safety.FlightAreasSyncWait, syncs[0].Status)
+}
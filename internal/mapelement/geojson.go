@@ -0,0 +1,122 @@
+package mapelement
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// Resource types of map elements, as used by DJI Pilot 2.
+const (
+	ResourceTypePoint   = 0
+	ResourceTypeLine    = 1
+	ResourceTypePolygon = 2
+)
+
+// GeoJSON geometry types accepted for map elements.
+const (
+	GeometryPoint      = "Point"
+	GeometryLineString = "LineString"
+	GeometryPolygon    = "Polygon"
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// Properties are the display properties of a map element.
+type Properties struct {
+	Color         string `json:"color"`
+	ClampToGround bool   `json:"clampToGround"`
+}
+
+// Geometry is a GeoJSON geometry object.
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Content is the GeoJSON Feature describing a map element.
+type Content struct {
+	Type       string     `json:"type"`
+	Properties Properties `json:"properties"`
+	Geometry   Geometry   `json:"geometry"`
+}
+
+// Validate checks the feature and returns the resource type matching its geometry.
+func (c *Content) Validate() (int, error) {
+	if c.Type != "Feature" {
+		return 0, fmt.Errorf("%w: content type must be Feature", ErrInvalidElement)
+	}
+	if c.Properties.Color != "" && !colorPattern.MatchString(c.Properties.Color) {
+		return 0, fmt.Errorf("%w: color must be #RRGGBB", ErrInvalidElement)
+	}
+
+	switch c.Geometry.Type {
+	case GeometryPoint:
+		var position []float64
+		if err := json.Unmarshal(c.Geometry.Coordinates, &position); err != nil {
+			return 0, fmt.Errorf("%w: Point coordinates must be a position", ErrInvalidElement)
+		}
+		if err := validatePosition(position); err != nil {
+			return 0, err
+		}
+		return ResourceTypePoint, nil
+
+	case GeometryLineString:
+		var line [][]float64
+		if err := json.Unmarshal(c.Geometry.Coordinates, &line); err != nil {
+			return 0, fmt.Errorf("%w: LineString coordinates must be an array of positions", ErrInvalidElement)
+		}
+		if len(line) < 2 {
+			return 0, fmt.Errorf("%w: LineString needs at least 2 positions", ErrInvalidElement)
+		}
+		if err := validatePositions(line); err != nil {
+			return 0, err
+		}
+		return ResourceTypeLine, nil
+
+	case GeometryPolygon:
+		var rings [][][]float64
+		if err := json.Unmarshal(c.Geometry.Coordinates, &rings); err != nil {
+			return 0, fmt.Errorf("%w: Polygon coordinates must be an array of linear rings", ErrInvalidElement)
+		}
+		if len(rings) == 0 {
+			return 0, fmt.Errorf("%w: Polygon needs an outer ring", ErrInvalidElement)
+		}
+		for i, ring := range rings {
+			if len(ring) < 4 {
+				return 0, fmt.Errorf("%w: ring %d needs at least 4 positions", ErrInvalidElement, i)
+			}
+			if err := validatePositions(ring); err != nil {
+				return 0, err
+			}
+			first, last := ring[0], ring[len(ring)-1]
+			if first[0] != last[0] || first[1] != last[1] {
+				return 0, fmt.Errorf("%w: ring %d is not closed", ErrInvalidElement, i)
+			}
+		}
+		return ResourceTypePolygon, nil
+
+	default:
+		return 0, fmt.Errorf("%w: unsupported geometry type %q", ErrInvalidElement, c.Geometry.Type)
+	}
+}
+
+func validatePositions(positions [][]float64) error {
+	for _, position := range positions {
+		if err := validatePosition(position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePosition checks a [longitude, latitude(, altitude)] position.
+func validatePosition(position []float64) error {
+	if len(position) < 2 || len(position) > 3 {
+		return fmt.Errorf("%w: positions must be [longitude, latitude(, altitude)]", ErrInvalidElement)
+	}
+	if position[0] < -180 || position[0] > 180 || position[1] < -90 || position[1] > 90 {
+		return fmt.Errorf("%w: position %v is out of range", ErrInvalidElement, position)
+	}
+	return nil
+}
@@ -0,0 +1,364 @@
+// Package mapelement stores the map elements (annotations) shared within a
+// workspace and notifies the workspace's clients of every change.
+package mapelement
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// WebSocket events pushed to the workspace on changes, named after the DJI
+// Pilot 2 biz codes.
+const (
+	EventElementCreate = "map_element_create"
+	EventElementUpdate = "map_element_update"
+	EventElementDelete = "map_element_delete"
+	EventGroupRefresh  = "map_group_refresh"
+)
+
+// Names of the layers every workspace starts with.
+const (
+	SharedGroupName  = "Pilot Share Layer"
+	DefaultGroupName = "Default Layer"
+)
+
+var (
+	// ErrInvalidElement is returned for malformed element content.
+	ErrInvalidElement = errors.New("invalid map element")
+	// ErrGroupNotFound is returned when the element group does not exist.
+	ErrGroupNotFound = errors.New("map element group not found")
+	// ErrGroupLocked is returned when modifying elements of a locked group.
+	ErrGroupLocked = errors.New("map element group is locked")
+	// ErrElementNotFound is returned when the element does not exist.
+	ErrElementNotFound = errors.New("map element not found")
+	// ErrDuplicateElement is returned when an element ID is already in use.
+	ErrDuplicateElement = errors.New("map element already exists")
+)
+
+// Notifier pushes events to the clients of a workspace.
+type Notifier interface {
+	NotifyWorkspace(ctx context.Context, workspaceID, event string, data any) error
+}
+
+// Resource is the typed content of a map element.
+type Resource struct {
+	Type     int     `json:"type"`
+	UserName string  `json:"user_name"`
+	Content  Content `json:"content"`
+}
+
+// Element is a map element in the form DJI Pilot 2 exchanges.
+type Element struct {
+	ID         string   `json:"id"`
+	GroupID    string   `json:"group_id"`
+	Name       string   `json:"name"`
+	Resource   Resource `json:"resource"`
+	CreateTime int64    `json:"create_time"`
+	UpdateTime int64    `json:"update_time"`
+}
+
+// Group is an element group (layer) with its elements.
+type Group struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Type       int       `json:"type"`
+	IsLock     bool      `json:"is_lock"`
+	CreateTime int64     `json:"create_time"`
+	Elements   []Element `json:"elements"`
+}
+
+// deletedElement is the payload of map_element_delete events.
+type deletedElement struct {
+	ID      string `json:"id"`
+	GroupID string `json:"group_id"`
+}
+
+// ToElement converts a stored element to its Pilot 2 form.
+func ToElement(m *models.MapElement) Element {
+	e := Element{
+		ID:      m.ID,
+		GroupID: m.GroupID,
+		Name:    m.Name,
+		Resource: Resource{
+			Type:     m.ResourceType,
+			UserName: m.Username,
+			Content: Content{
+				Type:       "Feature",
+				Properties: Properties{Color: m.Color, ClampToGround: m.ClampToGround},
+			},
+		},
+		CreateTime: m.CreatedAt.UnixMilli(),
+		UpdateTime: m.UpdatedAt.UnixMilli(),
+	}
+	_ = json.Unmarshal(m.Geometry, &e.Resource.Content.Geometry)
+	return e
+}
+
+// Service manages map elements.
+type Service struct {
+	db       *gorm.DB
+	notifier Notifier
+	logger   *logrus.Entry
+}
+
+// NewService creates a new map element service. notifier may be nil, in
+// which case changes are stored without being pushed.
+func NewService(db *gorm.DB, notifier Notifier, logger *logrus.Entry) *Service {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Service{
+		db:       db,
+		notifier: notifier,
+		logger:   logger.WithField("component", "mapelement"),
+	}
+}
+
+// Groups returns the workspace's element groups with their elements,
+// creating the default groups on first use. groupID optionally selects a
+// single group.
+func (s *Service) Groups(ctx context.Context, workspaceID, groupID string) ([]Group, error) {
+	if err := s.ensureDefaultGroups(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+
+	query := s.db.WithContext(ctx).Where("workspace_id = ?", workspaceID)
+	if groupID != "" {
+		query = query.Where("id = ?", groupID)
+	}
+	var groups []models.MapElementGroup
+	if err := query.Order("type DESC, created_at ASC").Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to list map element groups: %w", err)
+	}
+
+	result := make([]Group, len(groups))
+	index := make(map[string]int, len(groups))
+	ids := make([]string, len(groups))
+	for i, g := range groups {
+		result[i] = Group{
+			ID:         g.ID,
+			Name:       g.Name,
+			Type:       g.Type,
+			IsLock:     g.IsLock,
+			CreateTime: g.CreatedAt.UnixMilli(),
+			Elements:   []Element{},
+		}
+		index[g.ID] = i
+		ids[i] = g.ID
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var elements []models.MapElement
+	err := s.db.WithContext(ctx).
+		Where("workspace_id = ? AND group_id IN ?", workspaceID, ids).
+		Order("created_at ASC").
+		Find(&elements).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list map elements: %w", err)
+	}
+	for i := range elements {
+		g := index[elements[i].GroupID]
+		result[g].Elements = append(result[g].Elements, ToElement(&elements[i]))
+	}
+	return result, nil
+}
+
+// Create adds an element to a group and pushes map_element_create. An empty
+// id is replaced by a generated one.
+func (s *Service) Create(ctx context.Context, workspaceID, username, groupID, id, name string, content Content) (*Element, error) {
+	resourceType, err := content.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.writableGroup(ctx, workspaceID, groupID); err != nil {
+		return nil, err
+	}
+	if id == "" {
+		id = uuid.New().String()
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.MapElement{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check map element: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrDuplicateElement
+	}
+
+	geometry, err := json.Marshal(content.Geometry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode geometry: %w", err)
+	}
+	m := &models.MapElement{
+		ID:            id,
+		WorkspaceID:   workspaceID,
+		GroupID:       groupID,
+		Name:          name,
+		Color:         content.Properties.Color,
+		ClampToGround: content.Properties.ClampToGround,
+		Username:      username,
+		ResourceType:  resourceType,
+		Geometry:      geometry,
+	}
+	if err := s.db.WithContext(ctx).Create(m).Error; err != nil {
+		return nil, fmt.Errorf("failed to create map element: %w", err)
+	}
+
+	element := ToElement(m)
+	s.notify(ctx, workspaceID, EventElementCreate, element)
+	return &element, nil
+}
+
+// Update changes an element's name and/or content and pushes map_element_update.
+func (s *Service) Update(ctx context.Context, workspaceID, username, id, name string, content *Content) (*Element, error) {
+	m, err := s.load(ctx, workspaceID, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.writableGroup(ctx, workspaceID, m.GroupID); err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		m.Name = name
+	}
+	if content != nil {
+		resourceType, err := content.Validate()
+		if err != nil {
+			return nil, err
+		}
+		geometry, err := json.Marshal(content.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode geometry: %w", err)
+		}
+		m.Geometry = geometry
+		m.ResourceType = resourceType
+		m.Color = content.Properties.Color
+		m.ClampToGround = content.Properties.ClampToGround
+	}
+	if username != "" {
+		m.Username = username
+	}
+	if err := s.db.WithContext(ctx).Save(m).Error; err != nil {
+		return nil, fmt.Errorf("failed to update map element: %w", err)
+	}
+
+	element := ToElement(m)
+	s.notify(ctx, workspaceID, EventElementUpdate, element)
+	return &element, nil
+}
+
+// Delete removes an element and pushes map_element_delete.
+func (s *Service) Delete(ctx context.Context, workspaceID, id string) error {
+	m, err := s.load(ctx, workspaceID, id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.writableGroup(ctx, workspaceID, m.GroupID); err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Delete(m).Error; err != nil {
+		return fmt.Errorf("failed to delete map element: %w", err)
+	}
+
+	s.notify(ctx, workspaceID, EventElementDelete, deletedElement{ID: m.ID, GroupID: m.GroupID})
+	return nil
+}
+
+// ClearGroup removes every element of a group and pushes map_group_refresh.
+func (s *Service) ClearGroup(ctx context.Context, workspaceID, groupID string) error {
+	if _, err := s.writableGroup(ctx, workspaceID, groupID); err != nil {
+		return err
+	}
+	err := s.db.WithContext(ctx).
+		Where("workspace_id = ? AND group_id = ?", workspaceID, groupID).
+		Delete(&models.MapElement{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to clear map element group: %w", err)
+	}
+
+	s.notify(ctx, workspaceID, EventGroupRefresh, map[string][]string{"ids": {groupID}})
+	return nil
+}
+
+func (s *Service) load(ctx context.Context, workspaceID, id string) (*models.MapElement, error) {
+	var m models.MapElement
+	err := s.db.WithContext(ctx).Where("workspace_id = ? AND id = ?", workspaceID, id).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrElementNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get map element: %w", err)
+	}
+	return &m, nil
+}
+
+func (s *Service) writableGroup(ctx context.Context, workspaceID, groupID string) (*models.MapElementGroup, error) {
+	if err := s.ensureDefaultGroups(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+	var g models.MapElementGroup
+	err := s.db.WithContext(ctx).Where("workspace_id = ? AND id = ?", workspaceID, groupID).First(&g).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get map element group: %w", err)
+	}
+	if g.IsLock {
+		return nil, ErrGroupLocked
+	}
+	return &g, nil
+}
+
+// ensureDefaultGroups creates the shared and default layers of a workspace
+// that has no groups yet. Concurrent callers may both find none; the default
+// groups are unique per workspace and type, so only one set is created.
+func (s *Service) ensureDefaultGroups(ctx context.Context, workspaceID string) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.MapElementGroup{}).Where("workspace_id = ?", workspaceID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count map element groups: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	return s.createDefaultGroups(ctx, workspaceID)
+}
+
+// createDefaultGroups creates the default groups of a workspace that does not
+// have them yet.
+func (s *Service) createDefaultGroups(ctx context.Context, workspaceID string) error {
+	groups := []models.MapElementGroup{
+		{ID: uuid.New().String(), WorkspaceID: workspaceID, Name: SharedGroupName, Type: models.MapElementGroupTypeShared, IsDefault: true},
+		{ID: uuid.New().String(), WorkspaceID: workspaceID, Name: DefaultGroupName, Type: models.MapElementGroupTypeCustom, IsDefault: true},
+	}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&groups).Error; err != nil {
+		return fmt.Errorf("failed to create default map element groups: %w", err)
+	}
+	return nil
+}
+
+// notify pushes an event to the workspace. Failures are logged only: the
+// change is stored and clients resynchronise on the next list.
+func (s *Service) notify(ctx context.Context, workspaceID, event string, data any) {
+	if s.notifier == nil {
+		return
+	}
+	if err := s.notifier.NotifyWorkspace(ctx, workspaceID, event, data); err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"workspace_id": workspaceID,
+			"event":        event,
+		}).Warn("Failed to push map element change")
+	}
+}
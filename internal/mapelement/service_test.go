@@ -0,0 +1,188 @@
+package mapelement
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/models"
+)
+
+type notification struct {
+	workspaceID string
+	event       string
+	data        any
+}
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []notification
+}
+
+func (n *recordingNotifier) NotifyWorkspace(_ context.Context, workspaceID, event string, data any) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, notification{workspaceID: workspaceID, event: event, data: data})
+	return nil
+}
+
+func setupService(t *testing.T) (*Service, *recordingNotifier) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.MapElementGroup{}, &models.MapElement{}))
+	notifier := &recordingNotifier{}
+	return NewService(db, notifier, nil), notifier
+}
+
+func feature(geometryType, coordinates string) Content {
+	return Content{
+		Type:       "Feature",
+		Properties: Properties{Color: "#2D8CF0"},
+		Geometry:   Geometry{Type: geometryType, Coordinates: json.RawMessage(coordinates)},
+	}
+}
+
+func TestContent_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  Content
+		wantType int
+		wantErr  bool
+	}{
+		{"point", feature(GeometryPoint, `[113.95, 22.54]`), ResourceTypePoint, false},
+		{"point with altitude", feature(GeometryPoint, `[113.95, 22.54, 50]`), ResourceTypePoint, false},
+		{"line", feature(GeometryLineString, `[[113.95, 22.54], [113.96, 22.55]]`), ResourceTypeLine, false},
+		{"polygon", feature(GeometryPolygon, `[[[113.95, 22.54], [113.96, 22.54], [113.96, 22.55], [113.95, 22.54]]]`), ResourceTypePolygon, false},
+		{"open polygon", feature(GeometryPolygon, `[[[113.95, 22.54], [113.96, 22.54], [113.96, 22.55], [113.95, 22.55]]]`), 0, true},
+		{"short line", feature(GeometryLineString, `[[113.95, 22.54]]`), 0, true},
+		{"latitude out of range", feature(GeometryPoint, `[113.95, 95]`), 0, true},
+		{"unsupported geometry", feature("MultiPoint", `[[113.95, 22.54]]`), 0, true},
+		{"not a feature", Content{Type: "Geometry", Geometry: Geometry{Type: GeometryPoint, Coordinates: json.RawMessage(`[0, 0]`)}}, 0, true},
+		{"bad color", Content{Type: "Feature", Properties: Properties{Color: "blue"}, Geometry: Geometry{Type: GeometryPoint, Coordinates: json.RawMessage(`[0, 0]`)}}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resourceType, err := tt.content.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidElement)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantType, resourceType)
+		})
+	}
+}
+
+func TestService_DefaultGroups(t *testing.T) {
+	svc, _ := setupService(t)
+	ctx := context.Background()
+
+	groups, err := svc.Groups(ctx, "ws1", "")
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, SharedGroupName, groups[0].Name)
+	assert.Equal(t, models.MapElementGroupTypeShared, groups[0].Type)
+
+	again, err := svc.Groups(ctx, "ws1", "")
+	require.NoError(t, err)
+	assert.Equal(t, groups[0].ID, again[0].ID, "default groups are created once")
+
+	other, err := svc.Groups(ctx, "ws2", "")
+	require.NoError(t, err)
+	assert.NotEqual(t, groups[0].ID, other[0].ID)
+}
+
+func TestService_DefaultGroupsAreUnique(t *testing.T) {
+	svc, _ := setupService(t)
+	ctx := context.Background()
+
+	// A caller that lost the race to create the default groups
+	require.NoError(t, svc.createDefaultGroups(ctx, "ws1"))
+	require.NoError(t, svc.createDefaultGroups(ctx, "ws1"))
+
+	groups, err := svc.Groups(ctx, "ws1", "")
+	require.NoError(t, err)
+	assert.Len(t, groups, 2)
+
+	err = svc.db.Create(&models.MapElementGroup{ID: "g1", WorkspaceID: "ws1", Name: "copy", Type: models.MapElementGroupTypeShared, IsDefault: true}).Error
+	assert.Error(t, err)
+	err = svc.db.Create(&models.MapElementGroup{ID: "g2", WorkspaceID: "ws1", Name: "custom", Type: models.MapElementGroupTypeCustom}).Error
+	assert.NoError(t, err, "only default groups are unique")
+}
+
+func TestService_ElementLifecycle(t *testing.T) {
+	svc, notifier := setupService(t)
+	ctx := context.Background()
+
+	groups, err := svc.Groups(ctx, "ws1", "")
+	require.NoError(t, err)
+	groupID := groups[0].ID
+
+	element, err := svc.Create(ctx, "ws1", "alice", groupID, "e1", "landing zone", feature(GeometryPoint, `[113.95, 22.54]`))
+	require.NoError(t, err)
+	assert.Equal(t, "e1", element.ID)
+	assert.Equal(t, "alice", element.Resource.UserName)
+	assert.Equal(t, ResourceTypePoint, element.Resource.Type)
+
+	_, err = svc.Create(ctx, "ws1", "alice", groupID, "e1", "dup", feature(GeometryPoint, `[113.95, 22.54]`))
+	assert.ErrorIs(t, err, ErrDuplicateElement)
+	_, err = svc.Create(ctx, "ws1", "alice", "missing", "e2", "x", feature(GeometryPoint, `[113.95, 22.54]`))
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+
+	line := feature(GeometryLineString, `[[113.95, 22.54], [113.96, 22.55]]`)
+	updated, err := svc.Update(ctx, "ws1", "bob", "e1", "", &line)
+	require.NoError(t, err)
+	assert.Equal(t, "landing zone", updated.Name)
+	assert.Equal(t, ResourceTypeLine, updated.Resource.Type)
+
+	_, err = svc.Update(ctx, "ws2", "bob", "e1", "x", nil)
+	assert.ErrorIs(t, err, ErrElementNotFound, "elements are workspace scoped")
+
+	groups, err = svc.Groups(ctx, "ws1", groupID)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	require.Len(t, groups[0].Elements, 1)
+	assert.Equal(t, GeometryLineString, groups[0].Elements[0].Resource.Content.Geometry.Type)
+
+	require.NoError(t, svc.Delete(ctx, "ws1", "e1"))
+	assert.ErrorIs(t, svc.Delete(ctx, "ws1", "e1"), ErrElementNotFound)
+
+	events := make([]string, len(notifier.events))
+	for i, n := range notifier.events {
+		assert.Equal(t, "ws1", n.workspaceID)
+		events[i] = n.event
+	}
+	assert.Equal(t, []string{EventElementCreate, EventElementUpdate, EventElementDelete}, events)
+	assert.Equal(t, deletedElement{ID: "e1", GroupID: groupID}, notifier.events[2].data)
+}
+
+func TestService_ClearGroupAndLock(t *testing.T) {
+	svc, notifier := setupService(t)
+	ctx := context.Background()
+
+	groups, err := svc.Groups(ctx, "ws1", "")
+	require.NoError(t, err)
+	groupID := groups[1].ID
+
+	for _, id := range []string{"a", "b"} {
+		_, err := svc.Create(ctx, "ws1", "alice", groupID, id, id, feature(GeometryPoint, `[113.95, 22.54]`))
+		require.NoError(t, err)
+	}
+	require.NoError(t, svc.ClearGroup(ctx, "ws1", groupID))
+
+	groups, err = svc.Groups(ctx, "ws1", groupID)
+	require.NoError(t, err)
+	assert.Empty(t, groups[0].Elements)
+	assert.Equal(t, EventGroupRefresh, notifier.events[len(notifier.events)-1].event)
+
+	require.NoError(t, svc.db.Model(&models.MapElementGroup{}).Where("id = ?", groupID).Update("is_lock", true).Error)
+	_, err = svc.Create(ctx, "ws1", "alice", groupID, "c", "c", feature(GeometryPoint, `[113.95, 22.54]`))
+	assert.ErrorIs(t, err, ErrGroupLocked)
+}
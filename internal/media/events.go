@@ -0,0 +1,78 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	dji "github.com/utmos/utmos/pkg/adapter/dji"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/file"
+	djirouter "github.com/utmos/utmos/pkg/adapter/dji/router"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+// uplinkEventData is the payload of event messages routed by iot-uplink.
+type uplinkEventData struct {
+	Events []struct {
+		Name   string          `json:"name"`
+		Params json.RawMessage `json:"params"`
+	} `json:"events"`
+}
+
+// ErrUnboundDevice is returned for uploads of docks not bound to a workspace.
+var ErrUnboundDevice = errors.New("device is not bound to a workspace")
+
+// HandleUplinkEvent catalogues the media files reported by dock
+// file_upload_callback events. Other events, and uploads of docks not bound
+// to a workspace, are ignored. It matches the
+// rabbitmq.MessageHandler signature so it can consume iot.api.event directly.
+func (s *Service) HandleUplinkEvent(ctx context.Context, msg *rabbitmq.StandardMessage) error {
+	if msg == nil || (msg.ProtocolMeta != nil && msg.ProtocolMeta.Vendor != "" && msg.ProtocolMeta.Vendor != dji.VendorDJI) {
+		return nil
+	}
+
+	var data uplinkEventData
+	if err := msg.GetData(&data); err != nil {
+		return fmt.Errorf("failed to decode event message: %w", err)
+	}
+
+	for _, event := range data.Events {
+		if event.Name != djirouter.MethodFileUploadCallback {
+			continue
+		}
+		var callback file.UploadCallbackData
+		if err := json.Unmarshal(event.Params, &callback); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", djirouter.MethodFileUploadCallback, err)
+		}
+		_, err := s.RecordDockUpload(ctx, msg.DeviceSN, &callback)
+		if errors.Is(err, ErrUnboundDevice) {
+			s.logger.WithField("device_sn", msg.DeviceSN).Warn("Ignoring media upload of unbound dock")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordDockUpload catalogues a file a dock reported through
+// file_upload_callback in the workspace the dock is bound to, so it is listed
+// and deduplicated with the workspace's Pilot 2 uploads.
+func (s *Service) RecordDockUpload(ctx context.Context, gatewaySN string, data *file.UploadCallbackData) (*models.MediaFile, error) {
+	var dock models.Device
+	err := s.db.WithContext(ctx).Select("workspace_id").Where("device_sn = ?", gatewaySN).First(&dock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && dock.WorkspaceID == "") {
+		return nil, ErrUnboundDevice
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dock: %w", err)
+	}
+
+	uploader := Uploader{WorkspaceID: dock.WorkspaceID, Source: models.MediaSourceDock, DeviceSN: gatewaySN}
+	return s.Record(ctx, NewMediaFile(uploader, &data.File))
+}
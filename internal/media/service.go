@@ -0,0 +1,190 @@
+// Package media maintains the media catalog shared by dock flights and DJI
+// Pilot 2 uploads, so imagery from both can be searched together.
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/file"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// ErrNotFound is returned when no catalogued file matches a fingerprint.
+var ErrNotFound = errors.New("media file not found")
+
+// Uploader identifies who reported a media file.
+type Uploader struct {
+	WorkspaceID string
+	Source      string
+	DeviceSN    string
+	Username    string
+}
+
+// GroupUpload is the summary Pilot 2 reports after uploading a file group.
+type GroupUpload struct {
+	FileGroupID       string
+	FlightID          string
+	FileCount         int
+	FileUploadedCount int
+}
+
+// Service records media files into the catalog.
+type Service struct {
+	db     *gorm.DB
+	logger *logrus.Entry
+}
+
+// NewService creates a new media catalog service.
+func NewService(db *gorm.DB, logger *logrus.Entry) *Service {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Service{
+		db:     db,
+		logger: logger.WithField("component", "media"),
+	}
+}
+
+// NewMediaFile maps a DJI upload report to a catalog entry.
+func NewMediaFile(uploader Uploader, info *file.Info) *models.MediaFile {
+	m := &models.MediaFile{
+		WorkspaceID:      uploader.WorkspaceID,
+		Source:           uploader.Source,
+		DeviceSN:         uploader.DeviceSN,
+		Username:         uploader.Username,
+		Name:             info.Name,
+		Path:             info.Path,
+		ObjectKey:        info.ObjectKey,
+		Fingerprint:      info.Ext.Fingerprint,
+		TinyFingerprint:  info.Ext.TinnyFingerprint,
+		FlightID:         info.Ext.FlightID,
+		DroneModelKey:    info.Ext.DroneModelKey,
+		PayloadModelKey:  info.Ext.PayloadModelKey,
+		Latitude:         info.Metadata.ShootPosition.Lat,
+		Longitude:        info.Metadata.ShootPosition.Lng,
+		AbsoluteAltitude: info.Metadata.AbsoluteAltitude,
+		RelativeAltitude: info.Metadata.RelativeAltitude,
+		GimbalYawDegree:  info.Metadata.GimbalYawDegree,
+		IsOriginal:       info.Ext.IsOriginal,
+	}
+	if info.Ext.SN != "" {
+		m.DeviceSN = info.Ext.SN
+	}
+	if t, err := time.Parse(time.RFC3339, info.Metadata.CreateTime); err == nil {
+		m.ShootTime = &t
+	}
+	return m
+}
+
+// recordColumns are the columns a repeated report of a catalogued file
+// replaces. Reports restore deleted entries.
+var recordColumns = []string{
+	"shoot_time", "deleted_at", "updated_at", "source", "device_sn", "username", "name", "path",
+	"fingerprint", "tiny_fingerprint", "flight_id", "drone_model_key", "payload_model_key",
+	"latitude", "longitude", "absolute_altitude", "relative_altitude", "gimbal_yaw_degree", "is_original",
+}
+
+// Record adds a file to the catalog. A file already catalogued under the same
+// workspace and object key is updated in place, so repeated or concurrent
+// reports of the same upload are idempotent.
+func (s *Service) Record(ctx context.Context, m *models.MediaFile) (*models.MediaFile, error) {
+	if m.ObjectKey == "" {
+		return nil, fmt.Errorf("object_key is required")
+	}
+
+	m.ID = 0
+	m.DeletedAt = gorm.DeletedAt{}
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "workspace_id"}, {Name: "object_key"}},
+		DoUpdates: clause.AssignmentColumns(recordColumns),
+	}).Create(m).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to record media file: %w", err)
+	}
+	// The ID of an updated entry is not returned by every database
+	var stored models.MediaFile
+	if err := s.db.WithContext(ctx).
+		Where("workspace_id = ? AND object_key = ?", m.WorkspaceID, m.ObjectKey).
+		First(&stored).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up media file: %w", err)
+	}
+	m = &stored
+
+	s.logger.WithFields(logrus.Fields{
+		"workspace_id": m.WorkspaceID,
+		"source":       m.Source,
+		"object_key":   m.ObjectKey,
+		"flight_id":    m.FlightID,
+	}).Info("Media file catalogued")
+	return m, nil
+}
+
+// FindByFingerprint returns the catalogued file with the given fingerprint,
+// or ErrNotFound. Pilot 2 uses this to skip uploading files the cloud
+// already holds.
+func (s *Service) FindByFingerprint(ctx context.Context, workspaceID, fingerprint string) (*models.MediaFile, error) {
+	var m models.MediaFile
+	err := s.db.WithContext(ctx).
+		Where("workspace_id = ? AND fingerprint = ?", workspaceID, fingerprint).
+		First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up fingerprint: %w", err)
+	}
+	return &m, nil
+}
+
+// ExistingTinyFingerprints returns the subset of tinyFingerprints already
+// catalogued in the workspace.
+func (s *Service) ExistingTinyFingerprints(ctx context.Context, workspaceID string, tinyFingerprints []string) ([]string, error) {
+	existing := []string{}
+	if len(tinyFingerprints) == 0 {
+		return existing, nil
+	}
+	err := s.db.WithContext(ctx).Model(&models.MediaFile{}).
+		Where("workspace_id = ? AND tiny_fingerprint IN ?", workspaceID, tinyFingerprints).
+		Distinct().
+		Pluck("tiny_fingerprint", &existing).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up tiny fingerprints: %w", err)
+	}
+	return existing, nil
+}
+
+// CompleteGroup records the end of a Pilot 2 group upload and returns how
+// many files of the flight are catalogued.
+func (s *Service) CompleteGroup(ctx context.Context, workspaceID string, group GroupUpload) (int64, error) {
+	var catalogued int64
+	if group.FlightID != "" {
+		err := s.db.WithContext(ctx).Model(&models.MediaFile{}).
+			Where("workspace_id = ? AND flight_id = ?", workspaceID, group.FlightID).
+			Count(&catalogued).Error
+		if err != nil {
+			return 0, fmt.Errorf("failed to count flight media: %w", err)
+		}
+	}
+
+	entry := s.logger.WithFields(logrus.Fields{
+		"workspace_id":        workspaceID,
+		"file_group_id":       group.FileGroupID,
+		"flight_id":           group.FlightID,
+		"file_count":          group.FileCount,
+		"file_uploaded_count": group.FileUploadedCount,
+		"catalogued":          catalogued,
+	})
+	if group.FileUploadedCount < group.FileCount {
+		entry.Warn("Media group upload incomplete")
+	} else {
+		entry.Info("Media group upload completed")
+	}
+	return catalogued, nil
+}
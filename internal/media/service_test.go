@@ -0,0 +1,184 @@
+package media
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/file"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+func setupService(t *testing.T) (*Service, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.MediaFile{}, &models.Device{}))
+	return NewService(db, nil), db
+}
+
+func TestNewMediaFile(t *testing.T) {
+	info := &file.Info{
+		ObjectKey: "workspaces/ws1/DJI_0001.JPG",
+		Path:      "flight-1",
+		Name:      "DJI_0001.JPG",
+		Ext: file.Ext{
+			FlightID:         "flight-1",
+			DroneModelKey:    "0-67-0",
+			PayloadModelKey:  "1-53-0",
+			IsOriginal:       true,
+			SN:               "drone-sn",
+			Fingerprint:      "fp",
+			TinnyFingerprint: "tiny",
+		},
+		Metadata: file.MediaMetadata{
+			AbsoluteAltitude: 120.5,
+			CreateTime:       "2024-05-01T10:00:00+08:00",
+			ShootPosition:    file.ShootPosition{Lat: 22.5, Lng: 113.9},
+		},
+	}
+
+	m := NewMediaFile(Uploader{WorkspaceID: "ws1", Source: models.MediaSourcePilot, DeviceSN: "rc-sn"}, info)
+	assert.Equal(t, "drone-sn", m.DeviceSN, "ext.sn names the capturing device")
+	assert.Equal(t, "fp", m.Fingerprint)
+	assert.Equal(t, "tiny", m.TinyFingerprint)
+	assert.Equal(t, 22.5, m.Latitude)
+	require.NotNil(t, m.ShootTime)
+	assert.Equal(t, int64(1714528800), m.ShootTime.Unix())
+}
+
+func TestService_RecordIsIdempotentPerObjectKey(t *testing.T) {
+	svc, db := setupService(t)
+	ctx := context.Background()
+
+	first, err := svc.Record(ctx, &models.MediaFile{WorkspaceID: "ws1", Source: models.MediaSourcePilot, Name: "a.jpg", ObjectKey: "k/a.jpg"})
+	require.NoError(t, err)
+	second, err := svc.Record(ctx, &models.MediaFile{WorkspaceID: "ws1", Source: models.MediaSourcePilot, Name: "a.jpg", ObjectKey: "k/a.jpg", Fingerprint: "fp"})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+
+	_, err = svc.Record(ctx, &models.MediaFile{WorkspaceID: "ws2", Source: models.MediaSourcePilot, Name: "a.jpg", ObjectKey: "k/a.jpg"})
+	require.NoError(t, err)
+
+	var count int64
+	require.NoError(t, db.Model(&models.MediaFile{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+
+	_, err = svc.Record(ctx, &models.MediaFile{WorkspaceID: "ws1", Name: "b.jpg"})
+	assert.Error(t, err)
+}
+
+func TestService_RecordUpsertsByObjectKey(t *testing.T) {
+	svc, db := setupService(t)
+	ctx := context.Background()
+
+	first, err := svc.Record(ctx, &models.MediaFile{WorkspaceID: "ws1", Source: models.MediaSourcePilot, Name: "a.jpg", ObjectKey: "k/a.jpg"})
+	require.NoError(t, err)
+	require.NoError(t, db.Delete(first).Error)
+
+	// Re-reporting a deleted file restores it with the new metadata
+	second, err := svc.Record(ctx, &models.MediaFile{WorkspaceID: "ws1", Source: models.MediaSourceDock, Name: "a.jpg", ObjectKey: "k/a.jpg", Fingerprint: "fp"})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, "fp", second.Fingerprint)
+	assert.Equal(t, models.MediaSourceDock, second.Source)
+	assert.False(t, second.DeletedAt.Valid)
+
+	// The catalogue rejects duplicates written around Record
+	err = db.Create(&models.MediaFile{WorkspaceID: "ws1", Name: "a.jpg", ObjectKey: "k/a.jpg"}).Error
+	assert.Error(t, err)
+
+	var count int64
+	require.NoError(t, db.Unscoped().Model(&models.MediaFile{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestService_Fingerprints(t *testing.T) {
+	svc, _ := setupService(t)
+	ctx := context.Background()
+
+	_, err := svc.Record(ctx, &models.MediaFile{WorkspaceID: "ws1", Name: "a", ObjectKey: "a", Fingerprint: "fp-a", TinyFingerprint: "tiny-a"})
+	require.NoError(t, err)
+	_, err = svc.Record(ctx, &models.MediaFile{WorkspaceID: "ws2", Name: "b", ObjectKey: "b", Fingerprint: "fp-b", TinyFingerprint: "tiny-b"})
+	require.NoError(t, err)
+
+	m, err := svc.FindByFingerprint(ctx, "ws1", "fp-a")
+	require.NoError(t, err)
+	assert.Equal(t, "a", m.ObjectKey)
+
+	_, err = svc.FindByFingerprint(ctx, "ws1", "fp-b")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	existing, err := svc.ExistingTinyFingerprints(ctx, "ws1", []string{"tiny-a", "tiny-b", "tiny-c"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tiny-a"}, existing)
+
+	existing, err = svc.ExistingTinyFingerprints(ctx, "ws1", nil)
+	require.NoError(t, err)
+	assert.Empty(t, existing)
+}
+
+func TestService_HandleUplinkEvent(t *testing.T) {
+	svc, db := setupService(t)
+	require.NoError(t, db.Create(&models.Device{DeviceSN: "dock-sn", DeviceName: "dock", DeviceType: "dock", WorkspaceID: "ws1"}).Error)
+	_, err := svc.Record(context.Background(), &models.MediaFile{WorkspaceID: "ws1", Source: models.MediaSourcePilot, Name: "DJI_0001.JPG", ObjectKey: "media/f1/DJI_0001.JPG"})
+	require.NoError(t, err)
+
+	data := map[string]any{
+		"events": []map[string]any{
+			{"name": "flighttask_progress", "params": map[string]any{"flight_id": "f1"}},
+			{"name": "file_upload_callback", "params": map[string]any{
+				"file": map[string]any{
+					"object_key": "media/f1/DJI_0001.JPG",
+					"name":       "DJI_0001.JPG",
+					"path":       "f1",
+					"ext":        map[string]any{"flight_id": "f1", "drone_model_key": "0-91-0", "is_original": true},
+					"metadata":   map[string]any{"shoot_position": map[string]any{"lat": 22.5, "lng": 113.9}},
+				},
+				"flight_task": map[string]any{"uploaded_file_count": 1, "expected_file_count": 2},
+			}},
+		},
+	}
+	msg, err := rabbitmq.NewStandardMessage("iot-uplink", "event.processed", "dock-sn", data)
+	require.NoError(t, err)
+	msg.ProtocolMeta = &rabbitmq.ProtocolMeta{Vendor: "dji"}
+
+	require.NoError(t, svc.HandleUplinkEvent(context.Background(), msg))
+
+	// The dock's upload lands in its workspace and replaces the Pilot record
+	// of the same object.
+	var files []models.MediaFile
+	require.NoError(t, db.Find(&files).Error)
+	require.Len(t, files, 1)
+	assert.Equal(t, "ws1", files[0].WorkspaceID)
+	assert.Equal(t, models.MediaSourceDock, files[0].Source)
+	assert.Equal(t, "dock-sn", files[0].DeviceSN)
+	assert.Equal(t, "f1", files[0].FlightID)
+	assert.True(t, files[0].IsOriginal)
+
+	// Unbound docks and other vendors are ignored.
+	unbound, err := rabbitmq.NewStandardMessage("iot-uplink", "event.processed", "other-dock", data)
+	require.NoError(t, err)
+	require.NoError(t, svc.HandleUplinkEvent(context.Background(), unbound))
+	msg.ProtocolMeta.Vendor = "tuya"
+	require.NoError(t, svc.HandleUplinkEvent(context.Background(), msg))
+	var count int64
+	require.NoError(t, db.Model(&models.MediaFile{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestService_CompleteGroup(t *testing.T) {
+	svc, _ := setupService(t)
+	ctx := context.Background()
+
+	_, err := svc.Record(ctx, &models.MediaFile{WorkspaceID: "ws1", Name: "a", ObjectKey: "a", FlightID: "f1"})
+	require.NoError(t, err)
+
+	catalogued, err := svc.CompleteGroup(ctx, "ws1", GroupUpload{FileGroupID: "g1", FlightID: "f1", FileCount: 2, FileUploadedCount: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), catalogued)
+}
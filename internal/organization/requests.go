@@ -0,0 +1,142 @@
+package organization
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	dji "github.com/utmos/utmos/pkg/adapter/dji"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/config"
+	djirouter "github.com/utmos/utmos/pkg/adapter/dji/router"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+// Result codes of request replies.
+const (
+	resultOK    = 0
+	resultError = 1
+)
+
+// RoutingKeyReply is the routing key request replies are published with.
+// The DJI adapter forwards them to the device's requests_reply topic.
+var RoutingKeyReply = rabbitmq.NewRoutingKey(dji.VendorDJI, "device", dji.ActionDeviceRequestReply).String()
+
+// RoutingKeyRequest is the routing key the DJI adapter publishes device
+// requests with.
+var RoutingKeyRequest = rabbitmq.NewRoutingKey(dji.VendorDJI, "device", dji.ActionDeviceRequest).String()
+
+// reply is the data of a requests_reply message.
+type reply struct {
+	Result int `json:"result"`
+	Output any `json:"output"`
+}
+
+type bindStatusOutput struct {
+	BindStatus []config.AirportBindStatusItem `json:"bind_status"`
+}
+
+type organizationGetOutput struct {
+	OrganizationName string `json:"organization_name"`
+}
+
+type organizationBindOutput struct {
+	ErrInfos []config.AirportBindErrorInfo `json:"err_infos"`
+}
+
+// HandleDeviceRequest answers airport_bind_status, airport_organization_get
+// and airport_organization_bind requests. Other requests are ignored. It
+// matches the rabbitmq.MessageHandler signature so it can consume device
+// requests directly.
+func (s *Service) HandleDeviceRequest(ctx context.Context, msg *rabbitmq.StandardMessage) error {
+	if msg == nil || msg.ProtocolMeta == nil || msg.ProtocolMeta.Vendor != dji.VendorDJI {
+		return nil
+	}
+
+	method := msg.ProtocolMeta.Method
+	var (
+		out reply
+		err error
+	)
+	switch method {
+	case djirouter.MethodAirportBindStatus:
+		out, err = s.handleBindStatus(ctx, msg)
+	case djirouter.MethodAirportOrganizationGet:
+		out, err = s.handleOrganizationGet(ctx, msg)
+	case djirouter.MethodAirportOrganizationBind:
+		out, err = s.handleOrganizationBind(ctx, msg)
+	default:
+		return nil
+	}
+	if err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"device_sn": msg.DeviceSN,
+			"method":    method,
+		}).Warn("Failed to answer organization request")
+		out = reply{Result: resultError, Output: struct{}{}}
+	}
+	return s.reply(ctx, msg, out)
+}
+
+func (s *Service) handleBindStatus(ctx context.Context, msg *rabbitmq.StandardMessage) (reply, error) {
+	var data config.AirportBindStatusRequestData
+	if err := msg.GetData(&data); err != nil {
+		return reply{}, fmt.Errorf("failed to decode %s: %w", djirouter.MethodAirportBindStatus, err)
+	}
+	sns := make([]string, 0, len(data.Devices))
+	for _, device := range data.Devices {
+		sns = append(sns, device.SN)
+	}
+	items, err := s.BindStatus(ctx, sns)
+	if err != nil {
+		return reply{}, err
+	}
+	return reply{Result: resultOK, Output: bindStatusOutput{BindStatus: items}}, nil
+}
+
+func (s *Service) handleOrganizationGet(ctx context.Context, msg *rabbitmq.StandardMessage) (reply, error) {
+	var data config.AirportOrganizationGetRequestData
+	if err := msg.GetData(&data); err != nil {
+		return reply{}, fmt.Errorf("failed to decode %s: %w", djirouter.MethodAirportOrganizationGet, err)
+	}
+	name, err := s.OrganizationName(ctx, data.OrganizationID, data.DeviceBindingCode)
+	if err != nil {
+		return reply{}, err
+	}
+	return reply{Result: resultOK, Output: organizationGetOutput{OrganizationName: name}}, nil
+}
+
+func (s *Service) handleOrganizationBind(ctx context.Context, msg *rabbitmq.StandardMessage) (reply, error) {
+	var data config.AirportOrganizationBindRequestData
+	if err := msg.GetData(&data); err != nil {
+		return reply{}, fmt.Errorf("failed to decode %s: %w", djirouter.MethodAirportOrganizationBind, err)
+	}
+	errInfos, err := s.Bind(ctx, msg.DeviceSN, data.BindDevices)
+	if err != nil {
+		return reply{}, err
+	}
+	result := resultOK
+	if len(errInfos) > 0 {
+		result = resultError
+	}
+	return reply{Result: result, Output: organizationBindOutput{ErrInfos: errInfos}}, nil
+}
+
+// reply publishes out to the device on its requests_reply topic.
+func (s *Service) reply(ctx context.Context, req *rabbitmq.StandardMessage, out reply) error {
+	if s.publisher == nil {
+		return nil
+	}
+	msg, err := rabbitmq.NewStandardMessageWithIDs(req.TID, req.BID, "iot-api", dji.ActionDeviceRequestReply, req.DeviceSN, out)
+	if err != nil {
+		return fmt.Errorf("failed to create request reply: %w", err)
+	}
+	msg.ProtocolMeta = &rabbitmq.ProtocolMeta{
+		Vendor: dji.VendorDJI,
+		Method: req.ProtocolMeta.Method,
+	}
+	if err := s.publisher.Publish(ctx, RoutingKeyReply, msg); err != nil {
+		return fmt.Errorf("failed to publish request reply: %w", err)
+	}
+	return nil
+}
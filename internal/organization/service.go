@@ -0,0 +1,210 @@
+// Package organization implements the DJI dock organization binding flow.
+// Docks ask whether they are bound, look up an organization by binding
+// code and bind themselves and their aircraft to it; organizations are
+// workspaces.
+package organization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/topology"
+	dji "github.com/utmos/utmos/pkg/adapter/dji"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/config"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+// Bind error codes reported per device in AirportBindErrorInfo.
+const (
+	// BindErrOrganizationNotFound means the organization ID and binding
+	// code do not match a workspace.
+	BindErrOrganizationNotFound = 1
+	// BindErrInternal means the device could not be saved.
+	BindErrInternal = 2
+)
+
+// ErrOrganizationNotFound is returned when no workspace matches an
+// organization ID and binding code.
+var ErrOrganizationNotFound = errors.New("organization not found")
+
+// Publisher publishes replies to the devices.
+type Publisher interface {
+	Publish(ctx context.Context, routingKey string, msg *rabbitmq.StandardMessage) error
+}
+
+// Service answers dock organization requests from the device registry.
+type Service struct {
+	db        *gorm.DB
+	publisher Publisher
+	logger    *logrus.Entry
+}
+
+// NewService creates a new organization service. Replies are not sent when
+// publisher is nil.
+func NewService(db *gorm.DB, publisher Publisher, logger *logrus.Entry) *Service {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Service{
+		db:        db,
+		publisher: publisher,
+		logger:    logger.WithField("component", "organization"),
+	}
+}
+
+// BindStatus reports whether each device is bound to an organization.
+// Unknown devices are reported unbound.
+func (s *Service) BindStatus(ctx context.Context, deviceSNs []string) ([]config.AirportBindStatusItem, error) {
+	var devices []models.Device
+	if len(deviceSNs) > 0 {
+		if err := s.db.WithContext(ctx).Where("device_sn IN ?", deviceSNs).Find(&devices).Error; err != nil {
+			return nil, fmt.Errorf("failed to get devices: %w", err)
+		}
+	}
+
+	workspaceIDs := make([]string, 0, len(devices))
+	bySN := make(map[string]*models.Device, len(devices))
+	for i := range devices {
+		bySN[devices[i].DeviceSN] = &devices[i]
+		if devices[i].WorkspaceID != "" {
+			workspaceIDs = append(workspaceIDs, devices[i].WorkspaceID)
+		}
+	}
+	names, err := s.workspaceNames(ctx, workspaceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]config.AirportBindStatusItem, 0, len(deviceSNs))
+	for _, sn := range deviceSNs {
+		item := config.AirportBindStatusItem{SN: sn}
+		if device, ok := bySN[sn]; ok {
+			item.DeviceCallsign = device.DeviceName
+			if name, bound := names[device.WorkspaceID]; bound {
+				item.IsDeviceBindOrganization = true
+				item.OrganizationID = device.WorkspaceID
+				item.OrganizationName = name
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// OrganizationName returns the name of the workspace matching the
+// organization ID and binding code.
+func (s *Service) OrganizationName(ctx context.Context, organizationID, bindingCode string) (string, error) {
+	w, err := s.organization(ctx, organizationID, bindingCode)
+	if err != nil {
+		return "", err
+	}
+	return w.Name, nil
+}
+
+// Bind creates or updates the devices and moves them into the workspaces
+// their binding codes name. Devices other than the requesting gateway are
+// attached to it. Devices that could not be bound are returned with an
+// error code; the others are bound regardless.
+func (s *Service) Bind(ctx context.Context, gatewaySN string, items []config.AirportBindDeviceItem) ([]config.AirportBindErrorInfo, error) {
+	errInfos := make([]config.AirportBindErrorInfo, 0)
+	for _, item := range items {
+		if _, err := s.organization(ctx, item.OrganizationID, item.DeviceBindingCode); err != nil {
+			if !errors.Is(err, ErrOrganizationNotFound) {
+				return nil, err
+			}
+			errInfos = append(errInfos, config.AirportBindErrorInfo{SN: item.SN, ErrCode: BindErrOrganizationNotFound})
+			continue
+		}
+		if err := s.bindDevice(ctx, gatewaySN, item); err != nil {
+			s.logger.WithError(err).WithField("device_sn", item.SN).Warn("Failed to bind device")
+			errInfos = append(errInfos, config.AirportBindErrorInfo{SN: item.SN, ErrCode: BindErrInternal})
+			continue
+		}
+		s.logger.WithFields(logrus.Fields{
+			"device_sn":    item.SN,
+			"workspace_id": item.OrganizationID,
+		}).Info("Device bound to organization")
+	}
+	return errInfos, nil
+}
+
+func (s *Service) bindDevice(ctx context.Context, gatewaySN string, item config.AirportBindDeviceItem) error {
+	var device models.Device
+	err := s.db.WithContext(ctx).Where("device_sn = ?", item.SN).First(&device).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to get device: %w", err)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		device = models.Device{
+			DeviceSN:   item.SN,
+			DeviceType: DeviceType(item.DeviceModelKey),
+			Vendor:     dji.VendorDJI,
+			Status:     models.DeviceStatusUnknown,
+		}
+	}
+
+	device.WorkspaceID = item.OrganizationID
+	if item.DeviceModelKey != "" {
+		device.ModelKey = item.DeviceModelKey
+	}
+	if item.DeviceCallsign != "" {
+		device.DeviceName = item.DeviceCallsign
+	}
+	if device.DeviceName == "" {
+		device.DeviceName = item.SN
+	}
+	if gatewaySN != "" && item.SN != gatewaySN {
+		gateway := gatewaySN
+		device.GatewaySN = &gateway
+	}
+
+	if err := s.db.WithContext(ctx).Save(&device).Error; err != nil {
+		return fmt.Errorf("failed to save device: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) organization(ctx context.Context, organizationID, bindingCode string) (*models.Workspace, error) {
+	if organizationID == "" || bindingCode == "" {
+		return nil, ErrOrganizationNotFound
+	}
+	var w models.Workspace
+	err := s.db.WithContext(ctx).Where("id = ? AND bind_code = ?", organizationID, bindingCode).First(&w).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return &w, nil
+}
+
+func (s *Service) workspaceNames(ctx context.Context, ids []string) (map[string]string, error) {
+	names := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+	var workspaces []models.Workspace
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&workspaces).Error; err != nil {
+		return nil, fmt.Errorf("failed to get workspaces: %w", err)
+	}
+	for _, w := range workspaces {
+		names[w.ID] = w.Name
+	}
+	return names, nil
+}
+
+// DeviceType returns the device type for a DJI device model key of the
+// form domain-type-sub_type: domain 0 is an aircraft, 1 a payload, 2 a
+// remote controller gateway and 3 a dock. Unknown domains are gateways.
+func DeviceType(deviceModelKey string) string {
+	if deviceType := topology.DeviceType(deviceModelKey); deviceType != "" {
+		return deviceType
+	}
+	return topology.DefaultGatewayType
+}
@@ -0,0 +1,156 @@
+package organization
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/topology"
+	dji "github.com/utmos/utmos/pkg/adapter/dji"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/config"
+	djirouter "github.com/utmos/utmos/pkg/adapter/dji/router"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+type published struct {
+	routingKey string
+	msg        *rabbitmq.StandardMessage
+}
+
+type recordingPublisher struct {
+	mu   sync.Mutex
+	msgs []published
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, routingKey string, msg *rabbitmq.StandardMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.msgs = append(p.msgs, published{routingKey: routingKey, msg: msg})
+	return nil
+}
+
+func (p *recordingPublisher) last(t *testing.T) published {
+	t.Helper()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	require.NotEmpty(t, p.msgs)
+	return p.msgs[len(p.msgs)-1]
+}
+
+func setupService(t *testing.T) (*Service, *recordingPublisher) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Workspace{}, &models.Device{}))
+	require.NoError(t, db.Create(&models.Workspace{ID: "ws1", Name: "Site A", BindCode: "123456"}).Error)
+	publisher := &recordingPublisher{}
+	return NewService(db, publisher, nil), publisher
+}
+
+func request(t *testing.T, method string, data any) *rabbitmq.StandardMessage {
+	t.Helper()
+	msg, err := rabbitmq.NewStandardMessageWithIDs("tid-1", "bid-1", "dji-adapter", dji.ActionDeviceRequest, "DOCK1", data)
+	require.NoError(t, err)
+	msg.ProtocolMeta = &rabbitmq.ProtocolMeta{Vendor: dji.VendorDJI, Method: method}
+	return msg
+}
+
+func decodeReply(t *testing.T, p published, output any) int {
+	t.Helper()
+	assert.Equal(t, RoutingKeyReply, p.routingKey)
+	assert.Equal(t, dji.ActionDeviceRequestReply, p.msg.Action)
+	assert.Equal(t, "tid-1", p.msg.TID)
+	assert.Equal(t, "DOCK1", p.msg.DeviceSN)
+
+	var data struct {
+		Result int             `json:"result"`
+		Output json.RawMessage `json:"output"`
+	}
+	require.NoError(t, p.msg.GetData(&data))
+	if output != nil {
+		require.NoError(t, json.Unmarshal(data.Output, output))
+	}
+	return data.Result
+}
+
+func TestService_OrganizationGet(t *testing.T) {
+	svc, publisher := setupService(t)
+	ctx := context.Background()
+
+	require.NoError(t, svc.HandleDeviceRequest(ctx, request(t, djirouter.MethodAirportOrganizationGet,
+		config.AirportOrganizationGetRequestData{DeviceBindingCode: "123456", OrganizationID: "ws1"})))
+	var out organizationGetOutput
+	assert.Equal(t, resultOK, decodeReply(t, publisher.last(t), &out))
+	assert.Equal(t, "Site A", out.OrganizationName)
+
+	require.NoError(t, svc.HandleDeviceRequest(ctx, request(t, djirouter.MethodAirportOrganizationGet,
+		config.AirportOrganizationGetRequestData{DeviceBindingCode: "000000", OrganizationID: "ws1"})))
+	assert.Equal(t, resultError, decodeReply(t, publisher.last(t), nil))
+}
+
+func TestService_BindFlow(t *testing.T) {
+	svc, publisher := setupService(t)
+	ctx := context.Background()
+
+	statusRequest := request(t, djirouter.MethodAirportBindStatus, map[string]any{
+		"devices": []map[string]string{{"sn": "DOCK1"}, {"sn": "UAV1"}},
+	})
+	require.NoError(t, svc.HandleDeviceRequest(ctx, statusRequest))
+	var status bindStatusOutput
+	assert.Equal(t, resultOK, decodeReply(t, publisher.last(t), &status))
+	require.Len(t, status.BindStatus, 2)
+	assert.False(t, status.BindStatus[0].IsDeviceBindOrganization)
+
+	require.NoError(t, svc.HandleDeviceRequest(ctx, request(t, djirouter.MethodAirportOrganizationBind,
+		config.AirportOrganizationBindRequestData{BindDevices: []config.AirportBindDeviceItem{
+			{SN: "DOCK1", DeviceBindingCode: "123456", OrganizationID: "ws1", DeviceCallsign: "Dock 1", DeviceModelKey: "3-1-0"},
+			{SN: "UAV1", DeviceBindingCode: "123456", OrganizationID: "ws1", DeviceCallsign: "UAV 1", DeviceModelKey: "0-67-0"},
+			{SN: "UAV2", DeviceBindingCode: "bad", OrganizationID: "ws1", DeviceModelKey: "0-67-0"},
+		}})))
+	var bind organizationBindOutput
+	assert.Equal(t, resultError, decodeReply(t, publisher.last(t), &bind))
+	assert.Equal(t, []config.AirportBindErrorInfo{{SN: "UAV2", ErrCode: BindErrOrganizationNotFound}}, bind.ErrInfos)
+
+	var aircraft models.Device
+	require.NoError(t, svc.db.Where("device_sn = ?", "UAV1").First(&aircraft).Error)
+	assert.Equal(t, "ws1", aircraft.WorkspaceID)
+	assert.Equal(t, topology.DeviceTypeAircraft, aircraft.DeviceType)
+	assert.Equal(t, "UAV 1", aircraft.DeviceName)
+	require.NotNil(t, aircraft.GatewaySN)
+	assert.Equal(t, "DOCK1", *aircraft.GatewaySN)
+
+	var dock models.Device
+	require.NoError(t, svc.db.Where("device_sn = ?", "DOCK1").First(&dock).Error)
+	assert.Equal(t, topology.DeviceTypeDock, dock.DeviceType)
+	assert.Nil(t, dock.GatewaySN)
+
+	require.NoError(t, svc.HandleDeviceRequest(ctx, statusRequest))
+	assert.Equal(t, resultOK, decodeReply(t, publisher.last(t), &status))
+	assert.Equal(t, config.AirportBindStatusItem{
+		SN:                       "DOCK1",
+		IsDeviceBindOrganization: true,
+		OrganizationID:           "ws1",
+		OrganizationName:         "Site A",
+		DeviceCallsign:           "Dock 1",
+	}, status.BindStatus[0])
+}
+
+func TestService_IgnoresOtherRequests(t *testing.T) {
+	svc, publisher := setupService(t)
+	require.NoError(t, svc.HandleDeviceRequest(context.Background(), request(t, "config", map[string]any{})))
+	assert.Empty(t, publisher.msgs)
+}
+
+func TestDeviceType(t *testing.T) {
+	assert.Equal(t, topology.DeviceTypeAircraft, DeviceType("0-67-0"))
+	assert.Equal(t, topology.DeviceTypePayload, DeviceType("1-42-0"))
+	assert.Equal(t, topology.DeviceTypeRC, DeviceType("2-119-0"))
+	assert.Equal(t, topology.DeviceTypeDock, DeviceType("3-1-0"))
+	assert.Equal(t, topology.DefaultGatewayType, DeviceType(""))
+}
@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/aircraft"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// Property and event keys read from the dock's reported state.
+const (
+	PropertyWindSpeed = "wind_speed"
+	PropertyRainfall  = "rainfall"
+	EventHMS          = "hms"
+)
+
+// DockConditions is a snapshot of the dock state relevant to takeoff safety.
+type DockConditions struct {
+	WindSpeed *float64
+	Rainfall  *int
+	HMS       []aircraft.HMSItem
+}
+
+// ConditionSource provides the latest known conditions for a dock.
+type ConditionSource interface {
+	DockConditions(ctx context.Context, dockSN string) (*DockConditions, error)
+}
+
+// Thresholds are the limits above which a scheduled occurrence is skipped.
+type Thresholds struct {
+	// MaxWindSpeed in m/s; zero disables the check.
+	MaxWindSpeed float64
+	// MaxRainfall is the highest tolerated rainfall level (0=none .. 3=heavy).
+	MaxRainfall int
+	// BlockingHMSLevel is the lowest HMS level that blocks takeoff.
+	BlockingHMSLevel int
+}
+
+// SkipReason evaluates conditions against thresholds and returns a
+// non-empty reason when the occurrence must not be flown.
+func (th Thresholds) SkipReason(cond *DockConditions) string {
+	if cond == nil {
+		return ""
+	}
+	if th.MaxWindSpeed > 0 && cond.WindSpeed != nil && *cond.WindSpeed > th.MaxWindSpeed {
+		return fmt.Sprintf("wind speed %.1f m/s exceeds limit %.1f m/s", *cond.WindSpeed, th.MaxWindSpeed)
+	}
+	if cond.Rainfall != nil && *cond.Rainfall > th.MaxRainfall {
+		return fmt.Sprintf("rainfall level %d exceeds limit %d", *cond.Rainfall, th.MaxRainfall)
+	}
+	for _, item := range cond.HMS {
+		if item.Level >= th.BlockingHMSLevel {
+			return fmt.Sprintf("blocking HMS alarm %s (level %d)", item.Code, item.Level)
+		}
+	}
+	return ""
+}
+
+// DBConditionSource reads dock conditions from the device_properties and
+// device_events tables, which ConditionRecorder fills from uplink messages.
+type DBConditionSource struct {
+	db        *gorm.DB
+	clock     Clock
+	hmsMaxAge time.Duration
+}
+
+// NewDBConditionSource creates a DBConditionSource. HMS events older than
+// hmsMaxAge are considered cleared.
+func NewDBConditionSource(db *gorm.DB, clock Clock, hmsMaxAge time.Duration) *DBConditionSource {
+	if clock == nil {
+		clock = RealClock{}
+	}
+	return &DBConditionSource{db: db, clock: clock, hmsMaxAge: hmsMaxAge}
+}
+
+// DockConditions implements ConditionSource.
+func (s *DBConditionSource) DockConditions(ctx context.Context, dockSN string) (*DockConditions, error) {
+	var device models.Device
+	err := s.db.WithContext(ctx).Where("device_sn = ?", dockSN).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &DockConditions{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dock %s: %w", dockSN, err)
+	}
+
+	cond := &DockConditions{}
+
+	// Values are read as text so scalar JSON survives drivers that coerce
+	// numeric-looking column values.
+	var props []struct {
+		PropertyKey   string
+		PropertyValue string
+	}
+	if err := s.db.WithContext(ctx).Model(&models.DeviceProperty{}).
+		Select("property_key, CAST(property_value AS TEXT) AS property_value").
+		Where("device_id = ? AND property_key IN ?", device.ID, []string{PropertyWindSpeed, PropertyRainfall}).
+		Find(&props).Error; err != nil {
+		return nil, fmt.Errorf("failed to load dock properties: %w", err)
+	}
+	for _, p := range props {
+		switch p.PropertyKey {
+		case PropertyWindSpeed:
+			var v float64
+			if json.Unmarshal([]byte(p.PropertyValue), &v) == nil {
+				cond.WindSpeed = &v
+			}
+		case PropertyRainfall:
+			var v int
+			if json.Unmarshal([]byte(p.PropertyValue), &v) == nil {
+				cond.Rainfall = &v
+			}
+		}
+	}
+
+	var event models.DeviceEvent
+	err = s.db.WithContext(ctx).
+		Where("device_id = ? AND event_key = ? AND timestamp >= ?", device.ID, EventHMS, s.clock.Now().Add(-s.hmsMaxAge)).
+		Order("timestamp DESC").
+		First(&event).Error
+	switch {
+	case err == nil:
+		var data aircraft.HMSData
+		if json.Unmarshal(event.EventData, &data) == nil {
+			cond.HMS = data.List
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, fmt.Errorf("failed to load HMS events: %w", err)
+	}
+
+	return cond, nil
+}
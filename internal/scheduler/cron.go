@@ -0,0 +1,186 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCronExpr is returned when a cron expression cannot be parsed.
+var ErrInvalidCronExpr = errors.New("invalid cron expression")
+
+// maxCronSearch bounds how far ahead Next looks for a matching minute.
+const maxCronSearch = 4 * 366 * 24 * time.Hour
+
+// cronDescriptors maps shorthand descriptors to their five-field equivalent.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+	"@weekdays": "0 0 * * 1-5",
+}
+
+type cronField struct {
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 6},  // day of week (7 is accepted as Sunday)
+}
+
+// Schedule is a parsed five-field cron expression
+// ("minute hour day-of-month month day-of-week").
+//
+// For example "0 9,15 * * 1-5" fires every weekday at 09:00 and 15:00.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	expr                          string
+}
+
+// ParseCron parses a standard five-field cron expression or one of the
+// @daily/@hourly/@weekly/@monthly/@yearly/@weekdays descriptors.
+func ParseCron(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	spec := expr
+	if d, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d", ErrInvalidCronExpr, len(parts))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %d %q: %v", ErrInvalidCronExpr, i+1, part, err)
+		}
+		bits[i] = b
+	}
+
+	// Sunday may be written as 7.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return &Schedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: parts[2] == "*" || parts[2] == "?",
+		dowStar: parts[4] == "*" || parts[4] == "?",
+		expr:    expr,
+	}, nil
+}
+
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	maxValue := bounds.max
+	if bounds.max == 6 {
+		maxValue = 7
+	}
+
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(item, "/"); idx >= 0 {
+			s, err := strconv.Atoi(item[idx+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step %q", item[idx+1:])
+			}
+			step = s
+			item = item[:idx]
+		}
+
+		lo, hi := bounds.min, maxValue
+		switch {
+		case item == "*" || item == "?":
+			if bounds.max == 6 {
+				hi = 6
+			}
+		case strings.Contains(item, "-"):
+			rng := strings.SplitN(item, "-", 2)
+			a, errA := strconv.Atoi(rng[0])
+			b, errB := strconv.Atoi(rng[1])
+			if errA != nil || errB != nil {
+				return 0, fmt.Errorf("invalid range %q", item)
+			}
+			lo, hi = a, b
+		default:
+			v, err := strconv.Atoi(item)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", item)
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+
+		if lo < bounds.min || hi > maxValue || lo > hi {
+			return 0, fmt.Errorf("value out of range [%d,%d]", bounds.min, maxValue)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// String returns the original expression.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first activation strictly after t, in t's location.
+// It returns the zero time if no activation exists within four years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxCronSearch)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches applies the usual cron rule: when both day-of-month and
+// day-of-week are restricted, either one matching is enough.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
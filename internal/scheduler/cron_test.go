@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{"every minute", "* * * * *", false},
+		{"weekdays twice", "0 9,15 * * 1-5", false},
+		{"step", "*/15 * * * *", false},
+		{"sunday as seven", "0 8 * * 7", false},
+		{"descriptor", "@daily", false},
+		{"too few fields", "0 9 * *", true},
+		{"minute out of range", "60 * * * *", true},
+		{"bad range", "0 9 * * 5-1", true},
+		{"bad step", "*/0 * * * *", true},
+		{"garbage", "a b c d e", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseCron(tt.expr)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidCronExpr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expr, s.String())
+		})
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// Friday 2026-01-02 10:30 UTC
+	base := time.Date(2026, 1, 2, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{
+			name: "weekday afternoon slot same day",
+			expr: "0 9,15 * * 1-5",
+			from: base,
+			want: time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "weekday slot skips weekend",
+			expr: "0 9,15 * * 1-5",
+			from: time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC),
+			want: time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "every 15 minutes",
+			expr: "*/15 * * * *",
+			from: base,
+			want: time.Date(2026, 1, 2, 10, 45, 0, 0, time.UTC),
+		},
+		{
+			name: "first of month",
+			expr: "@monthly",
+			from: base,
+			want: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "sunday written as seven",
+			expr: "0 8 * * 7",
+			from: base,
+			want: time.Date(2026, 1, 4, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "dom or dow when both restricted",
+			expr: "0 0 15 * 1",
+			from: base,
+			want: time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "leap day",
+			expr: "0 0 29 2 *",
+			from: base,
+			want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseCron(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s.Next(tt.from))
+		})
+	}
+}
+
+func TestSchedule_NextRespectsLocation(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+
+	s, err := ParseCron("0 9 * * *")
+	require.NoError(t, err)
+
+	from := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC) // 08:00 in Shanghai
+	next := s.Next(from.In(loc))
+	assert.Equal(t, time.Date(2026, 1, 2, 1, 0, 0, 0, time.UTC), next.UTC())
+}
@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+// uplinkData is the payload of property and event messages routed by
+// iot-uplink.
+type uplinkData struct {
+	Properties map[string]json.RawMessage `json:"properties"`
+	Events     []struct {
+		Name   string          `json:"name"`
+		Params json.RawMessage `json:"params"`
+	} `json:"events"`
+}
+
+// ConditionRecorder persists the dock state read by DBConditionSource: the
+// latest wind_speed and rainfall OSD properties and every hms event.
+type ConditionRecorder struct {
+	db     *gorm.DB
+	clock  Clock
+	logger *logrus.Entry
+}
+
+// NewConditionRecorder creates a ConditionRecorder.
+func NewConditionRecorder(db *gorm.DB, clock Clock, logger *logrus.Entry) *ConditionRecorder {
+	if clock == nil {
+		clock = RealClock{}
+	}
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &ConditionRecorder{
+		db:     db,
+		clock:  clock,
+		logger: logger.WithField("component", "condition-recorder"),
+	}
+}
+
+// HandleUplinkProperty records the wind_speed and rainfall properties of a
+// property message. It matches the rabbitmq.MessageHandler signature so it
+// can consume iot.api.property directly.
+func (r *ConditionRecorder) HandleUplinkProperty(ctx context.Context, msg *rabbitmq.StandardMessage) error {
+	if msg == nil {
+		return nil
+	}
+	var data uplinkData
+	if err := msg.GetData(&data); err != nil {
+		return fmt.Errorf("failed to decode property message: %w", err)
+	}
+
+	var props []models.DeviceProperty
+	for _, key := range []string{PropertyWindSpeed, PropertyRainfall} {
+		if value, ok := data.Properties[key]; ok && string(value) != "null" {
+			props = append(props, models.DeviceProperty{PropertyKey: key, PropertyValue: datatypes.JSON(value)})
+		}
+	}
+	if len(props) == 0 {
+		return nil
+	}
+
+	deviceID, ok, err := r.deviceID(ctx, msg.DeviceSN)
+	if err != nil || !ok {
+		return err
+	}
+	now := r.clock.Now()
+	for i := range props {
+		props[i].DeviceID = deviceID
+		props[i].UpdatedAt = now
+	}
+	err = r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "device_id"}, {Name: "property_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"property_value", "updated_at"}),
+	}).Create(&props).Error
+	if err != nil {
+		return fmt.Errorf("failed to save dock properties: %w", err)
+	}
+	return nil
+}
+
+// HandleUplinkEvent records the hms events of an event message. Other events
+// are ignored. It matches the rabbitmq.MessageHandler signature so it can
+// consume iot.api.event directly.
+func (r *ConditionRecorder) HandleUplinkEvent(ctx context.Context, msg *rabbitmq.StandardMessage) error {
+	if msg == nil {
+		return nil
+	}
+	var data uplinkData
+	if err := msg.GetData(&data); err != nil {
+		return fmt.Errorf("failed to decode event message: %w", err)
+	}
+
+	var events []models.DeviceEvent
+	for _, event := range data.Events {
+		if event.Name == EventHMS && len(event.Params) > 0 {
+			events = append(events, models.DeviceEvent{EventKey: EventHMS, EventData: datatypes.JSON(event.Params)})
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	deviceID, ok, err := r.deviceID(ctx, msg.DeviceSN)
+	if err != nil || !ok {
+		return err
+	}
+	at := r.clock.Now()
+	for i := range events {
+		events[i].DeviceID = deviceID
+		events[i].Timestamp = at
+	}
+	if err := r.db.WithContext(ctx).Create(&events).Error; err != nil {
+		return fmt.Errorf("failed to save dock events: %w", err)
+	}
+	return nil
+}
+
+// deviceID returns the ID of the registered device with the serial number,
+// reporting false for unknown devices.
+func (r *ConditionRecorder) deviceID(ctx context.Context, deviceSN string) (uint, bool, error) {
+	var device models.Device
+	err := r.db.WithContext(ctx).Select("id").Where("device_sn = ?", deviceSN).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		r.logger.WithField("device_sn", deviceSN).Debug("Ignoring conditions of unknown device")
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get device: %w", err)
+	}
+	return device.ID, true, nil
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dji "github.com/utmos/utmos/pkg/adapter/dji"
+	djiuplink "github.com/utmos/utmos/pkg/adapter/dji/uplink"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+// uplinkMessage runs a raw DJI message through the DJI uplink processor and
+// wraps the result the way iot-uplink routes it to iot-api.
+func uplinkMessage(t *testing.T, deviceSN, action, method, payload string) *rabbitmq.StandardMessage {
+	t.Helper()
+	raw, err := rabbitmq.NewStandardMessage("iot-gateway", action, deviceSN, nil)
+	require.NoError(t, err)
+	raw.Data = []byte(payload)
+	raw.ProtocolMeta = &rabbitmq.ProtocolMeta{Vendor: dji.VendorDJI, Method: method}
+
+	processed, err := djiuplink.NewProcessorAdapter(nil).Process(context.Background(), raw)
+	require.NoError(t, err)
+	msg, err := rabbitmq.NewStandardMessage("iot-uplink", action, deviceSN, map[string]any{
+		"properties": processed.Properties,
+		"events":     processed.Events,
+	})
+	require.NoError(t, err)
+	msg.ProtocolMeta = &rabbitmq.ProtocolMeta{Vendor: processed.Vendor, Method: method}
+	return msg
+}
+
+func TestConditionRecorder_FeedsScheduler(t *testing.T) {
+	db := setupTestDB(t)
+	start := time.Date(2026, 1, 2, 8, 56, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	require.NoError(t, db.Create(&models.Device{DeviceSN: "DOCK1", DeviceName: "dock", DeviceType: "dock"}).Error)
+
+	recorder := NewConditionRecorder(db, clock, nil)
+	ctx := context.Background()
+	require.NoError(t, recorder.HandleUplinkProperty(ctx, uplinkMessage(t, "DOCK1", "property.report", "",
+		`{"tid":"t1","timestamp":1,"data":{"wind_speed":4.2,"rainfall":0,"mode_code":0}}`)))
+	require.NoError(t, recorder.HandleUplinkProperty(ctx, uplinkMessage(t, "DOCK1", "property.report", "",
+		`{"tid":"t2","timestamp":2,"data":{"wind_speed":14.5}}`)))
+	require.NoError(t, recorder.HandleUplinkEvent(ctx, uplinkMessage(t, "DOCK1", "event.report", EventHMS,
+		`{"tid":"t3","method":"hms","data":{"list":[{"code":"0x16100001","level":1,"module":3}]}}`)))
+	// Unknown devices are ignored
+	require.NoError(t, recorder.HandleUplinkProperty(ctx, uplinkMessage(t, "OTHER", "property.report", "",
+		`{"data":{"wind_speed":1}}`)))
+
+	cond, err := NewDBConditionSource(db, clock, 10*time.Minute).DockConditions(ctx, "DOCK1")
+	require.NoError(t, err)
+	require.NotNil(t, cond.WindSpeed)
+	assert.InDelta(t, 14.5, *cond.WindSpeed, 0.001)
+	require.NotNil(t, cond.Rainfall)
+	assert.Equal(t, 0, *cond.Rainfall)
+	require.Len(t, cond.HMS, 1)
+	assert.Equal(t, "0x16100001", cond.HMS[0].Code)
+
+	disp := &recordingDispatcher{}
+	s := NewScheduler(nil, db, disp, nil, clock, nil)
+	createTask(t, db, &models.FlightTask{Name: "patrol", DockSN: "DOCK1", CronExpr: "0 9 * * *"}, start)
+	require.NoError(t, s.Tick(ctx))
+	assert.Empty(t, disp.calls)
+
+	var run models.FlightTaskRun
+	require.NoError(t, db.First(&run).Error)
+	assert.Equal(t, models.FlightTaskRunStatusSkipped, run.Status)
+	assert.Contains(t, run.Reason, "wind speed 14.5")
+}
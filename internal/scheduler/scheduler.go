@@ -0,0 +1,532 @@
+// Package scheduler runs one-shot and recurring wayline flight tasks on docks.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/utmos/utmos/internal/downlink/dispatcher"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/wayline"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// MethodFlightTaskPrepare is the DJI service used to hand a timed task to a dock.
+const MethodFlightTaskPrepare = "flighttask_prepare"
+
+// defaultRthAltitude is used when a task does not set its own return-to-home altitude.
+const defaultRthAltitude = 100
+
+// defaultBatteryCapacity is the battery percentage conditional tasks wait
+// for when they do not set their own.
+const defaultBatteryCapacity = 90
+
+// ErrTaskNotSchedulable is returned when a task has neither a cron expression nor an execute time.
+var ErrTaskNotSchedulable = errors.New("task has neither cron_expr nor execute_time")
+
+// Config holds scheduler configuration
+type Config struct {
+	// TickInterval is how often due tasks are evaluated
+	TickInterval time.Duration
+
+	// PrepareLead is how long before execute_time the task is sent to the dock
+	PrepareLead time.Duration
+
+	// ConflictHorizon is how far ahead occurrences are compared for overlaps
+	ConflictHorizon time.Duration
+
+	// DefaultTaskDuration is assumed for tasks that do not declare a duration
+	DefaultTaskDuration time.Duration
+
+	// HMSMaxAge is how long an HMS alarm is considered active
+	HMSMaxAge time.Duration
+
+	// Thresholds are the weather and HMS limits that cause an occurrence to be skipped
+	Thresholds Thresholds
+}
+
+// DefaultConfig returns default scheduler configuration
+func DefaultConfig() *Config {
+	return &Config{
+		TickInterval:        30 * time.Second,
+		PrepareLead:         5 * time.Minute,
+		ConflictHorizon:     7 * 24 * time.Hour,
+		DefaultTaskDuration: 30 * time.Minute,
+		HMSMaxAge:           10 * time.Minute,
+		Thresholds: Thresholds{
+			MaxWindSpeed:     12,
+			MaxRainfall:      0,
+			BlockingHMSLevel: 2,
+		},
+	}
+}
+
+// Dispatcher sends service calls to devices.
+type Dispatcher interface {
+	Handle(ctx context.Context, call *dispatcher.ServiceCall) (*dispatcher.DispatchResult, error)
+}
+
+// WaylineURLs issues download addresses of stored wayline revisions.
+type WaylineURLs interface {
+	RevisionURL(ctx context.Context, waylineID uint, revision int, ttl time.Duration) (string, error)
+}
+
+// Conflict describes an occurrence of another task that overlaps the checked task.
+type Conflict struct {
+	TaskID   uint      `json:"task_id"`
+	TaskName string    `json:"task_name"`
+	At       time.Time `json:"at"`
+}
+
+// Scheduler turns FlightTask rows into flighttask_prepare calls at the right time.
+type Scheduler struct {
+	config     *Config
+	db         *gorm.DB
+	dispatcher Dispatcher
+	conditions ConditionSource
+	urls       WaylineURLs
+	clock      Clock
+	logger     *logrus.Entry
+
+	mu       sync.Mutex
+	running  bool
+	cancelFn context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewScheduler creates a new scheduler. A nil clock uses the wall clock and a
+// nil condition source reads dock state from the database.
+func NewScheduler(config *Config, db *gorm.DB, dispatch Dispatcher, conditions ConditionSource, clock Clock, logger *logrus.Entry) *Scheduler {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	if clock == nil {
+		clock = RealClock{}
+	}
+	if conditions == nil && db != nil {
+		conditions = NewDBConditionSource(db, clock, config.HMSMaxAge)
+	}
+
+	return &Scheduler{
+		config:     config,
+		db:         db,
+		dispatcher: dispatch,
+		conditions: conditions,
+		clock:      clock,
+		logger:     logger.WithField("component", "scheduler"),
+	}
+}
+
+// SetWaylineURLs sets the source of download addresses for tasks flying a
+// stored wayline. Their archive is signed when each occurrence is dispatched.
+func (s *Scheduler) SetWaylineURLs(urls WaylineURLs) {
+	s.urls = urls
+}
+
+// Start starts the background tick loop
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.cancelFn = cancel
+	s.running = true
+
+	s.wg.Add(1)
+	go s.run(ctx)
+
+	s.logger.Info("Flight task scheduler started")
+	return nil
+}
+
+// Stop stops the background tick loop
+func (s *Scheduler) Stop() error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = false
+	s.cancelFn()
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.logger.Info("Flight task scheduler stopped")
+	return nil
+}
+
+// IsRunning returns whether the scheduler loop is running
+func (s *Scheduler) IsRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+func (s *Scheduler) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.TickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Tick(ctx); err != nil {
+				s.logger.WithError(err).Warn("Scheduler tick failed")
+			}
+		}
+	}
+}
+
+// Tick processes every enabled task whose next occurrence falls within PrepareLead.
+func (s *Scheduler) Tick(ctx context.Context) error {
+	now := s.clock.Now()
+
+	var tasks []models.FlightTask
+	if err := s.db.WithContext(ctx).
+		Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now.Add(s.config.PrepareLead)).
+		Order("next_run_at ASC").
+		Find(&tasks).Error; err != nil {
+		return fmt.Errorf("failed to load due tasks: %w", err)
+	}
+
+	for i := range tasks {
+		if err := s.processTask(ctx, &tasks[i], now); err != nil {
+			s.logger.WithError(err).WithField("task_id", tasks[i].ID).Error("Failed to process flight task")
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) processTask(ctx context.Context, task *models.FlightTask, now time.Time) error {
+	at := *task.NextRunAt
+	next, err := NextRun(task, at)
+	if err != nil {
+		return err
+	}
+
+	claimed, err := s.claim(ctx, task, at, next)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		s.logger.WithFields(logrus.Fields{
+			"task_id": task.ID,
+			"at":      at,
+		}).Debug("Flight task occurrence already claimed")
+		return nil
+	}
+
+	run := &models.FlightTaskRun{
+		TaskID:             task.ID,
+		DockSN:             task.DockSN,
+		ScheduledAt:        at,
+		WaylineRevision:    task.WaylineRevision,
+		WaylineFingerprint: task.WaylineFingerprint,
+	}
+
+	if reason := s.skipReason(ctx, task, at, now); reason != "" {
+		run.Status = models.FlightTaskRunStatusSkipped
+		run.Reason = reason
+		s.logger.WithFields(logrus.Fields{
+			"task_id": task.ID,
+			"dock_sn": task.DockSN,
+			"at":      at,
+		}).Infof("Skipping flight task occurrence: %s", reason)
+	} else {
+		run.FlightID = uuid.New().String()
+		if err := s.dispatch(ctx, task, at, run.FlightID); err != nil {
+			run.Status = models.FlightTaskRunStatusFailed
+			run.Reason = err.Error()
+		} else {
+			run.Status = models.FlightTaskRunStatusDispatched
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Create(run).Error; err != nil {
+		return fmt.Errorf("failed to record run: %w", err)
+	}
+	return nil
+}
+
+// claim advances the task past the occurrence at, reporting whether this
+// scheduler won it. Every replica runs the scheduler, so the update only
+// applies while next_run_at still equals at; on PostgreSQL the row is also
+// locked with SKIP LOCKED so concurrent replicas pass over it instead of
+// waiting.
+func (s *Scheduler) claim(ctx context.Context, task *models.FlightTask, at time.Time, next *time.Time) (bool, error) {
+	claimed := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			var ids []uint
+			if err := tx.Model(&models.FlightTask{}).
+				Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("id = ? AND next_run_at = ?", task.ID, at).
+				Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				return nil
+			}
+		}
+
+		result := tx.Model(&models.FlightTask{}).
+			Where("id = ? AND next_run_at = ?", task.ID, at).
+			Updates(map[string]any{
+				"last_run_at": at,
+				"next_run_at": next,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		claimed = result.RowsAffected == 1
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to claim flight task: %w", err)
+	}
+	return claimed, nil
+}
+
+func (s *Scheduler) skipReason(ctx context.Context, task *models.FlightTask, at, now time.Time) string {
+	if at.Before(now) {
+		return "missed: execute time already passed"
+	}
+
+	var windows []models.BlackoutWindow
+	if err := s.db.WithContext(ctx).
+		Where("(dock_sn = '' OR dock_sn = ?) AND start_at <= ? AND end_at > ?", task.DockSN, at, at).
+		Find(&windows).Error; err != nil {
+		return "blackout check failed: " + err.Error()
+	}
+	if len(windows) > 0 {
+		reason := "blackout window"
+		if windows[0].Reason != "" {
+			reason += ": " + windows[0].Reason
+		}
+		return reason
+	}
+
+	if s.conditions == nil {
+		return ""
+	}
+	cond, err := s.conditions.DockConditions(ctx, task.DockSN)
+	if err != nil {
+		return "condition check failed: " + err.Error()
+	}
+	return s.config.Thresholds.SkipReason(cond)
+}
+
+func (s *Scheduler) dispatch(ctx context.Context, task *models.FlightTask, at time.Time, flightID string) error {
+	if s.dispatcher == nil {
+		return errors.New("no dispatcher configured")
+	}
+
+	duration := s.taskDuration(task)
+	if task.WaylineID != nil {
+		signed, err := s.signWayline(ctx, task, s.config.PrepareLead+duration)
+		if err != nil {
+			return err
+		}
+		task = signed
+	}
+
+	data := BuildPrepareData(task, at, flightID, duration)
+	params, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal prepare data: %w", err)
+	}
+
+	call := dispatcher.NewServiceCall(task.DockSN, task.Vendor, MethodFlightTaskPrepare, params)
+	call.ID = flightID
+	// The call is rejected when the dock has left the task's workspace
+	call.WorkspaceID = task.WorkspaceID
+	if _, err := s.dispatcher.Handle(ctx, call); err != nil {
+		return fmt.Errorf("failed to dispatch flight task: %w", err)
+	}
+	return nil
+}
+
+// signWayline returns a copy of a task flying a stored wayline whose
+// WaylineURL is a fresh download address of the referenced revision.
+func (s *Scheduler) signWayline(ctx context.Context, task *models.FlightTask, ttl time.Duration) (*models.FlightTask, error) {
+	if s.urls == nil {
+		return nil, errors.New("no wayline storage configured")
+	}
+	if task.WaylineRevision == nil {
+		return nil, errors.New("task references no wayline revision")
+	}
+	url, err := s.urls.RevisionURL(ctx, *task.WaylineID, *task.WaylineRevision, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign wayline: %w", err)
+	}
+	signed := *task
+	signed.WaylineURL = url
+	return &signed, nil
+}
+
+func (s *Scheduler) taskDuration(task *models.FlightTask) time.Duration {
+	if task.DurationSeconds > 0 {
+		return time.Duration(task.DurationSeconds) * time.Second
+	}
+	return s.config.DefaultTaskDuration
+}
+
+// BuildPrepareData builds the flighttask_prepare payload for one occurrence.
+func BuildPrepareData(task *models.FlightTask, at time.Time, flightID string, duration time.Duration) wayline.PrepareData {
+	rth := defaultRthAltitude
+	if task.RthAltitude != nil {
+		rth = *task.RthAltitude
+	}
+
+	data := wayline.PrepareData{
+		FlightID:           flightID,
+		ExecuteTime:        at.UnixMilli(),
+		TaskType:           int(task.TaskType),
+		File:               wayline.File{URL: task.WaylineURL, Fingerprint: task.WaylineFingerprint},
+		RthAltitude:        rth,
+		OutOfControlAction: task.OutOfControlAction,
+	}
+	if task.TaskType == models.FlightTaskTypeConditional {
+		battery := defaultBatteryCapacity
+		if task.BatteryCapacity != nil {
+			battery = *task.BatteryCapacity
+		}
+		data.ReadyConditions = &wayline.ReadyConditions{
+			BatteryCapacity: battery,
+			BeginTime:       at.UnixMilli(),
+			EndTime:         at.Add(duration).UnixMilli(),
+		}
+	}
+	return data
+}
+
+// NextRun returns the first occurrence of task strictly after t, or nil when
+// the task has no further occurrences.
+func NextRun(task *models.FlightTask, t time.Time) (*time.Time, error) {
+	if task.IsRecurring() {
+		sched, err := ParseCron(task.CronExpr)
+		if err != nil {
+			return nil, err
+		}
+		loc, err := taskLocation(task)
+		if err != nil {
+			return nil, err
+		}
+		next := sched.Next(t.In(loc))
+		if next.IsZero() {
+			return nil, nil
+		}
+		next = next.UTC()
+		return &next, nil
+	}
+
+	if task.ExecuteTime == nil {
+		return nil, ErrTaskNotSchedulable
+	}
+	if task.ExecuteTime.After(t) {
+		next := task.ExecuteTime.UTC()
+		return &next, nil
+	}
+	return nil, nil
+}
+
+// Occurrences lists the occurrences of task in [from, to).
+func Occurrences(task *models.FlightTask, from, to time.Time) ([]time.Time, error) {
+	var out []time.Time
+	cursor := from.Add(-time.Nanosecond)
+	for {
+		next, err := NextRun(task, cursor)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil || !next.Before(to) {
+			return out, nil
+		}
+		out = append(out, *next)
+		if !task.IsRecurring() {
+			return out, nil
+		}
+		cursor = *next
+	}
+}
+
+// FindConflicts returns occurrences of other enabled tasks on the same dock
+// that overlap task within the configured horizon.
+func (s *Scheduler) FindConflicts(ctx context.Context, task *models.FlightTask) ([]Conflict, error) {
+	now := s.clock.Now()
+	to := now.Add(s.config.ConflictHorizon)
+
+	mine, err := Occurrences(task, now, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(mine) == 0 {
+		return nil, nil
+	}
+
+	query := s.db.WithContext(ctx).Where("dock_sn = ? AND enabled = ?", task.DockSN, true)
+	if task.ID != 0 {
+		query = query.Where("id <> ?", task.ID)
+	}
+	var others []models.FlightTask
+	if err := query.Find(&others).Error; err != nil {
+		return nil, fmt.Errorf("failed to load dock tasks: %w", err)
+	}
+
+	myDuration := s.taskDuration(task)
+	var conflicts []Conflict
+	for i := range others {
+		other := &others[i]
+		theirs, err := Occurrences(other, now.Add(-s.taskDuration(other)), to)
+		if err != nil {
+			s.logger.WithError(err).WithField("task_id", other.ID).Warn("Skipping task with invalid schedule")
+			continue
+		}
+		otherDuration := s.taskDuration(other)
+	occurrences:
+		for _, a := range mine {
+			for _, b := range theirs {
+				if a.Before(b.Add(otherDuration)) && b.Before(a.Add(myDuration)) {
+					// One conflict per task is enough to reject the schedule.
+					conflicts = append(conflicts, Conflict{TaskID: other.ID, TaskName: other.Name, At: b})
+					break occurrences
+				}
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// Now returns the scheduler's current time.
+func (s *Scheduler) Now() time.Time {
+	return s.clock.Now()
+}
+
+func taskLocation(task *models.FlightTask) (*time.Location, error) {
+	if task.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(task.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", task.Timezone, err)
+	}
+	return loc, nil
+}
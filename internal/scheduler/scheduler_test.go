@@ -0,0 +1,417 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/downlink/dispatcher"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/aircraft"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/wayline"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+type recordingDispatcher struct {
+	mu    sync.Mutex
+	calls []*dispatcher.ServiceCall
+	err   error
+}
+
+func (d *recordingDispatcher) Handle(_ context.Context, call *dispatcher.ServiceCall) (*dispatcher.DispatchResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return nil, d.err
+	}
+	d.calls = append(d.calls, call)
+	return &dispatcher.DispatchResult{Success: true, MessageID: call.ID}, nil
+}
+
+type staticConditions struct {
+	cond *DockConditions
+}
+
+func (s staticConditions) DockConditions(context.Context, string) (*DockConditions, error) {
+	return s.cond, nil
+}
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, models.AutoMigrate(db))
+	return db
+}
+
+func createTask(t *testing.T, db *gorm.DB, task *models.FlightTask, now time.Time) *models.FlightTask {
+	if task.Vendor == "" {
+		task.Vendor = "dji"
+	}
+	if task.TaskType == 0 {
+		task.TaskType = models.FlightTaskTypeTimed
+	}
+	task.Enabled = true
+	next, err := NextRun(task, now)
+	require.NoError(t, err)
+	task.NextRunAt = next
+	require.NoError(t, db.Create(task).Error)
+	return task
+}
+
+func TestScheduler_TickDispatchesRecurringTask(t *testing.T) {
+	db := setupTestDB(t)
+	start := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC) // Friday
+	clock := NewFakeClock(start)
+	disp := &recordingDispatcher{}
+	s := NewScheduler(nil, db, disp, staticConditions{}, clock, nil)
+
+	revision := 2
+	task := createTask(t, db, &models.FlightTask{
+		Name:               "patrol",
+		DockSN:             "DOCK1",
+		CronExpr:           "0 9,15 * * 1-5",
+		WaylineURL:         "https://example.com/a.kmz",
+		WaylineFingerprint: "abc123",
+		WaylineRevision:    &revision,
+	}, start)
+	require.Equal(t, time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC), *task.NextRunAt)
+
+	// Not yet within PrepareLead.
+	require.NoError(t, s.Tick(context.Background()))
+	assert.Empty(t, disp.calls)
+
+	clock.Set(time.Date(2026, 1, 2, 8, 56, 0, 0, time.UTC))
+	require.NoError(t, s.Tick(context.Background()))
+	require.Len(t, disp.calls, 1)
+
+	call := disp.calls[0]
+	assert.Equal(t, "DOCK1", call.DeviceSN)
+	assert.Equal(t, MethodFlightTaskPrepare, call.Method)
+
+	var data wayline.PrepareData
+	require.NoError(t, json.Unmarshal(call.Params, &data))
+	assert.Equal(t, int(models.FlightTaskTypeTimed), data.TaskType)
+	assert.Equal(t, time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC).UnixMilli(), data.ExecuteTime)
+	assert.Equal(t, "https://example.com/a.kmz", data.File.URL)
+	assert.Equal(t, call.ID, data.FlightID)
+
+	var reloaded models.FlightTask
+	require.NoError(t, db.First(&reloaded, task.ID).Error)
+	assert.Equal(t, time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC), reloaded.NextRunAt.UTC())
+
+	var runs []models.FlightTaskRun
+	require.NoError(t, db.Find(&runs).Error)
+	require.Len(t, runs, 1)
+	assert.Equal(t, models.FlightTaskRunStatusDispatched, runs[0].Status)
+	assert.Equal(t, call.ID, runs[0].FlightID)
+	assert.Equal(t, "abc123", runs[0].WaylineFingerprint)
+	require.NotNil(t, runs[0].WaylineRevision)
+	assert.Equal(t, 2, *runs[0].WaylineRevision)
+
+	// Ticking again in the same window does not dispatch twice.
+	require.NoError(t, s.Tick(context.Background()))
+	assert.Len(t, disp.calls, 1)
+}
+
+func TestScheduler_OneShotTaskRunsOnce(t *testing.T) {
+	db := setupTestDB(t)
+	start := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	disp := &recordingDispatcher{}
+	s := NewScheduler(nil, db, disp, staticConditions{}, clock, nil)
+
+	at := start.Add(time.Hour)
+	task := createTask(t, db, &models.FlightTask{Name: "once", DockSN: "DOCK1", ExecuteTime: &at}, start)
+
+	clock.Set(at.Add(-time.Minute))
+	require.NoError(t, s.Tick(context.Background()))
+	require.Len(t, disp.calls, 1)
+
+	var reloaded models.FlightTask
+	require.NoError(t, db.First(&reloaded, task.ID).Error)
+	assert.Nil(t, reloaded.NextRunAt)
+	require.NotNil(t, reloaded.LastRunAt)
+
+	clock.Advance(time.Hour)
+	require.NoError(t, s.Tick(context.Background()))
+	assert.Len(t, disp.calls, 1)
+}
+
+func TestScheduler_ReplicasClaimOccurrenceOnce(t *testing.T) {
+	db := setupTestDB(t)
+	start := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	disp := &recordingDispatcher{}
+	first := NewScheduler(nil, db, disp, staticConditions{}, clock, nil)
+	second := NewScheduler(nil, db, disp, staticConditions{}, clock, nil)
+
+	task := createTask(t, db, &models.FlightTask{Name: "patrol", DockSN: "DOCK1", CronExpr: "0 9 * * *"}, start)
+	clock.Set(time.Date(2026, 1, 2, 8, 56, 0, 0, time.UTC))
+
+	// Both replicas loaded the same due row before either processed it.
+	var loaded [2]models.FlightTask
+	for i := range loaded {
+		require.NoError(t, db.First(&loaded[i], task.ID).Error)
+	}
+	require.NoError(t, first.processTask(context.Background(), &loaded[0], clock.Now()))
+	require.NoError(t, second.processTask(context.Background(), &loaded[1], clock.Now()))
+
+	assert.Len(t, disp.calls, 1)
+	var runs int64
+	require.NoError(t, db.Model(&models.FlightTaskRun{}).Count(&runs).Error)
+	assert.Equal(t, int64(1), runs)
+}
+
+func TestScheduler_SkipReasons(t *testing.T) {
+	start := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	at := start.Add(2 * time.Minute)
+	wind := 15.0
+	rain := 2
+
+	tests := []struct {
+		name       string
+		conditions *DockConditions
+		blackout   *models.BlackoutWindow
+		clockAt    time.Time
+		wantReason string
+	}{
+		{
+			name:       "high wind",
+			conditions: &DockConditions{WindSpeed: &wind},
+			clockAt:    start,
+			wantReason: "wind speed",
+		},
+		{
+			name:       "rain",
+			conditions: &DockConditions{Rainfall: &rain},
+			clockAt:    start,
+			wantReason: "rainfall",
+		},
+		{
+			name:       "blocking hms",
+			conditions: &DockConditions{HMS: []aircraft.HMSItem{{Level: 2, Code: "0x16100083"}}},
+			clockAt:    start,
+			wantReason: "HMS",
+		},
+		{
+			name:       "blackout for all docks",
+			blackout:   &models.BlackoutWindow{StartAt: start, EndAt: start.Add(time.Hour), Reason: "airshow"},
+			clockAt:    start,
+			wantReason: "blackout window: airshow",
+		},
+		{
+			name:       "missed occurrence",
+			clockAt:    at.Add(time.Minute),
+			wantReason: "missed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			clock := NewFakeClock(start)
+			disp := &recordingDispatcher{}
+			s := NewScheduler(nil, db, disp, staticConditions{cond: tt.conditions}, clock, nil)
+
+			createTask(t, db, &models.FlightTask{Name: "t", DockSN: "DOCK1", ExecuteTime: &at}, start)
+			if tt.blackout != nil {
+				require.NoError(t, db.Create(tt.blackout).Error)
+			}
+
+			clock.Set(tt.clockAt)
+			require.NoError(t, s.Tick(context.Background()))
+			assert.Empty(t, disp.calls)
+
+			var run models.FlightTaskRun
+			require.NoError(t, db.First(&run).Error)
+			assert.Equal(t, models.FlightTaskRunStatusSkipped, run.Status)
+			assert.Contains(t, run.Reason, tt.wantReason)
+		})
+	}
+}
+
+type signedURLs struct {
+	ttl time.Duration
+}
+
+func (u *signedURLs) RevisionURL(_ context.Context, waylineID uint, revision int, ttl time.Duration) (string, error) {
+	u.ttl = ttl
+	return fmt.Sprintf("https://files.example.com/waylines/%d/%d.kmz?signature=x", waylineID, revision), nil
+}
+
+func TestScheduler_SignsStoredWayline(t *testing.T) {
+	db := setupTestDB(t)
+	start := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	disp := &recordingDispatcher{}
+	s := NewScheduler(nil, db, disp, staticConditions{}, NewFakeClock(start), nil)
+
+	at := start.Add(time.Minute)
+	waylineID, revision := uint(7), 3
+	task := createTask(t, db, &models.FlightTask{Name: "stored", DockSN: "DOCK1", ExecuteTime: &at, DurationSeconds: 600, WaylineID: &waylineID, WaylineRevision: &revision}, start)
+
+	// Without wayline storage the occurrence fails
+	require.NoError(t, s.Tick(context.Background()))
+	assert.Empty(t, disp.calls)
+	var run models.FlightTaskRun
+	require.NoError(t, db.First(&run).Error)
+	assert.Equal(t, models.FlightTaskRunStatusFailed, run.Status)
+
+	urls := &signedURLs{}
+	s.SetWaylineURLs(urls)
+	next := start.Add(2 * time.Minute)
+	require.NoError(t, db.Model(task).Update("next_run_at", next).Error)
+	require.NoError(t, s.Tick(context.Background()))
+	require.Len(t, disp.calls, 1)
+
+	var data wayline.PrepareData
+	require.NoError(t, json.Unmarshal(disp.calls[0].Params, &data))
+	assert.Equal(t, "https://files.example.com/waylines/7/3.kmz?signature=x", data.File.URL)
+	assert.Equal(t, DefaultConfig().PrepareLead+10*time.Minute, urls.ttl)
+}
+
+func TestBuildPrepareData_ReadyConditions(t *testing.T) {
+	at := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+	task := &models.FlightTask{TaskType: models.FlightTaskTypeConditional}
+
+	data := BuildPrepareData(task, at, "flight-1", 30*time.Minute)
+	require.NotNil(t, data.ReadyConditions)
+	assert.Equal(t, defaultBatteryCapacity, data.ReadyConditions.BatteryCapacity)
+	assert.Equal(t, at.Add(30*time.Minute).UnixMilli(), data.ReadyConditions.EndTime)
+
+	battery := 60
+	task.BatteryCapacity = &battery
+	assert.Equal(t, 60, BuildPrepareData(task, at, "flight-1", time.Hour).ReadyConditions.BatteryCapacity)
+
+	task.TaskType = models.FlightTaskTypeTimed
+	assert.Nil(t, BuildPrepareData(task, at, "flight-1", time.Hour).ReadyConditions)
+}
+
+func TestScheduler_DispatchFailureRecorded(t *testing.T) {
+	db := setupTestDB(t)
+	start := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	disp := &recordingDispatcher{err: errors.New("broker down")}
+	s := NewScheduler(nil, db, disp, staticConditions{}, NewFakeClock(start), nil)
+
+	at := start.Add(time.Minute)
+	createTask(t, db, &models.FlightTask{Name: "t", DockSN: "DOCK1", ExecuteTime: &at}, start)
+
+	require.NoError(t, s.Tick(context.Background()))
+
+	var run models.FlightTaskRun
+	require.NoError(t, db.First(&run).Error)
+	assert.Equal(t, models.FlightTaskRunStatusFailed, run.Status)
+	assert.Contains(t, run.Reason, "broker down")
+}
+
+func TestScheduler_DispatchesForTaskWorkspace(t *testing.T) {
+	db := setupTestDB(t)
+	start := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	disp := &recordingDispatcher{}
+	s := NewScheduler(nil, db, disp, staticConditions{}, NewFakeClock(start), nil)
+
+	at := start.Add(time.Minute)
+	createTask(t, db, &models.FlightTask{Name: "t", DockSN: "DOCK1", WorkspaceID: "ws1", ExecuteTime: &at}, start)
+
+	require.NoError(t, s.Tick(context.Background()))
+	require.Len(t, disp.calls, 1)
+	assert.Equal(t, "ws1", disp.calls[0].WorkspaceID, "the dispatcher checks the dock is still in the workspace")
+}
+
+func TestScheduler_FindConflicts(t *testing.T) {
+	db := setupTestDB(t)
+	start := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	s := NewScheduler(nil, db, nil, staticConditions{}, NewFakeClock(start), nil)
+
+	existing := createTask(t, db, &models.FlightTask{
+		Name:            "daily",
+		DockSN:          "DOCK1",
+		CronExpr:        "0 9 * * *",
+		DurationSeconds: 1800,
+	}, start)
+
+	overlapping := time.Date(2026, 1, 3, 9, 15, 0, 0, time.UTC)
+	conflicts, err := s.FindConflicts(context.Background(), &models.FlightTask{
+		DockSN:      "DOCK1",
+		ExecuteTime: &overlapping,
+	})
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, existing.ID, conflicts[0].TaskID)
+
+	free := time.Date(2026, 1, 3, 9, 30, 0, 0, time.UTC)
+	conflicts, err = s.FindConflicts(context.Background(), &models.FlightTask{
+		DockSN:          "DOCK1",
+		ExecuteTime:     &free,
+		DurationSeconds: 600,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	otherDock, err := s.FindConflicts(context.Background(), &models.FlightTask{
+		DockSN:      "DOCK2",
+		ExecuteTime: &overlapping,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, otherDock)
+}
+
+func TestDBConditionSource(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+
+	dock := &models.Device{DeviceSN: "DOCK1", DeviceName: "dock", DeviceType: "dock"}
+	require.NoError(t, db.Create(dock).Error)
+	require.NoError(t, db.Create(&models.DeviceProperty{
+		DeviceID: dock.ID, PropertyKey: PropertyWindSpeed, PropertyValue: datatypes.JSON(`8.5`),
+	}).Error)
+	require.NoError(t, db.Create(&models.DeviceProperty{
+		DeviceID: dock.ID, PropertyKey: PropertyRainfall, PropertyValue: datatypes.JSON(`1`),
+	}).Error)
+	require.NoError(t, db.Create(&models.DeviceEvent{
+		DeviceID:  dock.ID,
+		EventKey:  EventHMS,
+		EventData: datatypes.JSON(`{"list":[{"level":2,"code":"0x1"}]}`),
+		Timestamp: now.Add(-time.Minute),
+	}).Error)
+
+	src := NewDBConditionSource(db, NewFakeClock(now), 10*time.Minute)
+	cond, err := src.DockConditions(context.Background(), "DOCK1")
+	require.NoError(t, err)
+	require.NotNil(t, cond.WindSpeed)
+	assert.InDelta(t, 8.5, *cond.WindSpeed, 0.001)
+	require.NotNil(t, cond.Rainfall)
+	assert.Equal(t, 1, *cond.Rainfall)
+	require.Len(t, cond.HMS, 1)
+
+	// HMS alarms older than the max age are treated as cleared.
+	src = NewDBConditionSource(db, NewFakeClock(now.Add(time.Hour)), 10*time.Minute)
+	cond, err = src.DockConditions(context.Background(), "DOCK1")
+	require.NoError(t, err)
+	assert.Empty(t, cond.HMS)
+
+	cond, err = src.DockConditions(context.Background(), "UNKNOWN")
+	require.NoError(t, err)
+	assert.Nil(t, cond.WindSpeed)
+}
+
+func TestScheduler_StartStop(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := DefaultConfig()
+	cfg.TickInterval = 10 * time.Millisecond
+	s := NewScheduler(cfg, db, nil, staticConditions{}, nil, nil)
+
+	require.NoError(t, s.Start(context.Background()))
+	assert.True(t, s.IsRunning())
+	time.Sleep(30 * time.Millisecond)
+	require.NoError(t, s.Stop())
+	assert.False(t, s.IsRunning())
+}
@@ -0,0 +1,123 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+
+	dji "github.com/utmos/utmos/pkg/adapter/dji"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/config"
+	djirouter "github.com/utmos/utmos/pkg/adapter/dji/router"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+// RoutingKeyStatusReply is the routing key status replies are published
+// with. The DJI adapter forwards them to the gateway's status_reply topic.
+var RoutingKeyStatusReply = rabbitmq.NewRoutingKey(dji.VendorDJI, "device", dji.ActionStatusReply).String()
+
+// Publisher publishes replies to the devices.
+type Publisher interface {
+	Publish(ctx context.Context, routingKey string, msg *rabbitmq.StandardMessage) error
+}
+
+// uplinkStatusData is the payload of status messages routed by iot-uplink.
+// update_topo requests carry the gateway model and its sub-devices at the
+// top level of the properties.
+type uplinkStatusData struct {
+	Properties struct {
+		Online   *bool `json:"online"`
+		Topology *struct {
+			GatewaySN   string      `json:"gateway_sn"`
+			GatewayType string      `json:"gateway_type"`
+			SubDevices  []SubDevice `json:"sub_devices"`
+		} `json:"topology"`
+		config.UpdateTopoData
+	} `json:"properties"`
+}
+
+// statusReply is the data of a status_reply message.
+type statusReply struct {
+	Result int `json:"result"`
+}
+
+// SetReplyPublisher sets the publisher used to answer update_topo
+// requests. Requests are applied without a reply while it is nil.
+func (s *Service) SetReplyPublisher(publisher Publisher) {
+	s.publisher = publisher
+}
+
+// HandleUplinkStatus applies the device status messages routed by
+// iot-uplink. It matches the rabbitmq.MessageHandler signature so it can
+// consume iot.api.status directly.
+func (s *Service) HandleUplinkStatus(ctx context.Context, msg *rabbitmq.StandardMessage) error {
+	if msg == nil {
+		return nil
+	}
+
+	var data uplinkStatusData
+	if err := msg.GetData(&data); err != nil {
+		return fmt.Errorf("failed to decode status message: %w", err)
+	}
+
+	status := &Status{
+		GatewaySN: msg.DeviceSN,
+		Online:    data.Properties.Online == nil || *data.Properties.Online,
+	}
+	if msg.ProtocolMeta != nil {
+		status.Vendor = msg.ProtocolMeta.Vendor
+	}
+	if topology := data.Properties.Topology; topology != nil {
+		if topology.GatewaySN != "" {
+			status.GatewaySN = topology.GatewaySN
+		}
+		status.GatewayType = topology.GatewayType
+		status.SubDevices = topology.SubDevices
+	}
+
+	updateTopo := isUpdateTopo(msg)
+	if updateTopo {
+		topo := data.Properties.UpdateTopoData
+		status.GatewayModelKey = DeviceModelKey(topo.Domain, topo.Type, topo.SubType)
+		status.SubDevices = make([]SubDevice, 0, len(topo.SubDevices))
+		for _, sd := range topo.SubDevices {
+			status.SubDevices = append(status.SubDevices, SubDevice{
+				DeviceSN:       sd.SN,
+				DeviceModelKey: DeviceModelKey(sd.Domain, sd.Type, sd.SubType),
+				Online:         true,
+			})
+		}
+	}
+
+	if err := s.Update(ctx, status); err != nil {
+		return err
+	}
+	if updateTopo {
+		return s.replyStatus(ctx, msg)
+	}
+	return nil
+}
+
+func isUpdateTopo(msg *rabbitmq.StandardMessage) bool {
+	return msg.ProtocolMeta != nil &&
+		msg.ProtocolMeta.Vendor == dji.VendorDJI &&
+		msg.ProtocolMeta.Method == djirouter.MethodUpdateTopo
+}
+
+// replyStatus acknowledges an update_topo request on the gateway's
+// status_reply topic. Without the reply the gateway keeps resending it.
+func (s *Service) replyStatus(ctx context.Context, req *rabbitmq.StandardMessage) error {
+	if s.publisher == nil {
+		return nil
+	}
+	msg, err := rabbitmq.NewStandardMessageWithIDs(req.TID, req.BID, "iot-api", dji.ActionStatusReply, req.DeviceSN, statusReply{Result: 0})
+	if err != nil {
+		return fmt.Errorf("failed to create status reply: %w", err)
+	}
+	msg.ProtocolMeta = &rabbitmq.ProtocolMeta{
+		Vendor: dji.VendorDJI,
+		Method: req.ProtocolMeta.Method,
+	}
+	if err := s.publisher.Publish(ctx, RoutingKeyStatusReply, msg); err != nil {
+		return fmt.Errorf("failed to publish status reply: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,482 @@
+// Package topology persists the gateway → sub-device relations reported by
+// device status messages and serves the device topology of a workspace.
+package topology
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/models"
+)
+
+// WebSocket events pushed to the workspace, named after the DJI Pilot 2
+// biz codes.
+const (
+	EventDeviceOnline  = "device_online"
+	EventDeviceOffline = "device_offline"
+	EventUpdateTopo    = "device_update_topo"
+)
+
+// Device types assigned to devices first seen in a status report.
+const (
+	DefaultGatewayType   = "gateway"
+	DefaultSubDeviceType = "sub_device"
+)
+
+// Device types derived from the domain of a DJI device model key.
+const (
+	DeviceTypeAircraft = "aircraft"
+	DeviceTypePayload  = "payload"
+	DeviceTypeRC       = "rc"
+	DeviceTypeDock     = "dock"
+)
+
+// Notifier pushes events to the clients of a workspace.
+type Notifier interface {
+	NotifyWorkspace(ctx context.Context, workspaceID, event string, data any) error
+}
+
+// SubDevice is a device reported as connected to a gateway.
+type SubDevice struct {
+	DeviceSN       string `json:"device_sn"`
+	ProductType    string `json:"product_type,omitempty"`
+	DeviceModelKey string `json:"device_model_key,omitempty"`
+	Online         bool   `json:"online"`
+}
+
+// Status is a gateway status report with the sub-devices connected to it.
+// Model keys, when known, select the thing model of devices seen for the
+// first time.
+type Status struct {
+	GatewaySN       string
+	GatewayType     string
+	GatewayModelKey string
+	Vendor          string
+	SubDevices      []SubDevice
+	Online          bool
+}
+
+// DeviceModelKey returns the DJI device model key, domain-type-sub_type,
+// which is also the product key of the device's thing model.
+func DeviceModelKey(domain string, productType, subType int) string {
+	return fmt.Sprintf("%s-%d-%d", domain, productType, subType)
+}
+
+// DeviceType returns the device type for a DJI device model key, or "" when
+// the domain is not known.
+func DeviceType(deviceModelKey string) string {
+	domain, _, _ := strings.Cut(deviceModelKey, "-")
+	switch domain {
+	case "0":
+		return DeviceTypeAircraft
+	case "1":
+		return DeviceTypePayload
+	case "2":
+		return DeviceTypeRC
+	case "3":
+		return DeviceTypeDock
+	default:
+		return ""
+	}
+}
+
+// Map icons DJI Pilot 2 bundles for the aircraft of other pilots.
+const (
+	AircraftIconURL         = "resource://pilot/drawable/tsa_aircraft_others_normal"
+	AircraftSelectedIconURL = "resource://pilot/drawable/tsa_aircraft_others_pressed"
+)
+
+// DeviceModel identifies the model of a device by its device model key and
+// the parts of the key.
+type DeviceModel struct {
+	Key     string `json:"key"`
+	Domain  string `json:"domain"`
+	Type    string `json:"type"`
+	SubType string `json:"sub_type"`
+}
+
+// ParseDeviceModel splits a device model key into its parts. Parts are left
+// empty for keys not of the form domain-type-sub_type.
+func ParseDeviceModel(deviceModelKey string) DeviceModel {
+	model := DeviceModel{Key: deviceModelKey}
+	if parts := strings.Split(deviceModelKey, "-"); len(parts) == 3 {
+		model.Domain, model.Type, model.SubType = parts[0], parts[1], parts[2]
+	}
+	return model
+}
+
+// IconURLs are the map icons of a device. DJI Pilot 2 shows the icon of the
+// device model for empty URLs.
+type IconURLs struct {
+	NormalIconURL   string `json:"normal_icon_url"`
+	SelectedIconURL string `json:"selected_icon_url"`
+}
+
+// Device is a device of the topology list in the form DJI Pilot 2 expects.
+type Device struct {
+	SN             string      `json:"sn"`
+	DeviceCallsign string      `json:"device_callsign"`
+	DeviceModel    DeviceModel `json:"device_model"`
+	IconURLs       IconURLs    `json:"icon_urls"`
+	GatewaySN      string      `json:"gateway_sn,omitempty"`
+	OnlineStatus   bool        `json:"online_status"`
+}
+
+// DeviceEvent is the data of device_online, device_offline and
+// device_update_topo events: the device, or for topology changes the
+// gateway, in its topology form.
+type DeviceEvent struct {
+	SN   string `json:"sn"`
+	Host Device `json:"host"`
+}
+
+// Topology is a gateway (parent) with the devices connected to it (hosts).
+type Topology struct {
+	Hosts   []Device `json:"hosts"`
+	Parents []Device `json:"parents"`
+}
+
+// ToDevice converts a stored device to its topology form.
+func ToDevice(d *models.Device) Device {
+	device := Device{
+		SN:             d.DeviceSN,
+		DeviceCallsign: d.DeviceName,
+		DeviceModel:    ParseDeviceModel(d.ModelKey),
+		OnlineStatus:   d.Status == models.DeviceStatusOnline,
+	}
+	if d.GatewaySN != nil {
+		device.GatewaySN = *d.GatewaySN
+	}
+	if DeviceType(d.ModelKey) == DeviceTypeAircraft {
+		device.IconURLs = IconURLs{NormalIconURL: AircraftIconURL, SelectedIconURL: AircraftSelectedIconURL}
+	}
+	return device
+}
+
+// notification is a workspace event queued during an update and pushed once
+// the update is committed.
+type notification struct {
+	workspaceID string
+	event       string
+	data        any
+}
+
+// Service persists device topology.
+type Service struct {
+	db        *gorm.DB
+	notifier  Notifier
+	publisher Publisher
+	logger    *logrus.Entry
+	now       func() time.Time
+}
+
+// NewService creates a new topology service. notifier may be nil, in which
+// case changes are stored without being pushed.
+func NewService(db *gorm.DB, notifier Notifier, logger *logrus.Entry) *Service {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Service{
+		db:       db,
+		notifier: notifier,
+		logger:   logger.WithField("component", "topology"),
+		now:      time.Now,
+	}
+}
+
+// Update applies a gateway status report: the gateway and its sub-devices
+// are created on first sight, sub-devices are attached to the gateway and
+// inherit its workspace, and sub-devices no longer reported are marked
+// offline. A sub-device reported by a new gateway, such as an aircraft
+// moved to another dock, is detached from its previous one and follows the
+// new gateway into its workspace. Online, offline and topology changes are
+// pushed to the workspaces concerned.
+func (s *Service) Update(ctx context.Context, status *Status) error {
+	if status.GatewaySN == "" {
+		return fmt.Errorf("status report without gateway serial number")
+	}
+
+	var notifications []notification
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		notifications = nil
+
+		gatewayType := deviceType(status.GatewayType, status.GatewayModelKey, DefaultGatewayType)
+		gateway, created, err := s.loadOrCreate(tx, status.GatewaySN, gatewayType, status.GatewayModelKey, status.Vendor)
+		if err != nil {
+			return err
+		}
+		if changed := s.setStatus(gateway, status.Online); changed || created {
+			notifications = append(notifications, statusNotification(gateway))
+		}
+		if err := tx.Save(gateway).Error; err != nil {
+			return fmt.Errorf("failed to update gateway: %w", err)
+		}
+
+		topologyChanged := false
+		reported := make([]string, 0, len(status.SubDevices))
+		for _, sd := range status.SubDevices {
+			if sd.DeviceSN == "" || sd.DeviceSN == gateway.DeviceSN {
+				continue
+			}
+			reported = append(reported, sd.DeviceSN)
+
+			subNotifications, changed, err := s.updateSubDevice(tx, gateway, sd, status)
+			if err != nil {
+				return err
+			}
+			notifications = append(notifications, subNotifications...)
+			topologyChanged = topologyChanged || changed
+		}
+
+		// Sub-devices missing from the report have disconnected from the
+		// gateway; they stay attached so the topology list still shows them.
+		var detached []models.Device
+		query := tx.Where("gateway_sn = ? AND status = ?", gateway.DeviceSN, models.DeviceStatusOnline)
+		if len(reported) > 0 {
+			query = query.Where("device_sn NOT IN ?", reported)
+		}
+		if err := query.Find(&detached).Error; err != nil {
+			return fmt.Errorf("failed to list sub-devices: %w", err)
+		}
+		for i := range detached {
+			s.setStatus(&detached[i], false)
+			if err := tx.Save(&detached[i]).Error; err != nil {
+				return fmt.Errorf("failed to update sub-device %s: %w", detached[i].DeviceSN, err)
+			}
+			notifications = append(notifications, statusNotification(&detached[i]))
+		}
+
+		if topologyChanged {
+			notifications = append(notifications, topologyNotification(gateway))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notifications {
+		s.notify(ctx, n)
+	}
+	return nil
+}
+
+// Topologies returns the devices of a workspace grouped by gateway. Devices
+// whose gateway is not in the workspace are listed as parents of their own.
+func (s *Service) Topologies(ctx context.Context, workspaceID string) ([]Topology, error) {
+	var devices []models.Device
+	err := s.db.WithContext(ctx).
+		Where("workspace_id = ?", workspaceID).
+		Order("device_sn ASC").
+		Find(&devices).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	inWorkspace := make(map[string]bool, len(devices))
+	for i := range devices {
+		inWorkspace[devices[i].DeviceSN] = true
+	}
+
+	hosts := make(map[string][]Device)
+	var parents []*models.Device
+	for i := range devices {
+		d := &devices[i]
+		if d.GatewaySN != nil && *d.GatewaySN != d.DeviceSN && inWorkspace[*d.GatewaySN] {
+			hosts[*d.GatewaySN] = append(hosts[*d.GatewaySN], ToDevice(d))
+			continue
+		}
+		parents = append(parents, d)
+	}
+
+	result := make([]Topology, 0, len(parents))
+	for _, parent := range parents {
+		t := Topology{
+			Hosts:   hosts[parent.DeviceSN],
+			Parents: []Device{ToDevice(parent)},
+		}
+		if t.Hosts == nil {
+			t.Hosts = []Device{}
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+// updateSubDevice attaches a reported sub-device to the gateway and
+// updates its status. It returns the notifications to push and whether the
+// topology changed.
+func (s *Service) updateSubDevice(tx *gorm.DB, gateway *models.Device, sd SubDevice, status *Status) ([]notification, bool, error) {
+	subDeviceType := deviceType(sd.ProductType, sd.DeviceModelKey, DefaultSubDeviceType)
+	device, created, err := s.loadOrCreate(tx, sd.DeviceSN, subDeviceType, sd.DeviceModelKey, status.Vendor)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var notifications []notification
+	topologyChanged := false
+	moved := false
+	if device.GatewaySN == nil || *device.GatewaySN != gateway.DeviceSN {
+		// The previous gateway's clients must drop the device
+		if device.GatewaySN != nil && *device.GatewaySN != "" {
+			moved = true
+			previous, err := previousGateway(tx, device)
+			if err != nil {
+				return nil, false, err
+			}
+			notifications = append(notifications, topologyNotification(previous))
+		}
+		device.GatewaySN = &gateway.DeviceSN
+		topologyChanged = true
+	}
+	if gateway.WorkspaceID != "" && device.WorkspaceID != gateway.WorkspaceID && (device.WorkspaceID == "" || moved) {
+		device.WorkspaceID = gateway.WorkspaceID
+		topologyChanged = true
+	}
+	if changed := s.setStatus(device, status.Online && sd.Online); changed || created {
+		notifications = append(notifications, statusNotification(device))
+	}
+	if err := tx.Save(device).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to update sub-device %s: %w", sd.DeviceSN, err)
+	}
+	return notifications, topologyChanged, nil
+}
+
+// previousGateway returns the gateway a device is attached to, for the
+// topology event of the workspace the device is leaving. A gateway no longer
+// stored is represented by its serial number.
+func previousGateway(tx *gorm.DB, device *models.Device) (*models.Device, error) {
+	var gateway models.Device
+	err := tx.Where("device_sn = ?", *device.GatewaySN).First(&gateway).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		gateway = models.Device{DeviceSN: *device.GatewaySN}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get gateway %s: %w", *device.GatewaySN, err)
+	}
+	gateway.WorkspaceID = device.WorkspaceID
+	return &gateway, nil
+}
+
+// deviceType returns the reported device type, else the one derived from
+// the model key, else fallback.
+func deviceType(reported, modelKey, fallback string) string {
+	if reported != "" {
+		return reported
+	}
+	if t := DeviceType(modelKey); t != "" {
+		return t
+	}
+	return fallback
+}
+
+// loadOrCreate returns the device with the serial number, creating it when
+// it is unknown. created reports whether it was created. A reported modelKey
+// is stored with the device, and devices without a thing model are given the
+// one whose product key is modelKey, if any.
+func (s *Service) loadOrCreate(tx *gorm.DB, deviceSN, deviceType, modelKey, vendor string) (*models.Device, bool, error) {
+	var device models.Device
+	err := tx.Where("device_sn = ?", deviceSN).First(&device).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, fmt.Errorf("failed to get device %s: %w", deviceSN, err)
+	}
+
+	var thingModelID *uint
+	if device.ThingModelID == nil && modelKey != "" {
+		if thingModelID, err = thingModel(tx, modelKey); err != nil {
+			return nil, false, err
+		}
+	}
+	if device.ID != 0 {
+		if thingModelID != nil {
+			device.ThingModelID = thingModelID
+		}
+		if modelKey != "" {
+			device.ModelKey = modelKey
+		}
+		return &device, false, nil
+	}
+
+	if vendor == "" {
+		vendor = "generic"
+	}
+	device = models.Device{
+		ThingModelID: thingModelID,
+		DeviceSN:     deviceSN,
+		DeviceName:   deviceSN,
+		DeviceType:   deviceType,
+		ModelKey:     modelKey,
+		Vendor:       vendor,
+		Status:       models.DeviceStatusUnknown,
+	}
+	if err := tx.Create(&device).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to create device %s: %w", deviceSN, err)
+	}
+	return &device, true, nil
+}
+
+// thingModel returns the ID of the thing model with the product key, or nil
+// when there is none.
+func thingModel(tx *gorm.DB, productKey string) (*uint, error) {
+	var thingModels []models.ThingModel
+	if err := tx.Select("id").Where("product_key = ?", productKey).Limit(1).Find(&thingModels).Error; err != nil {
+		return nil, fmt.Errorf("failed to get thing model %s: %w", productKey, err)
+	}
+	if len(thingModels) == 0 {
+		return nil, nil
+	}
+	return &thingModels[0].ID, nil
+}
+
+// setStatus sets the device's status and reports whether it changed.
+func (s *Service) setStatus(device *models.Device, online bool) bool {
+	status := models.DeviceStatusOffline
+	if online {
+		status = models.DeviceStatusOnline
+		now := s.now()
+		device.LastOnlineTime = &now
+	}
+	changed := device.Status != status
+	device.Status = status
+	return changed
+}
+
+func topologyNotification(gateway *models.Device) notification {
+	return notification{
+		workspaceID: gateway.WorkspaceID,
+		event:       EventUpdateTopo,
+		data:        DeviceEvent{SN: gateway.DeviceSN, Host: ToDevice(gateway)},
+	}
+}
+
+func statusNotification(device *models.Device) notification {
+	event := EventDeviceOffline
+	if device.Status == models.DeviceStatusOnline {
+		event = EventDeviceOnline
+	}
+	return notification{
+		workspaceID: device.WorkspaceID,
+		event:       event,
+		data:        DeviceEvent{SN: device.DeviceSN, Host: ToDevice(device)},
+	}
+}
+
+// notify pushes an event to a workspace. Devices not bound to a workspace
+// have no audience. Failures are logged only: the change is stored and
+// clients resynchronise on the next topology list.
+func (s *Service) notify(ctx context.Context, n notification) {
+	if s.notifier == nil || n.workspaceID == "" {
+		return
+	}
+	if err := s.notifier.NotifyWorkspace(ctx, n.workspaceID, n.event, n.data); err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"workspace_id": n.workspaceID,
+			"event":        n.event,
+		}).Warn("Failed to push device topology change")
+	}
+}
@@ -0,0 +1,261 @@
+package topology
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+type notified struct {
+	workspaceID string
+	event       string
+}
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []notified
+	data   []any
+}
+
+func (n *recordingNotifier) NotifyWorkspace(_ context.Context, workspaceID, event string, data any) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, notified{workspaceID: workspaceID, event: event})
+	n.data = append(n.data, data)
+	return nil
+}
+
+func (n *recordingNotifier) take() []notified {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	events := n.events
+	n.events = nil
+	n.data = nil
+	return events
+}
+
+// takeData returns the data of the events pushed since the last take
+func (n *recordingNotifier) takeData() []any {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	data := n.data
+	n.events = nil
+	n.data = nil
+	return data
+}
+
+type recordingPublisher struct {
+	routingKeys []string
+	msgs        []*rabbitmq.StandardMessage
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, routingKey string, msg *rabbitmq.StandardMessage) error {
+	p.routingKeys = append(p.routingKeys, routingKey)
+	p.msgs = append(p.msgs, msg)
+	return nil
+}
+
+func setupService(t *testing.T) (*Service, *recordingNotifier) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Device{}, &models.ThingModel{}))
+	notifier := &recordingNotifier{}
+	return NewService(db, notifier, nil), notifier
+}
+
+func statusMessage(t *testing.T, gatewaySN string, online bool, subDevices ...SubDevice) *rabbitmq.StandardMessage {
+	t.Helper()
+	msg, err := rabbitmq.NewStandardMessage("iot-uplink", "status.processed", gatewaySN, map[string]any{
+		"properties": map[string]any{
+			"online": online,
+			"topology": map[string]any{
+				"gateway_sn":   gatewaySN,
+				"gateway_type": "dock",
+				"sub_devices":  subDevices,
+			},
+		},
+	})
+	require.NoError(t, err)
+	msg.ProtocolMeta = &rabbitmq.ProtocolMeta{Vendor: "dji"}
+	return msg
+}
+
+func TestService_HandleUplinkStatus(t *testing.T) {
+	svc, notifier := setupService(t)
+	ctx := context.Background()
+
+	require.NoError(t, svc.db.Create(&models.Device{
+		DeviceSN: "DOCK1", DeviceName: "Dock 1", DeviceType: "dock", Vendor: "dji", WorkspaceID: "ws1",
+	}).Error)
+
+	msg := statusMessage(t, "DOCK1", true, SubDevice{DeviceSN: "UAV1", ProductType: "aircraft", Online: true})
+	require.NoError(t, svc.HandleUplinkStatus(ctx, msg))
+
+	var aircraft models.Device
+	require.NoError(t, svc.db.Where("device_sn = ?", "UAV1").First(&aircraft).Error)
+	require.NotNil(t, aircraft.GatewaySN)
+	assert.Equal(t, "DOCK1", *aircraft.GatewaySN)
+	assert.Equal(t, "ws1", aircraft.WorkspaceID, "sub-devices inherit the gateway's workspace")
+	assert.Equal(t, models.DeviceStatusOnline, aircraft.Status)
+	assert.Equal(t, "dji", aircraft.Vendor)
+	assert.NotNil(t, aircraft.LastOnlineTime)
+
+	assert.Equal(t, []notified{
+		{"ws1", EventDeviceOnline},
+		{"ws1", EventDeviceOnline},
+		{"ws1", EventUpdateTopo},
+	}, notifier.take())
+
+	// The same report again changes nothing
+	require.NoError(t, svc.HandleUplinkStatus(ctx, msg))
+	assert.Empty(t, notifier.take())
+
+	// The aircraft disappearing from the topology takes it offline
+	require.NoError(t, svc.HandleUplinkStatus(ctx, statusMessage(t, "DOCK1", true)))
+	require.NoError(t, svc.db.Where("device_sn = ?", "UAV1").First(&aircraft).Error)
+	assert.Equal(t, models.DeviceStatusOffline, aircraft.Status)
+	require.NotNil(t, aircraft.GatewaySN, "the relation is kept")
+	assert.Equal(t, []notified{{"ws1", EventDeviceOffline}}, notifier.take())
+}
+
+func TestService_Topologies(t *testing.T) {
+	svc, _ := setupService(t)
+	ctx := context.Background()
+
+	require.NoError(t, svc.db.Create(&[]models.Device{
+		{DeviceSN: "RC1", DeviceName: "RC 1", DeviceType: "rc", Vendor: "dji", WorkspaceID: "ws1"},
+		{DeviceSN: "DOCK1", DeviceName: "Dock 1", DeviceType: "dock", Vendor: "dji", WorkspaceID: "ws1"},
+		{DeviceSN: "DOCK2", DeviceName: "Dock 2", DeviceType: "dock", Vendor: "dji", WorkspaceID: "ws2"},
+	}).Error)
+	require.NoError(t, svc.Update(ctx, &Status{
+		GatewaySN:  "DOCK1",
+		SubDevices: []SubDevice{{DeviceSN: "UAV1", DeviceModelKey: "0-67-0", Online: true}},
+		Online:     true,
+	}))
+
+	topologies, err := svc.Topologies(ctx, "ws1")
+	require.NoError(t, err)
+	require.Len(t, topologies, 2)
+
+	assert.Equal(t, "DOCK1", topologies[0].Parents[0].SN)
+	assert.True(t, topologies[0].Parents[0].OnlineStatus)
+	require.Len(t, topologies[0].Hosts, 1)
+	assert.Equal(t, "UAV1", topologies[0].Hosts[0].SN)
+	assert.Equal(t, "DOCK1", topologies[0].Hosts[0].GatewaySN)
+	assert.Equal(t, DeviceModel{Key: "0-67-0", Domain: "0", Type: "67", SubType: "0"}, topologies[0].Hosts[0].DeviceModel)
+	assert.Equal(t, AircraftIconURL, topologies[0].Hosts[0].IconURLs.NormalIconURL)
+	assert.Equal(t, DeviceModel{}, topologies[0].Parents[0].DeviceModel, "the dock reported no model key")
+
+	assert.Equal(t, "RC1", topologies[1].Parents[0].SN)
+	assert.Equal(t, "RC 1", topologies[1].Parents[0].DeviceCallsign)
+	assert.Empty(t, topologies[1].Hosts)
+
+	other, err := svc.Topologies(ctx, "ws2")
+	require.NoError(t, err)
+	require.Len(t, other, 1)
+	assert.Equal(t, "DOCK2", other[0].Parents[0].SN)
+}
+
+func TestService_UpdateRequiresGateway(t *testing.T) {
+	svc, _ := setupService(t)
+	assert.Error(t, svc.Update(context.Background(), &Status{Online: true}))
+}
+
+func updateTopoMessage(t *testing.T, gatewaySN string, subDevices ...map[string]any) *rabbitmq.StandardMessage {
+	t.Helper()
+	if subDevices == nil {
+		subDevices = []map[string]any{}
+	}
+	msg, err := rabbitmq.NewStandardMessageWithIDs("tid-"+gatewaySN, "bid-"+gatewaySN, "iot-uplink", "status.processed", gatewaySN, map[string]any{
+		"properties": map[string]any{
+			"online":      true,
+			"domain":      "3",
+			"type":        1,
+			"sub_type":    0,
+			"sub_devices": subDevices,
+		},
+	})
+	require.NoError(t, err)
+	msg.ProtocolMeta = &rabbitmq.ProtocolMeta{Vendor: "dji", Method: "update_topo"}
+	return msg
+}
+
+func TestService_HandleUpdateTopo(t *testing.T) {
+	svc, notifier := setupService(t)
+	publisher := &recordingPublisher{}
+	svc.SetReplyPublisher(publisher)
+	ctx := context.Background()
+
+	require.NoError(t, svc.db.Create(&models.ThingModel{
+		ProductKey: "0-67-0", ProductName: "M30", Version: "1.0", TSLJSON: []byte(`{}`),
+	}).Error)
+	require.NoError(t, svc.db.Create(&[]models.Device{
+		{DeviceSN: "DOCK1", DeviceName: "Dock 1", DeviceType: "dock", Vendor: "dji", WorkspaceID: "ws1"},
+		{DeviceSN: "DOCK2", DeviceName: "Dock 2", DeviceType: "dock", Vendor: "dji", WorkspaceID: "ws2"},
+	}).Error)
+
+	aircraft := map[string]any{"sn": "UAV1", "domain": "0", "type": 67, "sub_type": 0, "index": "A"}
+	require.NoError(t, svc.HandleUplinkStatus(ctx, updateTopoMessage(t, "DOCK1", aircraft)))
+
+	var uav models.Device
+	require.NoError(t, svc.db.Where("device_sn = ?", "UAV1").First(&uav).Error)
+	assert.Equal(t, DeviceTypeAircraft, uav.DeviceType)
+	assert.Equal(t, "0-67-0", uav.ModelKey)
+	require.NotNil(t, uav.ThingModelID, "the model key selects the thing model")
+	require.NotNil(t, uav.GatewaySN)
+	assert.Equal(t, "DOCK1", *uav.GatewaySN)
+	assert.Equal(t, "ws1", uav.WorkspaceID)
+	assert.Equal(t, models.DeviceStatusOnline, uav.Status)
+	data := notifier.takeData()
+	require.Len(t, data, 3)
+	online := data[1].(DeviceEvent)
+	assert.Equal(t, "UAV1", online.SN)
+	assert.Equal(t, "0-67-0", online.Host.DeviceModel.Key)
+	assert.True(t, online.Host.OnlineStatus)
+	topo := data[2].(DeviceEvent)
+	assert.Equal(t, "DOCK1", topo.SN)
+	assert.Equal(t, "3-1-0", topo.Host.DeviceModel.Key)
+
+	require.Len(t, publisher.msgs, 1)
+	assert.Equal(t, "iot.dji.device.status.reply", publisher.routingKeys[0])
+	reply := publisher.msgs[0]
+	assert.Equal(t, "tid-DOCK1", reply.TID)
+	assert.Equal(t, "bid-DOCK1", reply.BID)
+	assert.Equal(t, "DOCK1", reply.DeviceSN)
+	assert.Equal(t, "update_topo", reply.ProtocolMeta.Method)
+	assert.JSONEq(t, `{"result":0}`, string(reply.Data))
+
+	// The aircraft moves to the other dock, which is in another workspace
+	require.NoError(t, svc.HandleUplinkStatus(ctx, updateTopoMessage(t, "DOCK1")))
+	require.NoError(t, svc.HandleUplinkStatus(ctx, updateTopoMessage(t, "DOCK2", aircraft)))
+
+	require.NoError(t, svc.db.Where("device_sn = ?", "UAV1").First(&uav).Error)
+	assert.Equal(t, "DOCK2", *uav.GatewaySN)
+	assert.Equal(t, "ws2", uav.WorkspaceID)
+	assert.Equal(t, models.DeviceStatusOnline, uav.Status)
+	assert.Equal(t, []notified{
+		{"ws1", EventDeviceOffline}, // UAV1 left DOCK1
+		{"ws2", EventDeviceOnline},  // DOCK2
+		{"ws1", EventUpdateTopo},    // UAV1 detached from DOCK1
+		{"ws2", EventDeviceOnline},  // UAV1
+		{"ws2", EventUpdateTopo},
+	}, notifier.take())
+	assert.Len(t, publisher.msgs, 3)
+}
+
+func TestDeviceType(t *testing.T) {
+	assert.Equal(t, "0-67-0", DeviceModelKey("0", 67, 0))
+	assert.Equal(t, DeviceTypeAircraft, DeviceType("0-67-0"))
+	assert.Equal(t, DeviceTypePayload, DeviceType("1-42-0"))
+	assert.Equal(t, DeviceTypeRC, DeviceType("2-119-0"))
+	assert.Equal(t, DeviceTypeDock, DeviceType("3-1-0"))
+	assert.Empty(t, DeviceType(""))
+}
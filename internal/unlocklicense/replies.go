@@ -0,0 +1,163 @@
+package unlocklicense
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	dji "github.com/utmos/utmos/pkg/adapter/dji"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/safety"
+	djirouter "github.com/utmos/utmos/pkg/adapter/dji/router"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+// resultOK is the result code of successful replies.
+const resultOK = 0
+
+// uplinkReplyData is the payload of service reply messages routed by
+// iot-uplink; properties hold the data of the device's services_reply.
+type uplinkReplyData struct {
+	Properties json.RawMessage `json:"properties"`
+}
+
+// serviceReply is the data of a services_reply message.
+type serviceReply struct {
+	Output json.RawMessage `json:"output"`
+	Result int             `json:"result"`
+}
+
+// replyActions are the audited actions completed by replies to each method.
+var replyActions = map[string][]string{
+	djirouter.MethodUnlockLicenseUpdate: {ActionPush},
+	djirouter.MethodUnlockLicenseSwitch: {ActionEnable, ActionDisable},
+}
+
+// HandleServiceReply records the replies of docks to unlock licence
+// commands: unlock_license_list replies replace the licences kept for the
+// dock, and unlock_license_update and unlock_license_switch replies complete
+// the pending audit entry of the call they reply to, matched by TID. Other
+// messages are ignored.
+// It matches the rabbitmq.MessageHandler signature so it can consume
+// iot.api.service directly.
+func (s *Service) HandleServiceReply(ctx context.Context, msg *rabbitmq.StandardMessage) error {
+	if msg == nil || msg.ProtocolMeta == nil || msg.ProtocolMeta.Vendor != dji.VendorDJI {
+		return nil
+	}
+	method := msg.ProtocolMeta.Method
+	if _, ok := replyActions[method]; !ok && method != djirouter.MethodUnlockLicenseList {
+		return nil
+	}
+
+	var data uplinkReplyData
+	if err := msg.GetData(&data); err != nil {
+		return fmt.Errorf("failed to decode reply message: %w", err)
+	}
+	var reply serviceReply
+	if len(data.Properties) > 0 {
+		if err := json.Unmarshal(data.Properties, &reply); err != nil {
+			return fmt.Errorf("failed to decode %s reply: %w", method, err)
+		}
+	}
+
+	if method == djirouter.MethodUnlockLicenseList {
+		return s.recordLicenses(ctx, msg.DeviceSN, &reply)
+	}
+	return s.completeAudit(ctx, msg.DeviceSN, msg.TID, method, reply.Result)
+}
+
+// recordLicenses replaces the licences kept for a dock with those of an
+// unlock_license_list reply.
+func (s *Service) recordLicenses(ctx context.Context, deviceSN string, reply *serviceReply) error {
+	logger := s.logger.WithField("device_sn", deviceSN)
+	if reply.Result != resultOK {
+		logger.WithField("result", reply.Result).Warn("Dock failed to list unlock licences")
+		return nil
+	}
+	var output safety.UnlockLicenseListResponse
+	if err := json.Unmarshal(reply.Output, &output); err != nil {
+		return fmt.Errorf("failed to decode %s output: %w", djirouter.MethodUnlockLicenseList, err)
+	}
+
+	var device models.Device
+	err := s.db.WithContext(ctx).Where("device_sn = ?", deviceSN).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && device.WorkspaceID == "") {
+		logger.Debug("Ignoring unlock licences of unbound device")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get device: %w", err)
+	}
+
+	if output.Licenses == nil {
+		output.Licenses = []safety.UnlockLicense{}
+	}
+	licenses, err := json.Marshal(output.Licenses)
+	if err != nil {
+		return fmt.Errorf("failed to encode licences: %w", err)
+	}
+	record := models.DeviceUnlockLicenses{
+		DeviceSN:          deviceSN,
+		WorkspaceID:       device.WorkspaceID,
+		DeviceModelDomain: output.DeviceModelDomain,
+		Consistence:       output.Consistence,
+		Licenses:          licenses,
+	}
+	err = s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "device_sn"}, {Name: "device_model_domain"}},
+		DoUpdates: clause.AssignmentColumns([]string{"workspace_id", "consistence", "licenses", "updated_at"}),
+	}).Create(&record).Error
+	if err != nil {
+		return fmt.Errorf("failed to save device licences: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"device_model_domain": output.DeviceModelDomain,
+		"licenses":            len(output.Licenses),
+	}).Debug("Unlock licences recorded")
+	return nil
+}
+
+// completeAudit completes the pending audit entry of a dock's call with the
+// TID replied to.
+func (s *Service) completeAudit(ctx context.Context, deviceSN, tid, method string, result int) error {
+	logger := s.logger.WithFields(logrus.Fields{"device_sn": deviceSN, "tid": tid})
+	if tid == "" {
+		logger.Debugf("Ignoring %s reply without tid", method)
+		return nil
+	}
+	var audit models.UnlockLicenseAudit
+	err := s.db.WithContext(ctx).
+		Where("device_sn = ? AND tid = ? AND status = ? AND action IN ?", deviceSN, tid, StatusPending, replyActions[method]).
+		First(&audit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Debugf("Ignoring %s reply without pending change", method)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get pending licence audit: %w", err)
+	}
+
+	now := time.Now()
+	audit.Result = result
+	audit.CompletedAt = &now
+	audit.Status = StatusSuccess
+	if result != resultOK {
+		audit.Status = StatusFailed
+	}
+	if err := s.db.WithContext(ctx).Save(&audit).Error; err != nil {
+		return fmt.Errorf("failed to complete licence audit: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"action": audit.Action,
+		"result": result,
+	}).Info("Unlock licence change completed")
+	return nil
+}
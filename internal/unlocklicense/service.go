@@ -0,0 +1,433 @@
+// Package unlocklicense manages FlySafe unlock licences: offline licence
+// files uploaded to a workspace and pushed to its docks, the licences docks
+// report, enabling and disabling them, and an audit trail of every change.
+package unlocklicense
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/downlink/dispatcher"
+	"github.com/utmos/utmos/internal/topology"
+	dji "github.com/utmos/utmos/pkg/adapter/dji"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/safety"
+	djirouter "github.com/utmos/utmos/pkg/adapter/dji/router"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+// MaxFileSize is the largest licence file accepted, in bytes.
+const MaxFileSize = 10 << 20
+
+// fileURLTTL is how long docks may download a pushed licence file.
+const fileURLTTL = time.Hour
+
+// Device model domains of unlock_license_list.
+const (
+	DomainAircraft = 0
+	DomainDock     = 3
+)
+
+// Audited actions.
+const (
+	ActionUpload  = "upload"
+	ActionDelete  = "delete"
+	ActionPush    = "push"
+	ActionEnable  = "enable"
+	ActionDisable = "disable"
+)
+
+// Audit statuses. Changes sent to a dock are pending until it replies.
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+var (
+	// ErrInvalidFile is returned for empty, unnamed or oversized licence files.
+	ErrInvalidFile = errors.New("invalid licence file")
+	// ErrFileNotFound is returned when the licence file does not exist.
+	ErrFileNotFound = errors.New("licence file not found")
+	// ErrDeviceNotFound is returned when the device is not in the workspace.
+	ErrDeviceNotFound = errors.New("device not found in workspace")
+	// ErrNotDock is returned when licence commands target a device other
+	// than a dock.
+	ErrNotDock = errors.New("device is not a dock")
+	// ErrInvalidDomain is returned for unknown device model domains.
+	ErrInvalidDomain = errors.New("device model domain must be 0 (aircraft) or 3 (dock)")
+	// ErrDispatchUnavailable is returned when commands cannot be sent to
+	// devices.
+	ErrDispatchUnavailable = errors.New("device commands are not available")
+)
+
+// Dispatcher sends service calls to devices.
+type Dispatcher interface {
+	Handle(ctx context.Context, call *dispatcher.ServiceCall) (*dispatcher.DispatchResult, error)
+}
+
+// Actor identifies who makes a change, for the audit trail. The zero value
+// is an anonymous caller, as when authentication is disabled.
+type Actor struct {
+	// ID is the subject of the caller's credential, e.g. "user:1"
+	ID   string
+	Name string
+}
+
+// AuditFilter narrows the audit trail of a workspace.
+type AuditFilter struct {
+	DeviceSN string
+	// Limit caps the number of entries, newest first; zero means 100
+	Limit int
+}
+
+// Service stores licence files, sends licence commands to docks and keeps
+// the licences they report.
+type Service struct {
+	db         *gorm.DB
+	store      objectstore.Store
+	dispatcher Dispatcher
+	logger     *logrus.Entry
+}
+
+// NewService creates a new unlock licence service. Without a dispatcher,
+// licence files can be managed but not sent to docks.
+func NewService(db *gorm.DB, store objectstore.Store, dispatch Dispatcher, logger *logrus.Entry) *Service {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Service{
+		db:         db,
+		store:      store,
+		dispatcher: dispatch,
+		logger:     logger.WithField("component", "unlock-license"),
+	}
+}
+
+// Upload stores a licence file in a workspace.
+func (s *Service) Upload(ctx context.Context, workspaceID string, actor Actor, name string, data []byte) (*models.UnlockLicenseFile, error) {
+	name = strings.TrimSpace(filepath.Base(name))
+	if name == "" || name == "." || name == string(filepath.Separator) || len(name) > 255 {
+		return nil, fmt.Errorf("%w: file name is required", ErrInvalidFile)
+	}
+	if len(data) == 0 || len(data) > MaxFileSize {
+		return nil, fmt.Errorf("%w: file must be 1 byte to %d bytes", ErrInvalidFile, MaxFileSize)
+	}
+
+	sum := md5.Sum(data)
+	id := uuid.New().String()
+	file := &models.UnlockLicenseFile{
+		ID:          id,
+		WorkspaceID: workspaceID,
+		Name:        name,
+		ObjectKey:   fmt.Sprintf("unlock-licenses/%s/%s", workspaceID, id),
+		Fingerprint: hex.EncodeToString(sum[:]),
+		UploadedBy:  actor.Name,
+		Size:        int64(len(data)),
+	}
+	if err := s.store.Put(ctx, file.ObjectKey, bytes.NewReader(data), file.Size, "application/octet-stream"); err != nil {
+		return nil, fmt.Errorf("failed to store licence file: %w", err)
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(file).Error; err != nil {
+			return fmt.Errorf("failed to create licence file: %w", err)
+		}
+		return tx.Create(newAudit(workspaceID, actor, ActionUpload, StatusSuccess, func(a *models.UnlockLicenseAudit) {
+			a.FileID = file.ID
+		})).Error
+	})
+	if err != nil {
+		s.deleteObject(ctx, file.ObjectKey)
+		return nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"workspace_id": workspaceID,
+		"file_id":      file.ID,
+		"fingerprint":  file.Fingerprint,
+	}).Info("Unlock licence file uploaded")
+	return file, nil
+}
+
+// ListFiles lists the licence files of a workspace, newest first.
+func (s *Service) ListFiles(ctx context.Context, workspaceID string) ([]models.UnlockLicenseFile, error) {
+	var files []models.UnlockLicenseFile
+	err := s.db.WithContext(ctx).
+		Where("workspace_id = ?", workspaceID).
+		Order("created_at DESC").
+		Find(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list licence files: %w", err)
+	}
+	return files, nil
+}
+
+// GetFile returns a licence file of a workspace.
+func (s *Service) GetFile(ctx context.Context, workspaceID, id string) (*models.UnlockLicenseFile, error) {
+	var file models.UnlockLicenseFile
+	err := s.db.WithContext(ctx).Where("id = ? AND workspace_id = ?", id, workspaceID).First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get licence file: %w", err)
+	}
+	return &file, nil
+}
+
+// DeleteFile deletes a licence file. Licences docks already imported from
+// it are kept.
+func (s *Service) DeleteFile(ctx context.Context, workspaceID string, actor Actor, id string) error {
+	file, err := s.GetFile(ctx, workspaceID, id)
+	if err != nil {
+		return err
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(file).Error; err != nil {
+			return fmt.Errorf("failed to delete licence file: %w", err)
+		}
+		return tx.Create(newAudit(workspaceID, actor, ActionDelete, StatusSuccess, func(a *models.UnlockLicenseAudit) {
+			a.FileID = file.ID
+		})).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.deleteObject(ctx, file.ObjectKey)
+	return nil
+}
+
+// Push sends a licence file to docks of the workspace with
+// unlock_license_update. Every dock is checked before any is sent the
+// file; the returned audit entries record each send.
+func (s *Service) Push(ctx context.Context, workspaceID string, actor Actor, fileID string, deviceSNs []string) ([]models.UnlockLicenseAudit, error) {
+	if s.dispatcher == nil {
+		return nil, ErrDispatchUnavailable
+	}
+	file, err := s.GetFile(ctx, workspaceID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	docks := make([]*models.Device, 0, len(deviceSNs))
+	for _, sn := range deviceSNs {
+		dock, err := s.dock(ctx, workspaceID, sn)
+		if err != nil {
+			return nil, err
+		}
+		docks = append(docks, dock)
+	}
+
+	address, err := s.fileURL(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	params, err := json.Marshal(safety.UnlockLicenseUpdateData{
+		File: &safety.LicenseFileInfo{URL: address, Fingerprint: file.Fingerprint},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode unlock_license_update: %w", err)
+	}
+
+	audits := make([]models.UnlockLicenseAudit, 0, len(docks))
+	for _, dock := range docks {
+		audit := newAudit(workspaceID, actor, ActionPush, StatusPending, func(a *models.UnlockLicenseAudit) {
+			a.DeviceSN = dock.DeviceSN
+			a.FileID = file.ID
+		})
+		if err := s.send(ctx, dock, djirouter.MethodUnlockLicenseUpdate, params, audit); err != nil {
+			return audits, err
+		}
+		audits = append(audits, *audit)
+	}
+	return audits, nil
+}
+
+// Switch enables or disables a licence on a dock with
+// unlock_license_switch.
+func (s *Service) Switch(ctx context.Context, workspaceID string, actor Actor, deviceSN string, licenseID int, enable bool) (*models.UnlockLicenseAudit, error) {
+	if s.dispatcher == nil {
+		return nil, ErrDispatchUnavailable
+	}
+	dock, err := s.dock(ctx, workspaceID, deviceSN)
+	if err != nil {
+		return nil, err
+	}
+	params, err := json.Marshal(safety.UnlockLicenseSwitchData{LicenseID: licenseID, Enable: enable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode unlock_license_switch: %w", err)
+	}
+
+	action := ActionDisable
+	if enable {
+		action = ActionEnable
+	}
+	audit := newAudit(workspaceID, actor, action, StatusPending, func(a *models.UnlockLicenseAudit) {
+		a.DeviceSN = dock.DeviceSN
+		a.LicenseID = licenseID
+	})
+	if err := s.send(ctx, dock, djirouter.MethodUnlockLicenseSwitch, params, audit); err != nil {
+		return nil, err
+	}
+	return audit, nil
+}
+
+// Refresh asks a dock to report the licences of its aircraft or of itself
+// with unlock_license_list. The reply replaces the licences Licenses
+// returns for that domain.
+func (s *Service) Refresh(ctx context.Context, workspaceID, deviceSN string, domain int) error {
+	if domain != DomainAircraft && domain != DomainDock {
+		return ErrInvalidDomain
+	}
+	if s.dispatcher == nil {
+		return ErrDispatchUnavailable
+	}
+	dock, err := s.dock(ctx, workspaceID, deviceSN)
+	if err != nil {
+		return err
+	}
+	params, err := json.Marshal(safety.UnlockLicenseListData{DeviceModelDomain: domain})
+	if err != nil {
+		return fmt.Errorf("failed to encode unlock_license_list: %w", err)
+	}
+	call := newCall(dock, djirouter.MethodUnlockLicenseList, params)
+	if _, err := s.dispatcher.Handle(ctx, call); err != nil {
+		return fmt.Errorf("failed to send unlock_license_list: %w", err)
+	}
+	return nil
+}
+
+// Licenses returns the licences a dock last reported, one entry per device
+// model domain.
+func (s *Service) Licenses(ctx context.Context, workspaceID, deviceSN string) ([]models.DeviceUnlockLicenses, error) {
+	if _, err := s.dock(ctx, workspaceID, deviceSN); err != nil {
+		return nil, err
+	}
+	var licenses []models.DeviceUnlockLicenses
+	err := s.db.WithContext(ctx).
+		Where("device_sn = ? AND workspace_id = ?", deviceSN, workspaceID).
+		Order("device_model_domain ASC").
+		Find(&licenses).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list device licences: %w", err)
+	}
+	return licenses, nil
+}
+
+// Audits returns the audit trail of a workspace, newest first.
+func (s *Service) Audits(ctx context.Context, workspaceID string, filter AuditFilter) ([]models.UnlockLicenseAudit, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query := s.db.WithContext(ctx).Where("workspace_id = ?", workspaceID)
+	if filter.DeviceSN != "" {
+		query = query.Where("device_sn = ?", filter.DeviceSN)
+	}
+	var audits []models.UnlockLicenseAudit
+	if err := query.Order("id DESC").Limit(limit).Find(&audits).Error; err != nil {
+		return nil, fmt.Errorf("failed to list licence audits: %w", err)
+	}
+	return audits, nil
+}
+
+// dock returns a dock of the workspace.
+func (s *Service) dock(ctx context.Context, workspaceID, deviceSN string) (*models.Device, error) {
+	var device models.Device
+	err := s.db.WithContext(ctx).
+		Where("device_sn = ? AND workspace_id = ?", deviceSN, workspaceID).
+		First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceSN)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+	if device.DeviceType != topology.DeviceTypeDock {
+		return nil, fmt.Errorf("%w: %s", ErrNotDock, deviceSN)
+	}
+	return &device, nil
+}
+
+// send records a pending audit entry and sends the command to the dock.
+// Commands that cannot be sent are recorded as failed.
+func (s *Service) send(ctx context.Context, dock *models.Device, method string, params json.RawMessage, audit *models.UnlockLicenseAudit) error {
+	call := newCall(dock, method, params)
+	call.TID = uuid.New().String()
+	call.BID = uuid.New().String()
+	audit.TID = call.TID
+	audit.BID = call.BID
+	if err := s.db.WithContext(ctx).Create(audit).Error; err != nil {
+		return fmt.Errorf("failed to record licence audit: %w", err)
+	}
+
+	if _, err := s.dispatcher.Handle(ctx, call); err != nil {
+		s.logger.WithError(err).WithField("device_sn", dock.DeviceSN).Warnf("Failed to send %s", method)
+		now := time.Now()
+		audit.Status = StatusFailed
+		audit.Error = err.Error()
+		audit.CompletedAt = &now
+		if err := s.db.WithContext(ctx).Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to record licence audit: %w", err)
+		}
+	}
+	return nil
+}
+
+// fileURL returns a time-limited download address of a licence file.
+func (s *Service) fileURL(ctx context.Context, file *models.UnlockLicenseFile) (string, error) {
+	presigner, ok := s.store.(objectstore.Presigner)
+	if !ok {
+		return "", errors.New("object store cannot issue download addresses")
+	}
+	address, err := presigner.PresignGet(ctx, file.ObjectKey, fileURLTTL)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign licence file: %w", err)
+	}
+	return address, nil
+}
+
+func (s *Service) deleteObject(ctx context.Context, key string) {
+	if err := s.store.Delete(ctx, key); err != nil {
+		s.logger.WithError(err).WithField("object_key", key).Warn("Failed to delete licence file object")
+	}
+}
+
+// newCall builds a service call to a dock on behalf of its workspace.
+func newCall(dock *models.Device, method string, params json.RawMessage) *dispatcher.ServiceCall {
+	vendor := dock.Vendor
+	if vendor == "" {
+		vendor = dji.VendorDJI
+	}
+	call := dispatcher.NewServiceCall(dock.DeviceSN, vendor, method, params)
+	call.WorkspaceID = dock.WorkspaceID
+	return call
+}
+
+func newAudit(workspaceID string, actor Actor, action, status string, apply func(*models.UnlockLicenseAudit)) *models.UnlockLicenseAudit {
+	audit := &models.UnlockLicenseAudit{
+		WorkspaceID: workspaceID,
+		Action:      action,
+		ActorID:     actor.ID,
+		ActorName:   actor.Name,
+		Status:      status,
+	}
+	if status != StatusPending {
+		now := time.Now()
+		audit.CompletedAt = &now
+	}
+	apply(audit)
+	return audit
+}
@@ -0,0 +1,251 @@
+package unlocklicense
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/downlink/dispatcher"
+	dji "github.com/utmos/utmos/pkg/adapter/dji"
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/safety"
+	djirouter "github.com/utmos/utmos/pkg/adapter/dji/router"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+type recordingDispatcher struct {
+	mu    sync.Mutex
+	calls []*dispatcher.ServiceCall
+	err   error
+}
+
+func (d *recordingDispatcher) Handle(_ context.Context, call *dispatcher.ServiceCall) (*dispatcher.DispatchResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, call)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &dispatcher.DispatchResult{Success: true}, nil
+}
+
+func (d *recordingDispatcher) take() []*dispatcher.ServiceCall {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	calls := d.calls
+	d.calls = nil
+	return calls
+}
+
+var operator = Actor{ID: "user:1", Name: "alice"}
+
+func setupService(t *testing.T) (*Service, *recordingDispatcher, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Device{}, &models.UnlockLicenseFile{}, &models.DeviceUnlockLicenses{}, &models.UnlockLicenseAudit{}))
+	require.NoError(t, db.Create(&[]models.Device{
+		{DeviceSN: "DOCK1", DeviceName: "Dock 1", DeviceType: "dock", Vendor: "dji", WorkspaceID: "ws1"},
+		{DeviceSN: "DOCK2", DeviceName: "Dock 2", DeviceType: "dock", Vendor: "dji", WorkspaceID: "ws1"},
+		{DeviceSN: "DOCK3", DeviceName: "Dock 3", DeviceType: "dock", Vendor: "dji", WorkspaceID: "ws2"},
+		{DeviceSN: "DRONE1", DeviceName: "Drone 1", DeviceType: "aircraft", Vendor: "dji", WorkspaceID: "ws1"},
+	}).Error)
+
+	store, err := objectstore.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	store.SetURLSigner(objectstore.NewURLSigner("https://iot.example.com/objects", []byte("secret")))
+	disp := &recordingDispatcher{}
+	return NewService(db, store, disp, nil), disp, db
+}
+
+func replyMessage(t *testing.T, deviceSN, tid, method string, reply any) *rabbitmq.StandardMessage {
+	t.Helper()
+	properties, err := json.Marshal(reply)
+	require.NoError(t, err)
+	msg, err := rabbitmq.NewStandardMessageWithIDs(tid, "bid-1", "iot-uplink", "service.processed", deviceSN, map[string]any{
+		"properties": json.RawMessage(properties),
+	})
+	require.NoError(t, err)
+	msg.ProtocolMeta = &rabbitmq.ProtocolMeta{Vendor: dji.VendorDJI, Method: method}
+	return msg
+}
+
+func TestService_UploadAndDeleteFiles(t *testing.T) {
+	svc, _, _ := setupService(t)
+	ctx := context.Background()
+	content := []byte("licence")
+
+	_, err := svc.Upload(ctx, "ws1", operator, "empty.lic", nil)
+	require.ErrorIs(t, err, ErrInvalidFile)
+
+	file, err := svc.Upload(ctx, "ws1", operator, "../keys/site.lic", content)
+	require.NoError(t, err)
+	sum := md5.Sum(content)
+	assert.Equal(t, hex.EncodeToString(sum[:]), file.Fingerprint)
+	assert.Equal(t, "site.lic", file.Name)
+	assert.Equal(t, "alice", file.UploadedBy)
+
+	files, err := svc.ListFiles(ctx, "ws1")
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+	_, err = svc.GetFile(ctx, "ws2", file.ID)
+	require.ErrorIs(t, err, ErrFileNotFound)
+
+	require.NoError(t, svc.DeleteFile(ctx, "ws1", operator, file.ID))
+	require.ErrorIs(t, svc.DeleteFile(ctx, "ws1", operator, file.ID), ErrFileNotFound)
+
+	audits, err := svc.Audits(ctx, "ws1", AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, audits, 2)
+	assert.Equal(t, ActionDelete, audits[0].Action)
+	assert.Equal(t, ActionUpload, audits[1].Action)
+	assert.Equal(t, StatusSuccess, audits[1].Status)
+	assert.Equal(t, "user:1", audits[1].ActorID)
+	assert.Equal(t, file.ID, audits[1].FileID)
+}
+
+func TestService_PushCompletesOnReply(t *testing.T) {
+	svc, disp, _ := setupService(t)
+	ctx := context.Background()
+	file, err := svc.Upload(ctx, "ws1", operator, "site.lic", []byte("licence"))
+	require.NoError(t, err)
+
+	_, err = svc.Push(ctx, "ws1", operator, file.ID, []string{"DOCK1", "DOCK3"})
+	require.ErrorIs(t, err, ErrDeviceNotFound)
+	_, err = svc.Push(ctx, "ws1", operator, file.ID, []string{"DRONE1"})
+	require.ErrorIs(t, err, ErrNotDock)
+	assert.Empty(t, disp.take(), "nothing is sent unless every dock is valid")
+
+	audits, err := svc.Push(ctx, "ws1", operator, file.ID, []string{"DOCK1", "DOCK2"})
+	require.NoError(t, err)
+	require.Len(t, audits, 2)
+	assert.Equal(t, StatusPending, audits[0].Status)
+
+	calls := disp.take()
+	require.Len(t, calls, 2)
+	assert.Equal(t, djirouter.MethodUnlockLicenseUpdate, calls[0].Method)
+	assert.NotEmpty(t, calls[0].TID)
+	assert.Equal(t, calls[0].TID, audits[0].TID)
+	assert.Equal(t, calls[0].BID, audits[0].BID)
+	assert.Equal(t, "ws1", calls[0].WorkspaceID)
+	var params safety.UnlockLicenseUpdateData
+	require.NoError(t, json.Unmarshal(calls[0].Params, &params))
+	require.NotNil(t, params.File)
+	assert.Equal(t, file.Fingerprint, params.File.Fingerprint)
+	assert.Contains(t, params.File.URL, "https://iot.example.com/objects/")
+
+	require.NoError(t, svc.HandleServiceReply(ctx, replyMessage(t, "DOCK1", calls[0].TID, djirouter.MethodUnlockLicenseUpdate, map[string]any{"result": 0})))
+	require.NoError(t, svc.HandleServiceReply(ctx, replyMessage(t, "DOCK2", calls[1].TID, djirouter.MethodUnlockLicenseUpdate, map[string]any{"result": 314000})))
+
+	trail, err := svc.Audits(ctx, "ws1", AuditFilter{DeviceSN: "DOCK1"})
+	require.NoError(t, err)
+	require.Len(t, trail, 1)
+	assert.Equal(t, StatusSuccess, trail[0].Status)
+	assert.NotNil(t, trail[0].CompletedAt)
+	trail, err = svc.Audits(ctx, "ws1", AuditFilter{DeviceSN: "DOCK2"})
+	require.NoError(t, err)
+	require.Len(t, trail, 1)
+	assert.Equal(t, StatusFailed, trail[0].Status)
+	assert.Equal(t, 314000, trail[0].Result)
+}
+
+func TestService_Switch(t *testing.T) {
+	svc, disp, _ := setupService(t)
+	ctx := context.Background()
+
+	audit, err := svc.Switch(ctx, "ws1", operator, "DOCK1", 42, false)
+	require.NoError(t, err)
+	assert.Equal(t, ActionDisable, audit.Action)
+	assert.Equal(t, 42, audit.LicenseID)
+	calls := disp.take()
+	require.Len(t, calls, 1)
+	assert.JSONEq(t, `{"license_id":42,"enable":false}`, string(calls[0].Params))
+	disable := calls[0].TID
+
+	disp.err = errors.New("broker down")
+	audit, err = svc.Switch(ctx, "ws1", operator, "DOCK1", 42, true)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, audit.Status)
+	assert.Equal(t, "broker down", audit.Error)
+	disp.take()
+
+	disp.err = nil
+	_, err = svc.Switch(ctx, "ws1", operator, "DOCK1", 7, true)
+	require.NoError(t, err)
+	calls = disp.take()
+	require.Len(t, calls, 1)
+	enable := calls[0].TID
+
+	// Replies complete the call they answer, whatever the order, and
+	// replies to unknown calls are ignored
+	require.NoError(t, svc.HandleServiceReply(ctx, replyMessage(t, "DOCK1", enable, djirouter.MethodUnlockLicenseSwitch, map[string]any{"result": 0})))
+	require.NoError(t, svc.HandleServiceReply(ctx, replyMessage(t, "DOCK1", "unknown", djirouter.MethodUnlockLicenseSwitch, map[string]any{"result": 0})))
+	audits, err := svc.Audits(ctx, "ws1", AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, audits, 3)
+	assert.Equal(t, 7, audits[0].LicenseID)
+	assert.Equal(t, StatusSuccess, audits[0].Status)
+	assert.Equal(t, StatusFailed, audits[1].Status)
+	assert.Equal(t, StatusPending, audits[2].Status)
+
+	require.NoError(t, svc.HandleServiceReply(ctx, replyMessage(t, "DOCK1", disable, djirouter.MethodUnlockLicenseSwitch, map[string]any{"result": 0})))
+	audits, err = svc.Audits(ctx, "ws1", AuditFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, StatusSuccess, audits[2].Status)
+
+	svc = NewService(nil, nil, nil, nil)
+	_, err = svc.Switch(ctx, "ws1", operator, "DOCK1", 42, true)
+	assert.ErrorIs(t, err, ErrDispatchUnavailable)
+}
+
+func TestService_RefreshRecordsLicenses(t *testing.T) {
+	svc, disp, _ := setupService(t)
+	ctx := context.Background()
+
+	require.ErrorIs(t, svc.Refresh(ctx, "ws1", "DOCK1", 1), ErrInvalidDomain)
+	require.NoError(t, svc.Refresh(ctx, "ws1", "DOCK1", DomainAircraft))
+	calls := disp.take()
+	require.Len(t, calls, 1)
+	assert.Equal(t, djirouter.MethodUnlockLicenseList, calls[0].Method)
+	assert.JSONEq(t, `{"device_model_domain":0}`, string(calls[0].Params))
+
+	output := safety.UnlockLicenseListResponse{
+		DeviceModelDomain: DomainAircraft,
+		Consistence:       true,
+		Licenses: []safety.UnlockLicense{{
+			CommonFields: safety.LicenseCommonFields{LicenseID: 42, Name: "Tower", Type: 1, Enabled: true},
+			CircleUnlock: &safety.CircleUnlockInfo{Radius: 500, Latitude: 22.5, Longitude: 113.9, Height: 120},
+		}},
+	}
+	for range 2 {
+		require.NoError(t, svc.HandleServiceReply(ctx, replyMessage(t, "DOCK1", "tid-1", djirouter.MethodUnlockLicenseList, map[string]any{"result": 0, "output": output})))
+	}
+
+	licenses, err := svc.Licenses(ctx, "ws1", "DOCK1")
+	require.NoError(t, err)
+	require.Len(t, licenses, 1)
+	assert.True(t, licenses[0].Consistence)
+	var reported []safety.UnlockLicense
+	require.NoError(t, json.Unmarshal(licenses[0].Licenses, &reported))
+	require.Len(t, reported, 1)
+	assert.Equal(t, 42, reported[0].CommonFields.LicenseID)
+	require.NotNil(t, reported[0].CircleUnlock)
+
+	_, err = svc.Licenses(ctx, "ws2", "DOCK1")
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+
+	// Other methods and vendors are ignored
+	require.NoError(t, svc.HandleServiceReply(ctx, replyMessage(t, "DOCK1", "tid-1", "flighttask_execute", map[string]any{"result": 0})))
+	other := replyMessage(t, "DOCK1", "tid-1", djirouter.MethodUnlockLicenseList, map[string]any{"result": 0, "output": output})
+	other.ProtocolMeta.Vendor = "other"
+	require.NoError(t, svc.HandleServiceReply(ctx, other))
+}
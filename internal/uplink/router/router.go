@@ -22,6 +22,8 @@ const (
 	RoutingKeyWSStatus   = "iot.ws.status"
 	RoutingKeyAPIProperty = "iot.api.property"
 	RoutingKeyAPIEvent    = "iot.api.event"
+	RoutingKeyAPIStatus   = "iot.api.status"
+	RoutingKeyAPIService  = "iot.api.service"
 )
 
 // Config holds router configuration
@@ -159,8 +161,10 @@ func (r *Router) routeToWS(ctx context.Context, msg *adapter.ProcessedMessage) e
 
 // routeToAPI routes message to API service
 func (r *Router) routeToAPI(ctx context.Context, msg *adapter.ProcessedMessage) error {
-	// Only route property and event messages to API
-	if msg.MessageType != adapter.MessageTypeProperty && msg.MessageType != adapter.MessageTypeEvent {
+	// Only route property, event, status and service reply messages to API
+	switch msg.MessageType {
+	case adapter.MessageTypeProperty, adapter.MessageTypeEvent, adapter.MessageTypeStatus, adapter.MessageTypeService:
+	default:
 		return nil
 	}
 
@@ -204,6 +208,10 @@ func (r *Router) getAPIRoutingKey(msgType adapter.MessageType) string {
 		return RoutingKeyAPIProperty
 	case adapter.MessageTypeEvent:
 		return RoutingKeyAPIEvent
+	case adapter.MessageTypeStatus:
+		return RoutingKeyAPIStatus
+	case adapter.MessageTypeService:
+		return RoutingKeyAPIService
 	default:
 		return RoutingKeyAPIProperty
 	}
@@ -230,10 +238,13 @@ func (r *Router) createStandardMessage(msg *adapter.ProcessedMessage) (*rabbitmq
 		stdMsg.BID = msg.Original.BID
 	}
 
-	// Set protocol meta
+	// Set protocol meta, keeping the method so consumers can reply
 	stdMsg.ProtocolMeta = &rabbitmq.ProtocolMeta{
 		Vendor: msg.Vendor,
 	}
+	if msg.Original != nil && msg.Original.ProtocolMeta != nil {
+		stdMsg.ProtocolMeta.Method = msg.Original.ProtocolMeta.Method
+	}
 
 	return stdMsg, nil
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/utmos/utmos/pkg/adapter"
+	"github.com/utmos/utmos/pkg/rabbitmq"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -115,6 +116,8 @@ func TestRouter_GetAPIRoutingKey(t *testing.T) {
 	}{
 		{adapter.MessageTypeProperty, RoutingKeyAPIProperty},
 		{adapter.MessageTypeEvent, RoutingKeyAPIEvent},
+		{adapter.MessageTypeStatus, RoutingKeyAPIStatus},
+		{adapter.MessageTypeService, RoutingKeyAPIService},
 		{adapter.MessageType("unknown"), RoutingKeyAPIProperty},
 	}
 
@@ -173,6 +176,26 @@ func TestRouter_CreateStandardMessage(t *testing.T) {
 	assert.Equal(t, "dji", stdMsg.ProtocolMeta.Vendor)
 }
 
+func TestRouter_CreateStandardMessageKeepsMethod(t *testing.T) {
+	router := NewRouter(nil, nil, nil)
+
+	msg := &adapter.ProcessedMessage{
+		Original: &rabbitmq.StandardMessage{
+			TID:          "tid-1",
+			BID:          "bid-1",
+			ProtocolMeta: &rabbitmq.ProtocolMeta{Vendor: "dji", Method: "update_topo"},
+		},
+		MessageType: adapter.MessageTypeStatus,
+		DeviceSN:    "DOCK001",
+		Vendor:      "dji",
+	}
+
+	stdMsg, err := router.createStandardMessage(msg)
+	require.NoError(t, err)
+	assert.Equal(t, "tid-1", stdMsg.TID)
+	assert.Equal(t, "update_topo", stdMsg.ProtocolMeta.Method)
+}
+
 func TestNewMultiRouter(t *testing.T) {
 	router := NewMultiRouter(nil)
 	require.NotNil(t, router)
@@ -249,4 +272,6 @@ func TestRoutingKeyConstants(t *testing.T) {
 	assert.Equal(t, "iot.ws.status", RoutingKeyWSStatus)
 	assert.Equal(t, "iot.api.property", RoutingKeyAPIProperty)
 	assert.Equal(t, "iot.api.event", RoutingKeyAPIEvent)
+	assert.Equal(t, "iot.api.status", RoutingKeyAPIStatus)
+	assert.Equal(t, "iot.api.service", RoutingKeyAPIService)
 }
@@ -0,0 +1,699 @@
+// Package wayline manages stored WPML wayline files: validation, KMZ
+// packaging, persistence of metadata, revision history and blob storage.
+package wayline
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/adapter/dji/wpml"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+	"github.com/utmos/utmos/pkg/terrain"
+)
+
+// ContentTypeKMZ is the MIME type used for stored wayline archives.
+const ContentTypeKMZ = "application/vnd.google-earth.kmz"
+
+// objectPrefix is the object store prefix for wayline archives.
+const objectPrefix = "waylines/"
+
+var (
+	// ErrDuplicateName is returned when a wayline with the same name already exists.
+	ErrDuplicateName = errors.New("wayline name already exists")
+	// ErrInvalidKMZ is returned when an uploaded archive cannot be parsed.
+	ErrInvalidKMZ = errors.New("invalid KMZ file")
+	// ErrInvalidRoute is returned when an imported route file cannot be read.
+	ErrInvalidRoute = errors.New("invalid route file")
+	// ErrRevisionNotFound is returned when a wayline has no revision with the requested number.
+	ErrRevisionNotFound = errors.New("wayline revision not found")
+	// ErrRevisionUnchanged is returned when a new revision's archive is identical to the latest one.
+	ErrRevisionUnchanged = errors.New("wayline revision is unchanged")
+	// ErrRevisionConflict is returned when another revision was stored concurrently.
+	ErrRevisionConflict = errors.New("wayline was revised concurrently")
+	// ErrWaylineNotFound is returned when a referenced wayline does not exist.
+	ErrWaylineNotFound = errors.New("wayline not found")
+	// ErrWaylineInUse is returned when deleting a wayline flight tasks still reference.
+	ErrWaylineInUse = errors.New("wayline is referenced by flight tasks")
+	// ErrPresignUnsupported is returned when the object store cannot issue download addresses.
+	ErrPresignUnsupported = errors.New("object store cannot issue download addresses")
+)
+
+// ValidationError reports why a mission was rejected.
+type ValidationError struct {
+	Details []string
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	return "wayline validation failed: " + strings.Join(e.Details, "; ")
+}
+
+// Owner identifies who a wayline is stored for. The zero value is the
+// default workspace used by the platform REST API.
+type Owner struct {
+	WorkspaceID string
+	Username    string
+}
+
+// WorkspaceGeofences provides the flight areas a workspace keeps its
+// aircraft out of or within.
+type WorkspaceGeofences interface {
+	Geofences(ctx context.Context, workspaceID string) ([]wpml.Geofence, error)
+}
+
+// Service stores and retrieves wayline files.
+type Service struct {
+	db        *gorm.DB
+	store     objectstore.Store
+	validator *wpml.Validator
+	logger    *logrus.Entry
+
+	terrain      *wpml.TerrainModel
+	minClearance float64
+
+	geofences          []wpml.Geofence
+	workspaceGeofences WorkspaceGeofences
+}
+
+// NewService creates a new wayline service.
+func NewService(db *gorm.DB, store objectstore.Store, logger *logrus.Entry) (*Service, error) {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	validator, err := wpml.NewValidator()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WPML validator: %w", err)
+	}
+	return &Service{
+		db:        db,
+		store:     store,
+		validator: validator,
+		logger:    logger.WithField("component", "wayline"),
+	}, nil
+}
+
+// Store returns the object store backing the service.
+func (s *Service) Store() objectstore.Store {
+	return s.store
+}
+
+// SetTerrain sets the terrain model used to resolve above ground level and
+// EGM96 waypoint heights of created waylines. When minClearance is positive,
+// validation also rejects waylines passing closer to the terrain; waylines
+// outside the elevation model's coverage are not checked.
+func (s *Service) SetTerrain(model *wpml.TerrainModel, minClearance float64) {
+	s.terrain = model
+	s.minClearance = minClearance
+}
+
+// SetGeofences sets the no-fly zones and geofences every leg of a wayline
+// is checked against.
+func (s *Service) SetGeofences(geofences []wpml.Geofence) error {
+	if err := s.validator.SetGeofences(geofences); err != nil {
+		return err
+	}
+	s.geofences = geofences
+	return nil
+}
+
+// SetWorkspaceGeofences sets the source of the flight areas a wayline's
+// workspace adds to the geofences it is checked against.
+func (s *Service) SetWorkspaceGeofences(source WorkspaceGeofences) {
+	s.workspaceGeofences = source
+}
+
+// ImportKMZ validates an uploaded KMZ archive and stores it unchanged.
+func (s *Service) ImportKMZ(ctx context.Context, owner Owner, name, description string, data []byte) (*models.Wayline, error) {
+	mission, err := wpml.ParseKMZBuffer(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKMZ, err)
+	}
+	wayline, err := s.prepare(ctx, owner, name, description, mission, data)
+	if err != nil {
+		return nil, err
+	}
+	return s.save(ctx, wayline, data)
+}
+
+// ImportRoute creates a wayline from a KML, GeoJSON, GPX or Litchi CSV route
+// file, converting it as CreateFromWaylines does.
+func (s *Service) ImportRoute(ctx context.Context, owner Owner, format wpml.RouteFormat, data []byte, opts *wpml.ImportOptions) (*models.Wayline, error) {
+	waylines, err := wpml.ImportRoute(format, data, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRoute, err)
+	}
+	return s.CreateFromWaylines(ctx, owner, waylines)
+}
+
+// RegisterUploaded records an archive that a device already uploaded to the
+// object store under objectKey. The archive is validated in place and kept
+// under its original key.
+func (s *Service) RegisterUploaded(ctx context.Context, owner Owner, name, objectKey string) (*models.Wayline, error) {
+	rc, _, err := s.store.Get(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded wayline: %w", err)
+	}
+	mission, err := wpml.ParseKMZBuffer(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKMZ, err)
+	}
+
+	wayline, err := s.prepare(ctx, owner, name, "", mission, data)
+	if err != nil {
+		return nil, err
+	}
+	wayline.ObjectKey = objectKey
+	if err := s.create(ctx, wayline); err != nil {
+		return nil, fmt.Errorf("failed to save wayline: %w", err)
+	}
+	s.logStored(wayline)
+	return wayline, nil
+}
+
+// CreateFromWaylines converts a Waylines JSON schema into a mission and stores
+// the generated KMZ.
+func (s *Service) CreateFromWaylines(ctx context.Context, owner Owner, waylines *wpml.Waylines) (*models.Wayline, error) {
+	waylines.ApplyDefaults()
+	if err := s.validator.ValidateStruct(waylines); err != nil {
+		return nil, &ValidationError{Details: s.validator.GetValidationErrors(err)}
+	}
+	if s.terrain != nil {
+		if err := s.terrain.Apply(waylines); err != nil {
+			return nil, &ValidationError{Details: []string{err.Error()}}
+		}
+	}
+
+	mission, err := wpml.ConvertWaylinesToMission(waylines)
+	if err != nil {
+		return nil, &ValidationError{Details: []string{err.Error()}}
+	}
+
+	buffer, err := wpml.CreateKmzBuffer(mission)
+	if errors.Is(err, wpml.ErrMegaphoneFileMissing) || errors.Is(err, wpml.ErrMegaphoneFileMD5Mismatch) {
+		return nil, &ValidationError{Details: []string{err.Error()}}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to build KMZ: %w", err)
+	}
+	wayline, err := s.prepare(ctx, owner, waylines.Name, waylines.Description, mission, buffer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return s.save(ctx, wayline, buffer.Bytes())
+}
+
+// Validate checks a mission's waylines document, including rules that depend
+// on the drone and payload declared in its mission config and the geofences
+// of the workspace it is stored in.
+func (s *Service) Validate(ctx context.Context, workspaceID string, mission *wpml.Mission) error {
+	if mission == nil || mission.Waylines == nil {
+		return &ValidationError{Details: []string{wpml.ErrWaylineDocumentCannotBeNil.Error()}}
+	}
+
+	if err := s.validator.ValidateWaylinesDocument(mission.Waylines); err != nil {
+		return &ValidationError{Details: s.validator.GetValidationErrors(err)}
+	}
+
+	cfg := mission.Waylines.Document.MissionConfig
+	drone := wpml.DroneModel(cfg.DroneInfo.DroneEnumValue)
+	payload := wpml.PayloadModel(cfg.PayloadInfo.PayloadEnumValue)
+
+	var details []string
+	if err := s.validator.ValidateVar(drone, "drone_model"); err != nil {
+		details = append(details, fmt.Sprintf("unsupported drone model %d", drone))
+	}
+	if err := s.validator.ValidateVar(payload, "payload_model"); err != nil {
+		details = append(details, fmt.Sprintf("unsupported payload model %d", payload))
+	}
+	if len(details) > 0 {
+		return &ValidationError{Details: details}
+	}
+
+	if err := s.validator.ValidateWithContext(mission.Waylines, drone, payload); err != nil {
+		return &ValidationError{Details: s.validator.GetValidationErrors(err)}
+	}
+	if err := s.validator.ValidateMissionSupport(mission.Waylines); err != nil {
+		return &ValidationError{Details: []string{err.Error()}}
+	}
+	if err := s.checkGeofences(ctx, workspaceID, mission); err != nil {
+		return err
+	}
+	return s.checkClearance(mission)
+}
+
+// checkGeofences rejects missions entering the configured no-fly zones or
+// leaving the configured geofences, and those of the workspace's flight
+// areas, listing the offending legs.
+func (s *Service) checkGeofences(ctx context.Context, workspaceID string, mission *wpml.Mission) error {
+	geofences := s.geofences
+	if s.workspaceGeofences != nil {
+		areas, err := s.workspaceGeofences.Geofences(ctx, workspaceID)
+		if err != nil {
+			return fmt.Errorf("failed to get workspace geofences: %w", err)
+		}
+		geofences = append(slices.Clip(geofences), areas...)
+	}
+
+	violations, err := wpml.CheckGeofences(mission, geofences)
+	if err != nil {
+		return &ValidationError{Details: []string{err.Error()}}
+	}
+	if len(violations) > 0 {
+		details := make([]string, len(violations))
+		for i := range violations {
+			details[i] = violations[i].String()
+		}
+		return &ValidationError{Details: details}
+	}
+	return nil
+}
+
+// checkClearance rejects missions passing closer to the terrain than the
+// configured clearance.
+func (s *Service) checkClearance(mission *wpml.Mission) error {
+	if s.terrain == nil || s.terrain.DEM == nil || s.minClearance <= 0 {
+		return nil
+	}
+	violations, err := s.terrain.CheckClearance(mission, s.minClearance)
+	if errors.Is(err, terrain.ErrOutOfCoverage) || errors.Is(err, terrain.ErrNoData) {
+		s.logger.WithError(err).Debug("Skipped terrain clearance check")
+		return nil
+	}
+	if err != nil {
+		return &ValidationError{Details: []string{err.Error()}}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	details := make([]string, len(violations))
+	for i, v := range violations {
+		details[i] = fmt.Sprintf("wayline %d passes %.1f m above terrain after waypoint %d, below the %.1f m minimum",
+			v.WaylineID, v.Clearance, v.WaypointIndex, s.minClearance)
+	}
+	return &ValidationError{Details: details}
+}
+
+// prepare validates the mission, checks the name is free in the owner's
+// workspace and builds the record to persist.
+func (s *Service) prepare(ctx context.Context, owner Owner, name, description string, mission *wpml.Mission, data []byte) (*models.Wayline, error) {
+	if name == "" {
+		return nil, &ValidationError{Details: []string{"name is required"}}
+	}
+	if err := s.Validate(ctx, owner.WorkspaceID, mission); err != nil {
+		return nil, err
+	}
+
+	duplicates, err := s.DuplicateNames(ctx, owner.WorkspaceID, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	if len(duplicates) > 0 {
+		return nil, ErrDuplicateName
+	}
+
+	wayline := describeMission(name, description, mission, data)
+	wayline.WorkspaceID = owner.WorkspaceID
+	wayline.Username = owner.Username
+	return wayline, nil
+}
+
+// describeMission builds the record of a mission's archive, with its KMZ
+// metadata and flight estimate.
+func describeMission(name, description string, mission *wpml.Mission, data []byte) *models.Wayline {
+	wayline := newWaylineRecord(name, description, mission, data)
+	if info, infoErr := wpml.GetKmzInfo(mission); infoErr == nil {
+		if raw, marshalErr := json.Marshal(info); marshalErr == nil {
+			wayline.Metadata = raw
+		}
+	}
+	if estimate, estimateErr := wpml.EstimateMission(mission); estimateErr == nil {
+		wayline.Distance = estimate.Distance
+		wayline.Duration = estimate.Duration
+		wayline.PhotoCount = estimate.Photos
+	}
+	return wayline
+}
+
+// save writes the archive to the object store and records its metadata.
+func (s *Service) save(ctx context.Context, wayline *models.Wayline, data []byte) (*models.Wayline, error) {
+	if err := s.store.Put(ctx, wayline.ObjectKey, bytes.NewReader(data), int64(len(data)), ContentTypeKMZ); err != nil {
+		return nil, fmt.Errorf("failed to store wayline file: %w", err)
+	}
+
+	if err := s.create(ctx, wayline); err != nil {
+		_ = s.store.Delete(ctx, wayline.ObjectKey)
+		return nil, fmt.Errorf("failed to save wayline: %w", err)
+	}
+
+	s.logStored(wayline)
+	return wayline, nil
+}
+
+// create records a new wayline with its first revision. The unique index on
+// the workspace and name catches names taken after prepare checked them.
+func (s *Service) create(ctx context.Context, wayline *models.Wayline) error {
+	wayline.Revision = 1
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(wayline).Error; err != nil {
+			if isUniqueViolation(err) {
+				return ErrDuplicateName
+			}
+			return err
+		}
+		return tx.Create(newRevisionRecord(wayline, wayline.Username, "")).Error
+	})
+}
+
+// AddRevision validates an edited KMZ archive and stores it as the next
+// revision of a wayline, keeping its name, description and favourite flag.
+// The archives of earlier revisions are kept.
+func (s *Service) AddRevision(ctx context.Context, wayline *models.Wayline, owner Owner, data []byte, comment string) (*models.Wayline, error) {
+	mission, err := wpml.ParseKMZBuffer(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKMZ, err)
+	}
+	if err := s.Validate(ctx, wayline.WorkspaceID, mission); err != nil {
+		return nil, err
+	}
+
+	revised := describeMission(wayline.Name, wayline.Description, mission, data)
+	if revised.Fingerprint == wayline.Fingerprint {
+		return nil, ErrRevisionUnchanged
+	}
+	revised.ID = wayline.ID
+	revised.WorkspaceID = wayline.WorkspaceID
+	revised.Username = wayline.Username
+	revised.Favorited = wayline.Favorited
+	revised.CreatedAt = wayline.CreatedAt
+	revised.Revision = wayline.Revision + 1
+
+	if err := s.store.Put(ctx, revised.ObjectKey, bytes.NewReader(data), int64(len(data)), ContentTypeKMZ); err != nil {
+		return nil, fmt.Errorf("failed to store wayline file: %w", err)
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Waylines stored before revisions were kept have no revision records
+		current := newRevisionRecord(wayline, wayline.Username, "")
+		if err := tx.Where(&models.WaylineRevision{WaylineID: wayline.ID, Revision: wayline.Revision}).
+			FirstOrCreate(current).Error; err != nil {
+			return err
+		}
+		result := tx.Model(revised).Where("revision = ?", wayline.Revision).
+			Select("*").Omit("id", "created_at", "deleted_at").Updates(revised)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRevisionConflict
+		}
+		return tx.Create(newRevisionRecord(revised, owner.Username, comment)).Error
+	})
+	if err != nil {
+		_ = s.store.Delete(ctx, revised.ObjectKey)
+		if errors.Is(err, ErrRevisionConflict) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to save wayline revision: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"wayline_id":  revised.ID,
+		"revision":    revised.Revision,
+		"fingerprint": revised.Fingerprint,
+	}).Info("Wayline revised")
+	return revised, nil
+}
+
+// newRevisionRecord records the current archive of a wayline as a revision.
+func newRevisionRecord(wayline *models.Wayline, username, comment string) *models.WaylineRevision {
+	return &models.WaylineRevision{
+		WaylineID:   wayline.ID,
+		Revision:    wayline.Revision,
+		ObjectKey:   wayline.ObjectKey,
+		Fingerprint: wayline.Fingerprint,
+		Size:        wayline.Size,
+		Username:    username,
+		Comment:     comment,
+	}
+}
+
+// Revisions lists the revisions of a wayline, oldest first.
+func (s *Service) Revisions(ctx context.Context, wayline *models.Wayline) ([]models.WaylineRevision, error) {
+	var revisions []models.WaylineRevision
+	if err := s.db.WithContext(ctx).Where("wayline_id = ?", wayline.ID).
+		Order("revision").Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list wayline revisions: %w", err)
+	}
+	if len(revisions) == 0 {
+		revisions = append(revisions, *latestRevision(wayline))
+	}
+	return revisions, nil
+}
+
+// Revision returns a revision of a wayline by number.
+func (s *Service) Revision(ctx context.Context, wayline *models.Wayline, number int) (*models.WaylineRevision, error) {
+	return FindRevision(ctx, s.db, wayline, number)
+}
+
+// FindRevision looks up a revision of a wayline by number, for callers
+// referencing stored waylines without the object store.
+func FindRevision(ctx context.Context, db *gorm.DB, wayline *models.Wayline, number int) (*models.WaylineRevision, error) {
+	var revision models.WaylineRevision
+	err := db.WithContext(ctx).Where("wayline_id = ? AND revision = ?", wayline.ID, number).First(&revision).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound) && number == wayline.Revision:
+		return latestRevision(wayline), nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, ErrRevisionNotFound
+	case err != nil:
+		return nil, fmt.Errorf("failed to get wayline revision: %w", err)
+	}
+	return &revision, nil
+}
+
+// RevisionURL returns a download address of a wayline revision's archive
+// that stays valid for ttl, for handing the archive to a dock.
+func (s *Service) RevisionURL(ctx context.Context, waylineID uint, number int, ttl time.Duration) (string, error) {
+	var w models.Wayline
+	err := s.db.WithContext(ctx).First(&w, waylineID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrWaylineNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get wayline: %w", err)
+	}
+	revision, err := FindRevision(ctx, s.db, &w, number)
+	if err != nil {
+		return "", err
+	}
+
+	presigner, ok := s.store.(objectstore.Presigner)
+	if !ok {
+		return "", ErrPresignUnsupported
+	}
+	address, err := presigner.PresignGet(ctx, revision.ObjectKey, ttl)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign wayline revision: %w", err)
+	}
+	return address, nil
+}
+
+// latestRevision describes the latest archive of a wayline stored before
+// revisions were kept.
+func latestRevision(wayline *models.Wayline) *models.WaylineRevision {
+	revision := newRevisionRecord(wayline, wayline.Username, "")
+	revision.CreatedAt = wayline.UpdatedAt
+	return revision
+}
+
+func (s *Service) logStored(wayline *models.Wayline) {
+	s.logger.WithFields(logrus.Fields{
+		"wayline_id":   wayline.ID,
+		"workspace_id": wayline.WorkspaceID,
+		"name":         wayline.Name,
+		"fingerprint":  wayline.Fingerprint,
+	}).Info("Wayline stored")
+}
+
+func newWaylineRecord(name, description string, mission *wpml.Mission, data []byte) *models.Wayline {
+	// DJI identifies wayline files by the MD5 fingerprint of the archive.
+	sum := md5.Sum(data)
+	cfg := mission.Waylines.Document.MissionConfig
+
+	wayline := &models.Wayline{
+		Name:            name,
+		Description:     description,
+		ObjectKey:       objectPrefix + uuid.New().String() + ".kmz",
+		Fingerprint:     hex.EncodeToString(sum[:]),
+		Size:            int64(len(data)),
+		DroneModel:      cfg.DroneInfo.DroneEnumValue,
+		DroneSubModel:   cfg.DroneInfo.DroneSubEnumValue,
+		PayloadModel:    cfg.PayloadInfo.PayloadEnumValue,
+		PayloadPosition: cfg.PayloadInfo.PayloadPositionIndex,
+	}
+	if mission.Template != nil && len(mission.Template.Document.Folders) > 0 {
+		wayline.TemplateType = string(mission.Template.Document.Folders[0].TemplateType)
+	}
+	for _, folder := range mission.Waylines.Document.Folders {
+		wayline.WaypointCount += len(folder.Placemarks)
+	}
+	return wayline
+}
+
+// DuplicateNames returns the subset of names already used by stored waylines
+// in the workspace.
+func (s *Service) DuplicateNames(ctx context.Context, workspaceID string, names []string) ([]string, error) {
+	duplicates := make([]string, 0)
+	if len(names) == 0 {
+		return duplicates, nil
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Wayline{}).
+		Where("workspace_id = ? AND name IN ?", workspaceID, names).
+		Distinct().
+		Pluck("name", &duplicates).Error; err != nil {
+		return nil, fmt.Errorf("failed to check wayline names: %w", err)
+	}
+	return duplicates, nil
+}
+
+// Open returns a reader for the stored archive of a wayline.
+func (s *Service) Open(ctx context.Context, wayline *models.Wayline) (io.ReadCloser, *objectstore.ObjectInfo, error) {
+	return s.store.Get(ctx, wayline.ObjectKey)
+}
+
+// OpenRevision returns a reader for the stored archive of a wayline revision.
+func (s *Service) OpenRevision(ctx context.Context, revision *models.WaylineRevision) (io.ReadCloser, *objectstore.ObjectInfo, error) {
+	return s.store.Get(ctx, revision.ObjectKey)
+}
+
+// LoadMission reads and parses the stored archive of a wayline.
+func (s *Service) LoadMission(ctx context.Context, wayline *models.Wayline) (*wpml.Mission, error) {
+	return s.loadMission(ctx, wayline.ObjectKey)
+}
+
+// Diff compares two revisions of a wayline at the level of what the
+// aircraft flies.
+func (s *Service) Diff(ctx context.Context, wayline *models.Wayline, from, to int) (*wpml.MissionDiff, error) {
+	missions := make([]*wpml.Mission, 2)
+	for i, number := range []int{from, to} {
+		revision, err := s.Revision(ctx, wayline, number)
+		if err != nil {
+			return nil, err
+		}
+		if missions[i], err = s.loadMission(ctx, revision.ObjectKey); err != nil {
+			return nil, err
+		}
+	}
+	return wpml.DiffMissions(missions[0], missions[1])
+}
+
+// loadMission reads and parses a stored archive.
+func (s *Service) loadMission(ctx context.Context, objectKey string) (*wpml.Mission, error) {
+	rc, _, err := s.store.Get(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wayline file: %w", err)
+	}
+	return wpml.ParseKMZBuffer(data)
+}
+
+// Estimate estimates the length, flight time, photo count and battery use
+// of a stored wayline.
+func (s *Service) Estimate(ctx context.Context, wayline *models.Wayline) (*wpml.Estimate, error) {
+	mission, err := s.LoadMission(ctx, wayline)
+	if err != nil {
+		return nil, err
+	}
+	return wpml.EstimateMission(mission)
+}
+
+// ExportRoute writes a stored wayline as a KML, GeoJSON or GPX route file
+// for review in GIS tools.
+func (s *Service) ExportRoute(ctx context.Context, wayline *models.Wayline, format wpml.RouteFormat) ([]byte, error) {
+	mission, err := s.LoadMission(ctx, wayline)
+	if err != nil {
+		return nil, err
+	}
+	return wpml.ExportRoute(format, mission)
+}
+
+// SetFavorite marks or unmarks the given waylines in a workspace as favourites.
+func (s *Service) SetFavorite(ctx context.Context, workspaceID string, ids []uint, favorite bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Model(&models.Wayline{}).
+		Where("workspace_id = ? AND id IN ?", workspaceID, ids).
+		Update("favorited", favorite).Error
+}
+
+// Delete removes a wayline record, its revisions and their stored archives.
+// Waylines that flight tasks still reference are kept and ErrWaylineInUse is
+// returned, as those tasks dispatch the referenced revision's archive.
+func (s *Service) Delete(ctx context.Context, wayline *models.Wayline) error {
+	objectKeys := []string{wayline.ObjectKey}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var tasks int64
+		if err := tx.Model(&models.FlightTask{}).Where("wayline_id = ?", wayline.ID).Count(&tasks).Error; err != nil {
+			return err
+		}
+		if tasks > 0 {
+			return ErrWaylineInUse
+		}
+		var revisionKeys []string
+		if err := tx.Model(&models.WaylineRevision{}).Where("wayline_id = ? AND object_key <> ?", wayline.ID, wayline.ObjectKey).
+			Pluck("object_key", &revisionKeys).Error; err != nil {
+			return err
+		}
+		objectKeys = append(objectKeys, revisionKeys...)
+		if err := tx.Where("wayline_id = ?", wayline.ID).Delete(&models.WaylineRevision{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(wayline).Error
+	})
+	if errors.Is(err, ErrWaylineInUse) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete wayline: %w", err)
+	}
+	for _, key := range objectKeys {
+		if err := s.store.Delete(ctx, key); err != nil {
+			s.logger.WithError(err).WithField("object_key", key).Warn("Failed to delete wayline file")
+		}
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is a unique index violation, as
+// reported by PostgreSQL or SQLite.
+func isUniqueViolation(err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "unique constraint")
+}
@@ -0,0 +1,439 @@
+package wayline
+
+import (
+	"context"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/adapter/dji/wpml"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/objectstore"
+)
+
+func testWaylines(name string) *wpml.Waylines {
+	return &wpml.Waylines{
+		Name:                    name,
+		DroneModel:              wpml.DroneM3DSeries,
+		PayloadModel:            wpml.PayloadMatrice3DCamera,
+		TemplateType:            wpml.TemplateTypeWaypoint,
+		GlobalHeight:            80,
+		GlobalSpeed:             10,
+		TakeOffSecurityHeight:   50,
+		GlobalRTHHeight:         100,
+		GlobalTransitionalSpeed: 10,
+		Waypoints: []wpml.WaylinesWaypoint{
+			{Latitude: 22.5431, Longitude: 113.9501, Height: 80, Speed: 10, TriggerType: wpml.TriggerTypeReachPoint},
+			{Latitude: 22.5440, Longitude: 113.9510, Height: 80, Speed: 10, TriggerType: wpml.TriggerTypeReachPoint},
+		},
+	}
+}
+
+func setupService(t *testing.T) (*Service, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Wayline{}, &models.WaylineRevision{}, &models.FlightTask{}))
+
+	store, err := objectstore.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	svc, err := NewService(db, store, nil)
+	require.NoError(t, err)
+	return svc, db
+}
+
+func TestService_CreateFromWaylines(t *testing.T) {
+	svc, _ := setupService(t)
+	ctx := context.Background()
+
+	w, err := svc.CreateFromWaylines(ctx, Owner{}, testWaylines("route-a"))
+	require.NoError(t, err)
+	assert.NotZero(t, w.ID)
+	assert.Equal(t, int(wpml.DroneM3DSeries), w.DroneModel)
+	assert.Equal(t, int(wpml.PayloadMatrice3DCamera), w.PayloadModel)
+	assert.Equal(t, 2, w.WaypointCount)
+	assert.Len(t, w.Fingerprint, 32)
+	assert.NotEmpty(t, w.Metadata)
+	assert.InDelta(t, 136, w.Distance, 1)
+	assert.Positive(t, w.Duration)
+
+	mission, err := svc.LoadMission(ctx, w)
+	require.NoError(t, err)
+	require.Len(t, mission.Waylines.Document.Folders, 1)
+	assert.Len(t, mission.Waylines.Document.Folders[0].Placemarks, 2)
+
+	folder := mission.Waylines.Document.Folders[0]
+	require.NotNil(t, folder.Distance)
+	assert.Equal(t, w.Distance, *folder.Distance)
+
+	estimate, err := svc.Estimate(ctx, w)
+	require.NoError(t, err)
+	assert.Equal(t, w.Distance, estimate.Distance)
+	require.NotNil(t, estimate.Battery)
+	assert.True(t, estimate.Battery.Feasible)
+
+	_, err = svc.CreateFromWaylines(ctx, Owner{}, testWaylines("route-a"))
+	assert.ErrorIs(t, err, ErrDuplicateName)
+}
+
+func TestService_CreateFromWaylinesValidation(t *testing.T) {
+	svc, _ := setupService(t)
+
+	invalid := testWaylines("bad")
+	invalid.DroneModel = 1
+	invalid.Waypoints = nil
+
+	_, err := svc.CreateFromWaylines(context.Background(), Owner{}, invalid)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.NotEmpty(t, validationErr.Details)
+
+	// A JSON schema cannot carry the audio file a megaphone plays
+	audio := testWaylines("audio")
+	audio.DroneModel = wpml.DroneM4Series
+	audio.Waypoints[0].Actions = []wpml.ActionRequest{*wpml.NewActionRequest(&wpml.MegaphoneAction{
+		PayloadPositionIndex: wpml.PayloadPosition2,
+		MegaphoneOperateType: "playAudio",
+		MegaphoneFileName:    "warning.wav",
+		MegaphoneFileMD5:     "9e107d9d372bb6826bd81d3542a419d6",
+	})}
+	_, err = svc.CreateFromWaylines(context.Background(), Owner{}, audio)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{wpml.ErrMegaphoneFileMissing.Error() + ": warning.wav"}, validationErr.Details)
+}
+
+// rampDEM is terrain rising 1 m for every 0.00001° east of the first test waypoint.
+type rampDEM struct{}
+
+func (rampDEM) Elevation(_, lon float64) (float64, error) {
+	return math.Max(lon-113.9501, 0) * 1e5, nil
+}
+
+func TestService_Terrain(t *testing.T) {
+	svc, _ := setupService(t)
+	svc.SetTerrain(&wpml.TerrainModel{DEM: rampDEM{}}, 20)
+	ctx := context.Background()
+
+	// The second waypoint is 90 m higher than the take-off point
+	_, err := svc.CreateFromWaylines(ctx, Owner{}, testWaylines("too-low"))
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Contains(t, validationErr.Details[0], "below the 20.0 m minimum")
+
+	agl := testWaylines("above-ground")
+	agl.HeightType = wpml.HeightModeAboveGroundLevel
+	w, err := svc.CreateFromWaylines(ctx, Owner{}, agl)
+	require.NoError(t, err)
+	mission, err := svc.LoadMission(ctx, w)
+	require.NoError(t, err)
+	assert.InDelta(t, 170, *mission.Waylines.Document.Folders[0].Placemarks[1].ExecuteHeight, 1e-6)
+}
+
+func TestService_Geofences(t *testing.T) {
+	svc, _ := setupService(t)
+	ctx := context.Background()
+
+	// A no-fly zone between the two waypoints of the test waylines
+	zone := wpml.Geofence{
+		ID:   "zone",
+		Name: "Stadium",
+		Type: wpml.GeofenceTypeNoFly,
+		Polygon: []wpml.Coordinate{
+			{Longitude: 113.9504, Latitude: 22.5433},
+			{Longitude: 113.9507, Latitude: 22.5433},
+			{Longitude: 113.9507, Latitude: 22.5437},
+			{Longitude: 113.9504, Latitude: 22.5437},
+		},
+	}
+	require.NoError(t, svc.SetGeofences([]wpml.Geofence{zone}))
+
+	_, err := svc.CreateFromWaylines(ctx, Owner{}, testWaylines("crossing"))
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Len(t, validationErr.Details, 1)
+	assert.Contains(t, validationErr.Details[0], `wayline 0 segment 0 enters no-fly zone "Stadium"`)
+
+	// The zone ends below the waylines
+	maxHeight := 60.0
+	zone.MaxHeight = &maxHeight
+	require.NoError(t, svc.SetGeofences([]wpml.Geofence{zone}))
+	_, err = svc.CreateFromWaylines(ctx, Owner{}, testWaylines("over"))
+	require.NoError(t, err)
+
+	zone.Type = "zone"
+	assert.ErrorIs(t, svc.SetGeofences([]wpml.Geofence{zone}), wpml.ErrGeofenceType)
+}
+
+// workspaceZones serves flight areas per workspace.
+type workspaceZones map[string][]wpml.Geofence
+
+func (z workspaceZones) Geofences(_ context.Context, workspaceID string) ([]wpml.Geofence, error) {
+	return z[workspaceID], nil
+}
+
+func TestService_WorkspaceGeofences(t *testing.T) {
+	svc, _ := setupService(t)
+	ctx := context.Background()
+
+	svc.SetWorkspaceGeofences(workspaceZones{"ws1": {{
+		ID:     "tower",
+		Name:   "Tower",
+		Type:   wpml.GeofenceTypeNoFly,
+		Center: &wpml.Coordinate{Longitude: 113.95055, Latitude: 22.54355},
+		Radius: 20,
+	}}})
+
+	_, err := svc.CreateFromWaylines(ctx, Owner{WorkspaceID: "ws1"}, testWaylines("crossing"))
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Contains(t, validationErr.Details[0], `enters no-fly zone "Tower"`)
+
+	// Other workspaces are not bound by the zone
+	w, err := svc.CreateFromWaylines(ctx, Owner{WorkspaceID: "ws2"}, testWaylines("crossing"))
+	require.NoError(t, err)
+
+	// Revisions are checked against the wayline's workspace
+	w.WorkspaceID = "ws1"
+	edited := testWaylines("crossing")
+	edited.GlobalSpeed = 8
+	buffer, err := wpml.CreateKmzBufferFromWaylines(edited)
+	require.NoError(t, err)
+	_, err = svc.AddRevision(ctx, w, Owner{}, buffer.Bytes(), "")
+	require.ErrorAs(t, err, &validationErr)
+}
+
+func TestService_ImportKMZ(t *testing.T) {
+	svc, _ := setupService(t)
+	ctx := context.Background()
+
+	buffer, err := wpml.CreateKmzBufferFromWaylines(testWaylines("imported"))
+	require.NoError(t, err)
+
+	w, err := svc.ImportKMZ(ctx, Owner{}, "imported", "from pilot", buffer.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, int64(buffer.Len()), w.Size)
+	assert.Equal(t, string(wpml.TemplateTypeWaypoint), w.TemplateType)
+
+	rc, info, err := svc.Open(ctx, w)
+	require.NoError(t, err)
+	defer func() { _ = rc.Close() }()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, buffer.Bytes(), data)
+	assert.Equal(t, w.Size, info.Size)
+
+	_, err = svc.ImportKMZ(ctx, Owner{}, "garbage", "", []byte("not a zip"))
+	assert.ErrorIs(t, err, ErrInvalidKMZ)
+}
+
+func TestService_ImportAndExportRoute(t *testing.T) {
+	svc, _ := setupService(t)
+	ctx := context.Background()
+
+	w, err := svc.CreateFromWaylines(ctx, Owner{}, testWaylines("original"))
+	require.NoError(t, err)
+	data, err := svc.ExportRoute(ctx, w, wpml.RouteFormatGPX)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "<rtept")
+
+	opts := &wpml.ImportOptions{Name: "copy", DroneModel: wpml.DroneM3DSeries, PayloadModel: wpml.PayloadMatrice3DCamera}
+	imported, err := svc.ImportRoute(ctx, Owner{}, wpml.RouteFormatGPX, data, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "copy", imported.Name)
+	assert.Equal(t, w.WaypointCount, imported.WaypointCount)
+
+	_, err = svc.ImportRoute(ctx, Owner{}, wpml.RouteFormatGPX, []byte("<gpx/>"), opts)
+	assert.ErrorIs(t, err, ErrInvalidRoute)
+	_, err = svc.ImportRoute(ctx, Owner{}, wpml.RouteFormatGPX, data, &wpml.ImportOptions{})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	_, err = svc.ExportRoute(ctx, w, wpml.RouteFormatLitchiCSV)
+	assert.Error(t, err)
+}
+
+func TestService_ImportKMZRejectsUnsupportedDrone(t *testing.T) {
+	svc, _ := setupService(t)
+
+	mission, err := wpml.ConvertWaylinesToMission(testWaylines("unsupported"))
+	require.NoError(t, err)
+	mission.Waylines.Document.MissionConfig.DroneInfo.DroneEnumValue = 1
+	buffer, err := wpml.CreateKmzBuffer(mission)
+	require.NoError(t, err)
+
+	_, err = svc.ImportKMZ(context.Background(), Owner{}, "unsupported", "", buffer.Bytes())
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Contains(t, validationErr.Details[0], "unsupported drone model")
+}
+
+func TestService_ImportKMZRejectsUnsupportedAction(t *testing.T) {
+	svc, _ := setupService(t)
+
+	waylines := testWaylines("searchlight")
+	waylines.Waypoints[0].Actions = []wpml.ActionRequest{*wpml.NewActionRequest(&wpml.SearchlightAction{SearchlightOperateType: "on"})}
+	waylines.DroneModel = wpml.DroneM4Series
+	mission, err := wpml.ConvertWaylinesToMission(waylines)
+	require.NoError(t, err)
+	// The dock drone has no searchlight accessory
+	mission.Waylines.Document.MissionConfig.DroneInfo.DroneEnumValue = int(wpml.DroneM3DSeries)
+	buffer, err := wpml.CreateKmzBuffer(mission)
+	require.NoError(t, err)
+
+	_, err = svc.ImportKMZ(context.Background(), Owner{}, "searchlight", "", buffer.Bytes())
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{"waypoint 0 action 0: action searchlight is not supported by drone model 91"}, validationErr.Details)
+}
+
+func TestService_DuplicateNamesFavoriteAndDelete(t *testing.T) {
+	svc, db := setupService(t)
+	ctx := context.Background()
+
+	a, err := svc.CreateFromWaylines(ctx, Owner{}, testWaylines("a"))
+	require.NoError(t, err)
+	b, err := svc.CreateFromWaylines(ctx, Owner{}, testWaylines("b"))
+	require.NoError(t, err)
+
+	dups, err := svc.DuplicateNames(ctx, "", []string{"a", "c"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, dups)
+
+	require.NoError(t, svc.SetFavorite(ctx, "", []uint{a.ID, b.ID}, true))
+	var count int64
+	require.NoError(t, db.Model(&models.Wayline{}).Where("favorited = ?", true).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+
+	require.NoError(t, svc.Delete(ctx, a))
+	_, err = svc.Store().Stat(ctx, a.ObjectKey)
+	assert.ErrorIs(t, err, objectstore.ErrNotFound)
+
+	dups, err = svc.DuplicateNames(ctx, "", []string{"a"})
+	require.NoError(t, err)
+	assert.Empty(t, dups)
+}
+
+func TestService_UniqueNameIndex(t *testing.T) {
+	svc, _ := setupService(t)
+	ctx := context.Background()
+
+	w, err := svc.CreateFromWaylines(ctx, Owner{WorkspaceID: "ws1"}, testWaylines("a"))
+	require.NoError(t, err)
+
+	// A concurrent create that passed the name check is rejected by the index.
+	racing := *w
+	racing.ID = 0
+	require.ErrorIs(t, svc.create(ctx, &racing), ErrDuplicateName)
+
+	other := *w
+	other.ID = 0
+	other.WorkspaceID = "ws2"
+	require.NoError(t, svc.create(ctx, &other))
+
+	// Deleted waylines free their name.
+	require.NoError(t, svc.Delete(ctx, w))
+	_, err = svc.CreateFromWaylines(ctx, Owner{WorkspaceID: "ws1"}, testWaylines("a"))
+	require.NoError(t, err)
+}
+
+func TestService_Revisions(t *testing.T) {
+	svc, db := setupService(t)
+	ctx := context.Background()
+
+	w, err := svc.CreateFromWaylines(ctx, Owner{Username: "alice"}, testWaylines("revised"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, w.Revision)
+	require.NoError(t, svc.SetFavorite(ctx, "", []uint{w.ID}, true))
+	w.Favorited = true
+
+	edited := testWaylines("revised")
+	edited.Waypoints[1].Height = 100
+	buffer, err := wpml.CreateKmzBufferFromWaylines(edited)
+	require.NoError(t, err)
+	revised, err := svc.AddRevision(ctx, w, Owner{Username: "bob"}, buffer.Bytes(), "raise the second waypoint")
+	require.NoError(t, err)
+	assert.Equal(t, 2, revised.Revision)
+	assert.Equal(t, w.ID, revised.ID)
+	assert.NotEqual(t, w.Fingerprint, revised.Fingerprint)
+
+	var stored models.Wayline
+	require.NoError(t, db.First(&stored, w.ID).Error)
+	assert.Equal(t, 2, stored.Revision)
+	assert.Equal(t, revised.ObjectKey, stored.ObjectKey)
+	assert.Equal(t, "alice", stored.Username)
+	assert.True(t, stored.Favorited)
+
+	revisions, err := svc.Revisions(ctx, revised)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, w.Fingerprint, revisions[0].Fingerprint)
+	assert.Equal(t, "bob", revisions[1].Username)
+	assert.Equal(t, "raise the second waypoint", revisions[1].Comment)
+
+	diff, err := svc.Diff(ctx, revised, 1, 2)
+	require.NoError(t, err)
+	require.Len(t, diff.Waylines, 1)
+	require.Len(t, diff.Waylines[0].Waypoints, 1)
+	assert.Equal(t, []wpml.FieldChange{{Field: "height", Old: 80.0, New: 100.0}}, diff.Waylines[0].Waypoints[0].Fields)
+
+	// Revising from a stale copy, with an unchanged archive or to a missing revision fails
+	_, err = svc.AddRevision(ctx, w, Owner{}, buffer.Bytes(), "")
+	assert.ErrorIs(t, err, ErrRevisionConflict)
+	_, err = svc.AddRevision(ctx, revised, Owner{}, buffer.Bytes(), "")
+	assert.ErrorIs(t, err, ErrRevisionUnchanged)
+	_, err = svc.Diff(ctx, revised, 1, 3)
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+
+	svc.Store().(*objectstore.LocalStore).SetURLSigner(objectstore.NewURLSigner("https://files.example.com", []byte("secret")))
+	address, err := svc.RevisionURL(ctx, revised.ID, 1, time.Hour)
+	require.NoError(t, err)
+	assert.Contains(t, address, revisions[0].ObjectKey)
+	_, err = svc.RevisionURL(ctx, revised.ID, 3, time.Hour)
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+	_, err = svc.RevisionURL(ctx, revised.ID+1, 1, time.Hour)
+	assert.ErrorIs(t, err, ErrWaylineNotFound)
+
+	// Waylines flown by tasks are kept
+	task := models.FlightTask{Name: "patrol", DockSN: "DOCK1", WaylineID: &revised.ID, WaylineRevision: &revisions[0].Revision}
+	require.NoError(t, db.Create(&task).Error)
+	assert.ErrorIs(t, svc.Delete(ctx, revised), ErrWaylineInUse)
+	_, err = svc.Store().Stat(ctx, revisions[0].ObjectKey)
+	require.NoError(t, err)
+	require.NoError(t, db.Delete(&task).Error)
+
+	require.NoError(t, svc.Delete(ctx, revised))
+	for _, revision := range revisions {
+		_, err = svc.Store().Stat(ctx, revision.ObjectKey)
+		assert.ErrorIs(t, err, objectstore.ErrNotFound)
+	}
+}
+
+func TestService_RevisionsOfLegacyWayline(t *testing.T) {
+	svc, db := setupService(t)
+	ctx := context.Background()
+
+	w, err := svc.CreateFromWaylines(ctx, Owner{}, testWaylines("legacy"))
+	require.NoError(t, err)
+	require.NoError(t, db.Where("wayline_id = ?", w.ID).Delete(&models.WaylineRevision{}).Error)
+
+	revisions, err := svc.Revisions(ctx, w)
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, w.ObjectKey, revisions[0].ObjectKey)
+
+	edited := testWaylines("legacy")
+	edited.GlobalSpeed = 8
+	buffer, err := wpml.CreateKmzBufferFromWaylines(edited)
+	require.NoError(t, err)
+	revised, err := svc.AddRevision(ctx, w, Owner{}, buffer.Bytes(), "")
+	require.NoError(t, err)
+	revisions, err = svc.Revisions(ctx, revised)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, w.ObjectKey, revisions[0].ObjectKey)
+}
@@ -0,0 +1,230 @@
+// Package workspace manages workspaces (tenants) and the devices that
+// belong to them.
+package workspace
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/models"
+)
+
+var (
+	// ErrNotFound is returned when the workspace does not exist.
+	ErrNotFound = errors.New("workspace not found")
+	// ErrExists is returned when creating a workspace with an ID in use.
+	ErrExists = errors.New("workspace already exists")
+	// ErrNotEmpty is returned when deleting a workspace that still has devices.
+	ErrNotEmpty = errors.New("workspace still has devices")
+	// ErrDeviceNotFound is returned when the device does not exist.
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrDeviceNotMember is returned when the device is not in the workspace.
+	ErrDeviceNotMember = errors.New("device is not in the workspace")
+)
+
+// Service manages workspaces and device membership.
+type Service struct {
+	db     *gorm.DB
+	logger *logrus.Entry
+}
+
+// NewService creates a new workspace service.
+func NewService(db *gorm.DB, logger *logrus.Entry) *Service {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	return &Service{
+		db:     db,
+		logger: logger.WithField("component", "workspace"),
+	}
+}
+
+// Create creates a workspace with a fresh binding code. An empty id is
+// replaced by a generated one.
+func (s *Service) Create(ctx context.Context, id, name, description string) (*models.Workspace, error) {
+	if id == "" {
+		id = uuid.New().String()
+	}
+	bindCode, err := NewBindCode()
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Unscoped().Model(&models.Workspace{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check workspace: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrExists
+	}
+
+	w := &models.Workspace{ID: id, Name: name, Description: description, BindCode: bindCode}
+	if err := s.db.WithContext(ctx).Create(w).Error; err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	s.logger.WithField("workspace_id", id).Info("Workspace created")
+	return w, nil
+}
+
+// Get returns a workspace.
+func (s *Service) Get(ctx context.Context, id string) (*models.Workspace, error) {
+	var w models.Workspace
+	err := s.db.WithContext(ctx).Where("id = ?", id).First(&w).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace: %w", err)
+	}
+	return &w, nil
+}
+
+// List returns workspaces ordered by name. Non-empty ids restrict the
+// result to those workspaces.
+func (s *Service) List(ctx context.Context, ids ...string) ([]models.Workspace, error) {
+	query := s.db.WithContext(ctx)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	var workspaces []models.Workspace
+	if err := query.Order("name ASC").Find(&workspaces).Error; err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	return workspaces, nil
+}
+
+// Update changes a workspace's name, description and/or binding code.
+func (s *Service) Update(ctx context.Context, id string, name, description, bindCode *string) (*models.Workspace, error) {
+	w, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if name != nil {
+		w.Name = *name
+	}
+	if description != nil {
+		w.Description = *description
+	}
+	if bindCode != nil {
+		w.BindCode = *bindCode
+	}
+	if err := s.db.WithContext(ctx).Save(w).Error; err != nil {
+		return nil, fmt.Errorf("failed to update workspace: %w", err)
+	}
+	return w, nil
+}
+
+// Delete deletes a workspace without devices.
+func (s *Service) Delete(ctx context.Context, id string) error {
+	w, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Device{}).Where("workspace_id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count workspace devices: %w", err)
+	}
+	if count > 0 {
+		return ErrNotEmpty
+	}
+
+	if err := s.db.WithContext(ctx).Delete(w).Error; err != nil {
+		return fmt.Errorf("failed to delete workspace: %w", err)
+	}
+	s.logger.WithField("workspace_id", id).Info("Workspace deleted")
+	return nil
+}
+
+// Devices returns the devices of a workspace.
+func (s *Service) Devices(ctx context.Context, id string) ([]models.Device, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	var devices []models.Device
+	if err := s.db.WithContext(ctx).Where("workspace_id = ?", id).Order("device_sn ASC").Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("failed to list workspace devices: %w", err)
+	}
+	return devices, nil
+}
+
+// AddDevice moves a device, together with the sub-devices attached to it,
+// into a workspace.
+func (s *Service) AddDevice(ctx context.Context, id, deviceSN string) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	if _, err := s.device(ctx, deviceSN); err != nil {
+		return err
+	}
+	return s.setDeviceWorkspace(ctx, deviceSN, id)
+}
+
+// RemoveDevice takes a device, together with the sub-devices attached to
+// it, out of a workspace.
+func (s *Service) RemoveDevice(ctx context.Context, id, deviceSN string) error {
+	device, err := s.device(ctx, deviceSN)
+	if err != nil {
+		return err
+	}
+	if device.WorkspaceID != id {
+		return ErrDeviceNotMember
+	}
+	return s.setDeviceWorkspace(ctx, deviceSN, "")
+}
+
+// DeviceInWorkspace reports whether the device belongs to the workspace.
+// Unknown devices belong to no workspace.
+func (s *Service) DeviceInWorkspace(ctx context.Context, workspaceID, deviceSN string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.Device{}).
+		Where("device_sn = ? AND workspace_id = ?", deviceSN, workspaceID).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check device workspace: %w", err)
+	}
+	return count > 0, nil
+}
+
+// NewBindCode returns a random six digit binding code, short enough to be
+// typed on a remote controller.
+func NewBindCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate bind code: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func (s *Service) device(ctx context.Context, deviceSN string) (*models.Device, error) {
+	var device models.Device
+	err := s.db.WithContext(ctx).Where("device_sn = ?", deviceSN).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+	return &device, nil
+}
+
+func (s *Service) setDeviceWorkspace(ctx context.Context, deviceSN, workspaceID string) error {
+	err := s.db.WithContext(ctx).Model(&models.Device{}).
+		Where("device_sn = ? OR gateway_sn = ?", deviceSN, deviceSN).
+		Update("workspace_id", workspaceID).Error
+	if err != nil {
+		return fmt.Errorf("failed to update device workspace: %w", err)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"device_sn":    deviceSN,
+		"workspace_id": workspaceID,
+	}).Info("Device workspace changed")
+	return nil
+}
@@ -0,0 +1,98 @@
+package workspace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/pkg/models"
+)
+
+func setupService(t *testing.T) *Service {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Workspace{}, &models.Device{}))
+	return NewService(db, nil)
+}
+
+func TestService_CRUD(t *testing.T) {
+	svc := setupService(t)
+	ctx := context.Background()
+
+	w, err := svc.Create(ctx, "ws1", "Site A", "")
+	require.NoError(t, err)
+	assert.Equal(t, "ws1", w.ID)
+	assert.Len(t, w.BindCode, 6)
+
+	_, err = svc.Create(ctx, "ws1", "Duplicate", "")
+	assert.ErrorIs(t, err, ErrExists)
+
+	generated, err := svc.Create(ctx, "", "Another", "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, generated.ID)
+
+	name := "Site B"
+	code := "246810"
+	w, err = svc.Update(ctx, "ws1", &name, nil, &code)
+	require.NoError(t, err)
+	assert.Equal(t, "Site B", w.Name)
+	assert.Equal(t, "246810", w.BindCode)
+
+	list, err := svc.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "Another", list[0].Name)
+
+	list, err = svc.List(ctx, "ws1")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	require.NoError(t, svc.Delete(ctx, "ws1"))
+	_, err = svc.Get(ctx, "ws1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = svc.Create(ctx, "ws1", "Reused", "")
+	assert.ErrorIs(t, err, ErrExists, "deleted IDs are not reused")
+}
+
+func TestService_Membership(t *testing.T) {
+	svc := setupService(t)
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, "ws1", "Site A", "")
+	require.NoError(t, err)
+	dock := "DOCK1"
+	require.NoError(t, svc.db.Create(&[]models.Device{
+		{DeviceSN: "DOCK1", DeviceName: "Dock", DeviceType: "dock"},
+		{DeviceSN: "UAV1", DeviceName: "UAV", DeviceType: "aircraft", GatewaySN: &dock},
+		{DeviceSN: "UAV2", DeviceName: "UAV", DeviceType: "aircraft"},
+	}).Error)
+
+	assert.ErrorIs(t, svc.AddDevice(ctx, "missing", "DOCK1"), ErrNotFound)
+	assert.ErrorIs(t, svc.AddDevice(ctx, "ws1", "MISSING"), ErrDeviceNotFound)
+
+	require.NoError(t, svc.AddDevice(ctx, "ws1", "DOCK1"))
+	devices, err := svc.Devices(ctx, "ws1")
+	require.NoError(t, err)
+	require.Len(t, devices, 2, "sub-devices follow their gateway")
+
+	ok, err := svc.DeviceInWorkspace(ctx, "ws1", "UAV1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = svc.DeviceInWorkspace(ctx, "ws1", "UAV2")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.ErrorIs(t, svc.Delete(ctx, "ws1"), ErrNotEmpty)
+	assert.ErrorIs(t, svc.RemoveDevice(ctx, "ws1", "UAV2"), ErrDeviceNotMember)
+
+	require.NoError(t, svc.RemoveDevice(ctx, "ws1", "DOCK1"))
+	devices, err = svc.Devices(ctx, "ws1")
+	require.NoError(t, err)
+	assert.Empty(t, devices)
+	require.NoError(t, svc.Delete(ctx, "ws1"))
+}
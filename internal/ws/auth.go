@@ -0,0 +1,219 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/account"
+	"github.com/utmos/utmos/internal/ws/hub"
+)
+
+// DefaultAuthRecheckInterval is how often the credentials of connected
+// clients are checked for revocation
+const DefaultAuthRecheckInterval = 30 * time.Second
+
+// BearerProtocol is the WebSocket subprotocol browser clients offer, together
+// with their token, to authenticate without a query parameter:
+//
+//	Sec-WebSocket-Protocol: bearer, <token>
+const BearerProtocol = "bearer"
+
+// Query parameters a token may be passed in on the upgrade request. DJI
+// Pilot 2 names its session token x-auth-token, which it may also send as a
+// header.
+const (
+	tokenQueryParam      = "token"
+	pilotTokenQueryParam = "x-auth-token"
+	pilotTokenHeader     = "x-auth-token"
+)
+
+// Close reasons sent to clients disconnected by the server
+const (
+	closeReasonExpired = "token expired"
+	closeReasonRevoked = "credentials revoked"
+)
+
+// Authenticator resolves access tokens and API keys to identities.
+// account.Service implements it; middleware.Authenticators also accepts
+// static Pilot tokens.
+type Authenticator interface {
+	Authenticate(ctx context.Context, credential string) (*account.Identity, error)
+}
+
+// clientAuth is the credential a client connected with
+type clientAuth struct {
+	client     *hub.Client
+	expiry     *time.Timer
+	credential string
+}
+
+// SetAuthenticator requires clients to authenticate on connect. It must be
+// called before Start. Authenticated clients are confined to the workspace
+// of their identity and are disconnected when their token expires or is
+// revoked.
+func (s *Service) SetAuthenticator(authenticator Authenticator) {
+	s.authenticator = authenticator
+}
+
+// credentialFromRequest returns the credential of an upgrade request and the
+// subprotocol to accept, if the credential was offered as one
+func credentialFromRequest(r *http.Request) (credential, protocol string) {
+	protocols := websocket.Subprotocols(r)
+	for i, p := range protocols {
+		if p == BearerProtocol && i+1 < len(protocols) {
+			return protocols[i+1], BearerProtocol
+		}
+	}
+
+	query := r.URL.Query()
+	if token := query.Get(tokenQueryParam); token != "" {
+		return token, ""
+	}
+	if token := query.Get(pilotTokenQueryParam); token != "" {
+		return token, ""
+	}
+	if token := r.Header.Get(pilotTokenHeader); token != "" {
+		return token, ""
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token, ""
+	}
+	return "", ""
+}
+
+// isPilotRequest reports whether an upgrade request comes from DJI Pilot 2,
+// which authenticates with its x-auth-token
+func isPilotRequest(r *http.Request) bool {
+	return r.URL.Query().Get(pilotTokenQueryParam) != "" || r.Header.Get(pilotTokenHeader) != ""
+}
+
+// authenticateUpgrade authenticates an upgrade request. It writes an error
+// response and returns false when the request is rejected.
+func (s *Service) authenticateUpgrade(w http.ResponseWriter, r *http.Request) (*account.Identity, string, http.Header, bool) {
+	credential, protocol := credentialFromRequest(r)
+	if credential == "" {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return nil, "", nil, false
+	}
+
+	identity, err := s.authenticator.Authenticate(r.Context(), credential)
+	switch {
+	case errors.Is(err, account.ErrTokenExpired):
+		http.Error(w, "Access token has expired", http.StatusUnauthorized)
+		return nil, "", nil, false
+	case errors.Is(err, account.ErrInvalidCredentials), errors.Is(err, account.ErrInvalidToken):
+		s.logger.WithField("remote_addr", r.RemoteAddr).Warn("Rejected WebSocket connection with invalid credentials")
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return nil, "", nil, false
+	case err != nil:
+		s.logger.WithError(err).Error("Failed to authenticate WebSocket connection")
+		http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+		return nil, "", nil, false
+	}
+
+	if !identity.Can(account.PermissionRead) {
+		http.Error(w, "Permission required: "+string(account.PermissionRead), http.StatusForbidden)
+		return nil, "", nil, false
+	}
+	workspaceID := r.URL.Query().Get("workspace_id")
+	if identity.WorkspaceID != "" && workspaceID != "" && workspaceID != identity.WorkspaceID {
+		http.Error(w, "Workspace not accessible", http.StatusForbidden)
+		return nil, "", nil, false
+	}
+
+	var header http.Header
+	if protocol != "" {
+		header = http.Header{"Sec-Websocket-Protocol": {protocol}}
+	}
+	return identity, credential, header, true
+}
+
+// trackClient remembers the identity of an authenticated client and
+// schedules its disconnect when the credential expires
+func (s *Service) trackClient(client *hub.Client, identity *account.Identity, credential string) {
+	auth := &clientAuth{client: client, credential: credential}
+	if identity.ExpiresAt != nil {
+		auth.expiry = time.AfterFunc(time.Until(*identity.ExpiresAt), func() {
+			s.disconnect(client, closeReasonExpired)
+		})
+	}
+
+	s.authMu.Lock()
+	s.auths[client.ID] = auth
+	s.authMu.Unlock()
+}
+
+// untrackClient forgets the identity of a disconnected client
+func (s *Service) untrackClient(clientID string) {
+	s.authMu.Lock()
+	auth, ok := s.auths[clientID]
+	delete(s.auths, clientID)
+	s.authMu.Unlock()
+
+	if ok && auth.expiry != nil {
+		auth.expiry.Stop()
+	}
+}
+
+// disconnect closes a client's connection, telling it why
+func (s *Service) disconnect(client *hub.Client, reason string) {
+	s.logger.WithFields(logrus.Fields{
+		"client_id": client.ID,
+		"user_id":   client.UserID,
+		"reason":    reason,
+	}).Info("Disconnecting WebSocket client")
+	client.Disconnect(websocket.ClosePolicyViolation, reason)
+}
+
+// recheckAuth periodically re-authenticates connected clients so that
+// revoked tokens, signed-out users and revoked API keys lose their
+// connections
+func (s *Service) recheckAuth(ctx context.Context) {
+	interval := s.config.AuthRecheckInterval
+	if interval <= 0 {
+		interval = DefaultAuthRecheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.recheckClients(ctx)
+		}
+	}
+}
+
+// recheckClients re-authenticates every authenticated client once
+func (s *Service) recheckClients(ctx context.Context) {
+	s.authMu.Lock()
+	auths := make([]*clientAuth, 0, len(s.auths))
+	for _, auth := range s.auths {
+		auths = append(auths, auth)
+	}
+	s.authMu.Unlock()
+
+	for _, auth := range auths {
+		_, err := s.authenticator.Authenticate(ctx, auth.credential)
+		switch {
+		case err == nil:
+		case errors.Is(err, account.ErrTokenExpired):
+			s.disconnect(auth.client, closeReasonExpired)
+		case errors.Is(err, account.ErrInvalidCredentials), errors.Is(err, account.ErrInvalidToken):
+			s.disconnect(auth.client, closeReasonRevoked)
+		default:
+			// Keep the connection when the account store is unreachable
+			s.logger.WithError(err).WithField("client_id", auth.client.ID).Warn("Failed to recheck WebSocket client credentials")
+		}
+	}
+}
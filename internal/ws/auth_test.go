@@ -0,0 +1,220 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/utmos/utmos/internal/account"
+	"github.com/utmos/utmos/internal/api/middleware"
+)
+
+// staticAuthenticator resolves credentials from a map that tests may revoke
+// entries from
+type staticAuthenticator struct {
+	identities map[string]*account.Identity
+	mu         sync.Mutex
+}
+
+func (a *staticAuthenticator) Authenticate(_ context.Context, credential string) (*account.Identity, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	identity, ok := a.identities[credential]
+	if !ok {
+		return nil, account.ErrInvalidCredentials
+	}
+	if identity.ExpiresAt != nil && !time.Now().Before(*identity.ExpiresAt) {
+		return nil, account.ErrTokenExpired
+	}
+	return identity, nil
+}
+
+func (a *staticAuthenticator) revoke(credential string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.identities, credential)
+}
+
+func setupAuthService(t *testing.T, identities map[string]*account.Identity) (*Service, *staticAuthenticator, string) {
+	authenticator := &staticAuthenticator{identities: identities}
+	svc := NewService(nil, nil, nil)
+	svc.SetAuthenticator(authenticator)
+	require.NoError(t, svc.Start(context.Background()))
+	t.Cleanup(func() { _ = svc.Stop() })
+
+	server := httptest.NewServer(http.HandlerFunc(svc.HandleWebSocket))
+	t.Cleanup(server.Close)
+	return svc, authenticator, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestCredentialFromRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/ws?token=t1", nil)
+	credential, protocol := credentialFromRequest(r)
+	assert.Equal(t, "t1", credential)
+	assert.Empty(t, protocol)
+
+	r = httptest.NewRequest("GET", "/ws?x-auth-token=t2", nil)
+	credential, _ = credentialFromRequest(r)
+	assert.Equal(t, "t2", credential)
+
+	r = httptest.NewRequest("GET", "/ws?token=t1", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "bearer, t3")
+	credential, protocol = credentialFromRequest(r)
+	assert.Equal(t, "t3", credential)
+	assert.Equal(t, BearerProtocol, protocol)
+
+	r = httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("x-auth-token", "t5")
+	credential, _ = credentialFromRequest(r)
+	assert.Equal(t, "t5", credential)
+
+	r = httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Authorization", "Bearer t4")
+	credential, _ = credentialFromRequest(r)
+	assert.Equal(t, "t4", credential)
+
+	credential, _ = credentialFromRequest(httptest.NewRequest("GET", "/ws", nil))
+	assert.Empty(t, credential)
+}
+
+func TestService_HandleWebSocket_Auth(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	svc, _, wsURL := setupAuthService(t, map[string]*account.Identity{
+		"viewer": {Subject: "user:1", Role: account.RoleViewer, WorkspaceID: "ws1"},
+	})
+
+	t.Run("missing credential", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid credential", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL+"?token=nope", nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("other workspace", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL+"?token=viewer&workspace_id=ws2", nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("subprotocol", func(t *testing.T) {
+		dialer := websocket.Dialer{Subprotocols: []string{BearerProtocol, "viewer"}}
+		conn, _, err := dialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		assert.Equal(t, BearerProtocol, conn.Subprotocol())
+
+		// The client joins the workspace of its identity
+		require.Eventually(t, func() bool {
+			return svc.SubscriptionManager().GetSubscriberCount(WorkspaceTopic("ws1")) == 1
+		}, time.Second, 10*time.Millisecond)
+		clients := svc.Hub().GetClients()
+		require.Len(t, clients, 1)
+		assert.Equal(t, "user:1", clients[0].UserID)
+		assert.Equal(t, "ws1", clients[0].Metadata[MetadataWorkspaceID])
+	})
+}
+
+func TestService_PilotTokens(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	svc := NewService(nil, nil, nil)
+	svc.SetAuthenticator(middleware.Authenticators{
+		middleware.StaticPilotTokens{"pilot-token": {WorkspaceID: "ws1", Username: "pilot"}},
+		&staticAuthenticator{identities: map[string]*account.Identity{
+			"viewer": {Subject: "user:1", Role: account.RoleViewer, WorkspaceID: "ws2"},
+		}},
+	})
+	require.NoError(t, svc.Start(context.Background()))
+	t.Cleanup(func() { _ = svc.Stop() })
+	server := httptest.NewServer(http.HandlerFunc(svc.HandleWebSocket))
+	t.Cleanup(server.Close)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL+"?x-auth-token=nope", nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	for _, token := range []string{"pilot-token", "viewer"} {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?x-auth-token="+token, nil)
+		require.NoError(t, err, token)
+		defer func() { _ = conn.Close() }()
+	}
+	require.Eventually(t, func() bool {
+		return svc.SubscriptionManager().GetSubscriberCount(WorkspaceTopic("ws1")) == 1 &&
+			svc.SubscriptionManager().GetSubscriberCount(WorkspaceTopic("ws2")) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestService_DisconnectRevoked(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	svc, authenticator, wsURL := setupAuthService(t, map[string]*account.Identity{
+		"admin": {Subject: "user:1", Role: account.RoleAdmin},
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?token=admin", nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	require.Eventually(t, func() bool { return svc.Hub().GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	// Valid credentials keep their connection
+	svc.recheckClients(context.Background())
+	assert.Equal(t, 1, svc.Hub().GetClientCount())
+
+	authenticator.revoke("admin")
+	svc.recheckClients(context.Background())
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+	assert.Equal(t, closeReasonRevoked, closeErr.Text)
+	require.Eventually(t, func() bool { return svc.Hub().GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestService_DisconnectExpired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	expiresAt := time.Now().Add(200 * time.Millisecond)
+	svc, _, wsURL := setupAuthService(t, map[string]*account.Identity{
+		"pilot": {Subject: "user:2", Role: account.RolePilot, ExpiresAt: &expiresAt},
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?token=pilot", nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, closeReasonExpired, closeErr.Text)
+	require.Eventually(t, func() bool {
+		svc.authMu.Lock()
+		defer svc.authMu.Unlock()
+		return len(svc.auths) == 0
+	}, time.Second, 10*time.Millisecond)
+}
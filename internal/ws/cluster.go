@@ -0,0 +1,152 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+// Replicas of iot-ws each consume every message for WebSocket clients from
+// a queue of their own and push it to their local subscribers. They share
+// their statistics through the same exchange.
+const (
+	// RoutingKeyPattern binds replica queues to the messages for WebSocket
+	// clients
+	RoutingKeyPattern = "iot.ws.#"
+	// RoutingKeyClusterStats is the routing key of replica statistics
+	RoutingKeyClusterStats = "iot.ws.cluster.stats"
+	// QueuePrefix prefixes the queue name of each replica
+	QueuePrefix = "iot.ws.queue."
+	// DefaultStatsInterval is how often replicas share their statistics
+	DefaultStatsInterval = 10 * time.Second
+
+	// actionClusterStats is the action of replica statistics messages
+	actionClusterStats = "ws.cluster.stats"
+	// staleStatsIntervals is how many intervals without statistics a replica
+	// is considered gone after
+	staleStatsIntervals = 3
+)
+
+// QueueName returns the name of the queue a replica consumes
+func QueueName(instanceID string) string {
+	return QueuePrefix + instanceID
+}
+
+// NodeStats holds the statistics of one replica
+type NodeStats struct {
+	InstanceID string    `json:"instance_id"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	ServiceStats
+}
+
+// ClusterStats holds the statistics of every live replica and their sum.
+// Topics subscribed on several replicas are counted once per replica.
+type ClusterStats struct {
+	Nodes []NodeStats  `json:"nodes"`
+	Total ServiceStats `json:"total"`
+}
+
+// SetPublisher sets the RabbitMQ publisher used to share statistics with
+// the other replicas. Without one, cluster statistics only cover this
+// replica.
+func (s *Service) SetPublisher(publisher *rabbitmq.Publisher) {
+	s.publisher = publisher
+}
+
+// InstanceID returns the ID of this replica
+func (s *Service) InstanceID() string {
+	return s.instanceID
+}
+
+// statsInterval returns how often statistics are shared
+func (s *Service) statsInterval() time.Duration {
+	if s.config.StatsInterval > 0 {
+		return s.config.StatsInterval
+	}
+	return DefaultStatsInterval
+}
+
+// shareStats publishes this replica's statistics until the service stops
+func (s *Service) shareStats(ctx context.Context) {
+	ticker := time.NewTicker(s.statsInterval())
+	defer ticker.Stop()
+
+	for {
+		s.publishStats(ctx)
+		select {
+		case <-s.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishStats publishes this replica's statistics once
+func (s *Service) publishStats(ctx context.Context) {
+	stats := NodeStats{InstanceID: s.instanceID, UpdatedAt: time.Now(), ServiceStats: s.GetStats()}
+	msg, err := rabbitmq.NewStandardMessage("iot-ws", actionClusterStats, "", stats)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to create cluster stats message")
+		return
+	}
+	if err := s.publisher.Publish(ctx, RoutingKeyClusterStats, msg); err != nil {
+		s.logger.WithError(err).Debug("Failed to publish cluster stats")
+	}
+}
+
+// recordNodeStats keeps the statistics another replica shared
+func (s *Service) recordNodeStats(msg *rabbitmq.StandardMessage) {
+	var stats NodeStats
+	if err := json.Unmarshal(msg.Data, &stats); err != nil || stats.InstanceID == "" {
+		s.logger.WithError(err).Warn("Ignoring invalid cluster stats message")
+		return
+	}
+	if stats.InstanceID == s.instanceID {
+		return
+	}
+	// Replica clocks may differ, so staleness is judged by arrival
+	stats.UpdatedAt = time.Now()
+
+	s.nodesMu.Lock()
+	defer s.nodesMu.Unlock()
+	s.nodes[stats.InstanceID] = stats
+}
+
+// GetClusterStats returns the statistics of every replica that shared them
+// recently, including this one, and their sum
+func (s *Service) GetClusterStats() ClusterStats {
+	now := time.Now()
+	nodes := []NodeStats{{InstanceID: s.instanceID, UpdatedAt: now, ServiceStats: s.GetStats()}}
+
+	staleBefore := now.Add(-staleStatsIntervals * s.statsInterval())
+	s.nodesMu.Lock()
+	for id, node := range s.nodes {
+		if node.UpdatedAt.Before(staleBefore) {
+			delete(s.nodes, id)
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	s.nodesMu.Unlock()
+
+	slices.SortFunc(nodes, func(a, b NodeStats) int {
+		return strings.Compare(a.InstanceID, b.InstanceID)
+	})
+
+	var total ServiceStats
+	for _, node := range nodes {
+		total.ConnectedClients += node.ConnectedClients
+		total.ActiveTopics += node.ActiveTopics
+		total.MessagesPushed += node.MessagesPushed
+		total.MessagesDropped += node.MessagesDropped
+		total.MessagesCoalesced += node.MessagesCoalesced
+		total.QueueLength += node.QueueLength
+	}
+	return ClusterStats{Nodes: nodes, Total: total}
+}
@@ -0,0 +1,72 @@
+package ws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+func TestQueueName(t *testing.T) {
+	assert.Equal(t, "iot.ws.queue.ws-1", QueueName("ws-1"))
+}
+
+func TestNewService_InstanceID(t *testing.T) {
+	config := DefaultServiceConfig()
+	config.InstanceID = "ws-1"
+	assert.Equal(t, "ws-1", NewService(config, nil, nil).InstanceID())
+
+	a, b := NewService(nil, nil, nil), NewService(nil, nil, nil)
+	assert.NotEmpty(t, a.InstanceID())
+	assert.NotEqual(t, a.InstanceID(), b.InstanceID())
+}
+
+func statsMessage(t *testing.T, stats NodeStats) *rabbitmq.StandardMessage {
+	msg, err := rabbitmq.NewStandardMessage("iot-ws", actionClusterStats, "", stats)
+	require.NoError(t, err)
+	return msg
+}
+
+func TestService_ClusterStats(t *testing.T) {
+	config := DefaultServiceConfig()
+	config.InstanceID = "ws-1"
+	config.StatsInterval = time.Minute
+	svc := NewService(config, nil, nil)
+	require.NoError(t, svc.Start(context.Background()))
+	defer func() { _ = svc.Stop() }()
+
+	svc.handleRabbitMQMessage(context.Background(), statsMessage(t, NodeStats{
+		InstanceID:   "ws-2",
+		ServiceStats: ServiceStats{ConnectedClients: 3, MessagesPushed: 10},
+	}))
+	svc.handleRabbitMQMessage(context.Background(), statsMessage(t, NodeStats{
+		InstanceID:   "ws-1",
+		ServiceStats: ServiceStats{ConnectedClients: 100},
+	}))
+
+	stats := svc.GetClusterStats()
+	require.Len(t, stats.Nodes, 2)
+	assert.Equal(t, "ws-1", stats.Nodes[0].InstanceID)
+	assert.Zero(t, stats.Nodes[0].ConnectedClients, "a replica reports its own live statistics")
+	assert.Equal(t, "ws-2", stats.Nodes[1].InstanceID)
+	assert.Equal(t, 3, stats.Total.ConnectedClients)
+	assert.Equal(t, int64(10), stats.Total.MessagesPushed)
+
+	assert.Zero(t, svc.Pusher().History().LastSeq(svc.actionToTopic(actionClusterStats)), "statistics are not pushed to clients")
+
+	t.Run("forgets silent replicas", func(t *testing.T) {
+		svc.nodesMu.Lock()
+		node := svc.nodes["ws-2"]
+		node.UpdatedAt = time.Now().Add(-staleStatsIntervals * time.Minute)
+		svc.nodes["ws-2"] = node
+		svc.nodesMu.Unlock()
+
+		stats := svc.GetClusterStats()
+		require.Len(t, stats.Nodes, 1)
+		assert.Equal(t, "ws-1", stats.Nodes[0].InstanceID)
+	})
+}
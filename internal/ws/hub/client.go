@@ -55,6 +55,9 @@ type Client struct {
 	UserID   string
 	// Metadata stores client-specific key-value data
 	Metadata Metadata
+	// Frame is the envelope messages are written in. It must be set before
+	// Start.
+	Frame Frame
 
 	hub    *Hub
 	conn   *websocket.Conn
@@ -131,6 +134,21 @@ func (c *Client) Close() {
 	}
 }
 
+// Disconnect sends a close frame with code and reason, then unregisters the
+// client and closes its connection
+func (c *Client) Disconnect(code int, reason string) {
+	if c.conn != nil && !c.IsClosed() {
+		deadline := time.Now().Add(c.config.WriteWait)
+		if err := c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline); err != nil {
+			c.logger.WithError(err).Debug("Failed to send close message")
+		}
+	}
+	if c.hub != nil {
+		c.hub.Unregister(c)
+	}
+	c.Close()
+}
+
 // IsClosed returns whether the client is closed
 func (c *Client) IsClosed() bool {
 	c.closedMu.RLock()
@@ -273,7 +291,7 @@ func (c *Client) writePump() {
 				return
 			}
 
-			data, err := json.Marshal(msg)
+			data, err := c.encode(msg)
 			if err != nil {
 				c.logger.WithError(err).Error("Failed to marshal message")
 				continue
@@ -57,6 +57,14 @@ func TestClient_CloseAndIsClosed(t *testing.T) {
 	assert.True(t, client.IsClosed())
 }
 
+func TestClient_Disconnect(t *testing.T) {
+	h := NewHub(nil, nil)
+	client := NewClient("test-id", nil, h, nil, nil)
+
+	client.Disconnect(websocket.ClosePolicyViolation, "token expired")
+	assert.True(t, client.IsClosed())
+}
+
 func TestClient_Send(t *testing.T) {
 	client := NewClient("test-id", nil, nil, nil, nil)
 
@@ -0,0 +1,47 @@
+package hub
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DJIVersion is the protocol version of DJI Pilot 2 pushes
+const DJIVersion = "1.0"
+
+// Frame is the envelope messages are written to a client in
+type Frame int
+
+const (
+	// FrameHub writes messages as they are, the default
+	FrameHub Frame = iota
+	// FrameDJI writes events in the DJI Pilot 2 push envelope, with the
+	// event as biz_code. Other messages are written as they are.
+	FrameDJI
+)
+
+// DJIMessage is the envelope of server pushes to DJI Pilot 2
+type DJIMessage struct {
+	BizCode string `json:"biz_code"`
+	Version string `json:"version"`
+	// Timestamp is in Unix milliseconds
+	Timestamp int64 `json:"timestamp"`
+	Data      any   `json:"data"`
+}
+
+// encode marshals msg in the client's frame
+func (c *Client) encode(msg *Message) ([]byte, error) {
+	if c.Frame != FrameDJI || msg.Type != MessageTypeEvent {
+		return json.Marshal(msg)
+	}
+
+	timestamp := msg.Timestamp
+	if timestamp == 0 {
+		timestamp = time.Now().UnixMilli()
+	}
+	return json.Marshal(DJIMessage{
+		BizCode:   msg.Event,
+		Version:   DJIVersion,
+		Timestamp: timestamp,
+		Data:      msg.Data,
+	})
+}
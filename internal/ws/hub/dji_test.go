@@ -0,0 +1,37 @@
+package hub
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_EncodeDJIFrame(t *testing.T) {
+	client := NewClient("pilot", nil, nil, nil, nil)
+	client.Frame = FrameDJI
+
+	data, err := client.encode(&Message{
+		Type:      MessageTypeEvent,
+		Event:     "map_element_delete",
+		Data:      map[string]string{"id": "e1"},
+		Topic:     "workspace.ws1",
+		Seq:       3,
+		Timestamp: 146052438362,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"biz_code":"map_element_delete","version":"1.0","timestamp":146052438362,"data":{"id":"e1"}}`, string(data))
+
+	// Replies to the client are not pushes
+	data, err = client.encode(&Message{Type: MessageTypePong})
+	require.NoError(t, err)
+	var msg Message
+	require.NoError(t, json.Unmarshal(data, &msg))
+	assert.Equal(t, MessageTypePong, msg.Type)
+
+	client.Frame = FrameHub
+	data, err = client.encode(&Message{Type: MessageTypeEvent, Event: "map_element_delete"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"event","event":"map_element_delete"}`, string(data))
+}
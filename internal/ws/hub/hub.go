@@ -91,6 +91,16 @@ type Message struct {
 	Data    any    `json:"data,omitempty"`
 	Error   string `json:"error,omitempty"`
 	TraceID string `json:"trace_id,omitempty"`
+	// Topic and Seq identify messages pushed to topic subscribers. Seq
+	// increases with every message of the topic, so clients can resume
+	// after the last message they received.
+	Topic string `json:"topic,omitempty"`
+	Seq   uint64 `json:"seq,omitempty"`
+	// Epoch names the numbering Seq belongs to. It changes whenever the
+	// sequence restarts, e.g. on a service restart or another instance.
+	Epoch string `json:"epoch,omitempty"`
+	// Timestamp is when the event occurred, in Unix milliseconds
+	Timestamp int64 `json:"timestamp,omitempty"`
 }
 
 // MessageType represents the type of WebSocket message
@@ -111,6 +121,8 @@ const (
 	MessageTypeError MessageType = "error"
 	// MessageTypeAck is the ack message type.
 	MessageTypeAck MessageType = "ack"
+	// MessageTypeResume is the resume message type.
+	MessageTypeResume MessageType = "resume"
 )
 
 // NewHub creates a new WebSocket hub
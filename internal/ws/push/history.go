@@ -0,0 +1,133 @@
+package push
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/utmos/utmos/internal/ws/hub"
+)
+
+// History configuration defaults
+const (
+	// DefaultHistorySize is the default number of messages kept per topic
+	DefaultHistorySize = 64
+	// DefaultHistoryTopics is the default number of topics with history;
+	// the least recently pushed topics are forgotten first
+	DefaultHistoryTopics = 1024
+)
+
+// History keeps the latest messages pushed to each topic, numbered with a
+// sequence per topic, so reconnecting clients can replay what they missed.
+// Sequences restart when a topic is forgotten or the service restarts, and
+// each numbering has its own epoch, so a seq from another numbering is
+// never mistaken for one of the current.
+type History struct {
+	topics    map[string]*topicHistory
+	recent    *list.List
+	size      int
+	maxTopics int
+	mu        sync.Mutex
+}
+
+// topicHistory is a ring buffer of a topic's latest messages
+type topicHistory struct {
+	messages []*hub.Message
+	element  *list.Element
+	epoch    string
+	seq      uint64
+	count    int
+}
+
+// NewHistory creates a history keeping size messages for each of up to
+// maxTopics topics
+func NewHistory(size, maxTopics int) *History {
+	return &History{
+		topics:    make(map[string]*topicHistory),
+		recent:    list.New(),
+		size:      size,
+		maxTopics: maxTopics,
+	}
+}
+
+// Append records a message pushed to topic and returns a copy carrying the
+// topic, its sequence number and epoch
+func (h *History) Append(topic string, msg *hub.Message) *hub.Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	th, ok := h.topics[topic]
+	if ok {
+		h.recent.MoveToFront(th.element)
+	} else {
+		if len(h.topics) >= h.maxTopics {
+			h.evictOldest()
+		}
+		th = &topicHistory{
+			messages: make([]*hub.Message, h.size),
+			element:  h.recent.PushFront(topic),
+			epoch:    uuid.NewString(),
+		}
+		h.topics[topic] = th
+	}
+
+	th.seq++
+	numbered := *msg
+	numbered.Topic = topic
+	numbered.Seq = th.seq
+	numbered.Epoch = th.epoch
+	th.messages[int((th.seq-1)%uint64(h.size))] = &numbered
+	th.count = min(th.count+1, h.size)
+	return &numbered
+}
+
+// evictOldest forgets the least recently pushed topic
+func (h *History) evictOldest() {
+	oldest := h.recent.Back()
+	if oldest == nil {
+		return
+	}
+	h.recent.Remove(oldest)
+	if topic, ok := oldest.Value.(string); ok {
+		delete(h.topics, topic)
+	}
+}
+
+// Since returns the messages of topic after lastSeq of epoch, oldest
+// first. It returns false when messages after lastSeq are no longer kept or
+// epoch is not the topic's current one, in which case every kept message is
+// returned. The epoch is ignored when lastSeq is zero.
+func (h *History) Since(topic, epoch string, lastSeq uint64) ([]*hub.Message, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	th, ok := h.topics[topic]
+	if !ok {
+		return nil, lastSeq == 0
+	}
+
+	oldest := th.seq - uint64(th.count) + 1
+	complete := (lastSeq == 0 || epoch == th.epoch) && lastSeq <= th.seq && lastSeq+1 >= oldest
+	from := oldest
+	if complete {
+		from = lastSeq + 1
+	}
+
+	messages := make([]*hub.Message, 0, th.seq-from+1)
+	for seq := from; seq <= th.seq; seq++ {
+		messages = append(messages, th.messages[int((seq-1)%uint64(h.size))])
+	}
+	return messages, complete
+}
+
+// LastSeq returns the sequence number of the latest message of topic
+func (h *History) LastSeq(topic string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if th, ok := h.topics[topic]; ok {
+		return th.seq
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+package push
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/utmos/utmos/internal/ws/hub"
+)
+
+func seqs(messages []*hub.Message) []uint64 {
+	out := make([]uint64, 0, len(messages))
+	for _, m := range messages {
+		out = append(out, m.Seq)
+	}
+	return out
+}
+
+func TestHistory_Append(t *testing.T) {
+	history := NewHistory(3, 10)
+
+	original := &hub.Message{Type: hub.MessageTypeEvent, Event: "osd"}
+	numbered := history.Append("device.UAV1.osd", original)
+	assert.Equal(t, uint64(1), numbered.Seq)
+	assert.Equal(t, "device.UAV1.osd", numbered.Topic)
+	assert.Zero(t, original.Seq, "the pushed message is not modified")
+
+	assert.Equal(t, uint64(2), history.Append("device.UAV1.osd", original).Seq)
+	assert.Equal(t, uint64(1), history.Append("device.UAV2.osd", original).Seq, "topics are numbered separately")
+	assert.Equal(t, uint64(2), history.LastSeq("device.UAV1.osd"))
+	assert.Zero(t, history.LastSeq("unknown"))
+}
+
+func TestHistory_Since(t *testing.T) {
+	history := NewHistory(3, 10)
+	var epoch string
+	for i := 0; i < 5; i++ {
+		epoch = history.Append("topic", &hub.Message{Type: hub.MessageTypeEvent}).Epoch
+	}
+	require.NotEmpty(t, epoch)
+
+	t.Run("kept", func(t *testing.T) {
+		messages, complete := history.Since("topic", epoch, 3)
+		assert.True(t, complete)
+		assert.Equal(t, []uint64{4, 5}, seqs(messages))
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		messages, complete := history.Since("topic", epoch, 5)
+		assert.True(t, complete)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("overwritten", func(t *testing.T) {
+		messages, complete := history.Since("topic", epoch, 1)
+		assert.False(t, complete)
+		assert.Equal(t, []uint64{3, 4, 5}, seqs(messages))
+	})
+
+	t.Run("restarted", func(t *testing.T) {
+		messages, complete := history.Since("topic", epoch, 9)
+		assert.False(t, complete)
+		assert.Equal(t, []uint64{3, 4, 5}, seqs(messages))
+	})
+
+	t.Run("other epoch", func(t *testing.T) {
+		messages, complete := history.Since("topic", "other", 3)
+		assert.False(t, complete)
+		assert.Equal(t, []uint64{3, 4, 5}, seqs(messages))
+
+		_, complete = history.Since("topic", "", 3)
+		assert.False(t, complete, "resuming a seq requires its epoch")
+	})
+
+	t.Run("unknown topic", func(t *testing.T) {
+		messages, complete := history.Since("other", "", 0)
+		assert.True(t, complete)
+		assert.Empty(t, messages)
+
+		_, complete = history.Since("other", epoch, 1)
+		assert.False(t, complete)
+	})
+}
+
+func TestHistory_EvictsLeastRecentTopic(t *testing.T) {
+	history := NewHistory(2, 2)
+	epoch := history.Append("a", &hub.Message{}).Epoch
+	history.Append("b", &hub.Message{})
+	history.Append("a", &hub.Message{})
+	history.Append("c", &hub.Message{})
+
+	assert.Equal(t, uint64(2), history.LastSeq("a"))
+	assert.Zero(t, history.LastSeq("b"))
+	assert.Equal(t, uint64(1), history.LastSeq("c"))
+
+	// A forgotten topic restarts its sequence in a new epoch
+	history.Append("d", &hub.Message{})
+	restarted := history.Append("a", &hub.Message{})
+	assert.Equal(t, uint64(1), restarted.Seq)
+	assert.NotEqual(t, epoch, restarted.Epoch)
+	_, complete := history.Since("a", epoch, 1)
+	assert.False(t, complete)
+}
+
+func TestPusher_History(t *testing.T) {
+	pusher := NewPusher(&Config{WorkerCount: 1, QueueSize: 10, HistorySize: 4, HistoryTopics: 4}, nil, nil, nil)
+	pusher.running = true
+
+	require.True(t, pusher.PushToTopic("device.UAV1.osd", &hub.Message{Type: hub.MessageTypeEvent}))
+	require.True(t, pusher.PushToTopic("device.UAV1.osd", &hub.Message{Type: hub.MessageTypeEvent}))
+
+	queued := <-pusher.queue
+	assert.Equal(t, uint64(1), queued.Message.Seq)
+	assert.Equal(t, "device.UAV1.osd", queued.Message.Topic)
+	assert.Equal(t, uint64(2), pusher.History().LastSeq("device.UAV1.osd"))
+
+	assert.Nil(t, NewPusher(&Config{WorkerCount: 1, QueueSize: 10}, nil, nil, nil).History())
+}
@@ -24,13 +24,20 @@ type Config struct {
 	WorkerCount int
 	// QueueSize is the size of the message queue
 	QueueSize int
+	// HistorySize is the number of messages kept per topic for clients
+	// resuming after a reconnect; zero disables history
+	HistorySize int
+	// HistoryTopics is the number of topics with history
+	HistoryTopics int
 }
 
 // DefaultConfig returns default pusher configuration
 func DefaultConfig() *Config {
 	return &Config{
-		WorkerCount: DefaultWorkerCount,
-		QueueSize:   DefaultQueueSize,
+		WorkerCount:   DefaultWorkerCount,
+		QueueSize:     DefaultQueueSize,
+		HistorySize:   DefaultHistorySize,
+		HistoryTopics: DefaultHistoryTopics,
 	}
 }
 
@@ -45,6 +52,11 @@ type Message struct {
 	ClientIDs []string
 	// ExcludeClientIDs is a list of client IDs to exclude
 	ExcludeClientIDs []string
+	// CoalesceKey identifies the source of telemetry, such as a device SN.
+	// Messages of a topic with the same key supersede each other: they are
+	// coalesced instead of dropped when the queue is full and are rate
+	// limited for subscriptions with a maximum rate.
+	CoalesceKey string
 }
 
 // Pusher pushes messages to WebSocket clients
@@ -59,10 +71,23 @@ type Pusher struct {
 	running     bool
 	runningMu   sync.RWMutex
 
+	// history numbers and keeps topic messages; nil when disabled
+	history *History
+
+	// Coalesced telemetry waiting for the queue, by coalesceKey
+	latest      map[string]*Message
+	latestReady chan struct{}
+	latestMu    sync.Mutex
+
+	// Rate limited telemetry, by client ID and coalesceKey
+	throttles  map[string]map[string]*throttle
+	throttleMu sync.Mutex
+
 	// Metrics
-	messagesPushed   int64
-	messagesDropped  int64
-	metricsMu        sync.RWMutex
+	messagesPushed    int64
+	messagesDropped   int64
+	messagesCoalesced int64
+	metricsMu         sync.RWMutex
 }
 
 // NewPusher creates a new message pusher
@@ -79,13 +104,23 @@ func NewPusher(
 		logger = logrus.NewEntry(logrus.StandardLogger())
 	}
 
+	var history *History
+	if config.HistorySize > 0 {
+		history = NewHistory(config.HistorySize, max(config.HistoryTopics, 1))
+	}
+
 	return &Pusher{
 		config:     config,
 		hub:        h,
 		subManager: subManager,
+		history:    history,
 		logger:     logger.WithField("component", "pusher"),
 		queue:      make(chan *Message, config.QueueSize),
 		done:       make(chan struct{}),
+
+		latest:      make(map[string]*Message),
+		latestReady: make(chan struct{}, 1),
+		throttles:   make(map[string]map[string]*throttle),
 	}
 }
 
@@ -121,6 +156,17 @@ func (p *Pusher) Stop() {
 	close(p.done)
 	p.wg.Wait()
 
+	p.throttleMu.Lock()
+	for _, keys := range p.throttles {
+		for _, t := range keys {
+			if t.timer != nil {
+				t.timer.Stop()
+			}
+		}
+	}
+	p.throttles = make(map[string]map[string]*throttle)
+	p.throttleMu.Unlock()
+
 	p.logger.Info("Pusher stopped")
 }
 
@@ -137,10 +183,23 @@ func (p *Pusher) Push(msg *Message) bool {
 		return false
 	}
 
+	// Number topic messages and keep them for replay
+	if msg.Topic != "" && msg.Message != nil && p.history != nil {
+		msg.Message = p.history.Append(msg.Topic, msg.Message)
+	}
+
+	// Telemetry superseding a coalesced message replaces it
+	if msg.CoalesceKey != "" && p.coalesce(msg, true) {
+		return true
+	}
+
 	select {
 	case p.queue <- msg:
 		return true
 	default:
+		if msg.CoalesceKey != "" {
+			return p.coalesce(msg, false)
+		}
 		p.incrementDropped()
 		p.logger.WithField("topic", msg.Topic).Warn("Push queue full, dropping message")
 		return false
@@ -155,6 +214,17 @@ func (p *Pusher) PushToTopic(topic string, msg *hub.Message) bool {
 	})
 }
 
+// PushToTopicCoalescing pushes telemetry from a source, such as a device SN,
+// to all subscribers of a topic. Telemetry from the same source supersedes
+// undelivered earlier telemetry. An empty key behaves like PushToTopic.
+func (p *Pusher) PushToTopicCoalescing(topic, key string, msg *hub.Message) bool {
+	return p.Push(&Message{
+		Topic:       topic,
+		Message:     msg,
+		CoalesceKey: key,
+	})
+}
+
 // PushToClients pushes a message to specific clients
 func (p *Pusher) PushToClients(clientIDs []string, msg *hub.Message) bool {
 	return p.Push(&Message{
@@ -194,6 +264,9 @@ func (p *Pusher) worker(id int) {
 
 		case msg := <-p.queue:
 			p.processMessage(msg)
+
+		case <-p.latestReady:
+			p.drainLatest()
 		}
 	}
 }
@@ -204,17 +277,20 @@ func (p *Pusher) processMessage(msg *Message) {
 		return
 	}
 
-	var clientIDs []string
+	var subscribers []subscription.Subscriber
 
 	if len(msg.ClientIDs) > 0 {
 		// Push to specific clients
-		clientIDs = msg.ClientIDs
+		subscribers = make([]subscription.Subscriber, 0, len(msg.ClientIDs))
+		for _, clientID := range msg.ClientIDs {
+			subscribers = append(subscribers, subscription.Subscriber{ClientID: clientID})
+		}
 	} else if msg.Topic != "" && p.subManager != nil {
-		// Get subscribers for topic
-		clientIDs = p.subManager.GetSubscribers(msg.Topic)
+		// Get subscribers for topic, including wildcard subscriptions
+		subscribers = p.subManager.Match(msg.Topic)
 	}
 
-	if len(clientIDs) == 0 {
+	if len(subscribers) == 0 {
 		return
 	}
 
@@ -226,13 +302,25 @@ func (p *Pusher) processMessage(msg *Message) {
 
 	// Push to each client
 	pushedCount := 0
-	for _, clientID := range clientIDs {
-		if excludeSet[clientID] {
+	for _, subscriber := range subscribers {
+		if excludeSet[subscriber.ClientID] {
+			continue
+		}
+
+		out, ok := filterMessage(msg.Message, subscriber.Filters)
+		if !ok {
+			continue
+		}
+
+		if period := subscriber.Filters.Period(); period > 0 && msg.CoalesceKey != "" {
+			if p.throttle(subscriber.ClientID, coalesceKey(msg), period, out) {
+				pushedCount++
+			}
 			continue
 		}
 
 		if p.hub != nil {
-			if p.hub.SendToClient(clientID, msg.Message) {
+			if p.hub.SendToClient(subscriber.ClientID, out) {
 				pushedCount++
 			}
 		}
@@ -245,10 +333,25 @@ func (p *Pusher) processMessage(msg *Message) {
 	p.logger.WithFields(logrus.Fields{
 		"topic":        msg.Topic,
 		"pushed_count": pushedCount,
-		"total_targets": len(clientIDs),
+		"total_targets": len(subscribers),
 	}).Debug("Message pushed")
 }
 
+// filterMessage returns the message with the subscription filters applied,
+// and false when the filters leave nothing to deliver
+func filterMessage(msg *hub.Message, filters subscription.Filters) (*hub.Message, bool) {
+	if filters == nil {
+		return msg, true
+	}
+	data, ok := filters.Apply(msg.Data)
+	if !ok {
+		return nil, false
+	}
+	filtered := *msg
+	filtered.Data = data
+	return &filtered, true
+}
+
 // incrementPushed increments the pushed counter
 func (p *Pusher) incrementPushed(count int64) {
 	p.metricsMu.Lock()
@@ -256,6 +359,13 @@ func (p *Pusher) incrementPushed(count int64) {
 	p.metricsMu.Unlock()
 }
 
+// incrementCoalesced increments the coalesced counter
+func (p *Pusher) incrementCoalesced() {
+	p.metricsMu.Lock()
+	p.messagesCoalesced++
+	p.metricsMu.Unlock()
+}
+
 // incrementDropped increments the dropped counter
 func (p *Pusher) incrementDropped() {
 	p.metricsMu.Lock()
@@ -270,6 +380,19 @@ func (p *Pusher) GetMetrics() (pushed, dropped int64) {
 	return p.messagesPushed, p.messagesDropped
 }
 
+// History returns the message history, or nil when it is disabled
+func (p *Pusher) History() *History {
+	return p.history
+}
+
+// GetCoalesced returns the number of messages superseded by newer
+// telemetry before delivery
+func (p *Pusher) GetCoalesced() int64 {
+	p.metricsMu.RLock()
+	defer p.metricsMu.RUnlock()
+	return p.messagesCoalesced
+}
+
 // QueueLength returns the current queue length
 func (p *Pusher) QueueLength() int {
 	return len(p.queue)
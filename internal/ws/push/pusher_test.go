@@ -260,6 +260,56 @@ func TestPusher_ProcessMessage(t *testing.T) {
 	})
 }
 
+func TestPusher_ProcessMessage_Filters(t *testing.T) {
+	h := hub.NewHub(nil, nil)
+	h.Start()
+	defer h.Stop()
+
+	for _, id := range []string{"battery", "hms", "all"} {
+		h.Register(hub.NewClient(id, nil, h, nil, nil))
+	}
+	require.Eventually(t, func() bool { return h.GetClientCount() == 3 }, time.Second, 10*time.Millisecond)
+
+	subManager := subscription.NewManager(nil)
+	subManager.SubscribeWithFilter("battery", "device.*.property", &subscription.Filter{Properties: []string{"battery"}})
+	subManager.SubscribeWithFilter("hms", "device.#", &subscription.Filter{Events: []string{"hms"}})
+	subManager.Subscribe("all", "device.#")
+	pusher := NewPusher(nil, h, subManager, nil)
+
+	pusher.processMessage(&Message{
+		Topic: "device.UAV1.property",
+		Message: &hub.Message{
+			Type: hub.MessageTypeEvent,
+			Data: map[string]any{"properties": map[string]any{"battery": 80.0, "height": 10.0}},
+		},
+	})
+
+	pushed, _ := pusher.GetMetrics()
+	assert.Equal(t, int64(2), pushed, "the hms subscriber has nothing to receive")
+}
+
+func TestFilterMessage(t *testing.T) {
+	msg := &hub.Message{
+		Type:  hub.MessageTypeEvent,
+		Event: "property.processed",
+		Data:  map[string]any{"properties": map[string]any{"battery": 80.0, "height": 10.0}},
+	}
+
+	out, ok := filterMessage(msg, nil)
+	assert.True(t, ok)
+	assert.Same(t, msg, out)
+
+	out, ok = filterMessage(msg, subscription.Filters{{Properties: []string{"height"}}})
+	require.True(t, ok)
+	assert.Equal(t, "property.processed", out.Event)
+	assert.Equal(t, map[string]any{"properties": map[string]any{"height": 10.0}}, out.Data)
+	original, _ := msg.Data.(map[string]any)
+	assert.Equal(t, map[string]any{"battery": 80.0, "height": 10.0}, original["properties"], "the original message is unchanged")
+
+	_, ok = filterMessage(msg, subscription.Filters{{Properties: []string{"latitude"}}})
+	assert.False(t, ok)
+}
+
 func TestPusher_Broadcast(t *testing.T) {
 	h := hub.NewHub(nil, nil)
 	h.Start()
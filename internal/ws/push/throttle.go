@@ -0,0 +1,148 @@
+package push
+
+import (
+	"time"
+
+	"github.com/utmos/utmos/internal/ws/hub"
+)
+
+// throttle holds the delivery state of one source's telemetry to a client
+// with a rate limited subscription
+type throttle struct {
+	last    time.Time
+	pending *hub.Message
+	timer   *time.Timer
+}
+
+// coalesceKey identifies the source of a coalescable message within its topic
+func coalesceKey(msg *Message) string {
+	return msg.Topic + "|" + msg.CoalesceKey
+}
+
+// coalesce keeps the latest of the coalescable messages that found the
+// queue full, or that superseded one waiting there. It reports whether msg
+// was kept.
+func (p *Pusher) coalesce(msg *Message, onlyIfWaiting bool) bool {
+	key := coalesceKey(msg)
+
+	p.latestMu.Lock()
+	_, waiting := p.latest[key]
+	if onlyIfWaiting && !waiting {
+		p.latestMu.Unlock()
+		return false
+	}
+	p.latest[key] = msg
+	p.latestMu.Unlock()
+
+	if waiting {
+		p.incrementCoalesced()
+	}
+	select {
+	case p.latestReady <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// drainLatest processes the coalesced messages
+func (p *Pusher) drainLatest() {
+	p.latestMu.Lock()
+	latest := p.latest
+	p.latest = make(map[string]*Message)
+	p.latestMu.Unlock()
+
+	for _, msg := range latest {
+		p.processMessage(msg)
+	}
+}
+
+// throttle delivers msg to a client at most once per period per key,
+// replacing undelivered messages with newer ones. It reports whether msg
+// was sent immediately.
+func (p *Pusher) throttle(clientID, key string, period time.Duration, msg *hub.Message) bool {
+	now := time.Now()
+
+	p.throttleMu.Lock()
+	keys, ok := p.throttles[clientID]
+	if !ok {
+		// The hub unregisters clients before RemoveClient discards their
+		// state, so state is never re-created for a disconnected client
+		// that a message was already being processed for
+		if !p.registered(clientID) {
+			p.throttleMu.Unlock()
+			return false
+		}
+		keys = make(map[string]*throttle)
+		p.throttles[clientID] = keys
+	}
+	t, ok := keys[key]
+	if !ok {
+		t = &throttle{}
+		keys[key] = t
+	}
+
+	if t.timer == nil && now.Sub(t.last) >= period {
+		t.last = now
+		p.throttleMu.Unlock()
+		return p.send(clientID, msg)
+	}
+
+	superseded := t.pending != nil
+	t.pending = msg
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.last.Add(period).Sub(now), func() {
+			p.flushThrottle(clientID, key)
+		})
+	}
+	p.throttleMu.Unlock()
+
+	if superseded {
+		p.incrementCoalesced()
+	}
+	return false
+}
+
+// flushThrottle sends the latest message held back for a client
+func (p *Pusher) flushThrottle(clientID, key string) {
+	p.throttleMu.Lock()
+	t, ok := p.throttles[clientID][key]
+	if !ok {
+		p.throttleMu.Unlock()
+		return
+	}
+	msg := t.pending
+	t.pending, t.timer = nil, nil
+	t.last = time.Now()
+	p.throttleMu.Unlock()
+
+	if msg != nil && p.send(clientID, msg) {
+		p.incrementPushed(1)
+	}
+}
+
+// RemoveClient discards the rate limiting state of a disconnected client
+func (p *Pusher) RemoveClient(clientID string) {
+	p.throttleMu.Lock()
+	defer p.throttleMu.Unlock()
+
+	for _, t := range p.throttles[clientID] {
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+	}
+	delete(p.throttles, clientID)
+}
+
+// registered reports whether a client is connected to the hub
+func (p *Pusher) registered(clientID string) bool {
+	if p.hub == nil {
+		return false
+	}
+	_, ok := p.hub.GetClient(clientID)
+	return ok
+}
+
+// send sends a message to a client through the hub
+func (p *Pusher) send(clientID string, msg *hub.Message) bool {
+	return p.hub != nil && p.hub.SendToClient(clientID, msg)
+}
@@ -0,0 +1,116 @@
+package push
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/utmos/utmos/internal/ws/hub"
+	"github.com/utmos/utmos/internal/ws/subscription"
+)
+
+func TestPusher_CoalesceWhenQueueFull(t *testing.T) {
+	pusher := NewPusher(&Config{WorkerCount: 1, QueueSize: 1}, nil, nil, nil)
+	// Mark the pusher running without workers so the queue stays full
+	pusher.running = true
+
+	require.True(t, pusher.PushToTopic("filler", &hub.Message{Type: hub.MessageTypeEvent}))
+	for i := 0; i < 3; i++ {
+		assert.True(t, pusher.PushToTopicCoalescing("device.UAV1.osd", "UAV1", &hub.Message{
+			Type: hub.MessageTypeEvent,
+			Data: map[string]any{"index": i},
+		}))
+	}
+	assert.True(t, pusher.PushToTopicCoalescing("device.UAV2.osd", "UAV2", &hub.Message{Type: hub.MessageTypeEvent}))
+	assert.False(t, pusher.PushToTopic("events", &hub.Message{Type: hub.MessageTypeEvent}), "events are still dropped")
+
+	_, dropped := pusher.GetMetrics()
+	assert.Equal(t, int64(1), dropped)
+	assert.Equal(t, int64(2), pusher.GetCoalesced())
+	require.Len(t, pusher.latest, 2)
+	assert.Equal(t, map[string]any{"index": 2}, pusher.latest["device.UAV1.osd|UAV1"].Message.Data)
+
+	// Once waiting, newer telemetry replaces the coalesced message even
+	// when the queue has room
+	<-pusher.queue
+	assert.True(t, pusher.PushToTopicCoalescing("device.UAV1.osd", "UAV1", &hub.Message{
+		Type: hub.MessageTypeEvent,
+		Data: map[string]any{"index": 3},
+	}))
+	assert.Empty(t, pusher.queue)
+	assert.Equal(t, map[string]any{"index": 3}, pusher.latest["device.UAV1.osd|UAV1"].Message.Data)
+
+	pusher.drainLatest()
+	assert.Empty(t, pusher.latest)
+}
+
+func TestPusher_Throttle(t *testing.T) {
+	h := hub.NewHub(nil, nil)
+	h.Start()
+	defer h.Stop()
+	for _, id := range []string{"map", "ops", "raw"} {
+		h.Register(hub.NewClient(id, nil, h, nil, nil))
+	}
+	require.Eventually(t, func() bool { return h.GetClientCount() == 3 }, time.Second, 10*time.Millisecond)
+
+	subManager := subscription.NewManager(nil)
+	subManager.SubscribeWithFilter("map", "device.*.osd", &subscription.Filter{MaxRate: 10})
+	subManager.SubscribeWithFilter("ops", "device.*.osd", &subscription.Filter{Interval: 60})
+	subManager.Subscribe("raw", "device.*.osd")
+	pusher := NewPusher(nil, h, subManager, nil)
+
+	for i := 0; i < 5; i++ {
+		pusher.processMessage(&Message{
+			Topic:       "device.UAV1.osd",
+			Message:     &hub.Message{Type: hub.MessageTypeEvent, Data: map[string]any{"index": i}},
+			CoalesceKey: "UAV1",
+		})
+	}
+	// Events are never rate limited
+	pusher.processMessage(&Message{Topic: "device.UAV1.osd", Message: &hub.Message{Type: hub.MessageTypeEvent}})
+
+	pushed, _ := pusher.GetMetrics()
+	assert.Equal(t, int64(5+1+1+3), pushed, "raw gets everything, map and ops the first telemetry and the event")
+	assert.Equal(t, int64(3+3), pusher.GetCoalesced())
+
+	// The map subscriber receives the latest held back telemetry after 100ms
+	require.Eventually(t, func() bool {
+		pushed, _ := pusher.GetMetrics()
+		return pushed == 11
+	}, time.Second, 10*time.Millisecond)
+
+	pusher.throttleMu.Lock()
+	assert.Nil(t, pusher.throttles["map"]["device.UAV1.osd|UAV1"].pending)
+	assert.Equal(t, map[string]any{"index": 4}, pusher.throttles["ops"]["device.UAV1.osd|UAV1"].pending.Data)
+	pusher.throttleMu.Unlock()
+
+	pusher.RemoveClient("ops")
+	pusher.throttleMu.Lock()
+	assert.NotContains(t, pusher.throttles, "ops")
+	pusher.throttleMu.Unlock()
+}
+
+func TestPusher_ThrottleAfterRemoveClient(t *testing.T) {
+	h := hub.NewHub(nil, nil)
+	h.Start()
+	defer h.Stop()
+	client := hub.NewClient("map", nil, h, nil, nil)
+	h.Register(client)
+	require.Eventually(t, func() bool { return h.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+	pusher := NewPusher(nil, h, subscription.NewManager(nil), nil)
+
+	msg := &hub.Message{Type: hub.MessageTypeEvent}
+	assert.True(t, pusher.throttle("map", "device.UAV1.osd|UAV1", time.Hour, msg))
+
+	// The client disconnects while telemetry for it is being processed
+	h.Unregister(client)
+	require.Eventually(t, func() bool { return h.GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+	pusher.RemoveClient("map")
+	assert.False(t, pusher.throttle("map", "device.UAV1.osd|UAV1", time.Hour, msg))
+
+	pusher.throttleMu.Lock()
+	defer pusher.throttleMu.Unlock()
+	assert.NotContains(t, pusher.throttles, "map", "no state or flush timer is left for the removed client")
+}
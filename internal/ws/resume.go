@@ -0,0 +1,126 @@
+package ws
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/utmos/utmos/internal/ws/hub"
+	"github.com/utmos/utmos/internal/ws/subscription"
+)
+
+// ResumeRequest is the data of a resume message, which subscribes a
+// reconnected client to a topic and replays the messages it missed:
+//
+//	{"type":"resume","event":"device.UAV1.property.processed","data":{"last_seq":42,"epoch":"..."}}
+//
+// A subscription filter may be given along with last_seq. Messages pushed
+// while the replay is sent may arrive twice; clients drop messages with a
+// seq they already have.
+type ResumeRequest struct {
+	subscription.Filter
+	// LastSeq is the seq of the last message the client received on the
+	// topic; zero replays every kept message
+	LastSeq uint64 `json:"last_seq"`
+	// Epoch is the epoch of that message. Seqs of another epoch, e.g. from
+	// before a restart or from another instance, cannot be resumed from.
+	Epoch string `json:"epoch,omitempty"`
+}
+
+// ResumeResult is the data of the ack that follows a replay
+type ResumeResult struct {
+	// LastSeq is the seq of the last replayed message, or the requested
+	// seq when nothing was replayed
+	LastSeq uint64 `json:"last_seq"`
+	// Epoch is the epoch of LastSeq
+	Epoch    string `json:"epoch,omitempty"`
+	Replayed int    `json:"replayed"`
+	// Complete is false when messages after the requested seq are no
+	// longer kept, or the requested epoch is not the topic's current one
+	// because its sequence restarted; clients should then reload the state
+	// the topic updates
+	Complete bool `json:"complete"`
+}
+
+// resume subscribes a client to a topic and replays the messages it missed
+func (s *Service) resume(client *hub.Client, msg *hub.Message) {
+	if msg.Event == "" || subscription.IsPattern(msg.Event) {
+		client.SendError("resume requires a topic without wildcards", msg.TraceID)
+		return
+	}
+	if !s.authorizeTopic(client, msg) {
+		return
+	}
+
+	var req ResumeRequest
+	if msg.Data != nil {
+		if err := decodeMessageData(msg.Data, &req); err != nil {
+			client.SendError("invalid resume request", msg.TraceID)
+			return
+		}
+	}
+	if err := req.Validate(); err != nil {
+		client.SendError("invalid subscription filter", msg.TraceID)
+		return
+	}
+
+	var filter *subscription.Filter
+	if !req.Filter.IsEmpty() {
+		filter = &req.Filter
+	}
+
+	// Subscribe before replaying so that nothing pushed meanwhile is missed
+	if err := s.subManager.SubscribeWithFilter(client.ID, msg.Event, filter); err != nil {
+		client.SendError(err.Error(), msg.TraceID)
+		return
+	}
+	client.Subscribe(msg.Event)
+
+	result := ResumeResult{LastSeq: req.LastSeq, Epoch: req.Epoch, Complete: req.LastSeq == 0}
+	if history := s.pusher.History(); history != nil {
+		var missed []*hub.Message
+		missed, result.Complete = history.Since(msg.Event, req.Epoch, req.LastSeq)
+		result.Replayed = s.replay(client, missed, filter)
+		if len(missed) > 0 {
+			result.LastSeq = missed[len(missed)-1].Seq
+			result.Epoch = missed[len(missed)-1].Epoch
+		}
+	}
+
+	client.Send(&hub.Message{
+		Type:    hub.MessageTypeAck,
+		Event:   msg.Event,
+		Data:    result,
+		TraceID: msg.TraceID,
+	})
+
+	s.logger.WithFields(logrus.Fields{
+		"client_id": client.ID,
+		"topic":     msg.Event,
+		"last_seq":  req.LastSeq,
+		"epoch":     req.Epoch,
+		"replayed":  result.Replayed,
+		"complete":  result.Complete,
+	}).Debug("Client resumed topic")
+}
+
+// replay sends missed messages to a client through its filter and returns
+// how many were sent
+func (s *Service) replay(client *hub.Client, messages []*hub.Message, filter *subscription.Filter) int {
+	var filters subscription.Filters
+	if filter != nil {
+		filters = subscription.Filters{filter}
+	}
+
+	sent := 0
+	for _, m := range messages {
+		data, ok := filters.Apply(m.Data)
+		if !ok {
+			continue
+		}
+		out := *m
+		out.Data = data
+		if client.Send(&out) {
+			sent++
+		}
+	}
+	return sent
+}
@@ -0,0 +1,108 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/utmos/utmos/internal/ws/hub"
+)
+
+func readMessage(t *testing.T, conn *websocket.Conn) hub.Message {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var msg hub.Message
+	require.NoError(t, json.Unmarshal(data, &msg))
+	return msg
+}
+
+func TestService_Resume(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	svc := NewService(nil, nil, nil)
+	require.NoError(t, svc.Start(context.Background()))
+	defer func() { _ = svc.Stop() }()
+
+	server := httptest.NewServer(http.HandlerFunc(svc.HandleWebSocket))
+	defer server.Close()
+
+	const topic = "device.UAV1.property.processed"
+	for i := 1; i <= 3; i++ {
+		require.True(t, svc.Pusher().PushToTopic(topic, &hub.Message{
+			Type: hub.MessageTypeEvent,
+			Data: map[string]any{"properties": map[string]any{"height": i, "speed": i}},
+		}))
+	}
+	require.Eventually(t, func() bool { return svc.Pusher().History().LastSeq(topic) == 3 }, time.Second, 10*time.Millisecond)
+	kept, _ := svc.Pusher().History().Since(topic, "", 0)
+	epoch := kept[0].Epoch
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	t.Run("replays missed messages", func(t *testing.T) {
+		require.NoError(t, conn.WriteJSON(hub.Message{
+			Type:  hub.MessageTypeResume,
+			Event: topic,
+			Data:  map[string]any{"last_seq": 1, "epoch": epoch, "properties": []string{"height"}},
+		}))
+
+		for _, seq := range []uint64{2, 3} {
+			msg := readMessage(t, conn)
+			assert.Equal(t, seq, msg.Seq)
+			assert.Equal(t, epoch, msg.Epoch)
+			assert.Equal(t, topic, msg.Topic)
+			assert.Equal(t, map[string]any{"properties": map[string]any{"height": float64(seq)}}, msg.Data)
+		}
+
+		ack := readMessage(t, conn)
+		assert.Equal(t, hub.MessageTypeAck, ack.Type)
+		assert.Equal(t, map[string]any{"last_seq": float64(3), "epoch": epoch, "replayed": float64(2), "complete": true}, ack.Data)
+	})
+
+	t.Run("delivers live messages after replay", func(t *testing.T) {
+		require.True(t, svc.Pusher().PushToTopic(topic, &hub.Message{
+			Type: hub.MessageTypeEvent,
+			Data: map[string]any{"properties": map[string]any{"height": 4, "speed": 4}},
+		}))
+
+		msg := readMessage(t, conn)
+		assert.Equal(t, uint64(4), msg.Seq)
+		assert.Equal(t, map[string]any{"properties": map[string]any{"height": float64(4)}}, msg.Data)
+	})
+
+	t.Run("replays everything for another epoch", func(t *testing.T) {
+		require.NoError(t, conn.WriteJSON(hub.Message{
+			Type:  hub.MessageTypeResume,
+			Event: topic,
+			Data:  map[string]any{"last_seq": 3, "epoch": "previous-instance"},
+		}))
+
+		for _, seq := range []uint64{1, 2, 3, 4} {
+			assert.Equal(t, seq, readMessage(t, conn).Seq)
+		}
+		ack := readMessage(t, conn)
+		assert.Equal(t, hub.MessageTypeAck, ack.Type)
+		assert.Equal(t, map[string]any{"last_seq": float64(4), "epoch": epoch, "replayed": float64(4), "complete": false}, ack.Data)
+	})
+
+	t.Run("rejects patterns", func(t *testing.T) {
+		require.NoError(t, conn.WriteJSON(hub.Message{Type: hub.MessageTypeResume, Event: "device.*.property.processed"}))
+
+		msg := readMessage(t, conn)
+		assert.Equal(t, hub.MessageTypeError, msg.Type)
+	})
+}
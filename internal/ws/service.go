@@ -2,10 +2,13 @@
 package ws
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -14,6 +17,7 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/utmos/utmos/internal/account"
 	"github.com/utmos/utmos/internal/ws/hub"
 	"github.com/utmos/utmos/internal/ws/push"
 	"github.com/utmos/utmos/internal/ws/subscription"
@@ -39,15 +43,25 @@ type ServiceConfig struct {
 	PusherConfig *push.Config
 	// AllowedOrigins for WebSocket upgrade
 	AllowedOrigins []string
+	// AuthRecheckInterval is how often authenticated clients' credentials
+	// are checked for revocation
+	AuthRecheckInterval time.Duration
+	// InstanceID identifies this replica and names its RabbitMQ queue;
+	// a random ID is used when empty
+	InstanceID string
+	// StatsInterval is how often statistics are shared with other replicas
+	StatsInterval time.Duration
 }
 
 // DefaultServiceConfig returns default service configuration
 func DefaultServiceConfig() *ServiceConfig {
 	return &ServiceConfig{
-		HubConfig:      hub.DefaultConfig(),
-		ClientConfig:   hub.DefaultClientConfig(),
-		PusherConfig:   push.DefaultConfig(),
-		AllowedOrigins: []string{"*"},
+		HubConfig:           hub.DefaultConfig(),
+		ClientConfig:        hub.DefaultClientConfig(),
+		PusherConfig:        push.DefaultConfig(),
+		AllowedOrigins:      []string{"*"},
+		AuthRecheckInterval: DefaultAuthRecheckInterval,
+		StatsInterval:       DefaultStatsInterval,
 	}
 }
 
@@ -58,9 +72,19 @@ type Service struct {
 	subManager *subscription.Manager
 	pusher     *push.Pusher
 	subscriber *rabbitmq.Subscriber
+	publisher  *rabbitmq.Publisher
+	instanceID string
 	upgrader   websocket.Upgrader
 	logger     *logrus.Entry
 	msgMetrics *metrics.MessageMetrics
+	workspaces WorkspaceResolver
+
+	authenticator Authenticator
+	auths         map[string]*clientAuth
+	authMu        sync.Mutex
+
+	nodes   map[string]NodeStats
+	nodesMu sync.Mutex
 
 	running   bool
 	runningMu sync.RWMutex
@@ -108,14 +132,22 @@ func NewService(config *ServiceConfig, metricsCollector *metrics.Collector, logg
 		},
 	}
 
+	instanceID := config.InstanceID
+	if instanceID == "" {
+		instanceID = uuid.New().String()
+	}
+
 	svc := &Service{
 		config:     config,
 		hub:        h,
 		subManager: subManager,
 		pusher:     pusher,
+		instanceID: instanceID,
 		upgrader:   upgrader,
 		logger:     logger.WithField("component", "ws-service"),
 		msgMetrics: msgMetrics,
+		auths:      make(map[string]*clientAuth),
+		nodes:      make(map[string]NodeStats),
 		done:       make(chan struct{}),
 	}
 
@@ -132,6 +164,13 @@ func (s *Service) SetSubscriber(subscriber *rabbitmq.Subscriber) {
 	s.subscriber = subscriber
 }
 
+// SetWorkspaceResolver sets the resolver used to push device telemetry to
+// the device's workspace. Without one, telemetry is only pushed to the
+// action and device topics.
+func (s *Service) SetWorkspaceResolver(resolver WorkspaceResolver) {
+	s.workspaces = resolver
+}
+
 // Start starts the WebSocket service
 func (s *Service) Start(ctx context.Context) error {
 	s.runningMu.Lock()
@@ -156,6 +195,16 @@ func (s *Service) Start(ctx context.Context) error {
 		go s.consumeMessages(ctx)
 	}
 
+	// Share statistics with the other replicas
+	if s.publisher != nil {
+		go s.shareStats(ctx)
+	}
+
+	// Recheck credentials of authenticated clients
+	if s.authenticator != nil {
+		go s.recheckAuth(ctx)
+	}
+
 	return nil
 }
 
@@ -195,7 +244,31 @@ func (s *Service) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := s.upgrader.Upgrade(w, r, nil)
+	// Extract metadata from request
+	deviceSN := r.URL.Query().Get("device_sn")
+	userID := r.URL.Query().Get("user_id")
+
+	// Authenticated clients are identified and scoped by their credential
+	// rather than by query parameters. Only identities without a workspace
+	// may pick one; without authentication clients join no workspace, since
+	// anyone could claim any workspace_id.
+	var identity *account.Identity
+	var credential, workspaceID string
+	var responseHeader http.Header
+	if s.authenticator != nil {
+		var ok bool
+		identity, credential, responseHeader, ok = s.authenticateUpgrade(w, r)
+		if !ok {
+			return
+		}
+		userID = identity.Subject
+		workspaceID = identity.WorkspaceID
+		if workspaceID == "" {
+			workspaceID = r.URL.Query().Get("workspace_id")
+		}
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
 		s.logger.WithError(err).Warn("Failed to upgrade WebSocket connection")
 		return
@@ -204,18 +277,32 @@ func (s *Service) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Generate client ID
 	clientID := uuid.New().String()
 
-	// Extract metadata from request
-	deviceSN := r.URL.Query().Get("device_sn")
-	userID := r.URL.Query().Get("user_id")
-
 	// Create client
 	client := hub.NewClient(clientID, conn, s.hub, s.config.ClientConfig, s.logger)
 	client.DeviceSN = deviceSN
 	client.UserID = userID
+	if workspaceID != "" {
+		client.Metadata[MetadataWorkspaceID] = workspaceID
+	}
+	if identity != nil {
+		s.trackClient(client, identity, credential)
+	}
+	// DJI Pilot 2 only understands pushes in its own envelope
+	if identity != nil && isPilotRequest(r) {
+		client.Frame = hub.FrameDJI
+	}
 
 	// Register with hub
 	s.hub.Register(client)
 
+	// Clients joining a workspace receive its notifications without
+	// subscribing, since DJI Pilot 2 never sends subscribe messages
+	if workspaceID != "" {
+		if err := s.subManager.Subscribe(clientID, WorkspaceTopic(workspaceID)); err != nil {
+			s.logger.WithError(err).WithField("workspace_id", workspaceID).Warn("Failed to join client to workspace")
+		}
+	}
+
 	// Start client pumps
 	client.Start()
 
@@ -235,6 +322,8 @@ func (s *Service) onClientConnect(client *hub.Client) {
 func (s *Service) onClientDisconnect(client *hub.Client) {
 	// Unsubscribe from all topics
 	s.subManager.UnsubscribeAll(client.ID)
+	s.pusher.RemoveClient(client.ID)
+	s.untrackClient(client.ID)
 
 	s.logger.WithField("client_id", client.ID).Debug("Client disconnected callback")
 }
@@ -250,9 +339,21 @@ func (s *Service) onClientMessage(client *hub.Client, msg *hub.Message) {
 	// Handle subscription messages
 	switch msg.Type {
 	case hub.MessageTypeSubscribe:
-		if msg.Event != "" {
-			s.subManager.Subscribe(client.ID, msg.Event)
+		if msg.Event == "" || !s.authorizeTopic(client, msg) {
+			return
+		}
+		filter, err := subscriptionFilter(msg.Data)
+		if err != nil {
+			client.Unsubscribe(msg.Event)
+			client.SendError("invalid subscription filter", msg.TraceID)
+			return
 		}
+		if err := s.subManager.SubscribeWithFilter(client.ID, msg.Event, filter); err != nil {
+			client.Unsubscribe(msg.Event)
+			client.SendError(err.Error(), msg.TraceID)
+		}
+	case hub.MessageTypeResume:
+		s.resume(client, msg)
 	case hub.MessageTypeUnsubscribe:
 		if msg.Event != "" {
 			s.subManager.Unsubscribe(client.ID, msg.Event)
@@ -260,14 +361,64 @@ func (s *Service) onClientMessage(client *hub.Client, msg *hub.Message) {
 	}
 }
 
+// authorizeTopic checks that a client may receive the topic of a subscribe
+// or resume message, telling the client when it may not
+func (s *Service) authorizeTopic(client *hub.Client, msg *hub.Message) bool {
+	if s.topicAllowed(context.Background(), client, msg.Event) {
+		return true
+	}
+	client.Unsubscribe(msg.Event)
+	client.SendError("topic is not accessible in this workspace", msg.TraceID)
+	s.logger.WithFields(logrus.Fields{
+		"client_id": client.ID,
+		"topic":     msg.Event,
+	}).Warn("Rejected subscription outside the client's workspace")
+	return false
+}
+
+// subscriptionFilter decodes the filter clients may send in the data of a
+// subscribe message, e.g.
+//
+//	{"type":"subscribe","event":"device.*.property.processed",
+//	 "data":{"properties":["battery.capacity_percent","latitude"],"interval":10}}
+func subscriptionFilter(data any) (*subscription.Filter, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var filter subscription.Filter
+	if err := decodeMessageData(data, &filter); err != nil {
+		return nil, err
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+// decodeMessageData decodes the data of a client message into v, rejecting
+// unknown fields
+func decodeMessageData(data, v any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode message data: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("failed to decode message data: %w", err)
+	}
+	return nil
+}
+
 // consumeMessages consumes messages from RabbitMQ and pushes to clients
 func (s *Service) consumeMessages(ctx context.Context) {
 	if s.subscriber == nil {
 		return
 	}
 
-	// Subscribe to the WebSocket queue
-	err := s.subscriber.Subscribe("iot.ws.queue", func(ctx context.Context, msg *rabbitmq.StandardMessage) error {
+	// Subscribe to this replica's queue
+	queueName := QueueName(s.instanceID)
+	err := s.subscriber.Subscribe(queueName, func(ctx context.Context, msg *rabbitmq.StandardMessage) error {
 		s.handleRabbitMQMessage(ctx, msg)
 		return nil
 	})
@@ -283,7 +434,7 @@ func (s *Service) consumeMessages(ctx context.Context) {
 	}
 
 	// Unsubscribe when done
-	if err := s.subscriber.Unsubscribe("iot.ws.queue"); err != nil {
+	if err := s.subscriber.Unsubscribe(queueName); err != nil {
 		return
 	}
 }
@@ -293,6 +444,10 @@ func (s *Service) handleRabbitMQMessage(ctx context.Context, msg *rabbitmq.Stand
 	if msg == nil {
 		return
 	}
+	if msg.Action == actionClusterStats {
+		s.recordNodeStats(msg)
+		return
+	}
 
 	tr := otel.Tracer("iot-ws")
 	_, span := tr.Start(ctx, "ws.message.push",
@@ -308,9 +463,10 @@ func (s *Service) handleRabbitMQMessage(ctx context.Context, msg *rabbitmq.Stand
 
 	// Create WebSocket message
 	wsMsg := &hub.Message{
-		Type:    hub.MessageTypeEvent,
-		Event:   topic,
-		TraceID: msg.TID,
+		Type:      hub.MessageTypeEvent,
+		Event:     topic,
+		TraceID:   msg.TID,
+		Timestamp: msg.Timestamp,
 	}
 
 	// Parse data
@@ -323,13 +479,29 @@ func (s *Service) handleRabbitMQMessage(ctx context.Context, msg *rabbitmq.Stand
 		}
 	}
 
+	// Telemetry of a device supersedes its undelivered telemetry
+	var coalesceKey string
+	if msg.Action == actionPropertyProcessed {
+		coalesceKey = msg.DeviceSN
+	}
+
 	// Push to subscribers
-	s.pusher.PushToTopic(topic, wsMsg)
+	s.pusher.PushToTopicCoalescing(topic, coalesceKey, wsMsg)
 
 	// Also push to device-specific topic if device_sn is present
 	if msg.DeviceSN != "" {
 		deviceTopic := "device." + msg.DeviceSN + "." + topic
-		s.pusher.PushToTopic(deviceTopic, wsMsg)
+		s.pusher.PushToTopicCoalescing(deviceTopic, coalesceKey, wsMsg)
+	}
+
+	// And to every client of the workspace for workspace-scoped messages
+	if msg.WorkspaceID != "" {
+		s.pusher.PushToTopic(WorkspaceTopic(msg.WorkspaceID), wsMsg)
+	}
+
+	// Telemetry is shared with the device's workspace for situation awareness
+	if msg.Action == actionPropertyProcessed && msg.DeviceSN != "" {
+		s.pushDeviceOSD(ctx, msg.DeviceSN, wsMsg)
 	}
 
 	if s.msgMetrics != nil {
@@ -337,6 +509,35 @@ func (s *Service) handleRabbitMQMessage(ctx context.Context, msg *rabbitmq.Stand
 	}
 }
 
+// pushDeviceOSD pushes device telemetry to the device's workspace as a
+// device_osd event
+func (s *Service) pushDeviceOSD(ctx context.Context, deviceSN string, wsMsg *hub.Message) {
+	if s.workspaces == nil {
+		return
+	}
+
+	workspaceID, err := s.workspaces.WorkspaceOf(ctx, deviceSN)
+	if err != nil {
+		s.logger.WithError(err).WithField("device_sn", deviceSN).Warn("Failed to resolve device workspace")
+		return
+	}
+	if workspaceID == "" {
+		return
+	}
+
+	var host any
+	if data, ok := wsMsg.Data.(map[string]any); ok {
+		host = data["properties"]
+	}
+	s.pusher.PushToTopicCoalescing(WorkspaceTopic(workspaceID), deviceSN, &hub.Message{
+		Type:      hub.MessageTypeEvent,
+		Event:     EventDeviceOSD,
+		Data:      DeviceOSD{SN: deviceSN, Host: host},
+		TraceID:   wsMsg.TraceID,
+		Timestamp: wsMsg.Timestamp,
+	})
+}
+
 // actionToTopic converts an action to a WebSocket topic
 func (s *Service) actionToTopic(action string) string {
 	return action
@@ -361,19 +562,21 @@ func (s *Service) Pusher() *push.Pusher {
 func (s *Service) GetStats() ServiceStats {
 	pushed, dropped := s.pusher.GetMetrics()
 	return ServiceStats{
-		ConnectedClients: s.hub.GetClientCount(),
-		ActiveTopics:     s.subManager.GetTopicCount(),
-		MessagesPushed:   pushed,
-		MessagesDropped:  dropped,
-		QueueLength:      s.pusher.QueueLength(),
+		ConnectedClients:  s.hub.GetClientCount(),
+		ActiveTopics:      s.subManager.GetTopicCount(),
+		MessagesPushed:    pushed,
+		MessagesDropped:   dropped,
+		MessagesCoalesced: s.pusher.GetCoalesced(),
+		QueueLength:       s.pusher.QueueLength(),
 	}
 }
 
 // ServiceStats holds service statistics
 type ServiceStats struct {
-	ConnectedClients int   `json:"connected_clients"`
-	ActiveTopics     int   `json:"active_topics"`
-	MessagesPushed   int64 `json:"messages_pushed"`
-	MessagesDropped  int64 `json:"messages_dropped"`
-	QueueLength      int   `json:"queue_length"`
+	ConnectedClients  int   `json:"connected_clients"`
+	ActiveTopics      int   `json:"active_topics"`
+	MessagesPushed    int64 `json:"messages_pushed"`
+	MessagesDropped   int64 `json:"messages_dropped"`
+	MessagesCoalesced int64 `json:"messages_coalesced"`
+	QueueLength       int   `json:"queue_length"`
 }
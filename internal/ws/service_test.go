@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/utmos/utmos/internal/account"
 	"github.com/utmos/utmos/internal/ws/hub"
 	"github.com/utmos/utmos/pkg/rabbitmq"
 )
@@ -234,6 +235,150 @@ func TestService_HandleRabbitMQMessage(t *testing.T) {
 	})
 }
 
+func TestService_WorkspaceMessages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	svc, _, wsURL := setupAuthService(t, map[string]*account.Identity{
+		"pilot": {Subject: "user:1", Role: account.RoleViewer, WorkspaceID: "ws1"},
+	})
+
+	// Unauthenticated query parameters do not pick the workspace
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?x-auth-token=pilot&workspace_id=ws2", nil)
+	if err == nil {
+		_ = conn.Close()
+	}
+	require.Error(t, err)
+
+	conn, _, err = websocket.DefaultDialer.Dial(wsURL+"?x-auth-token=pilot", nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	require.Eventually(t, func() bool {
+		return svc.SubscriptionManager().GetTopicCount() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// Other clients of the workspace keep the hub framing
+	web, _, err := websocket.DefaultDialer.Dial(wsURL+"?token=pilot", nil)
+	require.NoError(t, err)
+	defer func() { _ = web.Close() }()
+
+	require.Eventually(t, func() bool {
+		return svc.SubscriptionManager().GetSubscriberCount(WorkspaceTopic("ws1")) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	svc.handleRabbitMQMessage(context.Background(), &rabbitmq.StandardMessage{
+		Action:      "map_element_create",
+		WorkspaceID: "ws1",
+		Timestamp:   146052438362,
+		Data:        json.RawMessage(`{"id":"e1","group_id":"g1"}`),
+	})
+
+	// DJI Pilot 2 decodes pushes by their biz_code
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	var push map[string]any
+	require.NoError(t, json.Unmarshal(data, &push))
+	assert.Equal(t, map[string]any{
+		"biz_code":  "map_element_create",
+		"version":   "1.0",
+		"timestamp": float64(146052438362),
+		"data":      map[string]any{"id": "e1", "group_id": "g1"},
+	}, push)
+
+	require.NoError(t, web.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, data, err = web.ReadMessage()
+	require.NoError(t, err)
+
+	var msg hub.Message
+	require.NoError(t, json.Unmarshal(data, &msg))
+	assert.Equal(t, hub.MessageTypeEvent, msg.Type)
+	assert.Equal(t, "map_element_create", msg.Event)
+	assert.Equal(t, map[string]any{"id": "e1", "group_id": "g1"}, msg.Data)
+}
+
+func TestService_UnauthenticatedClientJoinsNoWorkspace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	svc := NewService(nil, nil, nil)
+	require.NoError(t, svc.Start(context.Background()))
+	defer func() { _ = svc.Stop() }()
+
+	server := httptest.NewServer(http.HandlerFunc(svc.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?workspace_id=ws1"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	require.Eventually(t, func() bool {
+		return svc.Hub().GetClientCount() == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Zero(t, svc.SubscriptionManager().GetSubscriberCount(WorkspaceTopic("ws1")))
+	assert.Empty(t, svc.Hub().GetClients()[0].Metadata[MetadataWorkspaceID])
+}
+
+type staticWorkspaces map[string]string
+
+func (w staticWorkspaces) WorkspaceOf(_ context.Context, deviceSN string) (string, error) {
+	return w[deviceSN], nil
+}
+
+func TestService_DeviceOSD(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	svc := NewService(nil, nil, nil)
+	svc.SetWorkspaceResolver(staticWorkspaces{"UAV1": "ws1"})
+	svc.SetAuthenticator(&staticAuthenticator{identities: map[string]*account.Identity{
+		"pilot": {Subject: "user:1", Role: account.RoleViewer, WorkspaceID: "ws1"},
+	}})
+	require.NoError(t, svc.Start(context.Background()))
+	defer func() { _ = svc.Stop() }()
+
+	server := httptest.NewServer(http.HandlerFunc(svc.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?x-auth-token=pilot"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	require.Eventually(t, func() bool {
+		return svc.SubscriptionManager().GetTopicCount() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// Devices outside the workspace are not pushed
+	svc.handleRabbitMQMessage(context.Background(), &rabbitmq.StandardMessage{
+		Action:   actionPropertyProcessed,
+		DeviceSN: "UAV2",
+		Data:     json.RawMessage(`{"properties":{"height":10}}`),
+	})
+	svc.handleRabbitMQMessage(context.Background(), &rabbitmq.StandardMessage{
+		Action:   actionPropertyProcessed,
+		DeviceSN: "UAV1",
+		Data:     json.RawMessage(`{"properties":{"height":42.5},"events":[]}`),
+	})
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	var push hub.DJIMessage
+	require.NoError(t, json.Unmarshal(data, &push))
+	assert.Equal(t, EventDeviceOSD, push.BizCode)
+	assert.Equal(t, hub.DJIVersion, push.Version)
+	assert.NotZero(t, push.Timestamp)
+	assert.Equal(t, map[string]any{"sn": "UAV1", "host": map[string]any{"height": 42.5}}, push.Data)
+}
+
 func TestService_Accessors(t *testing.T) {
 	svc := NewService(nil, nil, nil)
 
@@ -298,6 +443,38 @@ func TestService_OnClientMessage(t *testing.T) {
 		assert.False(t, svc.SubscriptionManager().IsSubscribed(client.ID, "device.telemetry"))
 	})
 
+	t.Run("subscribe with filter", func(t *testing.T) {
+		svc.onClientMessage(client, &hub.Message{
+			Type:  hub.MessageTypeSubscribe,
+			Event: "device.*.property.processed",
+			Data:  map[string]any{"properties": []any{"battery.capacity_percent"}},
+		})
+
+		matched := svc.SubscriptionManager().Match("device.UAV1.property.processed")
+		require.Len(t, matched, 1)
+		assert.Equal(t, client.ID, matched[0].ClientID)
+		require.Len(t, matched[0].Filters, 1)
+		assert.Equal(t, []string{"battery.capacity_percent"}, matched[0].Filters[0].Properties)
+	})
+
+	t.Run("subscribe with invalid filter", func(t *testing.T) {
+		c := hub.NewClient("filter-client", nil, nil, nil, nil)
+		svc.onClientMessage(c, &hub.Message{
+			Type:  hub.MessageTypeSubscribe,
+			Event: "device.#",
+			Data:  map[string]any{"level": 2},
+		})
+
+		assert.False(t, svc.SubscriptionManager().IsSubscribed(c.ID, "device.#"))
+
+		svc.onClientMessage(c, &hub.Message{
+			Type:  hub.MessageTypeSubscribe,
+			Event: "device.#",
+			Data:  map[string]any{"max_rate": -1},
+		})
+		assert.False(t, svc.SubscriptionManager().IsSubscribed(c.ID, "device.#"))
+	})
+
 	t.Run("other message type", func(t *testing.T) {
 		msg := &hub.Message{
 			Type:  hub.MessageTypeEvent,
@@ -0,0 +1,289 @@
+package subscription
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Message data keys filters apply to. Uplink telemetry carries properties
+// and events; device_osd workspace pushes carry properties as host.
+const (
+	dataKeyProperties = "properties"
+	dataKeyHost       = "host"
+	dataKeyEvents     = "events"
+)
+
+// ErrInvalidFilter is returned for filters with negative rates or levels
+var ErrInvalidFilter = errors.New("invalid subscription filter")
+
+// Filter narrows the messages delivered to a subscription, so clients only
+// receive the telemetry they display. A filter keeps what it names: with
+// only Properties set events are dropped, and with only Events or MinLevel
+// set properties are dropped. Messages left with neither are not delivered.
+// Messages without properties or events, such as workspace notifications,
+// are delivered unchanged.
+//
+// MaxRate and Interval limit how often telemetry of each device is
+// delivered; superseded telemetry is coalesced to the latest value. Events
+// are never rate limited.
+type Filter struct {
+	// Properties keeps these properties. Dotted names select nested values,
+	// e.g. "battery.capacity_percent".
+	Properties []string `json:"properties,omitempty"`
+	// Events keeps events with these names, e.g. "hms"
+	Events []string `json:"events,omitempty"`
+	// MinLevel drops event list items, such as HMS alerts, below this level
+	// and events left without items
+	MinLevel int `json:"min_level,omitempty"`
+	// MaxRate is the most telemetry messages per second per device
+	MaxRate float64 `json:"max_rate,omitempty"`
+	// Interval is the least time between telemetry messages per device, in
+	// seconds
+	Interval float64 `json:"interval,omitempty"`
+}
+
+// Validate checks that rates and levels are not negative
+func (f *Filter) Validate() error {
+	if f.MinLevel < 0 || f.MaxRate < 0 || f.Interval < 0 {
+		return fmt.Errorf("%w: min_level, max_rate and interval must not be negative", ErrInvalidFilter)
+	}
+	return nil
+}
+
+// IsEmpty reports whether the filter delivers every message unchanged
+func (f *Filter) IsEmpty() bool {
+	return f == nil || (!f.selectsContent() && f.Period() == 0)
+}
+
+// Period returns the least time between telemetry messages per device, or
+// zero when telemetry is not rate limited
+func (f *Filter) Period() time.Duration {
+	period := time.Duration(f.Interval * float64(time.Second))
+	if f.MaxRate > 0 {
+		period = max(period, time.Duration(float64(time.Second)/f.MaxRate))
+	}
+	return period
+}
+
+// selectsContent reports whether the filter narrows message data
+func (f *Filter) selectsContent() bool {
+	return len(f.Properties) > 0 || f.keepsEvents()
+}
+
+// keepsEvents reports whether the filter selects events
+func (f *Filter) keepsEvents() bool {
+	return len(f.Events) > 0 || f.MinLevel > 0
+}
+
+// Filters are the filters of the subscriptions a client matched a topic
+// with. A message is delivered with everything any of them keeps. A nil
+// Filters delivers every message unchanged.
+type Filters []*Filter
+
+// mergeFilters adds the filter of another matching subscription. Unfiltered
+// subscriptions make the merged result unfiltered.
+func mergeFilters(existing Filters, seen bool, filter *Filter) Filters {
+	if filter == nil || (seen && existing == nil) {
+		return nil
+	}
+	if slices.Contains(existing, filter) {
+		return existing
+	}
+	return append(existing, filter)
+}
+
+// Period returns the least time between telemetry messages per device
+// that satisfies every filter, or zero when telemetry is not rate limited
+func (fs Filters) Period() time.Duration {
+	if len(fs) == 0 {
+		return 0
+	}
+	period := fs[0].Period()
+	for _, f := range fs[1:] {
+		period = min(period, f.Period())
+	}
+	return period
+}
+
+// Apply filters message data. It returns the data to deliver and false when
+// nothing the filters keep is left.
+func (fs Filters) Apply(data any) (any, bool) {
+	if fs == nil || slices.ContainsFunc(fs, func(f *Filter) bool { return !f.selectsContent() }) {
+		return data, true
+	}
+	m, ok := toMap(data)
+	if !ok {
+		return data, true
+	}
+
+	out := make(map[string]any, len(m))
+	filtered, kept := false, false
+	for key, value := range m {
+		switch key {
+		case dataKeyProperties, dataKeyHost:
+			filtered = true
+			props, _ := value.(map[string]any)
+			selected := fs.selectProperties(props)
+			if len(selected) > 0 {
+				out[key] = selected
+				kept = true
+			}
+		case dataKeyEvents:
+			filtered = true
+			events, _ := value.([]any)
+			selected := fs.selectEvents(events)
+			if len(selected) > 0 {
+				out[key] = selected
+				kept = true
+			}
+		default:
+			out[key] = value
+		}
+	}
+
+	if !filtered {
+		return data, true
+	}
+	return out, kept
+}
+
+// selectProperties returns the properties any filter keeps
+func (fs Filters) selectProperties(props map[string]any) map[string]any {
+	selected := make(map[string]any)
+	for _, f := range fs {
+		for _, path := range f.Properties {
+			if value, ok := lookupPath(props, path); ok {
+				setPath(selected, path, value)
+			}
+		}
+	}
+	return selected
+}
+
+// selectEvents returns the events any filter keeps, with list items below
+// the lowest level the filters keeping the event accept removed
+func (fs Filters) selectEvents(events []any) []any {
+	selected := make([]any, 0, len(events))
+	for _, e := range events {
+		event, ok := e.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _ := event["name"].(string)
+		minLevel, keep := fs.eventMinLevel(name)
+		if !keep {
+			continue
+		}
+		if minLevel <= 0 {
+			selected = append(selected, event)
+			continue
+		}
+		if event, ok = filterEventLevel(event, minLevel); ok {
+			selected = append(selected, event)
+		}
+	}
+	return selected
+}
+
+// eventMinLevel returns the lowest level of the filters keeping events
+// named name, and false when none keeps them
+func (fs Filters) eventMinLevel(name string) (int, bool) {
+	minLevel, keep := 0, false
+	for _, f := range fs {
+		if !f.keepsEvents() || (len(f.Events) > 0 && !slices.Contains(f.Events, name)) {
+			continue
+		}
+		if !keep || f.MinLevel < minLevel {
+			minLevel = f.MinLevel
+		}
+		keep = true
+	}
+	return minLevel, keep
+}
+
+// filterEventLevel removes the items of an event's params.list below
+// minLevel. Events without a list are kept; events left with an empty
+// list are not.
+func filterEventLevel(event map[string]any, minLevel int) (map[string]any, bool) {
+	params, _ := event["params"].(map[string]any)
+	list, ok := params["list"].([]any)
+	if !ok {
+		return event, true
+	}
+
+	items := make([]any, 0, len(list))
+	for _, i := range list {
+		item, _ := i.(map[string]any)
+		if level, ok := item["level"].(float64); ok && level >= float64(minLevel) {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return nil, false
+	}
+
+	filteredParams := make(map[string]any, len(params))
+	for k, v := range params {
+		filteredParams[k] = v
+	}
+	filteredParams["list"] = items
+	filteredEvent := make(map[string]any, len(event))
+	for k, v := range event {
+		filteredEvent[k] = v
+	}
+	filteredEvent["params"] = filteredParams
+	return filteredEvent, true
+}
+
+// lookupPath returns the value at a dotted path
+func lookupPath(m map[string]any, path string) (any, bool) {
+	var value any = m
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// setPath sets the value at a dotted path, creating intermediate objects
+func setPath(m map[string]any, path string, value any) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := m[key].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			m[key] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = value
+}
+
+// toMap returns message data as a JSON object. Typed payloads are converted
+// through JSON.
+func toMap(data any) (map[string]any, bool) {
+	if m, ok := data.(map[string]any); ok {
+		return m, true
+	}
+	if data == nil {
+		return nil, false
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, false
+	}
+	var m map[string]any
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, false
+	}
+	return m, true
+}
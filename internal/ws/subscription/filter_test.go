@@ -0,0 +1,138 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func osdData() map[string]any {
+	return map[string]any{
+		"properties": map[string]any{
+			"latitude":  22.5,
+			"longitude": 113.9,
+			"battery":   map[string]any{"capacity_percent": 80.0, "temperature": 30.0},
+		},
+		"events": []any{
+			map[string]any{"name": "hms", "params": map[string]any{"list": []any{
+				map[string]any{"code": "0x1", "level": 0.0},
+				map[string]any{"code": "0x2", "level": 2.0},
+			}}},
+			map[string]any{"name": "flighttask_progress", "params": map[string]any{"progress": 50.0}},
+		},
+	}
+}
+
+func TestFilter_IsEmpty(t *testing.T) {
+	var f *Filter
+	assert.True(t, f.IsEmpty())
+	assert.True(t, (&Filter{}).IsEmpty())
+	assert.False(t, (&Filter{MinLevel: 1}).IsEmpty())
+	assert.False(t, (&Filter{Properties: []string{"latitude"}}).IsEmpty())
+	assert.False(t, (&Filter{MaxRate: 2}).IsEmpty())
+}
+
+func TestFilter_Period(t *testing.T) {
+	assert.Zero(t, (&Filter{}).Period())
+	assert.Equal(t, 500*time.Millisecond, (&Filter{MaxRate: 2}).Period())
+	assert.Equal(t, 10*time.Second, (&Filter{Interval: 10}).Period())
+	assert.Equal(t, 10*time.Second, (&Filter{Interval: 10, MaxRate: 2}).Period(), "the stricter limit applies")
+
+	assert.Zero(t, Filters(nil).Period())
+	assert.Equal(t, 500*time.Millisecond, Filters{{MaxRate: 2}, {Interval: 10}}.Period())
+	assert.Zero(t, Filters{{MaxRate: 2}, {Properties: []string{"latitude"}}}.Period())
+}
+
+func TestFilter_Validate(t *testing.T) {
+	assert.NoError(t, (&Filter{MaxRate: 2, Interval: 1, MinLevel: 1}).Validate())
+	assert.ErrorIs(t, (&Filter{MaxRate: -1}).Validate(), ErrInvalidFilter)
+	assert.ErrorIs(t, (&Filter{Interval: -1}).Validate(), ErrInvalidFilter)
+	assert.ErrorIs(t, (&Filter{MinLevel: -1}).Validate(), ErrInvalidFilter)
+}
+
+func TestFilters_Apply(t *testing.T) {
+	t.Run("unfiltered", func(t *testing.T) {
+		data, ok := Filters(nil).Apply(osdData())
+		assert.True(t, ok)
+		assert.Equal(t, osdData(), data)
+	})
+
+	t.Run("properties", func(t *testing.T) {
+		data, ok := Filters{{Properties: []string{"battery.capacity_percent", "latitude", "missing.value"}}}.Apply(osdData())
+		assert.True(t, ok)
+		assert.Equal(t, map[string]any{
+			"properties": map[string]any{
+				"latitude": 22.5,
+				"battery":  map[string]any{"capacity_percent": 80.0},
+			},
+		}, data)
+	})
+
+	t.Run("hms level", func(t *testing.T) {
+		data, ok := Filters{{Events: []string{"hms"}, MinLevel: 2}}.Apply(osdData())
+		assert.True(t, ok)
+		assert.Equal(t, map[string]any{
+			"events": []any{
+				map[string]any{"name": "hms", "params": map[string]any{"list": []any{
+					map[string]any{"code": "0x2", "level": 2.0},
+				}}},
+			},
+		}, data)
+	})
+
+	t.Run("nothing left", func(t *testing.T) {
+		_, ok := Filters{{Events: []string{"hms"}, MinLevel: 3}}.Apply(osdData())
+		assert.False(t, ok)
+		_, ok = Filters{{Properties: []string{"height"}}}.Apply(osdData())
+		assert.False(t, ok)
+	})
+
+	t.Run("union", func(t *testing.T) {
+		data, ok := Filters{
+			{Properties: []string{"latitude"}},
+			{Events: []string{"flighttask_progress"}},
+			{MinLevel: 2},
+		}.Apply(osdData())
+		assert.True(t, ok)
+		m, _ := data.(map[string]any)
+		assert.Equal(t, map[string]any{"latitude": 22.5}, m["properties"])
+		events, _ := m["events"].([]any)
+		assert.Len(t, events, 2, "both events kept, with flighttask_progress unleveled")
+	})
+
+	t.Run("typed payload", func(t *testing.T) {
+		osd := struct {
+			Host map[string]any `json:"host"`
+			SN   string         `json:"sn"`
+		}{Host: map[string]any{"latitude": 1.5, "height": 10}, SN: "UAV1"}
+		data, ok := Filters{{Properties: []string{"latitude"}}}.Apply(osd)
+		assert.True(t, ok)
+		assert.Equal(t, map[string]any{"sn": "UAV1", "host": map[string]any{"latitude": 1.5}}, data)
+	})
+
+	t.Run("rate only", func(t *testing.T) {
+		data, ok := Filters{{Properties: []string{"latitude"}}, {MaxRate: 2}}.Apply(osdData())
+		assert.True(t, ok)
+		assert.Equal(t, osdData(), data, "a subscription without content filters keeps everything")
+	})
+
+	t.Run("other payloads pass", func(t *testing.T) {
+		payload := map[string]any{"id": "e1"}
+		data, ok := Filters{{Properties: []string{"latitude"}}}.Apply(payload)
+		assert.True(t, ok)
+		assert.Equal(t, payload, data)
+
+		data, ok = Filters{{Properties: []string{"latitude"}}}.Apply("text")
+		assert.True(t, ok)
+		assert.Equal(t, "text", data)
+	})
+}
+
+func TestIsPattern(t *testing.T) {
+	assert.True(t, IsPattern("device.*.property"))
+	assert.True(t, IsPattern("device.#"))
+	assert.True(t, IsPattern("device.**"))
+	assert.False(t, IsPattern("device.drone1.property"))
+	assert.False(t, IsPattern("device.dr*ne"))
+}
@@ -18,14 +18,18 @@ func deleteFromNestedMap[K comparable, V comparable](m map[K]map[V]bool, key K,
 	}
 }
 
-// Manager manages client subscriptions to topics
+// Manager manages client subscriptions to topics. Subscriptions may use
+// wildcards: "*" matches one topic segment and a trailing "#" any number of
+// segments, e.g. "device.*.property.processed" or "device.#".
 type Manager struct {
 	// topic -> set of client IDs
 	topics map[string]map[string]bool
 	// client ID -> set of topics
 	clients map[string]map[string]bool
-	mu      sync.RWMutex
-	logger  *logrus.Entry
+	// index finds the subscriptions matching published topics
+	index  *trieNode
+	mu     sync.RWMutex
+	logger *logrus.Entry
 }
 
 // NewManager creates a new subscription manager
@@ -37,12 +41,27 @@ func NewManager(logger *logrus.Entry) *Manager {
 	return &Manager{
 		topics:  make(map[string]map[string]bool),
 		clients: make(map[string]map[string]bool),
+		index:   newTrieNode(),
 		logger:  logger.WithField("component", "subscription-manager"),
 	}
 }
 
-// Subscribe subscribes a client to a topic
-func (m *Manager) Subscribe(clientID, topic string) {
+// Subscribe subscribes a client to a topic or topic pattern
+func (m *Manager) Subscribe(clientID, topic string) error {
+	return m.SubscribeWithFilter(clientID, topic, nil)
+}
+
+// SubscribeWithFilter subscribes a client to a topic or topic pattern,
+// delivering only what filter keeps. Subscribing again to the same topic
+// replaces the filter. Topics failing ValidateTopic are rejected.
+func (m *Manager) SubscribeWithFilter(clientID, topic string, filter *Filter) error {
+	if err := ValidateTopic(topic); err != nil {
+		return err
+	}
+	if filter.IsEmpty() {
+		filter = nil
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -58,10 +77,13 @@ func (m *Manager) Subscribe(clientID, topic string) {
 	}
 	m.clients[clientID][topic] = true
 
+	m.index.insert(splitTopic(topic), clientID, filter)
+
 	m.logger.WithFields(logrus.Fields{
 		"client_id": clientID,
 		"topic":     topic,
 	}).Debug("Client subscribed to topic")
+	return nil
 }
 
 // Unsubscribe unsubscribes a client from a topic
@@ -75,6 +97,8 @@ func (m *Manager) Unsubscribe(clientID, topic string) {
 	// Remove from clients map
 	deleteFromNestedMap(m.clients, clientID, topic)
 
+	m.index.remove(splitTopic(topic), clientID)
+
 	m.logger.WithFields(logrus.Fields{
 		"client_id": clientID,
 		"topic":     topic,
@@ -94,6 +118,7 @@ func (m *Manager) UnsubscribeAll(clientID string) {
 	// Remove client from all topics
 	for topic := range topics {
 		deleteFromNestedMap(m.topics, topic, clientID)
+		m.index.remove(splitTopic(topic), clientID)
 	}
 
 	// Remove client entry
@@ -119,9 +144,57 @@ func (m *Manager) getKeysFromMap(lookup map[string]map[string]bool, key string)
 	return result
 }
 
-// GetSubscribers returns all client IDs subscribed to a topic
+// GetSubscribers returns all client IDs with a subscription matching a
+// published topic
 func (m *Manager) GetSubscribers(topic string) []string {
-	return m.getKeysFromMap(m.topics, topic)
+	subscribers := m.Match(topic)
+	if len(subscribers) == 0 {
+		return nil
+	}
+
+	result := make([]string, 0, len(subscribers))
+	for _, s := range subscribers {
+		result = append(result, s.ClientID)
+	}
+	return result
+}
+
+// Subscriber is a client with subscriptions matching a published topic
+type Subscriber struct {
+	ClientID string
+	// Filters are the filters of the matching subscriptions; nil when any
+	// of them is unfiltered
+	Filters Filters
+}
+
+// Match returns the clients with a subscription matching a published topic
+// and the filters of their matching subscriptions. The result is shared and
+// must not be modified.
+func (m *Manager) Match(topic string) []Subscriber {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	nodes := m.index.collect(splitTopic(topic), nil)
+	switch len(nodes) {
+	case 0:
+		return nil
+	case 1:
+		// Most topics match a single subscription, which needs no merging
+		return nodes[0].snapshot
+	}
+
+	merged := make(map[string]Filters)
+	for _, node := range nodes {
+		for clientID, filter := range node.subscribers {
+			existing, seen := merged[clientID]
+			merged[clientID] = mergeFilters(existing, seen, filter)
+		}
+	}
+	result := make([]Subscriber, 0, len(merged))
+	for clientID, filters := range merged {
+		result = append(result, Subscriber{ClientID: clientID, Filters: filters})
+	}
+	return result
 }
 
 // GetSubscribersMatching returns all client IDs subscribed to topics matching a pattern
@@ -2,6 +2,7 @@ package subscription
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 
@@ -269,6 +270,43 @@ func TestManager_ConcurrentAccess(t *testing.T) {
 	assert.Equal(t, 0, manager.GetClientCount())
 }
 
+func TestValidateTopic(t *testing.T) {
+	valid := []string{
+		"device.drone1.property",
+		"device.*.property",
+		"device.#",
+		"device.**",
+		"#",
+		strings.Repeat("a.", MaxTopicSegments-1) + "#",
+		strings.Repeat("a", MaxTopicLength),
+	}
+	for _, topic := range valid {
+		assert.NoError(t, ValidateTopic(topic), topic)
+	}
+
+	invalid := []string{
+		"device.#.value",
+		"#.property",
+		"device.**.value",
+		"#.#",
+		strings.Repeat("a.", MaxTopicSegments) + "a",
+		strings.Repeat("a", MaxTopicLength+1),
+	}
+	for _, topic := range invalid {
+		assert.ErrorIs(t, ValidateTopic(topic), ErrInvalidTopic, topic)
+	}
+}
+
+func TestManager_RejectsInvalidTopics(t *testing.T) {
+	manager := NewManager(nil)
+
+	assert.ErrorIs(t, manager.Subscribe("client1", strings.Repeat("#.", 20)+"#"), ErrInvalidTopic)
+	assert.ErrorIs(t, manager.SubscribeWithFilter("client1", strings.Repeat("x", MaxTopicLength+1), nil), ErrInvalidTopic)
+	assert.Zero(t, manager.GetTopicCount())
+	assert.Empty(t, manager.GetTopics("client1"))
+	assert.Empty(t, manager.index.children)
+}
+
 func TestManager_CleanupEmptyTopics(t *testing.T) {
 	manager := NewManager(nil)
 
@@ -278,3 +316,69 @@ func TestManager_CleanupEmptyTopics(t *testing.T) {
 	manager.Unsubscribe("client1", "topic1")
 	assert.Equal(t, 0, manager.GetTopicCount())
 }
+
+func TestManager_WildcardSubscriptions(t *testing.T) {
+	manager := NewManager(nil)
+
+	manager.Subscribe("exact", "device.drone1.property")
+	manager.Subscribe("single", "device.*.property")
+	manager.Subscribe("tail", "device.#")
+	manager.Subscribe("alias", "device.**")
+	manager.Subscribe("other", "system.*")
+	assert.ErrorIs(t, manager.Subscribe("middle", "device.#.value"), ErrInvalidTopic)
+
+	tests := []struct {
+		topic    string
+		expected []string
+	}{
+		{"device.drone1.property", []string{"exact", "single", "tail", "alias"}},
+		{"device.drone2.property", []string{"single", "tail", "alias"}},
+		{"device", []string{"tail", "alias"}},
+		{"device.drone1.property.value", []string{"tail", "alias"}},
+		{"device.value", []string{"tail", "alias"}},
+		{"system.health", []string{"other"}},
+		{"system.health.cpu", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			assert.ElementsMatch(t, tt.expected, manager.GetSubscribers(tt.topic))
+		})
+	}
+
+	manager.Unsubscribe("single", "device.*.property")
+	manager.UnsubscribeAll("tail")
+	manager.UnsubscribeAll("alias")
+	assert.ElementsMatch(t, []string{"exact"}, manager.GetSubscribers("device.drone1.property"))
+
+	manager.UnsubscribeAll("exact")
+	manager.UnsubscribeAll("other")
+	assert.Empty(t, manager.index.children, "empty trie nodes are pruned")
+}
+
+func TestManager_Match(t *testing.T) {
+	manager := NewManager(nil)
+	battery := &Filter{Properties: []string{"battery.capacity_percent"}}
+	hms := &Filter{Events: []string{"hms"}, MinLevel: 2}
+
+	manager.SubscribeWithFilter("client1", "device.*.property", battery)
+	manager.SubscribeWithFilter("client1", "device.#", hms)
+	manager.SubscribeWithFilter("client2", "device.*.property", battery)
+	manager.Subscribe("client2", "device.drone1.property")
+	manager.SubscribeWithFilter("client3", "device.drone1.property", &Filter{})
+
+	matched := make(map[string]Filters)
+	for _, s := range manager.Match("device.drone1.property") {
+		matched[s.ClientID] = s.Filters
+	}
+	require.Len(t, matched, 3)
+	assert.ElementsMatch(t, Filters{battery, hms}, matched["client1"])
+	assert.Nil(t, matched["client2"], "an unfiltered subscription delivers everything")
+	assert.Nil(t, matched["client3"], "empty filters deliver everything")
+
+	// Subscribing again replaces the filter
+	manager.SubscribeWithFilter("client1", "device.#", battery)
+	manager.UnsubscribeAll("client2")
+	manager.UnsubscribeAll("client3")
+	assert.Equal(t, []Subscriber{{ClientID: "client1", Filters: Filters{battery}}}, manager.Match("device.drone1.property"))
+	assert.Equal(t, []Subscriber{{ClientID: "client1", Filters: Filters{battery}}}, manager.Match("device.drone1.osd"))
+}
@@ -0,0 +1,151 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Wildcard segments of subscription topics
+const (
+	// WildcardSegment matches exactly one topic segment
+	WildcardSegment = "*"
+	// WildcardTail matches zero or more topic segments
+	WildcardTail = "#"
+	// wildcardTailAlias is the GetSubscribersMatching spelling of WildcardTail
+	wildcardTailAlias = "**"
+)
+
+// Limits on subscription topics, which bound the trie depth and the work of
+// matching a published topic
+const (
+	MaxTopicLength   = 256
+	MaxTopicSegments = 16
+)
+
+// ErrInvalidTopic is returned for subscription topics that are too long or
+// use "#" other than as their last segment.
+var ErrInvalidTopic = errors.New("invalid subscription topic")
+
+// ValidateTopic checks that a subscription topic is within the length and
+// segment limits and that "#" only appears as its last segment.
+func ValidateTopic(topic string) error {
+	if len(topic) > MaxTopicLength {
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidTopic, MaxTopicLength)
+	}
+	segments := strings.Split(topic, ".")
+	if len(segments) > MaxTopicSegments {
+		return fmt.Errorf("%w: more than %d segments", ErrInvalidTopic, MaxTopicSegments)
+	}
+	for i, s := range segments {
+		if (s == WildcardTail || s == wildcardTailAlias) && i != len(segments)-1 {
+			return fmt.Errorf("%w: %q is only allowed as the last segment", ErrInvalidTopic, WildcardTail)
+		}
+	}
+	return nil
+}
+
+// trieNode indexes subscriptions by topic segment, so the subscribers of a
+// published topic are found by walking its segments rather than scanning
+// every subscription
+type trieNode struct {
+	children map[string]*trieNode
+	// subscribers maps client IDs subscribed to the topic ending at this
+	// node to their filter; nil filters deliver every message
+	subscribers map[string]*Filter
+	// snapshot lists subscribers for publishing without copying. It is
+	// replaced, never modified, when subscribers change.
+	snapshot []Subscriber
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{
+		children:    make(map[string]*trieNode),
+		subscribers: make(map[string]*Filter),
+	}
+}
+
+// splitTopic splits a topic into trie segments
+func splitTopic(topic string) []string {
+	segments := strings.Split(topic, ".")
+	for i, s := range segments {
+		if s == wildcardTailAlias {
+			segments[i] = WildcardTail
+		}
+	}
+	return segments
+}
+
+// IsPattern reports whether a topic contains wildcard segments
+func IsPattern(topic string) bool {
+	for _, s := range strings.Split(topic, ".") {
+		if s == WildcardSegment || s == WildcardTail || s == wildcardTailAlias {
+			return true
+		}
+	}
+	return false
+}
+
+// insert adds a client's subscription to topic
+func (n *trieNode) insert(segments []string, clientID string, filter *Filter) {
+	node := n
+	for _, s := range segments {
+		child, ok := node.children[s]
+		if !ok {
+			child = newTrieNode()
+			node.children[s] = child
+		}
+		node = child
+	}
+	node.subscribers[clientID] = filter
+	node.updateSnapshot()
+}
+
+// remove deletes a client's subscription to topic and prunes nodes left
+// without subscribers. It reports whether n itself is now empty.
+func (n *trieNode) remove(segments []string, clientID string) bool {
+	if len(segments) == 0 {
+		if _, ok := n.subscribers[clientID]; ok {
+			delete(n.subscribers, clientID)
+			n.updateSnapshot()
+		}
+	} else if child, ok := n.children[segments[0]]; ok && child.remove(segments[1:], clientID) {
+		delete(n.children, segments[0])
+	}
+	return len(n.subscribers) == 0 && len(n.children) == 0
+}
+
+// updateSnapshot rebuilds the subscriber snapshot
+func (n *trieNode) updateSnapshot() {
+	snapshot := make([]Subscriber, 0, len(n.subscribers))
+	for clientID, filter := range n.subscribers {
+		snapshot = append(snapshot, Subscriber{ClientID: clientID, Filters: mergeFilters(nil, false, filter)})
+	}
+	n.snapshot = snapshot
+}
+
+// collect appends the nodes holding subscriptions that match the published
+// topic segments
+func (n *trieNode) collect(segments []string, nodes []*trieNode) []*trieNode {
+	// # is always the last segment of a subscription, so it matches the
+	// remaining segments without walking them
+	if tail, ok := n.children[WildcardTail]; ok && len(tail.subscribers) > 0 && !slices.Contains(nodes, tail) {
+		nodes = append(nodes, tail)
+	}
+
+	if len(segments) == 0 {
+		if len(n.subscribers) > 0 && !slices.Contains(nodes, n) {
+			nodes = append(nodes, n)
+		}
+		return nodes
+	}
+
+	if child, ok := n.children[segments[0]]; ok {
+		nodes = child.collect(segments[1:], nodes)
+	}
+	if child, ok := n.children[WildcardSegment]; ok && segments[0] != WildcardSegment {
+		nodes = child.collect(segments[1:], nodes)
+	}
+	return nodes
+}
@@ -0,0 +1,163 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/ws/hub"
+	"github.com/utmos/utmos/internal/ws/subscription"
+	"github.com/utmos/utmos/pkg/models"
+	"github.com/utmos/utmos/pkg/rabbitmq"
+)
+
+// RoutingKeyWorkspace is the routing key other services publish
+// workspace-scoped notifications with
+const RoutingKeyWorkspace = "iot.ws.workspace"
+
+// MetadataWorkspaceID is the client metadata key holding the workspace a
+// client joined on connect
+const MetadataWorkspaceID = "workspace_id"
+
+// EventDeviceOSD is the event device telemetry is pushed to the device's
+// workspace with, named after the DJI Pilot 2 biz code
+const EventDeviceOSD = "device_osd"
+
+// actionPropertyProcessed is the action of telemetry routed by iot-uplink
+const actionPropertyProcessed = "property.processed"
+
+// DefaultWorkspaceCacheTTL is how long DeviceWorkspaces remembers a device's
+// workspace
+const DefaultWorkspaceCacheTTL = time.Minute
+
+// WorkspaceResolver returns the workspace a device belongs to, or an empty
+// string for devices outside any workspace
+type WorkspaceResolver interface {
+	WorkspaceOf(ctx context.Context, deviceSN string) (string, error)
+}
+
+// DeviceOSD is the payload of device_osd events
+type DeviceOSD struct {
+	SN   string `json:"sn"`
+	Host any    `json:"host"`
+}
+
+// WorkspaceTopic returns the topic every client of a workspace is subscribed to
+func WorkspaceTopic(workspaceID string) string {
+	return "workspace." + workspaceID
+}
+
+// topicAllowed reports whether a client may subscribe to topic. Clients
+// that joined a workspace are confined to its topic and to the topics of
+// its devices, and may not use a wildcard for the device; other clients may
+// subscribe to anything.
+func (s *Service) topicAllowed(ctx context.Context, client *hub.Client, topic string) bool {
+	workspaceID := client.Metadata[MetadataWorkspaceID]
+	if workspaceID == "" {
+		return true
+	}
+	if topic == WorkspaceTopic(workspaceID) {
+		return true
+	}
+
+	rest, ok := strings.CutPrefix(topic, "device.")
+	if !ok || s.workspaces == nil {
+		return false
+	}
+	deviceSN, _, _ := strings.Cut(rest, ".")
+	if deviceSN == "" || subscription.IsPattern(deviceSN) {
+		return false
+	}
+	owner, err := s.workspaces.WorkspaceOf(ctx, deviceSN)
+	if err != nil {
+		s.logger.WithError(err).WithField("device_sn", deviceSN).Warn("Failed to resolve device workspace")
+		return false
+	}
+	return owner == workspaceID
+}
+
+// Notifier publishes workspace-scoped events to iot-ws through RabbitMQ.
+// iot-ws pushes them to every client of the workspace with the event name
+// in the message's event field, or as the biz_code of the DJI envelope to
+// DJI Pilot 2 clients.
+type Notifier struct {
+	publisher *rabbitmq.Publisher
+	service   string
+}
+
+// NewNotifier creates a new workspace notifier. service names the sender.
+func NewNotifier(publisher *rabbitmq.Publisher, service string) *Notifier {
+	return &Notifier{
+		publisher: publisher,
+		service:   service,
+	}
+}
+
+// NotifyWorkspace publishes event with data to the workspace's clients
+func (n *Notifier) NotifyWorkspace(ctx context.Context, workspaceID, event string, data any) error {
+	msg, err := rabbitmq.NewStandardMessage(n.service, event, "", data)
+	if err != nil {
+		return fmt.Errorf("failed to create workspace message: %w", err)
+	}
+	msg.WorkspaceID = workspaceID
+
+	if err := n.publisher.Publish(ctx, RoutingKeyWorkspace, msg); err != nil {
+		return fmt.Errorf("failed to publish workspace message: %w", err)
+	}
+	return nil
+}
+
+type cachedWorkspace struct {
+	expires     time.Time
+	workspaceID string
+}
+
+// DeviceWorkspaces resolves device workspaces from the devices table. Results,
+// including devices outside any workspace, are cached so telemetry at OSD
+// rates does not reach the database.
+type DeviceWorkspaces struct {
+	db    *gorm.DB
+	cache map[string]cachedWorkspace
+	ttl   time.Duration
+	mu    sync.Mutex
+}
+
+// NewDeviceWorkspaces creates a new database backed workspace resolver. A
+// non-positive ttl uses DefaultWorkspaceCacheTTL.
+func NewDeviceWorkspaces(db *gorm.DB, ttl time.Duration) *DeviceWorkspaces {
+	if ttl <= 0 {
+		ttl = DefaultWorkspaceCacheTTL
+	}
+	return &DeviceWorkspaces{
+		db:    db,
+		cache: make(map[string]cachedWorkspace),
+		ttl:   ttl,
+	}
+}
+
+// WorkspaceOf returns the workspace of the device
+func (r *DeviceWorkspaces) WorkspaceOf(ctx context.Context, deviceSN string) (string, error) {
+	now := time.Now()
+	r.mu.Lock()
+	cached, ok := r.cache[deviceSN]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.workspaceID, nil
+	}
+
+	var device models.Device
+	err := r.db.WithContext(ctx).Select("workspace_id").Where("device_sn = ?", deviceSN).First(&device).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", fmt.Errorf("failed to get device workspace: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cache[deviceSN] = cachedWorkspace{expires: now.Add(r.ttl), workspaceID: device.WorkspaceID}
+	r.mu.Unlock()
+	return device.WorkspaceID, nil
+}
@@ -0,0 +1,77 @@
+package ws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/utmos/utmos/internal/ws/hub"
+	"github.com/utmos/utmos/pkg/models"
+)
+
+func TestDeviceWorkspaces_WorkspaceOf(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Device{}))
+	require.NoError(t, db.Create(&models.Device{
+		DeviceSN: "UAV1", DeviceName: "UAV 1", DeviceType: "aircraft", WorkspaceID: "ws1",
+	}).Error)
+
+	resolver := NewDeviceWorkspaces(db, time.Hour)
+	ctx := context.Background()
+
+	workspaceID, err := resolver.WorkspaceOf(ctx, "UAV1")
+	require.NoError(t, err)
+	assert.Equal(t, "ws1", workspaceID)
+
+	workspaceID, err = resolver.WorkspaceOf(ctx, "UNKNOWN")
+	require.NoError(t, err)
+	assert.Empty(t, workspaceID)
+
+	require.NoError(t, db.Model(&models.Device{}).Where("device_sn = ?", "UAV1").Update("workspace_id", "ws2").Error)
+	workspaceID, err = resolver.WorkspaceOf(ctx, "UAV1")
+	require.NoError(t, err)
+	assert.Equal(t, "ws1", workspaceID, "cached until the TTL expires")
+}
+
+func TestService_TopicAllowed(t *testing.T) {
+	svc := NewService(nil, nil, nil)
+	svc.SetWorkspaceResolver(staticWorkspaces{"UAV1": "ws1", "UAV2": "ws2"})
+	ctx := context.Background()
+
+	unscoped := &hub.Client{ID: "c1"}
+	assert.True(t, svc.topicAllowed(ctx, unscoped, "device.UAV2.property.processed"))
+	assert.True(t, svc.topicAllowed(ctx, unscoped, "property.processed"))
+	assert.True(t, svc.topicAllowed(ctx, unscoped, "device.*.property.processed"))
+
+	scoped := &hub.Client{ID: "c2", Metadata: hub.Metadata{MetadataWorkspaceID: "ws1"}}
+	assert.True(t, svc.topicAllowed(ctx, scoped, WorkspaceTopic("ws1")))
+	assert.True(t, svc.topicAllowed(ctx, scoped, "device.UAV1.property.processed"))
+	assert.False(t, svc.topicAllowed(ctx, scoped, WorkspaceTopic("ws2")))
+	assert.False(t, svc.topicAllowed(ctx, scoped, "device.UAV2.property.processed"))
+	assert.False(t, svc.topicAllowed(ctx, scoped, "device.*.property.processed"))
+	assert.False(t, svc.topicAllowed(ctx, scoped, "device.#"))
+	assert.True(t, svc.topicAllowed(ctx, scoped, "device.UAV1.#"))
+	assert.False(t, svc.topicAllowed(ctx, scoped, "property.processed"))
+}
+
+func TestService_OnClientMessage_WorkspaceScope(t *testing.T) {
+	svc := NewService(nil, nil, nil)
+	svc.SetWorkspaceResolver(staticWorkspaces{"UAV1": "ws1", "UAV2": "ws2"})
+
+	client := hub.NewClient("c1", nil, nil, nil, nil)
+	client.Metadata[MetadataWorkspaceID] = "ws1"
+
+	client.Subscribe("device.UAV2.property.processed")
+	svc.onClientMessage(client, &hub.Message{Type: hub.MessageTypeSubscribe, Event: "device.UAV2.property.processed"})
+	assert.False(t, svc.SubscriptionManager().IsSubscribed(client.ID, "device.UAV2.property.processed"))
+	assert.False(t, client.IsSubscribed("device.UAV2.property.processed"))
+
+	svc.onClientMessage(client, &hub.Message{Type: hub.MessageTypeSubscribe, Event: "device.UAV1.property.processed"})
+	assert.True(t, svc.SubscriptionManager().IsSubscribed(client.ID, "device.UAV1.property.processed"))
+}
@@ -75,6 +75,10 @@ func MapTopicTypeToAction(topicType TopicType) string {
 		return ActionServiceCall
 	case TopicTypeStatusReply:
 		return ActionStatusReply
+	case TopicTypeRequests:
+		return ActionDeviceRequest
+	case TopicTypeRequestsReply:
+		return ActionDeviceRequestReply
 	default:
 		return "unknown"
 	}
@@ -93,6 +97,12 @@ func MapActionToTopicType(action string) TopicType {
 		return TopicTypeServices
 	case ActionServiceReply:
 		return TopicTypeServicesReply
+	case ActionStatusReply:
+		return TopicTypeStatusReply
+	case ActionDeviceRequest:
+		return TopicTypeRequests
+	case ActionDeviceRequestReply:
+		return TopicTypeRequestsReply
 	default:
 		return TopicTypeServices
 	}
@@ -179,6 +179,7 @@ func TestMapTopicTypeToAction(t *testing.T) {
 		{TopicTypeEvents, "event.report"},
 		{TopicTypeStatus, "device.online"},
 		{TopicTypeServicesReply, "service.reply"},
+		{TopicTypeRequests, "device.request"},
 	}
 
 	for _, tt := range tests {
@@ -188,3 +189,22 @@ func TestMapTopicTypeToAction(t *testing.T) {
 		})
 	}
 }
+
+func TestMapActionToTopicType(t *testing.T) {
+	tests := []struct {
+		action   string
+		expected TopicType
+	}{
+		{"property.report", TopicTypeOSD},
+		{"service.call", TopicTypeServices},
+		{"status.reply", TopicTypeStatusReply},
+		{"device.request.reply", TopicTypeRequestsReply},
+		{"unknown", TopicTypeServices},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			assert.Equal(t, tt.expected, MapActionToTopicType(tt.action))
+		})
+	}
+}
@@ -42,6 +42,8 @@ func (a *DispatcherAdapter) Dispatch(ctx context.Context, call *adapter.ServiceC
 		Vendor:   call.Vendor,
 		Method:   call.Method,
 		Params:   call.Params,
+		TID:      call.TID,
+		BID:      call.BID,
 	}
 
 	result, err := a.dispatcher.Dispatch(ctx, djiCall)
@@ -31,6 +31,7 @@ func registerServiceCommands(sr *router.ServiceRouter) error {
 		{"drc commands", router.RegisterDRCCommands},
 		{"file and firmware commands", router.RegisterFileAndFirmwareCommands},
 		{"config and live commands", router.RegisterConfigAndLiveCommands},
+		{"safety commands", router.RegisterSafetyCommands},
 	}
 
 	for _, r := range registrations {
@@ -52,6 +53,7 @@ func registerEvents(er *router.EventRouter) error {
 		{"drc events", router.RegisterDRCEvents},
 		{"file events", router.RegisterFileEvents},
 		{"firmware events", router.RegisterFirmwareEvents},
+		{"safety events", router.RegisterSafetyEvents},
 	}
 
 	for _, r := range registrations {
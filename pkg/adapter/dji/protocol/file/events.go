@@ -46,10 +46,13 @@ type MediaMetadata struct {
 
 // Ext represents the file extension information
 type Ext struct {
-	FlightID        string `json:"flight_id"`         // Flight task ID
-	DroneModelKey   string `json:"drone_model_key"`   // Drone product enum value
-	PayloadModelKey string `json:"payload_model_key"` // Payload product enum value
-	IsOriginal      bool   `json:"is_original"`       // Whether it is an original image
+	FlightID         string `json:"flight_id"`                   // Flight task ID
+	DroneModelKey    string `json:"drone_model_key"`             // Drone product enum value
+	PayloadModelKey  string `json:"payload_model_key"`           // Payload product enum value
+	IsOriginal       bool   `json:"is_original"`                 // Whether it is an original image
+	SN               string `json:"sn,omitempty"`                // Capturing device serial number (Pilot 2 uploads)
+	Fingerprint      string `json:"fingerprint,omitempty"`       // File fingerprint (Pilot 2 uploads)
+	TinnyFingerprint string `json:"tinny_fingerprint,omitempty"` // Tiny fingerprint (Pilot 2 uploads)
 }
 
 // Info represents the file information
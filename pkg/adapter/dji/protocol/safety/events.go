@@ -0,0 +1,37 @@
+package safety
+
+import "github.com/utmos/utmos/pkg/adapter/dji/protocol/common"
+
+// ===============================
+// Flight Safety Events
+// ===============================
+
+// Flight area synchronization statuses reported in flight_areas_sync_progress.
+const (
+	FlightAreasSyncWait         = "wait_sync"
+	FlightAreasSyncSyncing      = "synchronizing"
+	FlightAreasSyncSynchronized = "synchronized"
+	FlightAreasSyncFail         = "fail"
+)
+
+// FlightAreasSyncProgressData represents the custom flight area synchronization progress
+type FlightAreasSyncProgressData struct {
+	Status string `json:"status"` // Synchronization status (wait_sync, synchronizing, synchronized, fail)
+	Reason int    `json:"reason"` // Failure reason code (0=none)
+}
+
+// FlightAreasSyncProgressEvent represents the custom flight area synchronization progress event
+type FlightAreasSyncProgressEvent struct {
+	common.Header
+	MethodName string                      `json:"method"`
+	DataValue  FlightAreasSyncProgressData `json:"data"`
+}
+
+// Method returns the method name.
+func (e *FlightAreasSyncProgressEvent) Method() string { return e.MethodName }
+
+// Data returns the command/event data.
+func (e *FlightAreasSyncProgressEvent) Data() any { return e.DataValue }
+
+// GetHeader returns the event header.
+func (e *FlightAreasSyncProgressEvent) GetHeader() *common.Header { return &e.Header }
@@ -13,6 +13,18 @@ const (
 	MethodVideoStorageSet  = "video_storage_set"
 )
 
+// Organization request method names. Docks send these on the requests
+// topic while being bound to an organization.
+const (
+	MethodAirportBindStatus       = "airport_bind_status"
+	MethodAirportOrganizationGet  = "airport_organization_get"
+	MethodAirportOrganizationBind = "airport_organization_bind"
+)
+
+// MethodUpdateTopo is the method gateways send on the status topic to report
+// their sub-devices.
+const MethodUpdateTopo = "update_topo"
+
 // Live command method names.
 const (
 	MethodLiveStartPush  = "live_start_push"
@@ -0,0 +1,47 @@
+package router
+
+import (
+	"github.com/utmos/utmos/pkg/adapter/dji/protocol/safety"
+)
+
+// Flight safety method names. The cloud tells docks to fetch custom flight
+// areas with flight_areas_update; docks fetch them with the
+// flight_areas_get request and report their progress with the
+// flight_areas_sync_progress event.
+const (
+	MethodFlightAreasUpdate       = "flight_areas_update"
+	MethodFlightAreasGet          = "flight_areas_get"
+	MethodFlightAreasSyncProgress = "flight_areas_sync_progress"
+)
+
+// FlySafe unlock licence method names. Docks download offline licence files
+// on unlock_license_update, enable or disable a licence on
+// unlock_license_switch and report their licences on unlock_license_list.
+const (
+	MethodUnlockLicenseSwitch = "unlock_license_switch"
+	MethodUnlockLicenseUpdate = "unlock_license_update"
+	MethodUnlockLicenseList   = "unlock_license_list"
+)
+
+// RegisterSafetyCommands registers all flight safety commands to the router.
+// Returns an error if any handler registration fails.
+func RegisterSafetyCommands(r *ServiceRouter) error {
+	handlers := map[string]ServiceHandlerFunc{
+		MethodFlightAreasUpdate:   NoDataCommandHandler(MethodFlightAreasUpdate),
+		MethodUnlockLicenseSwitch: SimpleCommandHandler[safety.UnlockLicenseSwitchData](MethodUnlockLicenseSwitch),
+		MethodUnlockLicenseUpdate: SimpleCommandHandler[safety.UnlockLicenseUpdateData](MethodUnlockLicenseUpdate),
+		MethodUnlockLicenseList:   SimpleCommandHandler[safety.UnlockLicenseListData](MethodUnlockLicenseList),
+	}
+
+	return RegisterHandlers(r, handlers)
+}
+
+// RegisterSafetyEvents registers flight safety events to the event router.
+// Returns an error if any handler registration fails.
+func RegisterSafetyEvents(r *EventRouter) error {
+	handlers := map[string]EventHandlerFunc{
+		MethodFlightAreasSyncProgress: SimpleEventHandler[safety.FlightAreasSyncProgressData](MethodFlightAreasSyncProgress),
+	}
+
+	return RegisterEventHandlers(r, handlers)
+}
@@ -0,0 +1,62 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafetyCommands_FlightAreasUpdate(t *testing.T) {
+	r := NewServiceRouter()
+	require.NoError(t, RegisterSafetyCommands(r))
+	assert.True(t, r.Has(MethodFlightAreasUpdate))
+
+	resp, err := r.RouteService(context.Background(), &ServiceRequest{Method: MethodFlightAreasUpdate})
+	require.NoError(t, err)
+	assert.Equal(t, ResultSuccess, resp.Result)
+}
+
+func TestSafetyEvents_FlightAreasSyncProgress(t *testing.T) {
+	r := NewEventRouter()
+	require.NoError(t, RegisterSafetyEvents(r))
+
+	resp, err := r.RouteEvent(context.Background(), &EventRequest{
+		Method: MethodFlightAreasSyncProgress,
+		Data:   json.RawMessage(`{"status": "synchronized", "reason": 0}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ResultSuccess, resp.Result)
+
+	resp, err = r.RouteEvent(context.Background(), &EventRequest{
+		Method: MethodFlightAreasSyncProgress,
+		Data:   json.RawMessage(`{"status": 1}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ResultParamError, resp.Result)
+}
+
+func TestSafetyCommands_UnlockLicense(t *testing.T) {
+	r := NewServiceRouter()
+	require.NoError(t, RegisterSafetyCommands(r))
+
+	for _, method := range []string{MethodUnlockLicenseSwitch, MethodUnlockLicenseUpdate, MethodUnlockLicenseList} {
+		assert.True(t, r.Has(method), method)
+	}
+
+	resp, err := r.RouteService(context.Background(), &ServiceRequest{
+		Method: MethodUnlockLicenseSwitch,
+		Data:   json.RawMessage(`{"license_id": 12, "enable": true}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ResultSuccess, resp.Result)
+
+	resp, err = r.RouteService(context.Background(), &ServiceRequest{
+		Method: MethodUnlockLicenseSwitch,
+		Data:   json.RawMessage(`{"license_id": "12"}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ResultParamError, resp.Result)
+}
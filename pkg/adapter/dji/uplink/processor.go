@@ -245,6 +245,18 @@ func (p *Processor) processStatusMessage(msg *rabbitmq.StandardMessage, processe
 		processed.Properties["online"] = online
 	}
 
+	// Keep the gateway topology so sub-device relations can be persisted
+	if topology, ok := data["topology"].(map[string]any); ok {
+		processed.Properties["topology"] = topology
+	}
+
+	// update_topo requests report the gateway model and its sub-devices
+	for _, key := range []string{"domain", "type", "sub_type", "sub_devices"} {
+		if value, ok := data[key]; ok {
+			processed.Properties[key] = value
+		}
+	}
+
 	// Determine online status from action
 	if strings.HasSuffix(msg.Action, ".online") {
 		processed.Properties["online"] = true
@@ -58,52 +58,11 @@ func (ar *ActionRequest) MarshalJSON() ([]byte, error) {
 }
 
 func createActionByType(actionType string) (ActionInterface, error) {
-	switch actionType {
-	case ActionTypeTakePhoto:
-		return &TakePhotoAction{}, nil
-	case ActionTypeStartRecord:
-		return &StartRecordAction{}, nil
-	case ActionTypeStopRecord:
-		return &StopRecordAction{}, nil
-	case ActionTypeFocus:
-		return &FocusAction{}, nil
-	case ActionTypeZoom:
-		return &ZoomAction{}, nil
-	case ActionTypeCustomDirName:
-		return &CustomDirNameAction{}, nil
-	case ActionTypeGimbalRotate:
-		return &GimbalRotateAction{}, nil
-	case ActionTypeRotateYaw:
-		return &RotateYawAction{}, nil
-	case ActionTypeHover:
-		return &HoverAction{}, nil
-	case ActionTypeGimbalEvenlyRotate:
-		return &GimbalEvenlyRotateAction{}, nil
-	case ActionTypeOrientedShoot:
-		return &OrientedShootAction{}, nil
-	case ActionTypePanoShot:
-		return &PanoShotAction{}, nil
-	case ActionTypeRecordPointCloud:
-		return &RecordPointCloudAction{}, nil
-	case ActionTypeAccurateShoot:
-		return &AccurateShootAction{}, nil
-	case ActionTypeGimbalAngleLock:
-		return &GimbalAngleLockAction{}, nil
-	case ActionTypeGimbalAngleUnlock:
-		return &GimbalAngleUnlockAction{}, nil
-	case ActionTypeStartSmartOblique:
-		return &StartSmartObliqueAction{}, nil
-	case ActionTypeStartTimeLapse:
-		return &StartTimeLapseAction{}, nil
-	case ActionTypeStopTimeLapse:
-		return &StopTimeLapseAction{}, nil
-	case ActionTypeSetFocusType:
-		return &SetFocusTypeAction{}, nil
-	case ActionTypeTargetDetection:
-		return &TargetDetectionAction{}, nil
-	default:
+	action := CreateActionFromType(actionType)
+	if action == nil {
 		return nil, fmt.Errorf(ErrUnknownActionType, actionType)
 	}
+	return action, nil
 }
 
 // GetActionType returns the action type string, delegating to the underlying action if present.
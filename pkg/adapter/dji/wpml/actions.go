@@ -25,7 +25,7 @@ type TakePhotoAction struct {
 	PayloadPositionIndex      PayloadPosition `json:"payload_position_index" validate:"payload_position"`
 	FileSuffix                string          `json:"file_suffix,omitempty"`
 	UseGlobalPayloadLensIndex bool            `json:"use_global_payload_lens_index"`
-	PayloadLensIndex          *string         `json:"payload_lens_index,omitempty"`
+	PayloadLensIndex          *string         `json:"payload_lens_index,omitempty" validate:"omitempty,lens_index"`
 }
 
 // GetActionType returns the action type identifier for TakePhotoAction.
@@ -38,7 +38,7 @@ type StartRecordAction struct {
 	PayloadPositionIndex      PayloadPosition `json:"payload_position_index" validate:"payload_position"`
 	FileSuffix                string          `json:"file_suffix,omitempty"`
 	UseGlobalPayloadLensIndex bool            `json:"use_global_payload_lens_index"`
-	PayloadLensIndex          *string         `json:"payload_lens_index,omitempty"`
+	PayloadLensIndex          *string         `json:"payload_lens_index,omitempty" validate:"omitempty,lens_index"`
 }
 
 // GetActionType returns the action type identifier for StartRecordAction.
@@ -49,7 +49,7 @@ func (a *StartRecordAction) GetActionType() string {
 // StopRecordAction represents an action to stop video recording with the specified payload.
 type StopRecordAction struct {
 	PayloadPositionIndex PayloadPosition `json:"payload_position_index" validate:"payload_position"`
-	PayloadLensIndex     *string         `json:"payload_lens_index,omitempty"`
+	PayloadLensIndex     *string         `json:"payload_lens_index,omitempty" validate:"omitempty,lens_index"`
 }
 
 // GetActionType returns the action type identifier for StopRecordAction.
@@ -176,7 +176,7 @@ type OrientedShootAction struct {
 	OrientedCameraShutterTime float64         `json:"oriented_camera_shutter_time" validate:"required,gt=0"`
 	OrientedCameraISO         int             `json:"oriented_camera_iso" validate:"min=0"`
 	OrientedPhotoMode         string          `json:"oriented_photo_mode" validate:"required"`
-	PayloadLensIndex          *string         `json:"payload_lens_index,omitempty"`
+	PayloadLensIndex          *string         `json:"payload_lens_index,omitempty" validate:"omitempty,lens_index"`
 }
 
 // GetActionType returns the action type identifier for OrientedShootAction.
@@ -189,7 +189,7 @@ type PanoShotAction struct {
 	PayloadPositionIndex      PayloadPosition `json:"payload_position_index" validate:"payload_position"`
 	UseGlobalPayloadLensIndex bool            `json:"use_global_payload_lens_index"`
 	PanoShotSubMode           string          `json:"pano_shot_sub_mode" validate:"required"`
-	PayloadLensIndex          *string         `json:"payload_lens_index,omitempty"`
+	PayloadLensIndex          *string         `json:"payload_lens_index,omitempty" validate:"omitempty,lens_index"`
 }
 
 // GetActionType returns the action type identifier for PanoShotAction.
@@ -235,7 +235,7 @@ type AccurateShootAction struct {
 	AccurateCameraLuminance   int             `json:"accurate_camera_luminance" validate:"min=0"`
 	AccurateCameraShutterTime float64         `json:"accurate_camera_shutter_time" validate:"required,gt=0"`
 	AccurateCameraISO         int             `json:"accurate_camera_iso" validate:"min=0"`
-	PayloadLensIndex          *string         `json:"payload_lens_index,omitempty"`
+	PayloadLensIndex          *string         `json:"payload_lens_index,omitempty" validate:"omitempty,lens_index"`
 }
 
 // GetActionType returns the action type identifier for AccurateShootAction.
@@ -275,7 +275,10 @@ func (a *StartSmartObliqueAction) GetActionType() string {
 
 // StartTimeLapseAction represents an action to start time-lapse recording.
 type StartTimeLapseAction struct {
-	PayloadPositionIndex PayloadPosition `json:"payload_position_index" validate:"payload_position"`
+	PayloadPositionIndex      PayloadPosition `json:"payload_position_index" validate:"payload_position"`
+	UseGlobalPayloadLensIndex bool            `json:"use_global_payload_lens_index"`
+	PayloadLensIndex          *string         `json:"payload_lens_index,omitempty" validate:"omitempty,lens_index"`
+	MinShootInterval          *float64        `json:"min_shoot_interval,omitempty" validate:"omitempty,gt=0"`
 }
 
 // GetActionType returns the action type identifier for StartTimeLapseAction.
@@ -286,6 +289,7 @@ func (a *StartTimeLapseAction) GetActionType() string {
 // StopTimeLapseAction represents an action to stop time-lapse recording.
 type StopTimeLapseAction struct {
 	PayloadPositionIndex PayloadPosition `json:"payload_position_index" validate:"payload_position"`
+	PayloadLensIndex     *string         `json:"payload_lens_index,omitempty" validate:"omitempty,lens_index"`
 }
 
 // GetActionType returns the action type identifier for StopTimeLapseAction.
@@ -296,6 +300,7 @@ func (a *StopTimeLapseAction) GetActionType() string {
 // SetFocusTypeAction represents an action to set the camera focus type.
 type SetFocusTypeAction struct {
 	PayloadPositionIndex PayloadPosition `json:"payload_position_index" validate:"payload_position"`
+	CameraFocusType      string          `json:"camera_focus_type,omitempty" validate:"omitempty,oneof=manual auto"`
 }
 
 // GetActionType returns the action type identifier for SetFocusTypeAction.
@@ -313,53 +318,95 @@ func (a *TargetDetectionAction) GetActionType() string {
 	return ActionTypeTargetDetection
 }
 
+// StartContinuousShootingAction represents an action to start shooting photos continuously at a fixed interval.
+type StartContinuousShootingAction struct {
+	PayloadPositionIndex      PayloadPosition `json:"payload_position_index" validate:"payload_position"`
+	UseGlobalPayloadLensIndex bool            `json:"use_global_payload_lens_index"`
+	PayloadLensIndex          *string         `json:"payload_lens_index,omitempty" validate:"omitempty,lens_index"`
+	MinShootInterval          float64         `json:"min_shoot_interval" validate:"required,gt=0"`
+}
+
+// GetActionType returns the action type identifier for StartContinuousShootingAction.
+func (a *StartContinuousShootingAction) GetActionType() string {
+	return ActionTypeStartContinuousShooting
+}
+
+// StopContinuousShootingAction represents an action to stop continuous shooting.
+type StopContinuousShootingAction struct {
+	PayloadPositionIndex PayloadPosition `json:"payload_position_index" validate:"payload_position"`
+	PayloadLensIndex     *string         `json:"payload_lens_index,omitempty" validate:"omitempty,lens_index"`
+}
+
+// GetActionType returns the action type identifier for StopContinuousShootingAction.
+func (a *StopContinuousShootingAction) GetActionType() string {
+	return ActionTypeStopContinuousShooting
+}
+
+// MegaphoneAction represents an action to play an audio file or spoken text on a speaker, or stop playing.
+// The audio file must be added to the mission with AddResource under MegaphoneFileName;
+// CreateKmzBuffer packages it in the res folder of the KMZ.
+type MegaphoneAction struct {
+	PayloadPositionIndex PayloadPosition `json:"payload_position_index" validate:"payload_position"`
+	MegaphoneOperateType string          `json:"megaphone_operate_type" validate:"required,oneof=playAudio playText stop"`
+	MegaphoneFileName    string          `json:"megaphone_file_name,omitempty" validate:"required_if=MegaphoneOperateType playAudio"`
+	MegaphoneFileMD5     string          `json:"megaphone_file_md5,omitempty" validate:"required_if=MegaphoneOperateType playAudio"`
+	MegaphoneText        string          `json:"megaphone_text,omitempty" validate:"required_if=MegaphoneOperateType playText,max=500"`
+	MegaphoneVolume      int             `json:"megaphone_volume,omitempty" validate:"omitempty,min=1,max=100"`
+	MegaphoneLoop        bool            `json:"megaphone_loop"`
+}
+
+// GetActionType returns the action type identifier for MegaphoneAction.
+func (a *MegaphoneAction) GetActionType() string {
+	return ActionTypeMegaphone
+}
+
+// SearchlightAction represents an action to switch a searchlight on, off or to strobe.
+type SearchlightAction struct {
+	PayloadPositionIndex   PayloadPosition `json:"payload_position_index" validate:"payload_position"`
+	SearchlightOperateType string          `json:"searchlight_operate_type" validate:"required,oneof=on off strobe"`
+	SearchlightBrightness  int             `json:"searchlight_brightness,omitempty" validate:"omitempty,min=1,max=100"`
+}
+
+// GetActionType returns the action type identifier for SearchlightAction.
+func (a *SearchlightAction) GetActionType() string {
+	return ActionTypeSearchlight
+}
+
+// actionFactories creates an empty action of each WPML action type.
+var actionFactories = map[string]func() ActionInterface{
+	ActionTypeTakePhoto:               func() ActionInterface { return &TakePhotoAction{} },
+	ActionTypeStartRecord:             func() ActionInterface { return &StartRecordAction{} },
+	ActionTypeStopRecord:              func() ActionInterface { return &StopRecordAction{} },
+	ActionTypeFocus:                   func() ActionInterface { return &FocusAction{} },
+	ActionTypeZoom:                    func() ActionInterface { return &ZoomAction{} },
+	ActionTypeCustomDirName:           func() ActionInterface { return &CustomDirNameAction{} },
+	ActionTypeGimbalRotate:            func() ActionInterface { return &GimbalRotateAction{} },
+	ActionTypeRotateYaw:               func() ActionInterface { return &RotateYawAction{} },
+	ActionTypeHover:                   func() ActionInterface { return &HoverAction{} },
+	ActionTypeGimbalEvenlyRotate:      func() ActionInterface { return &GimbalEvenlyRotateAction{} },
+	ActionTypeOrientedShoot:           func() ActionInterface { return &OrientedShootAction{} },
+	ActionTypePanoShot:                func() ActionInterface { return &PanoShotAction{} },
+	ActionTypeRecordPointCloud:        func() ActionInterface { return &RecordPointCloudAction{} },
+	ActionTypeAccurateShoot:           func() ActionInterface { return &AccurateShootAction{} },
+	ActionTypeGimbalAngleLock:         func() ActionInterface { return &GimbalAngleLockAction{} },
+	ActionTypeGimbalAngleUnlock:       func() ActionInterface { return &GimbalAngleUnlockAction{} },
+	ActionTypeStartSmartOblique:       func() ActionInterface { return &StartSmartObliqueAction{} },
+	ActionTypeStartTimeLapse:          func() ActionInterface { return &StartTimeLapseAction{} },
+	ActionTypeStopTimeLapse:           func() ActionInterface { return &StopTimeLapseAction{} },
+	ActionTypeSetFocusType:            func() ActionInterface { return &SetFocusTypeAction{} },
+	ActionTypeTargetDetection:         func() ActionInterface { return &TargetDetectionAction{} },
+	ActionTypeStartContinuousShooting: func() ActionInterface { return &StartContinuousShootingAction{} },
+	ActionTypeStopContinuousShooting:  func() ActionInterface { return &StopContinuousShootingAction{} },
+	ActionTypeMegaphone:               func() ActionInterface { return &MegaphoneAction{} },
+	ActionTypeSearchlight:             func() ActionInterface { return &SearchlightAction{} },
+}
+
 // CreateActionFromType creates and returns a new action instance based on the given action type string.
 // Returns nil if the action type is not recognized.
 func CreateActionFromType(actionType string) ActionInterface {
-	switch actionType {
-	case ActionTypeTakePhoto:
-		return &TakePhotoAction{}
-	case ActionTypeStartRecord:
-		return &StartRecordAction{}
-	case ActionTypeStopRecord:
-		return &StopRecordAction{}
-	case ActionTypeFocus:
-		return &FocusAction{}
-	case ActionTypeZoom:
-		return &ZoomAction{}
-	case ActionTypeCustomDirName:
-		return &CustomDirNameAction{}
-	case ActionTypeGimbalRotate:
-		return &GimbalRotateAction{}
-	case ActionTypeRotateYaw:
-		return &RotateYawAction{}
-	case ActionTypeHover:
-		return &HoverAction{}
-	case ActionTypeGimbalEvenlyRotate:
-		return &GimbalEvenlyRotateAction{}
-	case ActionTypeOrientedShoot:
-		return &OrientedShootAction{}
-	case ActionTypePanoShot:
-		return &PanoShotAction{}
-	case ActionTypeRecordPointCloud:
-		return &RecordPointCloudAction{}
-	case ActionTypeAccurateShoot:
-		return &AccurateShootAction{}
-	case ActionTypeGimbalAngleLock:
-		return &GimbalAngleLockAction{}
-	case ActionTypeGimbalAngleUnlock:
-		return &GimbalAngleUnlockAction{}
-	case ActionTypeStartSmartOblique:
-		return &StartSmartObliqueAction{}
-	case ActionTypeStartTimeLapse:
-		return &StartTimeLapseAction{}
-	case ActionTypeStopTimeLapse:
-		return &StopTimeLapseAction{}
-	case ActionTypeSetFocusType:
-		return &SetFocusTypeAction{}
-	case ActionTypeTargetDetection:
-		return &TargetDetectionAction{}
-	default:
+	factory, ok := actionFactories[actionType]
+	if !ok {
 		return nil
 	}
+	return factory()
 }
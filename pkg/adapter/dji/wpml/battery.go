@@ -0,0 +1,36 @@
+package wpml
+
+// BatteryProfile describes how long a drone flies on a full battery and how
+// fast it climbs and descends, which decide how long the legs outside the
+// waylines take.
+type BatteryProfile struct {
+	// FlightTime is the rated flight time in seconds on a full battery
+	FlightTime float64 `json:"flight_time"`
+	// ClimbSpeed and DescentSpeed are the vertical speeds in meters per second
+	ClimbSpeed   float64 `json:"climb_speed"`
+	DescentSpeed float64 `json:"descent_speed"`
+}
+
+// defaultBatteryProfile holds the vertical speeds assumed for drones without a known profile.
+var defaultBatteryProfile = BatteryProfile{ClimbSpeed: 5, DescentSpeed: 4}
+
+// batteryProfiles holds the battery profiles of the supported drones.
+var batteryProfiles = map[DroneModel]BatteryProfile{
+	DroneM300RTK:   {FlightTime: 55 * 60, ClimbSpeed: 6, DescentSpeed: 5},
+	DroneM30:       {FlightTime: 41 * 60, ClimbSpeed: 6, DescentSpeed: 5},
+	DroneM3Series:  {FlightTime: 45 * 60, ClimbSpeed: 6, DescentSpeed: 6},
+	DroneM350RTK:   {FlightTime: 55 * 60, ClimbSpeed: 6, DescentSpeed: 5},
+	DroneM3DSeries: {FlightTime: 50 * 60, ClimbSpeed: 6, DescentSpeed: 5},
+	DroneM4Series:  {FlightTime: 49 * 60, ClimbSpeed: 10, DescentSpeed: 8},
+	DroneM4DSeries: {FlightTime: 54 * 60, ClimbSpeed: 10, DescentSpeed: 8},
+	DroneM400:      {FlightTime: 59 * 60, ClimbSpeed: 10, DescentSpeed: 8},
+}
+
+// BatteryProfileForDrone returns the battery profile of a drone model, and false when it is not known.
+func BatteryProfileForDrone(model DroneModel) (*BatteryProfile, bool) {
+	profile, ok := batteryProfiles[model]
+	if !ok {
+		return nil, false
+	}
+	return &profile, true
+}
@@ -0,0 +1,54 @@
+package wpml
+
+// Camera describes the optics of a mapping camera, which determine the ground
+// sample distance and photo footprint at a given altitude.
+type Camera struct {
+	// SensorWidth is the sensor width in millimeters
+	SensorWidth float64 `json:"sensor_width" validate:"gt=0"`
+	// SensorHeight is the sensor height in millimeters
+	SensorHeight float64 `json:"sensor_height" validate:"gt=0"`
+	// FocalLength is the actual (not 35mm equivalent) focal length in millimeters
+	FocalLength float64 `json:"focal_length" validate:"gt=0"`
+	// ImageWidth is the photo width in pixels
+	ImageWidth int `json:"image_width" validate:"gt=0"`
+	// ImageHeight is the photo height in pixels
+	ImageHeight int `json:"image_height" validate:"gt=0"`
+	// MinShootInterval is the shortest time in seconds between two photos
+	MinShootInterval float64 `json:"min_shoot_interval,omitempty" validate:"gte=0"`
+}
+
+// payloadCameras holds the wide camera specifications of payloads that map.
+var payloadCameras = map[PayloadModel]Camera{
+	PayloadMavic3ECamera:    {SensorWidth: 17.3, SensorHeight: 13.0, FocalLength: 12.29, ImageWidth: 5280, ImageHeight: 3956, MinShootInterval: 0.7},
+	PayloadMatrice3DCamera:  {SensorWidth: 17.3, SensorHeight: 13.0, FocalLength: 12.29, ImageWidth: 5280, ImageHeight: 3956, MinShootInterval: 0.7},
+	PayloadMatrice3TDCamera: {SensorWidth: 9.6, SensorHeight: 7.2, FocalLength: 6.72, ImageWidth: 8064, ImageHeight: 6048, MinShootInterval: 1},
+	PayloadMatrice4ECamera:  {SensorWidth: 17.3, SensorHeight: 13.0, FocalLength: 12.29, ImageWidth: 5280, ImageHeight: 3956, MinShootInterval: 0.5},
+	PayloadMatrice4DCamera:  {SensorWidth: 17.3, SensorHeight: 13.0, FocalLength: 12.29, ImageWidth: 5280, ImageHeight: 3956, MinShootInterval: 0.5},
+	PayloadM30Camera:        {SensorWidth: 6.4, SensorHeight: 4.8, FocalLength: 4.5, ImageWidth: 4000, ImageHeight: 3000, MinShootInterval: 2},
+	PayloadH20:              {SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 4.5, ImageWidth: 4056, ImageHeight: 3040, MinShootInterval: 2},
+	PayloadH30:              {SensorWidth: 9.6, SensorHeight: 7.2, FocalLength: 6.72, ImageWidth: 8064, ImageHeight: 6048, MinShootInterval: 2},
+}
+
+// CameraForPayload returns the mapping camera of a payload model, and false when it is not known.
+func CameraForPayload(model PayloadModel) (*Camera, bool) {
+	camera, ok := payloadCameras[model]
+	if !ok {
+		return nil, false
+	}
+	return &camera, true
+}
+
+// GSD returns the ground sample distance in centimeters per pixel at the given altitude in meters.
+func (c *Camera) GSD(altitude float64) float64 {
+	return c.SensorWidth * altitude * 100 / (c.FocalLength * float64(c.ImageWidth))
+}
+
+// AltitudeForGSD returns the altitude in meters that gives the ground sample distance in centimeters per pixel.
+func (c *Camera) AltitudeForGSD(gsd float64) float64 {
+	return gsd * c.FocalLength * float64(c.ImageWidth) / (c.SensorWidth * 100)
+}
+
+// Footprint returns the ground width and height in meters of a nadir photo taken at the given altitude.
+func (c *Camera) Footprint(altitude float64) (width, height float64) {
+	return c.SensorWidth * altitude / c.FocalLength, c.SensorHeight * altitude / c.FocalLength
+}
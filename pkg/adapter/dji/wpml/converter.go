@@ -36,6 +36,21 @@ func ConvertWaylinesToMission(waylines *Waylines) (*Mission, error) {
 	}
 	mission.Waylines.Document.Folders = []WaylineFolder{*waylineFolder}
 
+	// Distance and duration the caller left out are estimated
+	if waylines.Distance == nil || waylines.Duration == nil {
+		estimate, err := EstimateMission(mission)
+		if err != nil {
+			return nil, fmt.Errorf(ErrEstimateMission, err)
+		}
+		mission.SetEstimate(estimate)
+		if waylines.Distance != nil {
+			mission.Waylines.Document.Folders[0].Distance = waylines.Distance
+		}
+		if waylines.Duration != nil {
+			mission.Waylines.Document.Folders[0].Duration = waylines.Duration
+		}
+	}
+
 	return mission, nil
 }
 
@@ -91,10 +106,14 @@ func convertToMissionConfig(waylines *Waylines) (*MissionConfig, error) {
 		}
 	} else if len(waylines.Waypoints) > 0 {
 		firstWaypoint := waylines.Waypoints[0]
+		takeOffHeight := firstWaypoint.Height
+		if waylines.ellipsoidHeights() {
+			takeOffHeight = firstWaypoint.ellipsoidHeight()
+		}
 		takeOffPointStr := fmt.Sprintf("%.6f,%.6f,%.1f",
 			firstWaypoint.Latitude,
 			firstWaypoint.Longitude,
-			firstWaypoint.Height)
+			takeOffHeight)
 		takeOffRefPoint = &takeOffPointStr
 
 		aglHeight := 0.0
@@ -113,11 +132,15 @@ func convertToMissionConfig(waylines *Waylines) (*MissionConfig, error) {
 		GlobalRTHHeight:          &globalRTHHeight,
 		DroneInfo:                droneInfo,
 		PayloadInfo:              payloadInfo,
+		AutoRerouteInfo:          waylines.AutoRerouteInfo,
 	}, nil
 }
 
 func convertToTemplateFolder(waylines *Waylines) (*TemplateFolder, error) {
 	heightMode := HeightModeRelativeToStartPoint
+	if waylines.ellipsoidHeights() {
+		heightMode = HeightModeEGM96
+	}
 
 	globalShootHeight := waylines.GlobalHeight
 	surfaceRelativeHeight := waylines.GlobalHeight
@@ -134,7 +157,7 @@ func convertToTemplateFolder(waylines *Waylines) (*TemplateFolder, error) {
 
 	placemarks := make([]Placemark, len(waylines.Waypoints))
 	for i, wp := range waylines.Waypoints {
-		placemark, err := convertToTemplatePlacemark(wp, i)
+		placemark, err := convertToTemplatePlacemark(wp, i, len(waylines.Waypoints))
 		if err != nil {
 			return nil, fmt.Errorf(ErrConvertWaypoint, i, err)
 		}
@@ -147,23 +170,48 @@ func convertToTemplateFolder(waylines *Waylines) (*TemplateFolder, error) {
 		AutoFlightSpeed:            waylines.GlobalSpeed,
 		GlobalHeight:               &waylines.GlobalHeight,
 		WaylineCoordinateSysParam:  waylineCoordSysParam,
+		PayloadParam:               convertPayloadParam(waylines),
 		GimbalPitchMode:            stringPtr(waylines.GimbalPitchMode),
 		GlobalWaypointHeadingParam: convertGlobalHeadingParam(waylines),
 		Placemarks:                 placemarks,
 	}, nil
 }
 
+// convertPayloadParam sets the lenses photos are taken with, which actions
+// using the global lens list follow.
+func convertPayloadParam(waylines *Waylines) *PayloadParam {
+	if len(waylines.PhotoSettings) == 0 {
+		return nil
+	}
+	lenses := make([]string, len(waylines.PhotoSettings))
+	for i, setting := range waylines.PhotoSettings {
+		lenses[i] = photoSettingLens(setting)
+	}
+	return &PayloadParam{
+		PayloadPositionIndex: int(waylines.PayloadPositionIndex),
+		ImageFormat:          *LensIndex(lenses...),
+	}
+}
+
 func convertToWaylineFolder(waylines *Waylines) (*WaylineFolder, error) {
 	executeHeightMode := ExecuteHeightModeRelativeToStartPoint
 	if waylines.HeightType == HeightModeRealTimeFollowSurface {
 		executeHeightMode = ExecuteHeightModeRealTimeFollowSurface
 	}
+	// EGM96 waypoints are flown at their ellipsoid heights
+	wgs84 := waylines.ellipsoidHeights()
+	if wgs84 {
+		executeHeightMode = ExecuteHeightModeWGS84
+	}
 	placemarks := make([]Placemark, 0, len(waylines.Waypoints))
 	for i, wp := range waylines.Waypoints {
 		placemark, err := convertToWaylinePlacemark(wp, i, waylines)
 		if err != nil {
 			return nil, fmt.Errorf(ErrConvertWaypoint, i, err)
 		}
+		if wgs84 {
+			placemark.ExecuteHeight = float64Ptr(wp.ellipsoidHeight())
+		}
 		placemarks = append(placemarks, *placemark)
 	}
 
@@ -178,12 +226,12 @@ func convertToWaylineFolder(waylines *Waylines) (*WaylineFolder, error) {
 	}, nil
 }
 
-func convertToTemplatePlacemark(waypoint WaylinesWaypoint, index int) (*Placemark, error) {
+func convertToTemplatePlacemark(waypoint WaylinesWaypoint, index, count int) (*Placemark, error) {
 	point := &Point{
 		Coordinates: formatCoordinates(waypoint.Longitude, waypoint.Latitude),
 	}
 
-	ellipsoidHeight := waypoint.Height
+	ellipsoidHeight := waypoint.ellipsoidHeight()
 	height := waypoint.Height
 
 	var waypointSpeed *float64
@@ -217,7 +265,7 @@ func convertToTemplatePlacemark(waypoint WaylinesWaypoint, index int) (*Placemar
 
 	var actionGroups []ActionGroup
 	if len(waypoint.Actions) > 0 {
-		actionGroup := convertToActionGroup(waypoint.Actions, waypoint.TriggerType, index)
+		actionGroup := convertToActionGroup(&waypoint, index, count)
 		if actionGroup != nil {
 			actionGroups = append(actionGroups, *actionGroup)
 		}
@@ -274,7 +322,7 @@ func convertToWaylinePlacemark(waypoint WaylinesWaypoint, index int, waylines *W
 
 	var actionGroups []ActionGroup
 	if len(waypoint.Actions) > 0 {
-		actionGroup := convertToActionGroup(waypoint.Actions, waypoint.TriggerType, index)
+		actionGroup := convertToActionGroup(&waypoint, index, len(waylines.Waypoints))
 		if actionGroup != nil {
 			actionGroups = append(actionGroups, *actionGroup)
 		}
@@ -295,13 +343,16 @@ func convertToWaylinePlacemark(waypoint WaylinesWaypoint, index int, waylines *W
 	}, nil
 }
 
-func convertToActionGroup(actions []ActionRequest, triggerType string, waypointIndex int) *ActionGroup {
+// convertToActionGroup creates the action group of a waypoint out of count.
+// Groups triggered between waypoints run until the next waypoint.
+func convertToActionGroup(waypoint *WaylinesWaypoint, waypointIndex, count int) *ActionGroup {
+	actions := waypoint.Actions
 	if len(actions) == 0 {
 		return nil
 	}
 
 	triggerTypeStr := TriggerTypeReachPoint
-	switch triggerType {
+	switch waypoint.TriggerType {
 	case "reachPoint":
 		triggerTypeStr = TriggerTypeReachPoint
 	case "passPoint":
@@ -319,6 +370,14 @@ func convertToActionGroup(actions []ActionRequest, triggerType string, waypointI
 	trigger := ActionTrigger{
 		ActionTriggerType: triggerTypeStr,
 	}
+	endIndex := waypointIndex
+	switch triggerTypeStr {
+	case TriggerTypeBetweenAdjacentPoints, TriggerTypeMultipleTiming, TriggerTypeMultipleDistance:
+		endIndex = min(waypointIndex+1, count-1)
+	}
+	if waypoint.TriggerParam > 0 {
+		trigger.ActionTriggerParam = float64Ptr(waypoint.TriggerParam)
+	}
 
 	actionList := make([]Action, len(actions))
 	for i, actionReq := range actions {
@@ -332,7 +391,7 @@ func convertToActionGroup(actions []ActionRequest, triggerType string, waypointI
 	return &ActionGroup{
 		ActionGroupID:         waypointIndex,
 		ActionGroupStartIndex: waypointIndex,
-		ActionGroupEndIndex:   waypointIndex,
+		ActionGroupEndIndex:   endIndex,
 		ActionGroupMode:       ActionGroupModeSequence,
 		ActionTrigger:         trigger,
 		Actions:               actionList,
@@ -349,12 +408,16 @@ func convertActionParams(actionReq ActionRequest) *ActionActuatorFuncParam {
 		convertGimbalActions(actionReq, param)
 	case ActionTypeAccurateShoot, ActionTypeOrientedShoot, ActionTypePanoShot:
 		convertAdvancedShootActions(actionReq, param)
-	case ActionTypeHover, ActionTypeZoom, ActionTypeFocus, ActionTypeRotateYaw:
+	case ActionTypeHover, ActionTypeZoom, ActionTypeFocus, ActionTypeRotateYaw, ActionTypeSetFocusType:
 		convertBasicActions(actionReq, param)
 	case ActionTypeCustomDirName, ActionTypeRecordPointCloud:
 		convertUtilityActions(actionReq, param)
-	case ActionTypeStartSmartOblique, ActionTypeStartTimeLapse, ActionTypeStopTimeLapse,
-		ActionTypeSetFocusType, ActionTypeTargetDetection:
+	case ActionTypeStartTimeLapse, ActionTypeStopTimeLapse,
+		ActionTypeStartContinuousShooting, ActionTypeStopContinuousShooting:
+		convertIntervalShootActions(actionReq, param)
+	case ActionTypeMegaphone, ActionTypeSearchlight:
+		convertAccessoryActions(actionReq, param)
+	case ActionTypeStartSmartOblique, ActionTypeTargetDetection:
 		convertPayloadOnlyAction(actionReq, param)
 	}
 
@@ -508,6 +571,11 @@ func convertBasicActions(actionReq ActionRequest, param *ActionActuatorFuncParam
 			param.AircraftHeading = &rotateYawAction.AircraftHeading
 			param.AircraftPathMode = rotateYawAction.AircraftPathMode
 		}
+	case ActionTypeSetFocusType:
+		if setFocusTypeAction, ok := actionReq.Action.(*SetFocusTypeAction); ok {
+			param.PayloadPositionIndex = payloadPosIntPtr(setFocusTypeAction.PayloadPositionIndex)
+			param.CameraFocusType = stringPtr(setFocusTypeAction.CameraFocusType)
+		}
 	}
 }
 
@@ -526,8 +594,65 @@ func convertUtilityActions(actionReq ActionRequest, param *ActionActuatorFuncPar
 	}
 }
 
+// each case branch type-asserts a different action struct; not truly duplicate logic
+func convertIntervalShootActions(actionReq ActionRequest, param *ActionActuatorFuncParam) {
+	switch actionReq.Type {
+	case ActionTypeStartTimeLapse:
+		if startTimeLapseAction, ok := actionReq.Action.(*StartTimeLapseAction); ok {
+			param.PayloadPositionIndex = payloadPosIntPtr(startTimeLapseAction.PayloadPositionIndex)
+			param.UseGlobalPayloadLensIndex = boolToIntPtr(startTimeLapseAction.UseGlobalPayloadLensIndex)
+			param.PayloadLensIndex = startTimeLapseAction.PayloadLensIndex
+			param.MinShootInterval = startTimeLapseAction.MinShootInterval
+		}
+	case ActionTypeStopTimeLapse:
+		if stopTimeLapseAction, ok := actionReq.Action.(*StopTimeLapseAction); ok {
+			param.PayloadPositionIndex = payloadPosIntPtr(stopTimeLapseAction.PayloadPositionIndex)
+			param.PayloadLensIndex = stopTimeLapseAction.PayloadLensIndex
+		}
+	case ActionTypeStartContinuousShooting:
+		if startShootingAction, ok := actionReq.Action.(*StartContinuousShootingAction); ok {
+			param.PayloadPositionIndex = payloadPosIntPtr(startShootingAction.PayloadPositionIndex)
+			param.UseGlobalPayloadLensIndex = boolToIntPtr(startShootingAction.UseGlobalPayloadLensIndex)
+			param.PayloadLensIndex = startShootingAction.PayloadLensIndex
+			param.MinShootInterval = &startShootingAction.MinShootInterval
+		}
+	case ActionTypeStopContinuousShooting:
+		if stopShootingAction, ok := actionReq.Action.(*StopContinuousShootingAction); ok {
+			param.PayloadPositionIndex = payloadPosIntPtr(stopShootingAction.PayloadPositionIndex)
+			param.PayloadLensIndex = stopShootingAction.PayloadLensIndex
+		}
+	}
+}
+
+// convertAccessoryActions converts the actions of speakers and searchlights,
+// mounted as accessories or PSDK payloads.
+func convertAccessoryActions(actionReq ActionRequest, param *ActionActuatorFuncParam) {
+	switch actionReq.Type {
+	case ActionTypeMegaphone:
+		if megaphoneAction, ok := actionReq.Action.(*MegaphoneAction); ok {
+			param.PayloadPositionIndex = payloadPosIntPtr(megaphoneAction.PayloadPositionIndex)
+			param.MegaphoneOperateType = &megaphoneAction.MegaphoneOperateType
+			param.MegaphoneFileName = stringPtr(megaphoneAction.MegaphoneFileName)
+			param.MegaphoneFileMD5 = stringPtr(megaphoneAction.MegaphoneFileMD5)
+			param.MegaphoneText = stringPtr(megaphoneAction.MegaphoneText)
+			if megaphoneAction.MegaphoneVolume > 0 {
+				param.MegaphoneVolume = intPtr(megaphoneAction.MegaphoneVolume)
+			}
+			param.MegaphonePlayMode = boolToIntPtr(megaphoneAction.MegaphoneLoop)
+		}
+	case ActionTypeSearchlight:
+		if searchlightAction, ok := actionReq.Action.(*SearchlightAction); ok {
+			param.PayloadPositionIndex = payloadPosIntPtr(searchlightAction.PayloadPositionIndex)
+			param.SearchlightOperateType = &searchlightAction.SearchlightOperateType
+			if searchlightAction.SearchlightBrightness > 0 {
+				param.SearchlightBrightness = intPtr(searchlightAction.SearchlightBrightness)
+			}
+		}
+	}
+}
+
 // convertPayloadOnlyAction handles action types that only set PayloadPositionIndex.
-// This covers time-lapse, detection, and similar simple actions.
+// This covers smart oblique, detection, and similar simple actions.
 func convertPayloadOnlyAction(actionReq ActionRequest, param *ActionActuatorFuncParam) {
 	posMap := map[string]func() PayloadPosition{
 		ActionTypeStartSmartOblique: func() PayloadPosition {
@@ -536,24 +661,6 @@ func convertPayloadOnlyAction(actionReq ActionRequest, param *ActionActuatorFunc
 			}
 			return 0
 		},
-		ActionTypeStartTimeLapse: func() PayloadPosition {
-			if a, ok := actionReq.Action.(*StartTimeLapseAction); ok {
-				return a.PayloadPositionIndex
-			}
-			return 0
-		},
-		ActionTypeStopTimeLapse: func() PayloadPosition {
-			if a, ok := actionReq.Action.(*StopTimeLapseAction); ok {
-				return a.PayloadPositionIndex
-			}
-			return 0
-		},
-		ActionTypeSetFocusType: func() PayloadPosition {
-			if a, ok := actionReq.Action.(*SetFocusTypeAction); ok {
-				return a.PayloadPositionIndex
-			}
-			return 0
-		},
 		ActionTypeTargetDetection: func() PayloadPosition {
 			if a, ok := actionReq.Action.(*TargetDetectionAction); ok {
 				return a.PayloadPositionIndex
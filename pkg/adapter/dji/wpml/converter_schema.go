@@ -31,6 +31,7 @@ type Waylines struct {
 	SafeHeight               float64            `json:"safe_height,omitempty" validate:"omitempty,min=20,max=500"`
 	Distance                 *float64           `json:"distance,omitempty" validate:"omitempty,min=0"`
 	Duration                 *float64           `json:"duration,omitempty" validate:"omitempty,min=0"`
+	AutoRerouteInfo          *AutoRerouteInfo   `json:"auto_reroute_info,omitempty"`
 }
 
 // WaylinesWaypoint represents a single waypoint in the waylines conversion schema.
@@ -38,6 +39,7 @@ type WaylinesWaypoint struct {
 	Latitude         float64         `json:"latitude" validate:"required,min=-90,max=90"`
 	Longitude        float64         `json:"longitude" validate:"required,min=-180,max=180"`
 	Height           float64         `json:"height" validate:"required,min=5,max=500"`
+	EllipsoidHeight  *float64        `json:"ellipsoid_height,omitempty"`
 	Speed            float64         `json:"speed,omitempty" validate:"min=1,max=15"`
 	TriggerType      string          `json:"trigger_type,omitempty" validate:"oneof=reachPoint passPoint manual betweenAdjacentPoints multipleTiming multipleDistance"`
 	TriggerParam     float64         `json:"trigger_param,omitempty" validate:"min=0"`
@@ -47,13 +49,17 @@ type WaylinesWaypoint struct {
 	Actions          []ActionRequest `json:"actions,omitempty" validate:"dive"`
 }
 
-// Validate validates the Waylines schema using the WPML validator.
+// Validate validates the Waylines schema using the WPML validator, and checks
+// that the drone and payload models support its actions and lenses.
 func (w *Waylines) Validate() error {
 	validator, err := NewValidator()
 	if err != nil {
 		return err
 	}
-	return validator.ValidateStruct(w)
+	if err := validator.ValidateStruct(w); err != nil {
+		return err
+	}
+	return validator.ValidateWaylinesSupport(w)
 }
 
 // ApplyDefaults applies default values to the Waylines schema fields that are not set.
@@ -62,3 +68,25 @@ func (w *Waylines) ApplyDefaults() {
 		w.HeightType = HeightModeRelativeToStartPoint
 	}
 }
+
+// ellipsoidHeights reports whether the waypoints carry EGM96 heights with
+// their WGS84 ellipsoid heights, so the mission can be flown in WGS84.
+func (w *Waylines) ellipsoidHeights() bool {
+	if w.HeightType != HeightModeEGM96 {
+		return false
+	}
+	for i := range w.Waypoints {
+		if w.Waypoints[i].EllipsoidHeight == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ellipsoidHeight returns the WGS84 ellipsoid height of the waypoint, or its height when it has none.
+func (wp *WaylinesWaypoint) ellipsoidHeight() float64 {
+	if wp.EllipsoidHeight != nil {
+		return *wp.EllipsoidHeight
+	}
+	return wp.Height
+}
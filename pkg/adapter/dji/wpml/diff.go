@@ -0,0 +1,421 @@
+package wpml
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// ChangeType is the kind of change between two versions of a mission element.
+type ChangeType string
+
+// Change types of mission diffs.
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeRemoved  ChangeType = "removed"
+	ChangeModified ChangeType = "modified"
+)
+
+// diffMoveDistance is the distance in meters a waypoint moves at the cost of
+// adding or removing one when aligning waylines. Waypoints moved by more than
+// twice this are reported as removed and added instead.
+const diffMoveDistance = 50.0
+
+// diffPositionTolerance is the distance in meters below which a waypoint has not moved.
+const diffPositionTolerance = 0.01
+
+// alignment steps of the waypoint alignment.
+const (
+	alignMatch byte = iota
+	alignRemove
+	alignAdd
+)
+
+// FieldChange is a changed value of a mission element. Old is nil for added
+// values and New for removed ones.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old,omitempty"`
+	New   any    `json:"new,omitempty"`
+}
+
+// MissionDiff is the semantic difference between two missions.
+type MissionDiff struct {
+	// Config lists the changed mission settings
+	Config []FieldChange `json:"config,omitempty"`
+	// Waylines lists the added, removed and modified waylines
+	Waylines []WaylineDiff `json:"waylines,omitempty"`
+}
+
+// Empty reports whether the missions fly the same.
+func (d *MissionDiff) Empty() bool {
+	return len(d.Config) == 0 && len(d.Waylines) == 0
+}
+
+// WaylineDiff is the difference between two versions of a wayline, matched by wayline ID.
+type WaylineDiff struct {
+	WaylineID        int              `json:"wayline_id"`
+	Change           ChangeType       `json:"change"`
+	Fields           []FieldChange    `json:"fields,omitempty"`
+	StartActionGroup *ActionGroupDiff `json:"start_action_group,omitempty"`
+	Waypoints        []WaypointDiff   `json:"waypoints,omitempty"`
+}
+
+// WaypointDiff is an added, removed or modified waypoint. OldIndex and
+// NewIndex are the waypoint's indexes in each version, and Position its
+// latest position.
+type WaypointDiff struct {
+	Change   ChangeType `json:"change"`
+	OldIndex *int       `json:"old_index,omitempty"`
+	NewIndex *int       `json:"new_index,omitempty"`
+	Position Coordinate `json:"position"`
+	// Moved is the distance in meters the waypoint moved horizontally
+	Moved        float64           `json:"moved,omitempty"`
+	Fields       []FieldChange     `json:"fields,omitempty"`
+	ActionGroups []ActionGroupDiff `json:"action_groups,omitempty"`
+}
+
+// ActionGroupDiff is an added, removed or modified action group of a
+// waypoint, matched by its order on the waypoint.
+type ActionGroupDiff struct {
+	Change        ChangeType    `json:"change"`
+	ActionGroupID int           `json:"action_group_id"`
+	Fields        []FieldChange `json:"fields,omitempty"`
+}
+
+// diffedWaypoint is a waypoint of a wayline being diffed.
+type diffedWaypoint struct {
+	estimateWaypoint
+	placemark *Placemark
+}
+
+// DiffMissions compares two missions at the level of what the aircraft
+// flies: the mission settings, then each wayline's speed and height mode,
+// start actions and waypoints. Waypoints are aligned by position, so an
+// inserted waypoint shows as added rather than as every later waypoint
+// having moved.
+func DiffMissions(oldMission, newMission *Mission) (*MissionDiff, error) {
+	if oldMission == nil || newMission == nil {
+		return nil, ErrMissionCannotBeEmpty
+	}
+	if oldMission.Waylines == nil || newMission.Waylines == nil {
+		return nil, ErrMissionWaylinesCannotBeEmpty
+	}
+
+	diff := &MissionDiff{Config: diffMissionConfig(oldMission, newMission)}
+	newFolders := make(map[int]*WaylineFolder, len(newMission.Waylines.Document.Folders))
+	for i := range newMission.Waylines.Document.Folders {
+		folder := &newMission.Waylines.Document.Folders[i]
+		newFolders[folder.WaylineID] = folder
+	}
+
+	oldIDs := make(map[int]bool, len(oldMission.Waylines.Document.Folders))
+	for i := range oldMission.Waylines.Document.Folders {
+		oldFolder := &oldMission.Waylines.Document.Folders[i]
+		oldIDs[oldFolder.WaylineID] = true
+		newFolder, ok := newFolders[oldFolder.WaylineID]
+		if !ok {
+			diff.Waylines = append(diff.Waylines, WaylineDiff{WaylineID: oldFolder.WaylineID, Change: ChangeRemoved})
+			continue
+		}
+		waylineDiff, err := diffWayline(oldFolder, newFolder)
+		if err != nil {
+			return nil, fmt.Errorf(ErrDiffWayline, oldFolder.WaylineID, err)
+		}
+		if waylineDiff != nil {
+			diff.Waylines = append(diff.Waylines, *waylineDiff)
+		}
+	}
+	for _, folder := range newMission.Waylines.Document.Folders {
+		if !oldIDs[folder.WaylineID] {
+			diff.Waylines = append(diff.Waylines, WaylineDiff{WaylineID: folder.WaylineID, Change: ChangeAdded})
+		}
+	}
+	return diff, nil
+}
+
+// diffMissionConfig compares the mission settings and take-off points.
+func diffMissionConfig(oldMission, newMission *Mission) []FieldChange {
+	oldConfig := &oldMission.Waylines.Document.MissionConfig
+	newConfig := &newMission.Waylines.Document.MissionConfig
+
+	var changes []FieldChange
+	changes = diffField(changes, "fly_to_wayline_mode", oldConfig.FlyToWaylineMode, newConfig.FlyToWaylineMode)
+	changes = diffField(changes, "finish_action", oldConfig.FinishAction, newConfig.FinishAction)
+	changes = diffField(changes, "exit_on_rc_lost", oldConfig.ExitOnRCLost, newConfig.ExitOnRCLost)
+	changes = diffField(changes, "take_off_security_height", oldConfig.TakeOffSecurityHeight, newConfig.TakeOffSecurityHeight)
+	changes = diffField(changes, "global_transitional_speed", oldConfig.GlobalTransitionalSpeed, newConfig.GlobalTransitionalSpeed)
+	changes = diffField(changes, "global_rth_height", oldConfig.GlobalRTHHeight, newConfig.GlobalRTHHeight)
+	changes = diffField(changes, "drone", oldConfig.DroneInfo, newConfig.DroneInfo)
+	changes = diffField(changes, "payload", oldConfig.PayloadInfo, newConfig.PayloadInfo)
+	changes = diffField(changes, "auto_reroute", oldConfig.AutoRerouteInfo, newConfig.AutoRerouteInfo)
+	return diffField(changes, "take_off_ref_point", takeOffRefPoint(oldMission), takeOffRefPoint(newMission))
+}
+
+// takeOffRefPoint returns the take-off reference point of a mission, or nil without one.
+func takeOffRefPoint(mission *Mission) any {
+	if mission.Template == nil || mission.Template.Document.MissionConfig.TakeOffRefPoint == nil {
+		return nil
+	}
+	return *mission.Template.Document.MissionConfig.TakeOffRefPoint
+}
+
+// diffWayline compares two versions of a wayline, and returns nil when they fly the same.
+func diffWayline(oldFolder, newFolder *WaylineFolder) (*WaylineDiff, error) {
+	oldWaypoints, err := diffWaypoints(oldFolder)
+	if err != nil {
+		return nil, err
+	}
+	newWaypoints, err := diffWaypoints(newFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &WaylineDiff{WaylineID: newFolder.WaylineID, Change: ChangeModified}
+	diff.Fields = diffField(diff.Fields, "auto_flight_speed", oldFolder.AutoFlightSpeed, newFolder.AutoFlightSpeed)
+	diff.Fields = diffField(diff.Fields, "execute_height_mode", oldFolder.ExecuteHeightMode, newFolder.ExecuteHeightMode)
+	if groups := diffActionGroups(actionGroupList(oldFolder.StartActionGroup), actionGroupList(newFolder.StartActionGroup)); len(groups) > 0 {
+		diff.StartActionGroup = &groups[0]
+	}
+
+	for _, step := range alignWaypoints(oldWaypoints, newWaypoints) {
+		switch {
+		case step.from < 0:
+			w := newWaypoints[step.to]
+			diff.Waypoints = append(diff.Waypoints, WaypointDiff{Change: ChangeAdded, NewIndex: intPtr(w.placemark.Index), Position: w.position})
+		case step.to < 0:
+			w := oldWaypoints[step.from]
+			diff.Waypoints = append(diff.Waypoints, WaypointDiff{Change: ChangeRemoved, OldIndex: intPtr(w.placemark.Index), Position: w.position})
+		default:
+			if waypoint := diffWaypoint(&oldWaypoints[step.from], &newWaypoints[step.to]); waypoint != nil {
+				diff.Waypoints = append(diff.Waypoints, *waypoint)
+			}
+		}
+	}
+
+	if len(diff.Fields) == 0 && diff.StartActionGroup == nil && len(diff.Waypoints) == 0 {
+		return nil, nil
+	}
+	return diff, nil
+}
+
+// diffWaypoints reads the waypoints of a wayline with their placemarks.
+func diffWaypoints(folder *WaylineFolder) ([]diffedWaypoint, error) {
+	estimated, err := estimateWaypoints(folder, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	waypoints := make([]diffedWaypoint, len(estimated))
+	for i := range estimated {
+		waypoints[i] = diffedWaypoint{estimateWaypoint: estimated[i], placemark: &folder.Placemarks[i]}
+	}
+	return waypoints, nil
+}
+
+// diffWaypoint compares two aligned waypoints, and returns nil when they are the same.
+func diffWaypoint(oldWaypoint, newWaypoint *diffedWaypoint) *WaypointDiff {
+	diff := &WaypointDiff{
+		Change:   ChangeModified,
+		OldIndex: intPtr(oldWaypoint.placemark.Index),
+		NewIndex: intPtr(newWaypoint.placemark.Index),
+		Position: newWaypoint.position,
+	}
+	if moved := Distance(oldWaypoint.position, newWaypoint.position); moved > diffPositionTolerance {
+		diff.Moved = moved
+	}
+	diff.Fields = diffField(diff.Fields, "height", oldWaypoint.height, newWaypoint.height)
+	diff.Fields = diffField(diff.Fields, "speed", oldWaypoint.speed, newWaypoint.speed)
+	diff.Fields = diffField(diff.Fields, "turn_mode", turnMode(oldWaypoint.placemark), turnMode(newWaypoint.placemark))
+	diff.Fields = diffField(diff.Fields, "heading_mode", headingMode(oldWaypoint.placemark), headingMode(newWaypoint.placemark))
+	diff.ActionGroups = diffActionGroups(oldWaypoint.placemark.ActionGroups, newWaypoint.placemark.ActionGroups)
+
+	if diff.Moved == 0 && len(diff.Fields) == 0 && len(diff.ActionGroups) == 0 {
+		return nil
+	}
+	return diff
+}
+
+// turnMode returns the turn mode of a placemark, or nil when it uses the global one.
+func turnMode(placemark *Placemark) any {
+	if placemark.WaypointTurnParam == nil {
+		return nil
+	}
+	return placemark.WaypointTurnParam.WaypointTurnMode
+}
+
+// headingMode returns the heading mode of a placemark, or nil when it uses the global one.
+func headingMode(placemark *Placemark) any {
+	if placemark.WaypointHeadingParam == nil {
+		return nil
+	}
+	return placemark.WaypointHeadingParam.WaypointHeadingMode
+}
+
+// alignStep pairs a waypoint of the old wayline with one of the new, -1 on
+// the side a waypoint was added to or removed from.
+type alignStep struct {
+	from, to int
+}
+
+// alignWaypoints aligns the waypoints of two waylines with the fewest
+// additions and removals, matching waypoints at a cost growing with the
+// distance they moved. Unchanged leading and trailing waypoints are matched
+// up front, keeping the alignment cheap for local edits of long waylines.
+func alignWaypoints(oldWaypoints, newWaypoints []diffedWaypoint) []alignStep {
+	samePosition := func(i, j int) bool {
+		return Distance(oldWaypoints[i].position, newWaypoints[j].position) <= diffPositionTolerance
+	}
+	prefix := 0
+	for prefix < len(oldWaypoints) && prefix < len(newWaypoints) && samePosition(prefix, prefix) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldWaypoints)-prefix && suffix < len(newWaypoints)-prefix &&
+		samePosition(len(oldWaypoints)-1-suffix, len(newWaypoints)-1-suffix) {
+		suffix++
+	}
+
+	steps := make([]alignStep, 0, len(oldWaypoints)+len(newWaypoints))
+	for i := 0; i < prefix; i++ {
+		steps = append(steps, alignStep{i, i})
+	}
+	oldMiddle := oldWaypoints[prefix : len(oldWaypoints)-suffix]
+	newMiddle := newWaypoints[prefix : len(newWaypoints)-suffix]
+	for _, step := range alignMiddle(oldMiddle, newMiddle) {
+		if step.from >= 0 {
+			step.from += prefix
+		}
+		if step.to >= 0 {
+			step.to += prefix
+		}
+		steps = append(steps, step)
+	}
+	for k := suffix; k > 0; k-- {
+		steps = append(steps, alignStep{len(oldWaypoints) - k, len(newWaypoints) - k})
+	}
+	return steps
+}
+
+// alignMiddle aligns two runs of waypoints by edit distance, preferring
+// matches on ties.
+func alignMiddle(oldWaypoints, newWaypoints []diffedWaypoint) []alignStep {
+	n, m := len(oldWaypoints), len(newWaypoints)
+	moves := make([][]byte, n+1)
+	previous, current := make([]float64, m+1), make([]float64, m+1)
+	for i := 0; i <= n; i++ {
+		moves[i] = make([]byte, m+1)
+		for j := 0; j <= m; j++ {
+			switch {
+			case i == 0 && j == 0:
+				current[j] = 0
+			case i == 0:
+				current[j], moves[i][j] = current[j-1]+1, alignAdd
+			case j == 0:
+				current[j], moves[i][j] = previous[j]+1, alignRemove
+			default:
+				moved := Distance(oldWaypoints[i-1].position, newWaypoints[j-1].position)
+				current[j], moves[i][j] = previous[j-1]+moved/diffMoveDistance, alignMatch
+				if cost := previous[j] + 1; cost < current[j] {
+					current[j], moves[i][j] = cost, alignRemove
+				}
+				if cost := current[j-1] + 1; cost < current[j] {
+					current[j], moves[i][j] = cost, alignAdd
+				}
+			}
+		}
+		previous, current = current, previous
+	}
+
+	steps := make([]alignStep, 0, n+m)
+	for i, j := n, m; i > 0 || j > 0; {
+		switch moves[i][j] {
+		case alignMatch:
+			i, j = i-1, j-1
+			steps = append(steps, alignStep{i, j})
+		case alignRemove:
+			i--
+			steps = append(steps, alignStep{i, -1})
+		default:
+			j--
+			steps = append(steps, alignStep{-1, j})
+		}
+	}
+	for l, r := 0, len(steps)-1; l < r; l, r = l+1, r-1 {
+		steps[l], steps[r] = steps[r], steps[l]
+	}
+	return steps
+}
+
+// diffActionGroups compares the action groups of a waypoint in order.
+func diffActionGroups(oldGroups, newGroups []ActionGroup) []ActionGroupDiff {
+	var diffs []ActionGroupDiff
+	for k := 0; k < len(oldGroups) || k < len(newGroups); k++ {
+		switch {
+		case k >= len(oldGroups):
+			diffs = append(diffs, ActionGroupDiff{Change: ChangeAdded, ActionGroupID: newGroups[k].ActionGroupID})
+		case k >= len(newGroups):
+			diffs = append(diffs, ActionGroupDiff{Change: ChangeRemoved, ActionGroupID: oldGroups[k].ActionGroupID})
+		default:
+			if fields := diffActionGroup(&oldGroups[k], &newGroups[k]); len(fields) > 0 {
+				diffs = append(diffs, ActionGroupDiff{Change: ChangeModified, ActionGroupID: newGroups[k].ActionGroupID, Fields: fields})
+			}
+		}
+	}
+	return diffs
+}
+
+// diffActionGroup compares the trigger, mode, span and actions of two action
+// groups. Spans are compared by their number of waypoints, since inserted
+// waypoints shift their start and end indexes.
+func diffActionGroup(oldGroup, newGroup *ActionGroup) []FieldChange {
+	var changes []FieldChange
+	changes = diffField(changes, "trigger", oldGroup.ActionTrigger.ActionTriggerType, newGroup.ActionTrigger.ActionTriggerType)
+	changes = diffField(changes, "trigger_param", floatValue(oldGroup.ActionTrigger.ActionTriggerParam), floatValue(newGroup.ActionTrigger.ActionTriggerParam))
+	changes = diffField(changes, "mode", oldGroup.ActionGroupMode, newGroup.ActionGroupMode)
+	changes = diffField(changes, "span",
+		oldGroup.ActionGroupEndIndex-oldGroup.ActionGroupStartIndex+1, newGroup.ActionGroupEndIndex-newGroup.ActionGroupStartIndex+1)
+
+	for k := 0; k < len(oldGroup.Actions) || k < len(newGroup.Actions); k++ {
+		field := fmt.Sprintf("actions[%d]", k)
+		switch {
+		case k >= len(oldGroup.Actions):
+			changes = append(changes, FieldChange{Field: field, New: newGroup.Actions[k].ActionActuatorFunc})
+		case k >= len(newGroup.Actions):
+			changes = append(changes, FieldChange{Field: field, Old: oldGroup.Actions[k].ActionActuatorFunc})
+		case oldGroup.Actions[k].ActionActuatorFunc != newGroup.Actions[k].ActionActuatorFunc:
+			changes = append(changes, FieldChange{Field: field, Old: oldGroup.Actions[k].ActionActuatorFunc, New: newGroup.Actions[k].ActionActuatorFunc})
+		default:
+			changes = diffField(changes, field+".params", oldGroup.Actions[k].ActionActuatorFuncParam, newGroup.Actions[k].ActionActuatorFuncParam)
+		}
+	}
+	return changes
+}
+
+// diffField appends a change of the named field when its values differ.
+func diffField(changes []FieldChange, field string, oldValue, newValue any) []FieldChange {
+	if reflect.DeepEqual(oldValue, newValue) {
+		return changes
+	}
+	if oldFloat, ok := oldValue.(float64); ok {
+		if newFloat, ok := newValue.(float64); ok && math.Abs(oldFloat-newFloat) < 1e-9 {
+			return changes
+		}
+	}
+	return append(changes, FieldChange{Field: field, Old: oldValue, New: newValue})
+}
+
+// actionGroupList returns an optional action group as a list.
+func actionGroupList(group *ActionGroup) []ActionGroup {
+	if group == nil {
+		return nil
+	}
+	return []ActionGroup{*group}
+}
+
+// floatValue dereferences an optional number, returning nil for none.
+func floatValue(value *float64) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
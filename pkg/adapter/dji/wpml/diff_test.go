@@ -0,0 +1,117 @@
+package wpml
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diffEstimateWaylines(t *testing.T, oldWaylines, newWaylines *Waylines) *MissionDiff {
+	t.Helper()
+	oldMission, err := ConvertWaylinesToMission(oldWaylines)
+	require.NoError(t, err)
+	newMission, err := ConvertWaylinesToMission(newWaylines)
+	require.NoError(t, err)
+	diff, err := DiffMissions(oldMission, newMission)
+	require.NoError(t, err)
+	return diff
+}
+
+func TestDiffMissions_Unchanged(t *testing.T) {
+	diff := diffEstimateWaylines(t, testEstimateWaylines(), testEstimateWaylines())
+	assert.True(t, diff.Empty())
+
+	_, err := DiffMissions(nil, &Mission{})
+	require.ErrorIs(t, err, ErrMissionCannotBeEmpty)
+	_, err = DiffMissions(&Mission{}, &Mission{})
+	require.ErrorIs(t, err, ErrMissionWaylinesCannotBeEmpty)
+}
+
+func TestDiffMissions_Waypoints(t *testing.T) {
+	frame := estimateFrame()
+
+	// A waypoint inserted on the first leg leaves the later ones matched
+	inserted := testEstimateWaylines()
+	middle := frame.toCoordinate(point{50, 0})
+	waypoint := WaylinesWaypoint{Latitude: middle.Latitude, Longitude: middle.Longitude, Height: 50, Speed: 5, TriggerType: TriggerTypeReachPoint}
+	inserted.Waypoints = append(inserted.Waypoints[:1], append([]WaylinesWaypoint{waypoint}, inserted.Waypoints[1:]...)...)
+	diff := diffEstimateWaylines(t, testEstimateWaylines(), inserted)
+	assert.Empty(t, diff.Config)
+	require.Len(t, diff.Waylines, 1)
+	require.Len(t, diff.Waylines[0].Waypoints, 1)
+	added := diff.Waylines[0].Waypoints[0]
+	assert.Equal(t, ChangeAdded, added.Change)
+	require.NotNil(t, added.NewIndex)
+	assert.Equal(t, 1, *added.NewIndex)
+	assert.Nil(t, added.OldIndex)
+
+	// Moves, heights, speeds and actions of matched waypoints
+	edited := testEstimateWaylines()
+	corner := frame.toCoordinate(point{100, 10})
+	edited.Waypoints[1].Latitude, edited.Waypoints[1].Longitude = corner.Latitude, corner.Longitude
+	edited.Waypoints[1].Actions[0].Action = &HoverAction{HoverTime: 8}
+	edited.Waypoints[2].Height = 60
+	edited.Waypoints[2].Speed = 7
+	edited.GlobalRTHHeight = 120
+	diff = diffEstimateWaylines(t, testEstimateWaylines(), edited)
+	assert.Equal(t, []FieldChange{{Field: "global_rth_height", Old: 100.0, New: 120.0}}, diff.Config)
+	require.Len(t, diff.Waylines, 1)
+	waypoints := diff.Waylines[0].Waypoints
+	require.Len(t, waypoints, 2)
+
+	assert.Equal(t, ChangeModified, waypoints[0].Change)
+	assert.Equal(t, 1, *waypoints[0].OldIndex)
+	assert.InDelta(t, 10, waypoints[0].Moved, 1e-3)
+	assert.Empty(t, waypoints[0].Fields)
+	require.Len(t, waypoints[0].ActionGroups, 1)
+	assert.Equal(t, ChangeModified, waypoints[0].ActionGroups[0].Change)
+	require.Len(t, waypoints[0].ActionGroups[0].Fields, 1)
+	assert.Equal(t, "actions[0].params", waypoints[0].ActionGroups[0].Fields[0].Field)
+
+	assert.Zero(t, waypoints[1].Moved)
+	assert.Equal(t, []FieldChange{
+		{Field: "height", Old: 50.0, New: 60.0},
+		{Field: "speed", Old: 5.0, New: 7.0},
+	}, waypoints[1].Fields)
+
+	// A waypoint moved far away is removed and added again
+	moved := testEstimateWaylines()
+	far := frame.toCoordinate(point{400, 0})
+	moved.Waypoints[1].Latitude, moved.Waypoints[1].Longitude = far.Latitude, far.Longitude
+	diff = diffEstimateWaylines(t, testEstimateWaylines(), moved)
+	require.Len(t, diff.Waylines, 1)
+	waypoints = diff.Waylines[0].Waypoints
+	require.Len(t, waypoints, 2)
+	assert.ElementsMatch(t, []ChangeType{ChangeRemoved, ChangeAdded}, []ChangeType{waypoints[0].Change, waypoints[1].Change})
+}
+
+func TestDiffMissions_ActionGroupsAndWaylines(t *testing.T) {
+	edited := testEstimateWaylines()
+	edited.Waypoints[0].Actions = append(edited.Waypoints[0].Actions, ActionRequest{Type: ActionTypeHover, Action: &HoverAction{HoverTime: 2}})
+	edited.Waypoints[1].Actions = []ActionRequest{{Type: ActionTypeTakePhoto, Action: &TakePhotoAction{UseGlobalPayloadLensIndex: true}}}
+	edited.Waypoints[2].Actions = nil
+	diff := diffEstimateWaylines(t, testEstimateWaylines(), edited)
+	require.Len(t, diff.Waylines, 1)
+	waypoints := diff.Waylines[0].Waypoints
+	require.Len(t, waypoints, 3)
+	assert.Equal(t, []FieldChange{{Field: "actions[1]", New: ActionTypeHover}}, waypoints[0].ActionGroups[0].Fields)
+	assert.Equal(t, []FieldChange{{Field: "actions[0]", Old: ActionTypeHover, New: ActionTypeTakePhoto}}, waypoints[1].ActionGroups[0].Fields)
+	require.Len(t, waypoints[2].ActionGroups, 1)
+	assert.Equal(t, ChangeRemoved, waypoints[2].ActionGroups[0].Change)
+
+	// Waylines are matched by ID
+	oldMission, err := ConvertWaylinesToMission(testEstimateWaylines())
+	require.NoError(t, err)
+	newMission, err := ConvertWaylinesToMission(testEstimateWaylines())
+	require.NoError(t, err)
+	newMission.Waylines.Document.Folders[0].WaylineID = 1
+	diff, err = DiffMissions(oldMission, newMission)
+	require.NoError(t, err)
+	assert.Equal(t, []WaylineDiff{{WaylineID: 0, Change: ChangeRemoved}, {WaylineID: 1, Change: ChangeAdded}}, diff.Waylines)
+
+	data, err := json.Marshal(diff)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"waylines": [{"wayline_id": 0, "change": "removed"}, {"wayline_id": 1, "change": "added"}]}`, string(data))
+}
@@ -35,6 +35,10 @@ const (
 	ErrWriteWaylines = "failed to write wpmz/waylines.wpml: %w"
 	// ErrCloseZIPWriter is the error format for ZIP writer close failures.
 	ErrCloseZIPWriter = "failed to close ZIP writer: %w"
+	// ErrCreateResourceEntry is the error format for res folder ZIP entry creation failures.
+	ErrCreateResourceEntry = "failed to create wpmz/res/%s entry: %w"
+	// ErrWriteResource is the error format for res folder write failures.
+	ErrWriteResource = "failed to write wpmz/res/%s: %w"
 	// ErrConvertWaylines is the error format for waylines conversion failures.
 	ErrConvertWaylines = "failed to convert waylines: %w"
 	// ErrParseZIP is the error format for ZIP parsing failures.
@@ -97,6 +101,57 @@ const (
 	ErrFieldRequiredForDroneModel = "field %s is required for drone model %d"
 	// ErrFieldRequiredForPayloadModel is the error format for fields required by specific payload models.
 	ErrFieldRequiredForPayloadModel = "field %s is required for payload model %d"
+	// ErrActionNotSupportedByDrone is the error format for actions the drone model cannot run.
+	ErrActionNotSupportedByDrone = "action %s is not supported by drone model %d"
+	// ErrActionNotSupportedByPayload is the error format for actions the payload model cannot run.
+	ErrActionNotSupportedByPayload = "action %s is not supported by payload model %d"
+	// ErrLensNotAvailable is the error format for lenses the payload model does not have.
+	ErrLensNotAvailable = "lens %s is not available on payload model %d"
+	// ErrAutoRerouteNotSupported is the error format for auto reroute settings on drone models without it.
+	ErrAutoRerouteNotSupported = "auto reroute is not supported by drone model %d"
+	// ErrWaypointActionNotSupported is the error format for waypoint actions the drone or payload cannot run.
+	ErrWaypointActionNotSupported = "waypoint %d action %d: %w"
+
+	// ErrInvalidCoordinates is the error format for unparsable KML coordinate tuples.
+	ErrInvalidCoordinates = "invalid coordinates: %q"
+	// ErrSurveyValidationFailed is the error format for survey parameter validation failures.
+	ErrSurveyValidationFailed = "survey validation failed: %w"
+	// ErrUnknownPayloadCamera is the error format for payloads without a known mapping camera.
+	ErrUnknownPayloadCamera = "no mapping camera is known for payload model %d, camera must be set"
+	// ErrSurveyAltitudeOutOfRange is the error format for survey altitudes outside the flyable range.
+	ErrSurveyAltitudeOutOfRange = "survey altitude %.1f m is outside %.0f-%.0f m"
+	// ErrSurveySpeedTooHigh is the error format for survey speeds the camera cannot keep up with.
+	ErrSurveySpeedTooHigh = "survey speed %.1f m/s is outside 1-%.1f m/s allowed by the photo spacing and camera"
+	// ErrEstimateWayline is the error format for waylines that cannot be estimated.
+	ErrEstimateWayline = "failed to estimate wayline %d: %w"
+	// ErrEstimateMission is the error format for missions whose distance and duration cannot be estimated.
+	ErrEstimateMission = "failed to estimate mission: %w"
+	// ErrTerrainTakeOff is the error format for take-off points without terrain data.
+	ErrTerrainTakeOff = "failed to look up terrain at the take-off point: %w"
+	// ErrTerrainWaypoint is the error format for waypoints without terrain data.
+	ErrTerrainWaypoint = "failed to look up terrain at waypoint %d: %w"
+	// ErrTerrainHeightOutOfRange is the error format for terrain-adjusted heights outside the flyable range.
+	ErrTerrainHeightOutOfRange = "waypoint %d is %.1f m above the take-off point after terrain adjustment, outside 5-500 m"
+	// ErrTerrainWayline is the error format for waylines whose terrain clearance cannot be checked.
+	ErrTerrainWayline = "failed to check terrain clearance of wayline %d: %w"
+	// ErrUnsupportedRouteFormat is the error format for unknown route file formats.
+	ErrUnsupportedRouteFormat = "unsupported route format %q"
+	// ErrParseRoute is the error format for route files that cannot be read.
+	ErrParseRoute = "failed to parse %s route: %w"
+	// ErrExportRoute is the error format for route files that cannot be written.
+	ErrExportRoute = "failed to export %s route: %w"
+	// ErrExportWayline is the error format for waylines that cannot be exported.
+	ErrExportWayline = "failed to export wayline %d: %w"
+	// ErrInvalidRouteValue is the error format for unparsable values in route files.
+	ErrInvalidRouteValue = "invalid %s value %q"
+	// ErrInvalidGeofence is the error format for geofences that cannot be checked against.
+	ErrInvalidGeofence = "invalid geofence %q: %w"
+	// ErrParseGeofences is the error format for geofence files that cannot be read.
+	ErrParseGeofences = "failed to parse geofences: %w"
+	// ErrGeofenceWayline is the error format for waylines whose geofences cannot be checked.
+	ErrGeofenceWayline = "failed to check geofences of wayline %d: %w"
+	// ErrDiffWayline is the error format for waylines that cannot be compared.
+	ErrDiffWayline = "failed to diff wayline %d: %w"
 )
 
 // Sentinel error variables for common validation and structural error conditions.
@@ -109,6 +164,10 @@ var (
 	ErrMissionWaylinesCannotBeEmpty = errors.New("mission.Waylines cannot be empty")
 	// ErrKMZFormatIncorrect is returned when a KMZ file is missing required template or waylines files.
 	ErrKMZFormatIncorrect = errors.New("KMZ file format is incorrect: missing required template or waylines file")
+	// ErrMegaphoneFileMissing is returned when a megaphone action plays an audio file the mission does not carry.
+	ErrMegaphoneFileMissing = errors.New("megaphone audio file is missing from the mission resources")
+	// ErrMegaphoneFileMD5Mismatch is returned when a megaphone audio file does not match its declared MD5.
+	ErrMegaphoneFileMD5Mismatch = errors.New("megaphone audio file does not match megaphoneFileMD5")
 	// ErrActionIsNil is returned when an action is nil.
 	ErrActionIsNil = errors.New("action is nil")
 	// ErrActionCannotBeNil is returned when a nil action is provided to validation.
@@ -119,4 +178,24 @@ var (
 	ErrWaylineDocumentCannotBeNil = errors.New("wayline document cannot be nil")
 	// ErrTemplateCannotBeNil is returned when a nil template document is provided to validation.
 	ErrTemplateCannotBeNil = errors.New("template cannot be nil")
+	// ErrSurveyHeightRequired is returned when a survey has neither altitude nor GSD.
+	ErrSurveyHeightRequired = errors.New("survey altitude or gsd is required")
+	// ErrSurveyAreaTooSmall is returned when a survey area yields no survey lines.
+	ErrSurveyAreaTooSmall = errors.New("survey area is too small to plan")
+	// ErrTerrainDEMRequired is returned when terrain heights are needed without an elevation model.
+	ErrTerrainDEMRequired = errors.New("an elevation model is required for terrain heights")
+	// ErrTerrainGeoidRequired is returned when EGM96 or WGS84 heights are converted without a geoid model.
+	ErrTerrainGeoidRequired = errors.New("a geoid model is required for EGM96 and WGS84 heights")
+	// ErrRouteEmpty is returned when an imported route file has no waypoints.
+	ErrRouteEmpty = errors.New("route has no waypoints")
+	// ErrRouteMixedHeightModes is returned when the waypoints of an imported route use different height modes.
+	ErrRouteMixedHeightModes = errors.New("route waypoints use different height modes")
+	// ErrGeofenceType is returned for geofences that are neither nfz nor dfence.
+	ErrGeofenceType = errors.New("geofence type must be nfz or dfence")
+	// ErrGeofenceShape is returned for geofences without a polygon or circle.
+	ErrGeofenceShape = errors.New("geofence needs a polygon of at least 3 points or a circle with a positive radius")
+	// ErrGeofenceHeights is returned for geofences whose minimum height is above their maximum.
+	ErrGeofenceHeights = errors.New("geofence min_height is above its max_height")
+	// ErrGeofenceTakeOffRequired is returned when WGS84 heights are checked against height limits without a take-off height.
+	ErrGeofenceTakeOffRequired = errors.New("a take-off point height is required to check WGS84 heights against geofence height limits")
 )
@@ -0,0 +1,325 @@
+package wpml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Estimation constants.
+const (
+	// DefaultBatteryReserve is the share of the rated flight time kept in
+	// reserve for wind, landing and contingencies.
+	DefaultBatteryReserve = 0.3
+	// waypointAcceleration is the acceleration in m/s² assumed when braking
+	// at and leaving waypoints the aircraft stops at.
+	waypointAcceleration = 2.0
+)
+
+// Estimate holds the estimated length, flight time and photo count of a mission.
+type Estimate struct {
+	// Distance is the length of the waylines in meters
+	Distance float64 `json:"distance"`
+	// Duration is the time in seconds to fly the waylines, including stops and hovers
+	Duration float64 `json:"duration"`
+	// FlightDistance and FlightTime add the climb after take-off, the legs
+	// to and between waylines and the finish action
+	FlightDistance float64 `json:"flight_distance"`
+	FlightTime     float64 `json:"flight_time"`
+	// Photos is the expected number of photos
+	Photos int `json:"photos"`
+	// Waylines holds the estimates of each wayline
+	Waylines []WaylineEstimate `json:"waylines"`
+	// Battery is nil when the drone has no known battery profile
+	Battery *BatteryEstimate `json:"battery,omitempty"`
+}
+
+// WaylineEstimate holds the estimated length, flight time and photo count of one wayline.
+type WaylineEstimate struct {
+	WaylineID int     `json:"wayline_id"`
+	Distance  float64 `json:"distance"`
+	Duration  float64 `json:"duration"`
+	Photos    int     `json:"photos"`
+}
+
+// BatteryEstimate tells whether a mission can be flown on one battery.
+type BatteryEstimate struct {
+	// AvailableTime is the rated flight time in seconds less the reserve
+	AvailableTime float64 `json:"available_time"`
+	// Usage is the share in percent of the available time the flight takes
+	Usage float64 `json:"usage"`
+	// Feasible is true when the flight fits in the available time
+	Feasible bool `json:"feasible"`
+}
+
+// estimateWaypoint is a waypoint of a wayline as the estimator flies it.
+type estimateWaypoint struct {
+	position Coordinate
+	// height is the height in meters above the take-off point
+	height float64
+	// speed is the speed in meters per second towards the next waypoint
+	speed float64
+	stop  bool
+	hover float64
+}
+
+// flight tracks the aircraft along the legs of a flight.
+type flight struct {
+	profile  *BatteryProfile
+	position Coordinate
+	height   float64
+	distance float64
+	duration float64
+}
+
+// flyTo flies a straight leg at speed, limited by the climb and descent
+// speeds, and returns its length and duration.
+func (f *flight) flyTo(position Coordinate, height, speed float64) (distance, duration float64) {
+	climb := height - f.height
+	distance = math.Hypot(Distance(f.position, position), climb)
+	duration = distance / speed
+	if climb > 0 {
+		duration = math.Max(duration, climb/f.profile.ClimbSpeed)
+	} else {
+		duration = math.Max(duration, -climb/f.profile.DescentSpeed)
+	}
+	f.position, f.height = position, height
+	f.distance += distance
+	f.duration += duration
+	return distance, duration
+}
+
+// EstimateMission estimates the length, flight time and photo count of a
+// mission from its waylines document. The flight climbs to the take-off
+// security height, flies to each wayline at the transitional speed, flies
+// the waylines and performs the finish action. Photos are counted from the
+// photo actions and their triggers.
+func EstimateMission(mission *Mission) (*Estimate, error) {
+	if mission == nil {
+		return nil, ErrMissionCannotBeEmpty
+	}
+	if mission.Waylines == nil {
+		return nil, ErrMissionWaylinesCannotBeEmpty
+	}
+	cfg := &mission.Waylines.Document.MissionConfig
+	profile, known := BatteryProfileForDrone(DroneModel(cfg.DroneInfo.DroneEnumValue))
+	if !known {
+		fallback := defaultBatteryProfile
+		profile = &fallback
+	}
+	transitSpeed := cfg.GlobalTransitionalSpeed
+	if transitSpeed <= 0 {
+		transitSpeed = maxSurveySpeed
+	}
+	// Only the template records where the aircraft takes off
+	var takeOffRef *string
+	if mission.Template != nil {
+		takeOffRef = mission.Template.Document.MissionConfig.TakeOffRefPoint
+	}
+	takeOff, takeOffHeight, hasTakeOff := parseTakeOffRefPoint(takeOffRef)
+
+	folders := mission.Waylines.Document.Folders
+	waylines := make([][]estimateWaypoint, len(folders))
+	for i := range folders {
+		heightOffset := 0.0
+		if folders[i].ExecuteHeightMode == ExecuteHeightModeWGS84 {
+			heightOffset = takeOffHeight
+		}
+		waypoints, err := estimateWaypoints(&folders[i], heightOffset, transitSpeed)
+		if err != nil {
+			return nil, fmt.Errorf(ErrEstimateWayline, folders[i].WaylineID, err)
+		}
+		waylines[i] = waypoints
+	}
+
+	if !hasTakeOff && len(waylines) > 0 && len(waylines[0]) > 0 {
+		takeOff = waylines[0][0].position
+	}
+
+	f := &flight{profile: profile, position: takeOff}
+	f.flyTo(takeOff, cfg.TakeOffSecurityHeight, transitSpeed)
+
+	estimate := &Estimate{Waylines: make([]WaylineEstimate, 0, len(folders))}
+	for i, waypoints := range waylines {
+		if len(waypoints) == 0 {
+			continue
+		}
+		f.flyTo(waypoints[0].position, waypoints[0].height, transitSpeed)
+		wayline := f.flyWayline(&folders[i], waypoints)
+		estimate.Distance += wayline.Distance
+		estimate.Duration += wayline.Duration
+		estimate.Photos += wayline.Photos
+		estimate.Waylines = append(estimate.Waylines, wayline)
+	}
+
+	if len(estimate.Waylines) > 0 {
+		f.finish(cfg, transitSpeed, takeOff, waylines[0][0])
+	}
+	estimate.FlightDistance = f.distance
+	estimate.FlightTime = f.duration
+
+	if known {
+		available := profile.FlightTime * (1 - DefaultBatteryReserve)
+		estimate.Battery = &BatteryEstimate{
+			AvailableTime: available,
+			Usage:         estimate.FlightTime / available * 100,
+			Feasible:      estimate.FlightTime <= available,
+		}
+	}
+	return estimate, nil
+}
+
+// SetEstimate writes the estimated length and duration of each wayline into
+// the waylines document.
+func (m *Mission) SetEstimate(estimate *Estimate) {
+	if m.Waylines == nil {
+		return
+	}
+	for _, wayline := range estimate.Waylines {
+		for i := range m.Waylines.Document.Folders {
+			folder := &m.Waylines.Document.Folders[i]
+			if folder.WaylineID == wayline.WaylineID {
+				folder.Distance = float64Ptr(wayline.Distance)
+				folder.Duration = float64Ptr(wayline.Duration)
+			}
+		}
+	}
+}
+
+// estimateWaypoints reads the waypoints of a wayline, subtracting
+// heightOffset from their heights to make them relative to the take-off point.
+func estimateWaypoints(folder *WaylineFolder, heightOffset, transitSpeed float64) ([]estimateWaypoint, error) {
+	waypoints := make([]estimateWaypoint, len(folder.Placemarks))
+	for i := range folder.Placemarks {
+		placemark := &folder.Placemarks[i]
+		if placemark.Point == nil {
+			return nil, fmt.Errorf(ErrInvalidCoordinates, "")
+		}
+		coords, err := parseCoordinateList(placemark.Point.Coordinates)
+		if err != nil || len(coords) != 1 {
+			return nil, fmt.Errorf(ErrInvalidCoordinates, placemark.Point.Coordinates)
+		}
+
+		waypoint := estimateWaypoint{position: coords[0], speed: folder.AutoFlightSpeed}
+		if placemark.ExecuteHeight != nil {
+			waypoint.height = *placemark.ExecuteHeight - heightOffset
+		}
+		if placemark.WaypointSpeed != nil && *placemark.WaypointSpeed > 0 {
+			waypoint.speed = *placemark.WaypointSpeed
+		}
+		if waypoint.speed <= 0 {
+			waypoint.speed = transitSpeed
+		}
+		if turn := placemark.WaypointTurnParam; turn != nil {
+			waypoint.stop = turn.WaypointTurnMode == TurnModeToPointAndStopWithDiscontinuityCurvature ||
+				turn.WaypointTurnMode == TurnModeToPointAndStopWithContinuityCurvature
+		}
+		for _, group := range placemark.ActionGroups {
+			for _, action := range group.Actions {
+				if action.ActionActuatorFunc == ActionTypeHover && action.ActionActuatorFuncParam != nil &&
+					action.ActionActuatorFuncParam.HoverTime != nil {
+					waypoint.hover += *action.ActionActuatorFuncParam.HoverTime
+				}
+			}
+		}
+		waypoints[i] = waypoint
+	}
+	return waypoints, nil
+}
+
+// flyWayline flies the waypoints of a wayline from the first one.
+func (f *flight) flyWayline(folder *WaylineFolder, waypoints []estimateWaypoint) WaylineEstimate {
+	wayline := WaylineEstimate{WaylineID: folder.WaylineID}
+
+	// Offsets along the wayline of each waypoint, by placemark index
+	distances := make(map[int]float64, len(waypoints))
+	durations := make(map[int]float64, len(waypoints))
+	for i, waypoint := range waypoints {
+		if i > 0 {
+			distance, duration := f.flyTo(waypoint.position, waypoint.height, waypoints[i-1].speed)
+			wayline.Distance += distance
+			wayline.Duration += duration
+		}
+		index := folder.Placemarks[i].Index
+		distances[index], durations[index] = wayline.Distance, wayline.Duration
+
+		pause := waypoint.hover
+		if waypoint.stop && i > 0 && i < len(waypoints)-1 {
+			// Braking to a stop and speeding up again loses speed/acceleration seconds
+			pause += waypoint.speed / waypointAcceleration
+		}
+		wayline.Duration += pause
+		f.duration += pause
+	}
+
+	for _, placemark := range folder.Placemarks {
+		for _, group := range placemark.ActionGroups {
+			wayline.Photos += groupPhotos(&group, distances, durations)
+		}
+	}
+	return wayline
+}
+
+// groupPhotos returns the number of photos an action group takes.
+// Interval triggers repeat the group over its range of waypoints; other
+// triggers run it once.
+func groupPhotos(group *ActionGroup, distances, durations map[int]float64) int {
+	photos := 0
+	for _, action := range group.Actions {
+		switch action.ActionActuatorFunc {
+		case ActionTypeTakePhoto, ActionTypeAccurateShoot, ActionTypeOrientedShoot:
+			photos++
+		}
+	}
+	param := group.ActionTrigger.ActionTriggerParam
+	if photos == 0 || param == nil || *param <= 0 {
+		return photos
+	}
+
+	switch group.ActionTrigger.ActionTriggerType {
+	case TriggerTypeMultipleDistance:
+		span := distances[group.ActionGroupEndIndex] - distances[group.ActionGroupStartIndex]
+		return photos * (int(span / *param) + 1)
+	case TriggerTypeMultipleTiming:
+		span := durations[group.ActionGroupEndIndex] - durations[group.ActionGroupStartIndex]
+		return photos * (int(span / *param) + 1)
+	default:
+		return photos
+	}
+}
+
+// finish performs the finish action after the last wayline.
+func (f *flight) finish(cfg *WaylinesMissionConfig, transitSpeed float64, takeOff Coordinate, first estimateWaypoint) {
+	switch cfg.FinishAction {
+	case FinishActionGoHome:
+		height := math.Max(f.height, cfg.GlobalRTHHeight)
+		f.flyTo(f.position, height, transitSpeed)
+		f.flyTo(takeOff, height, transitSpeed)
+		f.flyTo(takeOff, 0, transitSpeed)
+	case FinishActionAutoLand:
+		f.flyTo(f.position, 0, transitSpeed)
+	case FinishActionGotoFirstWaypoint:
+		f.flyTo(first.position, first.height, transitSpeed)
+	}
+}
+
+// parseTakeOffRefPoint parses a "latitude,longitude,height" take-off reference point.
+func parseTakeOffRefPoint(point *string) (position Coordinate, height float64, ok bool) {
+	if point == nil {
+		return Coordinate{}, 0, false
+	}
+	parts := strings.Split(*point, ",")
+	if len(parts) != 3 {
+		return Coordinate{}, 0, false
+	}
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return Coordinate{}, 0, false
+		}
+		values[i] = value
+	}
+	return Coordinate{Longitude: values[1], Latitude: values[0]}, values[2], true
+}
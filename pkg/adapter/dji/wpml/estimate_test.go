@@ -0,0 +1,170 @@
+package wpml
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEstimateWaylines flies 100 m east then 100 m north at 5 m/s, taking a
+// photo at both ends and hovering 5 s at the corner.
+func testEstimateWaylines() *Waylines {
+	origin := Coordinate{116.4074, 39.9042}
+	frame := localFrame{origin: origin, cosLat: math.Cos(origin.Latitude * math.Pi / 180)}
+	corner := frame.toCoordinate(point{100, 0})
+	end := frame.toCoordinate(point{100, 100})
+	photo := ActionRequest{Type: ActionTypeTakePhoto, Action: &TakePhotoAction{UseGlobalPayloadLensIndex: true}}
+
+	return &Waylines{
+		Name:                    "estimate",
+		DroneModel:              DroneM3Series,
+		PayloadModel:            PayloadMavic3ECamera,
+		TemplateType:            TemplateTypeWaypoint,
+		GlobalSpeed:             5,
+		TakeOffSecurityHeight:   20,
+		GlobalRTHHeight:         100,
+		GlobalTransitionalSpeed: 10,
+		Waypoints: []WaylinesWaypoint{
+			{Latitude: origin.Latitude, Longitude: origin.Longitude, Height: 50, Speed: 5, TriggerType: TriggerTypeReachPoint, Actions: []ActionRequest{photo}},
+			{Latitude: corner.Latitude, Longitude: corner.Longitude, Height: 50, Speed: 5, TriggerType: TriggerTypeReachPoint, Actions: []ActionRequest{
+				{Type: ActionTypeHover, Action: &HoverAction{HoverTime: 5}},
+			}},
+			{Latitude: end.Latitude, Longitude: end.Longitude, Height: 50, Speed: 5, TriggerType: TriggerTypeReachPoint, Actions: []ActionRequest{photo}},
+		},
+	}
+}
+
+func TestEstimateMission(t *testing.T) {
+	mission, err := ConvertWaylinesToMission(testEstimateWaylines())
+	require.NoError(t, err)
+
+	estimate, err := EstimateMission(mission)
+	require.NoError(t, err)
+
+	assert.InDelta(t, 200, estimate.Distance, 0.1)
+	// 40 s flying, 5 s hovering and 2.5 s braking and speeding up at the corner
+	assert.InDelta(t, 47.5, estimate.Duration, 0.1)
+	assert.Equal(t, 2, estimate.Photos)
+	require.Len(t, estimate.Waylines, 1)
+	assert.Equal(t, estimate.Distance, estimate.Waylines[0].Distance)
+
+	// Climbing to 20 m, then 50 m, flying the wayline, climbing to the
+	// return height, flying 141 m home and landing
+	assert.InDelta(t, 20+30+200+50+141.4+100, estimate.FlightDistance, 0.1)
+	assert.InDelta(t, 20.0/6+30.0/6+47.5+50.0/6+14.14+100.0/6, estimate.FlightTime, 0.1)
+
+	require.NotNil(t, estimate.Battery)
+	assert.InDelta(t, 45*60*(1-DefaultBatteryReserve), estimate.Battery.AvailableTime, 1e-9)
+	assert.InDelta(t, estimate.FlightTime/estimate.Battery.AvailableTime*100, estimate.Battery.Usage, 1e-9)
+	assert.True(t, estimate.Battery.Feasible)
+
+	t.Run("written into the waylines", func(t *testing.T) {
+		folder := mission.Waylines.Document.Folders[0]
+		require.NotNil(t, folder.Distance)
+		require.NotNil(t, folder.Duration)
+		assert.Equal(t, estimate.Distance, *folder.Distance)
+		assert.Equal(t, estimate.Duration, *folder.Duration)
+
+		buffer, err := CreateKmzBuffer(mission)
+		require.NoError(t, err)
+		parsed, err := ParseKMZBuffer(buffer.Bytes())
+		require.NoError(t, err)
+		assert.InDelta(t, estimate.Distance, *parsed.Waylines.Document.Folders[0].Distance, 1e-6)
+	})
+
+	t.Run("caller figures are kept", func(t *testing.T) {
+		waylines := testEstimateWaylines()
+		waylines.Distance = float64Ptr(1234)
+		mission, err := ConvertWaylinesToMission(waylines)
+		require.NoError(t, err)
+		folder := mission.Waylines.Document.Folders[0]
+		assert.Equal(t, 1234.0, *folder.Distance)
+		assert.InDelta(t, 47.5, *folder.Duration, 0.1)
+	})
+}
+
+func TestEstimateMission_FinishActions(t *testing.T) {
+	tests := []struct {
+		action   FinishAction
+		distance float64
+	}{
+		{FinishActionNoAction, 20 + 30 + 200},
+		{FinishActionAutoLand, 20 + 30 + 200 + 50},
+		{FinishActionGotoFirstWaypoint, 20 + 30 + 200 + 141.4},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.action), func(t *testing.T) {
+			waylines := testEstimateWaylines()
+			waylines.FinishAction = tt.action
+			mission, err := ConvertWaylinesToMission(waylines)
+			require.NoError(t, err)
+
+			estimate, err := EstimateMission(mission)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.distance, estimate.FlightDistance, 0.1)
+		})
+	}
+}
+
+func TestEstimateMission_Battery(t *testing.T) {
+	mission, err := ConvertWaylinesToMission(testEstimateWaylines())
+	require.NoError(t, err)
+
+	// Moving the last waypoint 20 km away takes longer than a battery lasts
+	folder := &mission.Waylines.Document.Folders[0]
+	folder.Placemarks[2].Point.Coordinates = formatCoordinates(116.4074, 40.0842)
+	estimate, err := EstimateMission(mission)
+	require.NoError(t, err)
+	require.NotNil(t, estimate.Battery)
+	assert.False(t, estimate.Battery.Feasible)
+	assert.Greater(t, estimate.Battery.Usage, 100.0)
+
+	mission.Waylines.Document.MissionConfig.DroneInfo.DroneEnumValue = int(DroneDahua)
+	estimate, err = EstimateMission(mission)
+	require.NoError(t, err)
+	assert.Nil(t, estimate.Battery)
+	assert.Positive(t, estimate.FlightTime)
+}
+
+func TestGroupPhotos(t *testing.T) {
+	distances := map[int]float64{0: 0, 1: 100, 2: 250}
+	durations := map[int]float64{0: 0, 1: 20, 2: 50}
+	photo := Action{ActionActuatorFunc: ActionTypeTakePhoto}
+
+	tests := []struct {
+		name    string
+		trigger string
+		param   *float64
+		actions []Action
+		photos  int
+	}{
+		{"once", TriggerTypeReachPoint, nil, []Action{photo}, 1},
+		{"every 20 m", TriggerTypeMultipleDistance, float64Ptr(20), []Action{photo}, 13},
+		{"every 10 s", TriggerTypeMultipleTiming, float64Ptr(10), []Action{photo}, 6},
+		{"two actions", TriggerTypeMultipleDistance, float64Ptr(50), []Action{photo, {ActionActuatorFunc: ActionTypeOrientedShoot}}, 12},
+		{"no photos", TriggerTypeMultipleDistance, float64Ptr(20), []Action{{ActionActuatorFunc: ActionTypeStartRecord}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := NewActionGroup(0, 0, 2)
+			group.SetTrigger(tt.trigger, tt.param)
+			group.Actions = tt.actions
+			assert.Equal(t, tt.photos, groupPhotos(group, distances, durations))
+		})
+	}
+}
+
+func TestEstimateMission_Errors(t *testing.T) {
+	_, err := EstimateMission(nil)
+	assert.ErrorIs(t, err, ErrMissionCannotBeEmpty)
+	_, err = EstimateMission(&Mission{})
+	assert.ErrorIs(t, err, ErrMissionWaylinesCannotBeEmpty)
+
+	mission, err := ConvertWaylinesToMission(testEstimateWaylines())
+	require.NoError(t, err)
+	mission.Waylines.Document.Folders[0].Placemarks[1].Point.Coordinates = "east"
+	_, err = EstimateMission(mission)
+	assert.ErrorContains(t, err, "failed to estimate wayline 0")
+}
@@ -0,0 +1,412 @@
+package wpml
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// geofenceEpsilon is the tolerance of leg parameters, well under a
+// millimeter on any practical leg.
+const geofenceEpsilon = 1e-9
+
+// GeofenceType tells whether a geofence keeps aircraft out or in, as the
+// types of DJI custom flight areas do.
+type GeofenceType string
+
+// Geofence types.
+const (
+	// GeofenceTypeNoFly is a no-fly zone aircraft must stay out of
+	GeofenceTypeNoFly GeofenceType = "nfz"
+	// GeofenceTypeFence is a geofence aircraft must stay within
+	GeofenceTypeFence GeofenceType = "dfence"
+)
+
+// Geofence is a no-fly zone or geofence bounded by a polygon or a circle,
+// and optionally by heights in meters above the take-off point.
+type Geofence struct {
+	ID   string       `json:"id,omitempty"`
+	Name string       `json:"name,omitempty"`
+	Type GeofenceType `json:"type"`
+	// Polygon is the outer ring of a polygonal area
+	Polygon []Coordinate `json:"polygon,omitempty"`
+	// Center and Radius in meters bound a circular area
+	Center *Coordinate `json:"center,omitempty"`
+	Radius float64     `json:"radius,omitempty"`
+	// MinHeight and MaxHeight bound the area vertically, unbounded when nil.
+	// No-fly zones apply between them; geofences keep aircraft between them
+	MinHeight *float64 `json:"min_height,omitempty"`
+	MaxHeight *float64 `json:"max_height,omitempty"`
+}
+
+// GeofenceViolation is a leg of a wayline entering a no-fly zone or leaving a geofence.
+type GeofenceViolation struct {
+	WaylineID int `json:"wayline_id"`
+	// SegmentIndex is the index of the waypoint starting the leg, which ends
+	// at the next waypoint
+	SegmentIndex int          `json:"segment_index"`
+	GeofenceID   string       `json:"geofence_id,omitempty"`
+	GeofenceName string       `json:"geofence_name,omitempty"`
+	GeofenceType GeofenceType `json:"geofence_type"`
+	// Position and Height are where the violation starts along the leg, the
+	// height in meters above the take-off point
+	Position Coordinate `json:"position"`
+	Height   float64    `json:"height"`
+}
+
+// String describes the violation.
+func (v *GeofenceViolation) String() string {
+	verb, kind := "enters", "no-fly zone"
+	if v.GeofenceType == GeofenceTypeFence {
+		verb, kind = "leaves", "geofence"
+	}
+	name := v.GeofenceName
+	if name == "" {
+		name = v.GeofenceID
+	}
+	return fmt.Sprintf("wayline %d segment %d %s %s %q at %.6f,%.6f, %.1f m",
+		v.WaylineID, v.SegmentIndex, verb, kind, name, v.Position.Latitude, v.Position.Longitude, v.Height)
+}
+
+// GeofenceError reports the legs of a mission violating geofences.
+type GeofenceError struct {
+	Violations []GeofenceViolation
+}
+
+// Error implements error.
+func (e *GeofenceError) Error() string {
+	descriptions := make([]string, len(e.Violations))
+	for i := range e.Violations {
+		descriptions[i] = e.Violations[i].String()
+	}
+	return "geofence validation failed: " + strings.Join(descriptions, "; ")
+}
+
+// validate checks that a geofence has a known type, a shape and consistent heights.
+func (g *Geofence) validate() error {
+	var err error
+	switch {
+	case g.Type != GeofenceTypeNoFly && g.Type != GeofenceTypeFence:
+		err = ErrGeofenceType
+	case len(g.Polygon) < 3 && (g.Center == nil || g.Radius <= 0):
+		err = ErrGeofenceShape
+	case g.MinHeight != nil && g.MaxHeight != nil && *g.MinHeight > *g.MaxHeight:
+		err = ErrGeofenceHeights
+	}
+	if err != nil {
+		return fmt.Errorf(ErrInvalidGeofence, g.ID, err)
+	}
+	return nil
+}
+
+// limitsHeight tells whether a geofence has height limits.
+func (g *Geofence) limitsHeight() bool {
+	return g.MinHeight != nil || g.MaxHeight != nil
+}
+
+// CheckGeofences checks every leg of every wayline of a mission against
+// geofences, following the straight line between waypoints at linearly
+// changing height, and returns the legs entering no-fly zones or leaving
+// geofences. Heights following the surface are taken as heights above the
+// take-off point; WGS84 heights are measured from the take-off point
+// height of the template.
+func CheckGeofences(mission *Mission, geofences []Geofence) ([]GeofenceViolation, error) {
+	if mission == nil {
+		return nil, ErrMissionCannotBeEmpty
+	}
+	if mission.Waylines == nil {
+		return nil, ErrMissionWaylinesCannotBeEmpty
+	}
+	if len(geofences) == 0 {
+		return nil, nil
+	}
+
+	var takeOffRef *string
+	if mission.Template != nil {
+		takeOffRef = mission.Template.Document.MissionConfig.TakeOffRefPoint
+	}
+	_, takeOffHeight, hasTakeOff := parseTakeOffRefPoint(takeOffRef)
+
+	var violations []GeofenceViolation
+	for i := range mission.Waylines.Document.Folders {
+		folder := &mission.Waylines.Document.Folders[i]
+		offset := 0.0
+		if folder.ExecuteHeightMode == ExecuteHeightModeWGS84 {
+			if !hasTakeOff && limitsHeight(geofences) {
+				return nil, fmt.Errorf(ErrGeofenceWayline, folder.WaylineID, ErrGeofenceTakeOffRequired)
+			}
+			offset = takeOffHeight
+		}
+		waypoints, err := estimateWaypoints(folder, offset, 0)
+		if err != nil {
+			return nil, fmt.Errorf(ErrGeofenceWayline, folder.WaylineID, err)
+		}
+		violations = append(violations, checkWaylineGeofences(folder.WaylineID, waypoints, geofences)...)
+	}
+	return violations, nil
+}
+
+// limitsHeight tells whether any of the geofences has height limits.
+func limitsHeight(geofences []Geofence) bool {
+	for i := range geofences {
+		if geofences[i].limitsHeight() {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWaylineGeofences returns the first violation of each geofence by
+// each leg of a wayline. A wayline of a single waypoint is a leg of its own.
+func checkWaylineGeofences(waylineID int, waypoints []estimateWaypoint, geofences []Geofence) []GeofenceViolation {
+	if len(waypoints) == 0 {
+		return nil
+	}
+	var violations []GeofenceViolation
+	for i := 0; i < max(len(waypoints)-1, 1); i++ {
+		from, to := waypoints[i], waypoints[min(i+1, len(waypoints)-1)]
+		for j := range geofences {
+			g := &geofences[j]
+			t, violated := g.firstViolation(from, to)
+			if !violated {
+				continue
+			}
+			violations = append(violations, GeofenceViolation{
+				WaylineID:    waylineID,
+				SegmentIndex: i,
+				GeofenceID:   g.ID,
+				GeofenceName: g.Name,
+				GeofenceType: g.Type,
+				Position: Coordinate{
+					Longitude: from.position.Longitude + (to.position.Longitude-from.position.Longitude)*t,
+					Latitude:  from.position.Latitude + (to.position.Latitude-from.position.Latitude)*t,
+				},
+				Height: from.height + (to.height-from.height)*t,
+			})
+		}
+	}
+	return violations
+}
+
+// interval is a range of leg parameters from 0 at the start of a leg to 1 at its end.
+type interval struct {
+	lo, hi float64
+}
+
+// firstViolation returns the parameter along a leg where it first enters a
+// no-fly zone or leaves a geofence, and false when it does neither.
+func (g *Geofence) firstViolation(from, to estimateWaypoint) (float64, bool) {
+	vertical, verticalOK := heightInterval(from.height, to.height, g.MinHeight, g.MaxHeight)
+	var inside []interval
+	if verticalOK {
+		for _, horizontal := range g.insideIntervals(from.position, to.position) {
+			lo, hi := math.Max(horizontal.lo, vertical.lo), math.Min(horizontal.hi, vertical.hi)
+			if lo <= hi+geofenceEpsilon {
+				inside = append(inside, interval{lo, hi})
+			}
+		}
+	}
+
+	if g.Type == GeofenceTypeNoFly {
+		if len(inside) == 0 {
+			return 0, false
+		}
+		return inside[0].lo, true
+	}
+	// The leg must stay inside the geofence from start to end
+	t := 0.0
+	for _, in := range inside {
+		if in.lo > t+geofenceEpsilon {
+			return t, true
+		}
+		t = math.Max(t, in.hi)
+	}
+	return t, t < 1-geofenceEpsilon
+}
+
+// heightInterval returns the parameters of a leg climbing linearly from h0
+// to h1 at which it is between the height limits, and false when it never is.
+func heightInterval(h0, h1 float64, minHeight, maxHeight *float64) (interval, bool) {
+	in := interval{0, 1}
+	bound := func(limit float64, above bool) {
+		if h1 == h0 {
+			if (h0 < limit) == above && h0 != limit {
+				in = interval{1, 0}
+			}
+			return
+		}
+		t := (limit - h0) / (h1 - h0)
+		// The leg is above the limit after t when climbing and before t when descending
+		if (h1 > h0) == above {
+			in.lo = math.Max(in.lo, t)
+		} else {
+			in.hi = math.Min(in.hi, t)
+		}
+	}
+	if minHeight != nil {
+		bound(*minHeight, true)
+	}
+	if maxHeight != nil {
+		bound(*maxHeight, false)
+	}
+	return in, in.lo <= in.hi+geofenceEpsilon
+}
+
+// insideIntervals returns the sorted parameters of a leg at which it is
+// horizontally inside the geofence.
+func (g *Geofence) insideIntervals(from, to Coordinate) []interval {
+	frame := localFrame{origin: from, cosLat: math.Cos(from.Latitude * math.Pi / 180)}
+	end := frame.toPoint(to)
+	if len(g.Polygon) >= 3 {
+		return polygonIntervals(frame.toPoints(g.Polygon), end)
+	}
+	return circleIntervals(frame.toPoint(*g.Center), g.Radius, end)
+}
+
+// polygonIntervals returns the parameters of the leg from the origin to end
+// at which it is inside a polygon, splitting the leg where it crosses the
+// polygon edges.
+func polygonIntervals(polygon []point, end point) []interval {
+	ts := []float64{0, 1}
+	for i, p := range polygon {
+		edge := polygon[(i+1)%len(polygon)].sub(p)
+		denominator := cross(end, edge)
+		if denominator == 0 {
+			continue
+		}
+		t, u := cross(p, edge)/denominator, cross(p, end)/denominator
+		if t > 0 && t < 1 && u >= 0 && u <= 1 {
+			ts = append(ts, t)
+		}
+	}
+	sort.Float64s(ts)
+
+	var intervals []interval
+	for i := 1; i < len(ts); i++ {
+		if !pointInPolygon(end.scale((ts[i-1]+ts[i])/2), polygon) {
+			continue
+		}
+		if n := len(intervals); n > 0 && intervals[n-1].hi >= ts[i-1] {
+			intervals[n-1].hi = ts[i]
+		} else {
+			intervals = append(intervals, interval{ts[i-1], ts[i]})
+		}
+	}
+	return intervals
+}
+
+// circleIntervals returns the parameters of the leg from the origin to end
+// at which it is inside a circle.
+func circleIntervals(center point, radius float64, end point) []interval {
+	// |t*end - center|² = radius² is a quadratic in t
+	a := end.x*end.x + end.y*end.y
+	b := -2 * (end.x*center.x + end.y*center.y)
+	c := center.x*center.x + center.y*center.y - radius*radius
+	if a == 0 {
+		if c <= 0 {
+			return []interval{{0, 1}}
+		}
+		return nil
+	}
+	discriminant := b*b - 4*a*c
+	if discriminant < 0 {
+		return nil
+	}
+	root := math.Sqrt(discriminant)
+	lo, hi := math.Max((-b-root)/(2*a), 0), math.Min((-b+root)/(2*a), 1)
+	if lo > hi {
+		return nil
+	}
+	return []interval{{lo, hi}}
+}
+
+// cross returns the z component of the cross product of two vectors.
+func cross(p, q point) float64 {
+	return p.x*q.y - p.y*q.x
+}
+
+// pointInPolygon tells whether a point is inside a polygon by the even-odd rule.
+func pointInPolygon(p point, polygon []point) bool {
+	inside := false
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+		if (a.y > p.y) != (b.y > p.y) && p.x < a.x+(p.y-a.y)*(b.x-a.x)/(b.y-a.y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// ParseGeofences reads geofences from a GeoJSON feature collection, such as
+// an export of DJI custom flight areas. Polygon features bound polygonal
+// areas and point features with a "radius" property circular ones. The
+// "type" property is nfz or dfence, nfz when absent; "id", "name",
+// "min_height" and "max_height" properties are optional.
+func ParseGeofences(data []byte) ([]Geofence, error) {
+	var document geoJSONDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf(ErrParseGeofences, err)
+	}
+	geofences := make([]Geofence, 0, len(document.Features))
+	for i := range document.Features {
+		g, err := geoJSONGeofence(&document.Features[i])
+		if err != nil {
+			return nil, fmt.Errorf(ErrParseGeofences, err)
+		}
+		if err := g.validate(); err != nil {
+			return nil, fmt.Errorf(ErrParseGeofences, err)
+		}
+		geofences = append(geofences, *g)
+	}
+	return geofences, nil
+}
+
+// geoJSONGeofence reads the geofence of a GeoJSON feature.
+func geoJSONGeofence(feature *geoJSONFeature) (*Geofence, error) {
+	properties := feature.Properties
+	g := &Geofence{Type: GeofenceTypeNoFly}
+	g.ID, _ = properties["id"].(string)
+	g.Name, _ = properties["name"].(string)
+	if geofenceType, ok := properties["type"].(string); ok {
+		g.Type = GeofenceType(geofenceType)
+	}
+	if height, ok := properties["min_height"].(float64); ok {
+		g.MinHeight = float64Ptr(height)
+	}
+	if height, ok := properties["max_height"].(float64); ok {
+		g.MaxHeight = float64Ptr(height)
+	}
+	if feature.Geometry == nil {
+		return nil, fmt.Errorf(ErrInvalidGeofence, g.ID, ErrGeofenceShape)
+	}
+
+	switch feature.Geometry.Type {
+	case geoJSONTypePolygon:
+		var rings [][][]float64
+		if err := json.Unmarshal(feature.Geometry.Coordinates, &rings); err != nil || len(rings) == 0 {
+			return nil, fmt.Errorf(ErrInvalidGeofence, g.ID, ErrGeofenceShape)
+		}
+		for _, position := range rings[0] {
+			if len(position) < 2 {
+				return nil, fmt.Errorf(ErrInvalidCoordinates, fmt.Sprint(position))
+			}
+			g.Polygon = append(g.Polygon, Coordinate{Longitude: position[0], Latitude: position[1]})
+		}
+		// GeoJSON rings repeat their first position at the end
+		if n := len(g.Polygon); n > 1 && g.Polygon[0] == g.Polygon[n-1] {
+			g.Polygon = g.Polygon[:n-1]
+		}
+	case geoJSONTypePoint:
+		positions, err := feature.Geometry.positions()
+		if err != nil {
+			return nil, err
+		}
+		g.Center = &positions[0].position
+		g.Radius, _ = properties["radius"].(float64)
+	default:
+		return nil, fmt.Errorf(ErrInvalidGeofence, g.ID, ErrGeofenceShape)
+	}
+	return g, nil
+}
@@ -0,0 +1,208 @@
+package wpml
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// estimateFrame is the local frame of the estimate waylines, centered on their first waypoint.
+func estimateFrame() localFrame {
+	origin := Coordinate{116.4074, 39.9042}
+	return localFrame{origin: origin, cosLat: math.Cos(origin.Latitude * math.Pi / 180)}
+}
+
+// squareGeofence is a square around (x, y) meters in the estimate frame.
+func squareGeofence(id string, geofenceType GeofenceType, x, y, half float64) Geofence {
+	frame := estimateFrame()
+	return Geofence{
+		ID:   id,
+		Type: geofenceType,
+		Polygon: []Coordinate{
+			frame.toCoordinate(point{x - half, y - half}),
+			frame.toCoordinate(point{x + half, y - half}),
+			frame.toCoordinate(point{x + half, y + half}),
+			frame.toCoordinate(point{x - half, y + half}),
+		},
+	}
+}
+
+func checkEstimateGeofences(t *testing.T, waylines *Waylines, geofences ...Geofence) []GeofenceViolation {
+	t.Helper()
+	mission, err := ConvertWaylinesToMission(waylines)
+	require.NoError(t, err)
+	violations, err := CheckGeofences(mission, geofences)
+	require.NoError(t, err)
+	return violations
+}
+
+func TestCheckGeofences_NoFlyZones(t *testing.T) {
+	frame := estimateFrame()
+
+	// The first leg crosses the square between 40 and 60 m east without a waypoint inside
+	square := squareGeofence("square", GeofenceTypeNoFly, 50, 0, 10)
+	violations := checkEstimateGeofences(t, testEstimateWaylines(), square)
+	require.Len(t, violations, 1)
+	assert.Equal(t, 0, violations[0].SegmentIndex)
+	assert.Equal(t, "square", violations[0].GeofenceID)
+	entry := frame.toPoint(violations[0].Position)
+	assert.InDelta(t, 40, entry.x, 1e-3)
+	assert.InDelta(t, 50, violations[0].Height, 1e-9)
+
+	circle := Geofence{ID: "circle", Type: GeofenceTypeNoFly, Center: float64Coordinate(frame.toCoordinate(point{100, 50})), Radius: 10}
+	violations = checkEstimateGeofences(t, testEstimateWaylines(), circle)
+	require.Len(t, violations, 1)
+	assert.Equal(t, 1, violations[0].SegmentIndex)
+	assert.InDelta(t, 40, frame.toPoint(violations[0].Position).y, 1e-3)
+
+	// Zones above or below the aircraft do not apply
+	above, below, around := square, square, square
+	above.MinHeight = float64Ptr(60)
+	below.MaxHeight = float64Ptr(40)
+	around.MinHeight, around.MaxHeight = float64Ptr(40), float64Ptr(60)
+	assert.Empty(t, checkEstimateGeofences(t, testEstimateWaylines(), above, below))
+	assert.Len(t, checkEstimateGeofences(t, testEstimateWaylines(), around), 1)
+
+	// A climbing leg enters a zone above 75 m halfway
+	climbing := testEstimateWaylines()
+	climbing.Waypoints[1].Height = 100
+	high := squareGeofence("high", GeofenceTypeNoFly, 50, 0, 60)
+	high.MinHeight = float64Ptr(75)
+	violations = checkEstimateGeofences(t, climbing, high)
+	require.Len(t, violations, 2)
+	assert.Equal(t, 0, violations[0].SegmentIndex)
+	assert.InDelta(t, 50, frame.toPoint(violations[0].Position).x, 1e-3)
+	assert.InDelta(t, 75, violations[0].Height, 1e-6)
+	// The second leg starts inside the zone
+	assert.Equal(t, 1, violations[1].SegmentIndex)
+	assert.InDelta(t, 100, violations[1].Height, 1e-9)
+}
+
+func TestCheckGeofences_Fences(t *testing.T) {
+	frame := estimateFrame()
+
+	fence := squareGeofence("fence", GeofenceTypeFence, 50, 50, 80)
+	assert.Empty(t, checkEstimateGeofences(t, testEstimateWaylines(), fence))
+
+	// The second leg leaves the fence 60 m north
+	short := Geofence{ID: "short", Name: "Short fence", Type: GeofenceTypeFence, Polygon: squareGeofence("", "", 50, 25, 60).Polygon}
+	short.Polygon[2] = frame.toCoordinate(point{110, 60})
+	short.Polygon[3] = frame.toCoordinate(point{-10, 60})
+	violations := checkEstimateGeofences(t, testEstimateWaylines(), short)
+	require.Len(t, violations, 1)
+	assert.Equal(t, 1, violations[0].SegmentIndex)
+	assert.InDelta(t, 60, frame.toPoint(violations[0].Position).y, 1e-3)
+	assert.Contains(t, violations[0].String(), `segment 1 leaves geofence "Short fence"`)
+
+	// A ceiling below the aircraft is violated from the start
+	fence.MaxHeight = float64Ptr(45)
+	violations = checkEstimateGeofences(t, testEstimateWaylines(), fence)
+	require.Len(t, violations, 2)
+	assert.InDelta(t, 0, frame.toPoint(violations[0].Position).x, 1e-6)
+}
+
+func TestCheckGeofences_WGS84(t *testing.T) {
+	waylines := testEstimateWaylines()
+	waylines.HeightType = HeightModeEGM96
+	for i := range waylines.Waypoints {
+		waylines.Waypoints[i].Height = 160
+		waylines.Waypoints[i].EllipsoidHeight = float64Ptr(150)
+	}
+	ceiling := squareGeofence("ceiling", GeofenceTypeNoFly, 50, 0, 10)
+	ceiling.MinHeight = float64Ptr(60)
+
+	mission, err := ConvertWaylinesToMission(waylines)
+	require.NoError(t, err)
+	mission.Template = nil
+	_, err = CheckGeofences(mission, []Geofence{ceiling})
+	require.ErrorIs(t, err, ErrGeofenceTakeOffRequired)
+
+	// 150 m above the ellipsoid is 70 m above a take-off point 80 m above it
+	waylines.TakeOffRefPointLatitude, waylines.TakeOffRefPointLongitude = 39.9042, 116.4074
+	waylines.TakeOffRefPointHeight = 80
+	violations := checkEstimateGeofences(t, waylines, ceiling)
+	require.Len(t, violations, 1)
+	assert.InDelta(t, 70, violations[0].Height, 1e-9)
+}
+
+func TestValidator_ValidateGeofences(t *testing.T) {
+	v, err := NewValidator()
+	require.NoError(t, err)
+	mission, err := ConvertWaylinesToMission(testEstimateWaylines())
+	require.NoError(t, err)
+	require.NoError(t, v.ValidateGeofences(mission))
+
+	require.NoError(t, v.SetGeofences([]Geofence{squareGeofence("square", GeofenceTypeNoFly, 50, 0, 10)}))
+	err = v.ValidateGeofences(mission)
+	var geofenceErr *GeofenceError
+	require.ErrorAs(t, err, &geofenceErr)
+	assert.Len(t, geofenceErr.Violations, 1)
+	assert.Contains(t, err.Error(), `wayline 0 segment 0 enters no-fly zone "square"`)
+
+	invalid := []Geofence{
+		{ID: "type", Type: "zone", Center: &Coordinate{}, Radius: 1},
+		{ID: "shape", Type: GeofenceTypeNoFly, Polygon: []Coordinate{{}, {}}},
+		{ID: "heights", Type: GeofenceTypeNoFly, Center: &Coordinate{}, Radius: 1, MinHeight: float64Ptr(2), MaxHeight: float64Ptr(1)},
+	}
+	for _, want := range []error{ErrGeofenceType, ErrGeofenceShape, ErrGeofenceHeights} {
+		err = v.SetGeofences(invalid[:1])
+		assert.ErrorIs(t, err, want)
+		invalid = invalid[1:]
+	}
+	_, err = CheckGeofences(nil, nil)
+	assert.ErrorIs(t, err, ErrMissionCannotBeEmpty)
+}
+
+func TestParseGeofences(t *testing.T) {
+	data := []byte(`{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "properties": {"id": "a", "name": "Airport", "max_height": 120},
+     "geometry": {"type": "Polygon", "coordinates": [[[116.40, 39.90], [116.41, 39.90], [116.41, 39.91], [116.40, 39.90]]]}},
+    {"type": "Feature", "properties": {"id": "b", "type": "dfence", "radius": 500},
+     "geometry": {"type": "Point", "coordinates": [116.40, 39.90]}}
+  ]
+}`)
+	geofences, err := ParseGeofences(data)
+	require.NoError(t, err)
+	require.Len(t, geofences, 2)
+	assert.Equal(t, GeofenceTypeNoFly, geofences[0].Type)
+	assert.Equal(t, "Airport", geofences[0].Name)
+	assert.Len(t, geofences[0].Polygon, 3)
+	require.NotNil(t, geofences[0].MaxHeight)
+	assert.InDelta(t, 120, *geofences[0].MaxHeight, 1e-9)
+	assert.Nil(t, geofences[0].MinHeight)
+	assert.Equal(t, GeofenceTypeFence, geofences[1].Type)
+	require.NotNil(t, geofences[1].Center)
+	assert.InDelta(t, 500, geofences[1].Radius, 1e-9)
+
+	_, err = ParseGeofences([]byte(`{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {}, "geometry": {"type": "Point", "coordinates": [1, 2]}}]}`))
+	assert.ErrorIs(t, err, ErrGeofenceShape)
+	_, err = ParseGeofences([]byte(`{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {}, "geometry": {"type": "LineString", "coordinates": [[1, 2], [3, 4]]}}]}`))
+	assert.ErrorIs(t, err, ErrGeofenceShape)
+	_, err = ParseGeofences([]byte(`[`))
+	assert.ErrorContains(t, err, "failed to parse geofences")
+}
+
+func TestHeightInterval(t *testing.T) {
+	in, ok := heightInterval(50, 50, nil, nil)
+	assert.True(t, ok)
+	assert.Equal(t, interval{0, 1}, in)
+
+	in, ok = heightInterval(100, 0, float64Ptr(25), float64Ptr(75))
+	assert.True(t, ok)
+	assert.InDelta(t, 0.25, in.lo, 1e-12)
+	assert.InDelta(t, 0.75, in.hi, 1e-12)
+
+	_, ok = heightInterval(50, 50, float64Ptr(60), nil)
+	assert.False(t, ok)
+	_, ok = heightInterval(10, 20, nil, float64Ptr(5))
+	assert.False(t, ok)
+}
+
+// float64Coordinate returns a pointer to a coordinate.
+func float64Coordinate(c Coordinate) *Coordinate {
+	return &c
+}
@@ -0,0 +1,284 @@
+package wpml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// earthRadius is the mean Earth radius in meters used for planning distances.
+const earthRadius = 6371008.8
+
+// Coordinate represents a WGS84 position in degrees.
+type Coordinate struct {
+	Longitude float64 `json:"longitude" validate:"min=-180,max=180"`
+	Latitude  float64 `json:"latitude" validate:"min=-90,max=90"`
+}
+
+// NewPolygon creates a Polygon whose outer boundary is the given ring, closing it if needed.
+func NewPolygon(ring []Coordinate) *Polygon {
+	if len(ring) > 0 && ring[0] != ring[len(ring)-1] {
+		ring = append(ring[:len(ring):len(ring)], ring[0])
+	}
+	return &Polygon{
+		OuterBoundaryIs: OuterBoundaryIs{
+			LinearRing: LinearRing{Coordinates: formatCoordinateList(ring)},
+		},
+	}
+}
+
+// Ring returns the vertices of the polygon's outer boundary without the closing vertex.
+func (p *Polygon) Ring() ([]Coordinate, error) {
+	ring, err := parseCoordinateList(p.OuterBoundaryIs.LinearRing.Coordinates)
+	if err != nil {
+		return nil, err
+	}
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		ring = ring[:len(ring)-1]
+	}
+	return ring, nil
+}
+
+// NewLineString creates a LineString through the given coordinates.
+func NewLineString(points []Coordinate) *LineString {
+	return &LineString{Coordinates: formatCoordinateList(points)}
+}
+
+// Points returns the coordinates of the line string.
+func (l *LineString) Points() ([]Coordinate, error) {
+	return parseCoordinateList(l.Coordinates)
+}
+
+// formatCoordinateList formats coordinates as KML "longitude,latitude" tuples separated by whitespace.
+func formatCoordinateList(coords []Coordinate) string {
+	tuples := make([]string, len(coords))
+	for i, c := range coords {
+		tuples[i] = formatCoordinates(c.Longitude, c.Latitude)
+	}
+	return strings.Join(tuples, "\n")
+}
+
+// parseCoordinateList parses KML coordinate tuples, ignoring altitudes.
+func parseCoordinateList(s string) ([]Coordinate, error) {
+	fields := strings.Fields(s)
+	coords := make([]Coordinate, 0, len(fields))
+	for _, tuple := range fields {
+		parts := strings.Split(tuple, ",")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf(ErrInvalidCoordinates, tuple)
+		}
+		lon, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf(ErrInvalidCoordinates, tuple)
+		}
+		lat, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf(ErrInvalidCoordinates, tuple)
+		}
+		coords = append(coords, Coordinate{Longitude: lon, Latitude: lat})
+	}
+	return coords, nil
+}
+
+// Distance returns the great-circle distance in meters between two coordinates.
+func Distance(a, b Coordinate) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// point is a position in meters east (x) and north (y) of a local frame's origin.
+type point struct {
+	x, y float64
+}
+
+func (p point) add(q point) point     { return point{p.x + q.x, p.y + q.y} }
+func (p point) sub(q point) point     { return point{p.x - q.x, p.y - q.y} }
+func (p point) scale(f float64) point { return point{p.x * f, p.y * f} }
+func (p point) length() float64       { return math.Hypot(p.x, p.y) }
+
+// localFrame projects coordinates onto a plane tangent at its origin. Survey
+// areas span a few kilometers at most, where the equirectangular projection
+// is accurate to well under a meter.
+type localFrame struct {
+	origin Coordinate
+	cosLat float64
+}
+
+// newLocalFrame creates a local frame centered on the given coordinates.
+func newLocalFrame(coords []Coordinate) localFrame {
+	var origin Coordinate
+	for _, c := range coords {
+		origin.Longitude += c.Longitude / float64(len(coords))
+		origin.Latitude += c.Latitude / float64(len(coords))
+	}
+	return localFrame{origin: origin, cosLat: math.Cos(origin.Latitude * math.Pi / 180)}
+}
+
+func (f localFrame) toPoint(c Coordinate) point {
+	return point{
+		x: (c.Longitude - f.origin.Longitude) * math.Pi / 180 * earthRadius * f.cosLat,
+		y: (c.Latitude - f.origin.Latitude) * math.Pi / 180 * earthRadius,
+	}
+}
+
+func (f localFrame) toCoordinate(p point) Coordinate {
+	return Coordinate{
+		Longitude: f.origin.Longitude + p.x/(earthRadius*f.cosLat)*180/math.Pi,
+		Latitude:  f.origin.Latitude + p.y/earthRadius*180/math.Pi,
+	}
+}
+
+func (f localFrame) toPoints(coords []Coordinate) []point {
+	points := make([]point, len(coords))
+	for i, c := range coords {
+		points[i] = f.toPoint(c)
+	}
+	return points
+}
+
+// signedArea returns the area of a polygon, positive when its vertices run counter-clockwise.
+func signedArea(polygon []point) float64 {
+	area := 0.0
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		area += p.x*q.y - q.x*p.y
+	}
+	return area / 2
+}
+
+// offsetPolygon moves every edge of a polygon outwards by distance meters, or
+// inwards when distance is negative, joining the edges with mitered corners.
+func offsetPolygon(polygon []point, distance float64) []point {
+	if distance == 0 || len(polygon) < 3 {
+		return polygon
+	}
+	// Outward normals are to the right of edges of counter-clockwise polygons
+	if signedArea(polygon) < 0 {
+		distance = -distance
+	}
+
+	n := len(polygon)
+	normals := make([]point, n)
+	for i, p := range polygon {
+		edge := polygon[(i+1)%n].sub(p)
+		if l := edge.length(); l > 0 {
+			normals[i] = point{edge.y / l, -edge.x / l}
+		}
+	}
+
+	offset := make([]point, n)
+	for i, p := range polygon {
+		offset[i] = p.add(miter(normals[(i+n-1)%n], normals[i], distance))
+	}
+	return offset
+}
+
+// offsetLine moves a line sideways by distance meters, to the right of its
+// direction of travel or to the left when distance is negative.
+func offsetLine(line []point, distance float64) []point {
+	n := len(line)
+	if distance == 0 || n < 2 {
+		return line
+	}
+
+	normals := make([]point, n-1)
+	for i := range normals {
+		edge := line[i+1].sub(line[i])
+		if l := edge.length(); l > 0 {
+			normals[i] = point{edge.y / l, -edge.x / l}
+		}
+	}
+
+	offset := make([]point, n)
+	for i, p := range line {
+		prev, next := normals[max(i-1, 0)], normals[min(i, n-2)]
+		offset[i] = p.add(miter(prev, next, distance))
+	}
+	return offset
+}
+
+// miter returns the offset of a vertex joining edges with unit normals prev
+// and next when the edges move distance meters along their normals.
+func miter(prev, next point, distance float64) point {
+	// The miter of two unit normals has length 1/cos(half the turn) and is
+	// capped for very sharp corners
+	denominator := math.Max(1+prev.x*next.x+prev.y*next.y, 0.25)
+	return prev.add(next).scale(distance / denominator)
+}
+
+// extendLine lengthens the first and last segments of a line by extension meters.
+func extendLine(line []point, extension float64) []point {
+	n := len(line)
+	if extension == 0 || n < 2 {
+		return line
+	}
+	extended := append([]point(nil), line...)
+	if d := line[0].sub(line[1]); d.length() > 0 {
+		extended[0] = line[0].add(d.scale(extension / d.length()))
+	}
+	if d := line[n-1].sub(line[n-2]); d.length() > 0 {
+		extended[n-1] = line[n-1].add(d.scale(extension / d.length()))
+	}
+	return extended
+}
+
+// lineLength returns the length of a line in meters.
+func lineLength(line []point) float64 {
+	length := 0.0
+	for i := 1; i < len(line); i++ {
+		length += line[i].sub(line[i-1]).length()
+	}
+	return length
+}
+
+// trackFrame rotates local points so survey lines run along the first axis.
+type trackFrame struct {
+	sin, cos float64
+}
+
+// newTrackFrame creates a frame whose along-track axis points along course degrees clockwise from north.
+func newTrackFrame(course float64) trackFrame {
+	rad := course * math.Pi / 180
+	return trackFrame{sin: math.Sin(rad), cos: math.Cos(rad)}
+}
+
+// toTrack returns the along-track and cross-track offsets of a point.
+func (t trackFrame) toTrack(p point) (along, cross float64) {
+	return p.x*t.sin + p.y*t.cos, p.x*t.cos - p.y*t.sin
+}
+
+// fromTrack returns the point at the given along-track and cross-track offsets.
+func (t trackFrame) fromTrack(along, cross float64) point {
+	return point{x: along*t.sin + cross*t.cos, y: along*t.cos - cross*t.sin}
+}
+
+// scanPolygon returns the along-track extent of a polygon on the survey line
+// at the given cross-track offset, and false when the line misses the polygon.
+// Concave polygons are covered by the full extent of each line.
+func (t trackFrame) scanPolygon(polygon []point, cross float64) (minAlong, maxAlong float64, ok bool) {
+	minAlong, maxAlong = math.Inf(1), math.Inf(-1)
+	for i, p := range polygon {
+		pa, pc := t.toTrack(p)
+		qa, qc := t.toTrack(polygon[(i+1)%len(polygon)])
+		if (pc <= cross && cross < qc) || (qc <= cross && cross < pc) {
+			a := pa + (cross-pc)*(qa-pa)/(qc-pc)
+			minAlong, maxAlong = math.Min(minAlong, a), math.Max(maxAlong, a)
+			ok = true
+		}
+	}
+	return minAlong, maxAlong, ok
+}
+
+// crossExtent returns the smallest and largest cross-track offsets of a polygon's vertices.
+func (t trackFrame) crossExtent(polygon []point) (minCross, maxCross float64) {
+	minCross, maxCross = math.Inf(1), math.Inf(-1)
+	for _, p := range polygon {
+		_, c := t.toTrack(p)
+		minCross, maxCross = math.Min(minCross, c), math.Max(maxCross, c)
+	}
+	return minCross, maxCross
+}
@@ -0,0 +1,114 @@
+package wpml
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rectangle returns the corners of a width (east) by height (north) meter
+// rectangle centered on center, counter-clockwise from the south west
+func rectangle(center Coordinate, width, height float64) []Coordinate {
+	frame := localFrame{origin: center, cosLat: math.Cos(center.Latitude * math.Pi / 180)}
+	return []Coordinate{
+		frame.toCoordinate(point{-width / 2, -height / 2}),
+		frame.toCoordinate(point{width / 2, -height / 2}),
+		frame.toCoordinate(point{width / 2, height / 2}),
+		frame.toCoordinate(point{-width / 2, height / 2}),
+	}
+}
+
+func TestPolygon_Ring(t *testing.T) {
+	ring := []Coordinate{{116.4074, 39.9042}, {116.4084, 39.9042}, {116.4084, 39.9052}}
+	polygon := NewPolygon(ring)
+	assert.Equal(t, "116.4074,39.9042\n116.4084,39.9042\n116.4084,39.9052\n116.4074,39.9042", polygon.OuterBoundaryIs.LinearRing.Coordinates)
+
+	parsed, err := polygon.Ring()
+	require.NoError(t, err)
+	assert.Equal(t, ring, parsed)
+
+	polygon.OuterBoundaryIs.LinearRing.Coordinates = "116.4,39.9,0 bad"
+	_, err = polygon.Ring()
+	assert.Error(t, err)
+}
+
+func TestLineString_Points(t *testing.T) {
+	points := []Coordinate{{116.4074, 39.9042}, {116.4084, 39.9052}}
+	parsed, err := NewLineString(points).Points()
+	require.NoError(t, err)
+	assert.Equal(t, points, parsed)
+}
+
+func TestDistance(t *testing.T) {
+	assert.InDelta(t, 111195, Distance(Coordinate{0, 0}, Coordinate{0, 1}), 1)
+	assert.InDelta(t, 0, Distance(Coordinate{116.4, 39.9}, Coordinate{116.4, 39.9}), 1e-9)
+}
+
+func TestLocalFrame(t *testing.T) {
+	area := rectangle(Coordinate{116.4074, 39.9042}, 200, 100)
+	frame := newLocalFrame(area)
+	points := frame.toPoints(area)
+
+	assert.InDelta(t, 200*100, signedArea(points), 10)
+	assert.InDelta(t, 200, Distance(area[0], area[1]), 0.1)
+	back := frame.toCoordinate(points[2])
+	assert.InDelta(t, area[2].Longitude, back.Longitude, 1e-9)
+	assert.InDelta(t, area[2].Latitude, back.Latitude, 1e-9)
+}
+
+func TestOffsetPolygon(t *testing.T) {
+	square := []point{{0, 0}, {100, 0}, {100, 100}, {0, 100}}
+	assert.InDelta(t, 120*120, signedArea(offsetPolygon(square, 10)), 1e-6)
+	assert.InDelta(t, 80*80, signedArea(offsetPolygon(square, -10)), 1e-6)
+
+	clockwise := []point{{0, 0}, {0, 100}, {100, 100}, {100, 0}}
+	assert.InDelta(t, -120*120, signedArea(offsetPolygon(clockwise, 10)), 1e-6)
+}
+
+func TestTrackFrame_ScanPolygon(t *testing.T) {
+	square := []point{{0, 0}, {100, 0}, {100, 100}, {0, 100}}
+
+	// Lines running north scan the square at an easting
+	track := newTrackFrame(0)
+	minAlong, maxAlong, ok := track.scanPolygon(square, 50)
+	require.True(t, ok)
+	assert.InDelta(t, 0, minAlong, 1e-9)
+	assert.InDelta(t, 100, maxAlong, 1e-9)
+	_, _, ok = track.scanPolygon(square, 150)
+	assert.False(t, ok)
+
+	// Lines running east scan it at a negated northing
+	track = newTrackFrame(90)
+	minCross, maxCross := track.crossExtent(square)
+	assert.InDelta(t, -100, minCross, 1e-9)
+	assert.InDelta(t, 0, maxCross, 1e-9)
+	p := track.fromTrack(track.toTrack(point{30, 70}))
+	assert.InDelta(t, 30, p.x, 1e-9)
+	assert.InDelta(t, 70, p.y, 1e-9)
+}
+
+func TestOffsetLine(t *testing.T) {
+	line := []point{{0, 0}, {200, 0}, {200, 200}}
+
+	right := offsetLine(line, 20)
+	assert.InDelta(t, 0, right[0].x, 1e-9)
+	assert.InDelta(t, -20, right[0].y, 1e-9)
+	assert.InDelta(t, 220, right[1].x, 1e-9)
+	assert.InDelta(t, -20, right[1].y, 1e-9)
+	assert.InDelta(t, 220, right[2].x, 1e-9)
+	assert.InDelta(t, 200, right[2].y, 1e-9)
+
+	left := offsetLine(line, -20)
+	assert.InDelta(t, 180, left[1].x, 1e-9)
+	assert.InDelta(t, 20, left[1].y, 1e-9)
+}
+
+func TestExtendLine(t *testing.T) {
+	line := []point{{0, 0}, {200, 0}, {200, 200}}
+	extended := extendLine(line, 10)
+	assert.Equal(t, []point{{-10, 0}, {200, 0}, {200, 210}}, extended)
+	assert.Equal(t, point{0, 0}, line[0], "the line is not modified")
+	assert.InDelta(t, 420, lineLength(extended), 1e-9)
+}
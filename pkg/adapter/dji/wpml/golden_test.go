@@ -0,0 +1,153 @@
+package wpml
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// updateGolden rewrites the golden KMZ documents: go test -run TestGolden -update
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+// goldenTime is the creation time written to golden templates.
+const goldenTime = 1735689600000
+
+// assertGolden compares data with a golden file, or rewrites it with -update.
+func assertGolden(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+		return
+	}
+	golden, err := os.ReadFile(filepath.Clean(path))
+	require.NoError(t, err)
+	assert.Equal(t, string(golden), string(data))
+}
+
+func TestGolden_ActionCatalogue(t *testing.T) {
+	dir := filepath.Join("testdata", "catalogue")
+	data, err := os.ReadFile(filepath.Join(dir, "waylines.json"))
+	require.NoError(t, err)
+	var waylines Waylines
+	require.NoError(t, json.Unmarshal(data, &waylines))
+
+	mission, err := ConvertWaylinesToMission(&waylines)
+	require.NoError(t, err)
+	mission.Template.Document.CreateTime = goldenTime
+	mission.Template.Document.UpdateTime = goldenTime
+
+	// The KMZ reads back to the same documents
+	buffer, err := CreateKmzBuffer(mission)
+	require.NoError(t, err)
+	parsed, err := ParseKMZBuffer(buffer.Bytes())
+	require.NoError(t, err)
+
+	template, err := MarshalTemplate(parsed.Template)
+	require.NoError(t, err)
+	assertGolden(t, filepath.Join(dir, "template.kml"), template)
+	waylinesData, err := MarshalWaylines(parsed.Waylines)
+	require.NoError(t, err)
+	assertGolden(t, filepath.Join(dir, "waylines.wpml"), waylinesData)
+
+	v, err := NewValidator()
+	require.NoError(t, err)
+	require.NoError(t, v.ValidateWaylinesDocument(parsed.Waylines))
+	require.NoError(t, v.ValidateMissionSupport(parsed.Waylines))
+
+	seen := make(map[string]bool)
+	for _, placemark := range parsed.Waylines.Document.Folders[0].Placemarks {
+		for _, group := range placemark.ActionGroups {
+			for _, action := range group.Actions {
+				seen[action.ActionActuatorFunc] = true
+			}
+		}
+	}
+	for _, actionType := range []string{
+		ActionTypeStartContinuousShooting, ActionTypeStopContinuousShooting,
+		ActionTypeMegaphone, ActionTypeSearchlight, ActionTypeSetFocusType,
+	} {
+		assert.True(t, seen[actionType], actionType)
+	}
+}
+
+// TestGolden_DJIDocumentation parses the sample template.kml and waylines.wpml
+// of the DJI WPML documentation (docs/protocol/dji/en/60.api-reference/00.dji-wpml)
+// and checks that they serialize back to the same documents.
+func TestGolden_DJIDocumentation(t *testing.T) {
+	dir := filepath.Join("testdata", "docs")
+
+	data, err := os.ReadFile(filepath.Join(dir, "template.kml"))
+	require.NoError(t, err)
+	template, err := UnmarshalTemplate(data)
+	require.NoError(t, err)
+	assert.Equal(t, "Name", template.Document.Author)
+	assert.Equal(t, int64(1637600807044), template.Document.CreateTime)
+	require.Len(t, template.Document.Folders, 1)
+	require.Len(t, template.Document.Folders[0].Placemarks, 2)
+	assertDocumentationActions(t, template.Document.Folders[0].Placemarks[1])
+
+	marshaled, err := MarshalTemplate(template)
+	require.NoError(t, err)
+	assertElementsKept(t, data, marshaled)
+	reparsed, err := UnmarshalTemplate(marshaled)
+	require.NoError(t, err)
+	assert.Equal(t, template, reparsed)
+
+	data, err = os.ReadFile(filepath.Join(dir, "waylines.wpml"))
+	require.NoError(t, err)
+	waylines, err := UnmarshalWaylines(data)
+	require.NoError(t, err)
+	require.Len(t, waylines.Document.Folders, 1)
+	folder := waylines.Document.Folders[0]
+	assert.Equal(t, ExecuteHeightMode("WGS84"), folder.ExecuteHeightMode)
+	assert.InDelta(t, 10.0, folder.AutoFlightSpeed, 0)
+	require.Len(t, folder.Placemarks, 2)
+	assertDocumentationActions(t, folder.Placemarks[1])
+
+	marshaled, err = MarshalWaylines(waylines)
+	require.NoError(t, err)
+	assertElementsKept(t, data, marshaled)
+	reparsedWaylines, err := UnmarshalWaylines(marshaled)
+	require.NoError(t, err)
+	assert.Equal(t, waylines, reparsedWaylines)
+}
+
+// wpmlLeaf matches a wpml element holding a value.
+var wpmlLeaf = regexp.MustCompile(`<wpml:(\w+)>([^<]*)</wpml:\w+>`)
+
+// assertElementsKept checks that every valued wpml element of a source document
+// is written back with the same value.
+func assertElementsKept(t *testing.T, source, marshaled []byte) {
+	t.Helper()
+	for _, leaf := range wpmlLeaf.FindAllStringSubmatch(string(source), -1) {
+		assert.Contains(t, string(marshaled), leaf[0], leaf[1])
+	}
+}
+
+// assertDocumentationActions checks the gimbalRotate and takePhoto actions of
+// waypoint 1 in the documentation samples.
+func assertDocumentationActions(t *testing.T, placemark Placemark) {
+	t.Helper()
+	require.Len(t, placemark.ActionGroups, 1)
+	actions := placemark.ActionGroups[0].Actions
+	require.Len(t, actions, 2)
+
+	assert.Equal(t, ActionTypeGimbalRotate, actions[0].ActionActuatorFunc)
+	param := actions[0].ActionActuatorFuncParam
+	require.NotNil(t, param)
+	require.NotNil(t, param.GimbalRotateMode)
+	assert.Equal(t, "absoluteAngle", *param.GimbalRotateMode)
+	require.NotNil(t, param.GimbalYawRotateAngle)
+	assert.InDelta(t, 30.0, *param.GimbalYawRotateAngle, 0)
+
+	assert.Equal(t, ActionTypeTakePhoto, actions[1].ActionActuatorFunc)
+	require.NotNil(t, actions[1].ActionActuatorFuncParam)
+	require.NotNil(t, actions[1].ActionActuatorFuncParam.FileSuffix)
+	assert.Equal(t, "point1", *actions[1].ActionActuatorFuncParam.FileSuffix)
+}
@@ -3,15 +3,23 @@ package wpml
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
 
+// kmzResourceDir is the res folder next to template.kml that holds the
+// auxiliary files of a wayline, such as megaphone audio.
+const kmzResourceDir = "wpmz/res/"
+
 // CreateKmz creates a KMZ file at the specified path from a Mission.
 func CreateKmz(mission *Mission, kmzPath string) error {
 	buffer, err := CreateKmzBuffer(mission)
@@ -32,6 +40,8 @@ func CreateKmz(mission *Mission, kmzPath string) error {
 }
 
 // CreateKmzBuffer creates a KMZ file as an in-memory buffer from a Mission.
+// The mission's resources are written to the res folder; every audio file a
+// megaphone action plays must be among them with a matching MD5.
 func CreateKmzBuffer(mission *Mission) (*bytes.Buffer, error) {
 	if mission == nil {
 		return nil, ErrMissionCannotBeEmpty
@@ -42,6 +52,9 @@ func CreateKmzBuffer(mission *Mission) (*bytes.Buffer, error) {
 	if mission.Waylines == nil {
 		return nil, ErrMissionWaylinesCannotBeEmpty
 	}
+	if err := checkMegaphoneResources(mission); err != nil {
+		return nil, err
+	}
 	templateData, err := MarshalTemplate(mission.Template)
 	if err != nil {
 		return nil, fmt.Errorf(ErrSerializeTemplate, err)
@@ -72,6 +85,18 @@ func CreateKmzBuffer(mission *Mission) (*bytes.Buffer, error) {
 		return nil, fmt.Errorf(ErrWriteWaylines, err)
 	}
 
+	for _, name := range slices.Sorted(maps.Keys(mission.Resources)) {
+		resourceWriter, err := zipWriter.Create(kmzResourceDir + name)
+		if err != nil {
+			_ = zipWriter.Close()
+			return nil, fmt.Errorf(ErrCreateResourceEntry, name, err)
+		}
+		if _, err := resourceWriter.Write(mission.Resources[name]); err != nil {
+			_ = zipWriter.Close()
+			return nil, fmt.Errorf(ErrWriteResource, name, err)
+		}
+	}
+
 	if err := zipWriter.Close(); err != nil {
 		return nil, fmt.Errorf(ErrCloseZIPWriter, err)
 	}
@@ -154,10 +179,14 @@ func ParseKMZBuffer(buffer []byte) (*Mission, error) {
 			if err != nil {
 				return nil, fmt.Errorf(ErrReadWaylinesWPML, err)
 			}
-		case strings.HasPrefix(file.Name, "res/"):
+		default:
+			name, ok := resourceName(file.Name)
+			if !ok {
+				continue
+			}
 			resData, readErr := readZipFile(file)
 			if readErr == nil {
-				resources[file.Name] = resData
+				resources[name] = resData
 			}
 		}
 	}
@@ -221,6 +250,58 @@ func GenerateKMZJSON(mission *Mission, fileName string) (string, error) {
 	return string(jsonData), nil
 }
 
+// resourceName returns the name of a file in the res folder of a KMZ, which
+// sits next to template.kml or, in some archives, at the root.
+func resourceName(path string) (string, bool) {
+	for _, dir := range []string{kmzResourceDir, "res/"} {
+		if name, ok := strings.CutPrefix(path, dir); ok && name != "" && !strings.HasSuffix(name, "/") {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// checkMegaphoneResources reports megaphone actions that play an audio file
+// the mission does not carry, or carries with a different MD5.
+func checkMegaphoneResources(mission *Mission) error {
+	for _, folder := range mission.Waylines.Document.Folders {
+		var groups []ActionGroup
+		if folder.StartActionGroup != nil {
+			groups = append(groups, *folder.StartActionGroup)
+		}
+		for _, placemark := range folder.Placemarks {
+			groups = append(groups, placemark.ActionGroups...)
+		}
+		for _, group := range groups {
+			for _, action := range group.Actions {
+				if err := checkMegaphoneResource(mission.Resources, action); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func checkMegaphoneResource(resources map[string][]byte, action Action) error {
+	param := action.ActionActuatorFuncParam
+	if action.ActionActuatorFunc != ActionTypeMegaphone || param == nil ||
+		param.MegaphoneOperateType == nil || *param.MegaphoneOperateType != "playAudio" ||
+		param.MegaphoneFileName == nil {
+		return nil
+	}
+	name := *param.MegaphoneFileName
+	data, ok := resources[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrMegaphoneFileMissing, name)
+	}
+	sum := md5.Sum(data)
+	if param.MegaphoneFileMD5 != nil && !strings.EqualFold(*param.MegaphoneFileMD5, hex.EncodeToString(sum[:])) {
+		return fmt.Errorf("%w: %s", ErrMegaphoneFileMD5Mismatch, name)
+	}
+	return nil
+}
+
 func readZipFile(file *zip.File) ([]byte, error) {
 	reader, err := file.Open()
 	if err != nil {
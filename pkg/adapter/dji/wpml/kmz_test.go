@@ -1,6 +1,8 @@
 package wpml
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"testing"
@@ -318,3 +320,43 @@ func TestKmzWithComplexWaylines(t *testing.T) {
 	firstPlacemark := waylineFolder.Placemarks[0]
 	assert.NotNil(t, firstPlacemark)
 }
+
+func TestCreateKmzBuffer_MegaphoneAudio(t *testing.T) {
+	audio := []byte("RIFF audio")
+	sum := md5.Sum(audio)
+	waylines := createValidWaylines("Megaphone Mission")
+	waylines.Waypoints[0].Actions = []ActionRequest{{
+		Type: ActionTypeMegaphone,
+		Action: &MegaphoneAction{
+			PayloadPositionIndex: PayloadPosition2,
+			MegaphoneOperateType: "playAudio",
+			MegaphoneFileName:    "warning.wav",
+			MegaphoneFileMD5:     hex.EncodeToString(sum[:]),
+		},
+	}}
+	mission, err := ConvertWaylinesToMission(waylines)
+	require.NoError(t, err)
+
+	_, err = CreateKmzBuffer(mission)
+	require.ErrorIs(t, err, ErrMegaphoneFileMissing)
+
+	mission.AddResource("warning.wav", []byte("other audio"))
+	_, err = CreateKmzBuffer(mission)
+	require.ErrorIs(t, err, ErrMegaphoneFileMD5Mismatch)
+
+	mission.AddResource("warning.wav", audio)
+	buffer, err := CreateKmzBuffer(mission)
+	require.NoError(t, err)
+
+	info, err := GetKmzInfo(mission)
+	require.NoError(t, err)
+	names := make([]string, 0, len(info.Files))
+	for _, file := range info.Files {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{"wpmz/template.kml", "wpmz/waylines.wpml", "wpmz/res/warning.wav"}, names)
+
+	parsed, err := ParseKMZBuffer(buffer.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"warning.wav": audio}, parsed.Resources)
+}
@@ -0,0 +1,80 @@
+package wpml
+
+import (
+	"fmt"
+	"math"
+)
+
+// Mapping2DParams holds the settings of a 2D mapping (area survey) mission.
+type Mapping2DParams struct {
+	SurveyParams
+	// Area is the survey polygon
+	Area []Coordinate `json:"area" validate:"required,min=3,dive"`
+	// Direction is the course of the survey lines in degrees clockwise from north
+	Direction int `json:"direction" validate:"min=0,max=359"`
+	// Margin extends the survey area by this many meters, or shrinks it when negative
+	Margin int `json:"margin,omitempty"`
+	// EdgeOptimization ends survey lines at the area boundary instead of
+	// extending them until the photos cover the boundary, saving flight time
+	EdgeOptimization bool `json:"edge_optimization,omitempty"`
+}
+
+// Validate validates the mapping parameters using the WPML validator.
+func (p *Mapping2DParams) Validate() error {
+	validator, err := NewValidator()
+	if err != nil {
+		return err
+	}
+	return validator.ValidateStruct(p)
+}
+
+// PlanMapping2D plans a 2D mapping mission covering an area with parallel
+// survey lines flown back and forth, taking nadir photos at the spacing the
+// front overlap requires. The template carries the area and overlaps so DJI
+// Pilot can re-plan it; the waylines are ready to fly.
+func PlanMapping2D(params *Mapping2DParams) (*SurveyPlan, error) {
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf(ErrSurveyValidationFailed, err)
+	}
+	settings, err := params.settings()
+	if err != nil {
+		return nil, err
+	}
+
+	frame, area, err := surveyArea(params.Area, params.Margin)
+	if err != nil {
+		return nil, err
+	}
+
+	extension := settings.footprintHeight / 2
+	if params.EdgeOptimization {
+		extension = 0
+	}
+
+	lines := surveyLines(area, float64(params.Direction), settings.lineSpacing, extension)
+	passes := make([]surveyPass, len(lines))
+	for i, line := range lines {
+		passes[i] = settings.pass([]Coordinate{frame.toCoordinate(line[0]), frame.toCoordinate(line[1])}, GimbalPitchNadir, nil)
+	}
+
+	template := params.surveyTemplateFolder(TemplateTypeMapping2D, settings)
+	template.Polygon = NewPolygon(params.Area)
+	template.Direction = intPtr(params.Direction)
+	template.Margin = intPtr(params.Margin)
+	template.BoundaryOptimEnable = boolToIntPtr(params.EdgeOptimization)
+	template.GimbalPitchAngle = float64Ptr(GimbalPitchNadir)
+
+	return params.buildSurveyMission(template, passes, settings)
+}
+
+// surveyArea projects a survey area into a local frame and applies the margin.
+func surveyArea(coords []Coordinate, margin int) (localFrame, []point, error) {
+	frame := newLocalFrame(coords)
+	outline := frame.toPoints(coords)
+	area := offsetPolygon(outline, float64(margin))
+	// A negative margin wider than the area turns the polygon inside out
+	if math.Abs(signedArea(area)) < 1 || math.Signbit(signedArea(area)) != math.Signbit(signedArea(outline)) {
+		return frame, nil, ErrSurveyAreaTooSmall
+	}
+	return frame, area, nil
+}
@@ -0,0 +1,178 @@
+package wpml
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMapping2DParams() *Mapping2DParams {
+	return &Mapping2DParams{
+		SurveyParams: SurveyParams{
+			DroneModel:   DroneM3Series,
+			PayloadModel: PayloadMavic3ECamera,
+			Altitude:     100,
+			FrontOverlap: 80,
+			SideOverlap:  70,
+		},
+		Area: rectangle(Coordinate{116.4074, 39.9042}, 200, 100),
+	}
+}
+
+func TestCamera(t *testing.T) {
+	camera, ok := CameraForPayload(PayloadMavic3ECamera)
+	require.True(t, ok)
+	assert.InDelta(t, 2.666, camera.GSD(100), 0.001)
+	assert.InDelta(t, 100, camera.AltitudeForGSD(camera.GSD(100)), 1e-9)
+
+	width, height := camera.Footprint(100)
+	assert.InDelta(t, 140.8, width, 0.1)
+	assert.InDelta(t, 105.8, height, 0.1)
+
+	_, ok = CameraForPayload(PayloadZ30)
+	assert.False(t, ok)
+}
+
+func TestPlanMapping2D(t *testing.T) {
+	plan, err := PlanMapping2D(testMapping2DParams())
+	require.NoError(t, err)
+
+	assert.InDelta(t, 2.666, plan.GSD, 0.001)
+	assert.InDelta(t, 140.8*0.3, plan.LineSpacing, 0.1)
+	assert.InDelta(t, 105.8*0.2, plan.PhotoSpacing, 0.1)
+	assert.Equal(t, maxSurveySpeed, plan.Speed)
+	// 200 m across north-running lines 42 m apart
+	assert.Equal(t, 5, plan.Lines)
+
+	template := plan.Mission.Template.Document.Folders[0]
+	assert.Equal(t, TemplateTypeMapping2D, template.TemplateType)
+	ring, err := template.Polygon.Ring()
+	require.NoError(t, err)
+	assert.Equal(t, testMapping2DParams().Area, ring)
+	assert.Equal(t, 80, *template.Overlap.OrthoCameraOverlapH)
+	assert.Equal(t, 70, *template.Overlap.OrthoCameraOverlapW)
+	assert.Equal(t, 0, *template.BoundaryOptimEnable)
+
+	wayline := plan.Mission.Waylines.Document.Folders[0]
+	require.Len(t, wayline.Placemarks, 10)
+	assert.InDelta(t, plan.Distance, *wayline.Distance, 1e-9)
+
+	// Lines extend half a photo beyond the area and alternate in direction
+	first, err := parseCoordinateList(wayline.Placemarks[0].Point.Coordinates + " " + wayline.Placemarks[1].Point.Coordinates)
+	require.NoError(t, err)
+	assert.InDelta(t, 100+105.8, Distance(first[0], first[1]), 0.5)
+	assert.Greater(t, first[1].Latitude, first[0].Latitude)
+	second, err := parseCoordinateList(wayline.Placemarks[2].Point.Coordinates + " " + wayline.Placemarks[3].Point.Coordinates)
+	require.NoError(t, err)
+	assert.Less(t, second[1].Latitude, second[0].Latitude)
+
+	// The first line points the camera down, then every line takes photos
+	groups := wayline.Placemarks[0].ActionGroups
+	require.Len(t, groups, 2)
+	assert.Equal(t, ActionTypeGimbalRotate, groups[0].Actions[0].ActionActuatorFunc)
+	assert.Equal(t, GimbalPitchNadir, *groups[0].Actions[0].ActionActuatorFuncParam.GimbalPitchRotateAngle)
+	assert.Equal(t, TriggerTypeMultipleDistance, groups[1].ActionTrigger.ActionTriggerType)
+	assert.InDelta(t, plan.PhotoSpacing, *groups[1].ActionTrigger.ActionTriggerParam, 0.05)
+	assert.Equal(t, 0, groups[1].ActionGroupStartIndex)
+	assert.Equal(t, 1, groups[1].ActionGroupEndIndex)
+	require.Len(t, wayline.Placemarks[2].ActionGroups, 1)
+	assert.Equal(t, ActionTypeTakePhoto, wayline.Placemarks[2].ActionGroups[0].Actions[0].ActionActuatorFunc)
+	assert.Equal(t, 5*(int(205.8/plan.PhotoSpacing)+1), plan.Photos)
+
+	t.Run("round trips through KMZ", func(t *testing.T) {
+		buffer, err := CreateKmzBuffer(plan.Mission)
+		require.NoError(t, err)
+		parsed, err := ParseKMZBuffer(buffer.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, TemplateTypeMapping2D, parsed.Template.Document.Folders[0].TemplateType)
+		assert.Len(t, parsed.Waylines.Document.Folders[0].Placemarks, 10)
+	})
+}
+
+func TestPlanMapping2D_Options(t *testing.T) {
+	t.Run("edge optimization", func(t *testing.T) {
+		params := testMapping2DParams()
+		params.EdgeOptimization = true
+		plan, err := PlanMapping2D(params)
+		require.NoError(t, err)
+
+		placemarks := plan.Mission.Waylines.Document.Folders[0].Placemarks
+		line, err := parseCoordinateList(placemarks[0].Point.Coordinates + " " + placemarks[1].Point.Coordinates)
+		require.NoError(t, err)
+		assert.InDelta(t, 100, Distance(line[0], line[1]), 0.5)
+		assert.Equal(t, 1, *plan.Mission.Template.Document.Folders[0].BoundaryOptimEnable)
+	})
+
+	t.Run("margin and direction", func(t *testing.T) {
+		params := testMapping2DParams()
+		params.Direction = 90
+		params.Margin = 20
+		params.EdgeOptimization = true
+		plan, err := PlanMapping2D(params)
+		require.NoError(t, err)
+
+		// 140 m across east-running lines
+		assert.Equal(t, 4, plan.Lines)
+		placemarks := plan.Mission.Waylines.Document.Folders[0].Placemarks
+		line, err := parseCoordinateList(placemarks[0].Point.Coordinates + " " + placemarks[1].Point.Coordinates)
+		require.NoError(t, err)
+		assert.InDelta(t, 240, Distance(line[0], line[1]), 0.5)
+		assert.Greater(t, line[1].Longitude, line[0].Longitude)
+	})
+
+	t.Run("gsd", func(t *testing.T) {
+		params := testMapping2DParams()
+		params.Altitude = 0
+		params.GSD = 2
+		params.Speed = 10
+		plan, err := PlanMapping2D(params)
+		require.NoError(t, err)
+		assert.InDelta(t, 75, plan.Altitude, 0.1)
+		assert.InDelta(t, 2, plan.GSD, 1e-9)
+		assert.Equal(t, 10.0, plan.Speed)
+		assert.Equal(t, 75, int(math.Round(*plan.Mission.Waylines.Document.Folders[0].Placemarks[0].ExecuteHeight)))
+	})
+
+	t.Run("custom camera", func(t *testing.T) {
+		params := testMapping2DParams()
+		params.PayloadModel = PayloadZ30
+		_, err := PlanMapping2D(params)
+		assert.Error(t, err)
+
+		params.Camera = &Camera{SensorWidth: 13.2, SensorHeight: 8.8, FocalLength: 8.8, ImageWidth: 5472, ImageHeight: 3648}
+		plan, err := PlanMapping2D(params)
+		require.NoError(t, err)
+		assert.Equal(t, maxSurveySpeed, plan.Speed)
+
+		// A slow camera limits the speed over closely spaced photos
+		params.Camera.MinShootInterval = 3
+		plan, err = PlanMapping2D(params)
+		require.NoError(t, err)
+		assert.InDelta(t, plan.PhotoSpacing/3, plan.Speed, 1e-9)
+	})
+}
+
+func TestPlanMapping2D_Errors(t *testing.T) {
+	params := testMapping2DParams()
+	params.Altitude = 0
+	_, err := PlanMapping2D(params)
+	assert.ErrorIs(t, err, ErrSurveyHeightRequired)
+
+	params = testMapping2DParams()
+	params.Altitude = 20
+	params.Speed = 15
+	_, err = PlanMapping2D(params)
+	assert.ErrorContains(t, err, "survey speed")
+
+	params = testMapping2DParams()
+	params.Area = params.Area[:2]
+	_, err = PlanMapping2D(params)
+	assert.ErrorContains(t, err, "survey validation failed")
+
+	params = testMapping2DParams()
+	params.Margin = -60
+	_, err = PlanMapping2D(params)
+	assert.ErrorIs(t, err, ErrSurveyAreaTooSmall)
+}
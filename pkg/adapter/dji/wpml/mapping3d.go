@@ -0,0 +1,135 @@
+package wpml
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultInclinedGimbalPitch is the gimbal pitch in degrees of oblique photos when none is set.
+const DefaultInclinedGimbalPitch = -45
+
+// obliqueDirections is the number of oblique capture directions of a 3D mapping mission.
+const obliqueDirections = 4
+
+// Mapping3DParams holds the settings of a 3D mapping (oblique photography) mission.
+type Mapping3DParams struct {
+	SurveyParams
+	// Area is the survey polygon
+	Area []Coordinate `json:"area" validate:"required,min=3,dive"`
+	// Direction is the course of the nadir survey lines in degrees clockwise from north
+	Direction int `json:"direction" validate:"min=0,max=359"`
+	// Margin extends the survey area by this many meters, or shrinks it when negative
+	Margin int `json:"margin,omitempty"`
+	// InclinedGimbalPitch is the gimbal pitch of oblique photos in degrees,
+	// DefaultInclinedGimbalPitch when zero
+	InclinedGimbalPitch int `json:"inclined_gimbal_pitch,omitempty" validate:"omitempty,min=-85,max=-40"`
+	// InclinedFrontOverlap and InclinedSideOverlap are the overlaps of oblique
+	// photos in percent, the nadir overlaps when zero
+	InclinedFrontOverlap int `json:"inclined_front_overlap,omitempty" validate:"omitempty,min=10,max=95"`
+	InclinedSideOverlap  int `json:"inclined_side_overlap,omitempty" validate:"omitempty,min=10,max=95"`
+	// InclinedSpeed is the oblique flight speed in meters per second; when
+	// zero the fastest speed the camera can keep up with is used
+	InclinedSpeed float64 `json:"inclined_speed,omitempty" validate:"omitempty,min=1,max=15"`
+}
+
+// Validate validates the mapping parameters using the WPML validator.
+func (p *Mapping3DParams) Validate() error {
+	validator, err := NewValidator()
+	if err != nil {
+		return err
+	}
+	return validator.ValidateStruct(p)
+}
+
+// PlanMapping3D plans a five-direction oblique mapping mission: a nadir
+// survey of the area as PlanMapping2D plans it, followed by four oblique
+// surveys with the camera tilted forward and the aircraft facing each way
+// in turn. Oblique lines are shifted back so the center of each photo falls
+// on the area.
+func PlanMapping3D(params *Mapping3DParams) (*SurveyPlan, error) {
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf(ErrSurveyValidationFailed, err)
+	}
+	settings, err := params.settings()
+	if err != nil {
+		return nil, err
+	}
+	pitch := params.inclinedGimbalPitch()
+	inclined, err := params.inclinedSettings(settings, pitch)
+	if err != nil {
+		return nil, err
+	}
+
+	frame, area, err := surveyArea(params.Area, params.Margin)
+	if err != nil {
+		return nil, err
+	}
+
+	var passes []surveyPass
+	direction := float64(params.Direction)
+	for _, line := range surveyLines(area, direction, settings.lineSpacing, settings.footprintHeight/2) {
+		passes = append(passes, settings.pass([]Coordinate{frame.toCoordinate(line[0]), frame.toCoordinate(line[1])}, GimbalPitchNadir, nil))
+	}
+
+	// The camera looks ahead of the aircraft by the horizontal distance to the photo center
+	lookAhead := settings.altitude / math.Tan(-float64(pitch)*math.Pi/180)
+	for i := 0; i < obliqueDirections; i++ {
+		heading := math.Mod(direction+float64(i*360/obliqueDirections), 360)
+		shift := newTrackFrame(heading).fromTrack(-lookAhead, 0)
+		for _, line := range surveyLines(area, heading, inclined.lineSpacing, inclined.footprintHeight/2) {
+			points := []Coordinate{frame.toCoordinate(line[0].add(shift)), frame.toCoordinate(line[1].add(shift))}
+			passes = append(passes, inclined.pass(points, float64(pitch), float64Ptr(heading)))
+		}
+	}
+
+	template := params.surveyTemplateFolder(TemplateTypeMapping3D, settings)
+	template.Polygon = NewPolygon(params.Area)
+	template.Direction = intPtr(params.Direction)
+	template.Margin = intPtr(params.Margin)
+	template.InclinedGimbalPitch = intPtr(pitch)
+	template.InclinedFlightSpeed = float64Ptr(inclined.speed)
+	template.Overlap.InclinedCameraOverlapH = intPtr(params.inclinedOverlap(params.InclinedFrontOverlap, params.FrontOverlap))
+	template.Overlap.InclinedCameraOverlapW = intPtr(params.inclinedOverlap(params.InclinedSideOverlap, params.SideOverlap))
+
+	return params.buildSurveyMission(template, passes, settings)
+}
+
+// inclinedGimbalPitch returns the gimbal pitch of oblique photos.
+func (p *Mapping3DParams) inclinedGimbalPitch() int {
+	if p.InclinedGimbalPitch == 0 {
+		return DefaultInclinedGimbalPitch
+	}
+	return p.InclinedGimbalPitch
+}
+
+// inclinedOverlap returns an oblique overlap, falling back to the nadir one.
+func (p *Mapping3DParams) inclinedOverlap(inclined, nadir int) int {
+	if inclined == 0 {
+		return nadir
+	}
+	return inclined
+}
+
+// inclinedSettings derives the footprint, spacings and speed of oblique
+// photos taken at the given gimbal pitch from the nadir settings. The
+// footprint is measured at the photo center, where the camera is the slant
+// range away and the ground is stretched along the viewing direction.
+func (p *Mapping3DParams) inclinedSettings(nadir *surveySettings, pitch int) (*surveySettings, error) {
+	elevation := -float64(pitch) * math.Pi / 180
+	width, height := nadir.camera.Footprint(nadir.altitude / math.Sin(elevation))
+	height /= math.Sin(elevation)
+
+	s := &surveySettings{
+		camera:          nadir.camera,
+		altitude:        nadir.altitude,
+		gsd:             nadir.camera.GSD(nadir.altitude / math.Sin(elevation)),
+		footprintWidth:  width,
+		footprintHeight: height,
+		lineSpacing:     width * float64(100-p.inclinedOverlap(p.InclinedSideOverlap, p.SideOverlap)) / 100,
+		photoSpacing:    height * float64(100-p.inclinedOverlap(p.InclinedFrontOverlap, p.FrontOverlap)) / 100,
+	}
+	if err := s.setSpeed(p.InclinedSpeed); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
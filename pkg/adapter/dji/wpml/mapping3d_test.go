@@ -0,0 +1,95 @@
+package wpml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanMapping3D(t *testing.T) {
+	area := rectangle(Coordinate{116.4074, 39.9042}, 200, 100)
+	plan, err := PlanMapping3D(&Mapping3DParams{
+		SurveyParams: SurveyParams{
+			DroneModel:   DroneM3Series,
+			PayloadModel: PayloadMavic3ECamera,
+			Altitude:     100,
+			FrontOverlap: 80,
+			SideOverlap:  70,
+		},
+		Area: area,
+	})
+	require.NoError(t, err)
+
+	// 5 nadir lines, then oblique lines 60 m apart 4 times across 200 m
+	// heading north and south and twice across 100 m heading east and west
+	assert.Equal(t, 5+4+2+4+2, plan.Lines)
+
+	template := plan.Mission.Template.Document.Folders[0]
+	assert.Equal(t, TemplateTypeMapping3D, template.TemplateType)
+	assert.Equal(t, DefaultInclinedGimbalPitch, *template.InclinedGimbalPitch)
+	assert.Equal(t, 80, *template.Overlap.InclinedCameraOverlapH)
+	assert.Equal(t, 70, *template.Overlap.InclinedCameraOverlapW)
+	assert.Equal(t, maxSurveySpeed, *template.InclinedFlightSpeed)
+
+	placemarks := plan.Mission.Waylines.Document.Folders[0].Placemarks
+	require.Len(t, placemarks, 2*plan.Lines)
+
+	// Nadir lines follow the wayline with the camera pointing down
+	assert.Equal(t, HeadingModeFollowWayline, placemarks[0].WaypointHeadingParam.WaypointHeadingMode)
+	assert.Equal(t, GimbalPitchNadir, *placemarks[0].ActionGroups[0].Actions[0].ActionActuatorFuncParam.GimbalPitchRotateAngle)
+
+	// The first oblique line tilts the camera and faces north, flying 100 m
+	// south of the area center to look at it
+	oblique := placemarks[10]
+	require.Len(t, oblique.ActionGroups, 2)
+	assert.Equal(t, -45.0, *oblique.ActionGroups[0].Actions[0].ActionActuatorFuncParam.GimbalPitchRotateAngle)
+	assert.Equal(t, HeadingModeSmoothTransition, oblique.WaypointHeadingParam.WaypointHeadingMode)
+	assert.Equal(t, 0.0, *oblique.WaypointHeadingParam.WaypointHeadingAngle)
+	frame := newLocalFrame(area)
+	line, err := parseCoordinateList(oblique.Point.Coordinates + " " + placemarks[11].Point.Coordinates)
+	require.NoError(t, err)
+	start, end := frame.toPoint(line[0]), frame.toPoint(line[1])
+	assert.InDelta(t, -100, (start.y+end.y)/2, 0.5)
+	assert.Greater(t, end.y, start.y)
+
+	// Later oblique lines keep the pitch and turn to face east, south and west
+	assert.Len(t, placemarks[18].ActionGroups, 1)
+	assert.Equal(t, 90.0, *placemarks[18].WaypointHeadingParam.WaypointHeadingAngle)
+	assert.Equal(t, -180.0, *placemarks[22].WaypointHeadingParam.WaypointHeadingAngle)
+	assert.Equal(t, -90.0, *placemarks[30].WaypointHeadingParam.WaypointHeadingAngle)
+
+	buffer, err := CreateKmzBuffer(plan.Mission)
+	require.NoError(t, err)
+	parsed, err := ParseKMZBuffer(buffer.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, -45, *parsed.Template.Document.Folders[0].InclinedGimbalPitch)
+}
+
+func TestPlanMapping3D_InclinedSettings(t *testing.T) {
+	params := &Mapping3DParams{
+		SurveyParams: SurveyParams{
+			DroneModel:   DroneM3Series,
+			PayloadModel: PayloadMavic3ECamera,
+			Altitude:     100,
+			FrontOverlap: 80,
+			SideOverlap:  70,
+		},
+		Area:                 rectangle(Coordinate{116.4074, 39.9042}, 200, 100),
+		InclinedGimbalPitch:  -60,
+		InclinedFrontOverlap: 70,
+		InclinedSideOverlap:  60,
+		InclinedSpeed:        8,
+	}
+	plan, err := PlanMapping3D(params)
+	require.NoError(t, err)
+	template := plan.Mission.Template.Document.Folders[0]
+	assert.Equal(t, -60, *template.InclinedGimbalPitch)
+	assert.Equal(t, 70, *template.Overlap.InclinedCameraOverlapH)
+	assert.Equal(t, 60, *template.Overlap.InclinedCameraOverlapW)
+	assert.Equal(t, 8.0, *plan.Mission.Waylines.Document.Folders[0].Placemarks[10].WaypointSpeed)
+
+	params.InclinedGimbalPitch = -30
+	_, err = PlanMapping3D(params)
+	assert.ErrorContains(t, err, "survey validation failed")
+}
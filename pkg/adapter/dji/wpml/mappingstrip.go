@@ -0,0 +1,80 @@
+package wpml
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// MappingStripParams holds the settings of a strip (corridor) mapping mission.
+type MappingStripParams struct {
+	SurveyParams
+	// Path is the center line of the corridor
+	Path []Coordinate `json:"path" validate:"required,min=2,dive"`
+	// LeftExtend and RightExtend are the corridor widths in meters left and
+	// right of the path, looking along it
+	LeftExtend  int `json:"left_extend" validate:"min=0,max=1000"`
+	RightExtend int `json:"right_extend" validate:"min=0,max=1000"`
+	// SingleLine flies the center of the corridor once instead of covering
+	// its width with parallel lines
+	SingleLine bool `json:"single_line,omitempty"`
+}
+
+// Validate validates the mapping parameters using the WPML validator.
+func (p *MappingStripParams) Validate() error {
+	validator, err := NewValidator()
+	if err != nil {
+		return err
+	}
+	return validator.ValidateStruct(p)
+}
+
+// PlanMappingStrip plans a strip mapping mission along a corridor, such as a
+// pipeline or road, with lines parallel to its path flown back and forth and
+// extended beyond both ends until the photos cover them.
+func PlanMappingStrip(params *MappingStripParams) (*SurveyPlan, error) {
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf(ErrSurveyValidationFailed, err)
+	}
+	settings, err := params.settings()
+	if err != nil {
+		return nil, err
+	}
+
+	frame := newLocalFrame(params.Path)
+	path := frame.toPoints(params.Path)
+	width := float64(params.LeftExtend + params.RightExtend)
+	if width == 0 || lineLength(path) < 1 {
+		return nil, ErrSurveyAreaTooSmall
+	}
+
+	// Lines are centered on the corridor so both sides get equal coverage
+	count := 1
+	if !params.SingleLine {
+		count = max(1, int(math.Ceil(width/settings.lineSpacing)))
+	}
+	first := -float64(params.LeftExtend) + (width-float64(count-1)*settings.lineSpacing)/2
+
+	passes := make([]surveyPass, count)
+	for i := range passes {
+		line := extendLine(offsetLine(path, first+float64(i)*settings.lineSpacing), settings.footprintHeight/2)
+		points := make([]Coordinate, len(line))
+		for j, p := range line {
+			points[j] = frame.toCoordinate(p)
+		}
+		if i%2 == 1 {
+			slices.Reverse(points)
+		}
+		passes[i] = settings.pass(points, GimbalPitchNadir, nil)
+	}
+
+	template := params.surveyTemplateFolder(TemplateTypeMappingStrip, settings)
+	template.LineString = NewLineString(params.Path)
+	template.LeftExtend = intPtr(params.LeftExtend)
+	template.RightExtend = intPtr(params.RightExtend)
+	template.SingleLineEnable = boolToIntPtr(params.SingleLine)
+	template.StripUseTemplateAltitude = intPtr(1)
+	template.GimbalPitchAngle = float64Ptr(GimbalPitchNadir)
+
+	return params.buildSurveyMission(template, passes, settings)
+}
@@ -0,0 +1,103 @@
+package wpml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMappingStripParams() *MappingStripParams {
+	center := Coordinate{116.4074, 39.9042}
+	corners := rectangle(center, 300, 0)
+	return &MappingStripParams{
+		SurveyParams: SurveyParams{
+			DroneModel:   DroneM3Series,
+			PayloadModel: PayloadMavic3ECamera,
+			Altitude:     100,
+			FrontOverlap: 80,
+			SideOverlap:  70,
+		},
+		// 300 m due east
+		Path:        corners[:2],
+		LeftExtend:  30,
+		RightExtend: 30,
+	}
+}
+
+// stripLine returns the local points of the pass starting at placemark index.
+func stripLine(t *testing.T, plan *SurveyPlan, frame localFrame, index int) (start, end point) {
+	placemarks := plan.Mission.Waylines.Document.Folders[0].Placemarks
+	line, err := parseCoordinateList(placemarks[index].Point.Coordinates + " " + placemarks[index+1].Point.Coordinates)
+	require.NoError(t, err)
+	return frame.toPoint(line[0]), frame.toPoint(line[1])
+}
+
+func TestPlanMappingStrip(t *testing.T) {
+	params := testMappingStripParams()
+	plan, err := PlanMappingStrip(params)
+	require.NoError(t, err)
+	frame := newLocalFrame(params.Path)
+
+	// 60 m wide corridor covered by lines 42 m apart
+	assert.Equal(t, 2, plan.Lines)
+	require.Len(t, plan.Mission.Waylines.Document.Folders[0].Placemarks, 4)
+
+	// The first line runs east left of the path, extended half a photo at both ends
+	start, end := stripLine(t, plan, frame, 0)
+	assert.InDelta(t, 21.1, start.y, 0.1)
+	assert.InDelta(t, -150-52.9, start.x, 0.1)
+	assert.InDelta(t, 150+52.9, end.x, 0.1)
+
+	// The second runs back west right of it
+	start, end = stripLine(t, plan, frame, 2)
+	assert.InDelta(t, -21.1, start.y, 0.1)
+	assert.Greater(t, start.x, end.x)
+
+	template := plan.Mission.Template.Document.Folders[0]
+	assert.Equal(t, TemplateTypeMappingStrip, template.TemplateType)
+	path, err := template.LineString.Points()
+	require.NoError(t, err)
+	assert.Equal(t, params.Path, path)
+	assert.Equal(t, 30, *template.LeftExtend)
+	assert.Equal(t, 30, *template.RightExtend)
+	assert.Equal(t, 0, *template.SingleLineEnable)
+
+	buffer, err := CreateKmzBuffer(plan.Mission)
+	require.NoError(t, err)
+	parsed, err := ParseKMZBuffer(buffer.Bytes())
+	require.NoError(t, err)
+	assert.NotNil(t, parsed.Template.Document.Folders[0].LineString)
+}
+
+func TestPlanMappingStrip_SingleLine(t *testing.T) {
+	params := testMappingStripParams()
+	params.SingleLine = true
+	params.LeftExtend = 0
+	params.RightExtend = 40
+	plan, err := PlanMappingStrip(params)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, plan.Lines)
+	start, _ := stripLine(t, plan, newLocalFrame(params.Path), 0)
+	assert.InDelta(t, -20, start.y, 0.1, "the line runs along the corridor center")
+	assert.Equal(t, 1, *plan.Mission.Template.Document.Folders[0].SingleLineEnable)
+}
+
+func TestPlanMappingStrip_Errors(t *testing.T) {
+	params := testMappingStripParams()
+	params.LeftExtend = 0
+	params.RightExtend = 0
+	_, err := PlanMappingStrip(params)
+	assert.ErrorIs(t, err, ErrSurveyAreaTooSmall)
+
+	params = testMappingStripParams()
+	params.Path = []Coordinate{params.Path[0], params.Path[0]}
+	_, err = PlanMappingStrip(params)
+	assert.ErrorIs(t, err, ErrSurveyAreaTooSmall)
+
+	params = testMappingStripParams()
+	params.Path = params.Path[:1]
+	_, err = PlanMappingStrip(params)
+	assert.ErrorContains(t, err, "survey validation failed")
+}
@@ -130,6 +130,14 @@ type ActionActuatorFuncParam struct {
 	CameraFocusType           *string            `xml:"wpml:cameraFocusType,omitempty" json:"camera_focus_type,omitempty"`
 	SmartObliqueCycleMode     *string            `xml:"wpml:smartObliqueCycleMode,omitempty" json:"smart_oblique_cycle_mode,omitempty"`
 	SmartObliquePoint         *SmartObliquePoint `xml:"wpml:smartObliquePoint,omitempty" json:"smart_oblique_point,omitempty"`
+	MegaphoneOperateType      *string            `xml:"wpml:megaphoneOperateType,omitempty" json:"megaphone_operate_type,omitempty"`
+	MegaphoneFileName         *string            `xml:"wpml:megaphoneFileName,omitempty" json:"megaphone_file_name,omitempty"`
+	MegaphoneFileMD5          *string            `xml:"wpml:megaphoneFileMD5,omitempty" json:"megaphone_file_md5,omitempty"`
+	MegaphoneText             *string            `xml:"wpml:megaphoneText,omitempty" json:"megaphone_text,omitempty"`
+	MegaphoneVolume           *int               `xml:"wpml:megaphoneVolume,omitempty" json:"megaphone_volume,omitempty"`
+	MegaphonePlayMode         *int               `xml:"wpml:megaphonePlayMode,omitempty" json:"megaphone_play_mode,omitempty"`
+	SearchlightOperateType    *string            `xml:"wpml:searchlightOperateType,omitempty" json:"searchlight_operate_type,omitempty"`
+	SearchlightBrightness     *int               `xml:"wpml:searchlightBrightness,omitempty" json:"searchlight_brightness,omitempty"`
 }
 
 // GetActionType returns the action type identifier for ActionActuatorFuncParam.
@@ -190,6 +198,14 @@ const (
 	ActionTypeSetFocusType = "setFocusType"
 	// ActionTypeTargetDetection is the action type for target detection.
 	ActionTypeTargetDetection = "targetDetection"
+	// ActionTypeStartContinuousShooting is the action type for starting continuous shooting.
+	ActionTypeStartContinuousShooting = "startContinuousShooting"
+	// ActionTypeStopContinuousShooting is the action type for stopping continuous shooting.
+	ActionTypeStopContinuousShooting = "stopContinuousShooting"
+	// ActionTypeMegaphone is the action type for playing audio or text on a speaker.
+	ActionTypeMegaphone = "megaphone"
+	// ActionTypeSearchlight is the action type for switching a searchlight.
+	ActionTypeSearchlight = "searchlight"
 )
 
 // Trigger type string constants for determining when action groups execute.
@@ -0,0 +1,259 @@
+package wpml
+
+import (
+	"fmt"
+	"math"
+)
+
+// RouteFormat identifies a route file format waylines are imported from or exported to.
+type RouteFormat string
+
+// Route file formats.
+const (
+	// RouteFormatKML is a plain KML document, as drawn in Google Earth
+	RouteFormatKML RouteFormat = "kml"
+	// RouteFormatGeoJSON is a GeoJSON feature collection, as used by QGIS
+	RouteFormatGeoJSON RouteFormat = "geojson"
+	// RouteFormatGPX is a GPX 1.1 document
+	RouteFormatGPX RouteFormat = "gpx"
+	// RouteFormatLitchiCSV is a Litchi mission hub CSV export, which is import only
+	RouteFormatLitchiCSV RouteFormat = "litchi"
+)
+
+// Import defaults.
+const (
+	// DefaultImportHeight is the waypoint height in meters used when a route has no heights
+	DefaultImportHeight = 100.0
+	// DefaultImportSpeed is the flight speed in meters per second used when a route has no speeds
+	DefaultImportSpeed = 10.0
+	// defaultImportName names imported waylines when neither the file nor the caller does
+	defaultImportName = "Imported route"
+)
+
+// ImportOptions holds the settings of waylines imported from a route file
+// that the file does not carry.
+type ImportOptions struct {
+	// Name overrides the route name read from the file
+	Name         string       `json:"name,omitempty"`
+	DroneModel   DroneModel   `json:"drone_model"`
+	PayloadModel PayloadModel `json:"payload_model"`
+	// Height is the waypoint height of routes without heights, DefaultImportHeight when zero
+	Height float64 `json:"height,omitempty"`
+	// Speed is the flight speed of waypoints without a speed, DefaultImportSpeed when zero
+	Speed float64 `json:"speed,omitempty"`
+	// HeightType overrides the height mode read from the file
+	HeightType HeightMode `json:"height_type,omitempty"`
+	// TakePhoto takes a photo at every waypoint without actions of its own
+	TakePhoto bool `json:"take_photo,omitempty"`
+}
+
+// routePoint is a waypoint read from a route file.
+type routePoint struct {
+	position Coordinate
+	// height is nil when the file gives none
+	height *float64
+	// speed is zero when the file gives none
+	speed        float64
+	actions      []ActionRequest
+	triggerType  string
+	triggerParam float64
+}
+
+// importedRoute is a route read from a file, before the import options apply.
+type importedRoute struct {
+	name       string
+	heightType HeightMode
+	// wgs84 marks heights above the WGS84 ellipsoid rather than heightType
+	wgs84  bool
+	points []routePoint
+}
+
+// ImportRoute reads a route file of the given format into waylines ready to convert.
+func ImportRoute(format RouteFormat, data []byte, opts *ImportOptions) (*Waylines, error) {
+	switch format {
+	case RouteFormatKML:
+		return ImportKML(data, opts)
+	case RouteFormatGeoJSON:
+		return ImportGeoJSON(data, opts)
+	case RouteFormatGPX:
+		return ImportGPX(data, opts)
+	case RouteFormatLitchiCSV:
+		return ImportLitchiCSV(data, opts)
+	default:
+		return nil, fmt.Errorf(ErrUnsupportedRouteFormat, format)
+	}
+}
+
+// ExportRoute writes the waylines of a mission in the given format for review in GIS tools.
+func ExportRoute(format RouteFormat, mission *Mission) ([]byte, error) {
+	switch format {
+	case RouteFormatKML:
+		return ExportKML(mission)
+	case RouteFormatGeoJSON:
+		return ExportGeoJSON(mission)
+	case RouteFormatGPX:
+		return ExportGPX(mission)
+	default:
+		return nil, fmt.Errorf(ErrUnsupportedRouteFormat, format)
+	}
+}
+
+// waylines builds waypoint waylines from an imported route. Heights are
+// used only when every point has one; otherwise every waypoint flies at the
+// default height above the take-off point. Waypoints stop at each point and
+// follow the wayline heading.
+func (r *importedRoute) waylines(opts *ImportOptions) (*Waylines, error) {
+	if len(r.points) == 0 {
+		return nil, ErrRouteEmpty
+	}
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+	height := opts.Height
+	if height <= 0 {
+		height = DefaultImportHeight
+	}
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = DefaultImportSpeed
+	}
+
+	name := r.name
+	if opts.Name != "" {
+		name = opts.Name
+	}
+	if name == "" {
+		name = defaultImportName
+	}
+
+	heightType, useHeights := r.heightType, true
+	for _, p := range r.points {
+		useHeights = useHeights && p.height != nil
+	}
+	if !useHeights {
+		heightType = HeightModeRelativeToStartPoint
+	}
+	if opts.HeightType != "" {
+		heightType = opts.HeightType
+	}
+
+	waylines := &Waylines{
+		Name:         name,
+		DroneModel:   opts.DroneModel,
+		PayloadModel: opts.PayloadModel,
+		TemplateType: TemplateTypeWaypoint,
+		GlobalHeight: height,
+		GlobalSpeed:  speed,
+		HeightType:   heightType,
+		Waypoints:    make([]WaylinesWaypoint, len(r.points)),
+	}
+	for i, p := range r.points {
+		waylines.Waypoints[i] = p.waypoint(opts, height, speed, useHeights)
+		if useHeights && r.wgs84 && heightType == HeightModeEGM96 {
+			// Without a geoid at hand the ellipsoid height stands in for the EGM96 height
+			waylines.Waypoints[i].EllipsoidHeight = float64Ptr(*p.height)
+		}
+	}
+	return waylines, nil
+}
+
+// waypoint creates the waypoint of a route point.
+func (p *routePoint) waypoint(opts *ImportOptions, height, speed float64, useHeights bool) WaylinesWaypoint {
+	if useHeights {
+		height = *p.height
+	}
+	if p.speed > 0 {
+		speed = math.Min(math.Max(p.speed, 1), maxSurveySpeed)
+	}
+	triggerType := p.triggerType
+	if triggerType == "" {
+		triggerType = TriggerTypeReachPoint
+	}
+	actions := p.actions
+	if len(actions) == 0 && opts.TakePhoto {
+		actions = []ActionRequest{{
+			Type:   ActionTypeTakePhoto,
+			Action: &TakePhotoAction{PayloadPositionIndex: PayloadPosition0, UseGlobalPayloadLensIndex: true},
+		}}
+	}
+	return WaylinesWaypoint{
+		Latitude:     p.position.Latitude,
+		Longitude:    p.position.Longitude,
+		Height:       height,
+		Speed:        speed,
+		TriggerType:  triggerType,
+		TriggerParam: p.triggerParam,
+		Actions:      actions,
+	}
+}
+
+// exportedRoute is a wayline of a mission as written to route files.
+type exportedRoute struct {
+	waylineID  int
+	heightMode ExecuteHeightMode
+	distance   *float64
+	duration   *float64
+	waypoints  []exportedWaypoint
+}
+
+// exportedWaypoint is a waypoint of an exported route.
+type exportedWaypoint struct {
+	position Coordinate
+	height   float64
+	speed    float64
+	actions  []string
+}
+
+// exportedRoutes reads the waylines of a mission for export.
+func exportedRoutes(mission *Mission) ([]exportedRoute, error) {
+	if mission == nil {
+		return nil, ErrMissionCannotBeEmpty
+	}
+	if mission.Waylines == nil {
+		return nil, ErrMissionWaylinesCannotBeEmpty
+	}
+	folders := mission.Waylines.Document.Folders
+	routes := make([]exportedRoute, len(folders))
+	for i := range folders {
+		folder := &folders[i]
+		waypoints, err := estimateWaypoints(folder, 0, 0)
+		if err != nil {
+			return nil, fmt.Errorf(ErrExportWayline, folder.WaylineID, err)
+		}
+		route := exportedRoute{
+			waylineID:  folder.WaylineID,
+			heightMode: folder.ExecuteHeightMode,
+			distance:   folder.Distance,
+			duration:   folder.Duration,
+			waypoints:  make([]exportedWaypoint, len(waypoints)),
+		}
+		for j, w := range waypoints {
+			route.waypoints[j] = exportedWaypoint{position: w.position, height: w.height, speed: w.speed}
+			for _, group := range folder.Placemarks[j].ActionGroups {
+				for _, action := range group.Actions {
+					route.waypoints[j].actions = append(route.waypoints[j].actions, action.ActionActuatorFunc)
+				}
+			}
+		}
+		routes[i] = route
+	}
+	return routes, nil
+}
+
+// parseRouteHeightMode parses a height mode named in a route file, which
+// may be any HeightMode or WGS84 for ellipsoid heights.
+func parseRouteHeightMode(name string) (mode HeightMode, wgs84, ok bool) {
+	switch HeightMode(name) {
+	case HeightModeEGM96, HeightModeRelativeToStartPoint, HeightModeAboveGroundLevel, HeightModeRealTimeFollowSurface:
+		return HeightMode(name), false, true
+	}
+	if name == string(ExecuteHeightModeWGS84) {
+		return HeightModeEGM96, true, true
+	}
+	return "", false, false
+}
+
+// waylineName names an exported wayline.
+func waylineName(id int) string {
+	return fmt.Sprintf("Wayline %d", id)
+}
@@ -0,0 +1,243 @@
+package wpml
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GeoJSON object types.
+const (
+	geoJSONTypeFeatureCollection = "FeatureCollection"
+	geoJSONTypeFeature           = "Feature"
+	geoJSONTypePoint             = "Point"
+	geoJSONTypeMultiPoint        = "MultiPoint"
+	geoJSONTypeLineString        = "LineString"
+	geoJSONTypeMultiLineString   = "MultiLineString"
+	geoJSONTypePolygon           = "Polygon"
+)
+
+// geoJSONDocument is a GeoJSON feature collection, feature or geometry.
+type geoJSONDocument struct {
+	Type       string           `json:"type"`
+	Name       string           `json:"name,omitempty"`
+	Features   []geoJSONFeature `json:"features,omitempty"`
+	Geometry   *geoJSONGeometry `json:"geometry,omitempty"`
+	Properties map[string]any   `json:"properties,omitempty"`
+	geoJSONGeometry
+}
+
+// geoJSONFeature is a GeoJSON feature.
+type geoJSONFeature struct {
+	Type       string           `json:"type"`
+	Geometry   *geoJSONGeometry `json:"geometry"`
+	Properties map[string]any   `json:"properties"`
+}
+
+// geoJSONGeometry is a GeoJSON geometry, whose coordinates depend on its type.
+type geoJSONGeometry struct {
+	Type        string          `json:"type,omitempty"`
+	Coordinates json.RawMessage `json:"coordinates,omitempty"`
+}
+
+// ImportGeoJSON reads a route from a GeoJSON feature collection, feature or
+// geometry, such as a QGIS layer export. The first line is the route;
+// without one, the points are. Points exported as waypoints by
+// ExportGeoJSON take precedence, keeping their speeds and height mode.
+// Other heights are taken relative to the take-off point.
+func ImportGeoJSON(data []byte, opts *ImportOptions) (*Waylines, error) {
+	var document geoJSONDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf(ErrParseRoute, RouteFormatGeoJSON, err)
+	}
+	features := document.Features
+	switch document.Type {
+	case geoJSONTypeFeatureCollection:
+	case geoJSONTypeFeature:
+		features = []geoJSONFeature{{Type: geoJSONTypeFeature, Geometry: document.Geometry, Properties: document.Properties}}
+	default:
+		// The document's type field is the geometry's
+		geometry := &geoJSONGeometry{Type: document.Type, Coordinates: document.Coordinates}
+		features = []geoJSONFeature{{Type: geoJSONTypeFeature, Geometry: geometry}}
+	}
+
+	route, err := geoJSONRoute(features)
+	if err != nil {
+		return nil, fmt.Errorf(ErrParseRoute, RouteFormatGeoJSON, err)
+	}
+	if route.name == "" {
+		route.name = document.Name
+	}
+	return route.waylines(opts)
+}
+
+// geoJSONRoute picks the route out of GeoJSON features.
+func geoJSONRoute(features []geoJSONFeature) (*importedRoute, error) {
+	var line, points, waypoints []routePoint
+	route := &importedRoute{heightType: HeightModeRelativeToStartPoint}
+	lineFound, secondWayline := false, false
+	for _, f := range features {
+		if f.Geometry == nil {
+			continue
+		}
+		positions, err := f.Geometry.positions()
+		if err != nil {
+			return nil, err
+		}
+		switch f.Geometry.Type {
+		case geoJSONTypeLineString, geoJSONTypeMultiLineString:
+			if !lineFound {
+				line, lineFound = positions, true
+				route.name, _ = f.Properties["name"].(string)
+				route.setHeightMode(f.Properties)
+			}
+		case geoJSONTypePoint, geoJSONTypeMultiPoint:
+			if speed, ok := f.Properties["speed"].(float64); ok {
+				for i := range positions {
+					positions[i].speed = speed
+				}
+			}
+			points = append(points, positions...)
+			// Exported waylines number their waypoints from 0; only the first is imported
+			index, ok := f.Properties["index"].(float64)
+			if ok && index == 0 && len(waypoints) > 0 {
+				secondWayline = true
+			}
+			if ok && !secondWayline {
+				if len(waypoints) == 0 {
+					route.setHeightMode(f.Properties)
+				}
+				waypoints = append(waypoints, positions...)
+			}
+		}
+	}
+
+	switch {
+	case len(waypoints) > 0:
+		route.points = waypoints
+	case lineFound:
+		route.points = line
+	default:
+		route.points = points
+	}
+	return route, nil
+}
+
+// setHeightMode takes the height mode of a route from exported properties.
+func (r *importedRoute) setHeightMode(properties map[string]any) {
+	name, _ := properties["height_mode"].(string)
+	if mode, wgs84, ok := parseRouteHeightMode(name); ok {
+		r.heightType, r.wgs84 = mode, wgs84
+	}
+}
+
+// positions returns the positions of a point or line geometry, the first
+// line of a multi-line.
+func (g *geoJSONGeometry) positions() ([]routePoint, error) {
+	var positions [][]float64
+	var err error
+	switch g.Type {
+	case geoJSONTypePoint:
+		var position []float64
+		err = json.Unmarshal(g.Coordinates, &position)
+		positions = [][]float64{position}
+	case geoJSONTypeMultiPoint, geoJSONTypeLineString:
+		err = json.Unmarshal(g.Coordinates, &positions)
+	case geoJSONTypeMultiLineString:
+		var lines [][][]float64
+		err = json.Unmarshal(g.Coordinates, &lines)
+		if len(lines) > 0 {
+			positions = lines[0]
+		}
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]routePoint, len(positions))
+	for i, position := range positions {
+		if len(position) < 2 {
+			return nil, fmt.Errorf(ErrInvalidCoordinates, fmt.Sprint(position))
+		}
+		points[i].position = Coordinate{Longitude: position[0], Latitude: position[1]}
+		if len(position) > 2 {
+			points[i].height = float64Ptr(position[2])
+		}
+	}
+	return points, nil
+}
+
+// ExportGeoJSON writes the waylines of a mission as a GeoJSON feature
+// collection holding the path of each wayline and its waypoints, with the
+// waypoint heights, speeds and actions as properties.
+func ExportGeoJSON(mission *Mission) ([]byte, error) {
+	routes, err := exportedRoutes(mission)
+	if err != nil {
+		return nil, err
+	}
+	document := geoJSONDocument{Type: geoJSONTypeFeatureCollection, Name: "Waylines", Features: []geoJSONFeature{}}
+	for _, route := range routes {
+		features, err := route.geoJSONFeatures()
+		if err != nil {
+			return nil, fmt.Errorf(ErrExportRoute, RouteFormatGeoJSON, err)
+		}
+		document.Features = append(document.Features, features...)
+	}
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf(ErrExportRoute, RouteFormatGeoJSON, err)
+	}
+	return data, nil
+}
+
+// geoJSONFeatures creates the path and waypoint features of an exported route.
+func (r *exportedRoute) geoJSONFeatures() ([]geoJSONFeature, error) {
+	positions := make([][3]float64, len(r.waypoints))
+	features := make([]geoJSONFeature, 0, len(r.waypoints)+1)
+	for i, w := range r.waypoints {
+		positions[i] = [3]float64{w.position.Longitude, w.position.Latitude, w.height}
+		coordinates, err := json.Marshal(positions[i])
+		if err != nil {
+			return nil, err
+		}
+		actions := w.actions
+		if actions == nil {
+			actions = []string{}
+		}
+		features = append(features, geoJSONFeature{
+			Type:     geoJSONTypeFeature,
+			Geometry: &geoJSONGeometry{Type: geoJSONTypePoint, Coordinates: coordinates},
+			Properties: map[string]any{
+				"wayline_id":  r.waylineID,
+				"index":       i,
+				"height":      w.height,
+				"height_mode": r.heightMode,
+				"speed":       w.speed,
+				"actions":     actions,
+			},
+		})
+	}
+
+	coordinates, err := json.Marshal(positions)
+	if err != nil {
+		return nil, err
+	}
+	properties := map[string]any{
+		"name":        waylineName(r.waylineID),
+		"wayline_id":  r.waylineID,
+		"height_mode": r.heightMode,
+	}
+	if r.distance != nil {
+		properties["distance"] = *r.distance
+	}
+	if r.duration != nil {
+		properties["duration"] = *r.duration
+	}
+	path := geoJSONFeature{
+		Type:       geoJSONTypeFeature,
+		Geometry:   &geoJSONGeometry{Type: geoJSONTypeLineString, Coordinates: coordinates},
+		Properties: properties,
+	}
+	return append([]geoJSONFeature{path}, features...), nil
+}
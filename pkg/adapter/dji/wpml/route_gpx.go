@@ -0,0 +1,143 @@
+package wpml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// gpxNamespace is the namespace of GPX 1.1 documents.
+const gpxNamespace = "http://www.topografix.com/GPX/1/1"
+
+// gpxFile is a GPX 1.1 document.
+type gpxFile struct {
+	XMLName   xml.Name   `xml:"gpx"`
+	Namespace string     `xml:"xmlns,attr,omitempty"`
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Metadata  *gpxMeta   `xml:"metadata,omitempty"`
+	Waypoints []gpxPoint `xml:"wpt"`
+	Routes    []gpxRoute `xml:"rte"`
+	Tracks    []gpxTrack `xml:"trk"`
+}
+
+// gpxMeta holds the metadata of a GPX document.
+type gpxMeta struct {
+	Name string `xml:"name,omitempty"`
+}
+
+// gpxRoute is a GPX route. Its type names the height mode of exported waylines.
+type gpxRoute struct {
+	Name   string     `xml:"name,omitempty"`
+	Type   string     `xml:"type,omitempty"`
+	Points []gpxPoint `xml:"rtept"`
+}
+
+// gpxTrack is a GPX track.
+type gpxTrack struct {
+	Name     string            `xml:"name,omitempty"`
+	Type     string            `xml:"type,omitempty"`
+	Segments []gpxTrackSegment `xml:"trkseg"`
+}
+
+// gpxTrackSegment is a continuous part of a GPX track.
+type gpxTrackSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+// gpxPoint is a GPX waypoint, route point or track point.
+type gpxPoint struct {
+	Latitude    float64  `xml:"lat,attr"`
+	Longitude   float64  `xml:"lon,attr"`
+	Elevation   *float64 `xml:"ele,omitempty"`
+	Name        string   `xml:"name,omitempty"`
+	Description string   `xml:"desc,omitempty"`
+}
+
+// ImportGPX reads a route from a GPX document: its first route, else its
+// first track, else its waypoints. Elevations are heights above sea level
+// and imported as EGM96, unless the route type names a height mode as
+// ExportGPX writes it. GPX carries no speeds.
+func ImportGPX(data []byte, opts *ImportOptions) (*Waylines, error) {
+	var file gpxFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf(ErrParseRoute, RouteFormatGPX, err)
+	}
+
+	route := &importedRoute{heightType: HeightModeEGM96}
+	if file.Metadata != nil {
+		route.name = file.Metadata.Name
+	}
+	var points []gpxPoint
+	var routeType string
+	switch {
+	case len(file.Routes) > 0:
+		points, routeType = file.Routes[0].Points, file.Routes[0].Type
+		route.name = firstNonEmpty(file.Routes[0].Name, route.name)
+	case len(file.Tracks) > 0:
+		for _, segment := range file.Tracks[0].Segments {
+			points = append(points, segment.Points...)
+		}
+		routeType = file.Tracks[0].Type
+		route.name = firstNonEmpty(file.Tracks[0].Name, route.name)
+	default:
+		points = file.Waypoints
+	}
+	if mode, wgs84, ok := parseRouteHeightMode(routeType); ok {
+		route.heightType, route.wgs84 = mode, wgs84
+	}
+
+	for _, p := range points {
+		route.points = append(route.points, routePoint{
+			position: Coordinate{Longitude: p.Longitude, Latitude: p.Latitude},
+			height:   p.Elevation,
+		})
+	}
+	return route.waylines(opts)
+}
+
+// ExportGPX writes the waylines of a mission as GPX routes, with the
+// waypoint heights as elevations and the wayline height mode as the route
+// type. Only absolute heights are elevations in the GPX sense; GIS tools
+// show the others as if they were.
+func ExportGPX(mission *Mission) ([]byte, error) {
+	routes, err := exportedRoutes(mission)
+	if err != nil {
+		return nil, err
+	}
+	file := gpxFile{
+		Namespace: gpxNamespace,
+		Version:   "1.1",
+		Creator:   DefaultAuthor,
+		Metadata:  &gpxMeta{Name: "Waylines"},
+	}
+	for _, route := range routes {
+		rte := gpxRoute{Name: waylineName(route.waylineID), Type: string(route.heightMode)}
+		for i, w := range route.waypoints {
+			rte.Points = append(rte.Points, gpxPoint{
+				Latitude:    w.position.Latitude,
+				Longitude:   w.position.Longitude,
+				Elevation:   float64Ptr(w.height),
+				Name:        strconv.Itoa(i + 1),
+				Description: strings.Join(w.actions, ", "),
+			})
+		}
+		file.Routes = append(file.Routes, rte)
+	}
+	data, err := xml.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf(ErrExportRoute, RouteFormatGPX, err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// firstNonEmpty returns the first of its arguments that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,315 @@
+package wpml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// kmlNamespace is the namespace of KML 2.2 documents.
+const kmlNamespace = "http://www.opengis.net/kml/2.2"
+
+// KML altitude modes.
+const (
+	kmlRelativeToGround = "relativeToGround"
+	kmlAbsolute         = "absolute"
+)
+
+// kmlContainer is a KML Document or Folder.
+type kmlContainer struct {
+	Name       string         `xml:"name,omitempty"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+	Folders    []kmlContainer `xml:"Folder"`
+	Documents  []kmlContainer `xml:"Document"`
+}
+
+// kmlFile is a plain KML document.
+type kmlFile struct {
+	XMLName   xml.Name       `xml:"kml"`
+	Namespace string         `xml:"xmlns,attr,omitempty"`
+	Documents []kmlContainer `xml:"Document"`
+	Folders   []kmlContainer `xml:"Folder"`
+	Placemark []kmlPlacemark `xml:"Placemark"`
+}
+
+// kmlPlacemark is a KML Placemark with a point or a line.
+type kmlPlacemark struct {
+	Name          string            `xml:"name,omitempty"`
+	Description   string            `xml:"description,omitempty"`
+	ExtendedData  *kmlExtendedData  `xml:"ExtendedData,omitempty"`
+	Point         *kmlGeometry      `xml:"Point,omitempty"`
+	LineString    *kmlGeometry      `xml:"LineString,omitempty"`
+	MultiGeometry *kmlMultiGeometry `xml:"MultiGeometry,omitempty"`
+}
+
+// kmlMultiGeometry holds the lines and points of a KML MultiGeometry.
+type kmlMultiGeometry struct {
+	LineStrings []kmlGeometry `xml:"LineString"`
+	Points      []kmlGeometry `xml:"Point"`
+}
+
+// kmlGeometry is a KML Point or LineString. gx:altitudeMode shares the
+// local name of altitudeMode and is read into the same field.
+type kmlGeometry struct {
+	AltitudeMode string `xml:"altitudeMode,omitempty"`
+	Coordinates  string `xml:"coordinates"`
+}
+
+// kmlExtendedData holds the untyped data of a KML Placemark.
+type kmlExtendedData struct {
+	Data []kmlData `xml:"Data"`
+}
+
+// kmlData is a named value of KML extended data.
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+// ImportKML reads a route from a plain KML document such as a Google Earth
+// path. The first line in the document is the route; without one, its
+// points are. Points exported as waypoints by ExportKML take precedence, so
+// exported missions keep their speeds. Heights relative to the ground are
+// imported above ground level and absolute heights as EGM96; clamped
+// geometries have no heights.
+func ImportKML(data []byte, opts *ImportOptions) (*Waylines, error) {
+	var file kmlFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf(ErrParseRoute, RouteFormatKML, err)
+	}
+	root := kmlContainer{Placemarks: file.Placemark, Folders: file.Folders, Documents: file.Documents}
+	name := root.firstName()
+
+	var line *kmlGeometry
+	var points, waypoints []kmlPlacemark
+	secondWayline := false
+	root.walk(func(p *kmlPlacemark) {
+		lines, pointGeometries := p.geometries()
+		if line == nil && len(lines) > 0 {
+			line = &lines[0]
+		}
+		for i := range pointGeometries {
+			point := kmlPlacemark{Name: p.Name, ExtendedData: p.ExtendedData, Point: &pointGeometries[i]}
+			points = append(points, point)
+			// Exported waylines number their waypoints from 0; only the first is imported
+			index, ok := p.data("index")
+			if ok && index == "0" && len(waypoints) > 0 {
+				secondWayline = true
+			}
+			if ok && !secondWayline {
+				waypoints = append(waypoints, point)
+			}
+		}
+	})
+
+	var route *importedRoute
+	var err error
+	switch {
+	case len(waypoints) > 0:
+		route, err = kmlPointRoute(waypoints)
+	case line != nil:
+		route, err = kmlLineRoute(line)
+	default:
+		route, err = kmlPointRoute(points)
+	}
+	if err != nil {
+		return nil, fmt.Errorf(ErrParseRoute, RouteFormatKML, err)
+	}
+	route.name = name
+	return route.waylines(opts)
+}
+
+// walk calls fn for every placemark of the container in document order.
+func (c *kmlContainer) walk(fn func(*kmlPlacemark)) {
+	for i := range c.Placemarks {
+		fn(&c.Placemarks[i])
+	}
+	for i := range c.Folders {
+		c.Folders[i].walk(fn)
+	}
+	for i := range c.Documents {
+		c.Documents[i].walk(fn)
+	}
+}
+
+// firstName returns the name of the container or of its first named document.
+func (c *kmlContainer) firstName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	for i := range c.Documents {
+		if name := c.Documents[i].firstName(); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// geometries returns the lines and points of a placemark.
+func (p *kmlPlacemark) geometries() (lines, points []kmlGeometry) {
+	if p.LineString != nil {
+		lines = append(lines, *p.LineString)
+	}
+	if p.Point != nil {
+		points = append(points, *p.Point)
+	}
+	if p.MultiGeometry != nil {
+		lines = append(lines, p.MultiGeometry.LineStrings...)
+		points = append(points, p.MultiGeometry.Points...)
+	}
+	return lines, points
+}
+
+// data returns the extended data value of the given name.
+func (p *kmlPlacemark) data(name string) (string, bool) {
+	if p.ExtendedData == nil {
+		return "", false
+	}
+	for _, d := range p.ExtendedData.Data {
+		if d.Name == name {
+			return strings.TrimSpace(d.Value), true
+		}
+	}
+	return "", false
+}
+
+// kmlLineRoute reads the vertices of a line as a route.
+func kmlLineRoute(line *kmlGeometry) (*importedRoute, error) {
+	route := &importedRoute{}
+	heightType, useHeights := kmlHeightMode(line.AltitudeMode)
+	route.heightType = heightType
+	coords, heights, err := parseKMLCoordinates(line.Coordinates)
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range coords {
+		point := routePoint{position: c}
+		if useHeights {
+			point.height = heights[i]
+		}
+		route.points = append(route.points, point)
+	}
+	return route, nil
+}
+
+// kmlPointRoute reads points as a route, taking the height mode of the first.
+func kmlPointRoute(placemarks []kmlPlacemark) (*importedRoute, error) {
+	route := &importedRoute{}
+	for i := range placemarks {
+		p := &placemarks[i]
+		heightType, useHeights := kmlHeightMode(p.Point.AltitudeMode)
+		if mode, ok := p.data("height_mode"); ok {
+			if exported, wgs84, known := parseRouteHeightMode(mode); known {
+				heightType, route.wgs84 = exported, wgs84
+			}
+		}
+		if i == 0 {
+			route.heightType = heightType
+		}
+		coords, heights, err := parseKMLCoordinates(p.Point.Coordinates)
+		if err != nil {
+			return nil, err
+		}
+		if len(coords) != 1 {
+			return nil, fmt.Errorf(ErrInvalidCoordinates, p.Point.Coordinates)
+		}
+		point := routePoint{position: coords[0]}
+		if useHeights && heightType == route.heightType {
+			point.height = heights[0]
+		}
+		if speed, ok := p.data("speed"); ok {
+			point.speed, _ = strconv.ParseFloat(speed, 64)
+		}
+		route.points = append(route.points, point)
+	}
+	return route, nil
+}
+
+// kmlHeightMode returns the height mode of a KML altitude mode, and false
+// when geometries are clamped to the ground.
+func kmlHeightMode(altitudeMode string) (HeightMode, bool) {
+	switch strings.TrimSpace(altitudeMode) {
+	case kmlRelativeToGround, "relativeToSeaFloor":
+		return HeightModeAboveGroundLevel, true
+	case kmlAbsolute:
+		return HeightModeEGM96, true
+	default:
+		return HeightModeRelativeToStartPoint, false
+	}
+}
+
+// parseKMLCoordinates parses KML coordinate tuples with their optional altitudes.
+func parseKMLCoordinates(s string) ([]Coordinate, []*float64, error) {
+	coords, err := parseCoordinateList(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	heights := make([]*float64, len(coords))
+	for i, tuple := range strings.Fields(s) {
+		parts := strings.Split(tuple, ",")
+		if len(parts) < 3 {
+			continue
+		}
+		height, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf(ErrInvalidCoordinates, tuple)
+		}
+		heights[i] = &height
+	}
+	return coords, heights, nil
+}
+
+// ExportKML writes the waylines of a mission as a plain KML document, with
+// a folder per wayline holding its path and waypoints. KML has no height
+// mode relative to the take-off point, so those heights are written
+// relative to the ground; WGS84 heights are written as absolute.
+func ExportKML(mission *Mission) ([]byte, error) {
+	routes, err := exportedRoutes(mission)
+	if err != nil {
+		return nil, err
+	}
+	document := kmlContainer{Name: "Waylines"}
+	for _, route := range routes {
+		document.Folders = append(document.Folders, route.kmlFolder())
+	}
+	data, err := xml.MarshalIndent(kmlFile{Namespace: kmlNamespace, Documents: []kmlContainer{document}}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf(ErrExportRoute, RouteFormatKML, err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// kmlFolder creates the KML folder of an exported route.
+func (r *exportedRoute) kmlFolder() kmlContainer {
+	altitudeMode := kmlRelativeToGround
+	if r.heightMode == ExecuteHeightModeWGS84 {
+		altitudeMode = kmlAbsolute
+	}
+	tuples := make([]string, len(r.waypoints))
+	folder := kmlContainer{Name: waylineName(r.waylineID)}
+	for i, w := range r.waypoints {
+		tuples[i] = kmlTuple(w)
+		folder.Placemarks = append(folder.Placemarks, kmlPlacemark{
+			Name:        strconv.Itoa(i + 1),
+			Description: strings.Join(w.actions, ", "),
+			ExtendedData: &kmlExtendedData{Data: []kmlData{
+				{Name: "index", Value: strconv.Itoa(i)},
+				{Name: "height_mode", Value: string(r.heightMode)},
+				{Name: "speed", Value: strconv.FormatFloat(w.speed, 'f', -1, 64)},
+			}},
+			Point: &kmlGeometry{AltitudeMode: altitudeMode, Coordinates: tuples[i]},
+		})
+	}
+	path := kmlPlacemark{
+		Name:       folder.Name,
+		LineString: &kmlGeometry{AltitudeMode: altitudeMode, Coordinates: strings.Join(tuples, "\n")},
+	}
+	folder.Placemarks = append([]kmlPlacemark{path}, folder.Placemarks...)
+	return folder
+}
+
+// kmlTuple formats a waypoint as a KML "longitude,latitude,altitude" tuple.
+func kmlTuple(w exportedWaypoint) string {
+	return formatCoordinates(w.position.Longitude, w.position.Latitude) + "," + strconv.FormatFloat(w.height, 'f', -1, 64)
+}
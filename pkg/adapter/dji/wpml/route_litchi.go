@@ -0,0 +1,187 @@
+package wpml
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Litchi waypoint action types.
+const (
+	litchiStay        = 0
+	litchiTakePhoto   = 1
+	litchiStartRecord = 2
+	litchiStopRecord  = 3
+	litchiRotate      = 4
+	litchiTilt        = 5
+)
+
+// litchiGimbalInterpolate is the Litchi gimbal mode tilting the camera to
+// the waypoint's gimbal pitch.
+const litchiGimbalInterpolate = 2
+
+// litchiActions is the number of action columns of a Litchi waypoint.
+const litchiActions = 15
+
+// litchiRow reads the columns of a Litchi CSV row by header name.
+type litchiRow struct {
+	columns map[string]int
+	record  []string
+}
+
+// float returns the number in the named column, and zero when the row has none.
+func (r litchiRow) float(name string) (float64, error) {
+	i, ok := r.columns[name]
+	if !ok || i >= len(r.record) || strings.TrimSpace(r.record[i]) == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(r.record[i]), 64)
+	if err != nil {
+		return 0, fmt.Errorf(ErrInvalidRouteValue, name, r.record[i])
+	}
+	return value, nil
+}
+
+// ImportLitchiCSV reads a route from a Litchi mission hub CSV export. Stays,
+// photos, recordings, aircraft rotations, camera tilts and the interpolated
+// gimbal pitch become waypoint actions; photo intervals apply to waypoints
+// without other actions. Headings, curves and points of interest are not
+// imported. Altitudes are relative to the take-off point or, in Litchi's
+// above ground mode, above ground level.
+func ImportLitchiCSV(data []byte, opts *ImportOptions) (*Waylines, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf(ErrParseRoute, RouteFormatLitchiCSV, err)
+	}
+	if len(records) == 0 {
+		return nil, ErrRouteEmpty
+	}
+
+	columns := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["latitude"]; !ok {
+		return nil, fmt.Errorf(ErrParseRoute, RouteFormatLitchiCSV, fmt.Errorf(ErrInvalidRouteValue, "header", strings.Join(records[0], ",")))
+	}
+
+	route := &importedRoute{}
+	for i, record := range records[1:] {
+		row := litchiRow{columns: columns, record: record}
+		point, aboveGround, err := row.point()
+		if err != nil {
+			return nil, fmt.Errorf(ErrParseRoute, RouteFormatLitchiCSV, fmt.Errorf(ErrConvertWaypoint, i, err))
+		}
+		heightType := HeightModeRelativeToStartPoint
+		if aboveGround {
+			heightType = HeightModeAboveGroundLevel
+		}
+		if i == 0 {
+			route.heightType = heightType
+		} else if heightType != route.heightType {
+			return nil, ErrRouteMixedHeightModes
+		}
+		route.points = append(route.points, point)
+	}
+	return route.waylines(opts)
+}
+
+// point reads the waypoint of a Litchi row, and whether its altitude is above ground level.
+func (r litchiRow) point() (routePoint, bool, error) {
+	values := make(map[string]float64)
+	for _, name := range []string{"latitude", "longitude", "altitude(m)", "speed(m/s)", "altitudemode",
+		"gimbalmode", "gimbalpitchangle", "photo_timeinterval", "photo_distinterval"} {
+		value, err := r.float(name)
+		if err != nil {
+			return routePoint{}, false, err
+		}
+		values[name] = value
+	}
+
+	point := routePoint{
+		position: Coordinate{Longitude: values["longitude"], Latitude: values["latitude"]},
+		height:   float64Ptr(values["altitude(m)"]),
+		speed:    values["speed(m/s)"],
+	}
+	if values["gimbalmode"] == litchiGimbalInterpolate {
+		point.actions = append(point.actions, gimbalPitchAction(values["gimbalpitchangle"]))
+	}
+	for n := 1; n <= litchiActions; n++ {
+		typeColumn := fmt.Sprintf("actiontype%d", n)
+		if _, ok := r.columns[typeColumn]; !ok {
+			break
+		}
+		actionType, err := r.float(typeColumn)
+		if err != nil {
+			return routePoint{}, false, err
+		}
+		param, err := r.float(fmt.Sprintf("actionparam%d", n))
+		if err != nil {
+			return routePoint{}, false, err
+		}
+		if action := litchiAction(int(actionType), param); action != nil {
+			point.actions = append(point.actions, *action)
+		}
+	}
+
+	if len(point.actions) == 0 {
+		switch {
+		case values["photo_distinterval"] > 0:
+			point.triggerType, point.triggerParam = TriggerTypeMultipleDistance, values["photo_distinterval"]
+		case values["photo_timeinterval"] > 0:
+			point.triggerType, point.triggerParam = TriggerTypeMultipleTiming, values["photo_timeinterval"]
+		}
+		if point.triggerType != "" {
+			point.actions = []ActionRequest{*litchiAction(litchiTakePhoto, 0)}
+		}
+	}
+	return point, values["altitudemode"] == 1, nil
+}
+
+// litchiAction converts a Litchi action, and returns nil for no action or
+// actions without a WPML counterpart.
+func litchiAction(actionType int, param float64) *ActionRequest {
+	switch actionType {
+	case litchiStay:
+		if param <= 0 {
+			return nil
+		}
+		return &ActionRequest{Type: ActionTypeHover, Action: &HoverAction{HoverTime: param / 1000}}
+	case litchiTakePhoto:
+		return &ActionRequest{Type: ActionTypeTakePhoto, Action: &TakePhotoAction{UseGlobalPayloadLensIndex: true}}
+	case litchiStartRecord:
+		return &ActionRequest{Type: ActionTypeStartRecord, Action: &StartRecordAction{UseGlobalPayloadLensIndex: true}}
+	case litchiStopRecord:
+		return &ActionRequest{Type: ActionTypeStopRecord, Action: &StopRecordAction{}}
+	case litchiRotate:
+		// Litchi headings run 0-360 and WPML headings -180-180
+		heading := math.Mod(param+180, 360)
+		if heading < 0 {
+			heading += 360
+		}
+		return &ActionRequest{Type: ActionTypeRotateYaw, Action: &RotateYawAction{AircraftHeading: heading - 180}}
+	case litchiTilt:
+		request := gimbalPitchAction(param)
+		return &request
+	default:
+		return nil
+	}
+}
+
+// gimbalPitchAction points the camera at an absolute pitch.
+func gimbalPitchAction(pitch float64) ActionRequest {
+	return ActionRequest{
+		Type: ActionTypeGimbalRotate,
+		Action: &GimbalRotateAction{
+			GimbalHeadingYawBase:    "aircraft",
+			GimbalRotateMode:        "absoluteAngle",
+			GimbalPitchRotateEnable: true,
+			GimbalPitchRotateAngle:  pitch,
+		},
+	}
+}
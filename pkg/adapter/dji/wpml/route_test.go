@@ -0,0 +1,294 @@
+package wpml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parsedMission writes waylines to a KMZ and parses it back, as missions
+// are read from storage.
+func parsedMission(t *testing.T, waylines *Waylines) *Mission {
+	t.Helper()
+	buffer, err := CreateKmzBufferFromWaylines(waylines)
+	require.NoError(t, err)
+	mission, err := ParseKMZBuffer(buffer.Bytes())
+	require.NoError(t, err)
+	return mission
+}
+
+// assertSameRoute checks imported waypoints against the original ones.
+func assertSameRoute(t *testing.T, want, got *Waylines, speeds bool) {
+	t.Helper()
+	require.Len(t, got.Waypoints, len(want.Waypoints))
+	for i, w := range want.Waypoints {
+		g := got.Waypoints[i]
+		assert.InDelta(t, w.Latitude, g.Latitude, 1e-9, "waypoint %d", i)
+		assert.InDelta(t, w.Longitude, g.Longitude, 1e-9, "waypoint %d", i)
+		assert.InDelta(t, w.Height, g.Height, 1e-9, "waypoint %d", i)
+		if speeds {
+			assert.InDelta(t, w.Speed, g.Speed, 1e-9, "waypoint %d", i)
+		}
+		assert.Equal(t, TriggerTypeReachPoint, g.TriggerType)
+	}
+}
+
+func TestRoute_RoundTrip(t *testing.T) {
+	waylines := testEstimateWaylines()
+	waylines.HeightType = HeightModeRelativeToStartPoint
+	waylines.Waypoints[1].Height = 60
+	waylines.Waypoints[2].Speed = 8
+	mission := parsedMission(t, waylines)
+	opts := &ImportOptions{DroneModel: DroneM3Series, PayloadModel: PayloadMavic3ECamera}
+
+	tests := []struct {
+		format RouteFormat
+		speeds bool
+	}{
+		{RouteFormatGeoJSON, true},
+		{RouteFormatKML, true},
+		{RouteFormatGPX, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			data, err := ExportRoute(tt.format, mission)
+			require.NoError(t, err)
+
+			imported, err := ImportRoute(tt.format, data, opts)
+			require.NoError(t, err)
+			assert.Equal(t, HeightModeRelativeToStartPoint, imported.HeightType)
+			assertSameRoute(t, waylines, imported, tt.speeds)
+
+			// The imported route converts to the same flight path
+			converted, err := ConvertWaylinesToMission(imported)
+			require.NoError(t, err)
+			original, err := estimateWaypoints(&mission.Waylines.Document.Folders[0], 0, 0)
+			require.NoError(t, err)
+			roundTrip, err := estimateWaypoints(&converted.Waylines.Document.Folders[0], 0, 0)
+			require.NoError(t, err)
+			require.Len(t, roundTrip, len(original))
+			for i := range original {
+				assert.InDelta(t, original[i].height, roundTrip[i].height, 1e-9)
+				assert.InDelta(t, original[i].position.Latitude, roundTrip[i].position.Latitude, 1e-9)
+			}
+		})
+	}
+}
+
+func TestRoute_RoundTripWGS84(t *testing.T) {
+	waylines := testEstimateWaylines()
+	waylines.HeightType = HeightModeEGM96
+	for i := range waylines.Waypoints {
+		waylines.Waypoints[i].Height = 160
+		waylines.Waypoints[i].EllipsoidHeight = float64Ptr(150)
+	}
+	mission := parsedMission(t, waylines)
+
+	for _, format := range []RouteFormat{RouteFormatGeoJSON, RouteFormatKML, RouteFormatGPX} {
+		data, err := ExportRoute(format, mission)
+		require.NoError(t, err)
+		imported, err := ImportRoute(format, data, &ImportOptions{DroneModel: DroneM3Series, PayloadModel: PayloadMavic3ECamera})
+		require.NoError(t, err)
+		assert.Equal(t, HeightModeEGM96, imported.HeightType, format)
+		require.NotNil(t, imported.Waypoints[0].EllipsoidHeight, format)
+		assert.InDelta(t, 150, *imported.Waypoints[0].EllipsoidHeight, 1e-9)
+
+		converted, err := ConvertWaylinesToMission(imported)
+		require.NoError(t, err)
+		assert.Equal(t, ExecuteHeightModeWGS84, converted.Waylines.Document.Folders[0].ExecuteHeightMode)
+	}
+}
+
+func TestExportGeoJSON(t *testing.T) {
+	data, err := ExportGeoJSON(parsedMission(t, testEstimateWaylines()))
+	require.NoError(t, err)
+	text := string(data)
+	assert.Contains(t, text, `"type": "FeatureCollection"`)
+	assert.Contains(t, text, `"type": "LineString"`)
+	assert.Contains(t, text, `"takePhoto"`)
+	assert.Contains(t, text, `"hover"`)
+
+	_, err = ExportGeoJSON(nil)
+	assert.ErrorIs(t, err, ErrMissionCannotBeEmpty)
+	_, err = ExportRoute(RouteFormatLitchiCSV, &Mission{})
+	assert.ErrorContains(t, err, "unsupported route format \"litchi\"")
+}
+
+func TestImportKML_GoogleEarthPath(t *testing.T) {
+	path := `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+  <Document>
+    <name>Survey path</name>
+    <Placemark>
+      <name>Start</name>
+      <Point><coordinates>116.40,39.90,0</coordinates></Point>
+    </Placemark>
+    <Folder>
+      <Placemark>
+        <name>Path</name>
+        <LineString>
+          <altitudeMode>%s</altitudeMode>
+          <coordinates>
+            116.40,39.90,80 116.41,39.90,90
+            116.41,39.91,100
+          </coordinates>
+        </LineString>
+      </Placemark>
+    </Folder>
+  </Document>
+</kml>`
+
+	waylines, err := ImportKML([]byte(strings.Replace(path, "%s", "relativeToGround", 1)), &ImportOptions{Speed: 7})
+	require.NoError(t, err)
+	assert.Equal(t, "Survey path", waylines.Name)
+	assert.Equal(t, HeightModeAboveGroundLevel, waylines.HeightType)
+	require.Len(t, waylines.Waypoints, 3)
+	assert.InDelta(t, 90, waylines.Waypoints[1].Height, 1e-9)
+	assert.InDelta(t, 7, waylines.Waypoints[1].Speed, 1e-9)
+
+	// Clamped paths fly at the default height above the take-off point
+	waylines, err = ImportKML([]byte(strings.Replace(path, "%s", "clampToGround", 1)), &ImportOptions{TakePhoto: true})
+	require.NoError(t, err)
+	assert.Equal(t, HeightModeRelativeToStartPoint, waylines.HeightType)
+	assert.InDelta(t, DefaultImportHeight, waylines.Waypoints[0].Height, 1e-9)
+	assert.InDelta(t, DefaultImportSpeed, waylines.Waypoints[0].Speed, 1e-9)
+	require.Len(t, waylines.Waypoints[2].Actions, 1)
+	assert.Equal(t, ActionTypeTakePhoto, waylines.Waypoints[2].Actions[0].Type)
+
+	_, err = ImportKML([]byte(`<kml><Document/></kml>`), nil)
+	assert.ErrorIs(t, err, ErrRouteEmpty)
+	_, err = ImportKML([]byte(`not xml`), nil)
+	assert.ErrorContains(t, err, "failed to parse kml route")
+}
+
+func TestImportGeoJSON(t *testing.T) {
+	collection := `{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "properties": {"name": "Home"}, "geometry": {"type": "Point", "coordinates": [116.40, 39.90]}},
+    {"type": "Feature", "properties": {"name": "Line"}, "geometry": {"type": "MultiLineString", "coordinates": [[[116.40, 39.90, 30], [116.41, 39.90, 40]]]}}
+  ]
+}`
+	waylines, err := ImportGeoJSON([]byte(collection), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Line", waylines.Name)
+	assert.Equal(t, HeightModeRelativeToStartPoint, waylines.HeightType)
+	require.Len(t, waylines.Waypoints, 2)
+	assert.InDelta(t, 40, waylines.Waypoints[1].Height, 1e-9)
+
+	geometry := `{"type": "MultiPoint", "coordinates": [[116.40, 39.90], [116.41, 39.91]]}`
+	waylines, err = ImportGeoJSON([]byte(geometry), &ImportOptions{Name: "Points", Height: 70})
+	require.NoError(t, err)
+	assert.Equal(t, "Points", waylines.Name)
+	require.Len(t, waylines.Waypoints, 2)
+	assert.InDelta(t, 70, waylines.Waypoints[1].Height, 1e-9)
+
+	_, err = ImportGeoJSON([]byte(`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}`), nil)
+	assert.ErrorIs(t, err, ErrRouteEmpty)
+	_, err = ImportGeoJSON([]byte(`{"type": "Point", "coordinates": [1]}`), nil)
+	assert.ErrorContains(t, err, "failed to parse geojson route")
+}
+
+func TestImportGPX(t *testing.T) {
+	track := `<?xml version="1.0"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <metadata><name>Walk</name></metadata>
+  <trk>
+    <trkseg><trkpt lat="39.90" lon="116.40"><ele>120</ele></trkpt></trkseg>
+    <trkseg><trkpt lat="39.91" lon="116.41"><ele>125.5</ele></trkpt></trkseg>
+  </trk>
+</gpx>`
+	waylines, err := ImportGPX([]byte(track), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Walk", waylines.Name)
+	assert.Equal(t, HeightModeEGM96, waylines.HeightType)
+	require.Len(t, waylines.Waypoints, 2)
+	assert.InDelta(t, 125.5, waylines.Waypoints[1].Height, 1e-9)
+	assert.Nil(t, waylines.Waypoints[1].EllipsoidHeight)
+
+	points := `<gpx version="1.1"><wpt lat="39.90" lon="116.40"/><wpt lat="39.91" lon="116.41"/></gpx>`
+	waylines, err = ImportGPX([]byte(points), &ImportOptions{HeightType: HeightModeAboveGroundLevel})
+	require.NoError(t, err)
+	assert.Equal(t, defaultImportName, waylines.Name)
+	assert.Equal(t, HeightModeAboveGroundLevel, waylines.HeightType)
+	assert.InDelta(t, DefaultImportHeight, waylines.Waypoints[0].Height, 1e-9)
+
+	_, err = ImportGPX([]byte(`<gpx version="1.1"/>`), nil)
+	assert.ErrorIs(t, err, ErrRouteEmpty)
+}
+
+// litchiHeader is a Litchi mission hub header, trimmed to three actions.
+const litchiHeader = "latitude,longitude,altitude(m),heading(deg),curvesize(m),rotationdir,gimbalmode,gimbalpitchangle," +
+	"actiontype1,actionparam1,actiontype2,actionparam2,actiontype3,actionparam3," +
+	"altitudemode,speed(m/s),poi_latitude,poi_longitude,poi_altitude(m),poi_altitudemode,photo_timeinterval,photo_distinterval\n"
+
+func TestImportLitchiCSV(t *testing.T) {
+	csv := litchiHeader +
+		"39.90,116.40,30,0,0,0,2,-90,1,0,0,2000,4,270,0,0,0,0,0,0,-1,-1\n" +
+		"39.91,116.40,40,0,0,0,0,0,-1,0,-1,0,-1,0,0,20,0,0,0,0,-1,25\n" +
+		"39.91,116.41,50,0,0,0,0,0,2,0,5,-45,3,0,0,0,0,0,0,0,2,-1\n"
+
+	waylines, err := ImportLitchiCSV([]byte(csv), &ImportOptions{DroneModel: DroneM3Series, PayloadModel: PayloadMavic3ECamera})
+	require.NoError(t, err)
+	assert.Equal(t, HeightModeRelativeToStartPoint, waylines.HeightType)
+	require.Len(t, waylines.Waypoints, 3)
+
+	first := waylines.Waypoints[0]
+	assert.InDelta(t, 30, first.Height, 1e-9)
+	require.Len(t, first.Actions, 4)
+	assert.Equal(t, ActionTypeGimbalRotate, first.Actions[0].Type)
+	assert.Equal(t, ActionTypeTakePhoto, first.Actions[1].Type)
+	hover, ok := first.Actions[2].Action.(*HoverAction)
+	require.True(t, ok)
+	assert.InDelta(t, 2, hover.HoverTime, 1e-9)
+	rotate, ok := first.Actions[3].Action.(*RotateYawAction)
+	require.True(t, ok)
+	assert.InDelta(t, -90, rotate.AircraftHeading, 1e-9)
+
+	// Speeds are capped and photo intervals become triggers
+	second := waylines.Waypoints[1]
+	assert.InDelta(t, maxSurveySpeed, second.Speed, 1e-9)
+	assert.Equal(t, TriggerTypeMultipleDistance, second.TriggerType)
+	assert.InDelta(t, 25, second.TriggerParam, 1e-9)
+	require.Len(t, second.Actions, 1)
+	assert.Equal(t, ActionTypeTakePhoto, second.Actions[0].Type)
+
+	third := waylines.Waypoints[2]
+	assert.Equal(t, TriggerTypeReachPoint, third.TriggerType)
+	require.Len(t, third.Actions, 3)
+	assert.Equal(t, ActionTypeStartRecord, third.Actions[0].Type)
+	tilt, ok := third.Actions[1].Action.(*GimbalRotateAction)
+	require.True(t, ok)
+	assert.InDelta(t, -45, tilt.GimbalPitchRotateAngle, 1e-9)
+	assert.Equal(t, ActionTypeStopRecord, third.Actions[2].Type)
+
+	mission, err := ConvertWaylinesToMission(waylines)
+	require.NoError(t, err)
+	group := mission.Waylines.Document.Folders[0].Placemarks[1].ActionGroups[0]
+	assert.Equal(t, TriggerTypeMultipleDistance, group.ActionTrigger.ActionTriggerType)
+	require.NotNil(t, group.ActionTrigger.ActionTriggerParam)
+	assert.InDelta(t, 25, *group.ActionTrigger.ActionTriggerParam, 1e-9)
+}
+
+func TestImportLitchiCSV_Errors(t *testing.T) {
+	aboveGround := litchiHeader +
+		"39.90,116.40,30,0,0,0,0,0,-1,0,-1,0,-1,0,1,5,0,0,0,0,-1,-1\n"
+	waylines, err := ImportLitchiCSV([]byte(aboveGround), nil)
+	require.NoError(t, err)
+	assert.Equal(t, HeightModeAboveGroundLevel, waylines.HeightType)
+
+	mixed := aboveGround + "39.91,116.40,30,0,0,0,0,0,-1,0,-1,0,-1,0,0,5,0,0,0,0,-1,-1\n"
+	_, err = ImportLitchiCSV([]byte(mixed), nil)
+	assert.ErrorIs(t, err, ErrRouteMixedHeightModes)
+
+	_, err = ImportLitchiCSV([]byte(litchiHeader+"39.90,east\n"), nil)
+	assert.ErrorContains(t, err, `invalid longitude value "east"`)
+	_, err = ImportLitchiCSV([]byte("lat,lon\n1,2\n"), nil)
+	assert.ErrorContains(t, err, "failed to parse litchi route")
+	_, err = ImportLitchiCSV([]byte(litchiHeader), nil)
+	assert.ErrorIs(t, err, ErrRouteEmpty)
+	_, err = ImportRoute("shp", nil, nil)
+	assert.ErrorContains(t, err, "unsupported route format \"shp\"")
+}
@@ -0,0 +1,237 @@
+package wpml
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// payloadLenses lists the lenses of payload cameras. Lenses of payloads
+// missing here are not checked.
+var payloadLenses = map[PayloadModel][]string{
+	PayloadZ30:              {LensZoom},
+	PayloadXT2:              {LensWide, LensIR},
+	PayloadXTS:              {LensIR},
+	PayloadH20:              {LensWide, LensZoom},
+	PayloadH20T:             {LensWide, LensZoom, LensIR},
+	PayloadH20N:             {LensWide, LensZoom, LensIR},
+	PayloadH30:              {LensWide, LensZoom},
+	PayloadH30T:             {LensWide, LensZoom, LensIR},
+	PayloadM30Camera:        {LensWide, LensZoom},
+	PayloadM30TCamera:       {LensWide, LensZoom, LensIR},
+	PayloadMavic3ECamera:    {LensWide, LensZoom},
+	PayloadMavic3TCamera:    {LensWide, LensZoom, LensIR},
+	PayloadMatrice3DCamera:  {LensWide, LensZoom},
+	PayloadMatrice3TDCamera: {LensWide, LensZoom, LensIR},
+	PayloadMatrice4ECamera:  {LensWide, LensZoom},
+	PayloadMatrice4TCamera:  {LensWide, LensZoom, LensIR},
+	PayloadMatrice4DCamera:  {LensWide, LensZoom},
+	PayloadMatrice4TDCamera: {LensWide, LensZoom, LensIR},
+	PayloadFPVCamera:        {LensWide},
+	PayloadDockCamera:       {LensWide},
+	PayloadAuxiliaryCamera:  {LensWide},
+}
+
+// actionDrones lists, as drone patterns, the drones that can run action
+// types needing an accessory: speakers and searchlights are mounted as
+// accessories on the Mavic 3 and Matrice 4 series, and as PSDK payloads on the
+// Matrice 300, 350 and 400.
+var actionDrones = map[string]string{
+	ActionTypeMegaphone:   "M300|M350|M400|M3|M4",
+	ActionTypeSearchlight: "M300|M350|M400|M3|M4",
+}
+
+// actionPayloads lists, as payload patterns, the payloads that can run
+// action types limited to some cameras.
+var actionPayloads = map[string]string{
+	ActionTypeAccurateShoot: "H20",
+	ActionTypeOrientedShoot: "H30|M30|M3|M3D|M4|M4D",
+}
+
+// autoRerouteDrones lists, as drone patterns, the drones that reroute around obstacles on waylines.
+const autoRerouteDrones = "M400|M4|M4D"
+
+// LensIndex joins lenses into a payloadLensIndex list such as "wide,ir".
+func LensIndex(lenses ...string) *string {
+	lensIndex := strings.Join(lenses, ",")
+	return &lensIndex
+}
+
+// ParseLensIndex splits a payloadLensIndex list such as "wide,ir" into its lenses.
+func ParseLensIndex(lensIndex string) []string {
+	var lenses []string
+	for _, lens := range strings.Split(lensIndex, ",") {
+		if lens = strings.TrimSpace(lens); lens != "" {
+			lenses = append(lenses, lens)
+		}
+	}
+	return lenses
+}
+
+// PayloadLenses returns the lenses of a payload model, and false when they are not known.
+func PayloadLenses(model PayloadModel) ([]string, bool) {
+	lenses, ok := payloadLenses[model]
+	return lenses, ok
+}
+
+// payloadHasLens reports whether a payload has a lens. Visible light images
+// are taken with the wide lens; lenses of unknown payloads are assumed.
+func payloadHasLens(model PayloadModel, lens string) bool {
+	lenses, ok := payloadLenses[model]
+	if !ok {
+		return true
+	}
+	if lens == LensVisible {
+		lens = LensWide
+	}
+	return slices.Contains(lenses, lens)
+}
+
+// photoSettingLens returns the lens of a photo setting, which names visible light images "vision".
+func photoSettingLens(setting string) string {
+	if setting == "vision" {
+		return LensVisible
+	}
+	return setting
+}
+
+func (w *Validator) validateLensIndex(fl validator.FieldLevel) bool {
+	lenses := ParseLensIndex(fl.Field().String())
+	if len(lenses) == 0 {
+		return false
+	}
+	for _, lens := range lenses {
+		switch lens {
+		case LensWide, LensZoom, LensIR, LensNarrowBand, LensVisible:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateWaylinesSupport checks that the drone and payload models of
+// waylines support their auto reroute settings, photo lenses and actions.
+// Actions on other payload positions than the waylines' payload are only
+// checked against the drone.
+func (w *Validator) ValidateWaylinesSupport(waylines *Waylines) error {
+	if err := w.validateAutoReroute(waylines.AutoRerouteInfo, waylines.DroneModel); err != nil {
+		return err
+	}
+	for _, setting := range waylines.PhotoSettings {
+		if lens := photoSettingLens(setting); !payloadHasLens(waylines.PayloadModel, lens) {
+			return fmt.Errorf(ErrLensNotAvailable, lens, waylines.PayloadModel)
+		}
+	}
+
+	support := actionSupport{drone: waylines.DroneModel, payload: waylines.PayloadModel, position: waylines.PayloadPositionIndex}
+	for i := range waylines.Waypoints {
+		for j, action := range waylines.Waypoints[i].Actions {
+			position, lensIndex := actionPayloadFields(action.Action)
+			if err := w.validateActionSupport(support, action.Type, position, lensIndex); err != nil {
+				return fmt.Errorf(ErrWaypointActionNotSupported, i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateMissionSupport checks that the drone and payload models in the
+// mission config of a waylines document support its auto reroute settings
+// and the actions of its waypoints.
+func (w *Validator) ValidateMissionSupport(waylineDoc *WaylinesDocument) error {
+	if waylineDoc == nil {
+		return ErrWaylineDocumentCannotBeNil
+	}
+	cfg := &waylineDoc.Document.MissionConfig
+	support := actionSupport{
+		drone:    DroneModel(cfg.DroneInfo.DroneEnumValue),
+		payload:  PayloadModel(cfg.PayloadInfo.PayloadEnumValue),
+		position: PayloadPosition(cfg.PayloadInfo.PayloadPositionIndex),
+	}
+	if err := w.validateAutoReroute(cfg.AutoRerouteInfo, support.drone); err != nil {
+		return err
+	}
+
+	for _, folder := range waylineDoc.Document.Folders {
+		for _, placemark := range folder.Placemarks {
+			for _, group := range placemark.ActionGroups {
+				for j, action := range group.Actions {
+					var position PayloadPosition
+					var lensIndex *string
+					if param := action.ActionActuatorFuncParam; param != nil {
+						if param.PayloadPositionIndex != nil {
+							position = PayloadPosition(*param.PayloadPositionIndex)
+						}
+						lensIndex = param.PayloadLensIndex
+					}
+					if err := w.validateActionSupport(support, action.ActionActuatorFunc, position, lensIndex); err != nil {
+						return fmt.Errorf(ErrWaypointActionNotSupported, placemark.Index, j, err)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// actionSupport holds the drone and payload actions are checked against.
+type actionSupport struct {
+	drone    DroneModel
+	payload  PayloadModel
+	position PayloadPosition
+}
+
+// validateAutoReroute checks that a drone model supports auto reroute settings.
+func (w *Validator) validateAutoReroute(info *AutoRerouteInfo, drone DroneModel) error {
+	if info != nil && !matchesAnyPattern(autoRerouteDrones, drone, w.matchesDronePattern) {
+		return fmt.Errorf(ErrAutoRerouteNotSupported, drone)
+	}
+	return nil
+}
+
+// validateActionSupport checks an action type, run on a payload position
+// with an optional lens list, against the drone and payload.
+func (w *Validator) validateActionSupport(support actionSupport, actionType string, position PayloadPosition, lensIndex *string) error {
+	if pattern, ok := actionDrones[actionType]; ok && !matchesAnyPattern(pattern, support.drone, w.matchesDronePattern) {
+		return fmt.Errorf(ErrActionNotSupportedByDrone, actionType, support.drone)
+	}
+	if position != support.position {
+		return nil
+	}
+	if pattern, ok := actionPayloads[actionType]; ok && !matchesAnyPattern(pattern, support.payload, w.matchesPayloadPattern) {
+		return fmt.Errorf(ErrActionNotSupportedByPayload, actionType, support.payload)
+	}
+	if lensIndex == nil {
+		return nil
+	}
+	for _, lens := range ParseLensIndex(*lensIndex) {
+		if !payloadHasLens(support.payload, lens) {
+			return fmt.Errorf(ErrLensNotAvailable, lens, support.payload)
+		}
+	}
+	return nil
+}
+
+// actionPayloadFields returns the payload position and lens list of an
+// action; actions without a payload run on position 0.
+func actionPayloadFields(action ActionInterface) (PayloadPosition, *string) {
+	value := reflect.ValueOf(action)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return 0, nil
+	}
+	value = value.Elem()
+
+	var position PayloadPosition
+	if field := value.FieldByName("PayloadPositionIndex"); field.IsValid() && field.Kind() == reflect.Int {
+		position = PayloadPosition(field.Int())
+	}
+	var lensIndex *string
+	if field := value.FieldByName("PayloadLensIndex"); field.IsValid() {
+		lensIndex, _ = field.Interface().(*string)
+	}
+	return position, lensIndex
+}
@@ -0,0 +1,110 @@
+package wpml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// supportWaylines returns valid waylines whose first waypoint runs the given actions.
+func supportWaylines(drone DroneModel, payload PayloadModel, actions ...ActionInterface) *Waylines {
+	waylines := createValidWaylines("support")
+	waylines.DroneModel, waylines.PayloadModel = drone, payload
+	for _, action := range actions {
+		waylines.Waypoints[0].Actions = append(waylines.Waypoints[0].Actions, *NewActionRequest(action))
+	}
+	return waylines
+}
+
+func TestValidateWaylinesSupport_Actions(t *testing.T) {
+	megaphone := &MegaphoneAction{PayloadPositionIndex: PayloadPosition2, MegaphoneOperateType: "playText", MegaphoneText: "hello"}
+	searchlight := &SearchlightAction{PayloadPositionIndex: PayloadPosition2, SearchlightOperateType: "strobe"}
+	require.NoError(t, supportWaylines(DroneM4Series, PayloadMatrice4TCamera, megaphone, searchlight).Validate())
+	require.NoError(t, supportWaylines(DroneM350RTK, PayloadH20T, megaphone).Validate())
+
+	err := supportWaylines(DroneM30, PayloadM30TCamera, searchlight).Validate()
+	assert.EqualError(t, err, "waypoint 0 action 0: action searchlight is not supported by drone model 67")
+
+	// Actions limited to some cameras are checked on the waylines' payload only
+	pano := &PanoShotAction{PanoShotSubMode: "panoShot_360"}
+	oriented := &OrientedShootAction{}
+	v, err := NewValidator()
+	require.NoError(t, err)
+	require.NoError(t, v.ValidateWaylinesSupport(supportWaylines(DroneM4Series, PayloadMatrice4TCamera, pano, oriented)))
+	err = v.ValidateWaylinesSupport(supportWaylines(DroneM350RTK, PayloadH20T, pano, oriented))
+	assert.EqualError(t, err, "waypoint 0 action 1: action orientedShoot is not supported by payload model 43")
+	oriented.PayloadPositionIndex = PayloadPosition1
+	require.NoError(t, v.ValidateWaylinesSupport(supportWaylines(DroneM350RTK, PayloadH20T, oriented)))
+}
+
+func TestValidateWaylinesSupport_Lenses(t *testing.T) {
+	photo := &TakePhotoAction{PayloadLensIndex: LensIndex(LensWide, LensIR)}
+	require.NoError(t, supportWaylines(DroneM3Series, PayloadMavic3TCamera, photo).Validate())
+
+	err := supportWaylines(DroneM3Series, PayloadMavic3ECamera, photo).Validate()
+	assert.EqualError(t, err, "waypoint 0 action 0: lens ir is not available on payload model 66")
+
+	// Payloads with unknown lenses are not checked
+	require.NoError(t, supportWaylines(DroneM350RTK, PayloadPSDK, photo).Validate())
+
+	// Lens lists are checked against the WPML lens names
+	photo.PayloadLensIndex = LensIndex(LensWide, "thermal")
+	err = supportWaylines(DroneM3Series, PayloadMavic3TCamera, photo).Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "lens_index")
+
+	waylines := supportWaylines(DroneM300RTK, PayloadH20)
+	waylines.PhotoSettings = []string{"wide", "ir"}
+	assert.EqualError(t, waylines.Validate(), "lens ir is not available on payload model 42")
+	waylines.PhotoSettings = []string{"wide", "vision"}
+	require.NoError(t, waylines.Validate())
+
+	assert.Equal(t, []string{"wide", "zoom", "ir"}, ParseLensIndex(" wide,zoom,,ir "))
+	assert.Empty(t, ParseLensIndex(""))
+	lenses, ok := PayloadLenses(PayloadH30T)
+	assert.True(t, ok)
+	assert.Equal(t, []string{LensWide, LensZoom, LensIR}, lenses)
+	_, ok = PayloadLenses(PayloadPSDK)
+	assert.False(t, ok)
+}
+
+func TestValidateWaylinesSupport_ActionParams(t *testing.T) {
+	audio := &MegaphoneAction{MegaphoneOperateType: "playAudio"}
+	err := supportWaylines(DroneM4Series, PayloadMatrice4ECamera, audio).Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MegaphoneFileName")
+	audio.MegaphoneFileName, audio.MegaphoneFileMD5 = "warning.wav", "9e107d9d372bb6826bd81d3542a419d6"
+	require.NoError(t, supportWaylines(DroneM4Series, PayloadMatrice4ECamera, audio).Validate())
+
+	shooting := &StartContinuousShootingAction{}
+	err = supportWaylines(DroneM4Series, PayloadMatrice4ECamera, shooting).Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MinShootInterval")
+
+	focus := &SetFocusTypeAction{CameraFocusType: "fixed"}
+	require.Error(t, supportWaylines(DroneM4Series, PayloadMatrice4ECamera, focus).Validate())
+}
+
+func TestValidateMissionSupport(t *testing.T) {
+	waylines := supportWaylines(DroneM4Series, PayloadMatrice4TCamera,
+		&SearchlightAction{SearchlightOperateType: "on"},
+		&TakePhotoAction{PayloadLensIndex: LensIndex(LensIR)})
+	waylines.AutoRerouteInfo = &AutoRerouteInfo{MissionAutoRerouteMode: 1}
+	mission, err := ConvertWaylinesToMission(waylines)
+	require.NoError(t, err)
+	require.NotNil(t, mission.Template.Document.MissionConfig.AutoRerouteInfo)
+
+	v, err := NewValidator()
+	require.NoError(t, err)
+	require.NoError(t, v.ValidateMissionSupport(mission.Waylines))
+
+	cfg := &mission.Waylines.Document.MissionConfig
+	cfg.PayloadInfo.PayloadEnumValue = int(PayloadMatrice4ECamera)
+	assert.EqualError(t, v.ValidateMissionSupport(mission.Waylines), "waypoint 0 action 1: lens ir is not available on payload model 88")
+	cfg.DroneInfo.DroneEnumValue = int(DroneM30)
+	assert.EqualError(t, v.ValidateMissionSupport(mission.Waylines), "auto reroute is not supported by drone model 67")
+	cfg.AutoRerouteInfo = nil
+	assert.EqualError(t, v.ValidateMissionSupport(mission.Waylines), "waypoint 0 action 0: action searchlight is not supported by drone model 67")
+	assert.ErrorIs(t, v.ValidateMissionSupport(nil), ErrWaylineDocumentCannotBeNil)
+}
@@ -0,0 +1,369 @@
+package wpml
+
+import (
+	"fmt"
+	"math"
+)
+
+// Survey planning constants.
+const (
+	// ImageFormatVisible is the WPML image format of visible light photos (spelled as in WPML).
+	ImageFormatVisible = "visable"
+	// ShootTypeDistance triggers survey photos at equal distances.
+	ShootTypeDistance = "distance"
+	// GimbalPitchNadir is the gimbal pitch in degrees of a camera pointing straight down.
+	GimbalPitchNadir = -90.0
+
+	// maxSurveySpeed is the fastest survey flight speed in meters per second.
+	maxSurveySpeed = 15.0
+	// minSurveyAltitude and maxSurveyAltitude bound survey altitudes in meters.
+	minSurveyAltitude = 5.0
+	maxSurveyAltitude = 1500.0
+)
+
+// SurveyParams holds the aircraft, camera and capture settings shared by mapping mission planners.
+type SurveyParams struct {
+	DroneModel           DroneModel      `json:"drone_model" validate:"required,drone_model"`
+	PayloadModel         PayloadModel    `json:"payload_model" validate:"required,payload_model"`
+	PayloadPositionIndex PayloadPosition `json:"payload_position_index,omitempty" validate:"payload_position"`
+	// Camera overrides the built-in camera of the payload model
+	Camera *Camera `json:"camera,omitempty" validate:"omitempty"`
+	// Altitude is the flight height in meters above the take-off point;
+	// when zero it is derived from GSD
+	Altitude float64 `json:"altitude,omitempty" validate:"omitempty,min=5,max=1500"`
+	// GSD is the ground sample distance in centimeters per pixel
+	GSD float64 `json:"gsd,omitempty" validate:"omitempty,gt=0"`
+	// FrontOverlap and SideOverlap are the photo overlaps along and across survey lines in percent
+	FrontOverlap int `json:"front_overlap" validate:"min=10,max=95"`
+	SideOverlap  int `json:"side_overlap" validate:"min=10,max=95"`
+	// Speed is the survey flight speed in meters per second; when zero the
+	// fastest speed the camera can keep up with is used
+	Speed float64 `json:"speed,omitempty" validate:"omitempty,min=1,max=15"`
+
+	FinishAction            FinishAction `json:"finish_action,omitempty"`
+	TakeOffSecurityHeight   float64      `json:"take_off_security_height,omitempty" validate:"omitempty,min=20,max=200"`
+	GlobalTransitionalSpeed float64      `json:"global_transitional_speed,omitempty" validate:"omitempty,min=1,max=15"`
+	GlobalRTHHeight         float64      `json:"global_rth_height,omitempty" validate:"omitempty,min=20,max=1500"`
+}
+
+// SurveyPlan is a planned mapping mission with the capture figures it was planned for.
+type SurveyPlan struct {
+	Mission *Mission `json:"-"`
+	// Altitude is the flight height in meters above the take-off point
+	Altitude float64 `json:"altitude"`
+	// GSD is the ground sample distance in centimeters per pixel
+	GSD float64 `json:"gsd"`
+	// LineSpacing is the distance in meters between adjacent survey lines
+	LineSpacing float64 `json:"line_spacing"`
+	// PhotoSpacing is the distance in meters between photos along a line
+	PhotoSpacing float64 `json:"photo_spacing"`
+	// Speed is the survey flight speed in meters per second
+	Speed float64 `json:"speed"`
+	// Lines is the number of survey lines
+	Lines int `json:"lines"`
+	// Photos is the expected number of photos
+	Photos int `json:"photos"`
+	// Distance is the length of the waylines in meters
+	Distance float64 `json:"distance"`
+	// Duration is the flight time along the waylines in seconds
+	Duration float64 `json:"duration"`
+	// Battery tells whether the mission can be flown on one battery
+	Battery *BatteryEstimate `json:"battery,omitempty"`
+}
+
+// surveySettings are the capture settings derived from SurveyParams.
+type surveySettings struct {
+	camera          *Camera
+	altitude        float64
+	gsd             float64
+	footprintWidth  float64
+	footprintHeight float64
+	lineSpacing     float64
+	photoSpacing    float64
+	speed           float64
+}
+
+// surveyPass is a continuous run of photos along a line through points.
+type surveyPass struct {
+	points       []Coordinate
+	gimbalPitch  float64
+	photoSpacing float64
+	speed        float64
+	// heading is the fixed aircraft heading in degrees clockwise from north,
+	// or nil to follow the wayline
+	heading *float64
+}
+
+// pass creates a survey pass through points at the settings' photo spacing and speed.
+func (s *surveySettings) pass(points []Coordinate, gimbalPitch float64, heading *float64) surveyPass {
+	return surveyPass{
+		points:       points,
+		gimbalPitch:  gimbalPitch,
+		photoSpacing: s.photoSpacing,
+		speed:        s.speed,
+		heading:      heading,
+	}
+}
+
+// Validate validates the survey parameters using the WPML validator.
+func (p *SurveyParams) Validate() error {
+	validator, err := NewValidator()
+	if err != nil {
+		return err
+	}
+	return validator.ValidateStruct(p)
+}
+
+// settings derives the altitude, footprint, spacings and speed of the survey.
+func (p *SurveyParams) settings() (*surveySettings, error) {
+	camera := p.Camera
+	if camera == nil {
+		var ok bool
+		if camera, ok = CameraForPayload(p.PayloadModel); !ok {
+			return nil, fmt.Errorf(ErrUnknownPayloadCamera, p.PayloadModel)
+		}
+	}
+
+	altitude := p.Altitude
+	if altitude == 0 {
+		if p.GSD == 0 {
+			return nil, ErrSurveyHeightRequired
+		}
+		altitude = camera.AltitudeForGSD(p.GSD)
+	}
+	if altitude < minSurveyAltitude || altitude > maxSurveyAltitude {
+		return nil, fmt.Errorf(ErrSurveyAltitudeOutOfRange, altitude, minSurveyAltitude, maxSurveyAltitude)
+	}
+
+	width, height := camera.Footprint(altitude)
+	s := &surveySettings{
+		camera:          camera,
+		altitude:        altitude,
+		gsd:             camera.GSD(altitude),
+		footprintWidth:  width,
+		footprintHeight: height,
+		lineSpacing:     width * float64(100-p.SideOverlap) / 100,
+		photoSpacing:    height * float64(100-p.FrontOverlap) / 100,
+	}
+	if err := s.setSpeed(p.Speed); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// setSpeed sets the survey speed, or the fastest speed the camera can keep
+// up with at the photo spacing when speed is zero.
+func (s *surveySettings) setSpeed(speed float64) error {
+	maxSpeed := maxSurveySpeed
+	if s.camera.MinShootInterval > 0 {
+		maxSpeed = math.Min(maxSpeed, s.photoSpacing/s.camera.MinShootInterval)
+	}
+	s.speed = speed
+	if s.speed == 0 {
+		s.speed = maxSpeed
+	}
+	if s.speed > maxSpeed || s.speed < 1 {
+		return fmt.Errorf(ErrSurveySpeedTooHigh, s.speed, maxSpeed)
+	}
+	return nil
+}
+
+// surveyLines covers a polygon with parallel lines along course, spaced
+// spacing meters apart and alternating in direction, each extended by
+// extension meters beyond the polygon at both ends.
+func surveyLines(polygon []point, course, spacing, extension float64) [][2]point {
+	track := newTrackFrame(course)
+	minCross, maxCross := track.crossExtent(polygon)
+	width := maxCross - minCross
+
+	// Lines are centered on the polygon so both sides get equal coverage
+	count := max(1, int(math.Ceil(width/spacing)))
+	first := minCross + (width-float64(count-1)*spacing)/2
+
+	lines := make([][2]point, 0, count)
+	for i := 0; i < count; i++ {
+		cross := first + float64(i)*spacing
+		minAlong, maxAlong, ok := track.scanPolygon(polygon, cross)
+		if !ok {
+			continue
+		}
+		start := track.fromTrack(minAlong-extension, cross)
+		end := track.fromTrack(maxAlong+extension, cross)
+		if len(lines)%2 == 1 {
+			start, end = end, start
+		}
+		lines = append(lines, [2]point{start, end})
+	}
+	return lines
+}
+
+// missionConfig creates the mission configuration of a survey taking off towards first.
+func (p *SurveyParams) missionConfig(first Coordinate, altitude float64) (*MissionConfig, error) {
+	return convertToMissionConfig(&Waylines{
+		DroneModel:              p.DroneModel,
+		PayloadModel:            p.PayloadModel,
+		PayloadPositionIndex:    p.PayloadPositionIndex,
+		FinishAction:            p.FinishAction,
+		TakeOffSecurityHeight:   p.TakeOffSecurityHeight,
+		GlobalTransitionalSpeed: p.GlobalTransitionalSpeed,
+		GlobalRTHHeight:         p.GlobalRTHHeight,
+		Waypoints: []WaylinesWaypoint{
+			{Latitude: first.Latitude, Longitude: first.Longitude, Height: altitude},
+		},
+	})
+}
+
+// surveyTemplateFolder creates the template folder fields shared by mapping templates.
+func (p *SurveyParams) surveyTemplateFolder(templateType TemplateType, s *surveySettings) *TemplateFolder {
+	return &TemplateFolder{
+		TemplateType:    templateType,
+		TemplateID:      0,
+		AutoFlightSpeed: s.speed,
+		WaylineCoordinateSysParam: &WaylineCoordinateSysParam{
+			CoordinateMode:    CoordinateModeWGS84,
+			HeightMode:        HeightModeRelativeToStartPoint,
+			GlobalShootHeight: float64Ptr(s.altitude),
+		},
+		PayloadParam: &PayloadParam{
+			PayloadPositionIndex: int(p.PayloadPositionIndex),
+			ImageFormat:          ImageFormatVisible,
+		},
+		ShootType:               stringPtr(ShootTypeDistance),
+		EllipsoidHeight:         float64Ptr(s.altitude),
+		Height:                  float64Ptr(s.altitude),
+		ElevationOptimizeEnable: intPtr(0),
+		Overlap: &Overlap{
+			OrthoCameraOverlapH: intPtr(p.FrontOverlap),
+			OrthoCameraOverlapW: intPtr(p.SideOverlap),
+		},
+		MappingHeadingParam: &MappingHeadingParam{MappingHeadingMode: HeadingModeFollowWayline},
+	}
+}
+
+// buildSurveyMission creates a mission flying passes at the survey altitude,
+// taking photos every photoSpacing meters along each pass.
+func (p *SurveyParams) buildSurveyMission(template *TemplateFolder, passes []surveyPass, s *surveySettings) (*SurveyPlan, error) {
+	if len(passes) == 0 {
+		return nil, ErrSurveyAreaTooSmall
+	}
+
+	missionConfig, err := p.missionConfig(passes[0].points[0], s.altitude)
+	if err != nil {
+		return nil, fmt.Errorf(ErrConvertMissionConfig, err)
+	}
+
+	var placemarks []Placemark
+	groupID := 0
+	gimbalPitch := math.NaN()
+	for _, pass := range passes {
+		start := len(placemarks)
+		for _, c := range pass.points {
+			placemarks = append(placemarks, *p.surveyPlacemark(c, len(placemarks), s.altitude, &pass))
+		}
+		if pass.gimbalPitch != gimbalPitch {
+			gimbalPitch = pass.gimbalPitch
+			placemarks[start].AddActionGroup(p.gimbalActionGroup(groupID, start, gimbalPitch))
+			groupID++
+		}
+		placemarks[start].AddActionGroup(p.photoActionGroup(groupID, start, len(placemarks)-1, pass.photoSpacing))
+		groupID++
+	}
+
+	mission := NewMission()
+	mission.SetAuthor(DefaultAuthor)
+	mission.UpdateTimestamp()
+	mission.SetMissionConfig(*missionConfig)
+	mission.Template.Document.Folders = []TemplateFolder{*template}
+	mission.Waylines.Document.Folders = []WaylineFolder{{
+		TemplateID:        0,
+		WaylineID:         0,
+		AutoFlightSpeed:   s.speed,
+		ExecuteHeightMode: ExecuteHeightModeRelativeToStartPoint,
+		Placemarks:        placemarks,
+	}}
+
+	estimate, err := EstimateMission(mission)
+	if err != nil {
+		return nil, err
+	}
+	mission.SetEstimate(estimate)
+
+	return &SurveyPlan{
+		Mission:      mission,
+		Altitude:     s.altitude,
+		GSD:          s.gsd,
+		LineSpacing:  s.lineSpacing,
+		PhotoSpacing: s.photoSpacing,
+		Speed:        s.speed,
+		Lines:        len(passes),
+		Photos:       estimate.Photos,
+		Distance:     estimate.Distance,
+		Duration:     estimate.Duration,
+		Battery:      estimate.Battery,
+	}, nil
+}
+
+// surveyPlacemark creates a wayline placemark of a pass at the survey altitude.
+func (p *SurveyParams) surveyPlacemark(c Coordinate, index int, altitude float64, pass *surveyPass) *Placemark {
+	heading := &WaypointHeadingParam{
+		WaypointHeadingMode:     HeadingModeFollowWayline,
+		WaypointHeadingPathMode: HeadingPathModeFollowBadArc,
+	}
+	if pass.heading != nil {
+		// WPML headings run from -180 to 180 degrees
+		angle := math.Mod(*pass.heading+540, 360) - 180
+		heading.WaypointHeadingMode = HeadingModeSmoothTransition
+		heading.WaypointHeadingAngle = float64Ptr(angle)
+		heading.WaypointHeadingAngleEnable = intPtr(1)
+	}
+	return &Placemark{
+		Point:                &Point{Coordinates: formatCoordinates(c.Longitude, c.Latitude)},
+		Index:                index,
+		ExecuteHeight:        float64Ptr(altitude),
+		WaypointSpeed:        float64Ptr(pass.speed),
+		WaypointHeadingParam: heading,
+		WaypointTurnParam: &WaypointTurnParam{
+			WaypointTurnMode:        TurnModeToPointAndStopWithDiscontinuityCurvature,
+			WaypointTurnDampingDist: float64Ptr(0),
+		},
+		UseStraightLine:  intPtr(1),
+		IsRisky:          intPtr(0),
+		WaypointWorkType: intPtr(0),
+	}
+}
+
+// gimbalActionGroup points the camera at the given pitch on reaching a waypoint.
+func (p *SurveyParams) gimbalActionGroup(id, index int, pitch float64) ActionGroup {
+	group := NewActionGroup(id, index, index)
+	group.SetTrigger(TriggerTypeReachPoint, nil)
+	group.AddAction(Action{
+		ActionID:           0,
+		ActionActuatorFunc: ActionTypeGimbalRotate,
+		ActionActuatorFuncParam: convertActionParams(ActionRequest{
+			Type: ActionTypeGimbalRotate,
+			Action: &GimbalRotateAction{
+				PayloadPositionIndex:    p.PayloadPositionIndex,
+				GimbalHeadingYawBase:    "aircraft",
+				GimbalRotateMode:        "absoluteAngle",
+				GimbalPitchRotateEnable: true,
+				GimbalPitchRotateAngle:  pitch,
+			},
+		}),
+	})
+	return *group
+}
+
+// photoActionGroup takes a photo every spacing meters between two waypoints.
+func (p *SurveyParams) photoActionGroup(id, startIndex, endIndex int, spacing float64) ActionGroup {
+	group := NewActionGroup(id, startIndex, endIndex)
+	group.SetTrigger(TriggerTypeMultipleDistance, float64Ptr(math.Round(spacing*10)/10))
+	group.AddAction(Action{
+		ActionID:           0,
+		ActionActuatorFunc: ActionTypeTakePhoto,
+		ActionActuatorFuncParam: convertActionParams(ActionRequest{
+			Type:   ActionTypeTakePhoto,
+			Action: &TakePhotoAction{PayloadPositionIndex: p.PayloadPositionIndex, UseGlobalPayloadLensIndex: true},
+		}),
+	})
+	return *group
+}
@@ -0,0 +1,209 @@
+package wpml
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/utmos/utmos/pkg/terrain"
+)
+
+// clearanceSampleSpacing is the distance in meters between the points of a
+// leg checked for terrain clearance, finer than common DEM resolutions.
+const clearanceSampleSpacing = 10.0
+
+// TerrainModel holds the elevation and geoid models used to resolve waypoint
+// heights against the terrain. Either may be nil when not needed.
+type TerrainModel struct {
+	DEM   terrain.DEM
+	Geoid terrain.Geoid
+}
+
+// ClearanceViolation is a leg of a wayline that passes closer to the terrain
+// than the required clearance.
+type ClearanceViolation struct {
+	WaylineID int `json:"wayline_id"`
+	// WaypointIndex is the index of the waypoint starting the leg, which
+	// ends before the next waypoint; the last waypoint is a leg of its own
+	WaypointIndex int `json:"waypoint_index"`
+	// Position is where the leg comes closest to the terrain
+	Position Coordinate `json:"position"`
+	// Height and Terrain are the EGM96 heights in meters of the aircraft and the ground there
+	Height  float64 `json:"height"`
+	Terrain float64 `json:"terrain"`
+	// Clearance is the height in meters of the aircraft above the ground
+	Clearance float64 `json:"clearance"`
+}
+
+// Apply resolves the waypoint heights of waylines against the terrain so the
+// converter can fly them. Heights above ground level become heights above
+// the take-off point, so the aircraft keeps a constant height above the
+// ground at every waypoint. EGM96 heights get their WGS84 ellipsoid heights,
+// which the aircraft flies. Other height modes are left as they are.
+func (t *TerrainModel) Apply(waylines *Waylines) error {
+	switch waylines.HeightType {
+	case HeightModeAboveGroundLevel:
+		return t.applyAboveGroundLevel(waylines)
+	case HeightModeEGM96:
+		return t.applyGeoid(waylines)
+	default:
+		return nil
+	}
+}
+
+func (t *TerrainModel) applyAboveGroundLevel(waylines *Waylines) error {
+	if t.DEM == nil {
+		return ErrTerrainDEMRequired
+	}
+	if len(waylines.Waypoints) == 0 {
+		return nil
+	}
+	takeOff := Coordinate{Latitude: waylines.Waypoints[0].Latitude, Longitude: waylines.Waypoints[0].Longitude}
+	if waylines.TakeOffRefPointLatitude != 0 && waylines.TakeOffRefPointLongitude != 0 {
+		takeOff = Coordinate{Latitude: waylines.TakeOffRefPointLatitude, Longitude: waylines.TakeOffRefPointLongitude}
+	}
+	ground, err := t.DEM.Elevation(takeOff.Latitude, takeOff.Longitude)
+	if err != nil {
+		return fmt.Errorf(ErrTerrainTakeOff, err)
+	}
+
+	heights := make([]float64, len(waylines.Waypoints))
+	for i := range waylines.Waypoints {
+		wp := &waylines.Waypoints[i]
+		elevation, err := t.DEM.Elevation(wp.Latitude, wp.Longitude)
+		if err != nil {
+			return fmt.Errorf(ErrTerrainWaypoint, i, err)
+		}
+		heights[i] = wp.Height + elevation - ground
+		if heights[i] < 5 || heights[i] > 500 {
+			return fmt.Errorf(ErrTerrainHeightOutOfRange, i, heights[i])
+		}
+	}
+	for i := range waylines.Waypoints {
+		waylines.Waypoints[i].Height = heights[i]
+	}
+	waylines.HeightType = HeightModeRelativeToStartPoint
+	return nil
+}
+
+func (t *TerrainModel) applyGeoid(waylines *Waylines) error {
+	if t.Geoid == nil {
+		return ErrTerrainGeoidRequired
+	}
+	for i := range waylines.Waypoints {
+		wp := &waylines.Waypoints[i]
+		height, err := terrain.EllipsoidHeight(t.Geoid, wp.Latitude, wp.Longitude, wp.Height)
+		if err != nil {
+			return fmt.Errorf(ErrTerrainWaypoint, i, err)
+		}
+		wp.EllipsoidHeight = float64Ptr(height)
+	}
+	return nil
+}
+
+// CheckClearance checks that the legs of every wayline of a mission stay at
+// least minClearance meters above the terrain, sampling each leg every few
+// meters, and returns the closest point of each leg that does not. Heights
+// relative to the take-off point are measured from the ground there; WGS84
+// heights need the geoid.
+func (t *TerrainModel) CheckClearance(mission *Mission, minClearance float64) ([]ClearanceViolation, error) {
+	if mission == nil {
+		return nil, ErrMissionCannotBeEmpty
+	}
+	if mission.Waylines == nil {
+		return nil, ErrMissionWaylinesCannotBeEmpty
+	}
+	if t.DEM == nil {
+		return nil, ErrTerrainDEMRequired
+	}
+
+	var takeOffRef *string
+	if mission.Template != nil {
+		takeOffRef = mission.Template.Document.MissionConfig.TakeOffRefPoint
+	}
+	takeOff, _, hasTakeOff := parseTakeOffRefPoint(takeOffRef)
+
+	var violations []ClearanceViolation
+	for i := range mission.Waylines.Document.Folders {
+		folder := &mission.Waylines.Document.Folders[i]
+		waypoints, err := estimateWaypoints(folder, 0, 1)
+		if err != nil {
+			return nil, fmt.Errorf(ErrTerrainWayline, folder.WaylineID, err)
+		}
+		if len(waypoints) == 0 {
+			continue
+		}
+		if !hasTakeOff {
+			takeOff, hasTakeOff = waypoints[0].position, true
+		}
+		height, err := t.heightFunc(folder.ExecuteHeightMode, takeOff)
+		if err != nil {
+			return nil, fmt.Errorf(ErrTerrainWayline, folder.WaylineID, err)
+		}
+		found, err := t.checkWayline(folder.WaylineID, waypoints, height, minClearance)
+		if err != nil {
+			return nil, fmt.Errorf(ErrTerrainWayline, folder.WaylineID, err)
+		}
+		violations = append(violations, found...)
+	}
+	return violations, nil
+}
+
+// heightFunc returns the function converting waypoint heights of an execute
+// height mode to EGM96 heights at a position.
+func (t *TerrainModel) heightFunc(mode ExecuteHeightMode, takeOff Coordinate) (func(c Coordinate, h float64) (float64, error), error) {
+	switch mode {
+	case ExecuteHeightModeWGS84:
+		if t.Geoid == nil {
+			return nil, ErrTerrainGeoidRequired
+		}
+		return func(c Coordinate, h float64) (float64, error) {
+			return terrain.OrthometricHeight(t.Geoid, c.Latitude, c.Longitude, h)
+		}, nil
+	case ExecuteHeightModeRealTimeFollowSurface:
+		return func(c Coordinate, h float64) (float64, error) {
+			ground, err := t.DEM.Elevation(c.Latitude, c.Longitude)
+			return ground + h, err
+		}, nil
+	default:
+		ground, err := t.DEM.Elevation(takeOff.Latitude, takeOff.Longitude)
+		if err != nil {
+			return nil, fmt.Errorf(ErrTerrainTakeOff, err)
+		}
+		return func(_ Coordinate, h float64) (float64, error) {
+			return ground + h, nil
+		}, nil
+	}
+}
+
+// checkWayline samples the legs of a wayline and returns the closest point
+// to the terrain of each leg below the clearance.
+func (t *TerrainModel) checkWayline(waylineID int, waypoints []estimateWaypoint, height func(Coordinate, float64) (float64, error), minClearance float64) ([]ClearanceViolation, error) {
+	var violations []ClearanceViolation
+	for i := range waypoints {
+		from, to := waypoints[i], waypoints[min(i+1, len(waypoints)-1)]
+		samples := max(int(math.Ceil(Distance(from.position, to.position)/clearanceSampleSpacing)), 1)
+		closest := ClearanceViolation{WaylineID: waylineID, WaypointIndex: i, Clearance: math.Inf(1)}
+		for s := 0; s < samples; s++ {
+			f := float64(s) / float64(samples)
+			position := Coordinate{
+				Longitude: from.position.Longitude + (to.position.Longitude-from.position.Longitude)*f,
+				Latitude:  from.position.Latitude + (to.position.Latitude-from.position.Latitude)*f,
+			}
+			aircraft, err := height(position, from.height+(to.height-from.height)*f)
+			if err != nil {
+				return nil, fmt.Errorf(ErrTerrainWaypoint, i, err)
+			}
+			ground, err := t.DEM.Elevation(position.Latitude, position.Longitude)
+			if err != nil {
+				return nil, fmt.Errorf(ErrTerrainWaypoint, i, err)
+			}
+			if aircraft-ground < closest.Clearance {
+				closest.Position, closest.Height, closest.Terrain, closest.Clearance = position, aircraft, ground, aircraft-ground
+			}
+		}
+		if closest.Clearance < minClearance {
+			violations = append(violations, closest)
+		}
+	}
+	return violations, nil
+}
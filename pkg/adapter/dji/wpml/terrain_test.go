@@ -0,0 +1,147 @@
+package wpml
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/utmos/utmos/pkg/terrain"
+)
+
+// slopeDEM is terrain 100 m high at the origin of the estimate waylines,
+// rising eastwards by grade meters per meter.
+type slopeDEM struct {
+	grade float64
+}
+
+func (d slopeDEM) Elevation(lat, lon float64) (float64, error) {
+	origin := Coordinate{116.4074, 39.9042}
+	if math.Abs(lat-origin.Latitude) > 1 || math.Abs(lon-origin.Longitude) > 1 {
+		return 0, terrain.ErrOutOfCoverage
+	}
+	frame := localFrame{origin: origin, cosLat: math.Cos(origin.Latitude * math.Pi / 180)}
+	return 100 + d.grade*frame.toPoint(Coordinate{Longitude: lon, Latitude: lat}).x, nil
+}
+
+// constantGeoid places the geoid a fixed height above the ellipsoid.
+type constantGeoid float64
+
+func (g constantGeoid) Undulation(_, _ float64) (float64, error) {
+	return float64(g), nil
+}
+
+func TestTerrainModel_ApplyAboveGroundLevel(t *testing.T) {
+	model := &TerrainModel{DEM: slopeDEM{grade: 0.3}}
+	waylines := testEstimateWaylines()
+	waylines.HeightType = HeightModeAboveGroundLevel
+
+	require.NoError(t, model.Apply(waylines))
+	assert.Equal(t, HeightModeRelativeToStartPoint, waylines.HeightType)
+	assert.InDelta(t, 50, waylines.Waypoints[0].Height, 1e-6)
+	assert.InDelta(t, 80, waylines.Waypoints[1].Height, 1e-6)
+	assert.InDelta(t, 80, waylines.Waypoints[2].Height, 1e-6)
+
+	// The aircraft stays 50 m above the slope all along the legs
+	mission, err := ConvertWaylinesToMission(waylines)
+	require.NoError(t, err)
+	violations, err := model.CheckClearance(mission, 49.9)
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+
+	steep := testEstimateWaylines()
+	steep.HeightType = HeightModeAboveGroundLevel
+	err = (&TerrainModel{DEM: slopeDEM{grade: 5}}).Apply(steep)
+	assert.ErrorContains(t, err, "waypoint 1 is 550.0 m above the take-off point")
+	assert.InDelta(t, 50, steep.Waypoints[1].Height, 1e-9)
+}
+
+func TestTerrainModel_ApplyGeoid(t *testing.T) {
+	model := &TerrainModel{DEM: slopeDEM{grade: 0.3}, Geoid: constantGeoid(-10)}
+	waylines := testEstimateWaylines()
+	waylines.HeightType = HeightModeEGM96
+	for i := range waylines.Waypoints {
+		waylines.Waypoints[i].Height = 160
+	}
+
+	require.NoError(t, model.Apply(waylines))
+	require.NotNil(t, waylines.Waypoints[0].EllipsoidHeight)
+	assert.InDelta(t, 150, *waylines.Waypoints[0].EllipsoidHeight, 1e-9)
+
+	mission, err := ConvertWaylinesToMission(waylines)
+	require.NoError(t, err)
+	templateFolder := mission.Template.Document.Folders[0]
+	assert.Equal(t, HeightModeEGM96, templateFolder.WaylineCoordinateSysParam.HeightMode)
+	assert.InDelta(t, 160, *templateFolder.Placemarks[0].Height, 1e-9)
+	assert.InDelta(t, 150, *templateFolder.Placemarks[0].EllipsoidHeight, 1e-9)
+	waylineFolder := mission.Waylines.Document.Folders[0]
+	assert.Equal(t, ExecuteHeightModeWGS84, waylineFolder.ExecuteHeightMode)
+	assert.InDelta(t, 150, *waylineFolder.Placemarks[0].ExecuteHeight, 1e-9)
+
+	// 160 m above the geoid passes 33 m over the slope 10 m before the
+	// corner and 30 m over the rest of the route
+	violations, err := model.CheckClearance(mission, 35)
+	require.NoError(t, err)
+	require.Len(t, violations, 3)
+	assert.Equal(t, 0, violations[0].WaypointIndex)
+	assert.InDelta(t, 33, violations[0].Clearance, 1e-6)
+	assert.InDelta(t, 160, violations[0].Height, 1e-6)
+	assert.InDelta(t, 127, violations[0].Terrain, 1e-6)
+	assert.Equal(t, 2, violations[2].WaypointIndex)
+	assert.InDelta(t, 30, violations[2].Clearance, 1e-6)
+
+	_, err = (&TerrainModel{DEM: model.DEM}).CheckClearance(mission, 35)
+	assert.ErrorIs(t, err, ErrTerrainGeoidRequired)
+}
+
+func TestTerrainModel_CheckClearance(t *testing.T) {
+	model := &TerrainModel{DEM: slopeDEM{grade: 0.3}}
+	mission, err := ConvertWaylinesToMission(testEstimateWaylines())
+	require.NoError(t, err)
+
+	// 50 m above the take-off point clears the slope by 20 m at the corner
+	violations, err := model.CheckClearance(mission, 10)
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+
+	violations, err = model.CheckClearance(mission, 25)
+	require.NoError(t, err)
+	require.Len(t, violations, 3)
+	assert.Equal(t, 0, violations[0].WaylineID)
+	assert.InDelta(t, 23, violations[0].Clearance, 1e-6)
+	assert.InDelta(t, 90, Distance(Coordinate{116.4074, 39.9042}, violations[0].Position), 0.1)
+	assert.Equal(t, 1, violations[1].WaypointIndex)
+	assert.InDelta(t, 20, violations[1].Clearance, 1e-6)
+
+	follow := testEstimateWaylines()
+	follow.HeightType = HeightModeRealTimeFollowSurface
+	mission, err = ConvertWaylinesToMission(follow)
+	require.NoError(t, err)
+	violations, err = model.CheckClearance(mission, 60)
+	require.NoError(t, err)
+	require.Len(t, violations, 3)
+	assert.InDelta(t, 50, violations[1].Clearance, 1e-6)
+}
+
+func TestTerrainModel_Errors(t *testing.T) {
+	waylines := testEstimateWaylines()
+	waylines.HeightType = HeightModeAboveGroundLevel
+	assert.ErrorIs(t, (&TerrainModel{}).Apply(waylines), ErrTerrainDEMRequired)
+	waylines.HeightType = HeightModeEGM96
+	assert.ErrorIs(t, (&TerrainModel{}).Apply(waylines), ErrTerrainGeoidRequired)
+	waylines.HeightType = HeightModeRelativeToStartPoint
+	assert.NoError(t, (&TerrainModel{}).Apply(waylines))
+
+	waylines = testEstimateWaylines()
+	waylines.HeightType = HeightModeAboveGroundLevel
+	waylines.TakeOffRefPointLatitude, waylines.TakeOffRefPointLongitude = 10, 10
+	assert.ErrorIs(t, (&TerrainModel{DEM: slopeDEM{}}).Apply(waylines), terrain.ErrOutOfCoverage)
+
+	mission, err := ConvertWaylinesToMission(testEstimateWaylines())
+	require.NoError(t, err)
+	_, err = (&TerrainModel{}).CheckClearance(mission, 10)
+	assert.ErrorIs(t, err, ErrTerrainDEMRequired)
+	_, err = (&TerrainModel{DEM: slopeDEM{}}).CheckClearance(nil, 10)
+	assert.ErrorIs(t, err, ErrMissionCannotBeEmpty)
+}
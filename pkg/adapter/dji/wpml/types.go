@@ -140,6 +140,20 @@ const (
 	PayloadX900 PayloadModel = 2014
 )
 
+// Payload lens constants, listed in payloadLensIndex and imageFormat values.
+const (
+	// LensWide is the wide-angle visible light lens.
+	LensWide = "wide"
+	// LensZoom is the zoom lens.
+	LensZoom = "zoom"
+	// LensIR is the infrared thermal lens.
+	LensIR = "ir"
+	// LensNarrowBand is the narrow-band multispectral lens.
+	LensNarrowBand = "narrow_band"
+	// LensVisible is the visible light image of multispectral payloads (spelled as in WPML).
+	LensVisible = "visable"
+)
+
 // FlightMode represents the flight mode for flying to a wayline.
 type FlightMode string
 
@@ -12,6 +12,7 @@ import (
 // Validator provides validation for WPML structures using custom validation rules.
 type Validator struct {
 	validator *validator.Validate
+	geofences []Geofence
 }
 
 // NewValidator creates a new Validator with all custom validation rules registered.
@@ -35,6 +36,7 @@ func (w *Validator) registerCustomValidators() error {
 		{"drone_model", w.validateDroneModel},
 		{"payload_model", w.validatePayloadModel},
 		{"action_type", w.validateActionType},
+		{"lens_index", w.validateLensIndex},
 		{"required_for_drone", w.validateRequiredForDrone},
 		{"required_for_payload", w.validateRequiredForPayload},
 	}
@@ -115,38 +117,8 @@ func (w *Validator) validatePayloadModel(fl validator.FieldLevel) bool {
 }
 
 func (w *Validator) validateActionType(fl validator.FieldLevel) bool {
-	value := fl.Field().String()
-
-	validActionTypes := []string{
-		ActionTypeTakePhoto,
-		ActionTypeStartRecord,
-		ActionTypeStopRecord,
-		ActionTypeFocus,
-		ActionTypeZoom,
-		ActionTypeCustomDirName,
-		ActionTypeGimbalRotate,
-		ActionTypeRotateYaw,
-		ActionTypeHover,
-		ActionTypeGimbalEvenlyRotate,
-		ActionTypeOrientedShoot,
-		ActionTypePanoShot,
-		ActionTypeRecordPointCloud,
-		ActionTypeAccurateShoot,
-		ActionTypeGimbalAngleLock,
-		ActionTypeGimbalAngleUnlock,
-		ActionTypeStartSmartOblique,
-		ActionTypeStartTimeLapse,
-		ActionTypeStopTimeLapse,
-		ActionTypeSetFocusType,
-		ActionTypeTargetDetection,
-	}
-
-	for _, validType := range validActionTypes {
-		if value == validType {
-			return true
-		}
-	}
-	return false
+	_, ok := actionFactories[fl.Field().String()]
+	return ok
 }
 
 func (w *Validator) validateRequiredForDrone(_ validator.FieldLevel) bool {
@@ -208,6 +180,30 @@ func (w *Validator) ValidateWaylinesDocument(waylineDoc *WaylinesDocument) error
 	return nil
 }
 
+// SetGeofences sets the no-fly zones and geofences ValidateGeofences checks missions against.
+func (w *Validator) SetGeofences(geofences []Geofence) error {
+	for i := range geofences {
+		if err := geofences[i].validate(); err != nil {
+			return err
+		}
+	}
+	w.geofences = geofences
+	return nil
+}
+
+// ValidateGeofences checks every leg of a mission against the configured geofences,
+// returning a *GeofenceError listing the legs entering no-fly zones or leaving geofences.
+func (w *Validator) ValidateGeofences(mission *Mission) error {
+	violations, err := CheckGeofences(mission, w.geofences)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &GeofenceError{Violations: violations}
+	}
+	return nil
+}
+
 // ValidateTemplateDocument validates a TemplateDocument, returning an error if it is nil or fails validation.
 func (w *Validator) ValidateTemplateDocument(template *TemplateDocument) error {
 	if template == nil {
@@ -60,6 +60,10 @@ type ServiceCall struct {
 	Method   string         `json:"method"`
 	Params   map[string]any `json:"params,omitempty"`
 	Timeout  int64          `json:"timeout,omitempty"` // Timeout in milliseconds
+	// TID and BID identify the message sent to the device, and are
+	// generated when empty. Replies carry the same TID.
+	TID string `json:"tid,omitempty"`
+	BID string `json:"bid,omitempty"`
 }
 
 // DispatchResult represents the result of a service call dispatch.
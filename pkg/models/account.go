@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User is an account signing in to iot-api with a password. Users with a
+// WorkspaceID are confined to that workspace. TokenVersion is embedded in
+// issued tokens; bumping it revokes every token issued before.
+type User struct {
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	Username     string         `gorm:"uniqueIndex;size:100;not null" json:"username"`
+	PasswordHash string         `gorm:"size:100;not null" json:"-"`
+	Role         string         `gorm:"size:20;not null" json:"role"`
+	WorkspaceID  string         `gorm:"index;size:64" json:"workspace_id,omitempty"`
+	TokenVersion int            `gorm:"not null;default:0" json:"-"`
+	Disabled     bool           `gorm:"not null;default:false" json:"disabled"`
+	ID           uint           `gorm:"primaryKey" json:"id"`
+}
+
+// TableName returns the table name for the User model.
+func (User) TableName() string {
+	return "users"
+}
+
+// APIKey is a managed API key. Only a SHA-256 hash of the key is stored;
+// Prefix keeps its first characters so it can be recognised in listings.
+// Keys with a WorkspaceID are confined to that workspace.
+type APIKey struct {
+	ExpiresAt   *time.Time     `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time     `json:"last_used_at,omitempty"`
+	RevokedAt   *time.Time     `json:"revoked_at,omitempty"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	Name        string         `gorm:"size:200;not null" json:"name"`
+	KeyHash     string         `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	Prefix      string         `gorm:"size:16;not null" json:"prefix"`
+	Role        string         `gorm:"size:20;not null" json:"role"`
+	WorkspaceID string         `gorm:"index;size:64" json:"workspace_id,omitempty"`
+	ID          uint           `gorm:"primaryKey" json:"id"`
+}
+
+// TableName returns the table name for the APIKey model.
+func (APIKey) TableName() string {
+	return "api_keys"
+}
@@ -28,9 +28,11 @@ type Device struct {
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
+	WorkspaceID    string         `gorm:"index;size:64" json:"workspace_id,omitempty"`
 	DeviceSN       string         `gorm:"uniqueIndex;size:100;not null" json:"device_sn"`
 	DeviceName     string         `gorm:"size:200;not null" json:"device_name"`
 	DeviceType     string         `gorm:"size:50;not null" json:"device_type"`
+	ModelKey       string         `gorm:"size:50" json:"model_key,omitempty"`
 	Vendor         string         `gorm:"index;size:50;not null;default:'generic'" json:"vendor"`
 	Status         DeviceStatus   `gorm:"size:20;default:'unknown'" json:"status"`
 	ID             uint           `gorm:"primaryKey" json:"id"`
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// FlightArea is a custom flight area of a workspace: a no-fly zone (nfz) or
+// a geofence (dfence) docks keep their aircraft out of or within. Geometry
+// holds a GeoJSON Polygon, or a Point centering a circle of Radius meters.
+// Only enabled areas are enforced by the aircraft.
+type FlightArea struct {
+	Geometry    datatypes.JSON `gorm:"type:jsonb;not null" json:"geometry"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	WorkspaceID string         `gorm:"index;size:64;not null" json:"workspace_id"`
+	Name        string         `gorm:"size:100;not null" json:"name"`
+	Type        string         `gorm:"size:16;not null" json:"type"`
+	Radius      float64        `json:"radius,omitempty"`
+	Enable      bool           `gorm:"not null" json:"enable"`
+	ID          string         `gorm:"primaryKey;size:64" json:"id"`
+}
+
+// TableName returns the table name for the FlightArea model.
+func (FlightArea) TableName() string {
+	return "flight_areas"
+}
+
+// FlightAreaFile is the flight area file last generated for a workspace,
+// which its docks download on flight_areas_get.
+type FlightAreaFile struct {
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	WorkspaceID string    `gorm:"primaryKey;size:64" json:"workspace_id"`
+	Name        string    `gorm:"size:255;not null" json:"name"`
+	ObjectKey   string    `gorm:"size:512;not null" json:"object_key"`
+	// Checksum is the SHA256 of the file content, hex encoded
+	Checksum string `gorm:"size:64;not null" json:"checksum"`
+	Size     int64  `gorm:"not null" json:"size"`
+}
+
+// TableName returns the table name for the FlightAreaFile model.
+func (FlightAreaFile) TableName() string {
+	return "flight_area_files"
+}
+
+// RetiredFlightAreaFile is a flight area file replaced by a newer one. Its
+// object is kept until the download addresses handed out for it expire.
+type RetiredFlightAreaFile struct {
+	RetiredAt   time.Time `gorm:"index;not null" json:"retired_at"`
+	ObjectKey   string    `gorm:"primaryKey;size:512" json:"object_key"`
+	WorkspaceID string    `gorm:"index;size:64;not null" json:"workspace_id"`
+}
+
+// TableName returns the table name for the RetiredFlightAreaFile model.
+func (RetiredFlightAreaFile) TableName() string {
+	return "retired_flight_area_files"
+}
+
+// FlightAreaSync is the progress of a dock synchronizing the flight areas
+// of its workspace, as last reported by flight_areas_sync_progress.
+type FlightAreaSync struct {
+	UpdatedAt   time.Time `json:"updated_at"`
+	WorkspaceID string    `gorm:"index;size:64;not null" json:"workspace_id"`
+	DeviceSN    string    `gorm:"primaryKey;size:100" json:"device_sn"`
+	Status      string    `gorm:"size:32;not null" json:"status"`
+	// Checksum is the checksum of the file the dock was told to fetch
+	Checksum string `gorm:"size:64" json:"checksum"`
+	Reason   int    `json:"reason"`
+}
+
+// TableName returns the table name for the FlightAreaSync model.
+func (FlightAreaSync) TableName() string {
+	return "flight_area_syncs"
+}
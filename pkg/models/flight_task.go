@@ -0,0 +1,117 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FlightTaskType mirrors the DJI flight task type used in flighttask_prepare.
+type FlightTaskType int
+
+const (
+	// FlightTaskTypeImmediate executes the task as soon as it is prepared.
+	FlightTaskTypeImmediate FlightTaskType = 0
+	// FlightTaskTypeTimed executes the task at the given execute_time.
+	FlightTaskTypeTimed FlightTaskType = 1
+	// FlightTaskTypeConditional executes the task once its ready conditions are met.
+	FlightTaskTypeConditional FlightTaskType = 2
+)
+
+// FlightTaskRunStatus represents the outcome of a single scheduled occurrence.
+type FlightTaskRunStatus string
+
+const (
+	// FlightTaskRunStatusDispatched indicates the task was sent to the dock.
+	FlightTaskRunStatusDispatched FlightTaskRunStatus = "dispatched"
+	// FlightTaskRunStatusSkipped indicates the occurrence was skipped by a safety check.
+	FlightTaskRunStatusSkipped FlightTaskRunStatus = "skipped"
+	// FlightTaskRunStatusFailed indicates dispatching the task failed.
+	FlightTaskRunStatusFailed FlightTaskRunStatus = "failed"
+)
+
+// FlightTask represents a one-shot or recurring wayline mission scheduled on a dock.
+// A task with an empty CronExpr runs once at ExecuteTime. Tasks flying a
+// stored wayline reference a revision, whose archive is signed at dispatch;
+// other tasks fly the archive WaylineURL points to. Conditional tasks wait
+// for BatteryCapacity percent of battery, 90 when unset. Occurrences are
+// dispatched on behalf of WorkspaceID, the dock's workspace at creation.
+type FlightTask struct {
+	ExecuteTime        *time.Time     `json:"execute_time,omitempty"`
+	NextRunAt          *time.Time     `gorm:"index" json:"next_run_at,omitempty"`
+	LastRunAt          *time.Time     `json:"last_run_at,omitempty"`
+	RthAltitude        *int           `json:"rth_altitude,omitempty"`
+	BatteryCapacity    *int           `json:"battery_capacity,omitempty"`
+	WaylineID          *uint          `gorm:"index" json:"wayline_id,omitempty"`
+	WaylineRevision    *int           `json:"wayline_revision,omitempty"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	Name               string         `gorm:"size:200;not null" json:"name"`
+	DockSN             string         `gorm:"index;size:100;not null" json:"dock_sn"`
+	WorkspaceID        string         `gorm:"index;size:64;not null;default:''" json:"workspace_id,omitempty"`
+	Vendor             string         `gorm:"size:50;not null;default:'dji'" json:"vendor"`
+	CronExpr           string         `gorm:"size:100" json:"cron_expr,omitempty"`
+	Timezone           string         `gorm:"size:64;not null;default:'UTC'" json:"timezone"`
+	WaylineURL         string         `gorm:"size:1024" json:"wayline_url"`
+	WaylineFingerprint string         `gorm:"size:64" json:"wayline_fingerprint"`
+	TaskType           FlightTaskType `gorm:"not null;default:1" json:"task_type"`
+	OutOfControlAction int            `gorm:"not null;default:0" json:"out_of_control_action"`
+	DurationSeconds    int            `gorm:"not null;default:0" json:"duration_seconds"`
+	Enabled            bool           `gorm:"not null;default:true" json:"enabled"`
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+}
+
+// TableName returns the table name for the FlightTask model.
+func (FlightTask) TableName() string {
+	return "flight_tasks"
+}
+
+// IsRecurring reports whether the task is driven by a cron expression.
+func (t *FlightTask) IsRecurring() bool {
+	return t.CronExpr != ""
+}
+
+// FlightTaskRun records what happened to a single occurrence of a FlightTask,
+// including the wayline file it was dispatched with.
+type FlightTaskRun struct {
+	ScheduledAt        time.Time           `gorm:"index:idx_flight_task_run;not null" json:"scheduled_at"`
+	CreatedAt          time.Time           `json:"created_at"`
+	WaylineRevision    *int                `json:"wayline_revision,omitempty"`
+	FlightID           string              `gorm:"size:36;index" json:"flight_id"`
+	DockSN             string              `gorm:"index;size:100;not null" json:"dock_sn"`
+	Status             FlightTaskRunStatus `gorm:"size:20;not null" json:"status"`
+	Reason             string              `gorm:"size:500" json:"reason,omitempty"`
+	WaylineFingerprint string              `gorm:"size:64" json:"wayline_fingerprint,omitempty"`
+	TaskID             uint                `gorm:"index:idx_flight_task_run;not null" json:"task_id"`
+	ID                 uint                `gorm:"primaryKey" json:"id"`
+}
+
+// TableName returns the table name for the FlightTaskRun model.
+func (FlightTaskRun) TableName() string {
+	return "flight_task_runs"
+}
+
+// BlackoutWindow is a time range during which no scheduled task may start.
+// An empty DockSN applies the window to every dock.
+type BlackoutWindow struct {
+	StartAt   time.Time `gorm:"index;not null" json:"start_at"`
+	EndAt     time.Time `gorm:"index;not null" json:"end_at"`
+	CreatedAt time.Time `json:"created_at"`
+	DockSN    string    `gorm:"index;size:100" json:"dock_sn,omitempty"`
+	Reason    string    `gorm:"size:500" json:"reason,omitempty"`
+	ID        uint      `gorm:"primaryKey" json:"id"`
+}
+
+// TableName returns the table name for the BlackoutWindow model.
+func (BlackoutWindow) TableName() string {
+	return "blackout_windows"
+}
+
+// Covers reports whether the window applies to dockSN at time t.
+func (w *BlackoutWindow) Covers(dockSN string, t time.Time) bool {
+	if w.DockSN != "" && w.DockSN != dockSN {
+		return false
+	}
+	return !t.Before(w.StartAt) && t.Before(w.EndAt)
+}
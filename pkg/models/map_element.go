@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Map element group (layer) types, as used by DJI Pilot 2.
+const (
+	MapElementGroupTypeCustom = 1
+	MapElementGroupTypeShared = 2
+)
+
+// MapElementGroup is a layer of shared map elements in a workspace. A
+// workspace has one default group of each type.
+type MapElementGroup struct {
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	WorkspaceID string         `gorm:"index;uniqueIndex:idx_map_group_default,where:is_default AND deleted_at IS NULL;size:64;not null" json:"workspace_id"`
+	Name        string         `gorm:"size:100;not null" json:"name"`
+	Type        int            `gorm:"uniqueIndex:idx_map_group_default,where:is_default AND deleted_at IS NULL;not null;default:1" json:"type"`
+	IsLock      bool           `gorm:"not null;default:false" json:"is_lock"`
+	IsDefault   bool           `gorm:"not null;default:false" json:"is_default"`
+	ID          string         `gorm:"primaryKey;size:64" json:"id"`
+}
+
+// TableName returns the table name for the MapElementGroup model.
+func (MapElementGroup) TableName() string {
+	return "map_element_groups"
+}
+
+// MapElement is an annotation (point, line or polygon) shared on the map of
+// a workspace. Geometry holds a GeoJSON geometry object. IDs are chosen by
+// the creating client, so deleted elements are removed rather than soft
+// deleted to let the ID be reused.
+type MapElement struct {
+	Geometry      datatypes.JSON `gorm:"type:jsonb;not null" json:"geometry"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	WorkspaceID   string         `gorm:"index;size:64;not null" json:"workspace_id"`
+	GroupID       string         `gorm:"index;size:64;not null" json:"group_id"`
+	Name          string         `gorm:"size:100;not null" json:"name"`
+	Color         string         `gorm:"size:16" json:"color"`
+	Username      string         `gorm:"size:100" json:"username,omitempty"`
+	ResourceType  int            `gorm:"not null" json:"resource_type"`
+	ClampToGround bool           `gorm:"not null;default:false" json:"clamp_to_ground"`
+	ID            string         `gorm:"primaryKey;size:64" json:"id"`
+}
+
+// TableName returns the table name for the MapElement model.
+func (MapElement) TableName() string {
+	return "map_elements"
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Media file sources.
+const (
+	MediaSourceDock  = "dock"
+	MediaSourcePilot = "pilot"
+)
+
+// MediaFile is an entry in the media catalog: a photo or video captured by a
+// dock flight or uploaded by DJI Pilot 2. The file itself lives in the object
+// store under ObjectKey, which is unique per workspace, deleted entries
+// included.
+type MediaFile struct {
+	ShootTime        *time.Time     `json:"shoot_time,omitempty"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	WorkspaceID      string         `gorm:"uniqueIndex:idx_media_workspace_object;size:64;not null;default:''" json:"workspace_id"`
+	Source           string         `gorm:"index;size:16;not null" json:"source"`
+	DeviceSN         string         `gorm:"index;size:100" json:"device_sn,omitempty"`
+	Username         string         `gorm:"size:100" json:"username,omitempty"`
+	Name             string         `gorm:"size:255;not null" json:"name"`
+	Path             string         `gorm:"size:500" json:"path,omitempty"`
+	ObjectKey        string         `gorm:"uniqueIndex:idx_media_workspace_object;size:500;not null" json:"object_key"`
+	Fingerprint      string         `gorm:"index;size:64" json:"fingerprint,omitempty"`
+	TinyFingerprint  string         `gorm:"index;size:64" json:"tiny_fingerprint,omitempty"`
+	FlightID         string         `gorm:"index;size:64" json:"flight_id,omitempty"`
+	DroneModelKey    string         `gorm:"size:20" json:"drone_model_key,omitempty"`
+	PayloadModelKey  string         `gorm:"size:20" json:"payload_model_key,omitempty"`
+	Latitude         float64        `json:"latitude"`
+	Longitude        float64        `json:"longitude"`
+	AbsoluteAltitude float64        `json:"absolute_altitude"`
+	RelativeAltitude float64        `json:"relative_altitude"`
+	GimbalYawDegree  float64        `json:"gimbal_yaw_degree"`
+	IsOriginal       bool           `gorm:"not null;default:false" json:"is_original"`
+	ID               uint           `gorm:"primaryKey" json:"id"`
+}
+
+// TableName returns the table name for the MediaFile model.
+func (MediaFile) TableName() string {
+	return "media_files"
+}
@@ -8,9 +8,27 @@ import (
 func AutoMigrate(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&ThingModel{},
+		&Workspace{},
 		&Device{},
 		&DeviceProperty{},
 		&DeviceEvent{},
 		&MessageLog{},
+		&FlightTask{},
+		&FlightTaskRun{},
+		&BlackoutWindow{},
+		&Wayline{},
+		&WaylineRevision{},
+		&MediaFile{},
+		&MapElementGroup{},
+		&MapElement{},
+		&FlightArea{},
+		&FlightAreaFile{},
+		&RetiredFlightAreaFile{},
+		&FlightAreaSync{},
+		&UnlockLicenseFile{},
+		&DeviceUnlockLicenses{},
+		&UnlockLicenseAudit{},
+		&User{},
+		&APIKey{},
 	)
 }
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// UnlockLicenseFile is an offline FlySafe unlock licence file uploaded to a
+// workspace, which docks download on unlock_license_update.
+type UnlockLicenseFile struct {
+	CreatedAt   time.Time `json:"created_at"`
+	ID          string    `gorm:"primaryKey;size:64" json:"id"`
+	WorkspaceID string    `gorm:"index;size:64;not null" json:"workspace_id"`
+	Name        string    `gorm:"size:255;not null" json:"name"`
+	ObjectKey   string    `gorm:"size:512;not null" json:"object_key"`
+	// Fingerprint is the MD5 of the file content, hex encoded
+	Fingerprint string `gorm:"size:32;not null" json:"fingerprint"`
+	UploadedBy  string `gorm:"size:255" json:"uploaded_by"`
+	Size        int64  `gorm:"not null" json:"size"`
+}
+
+// TableName returns the table name for the UnlockLicenseFile model.
+func (UnlockLicenseFile) TableName() string {
+	return "unlock_license_files"
+}
+
+// DeviceUnlockLicenses is the unlock licence list a device last reported
+// with unlock_license_list, for one device model domain (0 for the
+// aircraft, 3 for the dock).
+type DeviceUnlockLicenses struct {
+	UpdatedAt         time.Time `json:"updated_at"`
+	DeviceSN          string    `gorm:"primaryKey;size:100" json:"device_sn"`
+	WorkspaceID       string    `gorm:"index;size:64;not null" json:"workspace_id"`
+	DeviceModelDomain int       `gorm:"primaryKey;autoIncrement:false" json:"device_model_domain"`
+	// Consistence reports whether the aircraft and dock licences match
+	Consistence bool `json:"consistence"`
+	// Licenses holds the reported safety.UnlockLicense list
+	Licenses datatypes.JSON `gorm:"type:jsonb" json:"licenses"`
+}
+
+// TableName returns the table name for the DeviceUnlockLicenses model.
+func (DeviceUnlockLicenses) TableName() string {
+	return "device_unlock_licenses"
+}
+
+// UnlockLicenseAudit records an unlock licence change: a licence file
+// uploaded, deleted or pushed to a dock, or a licence enabled or disabled.
+// Changes sent to devices stay pending until the device replies.
+type UnlockLicenseAudit struct {
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	WorkspaceID string     `gorm:"index;size:64;not null" json:"workspace_id"`
+	DeviceSN    string     `gorm:"index;size:100" json:"device_sn,omitempty"`
+	Action      string     `gorm:"size:16;not null" json:"action"`
+	FileID      string     `gorm:"size:64" json:"file_id,omitempty"`
+	// ActorID is the subject of the caller's credential, e.g. "user:1"
+	ActorID   string `gorm:"size:255" json:"actor_id,omitempty"`
+	ActorName string `gorm:"size:255" json:"actor_name,omitempty"`
+	// TID and BID identify the call sent to the dock; its reply carries the TID
+	TID       string `gorm:"column:tid;index;size:64" json:"tid,omitempty"`
+	BID       string `gorm:"column:bid;size:64" json:"bid,omitempty"`
+	Status    string `gorm:"size:16;index;not null" json:"status"`
+	Error     string `gorm:"type:text" json:"error,omitempty"`
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	LicenseID int    `json:"license_id,omitempty"`
+	// Result is the result code of the device reply
+	Result int `json:"result"`
+}
+
+// TableName returns the table name for the UnlockLicenseAudit model.
+func (UnlockLicenseAudit) TableName() string {
+	return "unlock_license_audits"
+}
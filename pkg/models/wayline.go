@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Wayline represents a stored WPML wayline file (KMZ). The archive itself
+// lives in the object store under ObjectKey. Names are unique per workspace.
+// The file fields describe the latest of the wayline's revisions.
+type Wayline struct {
+	Metadata        datatypes.JSON `gorm:"type:jsonb" json:"metadata,omitempty"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	WorkspaceID     string         `gorm:"index;uniqueIndex:idx_wayline_workspace_name,where:deleted_at IS NULL;size:64;not null;default:''" json:"workspace_id"`
+	Name            string         `gorm:"uniqueIndex:idx_wayline_workspace_name,where:deleted_at IS NULL;size:100;not null" json:"name"`
+	Description     string         `gorm:"size:500" json:"description,omitempty"`
+	Username        string         `gorm:"size:100" json:"username,omitempty"`
+	TemplateType    string         `gorm:"size:50" json:"template_type"`
+	ObjectKey       string         `gorm:"size:255;not null" json:"object_key"`
+	Fingerprint     string         `gorm:"index;size:64;not null" json:"fingerprint"`
+	Size            int64          `gorm:"not null;default:0" json:"size"`
+	Distance        float64        `gorm:"not null;default:0" json:"distance"`
+	Duration        float64        `gorm:"not null;default:0" json:"duration"`
+	DroneModel      int            `gorm:"not null" json:"drone_model"`
+	DroneSubModel   int            `gorm:"not null;default:0" json:"drone_sub_model"`
+	PayloadModel    int            `gorm:"not null" json:"payload_model"`
+	PayloadPosition int            `gorm:"not null;default:0" json:"payload_position"`
+	WaypointCount   int            `gorm:"not null;default:0" json:"waypoint_count"`
+	PhotoCount      int            `gorm:"not null;default:0" json:"photo_count"`
+	Revision        int            `gorm:"not null;default:1" json:"revision"`
+	Favorited       bool           `gorm:"index;not null;default:false" json:"favorited"`
+	ID              uint           `gorm:"primaryKey" json:"id"`
+}
+
+// TableName returns the table name for the Wayline model.
+func (Wayline) TableName() string {
+	return "waylines"
+}
+
+// WaylineRevision is a stored version of a wayline's archive. Revisions are
+// numbered from 1 for each wayline and keep their archives, so the file a
+// flight task flew can be retrieved after the wayline was edited.
+type WaylineRevision struct {
+	CreatedAt   time.Time `json:"created_at"`
+	ObjectKey   string    `gorm:"size:255;not null" json:"object_key"`
+	Fingerprint string    `gorm:"index;size:64;not null" json:"fingerprint"`
+	Username    string    `gorm:"size:100" json:"username,omitempty"`
+	Comment     string    `gorm:"size:500" json:"comment,omitempty"`
+	Size        int64     `gorm:"not null;default:0" json:"size"`
+	Revision    int       `gorm:"uniqueIndex:idx_wayline_revision;not null" json:"revision"`
+	WaylineID   uint      `gorm:"uniqueIndex:idx_wayline_revision;not null" json:"wayline_id"`
+	ID          uint      `gorm:"primaryKey" json:"id"`
+}
+
+// TableName returns the table name for the WaylineRevision model.
+func (WaylineRevision) TableName() string {
+	return "wayline_revisions"
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Workspace is a tenant owning devices and the data they produce. Devices
+// join a workspace through Device.WorkspaceID. DJI docks call workspaces
+// organizations and join one by presenting its BindCode.
+type Workspace struct {
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	Name        string         `gorm:"size:200;not null" json:"name"`
+	Description string         `gorm:"size:1000" json:"description,omitempty"`
+	BindCode    string         `gorm:"size:64;index" json:"bind_code,omitempty"`
+	ID          string         `gorm:"primaryKey;size:64" json:"id"`
+}
+
+// TableName returns the table name for the Workspace model.
+func (Workspace) TableName() string {
+	return "workspaces"
+}
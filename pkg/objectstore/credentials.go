@@ -0,0 +1,188 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrCredentialsUnavailable is returned when no credential provider is configured.
+var ErrCredentialsUnavailable = errors.New("temporary credentials unavailable")
+
+// minAssumeRoleDuration is the shortest session STS accepts.
+const minAssumeRoleDuration = 15 * time.Minute
+
+// AssumeRoleConfig configures AssumeRoleCredentials.
+type AssumeRoleConfig struct {
+	// Provider, Endpoint, Bucket and Region are reported to devices, which
+	// upload to Endpoint with the issued credentials
+	Provider string
+	Endpoint string
+	Bucket   string
+	Region   string
+	// STSEndpoint is the security token service URL; MinIO serves it on the
+	// S3 endpoint, AWS on e.g. "https://sts.us-east-1.amazonaws.com"
+	STSEndpoint string
+	// AccessKeyID and AccessKeySecret are the platform credentials that
+	// assume the role
+	AccessKeyID     string
+	AccessKeySecret string
+	// RoleARN is the role to assume; MinIO ignores it
+	RoleARN string
+	// Duration is the credential lifetime, at least 15 minutes (default 1h)
+	Duration time.Duration
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// AssumeRoleCredentials issues short-lived credentials through STS
+// AssumeRole. Each session carries a policy that only allows object access
+// below the requested prefix, so a device can neither read nor overwrite
+// other workspaces' objects.
+type AssumeRoleCredentials struct {
+	config AssumeRoleConfig
+	signer *sigV4
+	client *http.Client
+}
+
+// NewAssumeRoleCredentials creates an AssumeRoleCredentials provider.
+func NewAssumeRoleCredentials(config AssumeRoleConfig) (*AssumeRoleCredentials, error) {
+	if config.STSEndpoint == "" || config.Bucket == "" {
+		return nil, errors.New("sts endpoint and bucket are required")
+	}
+	if config.AccessKeyID == "" || config.AccessKeySecret == "" {
+		return nil, errors.New("sts access key is required")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Duration <= 0 {
+		config.Duration = time.Hour
+	}
+	if config.Duration < minAssumeRoleDuration {
+		config.Duration = minAssumeRoleDuration
+	}
+	client := config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &AssumeRoleCredentials{
+		config: config,
+		signer: &sigV4{
+			accessKeyID:     config.AccessKeyID,
+			accessKeySecret: config.AccessKeySecret,
+			region:          config.Region,
+			service:         "sts",
+		},
+		client: client,
+	}, nil
+}
+
+// assumeRoleResponse is the XML response of STS AssumeRole.
+type assumeRoleResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleResult>Credentials"`
+}
+
+// TemporaryCredentials implements CredentialProvider.
+func (a *AssumeRoleCredentials) TemporaryCredentials(ctx context.Context, prefix string) (*Credentials, error) {
+	if a == nil {
+		return nil, ErrCredentialsUnavailable
+	}
+	policy, err := prefixPolicy(a.config.Bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("Action", "AssumeRole")
+	form.Set("Version", "2011-06-15")
+	form.Set("RoleSessionName", "utmos-"+uuid.New().String()[:8])
+	form.Set("DurationSeconds", strconv.FormatInt(int64(a.config.Duration/time.Second), 10))
+	form.Set("Policy", policy)
+	if a.config.RoleARN != "" {
+		form.Set("RoleArn", a.config.RoleARN)
+	}
+	body := form.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.config.STSEndpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sts request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	a.signer.sign(req, hashHex([]byte(body)), time.Now())
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sts request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("sts AssumeRole failed: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+
+	var result assumeRoleResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode sts response: %w", err)
+	}
+	if result.Credentials.AccessKeyID == "" {
+		return nil, errors.New("sts response has no credentials")
+	}
+
+	expire := a.config.Duration
+	if !result.Credentials.Expiration.IsZero() {
+		expire = time.Until(result.Credentials.Expiration)
+	}
+	return &Credentials{
+		Provider:        a.config.Provider,
+		Endpoint:        a.config.Endpoint,
+		Bucket:          a.config.Bucket,
+		Region:          a.config.Region,
+		ObjectKeyPrefix: prefix,
+		AccessKeyID:     result.Credentials.AccessKeyID,
+		AccessKeySecret: result.Credentials.SecretAccessKey,
+		SecurityToken:   result.Credentials.SessionToken,
+		Expire:          int64(expire / time.Second),
+	}, nil
+}
+
+// prefixPolicy returns a session policy allowing object uploads and reads
+// only below prefix in bucket.
+func prefixPolicy(bucket, prefix string) (string, error) {
+	if prefix == "" || strings.Contains(prefix, "*") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, prefix)
+	}
+	policy := map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []map[string]any{{
+			"Effect": "Allow",
+			"Action": []string{
+				"s3:PutObject",
+				"s3:GetObject",
+				"s3:AbortMultipartUpload",
+				"s3:ListMultipartUploadParts",
+			},
+			"Resource": []string{"arn:aws:s3:::" + bucket + "/" + prefix + "*"},
+		}},
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session policy: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,93 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssumeRoleCredentials(t *testing.T) {
+	var policy struct {
+		Statement []struct {
+			Action   []string `json:"Action"`
+			Resource []string `json:"Resource"`
+		} `json:"Statement"`
+	}
+	expiration := time.Now().Add(30 * time.Minute).UTC().Format(time.RFC3339)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ak/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/sts/aws4_request")
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "AssumeRole", r.PostForm.Get("Action"))
+		assert.Equal(t, "arn:aws:iam::123:role/pilot", r.PostForm.Get("RoleArn"))
+		assert.Equal(t, "1800", r.PostForm.Get("DurationSeconds"))
+		require.NoError(t, json.Unmarshal([]byte(r.PostForm.Get("Policy")), &policy))
+
+		_, _ = w.Write([]byte(`<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIATEMP</AccessKeyId>
+      <SecretAccessKey>tempsecret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>` + expiration + `</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`))
+	}))
+	defer server.Close()
+
+	provider, err := NewAssumeRoleCredentials(AssumeRoleConfig{
+		Provider:        "minio",
+		Endpoint:        "https://minio.example.com",
+		Bucket:          "utmos",
+		STSEndpoint:     server.URL,
+		AccessKeyID:     "ak",
+		AccessKeySecret: "sk",
+		RoleARN:         "arn:aws:iam::123:role/pilot",
+		Duration:        30 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	creds, err := provider.TemporaryCredentials(context.Background(), "workspaces/ws1/")
+	require.NoError(t, err)
+	assert.Equal(t, "ASIATEMP", creds.AccessKeyID)
+	assert.Equal(t, "tempsecret", creds.AccessKeySecret)
+	assert.Equal(t, "token", creds.SecurityToken)
+	assert.Equal(t, "workspaces/ws1/", creds.ObjectKeyPrefix)
+	assert.Equal(t, "https://minio.example.com", creds.Endpoint)
+	assert.InDelta(t, 1800, creds.Expire, 5)
+
+	require.Len(t, policy.Statement, 1)
+	assert.Equal(t, []string{"arn:aws:s3:::utmos/workspaces/ws1/*"}, policy.Statement[0].Resource)
+	assert.Contains(t, policy.Statement[0].Action, "s3:PutObject")
+
+	_, err = provider.TemporaryCredentials(context.Background(), "")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	var empty *AssumeRoleCredentials
+	_, err = empty.TemporaryCredentials(context.Background(), "p/")
+	assert.ErrorIs(t, err, ErrCredentialsUnavailable)
+
+	_, err = NewAssumeRoleCredentials(AssumeRoleConfig{Bucket: "utmos", AccessKeyID: "ak", AccessKeySecret: "sk"})
+	assert.Error(t, err)
+}
+
+func TestAssumeRoleCredentials_STSError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	provider, err := NewAssumeRoleCredentials(AssumeRoleConfig{Bucket: "utmos", STSEndpoint: server.URL, AccessKeyID: "ak", AccessKeySecret: "sk"})
+	require.NoError(t, err)
+	_, err = provider.TemporaryCredentials(context.Background(), "workspaces/ws1/")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AccessDenied")
+}
@@ -0,0 +1,154 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LocalStore stores objects as files below a root directory.
+type LocalStore struct {
+	signer *URLSigner
+	root   string
+}
+
+// NewLocalStore creates a LocalStore rooted at dir, creating it if necessary.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if dir == "" {
+		return nil, errors.New("local store directory is required")
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	return &LocalStore{root: dir}, nil
+}
+
+// SetURLSigner enables PresignGet; signed URLs must be served by a handler
+// that verifies them with the same signer.
+func (s *LocalStore) SetURLSigner(signer *URLSigner) {
+	s.signer = signer
+}
+
+// URLSigner returns the signer used by PresignGet, or nil.
+func (s *LocalStore) URLSigner() *URLSigner {
+	return s.signer
+}
+
+// PresignGet implements Presigner.
+func (s *LocalStore) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if s.signer == nil {
+		return "", errors.New("local store has no URL signer configured")
+	}
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return s.signer.Sign(info.Key, time.Now().Add(ttl)), nil
+}
+
+// Root returns the directory objects are stored in.
+func (s *LocalStore) Root() string {
+	return s.root
+}
+
+func (s *LocalStore) path(key string) (string, string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return cleaned, filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+// Put implements Store. The object is written to a temporary file first so
+// readers never observe a partially written object.
+func (s *LocalStore) Put(_ context.Context, key string, r io.Reader, _ int64, _ string) error {
+	_, p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0750); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close object: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("failed to store object: %w", err)
+	}
+	return nil
+}
+
+// Get implements Store.
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, p, _ := s.path(key)
+	f, err := os.Open(filepath.Clean(p))
+	if err != nil {
+		return nil, nil, mapFSError(err)
+	}
+	return f, info, nil
+}
+
+// Stat implements Store.
+func (s *LocalStore) Stat(_ context.Context, key string) (*ObjectInfo, error) {
+	cleaned, p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, mapFSError(err)
+	}
+	if fi.IsDir() {
+		return nil, ErrNotFound
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(p))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &ObjectInfo{
+		ModTime:     fi.ModTime(),
+		Key:         cleaned,
+		ContentType: contentType,
+		Size:        fi.Size(),
+	}, nil
+}
+
+// Delete implements Store.
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	_, p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+func mapFSError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
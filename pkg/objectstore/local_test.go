@@ -0,0 +1,92 @@
+package objectstore
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "waylines/a.kmz", want: "waylines/a.kmz"},
+		{key: "/waylines//a.kmz", want: "waylines/a.kmz"},
+		{key: "waylines\\a.kmz", want: "waylines/a.kmz"},
+		{key: "", wantErr: true},
+		{key: "/", wantErr: true},
+		{key: "../etc/passwd", wantErr: true},
+		{key: "a/../../b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := CleanKey(tt.key)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidKey)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	var _ Store = store
+
+	t.Run("put and get", func(t *testing.T) {
+		require.NoError(t, store.Put(ctx, "waylines/1/test.kmz", strings.NewReader("kmz-data"), 8, "application/vnd.google-earth.kmz"))
+
+		rc, info, err := store.Get(ctx, "waylines/1/test.kmz")
+		require.NoError(t, err)
+		defer func() { _ = rc.Close() }()
+
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Equal(t, "kmz-data", string(data))
+		assert.Equal(t, int64(8), info.Size)
+		assert.Equal(t, "waylines/1/test.kmz", info.Key)
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		require.NoError(t, store.Put(ctx, "a.txt", strings.NewReader("one"), 3, ""))
+		require.NoError(t, store.Put(ctx, "a.txt", strings.NewReader("second"), 6, ""))
+
+		info, err := store.Stat(ctx, "a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), info.Size)
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		_, err := store.Stat(ctx, "missing.kmz")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, _, err = store.Get(ctx, "missing.kmz")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, store.Put(ctx, "b.txt", strings.NewReader("b"), 1, ""))
+		require.NoError(t, store.Delete(ctx, "b.txt"))
+		require.NoError(t, store.Delete(ctx, "b.txt"))
+
+		_, err := store.Stat(ctx, "b.txt")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("rejects traversal", func(t *testing.T) {
+		err := store.Put(ctx, "../escape.txt", strings.NewReader("x"), 1, "")
+		assert.ErrorIs(t, err, ErrInvalidKey)
+	})
+}
@@ -0,0 +1,210 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3Store.
+type S3Config struct {
+	// Endpoint is the S3 API URL, e.g. "https://s3.us-east-1.amazonaws.com"
+	// or "http://minio:9000"
+	Endpoint        string
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	AccessKeySecret string
+	// PathStyle addresses the bucket in the path instead of the host name,
+	// as MinIO and most other S3-compatible stores expect
+	PathStyle bool
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// S3Store stores objects in an S3 or S3-compatible (e.g. MinIO) bucket, the
+// same bucket Pilot 2 uploads to with temporary credentials.
+type S3Store struct {
+	client   *http.Client
+	endpoint *url.URL
+	signer   *sigV4
+	bucket   string
+	// pathStyle puts the bucket in the path instead of the host name
+	pathStyle bool
+}
+
+// NewS3Store creates an S3Store.
+func NewS3Store(config S3Config) (*S3Store, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket are required")
+	}
+	if config.AccessKeyID == "" || config.AccessKeySecret == "" {
+		return nil, errors.New("s3 access key is required")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", config.Endpoint)
+	}
+	region := config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	client := config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3Store{
+		client:   client,
+		endpoint: endpoint,
+		signer: &sigV4{
+			accessKeyID:     config.AccessKeyID,
+			accessKeySecret: config.AccessKeySecret,
+			region:          region,
+			service:         "s3",
+		},
+		bucket:    config.Bucket,
+		pathStyle: config.PathStyle,
+	}, nil
+}
+
+// Bucket returns the bucket objects are stored in.
+func (s *S3Store) Bucket() string {
+	return s.bucket
+}
+
+// objectURL returns the URL of the object with the cleaned key.
+func (s *S3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	objectPath := "/" + key
+	if s.pathStyle {
+		objectPath = "/" + s.bucket + objectPath
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + objectPath
+	u.RawPath = uriEncode(u.Path, false)
+	return &u
+}
+
+// do sends a signed request for the object with key and returns the response
+// of a 2xx status. A 404 is reported as ErrNotFound.
+func (s *S3Store) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(cleaned).String(), body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build s3 request: %w", err)
+	}
+	if body != nil {
+		req.ContentLength = size
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+	}
+	s.signer.sign(req, unsignedPayload, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("s3 %s %s failed: %w", method, cleaned, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, cleaned, nil
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", ErrNotFound
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, "", fmt.Errorf("s3 %s %s failed: %s: %s", method, cleaned, resp.Status, bytes.TrimSpace(detail))
+}
+
+// Put implements Store. Objects of unknown size are buffered first, since
+// S3 requires the content length up front.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if size < 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to read object: %w", err)
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	resp, _, err := s.do(ctx, http.MethodPut, key, r, size, contentType)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// Get implements Store.
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	resp, cleaned, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Body, objectInfo(cleaned, resp), nil
+}
+
+// Stat implements Store.
+func (s *S3Store) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	resp, cleaned, err := s.do(ctx, http.MethodHead, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	return objectInfo(cleaned, resp), nil
+}
+
+// Delete implements Store.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, _, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// PresignGet implements Presigner with an S3 presigned URL, so devices
+// download straight from the bucket.
+func (s *S3Store) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return s.signer.presign(http.MethodGet, s.objectURL(info.Key), ttl, time.Now()), nil
+}
+
+func objectInfo(key string, resp *http.Response) *ObjectInfo {
+	info := &ObjectInfo{
+		Key:         key,
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        resp.ContentLength,
+	}
+	if info.Size < 0 {
+		info.Size, _ = strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	}
+	if info.ContentType == "" {
+		info.ContentType = "application/octet-stream"
+	}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info
+}
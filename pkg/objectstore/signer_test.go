@@ -0,0 +1,57 @@
+package objectstore
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer := NewURLSigner("https://api.example.com/objects/", []byte("secret"))
+	now := time.Unix(1700000000, 0)
+
+	signed := signer.Sign("waylines/a.kmz", now.Add(time.Minute))
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "/objects/waylines/a.kmz", u.Path)
+
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	assert.True(t, signer.Verify("waylines/a.kmz", expires, signature, now))
+	assert.False(t, signer.Verify("waylines/b.kmz", expires, signature, now), "other key")
+	assert.False(t, signer.Verify("waylines/a.kmz", expires, signature, now.Add(2*time.Minute)), "expired")
+	assert.False(t, signer.Verify("waylines/a.kmz", "notanumber", signature, now))
+
+	// Keys are escaped in the URL and signed unescaped
+	signed = signer.Sign("media/ws1/DJI 0001#1?.jpg", now.Add(time.Minute))
+	u, err = url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "/objects/media/ws1/DJI 0001#1?.jpg", u.Path)
+	assert.True(t, signer.Verify("media/ws1/DJI 0001#1?.jpg", u.Query().Get("expires"), u.Query().Get("signature"), now))
+
+	other := NewURLSigner("https://api.example.com/objects", []byte("other"))
+	assert.False(t, other.Verify("waylines/a.kmz", expires, signature, now), "other secret")
+}
+
+func TestLocalStore_PresignGet(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put(ctx, "a.kmz", strings.NewReader("x"), 1, ""))
+
+	_, err = store.PresignGet(ctx, "a.kmz", time.Minute)
+	assert.Error(t, err, "no signer configured")
+
+	store.SetURLSigner(NewURLSigner("http://localhost/objects", []byte("k")))
+	signed, err := store.PresignGet(ctx, "a.kmz", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed, "http://localhost/objects/a.kmz?"))
+
+	_, err = store.PresignGet(ctx, "missing.kmz", time.Minute)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
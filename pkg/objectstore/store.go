@@ -0,0 +1,79 @@
+// Package objectstore provides a pluggable blob storage abstraction used for
+// wayline files, media and other artifacts exchanged with devices.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the requested object does not exist.
+	ErrNotFound = errors.New("object not found")
+	// ErrInvalidKey is returned when an object key is empty or escapes the store root.
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// ObjectInfo holds metadata about a stored object.
+type ObjectInfo struct {
+	ModTime     time.Time
+	Key         string
+	ContentType string
+	Size        int64
+}
+
+// Store is implemented by blob storage backends.
+type Store interface {
+	// Put writes the object, replacing any existing object with the same key.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object for reading. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	// Stat returns object metadata without reading its content.
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// CleanKey normalizes an object key to a slash-separated relative path and
+// rejects keys that are empty or would escape the store root.
+func CleanKey(key string) (string, error) {
+	key = strings.TrimSpace(strings.ReplaceAll(key, "\\", "/"))
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || strings.Contains(key, "..") {
+		return "", ErrInvalidKey
+	}
+	return strings.TrimPrefix(cleaned, "/"), nil
+}
+
+// Presigner is implemented by stores that can hand out time-limited download
+// URLs, so devices can fetch objects without platform credentials.
+type Presigner interface {
+	PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// Credentials are temporary object storage credentials handed to devices
+// that upload directly to the bucket (e.g. Pilot 2 wayline and media sync).
+type Credentials struct {
+	Provider        string `json:"provider"`
+	Endpoint        string `json:"endpoint"`
+	Bucket          string `json:"bucket"`
+	Region          string `json:"region"`
+	ObjectKeyPrefix string `json:"object_key_prefix"`
+	AccessKeyID     string `json:"access_key_id"`
+	AccessKeySecret string `json:"access_key_secret"`
+	SecurityToken   string `json:"security_token"`
+	// Expire is the credential lifetime in seconds.
+	Expire int64 `json:"expire"`
+}
+
+// CredentialProvider issues temporary credentials scoped to an object key prefix.
+type CredentialProvider interface {
+	TemporaryCredentials(ctx context.Context, prefix string) (*Credentials, error)
+}
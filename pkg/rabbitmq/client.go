@@ -158,6 +158,26 @@ func (c *Client) DeclareQueue(name string, durable bool) (amqp.Queue, error) {
 	)
 }
 
+// DeclareExclusiveQueue declares a queue owned by this connection, which the
+// broker deletes when the connection closes.
+func (c *Client) DeclareExclusiveQueue(name string) (amqp.Queue, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.connected {
+		return amqp.Queue{}, ErrNotConnected
+	}
+
+	return c.channel.QueueDeclare(
+		name,  // name
+		false, // durable
+		true,  // delete when unused
+		true,  // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+}
+
 // DeclareQueueWithDLQ declares a queue with dead letter queue support.
 func (c *Client) DeclareQueueWithDLQ(name, dlxName string) (amqp.Queue, error) {
 	c.mu.RLock()
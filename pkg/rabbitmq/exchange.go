@@ -37,6 +37,16 @@ func (c *Client) SetupQueueWithBinding(queueName, routingKeyPattern string) erro
 	return c.BindQueue(queueName, routingKeyPattern, c.cfg.ExchangeName)
 }
 
+// SetupExclusiveQueueWithBinding creates a queue owned by this connection
+// and binds it to the exchange, so that every instance of a service
+// receives each matching message.
+func (c *Client) SetupExclusiveQueueWithBinding(queueName, routingKeyPattern string) error {
+	if _, err := c.DeclareExclusiveQueue(queueName); err != nil {
+		return err
+	}
+	return c.BindQueue(queueName, routingKeyPattern, c.cfg.ExchangeName)
+}
+
 // SetupQueueWithDLQ creates a queue with dead letter support and binds it.
 func (c *Client) SetupQueueWithDLQ(queueName, routingKeyPattern string) error {
 	// Ensure DLX exists
@@ -26,6 +26,7 @@ type StandardMessage struct {
 	Service      string          `json:"service"`                 // Sending service name
 	Action       string          `json:"action"`                  // Action identifier
 	DeviceSN     string          `json:"device_sn"`               // Device serial number
+	WorkspaceID  string          `json:"workspace_id,omitempty"`  // Workspace of workspace-scoped messages (optional)
 	Timestamp    int64           `json:"timestamp"`               // Millisecond Unix timestamp
 	ProtocolMeta *ProtocolMeta   `json:"protocol_meta,omitempty"` // Protocol-specific metadata (optional)
 }
@@ -91,8 +92,8 @@ func (m *StandardMessage) Validate() error {
 	if m.Action == "" {
 		return errors.New("action is required")
 	}
-	if m.DeviceSN == "" {
-		return errors.New("device SN is required")
+	if m.DeviceSN == "" && m.WorkspaceID == "" {
+		return errors.New("device SN or workspace ID is required")
 	}
 	return nil
 }
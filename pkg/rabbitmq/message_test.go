@@ -111,6 +111,19 @@ func TestStandardMessage_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "workspace message without DeviceSN",
+			msg: &StandardMessage{
+				TID:         "tid-123",
+				BID:         "bid-456",
+				Timestamp:   time.Now().UnixMilli(),
+				Service:     "iot-api",
+				Action:      "map_element_create",
+				WorkspaceID: "ws-1",
+				Data:        json.RawMessage(`{}`),
+			},
+			wantErr: false,
+		},
 		{
 			name: "zero timestamp",
 			msg: &StandardMessage{
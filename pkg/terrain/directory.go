@@ -0,0 +1,83 @@
+package terrain
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Directory is a DEM backed by a directory of SRTM .hgt tiles and GeoTIFF
+// rasters. Tiles are loaded when first needed; rasters are loaded when the
+// directory is opened and consulted for positions no tile covers.
+type Directory struct {
+	dir     string
+	mu      sync.Mutex
+	tiles   map[string]*HGTTile
+	rasters []*GeoTIFF
+}
+
+// OpenDirectory opens a directory of elevation files.
+func OpenDirectory(dir string) (*Directory, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	d := &Directory{dir: dir, tiles: make(map[string]*HGTTile)}
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".tif", ".tiff":
+			raster, err := LoadGeoTIFF(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to load %s: %w", entry.Name(), err)
+			}
+			d.rasters = append(d.rasters, raster)
+		}
+	}
+	return d, nil
+}
+
+// Elevation returns the terrain elevation at a position from the tile or
+// raster covering it.
+func (d *Directory) Elevation(lat, lon float64) (float64, error) {
+	tile, err := d.tile(HGTTileName(lat, lon))
+	if err != nil {
+		return 0, err
+	}
+	if tile != nil {
+		return tile.Elevation(lat, lon)
+	}
+	for _, raster := range d.rasters {
+		if raster.covers(lat, lon) {
+			return raster.Elevation(lat, lon)
+		}
+	}
+	return 0, ErrOutOfCoverage
+}
+
+// tile returns the named tile, or nil when the directory does not hold it.
+func (d *Directory) tile(name string) (*HGTTile, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if tile, ok := d.tiles[name]; ok {
+		return tile, nil
+	}
+
+	var tile *HGTTile
+	for _, file := range []string{name + ".hgt", name + ".HGT", strings.ToLower(name) + ".hgt"} {
+		loaded, err := LoadHGT(filepath.Join(d.dir, file))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", file, err)
+		}
+		tile = loaded
+		break
+	}
+	d.tiles[name] = tile
+	return tile, nil
+}
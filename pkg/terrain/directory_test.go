@@ -0,0 +1,33 @@
+package terrain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "N39E116.hgt"), hgtData(11), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "N20E010.tif"), geoTIFFData(t, geoTIFFOptions{}), 0o600))
+
+	d, err := OpenDirectory(dir)
+	require.NoError(t, err)
+
+	elevation, err := d.Elevation(39.75, 116.25)
+	require.NoError(t, err)
+	assert.InDelta(t, 27.5, elevation, 1e-9)
+
+	elevation, err = d.Elevation(20.5, 10.5)
+	require.NoError(t, err)
+	assert.InDelta(t, 7.5, elevation, 1e-6)
+
+	_, err = d.Elevation(0, 0)
+	assert.ErrorIs(t, err, ErrOutOfCoverage)
+
+	_, err = OpenDirectory(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
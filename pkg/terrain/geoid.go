@@ -0,0 +1,68 @@
+package terrain
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// EGM96 15 arc minute grid dimensions, as distributed by NGA in WW15MGH.DAC.
+const (
+	egm96Rows    = 721
+	egm96Columns = 1440
+	egm96Spacing = 0.25
+)
+
+// EGM96Grid is the EGM96 geoid on the 15 arc minute grid NGA distributes
+// as WW15MGH.DAC: big-endian 16 bit undulations in centimeters, from 90°N
+// to 90°S and from 0° to 359.75°E, 2,076,480 bytes.
+type EGM96Grid struct {
+	grid
+}
+
+// LoadEGM96Grid reads an EGM96 15 arc minute geoid grid file.
+func LoadEGM96Grid(path string) (*EGM96Grid, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	return ParseEGM96Grid(data)
+}
+
+// ParseEGM96Grid parses an EGM96 15 arc minute geoid grid. The 0° column is
+// repeated at 360°E so positions east of 359.75°E interpolate across the
+// antimeridian.
+func ParseEGM96Grid(data []byte) (*EGM96Grid, error) {
+	if len(data) != egm96Rows*egm96Columns*2 {
+		return nil, fmt.Errorf("invalid EGM96 grid: %d bytes, want %d", len(data), egm96Rows*egm96Columns*2)
+	}
+	width := egm96Columns + 1
+	values := make([]float32, egm96Rows*width)
+	for row := 0; row < egm96Rows; row++ {
+		for col := 0; col < egm96Columns; col++ {
+			offset := (row*egm96Columns + col) * 2
+			values[row*width+col] = float32(int16(binary.BigEndian.Uint16(data[offset:]))) / 100
+		}
+		values[row*width+egm96Columns] = values[row*width]
+	}
+	return &EGM96Grid{grid: grid{
+		west:   0,
+		north:  90,
+		dLon:   egm96Spacing,
+		dLat:   egm96Spacing,
+		width:  width,
+		height: egm96Rows,
+		values: values,
+	}}, nil
+}
+
+// Undulation returns the interpolated geoid height above the WGS84 ellipsoid.
+func (g *EGM96Grid) Undulation(lat, lon float64) (float64, error) {
+	lon = math.Mod(lon, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return g.at(lat, lon)
+}
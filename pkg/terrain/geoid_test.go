@@ -0,0 +1,55 @@
+package terrain
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// egm96Data encodes a geoid grid whose undulation is the column number in
+// centimeters, so it rises 4 cm per degree eastwards from 0°E.
+func egm96Data() []byte {
+	data := make([]byte, egm96Rows*egm96Columns*2)
+	for row := 0; row < egm96Rows; row++ {
+		for col := 0; col < egm96Columns; col++ {
+			binary.BigEndian.PutUint16(data[(row*egm96Columns+col)*2:], uint16(int16(col)))
+		}
+	}
+	return data
+}
+
+func TestEGM96Grid(t *testing.T) {
+	geoid, err := ParseEGM96Grid(egm96Data())
+	require.NoError(t, err)
+
+	undulation, err := geoid.Undulation(39.9, 116.4)
+	require.NoError(t, err)
+	assert.InDelta(t, 4.656, undulation, 1e-6)
+
+	// Western longitudes wrap around to the east
+	undulation, err = geoid.Undulation(-33.4, -70.6)
+	require.NoError(t, err)
+	assert.InDelta(t, 11.576, undulation, 1e-6)
+
+	height, err := EllipsoidHeight(geoid, 39.9, 116.4, 100)
+	require.NoError(t, err)
+	assert.InDelta(t, 104.656, height, 1e-6)
+	height, err = OrthometricHeight(geoid, 39.9, 116.4, height)
+	require.NoError(t, err)
+	assert.InDelta(t, 100, height, 1e-6)
+
+	// Between the last column and 360°E the grid wraps to the first
+	undulation, err = geoid.Undulation(0, 359.875)
+	require.NoError(t, err)
+	assert.InDelta(t, 7.195, undulation, 1e-6)
+
+	_, err = geoid.Undulation(91, 0)
+	assert.ErrorIs(t, err, ErrOutOfCoverage)
+	_, err = ParseEGM96Grid(egm96Data()[:100])
+	assert.Error(t, err)
+	_, err = ParseEGM96Grid(make([]byte, 721*1441*2))
+	assert.Error(t, err, "the .GRD layout repeating 360°E is not a DAC file")
+	assert.Len(t, egm96Data(), 2076480)
+}
@@ -0,0 +1,396 @@
+package terrain
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TIFF tags read from GeoTIFF files.
+const (
+	tagImageWidth       = 256
+	tagImageLength      = 257
+	tagBitsPerSample    = 258
+	tagCompression      = 259
+	tagStripOffsets     = 273
+	tagSamplesPerPixel  = 277
+	tagRowsPerStrip     = 278
+	tagStripByteCounts  = 279
+	tagPredictor        = 317
+	tagTileWidth        = 322
+	tagTileLength       = 323
+	tagTileOffsets      = 324
+	tagTileByteCounts   = 325
+	tagSampleFormat     = 339
+	tagModelPixelScale  = 33550
+	tagModelTiepoint    = 33922
+	tagGeoKeyDirectory  = 34735
+	tagGDALNoData       = 42113
+	compressionNone     = 1
+	compressionDeflate  = 8
+	compressionDeflate2 = 32946
+	predictorNone       = 1
+	predictorHorizontal = 2
+	sampleFormatUint    = 1
+	sampleFormatInt     = 2
+	sampleFormatFloat   = 3
+	geoKeyModelType     = 1024
+	geoKeyRasterType    = 1025
+	geoKeyGeographic    = 2048
+	geoKeyProjected     = 3072
+	modelTypeGeographic = 2
+	rasterPixelIsPoint  = 2
+	epsgWGS84           = 4326
+)
+
+// ErrUnsupportedGeoTIFF is returned for GeoTIFF files this package cannot read.
+var ErrUnsupportedGeoTIFF = errors.New("unsupported GeoTIFF")
+
+// GeoTIFF is a single band elevation raster in WGS84 latitude and longitude,
+// stored in strips or tiles, uncompressed or deflated.
+type GeoTIFF struct {
+	grid
+}
+
+// LoadGeoTIFF reads a GeoTIFF elevation raster.
+func LoadGeoTIFF(path string) (*GeoTIFF, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	return ParseGeoTIFF(data)
+}
+
+// ParseGeoTIFF parses a GeoTIFF elevation raster.
+func ParseGeoTIFF(data []byte) (*GeoTIFF, error) {
+	r, err := newTIFFReader(data)
+	if err != nil {
+		return nil, err
+	}
+	width, height := r.uint(tagImageWidth, 0), r.uint(tagImageLength, 0)
+	if width < 2 || height < 2 {
+		return nil, fmt.Errorf("%w: raster is %dx%d", ErrUnsupportedGeoTIFF, width, height)
+	}
+	if samples := r.uint(tagSamplesPerPixel, 1); samples != 1 {
+		return nil, fmt.Errorf("%w: %d samples per pixel", ErrUnsupportedGeoTIFF, samples)
+	}
+
+	g := &GeoTIFF{grid: grid{width: width, height: height}}
+	if err := g.georeference(r); err != nil {
+		return nil, err
+	}
+	if g.values, err = r.samples(width, height); err != nil {
+		return nil, err
+	}
+	if noData := strings.TrimSpace(strings.TrimRight(r.ascii(tagGDALNoData), "\x00")); noData != "" {
+		value, err := strconv.ParseFloat(noData, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid no data value %q", ErrUnsupportedGeoTIFF, noData)
+		}
+		g.noData, g.hasNoData = float32(value), true
+	}
+	return g, nil
+}
+
+// georeference reads the position and spacing of the samples from the GeoTIFF tags.
+func (g *GeoTIFF) georeference(r *tiffReader) error {
+	keys := r.geoKeys()
+	if keys[geoKeyProjected] != 0 || (keys[geoKeyModelType] != 0 && keys[geoKeyModelType] != modelTypeGeographic) {
+		return fmt.Errorf("%w: only geographic coordinates are supported", ErrUnsupportedGeoTIFF)
+	}
+	if crs := keys[geoKeyGeographic]; crs != 0 && crs != epsgWGS84 {
+		return fmt.Errorf("%w: geographic CRS EPSG:%d is not WGS84", ErrUnsupportedGeoTIFF, crs)
+	}
+
+	scale, tiepoint := r.floats(tagModelPixelScale), r.floats(tagModelTiepoint)
+	if len(scale) < 2 || len(tiepoint) < 6 || scale[0] <= 0 || scale[1] <= 0 {
+		return fmt.Errorf("%w: missing pixel scale or tiepoint", ErrUnsupportedGeoTIFF)
+	}
+	g.dLon, g.dLat = scale[0], scale[1]
+	g.west = tiepoint[3] - tiepoint[0]*g.dLon
+	g.north = tiepoint[4] + tiepoint[1]*g.dLat
+	if keys[geoKeyRasterType] != rasterPixelIsPoint {
+		// The tiepoint is the corner of the first pixel rather than its center
+		g.west += g.dLon / 2
+		g.north -= g.dLat / 2
+	}
+	return nil
+}
+
+// Bounds returns the extent of the raster in degrees.
+func (g *GeoTIFF) Bounds() (south, west, north, east float64) {
+	return g.north - (float64(g.height)-0.5)*g.dLat, g.west - g.dLon/2,
+		g.north + g.dLat/2, g.west + (float64(g.width)-0.5)*g.dLon
+}
+
+// Elevation returns the interpolated elevation at a position within the raster.
+func (g *GeoTIFF) Elevation(lat, lon float64) (float64, error) {
+	return g.at(lat, lon)
+}
+
+// tiffReader reads the first image file directory of a classic TIFF file.
+type tiffReader struct {
+	data    []byte
+	order   binary.ByteOrder
+	entries map[uint16]tiffEntry
+}
+
+// tiffEntry is a directory entry holding count values of a TIFF field type.
+type tiffEntry struct {
+	fieldType uint16
+	count     uint32
+	value     []byte
+}
+
+// tiffTypeSizes holds the sizes in bytes of TIFF field types.
+var tiffTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 6: 1, 7: 1, 8: 2, 9: 4, 11: 4, 12: 8}
+
+func newTIFFReader(data []byte) (*tiffReader, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("%w: file is too short", ErrUnsupportedGeoTIFF)
+	}
+	r := &tiffReader{data: data, entries: make(map[uint16]tiffEntry)}
+	switch string(data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%w: not a TIFF file", ErrUnsupportedGeoTIFF)
+	}
+	if r.order.Uint16(data[2:]) != 42 {
+		return nil, fmt.Errorf("%w: only classic TIFF is supported", ErrUnsupportedGeoTIFF)
+	}
+
+	offset := int(r.order.Uint32(data[4:]))
+	if offset+2 > len(data) {
+		return nil, fmt.Errorf("%w: truncated directory", ErrUnsupportedGeoTIFF)
+	}
+	count := int(r.order.Uint16(data[offset:]))
+	if offset+2+count*12 > len(data) {
+		return nil, fmt.Errorf("%w: truncated directory", ErrUnsupportedGeoTIFF)
+	}
+	for i := 0; i < count; i++ {
+		entry := data[offset+2+i*12:]
+		tag, fieldType, n := r.order.Uint16(entry), r.order.Uint16(entry[2:]), r.order.Uint32(entry[4:])
+		size, ok := tiffTypeSizes[fieldType]
+		if !ok {
+			continue
+		}
+		length := size * int(n)
+		value := entry[8:12]
+		if length > 4 {
+			start := int(r.order.Uint32(entry[8:]))
+			if start+length > len(data) {
+				return nil, fmt.Errorf("%w: tag %d is truncated", ErrUnsupportedGeoTIFF, tag)
+			}
+			value = data[start : start+length]
+		}
+		r.entries[tag] = tiffEntry{fieldType: fieldType, count: n, value: value[:length]}
+	}
+	return r, nil
+}
+
+// floats returns the values of a numeric tag.
+func (r *tiffReader) floats(tag uint16) []float64 {
+	entry, ok := r.entries[tag]
+	if !ok {
+		return nil
+	}
+	values := make([]float64, entry.count)
+	for i := range values {
+		switch entry.fieldType {
+		case 1, 7:
+			values[i] = float64(entry.value[i])
+		case 3:
+			values[i] = float64(r.order.Uint16(entry.value[i*2:]))
+		case 4:
+			values[i] = float64(r.order.Uint32(entry.value[i*4:]))
+		case 11:
+			values[i] = float64(math.Float32frombits(r.order.Uint32(entry.value[i*4:])))
+		case 12:
+			values[i] = math.Float64frombits(r.order.Uint64(entry.value[i*8:]))
+		}
+	}
+	return values
+}
+
+// uint returns the first value of a numeric tag, or def when it is missing.
+func (r *tiffReader) uint(tag uint16, def int) int {
+	values := r.floats(tag)
+	if len(values) == 0 {
+		return def
+	}
+	return int(values[0])
+}
+
+// ascii returns the value of an ASCII tag.
+func (r *tiffReader) ascii(tag uint16) string {
+	entry, ok := r.entries[tag]
+	if !ok || entry.fieldType != 2 {
+		return ""
+	}
+	return string(entry.value)
+}
+
+// geoKeys returns the short valued keys of the GeoKey directory.
+func (r *tiffReader) geoKeys() map[int]int {
+	keys := make(map[int]int)
+	directory := r.floats(tagGeoKeyDirectory)
+	for i := 4; i+3 < len(directory); i += 4 {
+		// Keys stored in other tags are not needed
+		if directory[i+1] == 0 {
+			keys[int(directory[i])] = int(directory[i+3])
+		}
+	}
+	return keys
+}
+
+// sampleLayout describes how raster samples are stored.
+type sampleLayout struct {
+	width, height           int
+	blockWidth, blockHeight int
+	across                  int
+	tiled                   bool
+	compressed              bool
+	predictor               int
+	format, bits            int
+	decode                  func([]byte) float64
+}
+
+// samples decodes the raster samples row by row.
+func (r *tiffReader) samples(width, height int) ([]float32, error) {
+	layout := &sampleLayout{
+		width:     width,
+		height:    height,
+		format:    r.uint(tagSampleFormat, sampleFormatUint),
+		bits:      r.uint(tagBitsPerSample, 1),
+		predictor: r.uint(tagPredictor, predictorNone),
+	}
+	var err error
+	if layout.decode, err = sampleDecoder(r.order, layout.format, layout.bits); err != nil {
+		return nil, err
+	}
+	compression := r.uint(tagCompression, compressionNone)
+	if compression != compressionNone && compression != compressionDeflate && compression != compressionDeflate2 {
+		return nil, fmt.Errorf("%w: compression %d", ErrUnsupportedGeoTIFF, compression)
+	}
+	layout.compressed = compression != compressionNone
+	if layout.predictor != predictorNone && (layout.predictor != predictorHorizontal || layout.format == sampleFormatFloat) {
+		return nil, fmt.Errorf("%w: predictor %d", ErrUnsupportedGeoTIFF, layout.predictor)
+	}
+
+	// Strips are tiles as wide as the image
+	layout.blockWidth, layout.blockHeight = width, r.uint(tagRowsPerStrip, height)
+	offsets, counts := r.floats(tagStripOffsets), r.floats(tagStripByteCounts)
+	if _, layout.tiled = r.entries[tagTileOffsets]; layout.tiled {
+		layout.blockWidth, layout.blockHeight = r.uint(tagTileWidth, 0), r.uint(tagTileLength, 0)
+		offsets, counts = r.floats(tagTileOffsets), r.floats(tagTileByteCounts)
+	}
+	if layout.blockWidth <= 0 || layout.blockHeight <= 0 || len(offsets) != len(counts) {
+		return nil, fmt.Errorf("%w: missing strips or tiles", ErrUnsupportedGeoTIFF)
+	}
+	layout.across = (width + layout.blockWidth - 1) / layout.blockWidth
+	down := (height + layout.blockHeight - 1) / layout.blockHeight
+	if len(offsets) < layout.across*down {
+		return nil, fmt.Errorf("%w: missing strips or tiles", ErrUnsupportedGeoTIFF)
+	}
+
+	values := make([]float32, width*height)
+	for block := 0; block < layout.across*down; block++ {
+		start, end := int(offsets[block]), int(offsets[block])+int(counts[block])
+		if start < 0 || end > len(r.data) || start > end {
+			return nil, fmt.Errorf("%w: block %d is truncated", ErrUnsupportedGeoTIFF, block)
+		}
+		if err := layout.decodeBlock(values, block, r.data[start:end]); err != nil {
+			return nil, fmt.Errorf("%w: block %d: %w", ErrUnsupportedGeoTIFF, block, err)
+		}
+	}
+	return values, nil
+}
+
+// decodeBlock decodes the samples of a strip or tile into values.
+func (l *sampleLayout) decodeBlock(values []float32, block int, raw []byte) error {
+	sampleSize := l.bits / 8
+	rowSize := l.blockWidth * sampleSize
+	if l.compressed {
+		var err error
+		if raw, err = inflate(raw, rowSize*l.blockHeight); err != nil {
+			return err
+		}
+	}
+	// The last strip may hold fewer rows
+	rows := min(l.blockHeight, len(raw)/rowSize)
+	if l.tiled && rows < l.blockHeight {
+		return errors.New("tile is short")
+	}
+
+	left, top := (block%l.across)*l.blockWidth, (block/l.across)*l.blockHeight
+	row := make([]float64, l.blockWidth)
+	for y := 0; y < rows && top+y < l.height; y++ {
+		for x := range row {
+			row[x] = l.decode(raw[y*rowSize+x*sampleSize:])
+			if l.predictor == predictorHorizontal && x > 0 {
+				row[x] = wrapInteger(row[x]+row[x-1], l.format, l.bits)
+			}
+		}
+		for x := 0; x < l.blockWidth && left+x < l.width; x++ {
+			values[(top+y)*l.width+left+x] = float32(row[x])
+		}
+	}
+	return nil
+}
+
+// sampleDecoder returns a function decoding one sample of a format and size.
+func sampleDecoder(order binary.ByteOrder, format, bits int) (func([]byte) float64, error) {
+	switch {
+	case format == sampleFormatInt && bits == 8:
+		return func(b []byte) float64 { return float64(int8(b[0])) }, nil
+	case format == sampleFormatInt && bits == 16:
+		return func(b []byte) float64 { return float64(int16(order.Uint16(b))) }, nil
+	case format == sampleFormatInt && bits == 32:
+		return func(b []byte) float64 { return float64(int32(order.Uint32(b))) }, nil
+	case format == sampleFormatUint && bits == 8:
+		return func(b []byte) float64 { return float64(b[0]) }, nil
+	case format == sampleFormatUint && bits == 16:
+		return func(b []byte) float64 { return float64(order.Uint16(b)) }, nil
+	case format == sampleFormatUint && bits == 32:
+		return func(b []byte) float64 { return float64(order.Uint32(b)) }, nil
+	case format == sampleFormatFloat && bits == 32:
+		return func(b []byte) float64 { return float64(math.Float32frombits(order.Uint32(b))) }, nil
+	case format == sampleFormatFloat && bits == 64:
+		return func(b []byte) float64 { return math.Float64frombits(order.Uint64(b)) }, nil
+	}
+	return nil, fmt.Errorf("%w: %d bit samples of format %d", ErrUnsupportedGeoTIFF, bits, format)
+}
+
+// wrapInteger wraps the sum of two integer samples like the integer type would overflow.
+func wrapInteger(value float64, format, bits int) float64 {
+	span := math.Exp2(float64(bits))
+	value = math.Mod(value, span)
+	if value < 0 {
+		value += span
+	}
+	if format == sampleFormatInt && value >= span/2 {
+		value -= span
+	}
+	return value
+}
+
+// inflate decompresses a deflated block of at most limit bytes.
+func inflate(data []byte, limit int) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+	return io.ReadAll(io.LimitReader(reader, int64(limit)))
+}
@@ -0,0 +1,228 @@
+package terrain
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// geoTIFFOptions selects how geoTIFFData encodes its raster.
+type geoTIFFOptions struct {
+	bigEndian bool
+	float     bool
+	deflate   bool
+	predictor bool
+	tiled     bool
+	point     bool
+	noData    string
+	projected bool
+}
+
+// tiffField is a directory entry written by geoTIFFData.
+type tiffField struct {
+	tag       uint16
+	fieldType uint16
+	values    any
+}
+
+// geoTIFFData encodes a 4x4 raster covering 20-21°N 10-11°E whose samples
+// are row*4+col, in 2 strips of 2 rows or 4 tiles of 2x2 samples.
+func geoTIFFData(t *testing.T, opts geoTIFFOptions) []byte {
+	t.Helper()
+	var order binary.ByteOrder = binary.LittleEndian
+	header := []byte("II*\x00")
+	if opts.bigEndian {
+		order = binary.BigEndian
+		header = []byte("MM\x00*")
+	}
+
+	blocks, blockWidth := [][]int{{0, 1, 2, 3, 4, 5, 6, 7}, {8, 9, 10, 11, 12, 13, 14, 15}}, 4
+	if opts.tiled {
+		blocks, blockWidth = [][]int{{0, 1, 4, 5}, {2, 3, 6, 7}, {8, 9, 12, 13}, {10, 11, 14, 15}}, 2
+	}
+
+	var data bytes.Buffer
+	data.Write(header)
+	_ = binary.Write(&data, order, uint32(0))
+	var offsets, counts []uint32
+	for _, block := range blocks {
+		var raw bytes.Buffer
+		for i, sample := range block {
+			value := sample
+			if opts.predictor && i%blockWidth > 0 {
+				value = sample - block[i-1]
+			}
+			if opts.float {
+				_ = binary.Write(&raw, order, float32(value))
+			} else {
+				_ = binary.Write(&raw, order, int16(value))
+			}
+		}
+		encoded := raw.Bytes()
+		if opts.deflate {
+			var compressed bytes.Buffer
+			w := zlib.NewWriter(&compressed)
+			_, err := w.Write(encoded)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			encoded = compressed.Bytes()
+		}
+		offsets = append(offsets, uint32(data.Len()))
+		counts = append(counts, uint32(len(encoded)))
+		data.Write(encoded)
+	}
+
+	bits, format, compression, predictor := uint16(16), uint16(sampleFormatInt), uint16(compressionNone), uint16(predictorNone)
+	if opts.float {
+		bits, format = 32, sampleFormatFloat
+	}
+	if opts.deflate {
+		compression = compressionDeflate
+	}
+	if opts.predictor {
+		predictor = predictorHorizontal
+	}
+	rasterType := uint16(1)
+	tiepoint := []float64{0, 0, 0, 10, 21, 0}
+	if opts.point {
+		// Sample centers run from the corners inwards by half a sample
+		rasterType = rasterPixelIsPoint
+		tiepoint = []float64{0, 0, 0, 10.125, 20.875, 0}
+	}
+	geoKeys := []uint16{1, 1, 0, 3, geoKeyModelType, 0, 1, modelTypeGeographic, geoKeyRasterType, 0, 1, rasterType, geoKeyGeographic, 0, 1, epsgWGS84}
+	if opts.projected {
+		geoKeys = []uint16{1, 1, 0, 1, geoKeyProjected, 0, 1, 32650}
+	}
+
+	fields := []tiffField{
+		{tagImageWidth, 3, []uint16{4}},
+		{tagImageLength, 3, []uint16{4}},
+		{tagBitsPerSample, 3, []uint16{bits}},
+		{tagCompression, 3, []uint16{compression}},
+		{tagSamplesPerPixel, 3, []uint16{1}},
+		{tagPredictor, 3, []uint16{predictor}},
+		{tagSampleFormat, 3, []uint16{format}},
+		{tagModelPixelScale, 12, []float64{0.25, 0.25, 0}},
+		{tagModelTiepoint, 12, tiepoint},
+		{tagGeoKeyDirectory, 3, geoKeys},
+	}
+	if opts.tiled {
+		fields = append(fields,
+			tiffField{tagTileWidth, 3, []uint16{2}},
+			tiffField{tagTileLength, 3, []uint16{2}},
+			tiffField{tagTileOffsets, 4, offsets},
+			tiffField{tagTileByteCounts, 4, counts})
+	} else {
+		fields = append(fields,
+			tiffField{tagStripOffsets, 4, offsets},
+			tiffField{tagRowsPerStrip, 3, []uint16{2}},
+			tiffField{tagStripByteCounts, 4, counts})
+	}
+	if opts.noData != "" {
+		fields = append(fields, tiffField{tagGDALNoData, 2, []byte(opts.noData + "\x00")})
+	}
+
+	// Values longer than 4 bytes follow the directory
+	directory := uint32(data.Len())
+	order.PutUint32(data.Bytes()[4:], directory)
+	extra := directory + 2 + uint32(len(fields))*12 + 4
+	var values bytes.Buffer
+	_ = binary.Write(&data, order, uint16(len(fields)))
+	for _, field := range fields {
+		var encoded bytes.Buffer
+		_ = binary.Write(&encoded, order, field.values)
+		count := uint32(encoded.Len() / tiffTypeSizes[field.fieldType])
+		_ = binary.Write(&data, order, field.tag)
+		_ = binary.Write(&data, order, field.fieldType)
+		_ = binary.Write(&data, order, count)
+		if encoded.Len() <= 4 {
+			inline := make([]byte, 4)
+			copy(inline, encoded.Bytes())
+			data.Write(inline)
+			continue
+		}
+		_ = binary.Write(&data, order, extra+uint32(values.Len()))
+		values.Write(encoded.Bytes())
+	}
+	_ = binary.Write(&data, order, uint32(0))
+	data.Write(values.Bytes())
+	return data.Bytes()
+}
+
+func TestParseGeoTIFF(t *testing.T) {
+	tests := []struct {
+		name string
+		opts geoTIFFOptions
+	}{
+		{"int16 strips", geoTIFFOptions{}},
+		{"big endian", geoTIFFOptions{bigEndian: true}},
+		{"float32 deflated tiles", geoTIFFOptions{float: true, deflate: true, tiled: true}},
+		{"int16 deflated with predictor", geoTIFFOptions{deflate: true, predictor: true}},
+		{"pixel is point", geoTIFFOptions{point: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raster, err := ParseGeoTIFF(geoTIFFData(t, tt.opts))
+			require.NoError(t, err)
+
+			south, west, north, east := raster.Bounds()
+			assert.InDelta(t, 20, south, 1e-9)
+			assert.InDelta(t, 10, west, 1e-9)
+			assert.InDelta(t, 21, north, 1e-9)
+			assert.InDelta(t, 11, east, 1e-9)
+
+			// The north west sample is centered an eighth of a degree in
+			elevation, err := raster.Elevation(20.875, 10.125)
+			require.NoError(t, err)
+			assert.InDelta(t, 0, elevation, 1e-6)
+			elevation, err = raster.Elevation(20.125, 10.875)
+			require.NoError(t, err)
+			assert.InDelta(t, 15, elevation, 1e-6)
+			elevation, err = raster.Elevation(20.5, 10.5)
+			require.NoError(t, err)
+			assert.InDelta(t, 7.5, elevation, 1e-6)
+			// Positions beyond the outer sample centers take their value
+			elevation, err = raster.Elevation(20.99, 10.01)
+			require.NoError(t, err)
+			assert.InDelta(t, 0, elevation, 1e-6)
+
+			_, err = raster.Elevation(21.5, 10.5)
+			assert.ErrorIs(t, err, ErrOutOfCoverage)
+		})
+	}
+}
+
+func TestParseGeoTIFF_NoData(t *testing.T) {
+	raster, err := ParseGeoTIFF(geoTIFFData(t, geoTIFFOptions{noData: "5"}))
+	require.NoError(t, err)
+
+	_, err = raster.Elevation(20.5, 10.5)
+	assert.ErrorIs(t, err, ErrNoData)
+	elevation, err := raster.Elevation(20.125, 10.875)
+	require.NoError(t, err)
+	assert.InDelta(t, 15, elevation, 1e-6)
+}
+
+func TestParseGeoTIFF_Unsupported(t *testing.T) {
+	_, err := ParseGeoTIFF(geoTIFFData(t, geoTIFFOptions{projected: true}))
+	assert.ErrorIs(t, err, ErrUnsupportedGeoTIFF)
+
+	_, err = ParseGeoTIFF([]byte("not a tiff file"))
+	assert.ErrorIs(t, err, ErrUnsupportedGeoTIFF)
+
+	data := geoTIFFData(t, geoTIFFOptions{})
+	_, err = ParseGeoTIFF(data[:len(data)/2])
+	assert.ErrorIs(t, err, ErrUnsupportedGeoTIFF)
+}
+
+func TestWrapInteger(t *testing.T) {
+	assert.Equal(t, -32768.0, wrapInteger(32768, sampleFormatInt, 16))
+	assert.Equal(t, 0.0, wrapInteger(256, sampleFormatUint, 8))
+	assert.Equal(t, 255.0, wrapInteger(-1, sampleFormatUint, 8))
+	assert.False(t, math.IsNaN(wrapInteger(1, sampleFormatInt, 32)))
+}
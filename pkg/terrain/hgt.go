@@ -0,0 +1,93 @@
+package terrain
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hgtVoid marks SRTM samples without data.
+const hgtVoid = -32768
+
+// HGTTile is an SRTM height tile covering one degree of latitude and
+// longitude, with 1 (3601 samples) or 3 (1201 samples) arc second spacing.
+type HGTTile struct {
+	grid
+	name string
+}
+
+// HGTTileName returns the name of the SRTM tile covering a position, such as N39E116.
+func HGTTileName(lat, lon float64) string {
+	south, west := int(math.Floor(lat)), int(math.Floor(lon))
+	ns, ew := 'N', 'E'
+	if south < 0 {
+		ns, south = 'S', -south
+	}
+	if west < 0 {
+		ew, west = 'W', -west
+	}
+	return fmt.Sprintf("%c%02d%c%03d", ns, south, ew, west)
+}
+
+// LoadHGT reads an SRTM tile whose file name gives its south west corner.
+func LoadHGT(path string) (*HGTTile, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	return ParseHGT(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), data)
+}
+
+// ParseHGT parses the big-endian 16 bit samples of the SRTM tile with the given name.
+func ParseHGT(name string, data []byte) (*HGTTile, error) {
+	var ns, ew byte
+	var south, west int
+	if _, err := fmt.Sscanf(strings.ToUpper(name), "%c%2d%c%3d", &ns, &south, &ew, &west); err != nil ||
+		(ns != 'N' && ns != 'S') || (ew != 'E' && ew != 'W') {
+		return nil, fmt.Errorf("invalid HGT tile name %q", name)
+	}
+	if ns == 'S' {
+		south = -south
+	}
+	if ew == 'W' {
+		west = -west
+	}
+
+	size := int(math.Sqrt(float64(len(data) / 2)))
+	if size < 2 || size*size*2 != len(data) {
+		return nil, fmt.Errorf("invalid HGT tile %s: %d bytes is not a square grid", name, len(data))
+	}
+
+	values := make([]float32, size*size)
+	for i := range values {
+		values[i] = float32(int16(binary.BigEndian.Uint16(data[i*2:])))
+	}
+	spacing := 1 / float64(size-1)
+	return &HGTTile{
+		name: HGTTileName(float64(south), float64(west)),
+		grid: grid{
+			west:      float64(west),
+			north:     float64(south + 1),
+			dLon:      spacing,
+			dLat:      spacing,
+			width:     size,
+			height:    size,
+			values:    values,
+			noData:    hgtVoid,
+			hasNoData: true,
+		},
+	}, nil
+}
+
+// Name returns the name of the tile.
+func (t *HGTTile) Name() string {
+	return t.name
+}
+
+// Elevation returns the interpolated elevation at a position within the tile.
+func (t *HGTTile) Elevation(lat, lon float64) (float64, error) {
+	return t.at(lat, lon)
+}
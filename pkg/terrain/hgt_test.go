@@ -0,0 +1,65 @@
+package terrain
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hgtData encodes a size by size tile whose samples rise 10 m per column
+// eastwards and 1 m per row southwards.
+func hgtData(size int) []byte {
+	data := make([]byte, size*size*2)
+	for row := 0; row < size; row++ {
+		for col := 0; col < size; col++ {
+			binary.BigEndian.PutUint16(data[(row*size+col)*2:], uint16(int16(col*10+row)))
+		}
+	}
+	return data
+}
+
+func TestHGTTileName(t *testing.T) {
+	assert.Equal(t, "N39E116", HGTTileName(39.9, 116.4))
+	assert.Equal(t, "S34W071", HGTTileName(-33.4, -70.6))
+	assert.Equal(t, "N00W001", HGTTileName(0.5, -0.5))
+}
+
+func TestParseHGT(t *testing.T) {
+	tile, err := ParseHGT("N39E116", hgtData(11))
+	require.NoError(t, err)
+	assert.Equal(t, "N39E116", tile.Name())
+
+	// Samples are 0.1° apart from the north west corner
+	elevation, err := tile.Elevation(40, 116)
+	require.NoError(t, err)
+	assert.InDelta(t, 0, elevation, 1e-9)
+	elevation, err = tile.Elevation(39, 117)
+	require.NoError(t, err)
+	assert.InDelta(t, 110, elevation, 1e-9)
+	elevation, err = tile.Elevation(39.75, 116.25)
+	require.NoError(t, err)
+	assert.InDelta(t, 25+2.5, elevation, 1e-9)
+
+	_, err = tile.Elevation(40.5, 116.5)
+	assert.ErrorIs(t, err, ErrOutOfCoverage)
+
+	_, err = ParseHGT("X39E116", hgtData(11))
+	assert.Error(t, err)
+	_, err = ParseHGT("N39E116", hgtData(11)[:100])
+	assert.Error(t, err)
+}
+
+func TestParseHGT_Void(t *testing.T) {
+	data := hgtData(3)
+	binary.BigEndian.PutUint16(data[8:], uint16(0x8000))
+	tile, err := ParseHGT("S01W001", data)
+	require.NoError(t, err)
+
+	_, err = tile.Elevation(-0.5, -0.5)
+	assert.ErrorIs(t, err, ErrNoData)
+	elevation, err := tile.Elevation(-1, -1)
+	require.NoError(t, err)
+	assert.InDelta(t, 2, elevation, 1e-9)
+}
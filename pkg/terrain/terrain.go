@@ -0,0 +1,98 @@
+// Package terrain provides terrain elevations from local digital elevation
+// model (DEM) files and geoid undulations for converting between heights
+// above the EGM96 geoid and the WGS84 ellipsoid.
+package terrain
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	// ErrOutOfCoverage is returned for positions no loaded data covers.
+	ErrOutOfCoverage = errors.New("position is outside the terrain data")
+	// ErrNoData is returned for positions whose samples are voids.
+	ErrNoData = errors.New("terrain data has a void at position")
+)
+
+// DEM provides terrain elevations in meters above the EGM96 geoid, as SRTM
+// and most other elevation models record them.
+type DEM interface {
+	Elevation(lat, lon float64) (float64, error)
+}
+
+// Geoid provides geoid undulations: the height in meters of the geoid above
+// the WGS84 ellipsoid.
+type Geoid interface {
+	Undulation(lat, lon float64) (float64, error)
+}
+
+// EllipsoidHeight converts a height above the geoid into a height above the WGS84 ellipsoid.
+func EllipsoidHeight(geoid Geoid, lat, lon, height float64) (float64, error) {
+	undulation, err := geoid.Undulation(lat, lon)
+	if err != nil {
+		return 0, err
+	}
+	return height + undulation, nil
+}
+
+// OrthometricHeight converts a height above the WGS84 ellipsoid into a height above the geoid.
+func OrthometricHeight(geoid Geoid, lat, lon, height float64) (float64, error) {
+	undulation, err := geoid.Undulation(lat, lon)
+	if err != nil {
+		return 0, err
+	}
+	return height - undulation, nil
+}
+
+// grid is a raster of samples on a regular latitude/longitude grid, stored
+// row by row from north to south.
+type grid struct {
+	// west and north are the longitude and latitude of the first sample
+	west, north float64
+	// dLon and dLat are the sample spacings in degrees
+	dLon, dLat    float64
+	width, height int
+	values        []float32
+	noData        float32
+	hasNoData     bool
+}
+
+// covers reports whether a position lies within the grid, allowing half a
+// sample beyond the outer samples for rasters whose samples are areas.
+func (g *grid) covers(lat, lon float64) bool {
+	x := (lon - g.west) / g.dLon
+	y := (g.north - lat) / g.dLat
+	return x >= -0.5 && y >= -0.5 && x <= float64(g.width)-0.5 && y <= float64(g.height)-0.5
+}
+
+// at interpolates the grid bilinearly at a position.
+func (g *grid) at(lat, lon float64) (float64, error) {
+	if !g.covers(lat, lon) {
+		return 0, ErrOutOfCoverage
+	}
+	x := math.Min(math.Max((lon-g.west)/g.dLon, 0), float64(g.width-1))
+	y := math.Min(math.Max((g.north-lat)/g.dLat, 0), float64(g.height-1))
+	x0, y0 := min(int(x), g.width-2), min(int(y), g.height-2)
+	x0, y0 = max(x0, 0), max(y0, 0)
+	x1, y1 := min(x0+1, g.width-1), min(y0+1, g.height-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	corners := [4]float32{
+		g.values[y0*g.width+x0], g.values[y0*g.width+x1],
+		g.values[y1*g.width+x0], g.values[y1*g.width+x1],
+	}
+	weights := [4]float64{(1 - fx) * (1 - fy), fx * (1 - fy), (1 - fx) * fy, fx * fy}
+	elevation := 0.0
+	for i, v := range corners {
+		// Voids only matter when they contribute to the result
+		if weights[i] == 0 {
+			continue
+		}
+		if g.hasNoData && v == g.noData || math.IsNaN(float64(v)) {
+			return 0, ErrNoData
+		}
+		elevation += float64(v) * weights[i]
+	}
+	return elevation, nil
+}